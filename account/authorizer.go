@@ -3,7 +3,9 @@ package account
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/ReneKroon/ttlcache"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -14,18 +16,43 @@ import (
 	"github.com/code-payments/flipcash2-server/auth"
 )
 
+// keyUsageGranularity is how often the authorizer records a key's last-used
+// time, since a key is used on every authorized request.
+const keyUsageGranularity = time.Minute
+
 type Authorizer struct {
 	log   *zap.Logger
 	store Store
 	authn auth.Authenticator
+
+	// keyUsage holds the keys whose use was recorded within the last
+	// keyUsageGranularity.
+	keyUsage *ttlcache.Cache
+
+	nonces       NonceStore
+	replayWindow time.Duration
+	nonceTTL     time.Duration
 }
 
-func NewAuthorizer(log *zap.Logger, store Store, authn auth.Authenticator) *Authorizer {
-	return &Authorizer{
+func NewAuthorizer(log *zap.Logger, store Store, authn auth.Authenticator, opts ...AuthorizerOption) *Authorizer {
+	keyUsage := ttlcache.NewCache()
+	keyUsage.SetTTL(keyUsageGranularity)
+	keyUsage.SkipTtlExtensionOnHit(true)
+
+	a := &Authorizer{
 		log:   log,
 		store: store,
 		authn: authn,
+
+		keyUsage: keyUsage,
+
+		replayWindow: defaultReplayWindow,
+		nonceTTL:     defaultNonceTTL,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 func (a *Authorizer) Authorize(ctx context.Context, m proto.Message, authField **commonpb.Auth) (*commonpb.UserId, error) {
@@ -47,5 +74,48 @@ func (a *Authorizer) Authorize(ctx context.Context, m proto.Message, authField *
 		return nil, status.Error(codes.Internal, "failed to verify auth")
 	}
 
+	if err := a.checkSuspension(ctx, m, userID); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkReplay(ctx, m, authMessage.GetKeyPair().GetSignature()); err != nil {
+		return nil, err
+	}
+
+	a.markKeyUsed(ctx, authMessage.GetKeyPair().GetPubKey())
+
 	return userID, nil
 }
+
+// markKeyUsed records a key's use at most once per keyUsageGranularity. Last-used
+// times are informational, so failing to record one does not fail the request.
+func (a *Authorizer) markKeyUsed(ctx context.Context, pubKey *commonpb.PublicKey) {
+	if _, ok := a.keyUsage.Get(string(pubKey.GetValue())); ok {
+		return
+	}
+	if err := a.store.MarkPubKeyUsed(ctx, pubKey, time.Now()); err != nil {
+		a.log.Warn("Failed to mark key used", zap.Error(err))
+		return
+	}
+	a.keyUsage.Set(string(pubKey.GetValue()), true)
+}
+
+// checkSuspension denies a suspended user the requests its suspension does not
+// allow: every request when banned, and writes when read-only.
+func (a *Authorizer) checkSuspension(ctx context.Context, m proto.Message, userID *commonpb.UserId) error {
+	suspension, err := a.store.GetSuspension(ctx, userID)
+	if errors.Is(err, ErrNotSuspended) {
+		return nil
+	} else if err != nil {
+		a.log.Warn("Failed to get suspension", zap.Error(err))
+		return status.Error(codes.Internal, "failed to verify auth")
+	}
+
+	switch {
+	case !suspension.State.AllowsReads():
+		return status.Error(codes.PermissionDenied, "account banned")
+	case !suspension.State.AllowsWrites() && !isReadRequest(m):
+		return status.Error(codes.PermissionDenied, "account read-only")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/ReneKroon/ttlcache"
 	"google.golang.org/protobuf/proto"
@@ -12,19 +13,37 @@ import (
 	"github.com/code-payments/flipcash2-server/account"
 )
 
+// suspensionCacheTTL bounds how long another server instance keeps honoring a
+// suspension lifted, or missing one applied, through a different instance.
+const suspensionCacheTTL = time.Minute
+
+// keyCacheTTL bounds how long another server instance keeps authorizing a key
+// revoked through a different instance.
+const keyCacheTTL = time.Minute
+
 type Cache struct {
 	db                  account.Store
 	pubkeyToUserCache   *ttlcache.Cache
 	registeredUserCache *ttlcache.Cache
 	staffFlagCache      *ttlcache.Cache
+	suspensionCache     *ttlcache.Cache
 }
 
 func NewInCache(db account.Store) account.Store {
+	suspensionCache := ttlcache.NewCache()
+	suspensionCache.SetTTL(suspensionCacheTTL)
+	suspensionCache.SkipTtlExtensionOnHit(true)
+
+	pubkeyToUserCache := ttlcache.NewCache()
+	pubkeyToUserCache.SetTTL(keyCacheTTL)
+	pubkeyToUserCache.SkipTtlExtensionOnHit(true)
+
 	return &Cache{
 		db:                  db,
-		pubkeyToUserCache:   ttlcache.NewCache(),
+		pubkeyToUserCache:   pubkeyToUserCache,
 		registeredUserCache: ttlcache.NewCache(),
 		staffFlagCache:      ttlcache.NewCache(),
+		suspensionCache:     suspensionCache,
 	}
 }
 
@@ -54,6 +73,29 @@ func (c *Cache) GetPubKeys(ctx context.Context, userID *commonpb.UserId) ([]*com
 	return c.db.GetPubKeys(ctx, userID)
 }
 
+func (c *Cache) AddPubKey(ctx context.Context, userID *commonpb.UserId, key *account.Key) error {
+	return c.db.AddPubKey(ctx, userID, key)
+}
+
+func (c *Cache) GetKeys(ctx context.Context, userID *commonpb.UserId) ([]*account.Key, error) {
+	return c.db.GetKeys(ctx, userID)
+}
+
+func (c *Cache) GetPrimaryPubKey(ctx context.Context, userID *commonpb.UserId) (*commonpb.PublicKey, error) {
+	return c.db.GetPrimaryPubKey(ctx, userID)
+}
+
+// RevokePubKey also evicts the key's cached binding, so it stops authorizing on
+// this instance immediately.
+func (c *Cache) RevokePubKey(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) error {
+	defer c.pubkeyToUserCache.Remove(string(pubKey.Value))
+	return c.db.RevokePubKey(ctx, userID, pubKey)
+}
+
+func (c *Cache) MarkPubKeyUsed(ctx context.Context, pubKey *commonpb.PublicKey, at time.Time) error {
+	return c.db.MarkPubKeyUsed(ctx, pubKey, at)
+}
+
 func (c *Cache) IsAuthorized(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) (bool, error) {
 	linkedUserID, err := c.GetUserId(ctx, pubKey)
 	if err != nil {
@@ -90,3 +132,57 @@ func (c *Cache) IsRegistered(ctx context.Context, userID *commonpb.UserId) (bool
 func (c *Cache) SetRegistrationFlag(ctx context.Context, userID *commonpb.UserId, isRegistered bool) error {
 	return c.db.SetRegistrationFlag(ctx, userID, isRegistered)
 }
+
+func (c *Cache) Suspend(ctx context.Context, suspension *account.Suspension) error {
+	defer c.suspensionCache.Remove(string(suspension.UserID.Value))
+	return c.db.Suspend(ctx, suspension)
+}
+
+// GetSuspension caches whether a user is suspended, since it is checked on every
+// authorized request. A cached suspension that has since expired is not
+// returned.
+func (c *Cache) GetSuspension(ctx context.Context, userID *commonpb.UserId) (*account.Suspension, error) {
+	cached, ok := c.suspensionCache.Get(string(userID.Value))
+	if !ok {
+		suspension, err := c.db.GetSuspension(ctx, userID)
+		if err == nil {
+			c.suspensionCache.Set(string(userID.Value), suspension.Clone())
+		} else if err == account.ErrNotSuspended {
+			c.suspensionCache.Set(string(userID.Value), (*account.Suspension)(nil))
+		}
+		return suspension, err
+	}
+
+	suspension := cached.(*account.Suspension)
+	if suspension == nil || !suspension.InForce(time.Now()) {
+		return nil, account.ErrNotSuspended
+	}
+	return suspension.Clone(), nil
+}
+
+func (c *Cache) LiftSuspension(ctx context.Context, userID, liftedBy *commonpb.UserId, reason string, at time.Time) error {
+	defer c.suspensionCache.Remove(string(userID.Value))
+	return c.db.LiftSuspension(ctx, userID, liftedBy, reason, at)
+}
+
+func (c *Cache) GetSuspensions(ctx context.Context, userID *commonpb.UserId) ([]*account.Suspension, error) {
+	return c.db.GetSuspensions(ctx, userID)
+}
+
+// DeleteUser also evicts the user's cached key bindings and flags, so a deleted
+// account's keys stop authorizing on this instance immediately.
+func (c *Cache) DeleteUser(ctx context.Context, userID *commonpb.UserId) error {
+	pubKeys, err := c.db.GetPubKeys(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, pubKey := range pubKeys {
+			c.pubkeyToUserCache.Remove(string(pubKey.Value))
+		}
+		c.registeredUserCache.Remove(string(userID.Value))
+		c.staffFlagCache.Remove(string(userID.Value))
+	}()
+	return c.db.DeleteUser(ctx, userID)
+}
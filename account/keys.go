@@ -0,0 +1,142 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mr-tron/base58"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+const maxKeyLabelLength = 64
+
+// Key is a public key bound to an account, one per device the user signs in on.
+type Key struct {
+	PubKey *commonpb.PublicKey
+
+	// Label is the user's name for the device holding the key. The key bound at
+	// registration has none.
+	Label string
+
+	// IsPrimary marks the key bound at registration. It owns the user's payment
+	// account, so it is what the user resolves to and it cannot be revoked.
+	IsPrimary bool
+
+	CreatedAt time.Time
+
+	// LastUsedAt is when the key last authorized a request, to within a
+	// minute. Zero if it never has.
+	LastUsedAt time.Time
+}
+
+func (k *Key) Clone() *Key {
+	cloned := *k
+	cloned.PubKey = proto.Clone(k.PubKey).(*commonpb.PublicKey)
+	return &cloned
+}
+
+// AddKey binds a new device key to the caller. The request is authorized by one
+// of the caller's existing keys, and signed by the new key over the caller's
+// UserId.
+func (s *Server) AddKey(ctx context.Context, req *AddKeyRequest) (*Key, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(zap.String("user_id", model.UserIDString(caller)))
+
+	if err := validateKeyLabel(req.Label); err != nil {
+		return nil, err
+	}
+
+	err = s.verifier.Verify(ctx, caller, &commonpb.Auth{
+		Kind: &commonpb.Auth_KeyPair_{
+			KeyPair: &commonpb.Auth_KeyPair{
+				PubKey:    req.PubKey,
+				Signature: req.Signature,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{
+		PubKey:    req.PubKey,
+		Label:     req.Label,
+		CreatedAt: time.Now(),
+	}
+	err = s.store.AddPubKey(ctx, caller, key)
+	if errors.Is(err, ErrKeyBound) {
+		return nil, status.Error(codes.AlreadyExists, "public key already bound")
+	} else if errors.Is(err, ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	} else if err != nil {
+		log.Warn("Failed to add key", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to add key")
+	}
+	log.Info("Key added", zap.String("public_key", base58.Encode(req.PubKey.Value)))
+	return key, nil
+}
+
+// ListKeys returns the caller's keys, oldest first.
+func (s *Server) ListKeys(ctx context.Context, req *ListKeysRequest) ([]*Key, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.store.GetKeys(ctx, caller)
+	if err != nil {
+		s.log.Warn("Failed to get keys", zap.String("user_id", model.UserIDString(caller)), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get keys")
+	}
+	return keys, nil
+}
+
+// RevokeKey unbinds one of the caller's keys, such as that of a lost or
+// compromised device, which can no longer authorize requests. The primary key
+// cannot be revoked.
+func (s *Server) RevokeKey(ctx context.Context, req *RevokeKeyRequest) error {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return err
+	}
+
+	log := s.log.With(
+		zap.String("user_id", model.UserIDString(caller)),
+		zap.String("public_key", base58.Encode(req.PubKey.GetValue())),
+	)
+
+	err = s.store.RevokePubKey(ctx, caller, req.PubKey)
+	if errors.Is(err, ErrNotFound) {
+		return status.Error(codes.NotFound, "key not found")
+	} else if errors.Is(err, ErrPrimaryKey) {
+		return status.Error(codes.FailedPrecondition, "cannot revoke primary key")
+	} else if err != nil {
+		log.Warn("Failed to revoke key", zap.Error(err))
+		return status.Error(codes.Internal, "failed to revoke key")
+	}
+	log.Info("Key revoked")
+	return nil
+}
+
+func validateKeyLabel(label string) error {
+	if !utf8.ValidString(label) {
+		return status.Error(codes.InvalidArgument, "invalid label")
+	}
+	if utf8.RuneCountInString(label) > maxKeyLabelLength {
+		return status.Error(codes.InvalidArgument, "label too long")
+	}
+	return nil
+}
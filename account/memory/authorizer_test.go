@@ -13,3 +13,13 @@ func TestAccount_MemoryAuthorizer(t *testing.T) {
 	}
 	tests.RunAuthorizerTests(t, testStore, teardown)
 }
+
+func TestAccount_MemoryReplayProtection(t *testing.T) {
+	testStore := NewInMemory()
+	testNonces := NewInMemoryNonceStore()
+	teardown := func() {
+		testStore.(*memory).reset()
+		testNonces.(*nonceMemory).reset()
+	}
+	tests.RunReplayTests(t, testStore, testNonces, teardown)
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/code-payments/flipcash2-server/account"
+)
+
+type nonceMemory struct {
+	sync.Mutex
+
+	// maps a nonce (string representation) to when it expires
+	nonces map[string]time.Time
+}
+
+// NewInMemoryNonceStore returns an in-memory account.NonceStore, which only
+// rejects replays against the instance holding it.
+func NewInMemoryNonceStore() account.NonceStore {
+	return &nonceMemory{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (m *nonceMemory) reset() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nonces = make(map[string]time.Time)
+}
+
+func (m *nonceMemory) Claim(_ context.Context, nonce []byte, expiresAt time.Time) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if existing, ok := m.nonces[string(nonce)]; ok && existing.After(time.Now()) {
+		return false, nil
+	}
+	m.nonces[string(nonce)] = expiresAt
+	return true, nil
+}
+
+func (m *nonceMemory) DeleteExpired(_ context.Context, before time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for nonce, expiresAt := range m.nonces {
+		if !expiresAt.After(before) {
+			delete(m.nonces, nonce)
+		}
+	}
+	return nil
+}
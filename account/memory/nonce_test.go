@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/code-payments/flipcash2-server/account/tests"
+)
+
+func TestAccount_MemoryNonceStore(t *testing.T) {
+	testStore := NewInMemoryNonceStore()
+	teardown := func() {
+		testStore.(*nonceMemory).reset()
+	}
+	tests.RunNonceStoreTests(t, testStore, teardown)
+}
@@ -3,7 +3,9 @@ package memory
 import (
 	"bytes"
 	"context"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/mr-tron/base58"
 
@@ -23,15 +25,23 @@ type memory struct {
 	// maps a publicKey (string representation) to a userID (also stored as a string). This allows quick lookups of the user by their public key.
 	keys map[string]string
 
+	// maps a publicKey (string representation) to its metadata
+	keyInfo map[string]*account.Key
+
 	// set of registered users
 	registeredUsers map[string]any
+
+	// maps a userID to every suspension applied to it, oldest first
+	suspensions map[string][]*account.Suspension
 }
 
 func NewInMemory() account.Store {
 	return &memory{
 		users:           make(map[string][]string),
 		keys:            make(map[string]string),
+		keyInfo:         make(map[string]*account.Key),
 		registeredUsers: make(map[string]any),
+		suspensions:     make(map[string][]*account.Suspension),
 	}
 }
 
@@ -41,6 +51,8 @@ func (m *memory) reset() {
 
 	m.users = make(map[string][]string)
 	m.keys = make(map[string]string)
+	m.keyInfo = make(map[string]*account.Key)
+	m.suspensions = make(map[string][]*account.Suspension)
 }
 
 func (m *memory) Bind(_ context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) (*commonpb.UserId, error) {
@@ -59,10 +71,89 @@ func (m *memory) Bind(_ context.Context, userID *commonpb.UserId, pubKey *common
 	m.users[string(userID.Value)] = keys
 
 	m.keys[string(pubKey.Value)] = string(userID.Value)
+	m.keyInfo[string(pubKey.Value)] = &account.Key{
+		PubKey:    proto.Clone(pubKey).(*commonpb.PublicKey),
+		IsPrimary: true,
+		CreatedAt: time.Now(),
+	}
 
 	return proto.Clone(userID).(*commonpb.UserId), nil
 }
 
+func (m *memory) AddPubKey(_ context.Context, userID *commonpb.UserId, key *account.Key) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.keys[string(key.PubKey.Value)]; ok {
+		return account.ErrKeyBound
+	}
+
+	keys := m.users[string(userID.Value)]
+	if len(keys) == 0 {
+		return account.ErrNotFound
+	}
+	m.users[string(userID.Value)] = append(keys, string(key.PubKey.Value))
+
+	m.keys[string(key.PubKey.Value)] = string(userID.Value)
+	cloned := key.Clone()
+	cloned.IsPrimary = false
+	m.keyInfo[string(key.PubKey.Value)] = cloned
+
+	return nil
+}
+
+func (m *memory) GetKeys(_ context.Context, userID *commonpb.UserId) ([]*account.Key, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var res []*account.Key
+	for _, key := range m.users[string(userID.Value)] {
+		res = append(res, m.keyInfo[key].Clone())
+	}
+	return res, nil
+}
+
+func (m *memory) GetPrimaryPubKey(_ context.Context, userID *commonpb.UserId) (*commonpb.PublicKey, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, key := range m.users[string(userID.Value)] {
+		if m.keyInfo[key].IsPrimary {
+			return &commonpb.PublicKey{Value: []byte(key)}, nil
+		}
+	}
+	return nil, account.ErrNotFound
+}
+
+func (m *memory) RevokePubKey(_ context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.keys[string(pubKey.Value)] != string(userID.Value) {
+		return account.ErrNotFound
+	}
+	if m.keyInfo[string(pubKey.Value)].IsPrimary {
+		return account.ErrPrimaryKey
+	}
+
+	m.users[string(userID.Value)] = slices.DeleteFunc(m.users[string(userID.Value)], func(key string) bool {
+		return key == string(pubKey.Value)
+	})
+	delete(m.keys, string(pubKey.Value))
+	delete(m.keyInfo, string(pubKey.Value))
+	return nil
+}
+
+func (m *memory) MarkPubKeyUsed(_ context.Context, pubKey *commonpb.PublicKey, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if key, ok := m.keyInfo[string(pubKey.Value)]; ok && at.After(key.LastUsedAt) {
+		key.LastUsedAt = at
+	}
+	return nil
+}
+
 func (m *memory) GetUserId(_ context.Context, pubKey *commonpb.PublicKey) (*commonpb.UserId, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -147,3 +238,80 @@ func (m *memory) SetRegistrationFlag(ctx context.Context, userID *commonpb.UserI
 	}
 	return nil
 }
+
+func (m *memory) Suspend(_ context.Context, suspension *account.Suspension) error {
+	m.Lock()
+	defer m.Unlock()
+
+	key := string(suspension.UserID.Value)
+	if current := m.inForce(key, suspension.CreatedAt); current != nil {
+		current.LiftedBy = proto.Clone(suspension.SuspendedBy).(*commonpb.UserId)
+		current.LiftReason = account.LiftReasonSuperseded
+		current.LiftedAt = suspension.CreatedAt
+	}
+	m.suspensions[key] = append(m.suspensions[key], suspension.Clone())
+	return nil
+}
+
+func (m *memory) GetSuspension(_ context.Context, userID *commonpb.UserId) (*account.Suspension, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	current := m.inForce(string(userID.Value), time.Now())
+	if current == nil {
+		return nil, account.ErrNotSuspended
+	}
+	return current.Clone(), nil
+}
+
+func (m *memory) LiftSuspension(_ context.Context, userID, liftedBy *commonpb.UserId, reason string, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	current := m.inForce(string(userID.Value), at)
+	if current == nil {
+		return account.ErrNotSuspended
+	}
+	current.LiftedBy = proto.Clone(liftedBy).(*commonpb.UserId)
+	current.LiftReason = reason
+	current.LiftedAt = at
+	return nil
+}
+
+func (m *memory) GetSuspensions(_ context.Context, userID *commonpb.UserId) ([]*account.Suspension, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var res []*account.Suspension
+	for _, suspension := range slices.Backward(m.suspensions[string(userID.Value)]) {
+		res = append(res, suspension.Clone())
+	}
+	return res, nil
+}
+
+func (m *memory) DeleteUser(_ context.Context, userID *commonpb.UserId) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, key := range m.users[string(userID.Value)] {
+		delete(m.keys, key)
+		delete(m.keyInfo, key)
+	}
+	delete(m.users, string(userID.Value))
+	delete(m.registeredUsers, string(userID.Value))
+	return nil
+}
+
+// inForce returns the user's suspension in force at t, if any. Suspend keeps at
+// most one unlifted, so it is the latest.
+func (m *memory) inForce(userID string, t time.Time) *account.Suspension {
+	suspensions := m.suspensions[userID]
+	if len(suspensions) == 0 {
+		return nil
+	}
+	latest := suspensions[len(suspensions)-1]
+	if !latest.InForce(t) {
+		return nil
+	}
+	return latest
+}
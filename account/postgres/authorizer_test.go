@@ -28,3 +28,19 @@ func TestAccount_PostgresAuthorizer(t *testing.T) {
 	}
 	tests.RunAuthorizerTests(t, testStore, teardown)
 }
+
+func TestAccount_PostgresReplayProtection(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), testEnv.DatabaseUrl)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pg.SetupGlobalPgxPool(pool)
+
+	testStore := NewInPostgres(pool)
+	testNonces := NewInPostgresNonceStore(pool)
+	teardown := func() {
+		testStore.(*store).reset()
+		testNonces.(*nonceStore).reset()
+	}
+	tests.RunReplayTests(t, testStore, testNonces, teardown)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
@@ -21,9 +22,110 @@ const (
 	allUserFields  = `"id", "displayName", "profilePictureBlobId", "phoneNumber", "emailAddress", "isStaff", "isRegistered", "isPhoneNumberLinkedForPayment", "region", "locale", "createdAt", "updatedAt"`
 
 	publicKeysTableName = "flipcash_publickeys"
-	allPublicKeyFields  = `"key", "userId", "createdAt", "updatedAt"`
+	allPublicKeyFields  = `"key", "userId", "label", "isPrimary", "lastUsedAt", "createdAt", "updatedAt"`
+
+	noncesTableName = "flipcash_request_nonces"
+	allNonceFields  = `"nonce", "expiresAt"`
+
+	suspensionsTableName = "flipcash_account_suspensions"
+	allSuspensionFields  = `"id", "userId", "state", "reason", "suspendedBy", "createdAt", "expiresAt", "liftedBy", "liftReason", "liftedAt"`
+
+	// suspensionInForceCondition selects a user's suspension in force at a time,
+	// given as $1 and $2.
+	suspensionInForceCondition = `"userId" = $1 AND "liftedAt" IS NULL AND ("expiresAt" IS NULL OR "expiresAt" > $2)`
 )
 
+type keyModel struct {
+	Key        string     `db:"key"`
+	UserID     string     `db:"userId"`
+	Label      string     `db:"label"`
+	IsPrimary  bool       `db:"isPrimary"`
+	LastUsedAt *time.Time `db:"lastUsedAt"`
+	CreatedAt  time.Time  `db:"createdAt"`
+	UpdatedAt  time.Time  `db:"updatedAt"`
+}
+
+func fromKeyModel(m *keyModel) (*account.Key, error) {
+	pubKey, err := pg.Decode(m.Key)
+	if err != nil {
+		return nil, err
+	}
+	k := &account.Key{
+		PubKey:    &commonpb.PublicKey{Value: pubKey},
+		Label:     m.Label,
+		IsPrimary: m.IsPrimary,
+		CreatedAt: m.CreatedAt,
+	}
+	if m.LastUsedAt != nil {
+		k.LastUsedAt = *m.LastUsedAt
+	}
+	return k, nil
+}
+
+type suspensionModel struct {
+	ID          string     `db:"id"`
+	UserID      string     `db:"userId"`
+	State       string     `db:"state"`
+	Reason      string     `db:"reason"`
+	SuspendedBy string     `db:"suspendedBy"`
+	CreatedAt   time.Time  `db:"createdAt"`
+	ExpiresAt   *time.Time `db:"expiresAt"`
+	LiftedBy    *string    `db:"liftedBy"`
+	LiftReason  string     `db:"liftReason"`
+	LiftedAt    *time.Time `db:"liftedAt"`
+}
+
+func toSuspensionModel(s *account.Suspension) *suspensionModel {
+	m := &suspensionModel{
+		ID:          s.ID,
+		UserID:      pg.Encode(s.UserID.Value),
+		State:       string(s.State),
+		Reason:      s.Reason,
+		SuspendedBy: pg.Encode(s.SuspendedBy.Value),
+		CreatedAt:   s.CreatedAt.UTC(),
+		LiftReason:  s.LiftReason,
+	}
+	if !s.ExpiresAt.IsZero() {
+		expiresAt := s.ExpiresAt.UTC()
+		m.ExpiresAt = &expiresAt
+	}
+	return m
+}
+
+func fromSuspensionModel(m *suspensionModel) (*account.Suspension, error) {
+	userID, err := pg.Decode(m.UserID)
+	if err != nil {
+		return nil, err
+	}
+	suspendedBy, err := pg.Decode(m.SuspendedBy)
+	if err != nil {
+		return nil, err
+	}
+	s := &account.Suspension{
+		ID:          m.ID,
+		UserID:      &commonpb.UserId{Value: userID},
+		State:       account.SuspensionState(m.State),
+		Reason:      m.Reason,
+		SuspendedBy: &commonpb.UserId{Value: suspendedBy},
+		CreatedAt:   m.CreatedAt,
+		LiftReason:  m.LiftReason,
+	}
+	if m.ExpiresAt != nil {
+		s.ExpiresAt = *m.ExpiresAt
+	}
+	if m.LiftedBy != nil {
+		liftedBy, err := pg.Decode(*m.LiftedBy)
+		if err != nil {
+			return nil, err
+		}
+		s.LiftedBy = &commonpb.UserId{Value: liftedBy}
+	}
+	if m.LiftedAt != nil {
+		s.LiftedAt = *m.LiftedAt
+	}
+	return s, nil
+}
+
 func dbBind(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, pubKey *commonpb.PublicKey) error {
 	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
 		upsertUserQuery := `INSERT INTO ` + usersTableName + ` (` + allUserFields + `) VALUES ($1, NULL, NULL, NULL, NULL, FALSE, FALSE, FALSE, 'usd', 'en', NOW(), NOW()) ON CONFLICT ("id") DO NOTHING`
@@ -32,7 +134,16 @@ func dbBind(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, pu
 			return err
 		}
 
-		putPubkeyQuery := `INSERT INTO ` + publicKeysTableName + ` (` + allPublicKeyFields + `) VALUES ($1, $2, NOW(), NOW())`
+		hasKeys, err := dbHasPubKeys(ctx, tx, userID)
+		if err != nil {
+			return err
+		} else if hasKeys {
+			return account.ErrManyPublicKeys
+		}
+
+		// A concurrent Bind of the same user passes the check above too, and is
+		// stopped by the unique index on a user's primary key.
+		putPubkeyQuery := `INSERT INTO ` + publicKeysTableName + ` (` + allPublicKeyFields + `) VALUES ($1, $2, '', TRUE, NULL, NOW(), NOW())`
 		_, err = tx.Exec(ctx, putPubkeyQuery, pg.Encode(pubKey.Value, pg.Base58), pg.Encode(userID.Value))
 		if err == nil {
 			return nil
@@ -43,6 +154,89 @@ func dbBind(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, pu
 	})
 }
 
+func dbAddPubKey(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, key *account.Key) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		hasKeys, err := dbHasPubKeys(ctx, tx, userID)
+		if err != nil {
+			return err
+		} else if !hasKeys {
+			return account.ErrNotFound
+		}
+
+		putPubkeyQuery := `INSERT INTO ` + publicKeysTableName + ` (` + allPublicKeyFields + `) VALUES ($1, $2, $3, FALSE, NULL, $4, NOW())`
+		_, err = tx.Exec(ctx, putPubkeyQuery, pg.Encode(key.PubKey.Value, pg.Base58), pg.Encode(userID.Value), key.Label, key.CreatedAt.UTC())
+		if err == nil {
+			return nil
+		} else if strings.Contains(err.Error(), "23505") { // todo: better utility for detecting unique violations with pgx.Tx
+			return account.ErrKeyBound
+		}
+		return err
+	})
+}
+
+func dbHasPubKeys(ctx context.Context, tx pgx.Tx, userID *commonpb.UserId) (bool, error) {
+	var res bool
+	query := `SELECT EXISTS (SELECT 1 FROM ` + publicKeysTableName + ` WHERE "userId" = $1)`
+	err := tx.QueryRow(ctx, query, pg.Encode(userID.Value)).Scan(&res)
+	return res, err
+}
+
+func dbGetKeys(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) ([]*keyModel, error) {
+	var res []*keyModel
+	query := `SELECT ` + allPublicKeyFields + ` FROM ` + publicKeysTableName + `
+		WHERE "userId" = $1
+		ORDER BY "createdAt" ASC`
+	err := pgxscan.Select(ctx, pool, &res, query, pg.Encode(userID.Value))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetPrimaryPubKey(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) (*commonpb.PublicKey, error) {
+	var encoded string
+	query := `SELECT "key" FROM ` + publicKeysTableName + ` WHERE "userId" = $1 AND "isPrimary"`
+	err := pgxscan.Get(ctx, pool, &encoded, query, pg.Encode(userID.Value))
+	if pgxscan.NotFound(err) {
+		return nil, account.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	decoded, err := pg.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return &commonpb.PublicKey{Value: decoded}, nil
+}
+
+func dbRevokePubKey(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, pubKey *commonpb.PublicKey) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		var isPrimary bool
+		selectQuery := `SELECT "isPrimary" FROM ` + publicKeysTableName + ` WHERE "key" = $1 AND "userId" = $2 FOR UPDATE`
+		err := pgxscan.Get(ctx, tx, &isPrimary, selectQuery, pg.Encode(pubKey.Value, pg.Base58), pg.Encode(userID.Value))
+		if pgxscan.NotFound(err) {
+			return account.ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		if isPrimary {
+			return account.ErrPrimaryKey
+		}
+
+		deleteQuery := `DELETE FROM ` + publicKeysTableName + ` WHERE "key" = $1`
+		_, err = tx.Exec(ctx, deleteQuery, pg.Encode(pubKey.Value, pg.Base58))
+		return err
+	})
+}
+
+func dbMarkPubKeyUsed(ctx context.Context, pool *pgxpool.Pool, pubKey *commonpb.PublicKey, at time.Time) error {
+	query := `UPDATE ` + publicKeysTableName + `
+		SET "lastUsedAt" = $2
+		WHERE "key" = $1 AND ("lastUsedAt" IS NULL OR "lastUsedAt" < $2)`
+	_, err := pool.Exec(ctx, query, pg.Encode(pubKey.Value, pg.Base58), at.UTC())
+	return err
+}
+
 func dbGetUserId(ctx context.Context, pool *pgxpool.Pool, pubKey *commonpb.PublicKey) (*commonpb.UserId, error) {
 	var encoded string
 	query := `SELECT "userId" FROM ` + publicKeysTableName + ` WHERE "key" = $1`
@@ -179,3 +373,105 @@ func dbSetRegistrationFlag(ctx context.Context, pool *pgxpool.Pool, userID *comm
 		return nil
 	})
 }
+
+func dbDeleteUser(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		deletePubKeysQuery := `DELETE FROM ` + publicKeysTableName + ` WHERE "userId" = $1`
+		if _, err := tx.Exec(ctx, deletePubKeysQuery, pg.Encode(userID.Value)); err != nil {
+			return err
+		}
+
+		deleteUserQuery := `DELETE FROM ` + usersTableName + ` WHERE "id" = $1`
+		_, err := tx.Exec(ctx, deleteUserQuery, pg.Encode(userID.Value))
+		return err
+	})
+}
+
+func (m *suspensionModel) dbSuspend(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		supersedeQuery := `UPDATE ` + suspensionsTableName + `
+			SET "liftedBy" = $3, "liftReason" = $4, "liftedAt" = $2
+			WHERE ` + suspensionInForceCondition
+		_, err := tx.Exec(ctx, supersedeQuery, m.UserID, m.CreatedAt, m.SuspendedBy, account.LiftReasonSuperseded)
+		if err != nil {
+			return err
+		}
+
+		insertQuery := `INSERT INTO ` + suspensionsTableName + ` (` + allSuspensionFields + `)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULL, '', NULL)`
+		_, err = tx.Exec(
+			ctx,
+			insertQuery,
+			m.ID,
+			m.UserID,
+			m.State,
+			m.Reason,
+			m.SuspendedBy,
+			m.CreatedAt,
+			m.ExpiresAt,
+		)
+		return err
+	})
+}
+
+func dbGetSuspension(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, at time.Time) (*suspensionModel, error) {
+	res := &suspensionModel{}
+	query := `SELECT ` + allSuspensionFields + ` FROM ` + suspensionsTableName + `
+		WHERE ` + suspensionInForceCondition + `
+		ORDER BY "createdAt" DESC
+		LIMIT 1`
+	err := pgxscan.Get(ctx, pool, res, query, pg.Encode(userID.Value), at.UTC())
+	if pgxscan.NotFound(err) {
+		return nil, account.ErrNotSuspended
+	} else if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbLiftSuspension(ctx context.Context, pool *pgxpool.Pool, userID, liftedBy *commonpb.UserId, reason string, at time.Time) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `UPDATE ` + suspensionsTableName + `
+			SET "liftedBy" = $3, "liftReason" = $4, "liftedAt" = $2
+			WHERE ` + suspensionInForceCondition
+		res, err := tx.Exec(ctx, query, pg.Encode(userID.Value), at.UTC(), pg.Encode(liftedBy.Value), reason)
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return account.ErrNotSuspended
+		}
+		return nil
+	})
+}
+
+func dbGetSuspensions(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) ([]*suspensionModel, error) {
+	var res []*suspensionModel
+	query := `SELECT ` + allSuspensionFields + ` FROM ` + suspensionsTableName + `
+		WHERE "userId" = $1
+		ORDER BY "createdAt" DESC`
+	err := pgxscan.Select(ctx, pool, &res, query, pg.Encode(userID.Value))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbClaimNonce(ctx context.Context, pool *pgxpool.Pool, nonce []byte, expiresAt, now time.Time) (bool, error) {
+	// An expired nonce is claimed afresh, rather than waiting for it to be
+	// deleted.
+	query := `INSERT INTO ` + noncesTableName + ` (` + allNonceFields + `) VALUES ($1, $2)
+		ON CONFLICT ("nonce") DO UPDATE SET "expiresAt" = EXCLUDED."expiresAt"
+		WHERE ` + noncesTableName + `."expiresAt" <= $3`
+	res, err := pool.Exec(ctx, query, pg.Encode(nonce), expiresAt.UTC(), now.UTC())
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+func dbDeleteExpiredNonces(ctx context.Context, pool *pgxpool.Pool, before time.Time) error {
+	query := `DELETE FROM ` + noncesTableName + ` WHERE "expiresAt" <= $1`
+	_, err := pool.Exec(ctx, query, before.UTC())
+	return err
+}
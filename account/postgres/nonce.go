@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/code-payments/flipcash2-server/account"
+)
+
+type nonceStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewInPostgresNonceStore(pool *pgxpool.Pool) account.NonceStore {
+	return &nonceStore{
+		pool: pool,
+	}
+}
+
+func (s *nonceStore) Claim(ctx context.Context, nonce []byte, expiresAt time.Time) (bool, error) {
+	return dbClaimNonce(ctx, s.pool, nonce, expiresAt, time.Now())
+}
+
+func (s *nonceStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	return dbDeleteExpiredNonces(ctx, s.pool, before)
+}
+
+func (s *nonceStore) reset() {
+	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+noncesTableName)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/account/tests"
+	pg "github.com/code-payments/flipcash2-server/database/postgres"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestAccount_PostgresNonceStore(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), testEnv.DatabaseUrl)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	pg.SetupGlobalPgxPool(pool)
+
+	testStore := NewInPostgresNonceStore(pool)
+	teardown := func() {
+		testStore.(*nonceStore).reset()
+	}
+	tests.RunNonceStoreTests(t, testStore, teardown)
+}
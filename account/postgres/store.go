@@ -3,6 +3,7 @@ package postgres
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -48,6 +49,38 @@ func (s *store) GetPubKeys(ctx context.Context, userID *commonpb.UserId) ([]*com
 	return dbGetPubKeys(ctx, s.pool, userID)
 }
 
+func (s *store) AddPubKey(ctx context.Context, userID *commonpb.UserId, key *account.Key) error {
+	return dbAddPubKey(ctx, s.pool, userID, key)
+}
+
+func (s *store) GetKeys(ctx context.Context, userID *commonpb.UserId) ([]*account.Key, error) {
+	models, err := dbGetKeys(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*account.Key, len(models))
+	for i, model := range models {
+		res[i], err = fromKeyModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) GetPrimaryPubKey(ctx context.Context, userID *commonpb.UserId) (*commonpb.PublicKey, error) {
+	return dbGetPrimaryPubKey(ctx, s.pool, userID)
+}
+
+func (s *store) RevokePubKey(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) error {
+	return dbRevokePubKey(ctx, s.pool, userID, pubKey)
+}
+
+func (s *store) MarkPubKeyUsed(ctx context.Context, pubKey *commonpb.PublicKey, at time.Time) error {
+	return dbMarkPubKeyUsed(ctx, s.pool, pubKey, at)
+}
+
 func (s *store) IsAuthorized(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) (bool, error) {
 	linkedUserID, err := dbGetUserId(ctx, s.pool, pubKey)
 	if err == account.ErrNotFound {
@@ -70,8 +103,49 @@ func (s *store) SetRegistrationFlag(ctx context.Context, userID *commonpb.UserId
 	return dbSetRegistrationFlag(ctx, s.pool, userID, isRegistered)
 }
 
+func (s *store) Suspend(ctx context.Context, suspension *account.Suspension) error {
+	return toSuspensionModel(suspension).dbSuspend(ctx, s.pool)
+}
+
+func (s *store) GetSuspension(ctx context.Context, userID *commonpb.UserId) (*account.Suspension, error) {
+	model, err := dbGetSuspension(ctx, s.pool, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return fromSuspensionModel(model)
+}
+
+func (s *store) LiftSuspension(ctx context.Context, userID, liftedBy *commonpb.UserId, reason string, at time.Time) error {
+	return dbLiftSuspension(ctx, s.pool, userID, liftedBy, reason, at)
+}
+
+func (s *store) GetSuspensions(ctx context.Context, userID *commonpb.UserId) ([]*account.Suspension, error) {
+	models, err := dbGetSuspensions(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*account.Suspension, len(models))
+	for i, model := range models {
+		res[i], err = fromSuspensionModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) DeleteUser(ctx context.Context, userID *commonpb.UserId) error {
+	return dbDeleteUser(ctx, s.pool, userID)
+}
+
 func (s *store) reset() {
-	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+publicKeysTableName)
+	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+suspensionsTableName)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = s.pool.Exec(context.Background(), "DELETE FROM "+publicKeysTableName)
 	if err != nil {
 		panic(err)
 	}
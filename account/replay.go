@@ -0,0 +1,183 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/poll"
+)
+
+const (
+	// defaultReplayWindow is how far a request's signed timestamp may be from
+	// the server's clock, matching the window StreamEvents and Login apply.
+	defaultReplayWindow = 2 * time.Minute
+
+	// defaultNonceTTL is how long the signature of a write without a signed
+	// timestamp is remembered.
+	defaultNonceTTL = 5 * time.Minute
+)
+
+// NonceStore remembers the nonces of authorized requests for as long as a
+// replay of them must be rejected. It is shared by every server instance, so a
+// request captured from one cannot be replayed against another.
+type NonceStore interface {
+	// Claim records a nonce until expiresAt, and reports whether it was free:
+	// false means the nonce is already recorded and has not expired.
+	Claim(ctx context.Context, nonce []byte, expiresAt time.Time) (bool, error)
+
+	// DeleteExpired forgets the nonces that expired before a time.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// AuthorizerOption overrides one of the authorizer's knobs.
+type AuthorizerOption func(*Authorizer)
+
+// WithReplayProtection rejects replayed requests, remembering their signatures
+// in nonces. A request with a signed timestamp, which every unpublished request
+// carries (see auth.NewPayload), must be within the replay window, and its
+// signature is remembered until the window passes. Any other write has its
+// signature remembered for the nonce TTL.
+//
+// Signatures are deterministic, so a write sent twice unchanged within the
+// nonce TTL is rejected even when the user meant to repeat it, such as blocking
+// a user again after unblocking them. Reads are let through, and so are
+// requests carrying a client idempotency id, such as SendMessage's client
+// message id: their handlers answer a retry with the original result.
+func WithReplayProtection(nonces NonceStore) AuthorizerOption {
+	return func(a *Authorizer) {
+		a.nonces = nonces
+	}
+}
+
+// WithReplayWindow overrides how far a request's signed timestamp may be from
+// the server's clock.
+func WithReplayWindow(window time.Duration) AuthorizerOption {
+	return func(a *Authorizer) {
+		a.replayWindow = window
+	}
+}
+
+// WithNonceTTL overrides how long the signature of a write without a signed
+// timestamp is remembered.
+func WithNonceTTL(ttl time.Duration) AuthorizerOption {
+	return func(a *Authorizer) {
+		a.nonceTTL = ttl
+	}
+}
+
+// checkReplay rejects a replayed request, when replay protection is enabled.
+// It runs once every other check has passed, so only an authorized request
+// claims its nonce.
+func (a *Authorizer) checkReplay(ctx context.Context, m proto.Message, signature *commonpb.Signature) error {
+	if a.nonces == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var expiresAt time.Time
+	if ts, ok := signedTimestamp(m); ok {
+		if ts.After(now.Add(a.replayWindow)) || ts.Before(now.Add(-a.replayWindow)) {
+			return status.Error(codes.InvalidArgument, "request timestamp outside window")
+		}
+		// Past the window, the timestamp alone rejects a replay.
+		expiresAt = ts.Add(a.replayWindow)
+	} else if hasIdempotencyID(m) || isReadRequest(m) {
+		return nil
+	} else {
+		expiresAt = now.Add(a.nonceTTL)
+	}
+
+	// Requests of different types can serialize identically, as BlockUser and
+	// UnblockUser do, and so share a signature.
+	nonce := append([]byte(m.ProtoReflect().Descriptor().FullName()), signature.GetValue()...)
+	claimed, err := a.nonces.Claim(ctx, nonce, expiresAt)
+	if err != nil {
+		a.log.Warn("Failed to claim request nonce", zap.Error(err))
+		return status.Error(codes.Internal, "failed to verify auth")
+	}
+	if !claimed {
+		return status.Error(codes.AlreadyExists, "duplicate request")
+	}
+	return nil
+}
+
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// signedTimestamp returns the time a request's signature covers: the signing
+// time of an unpublished request's payload, or the time a published request
+// carries in a top-level Timestamp field named ts or timestamp. A request with
+// such a field that leaves it unset carries the zero Unix time.
+func signedTimestamp(m proto.Message) (time.Time, bool) {
+	if ts, ok := auth.PayloadTimestamp(m); ok {
+		return ts, true
+	}
+
+	r := m.ProtoReflect()
+	for _, name := range []protoreflect.Name{"ts", "timestamp"} {
+		fd := r.Descriptor().Fields().ByName(name)
+		if fd == nil || fd.Message() == nil || fd.Message().FullName() != timestampName || fd.IsList() {
+			continue
+		}
+
+		fields := r.Get(fd).Message()
+		seconds := fields.Get(fields.Descriptor().Fields().ByName("seconds")).Int()
+		nanos := fields.Get(fields.Descriptor().Fields().ByName("nanos")).Int()
+		return time.Unix(seconds, nanos), true
+	}
+	return time.Time{}, false
+}
+
+// idempotencyFields are the top-level fields in which a client names a request
+// so that a retry of it is recognized as one.
+var idempotencyFields = []protoreflect.Name{"client_message_id"}
+
+// hasIdempotencyID reports whether a request carries a client idempotency id.
+func hasIdempotencyID(m proto.Message) bool {
+	r := m.ProtoReflect()
+	for _, name := range idempotencyFields {
+		fd := r.Descriptor().Fields().ByName(name)
+		if fd != nil && r.Has(fd) {
+			return true
+		}
+	}
+	return false
+}
+
+// NonceReaper periodically forgets expired request nonces, which a NonceStore
+// otherwise keeps. Forgetting one twice is harmless, so it is safe to run on
+// every server instance.
+type NonceReaper struct {
+	log    *zap.Logger
+	nonces NonceStore
+}
+
+func NewNonceReaper(log *zap.Logger, nonces NonceStore) *NonceReaper {
+	return &NonceReaper{
+		log:    log,
+		nonces: nonces,
+	}
+}
+
+// Start satisfies the OCP worker.Runtime interface: it forgets expired nonces
+// every interval until ctx is cancelled (see poll.Loop), whose error it
+// returns.
+func (r *NonceReaper) Start(ctx context.Context, interval time.Duration) error {
+	return poll.Loop(ctx, interval, func(ctx context.Context) bool {
+		err := r.nonces.DeleteExpired(ctx, time.Now())
+		if err != nil && !errors.Is(err, context.Canceled) {
+			r.log.Warn("Failed to delete expired request nonces", zap.Error(err))
+		}
+		return false
+	})
+}
@@ -0,0 +1,94 @@
+package account
+
+import (
+	"time"
+
+	"github.com/mr-tron/base58"
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// The requests below are for the Server methods backing unpublished Account
+// RPCs, each signed over its Payload (see auth.NewPayload).
+
+// ApplySuspensionRequest suspends an account in State for Duration, or until
+// lifted if Duration is zero.
+type ApplySuspensionRequest struct {
+	UserID   *commonpb.UserId
+	State    SuspensionState
+	Reason   string
+	Duration time.Duration
+	Ts       time.Time
+	Auth     *commonpb.Auth
+}
+
+func (r *ApplySuspensionRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ApplySuspension", model.UserIDString(r.UserID), string(r.State), r.Reason, r.Duration.String())
+}
+
+// LiftSuspensionRequest lifts the suspension in force for an account early.
+type LiftSuspensionRequest struct {
+	UserID *commonpb.UserId
+	Reason string
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *LiftSuspensionRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "LiftSuspension", model.UserIDString(r.UserID), r.Reason)
+}
+
+// GetSuspensionHistoryRequest asks for every suspension applied to an account.
+type GetSuspensionHistoryRequest struct {
+	UserID *commonpb.UserId
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *GetSuspensionHistoryRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "GetSuspensionHistory", model.UserIDString(r.UserID))
+}
+
+// AddKeyRequest binds a new device key to the caller. Signature is PubKey's
+// signature over the caller's UserId, proving the new device holds it.
+type AddKeyRequest struct {
+	PubKey    *commonpb.PublicKey
+	Signature *commonpb.Signature
+	Label     string
+	Ts        time.Time
+	Auth      *commonpb.Auth
+}
+
+func (r *AddKeyRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "AddKey", base58.Encode(r.PubKey.GetValue()), r.Label)
+}
+
+// ListKeysRequest asks for the caller's keys.
+type ListKeysRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *ListKeysRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ListKeys")
+}
+
+// RevokeKeyRequest unbinds one of the caller's keys.
+type RevokeKeyRequest struct {
+	PubKey *commonpb.PublicKey
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *RevokeKeyRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "RevokeKey", base58.Encode(r.PubKey.GetValue()))
+}
+
+// requestPayload is the signed payload of an unpublished Account request.
+func requestPayload(ts time.Time, method string, args ...string) proto.Message {
+	return auth.NewPayload("flipcash.account.v1.Account/"+method, ts, args...)
+}
@@ -119,15 +119,17 @@ type Server struct {
 	log      *zap.Logger
 	store    Store
 	verifier auth.Authenticator
+	authz    auth.Authorizer
 
 	accountpb.UnimplementedAccountServer
 }
 
-func NewServer(log *zap.Logger, store Store, verifier auth.Authenticator) *Server {
+func NewServer(log *zap.Logger, store Store, verifier auth.Authenticator, authz auth.Authorizer) *Server {
 	return &Server{
 		log:      log,
 		store:    store,
 		verifier: verifier,
+		authz:    authz,
 	}
 }
 
@@ -242,6 +244,11 @@ func (s *Server) GetUserFlags(ctx context.Context, req *accountpb.GetUserFlagsRe
 		return nil, status.Errorf(codes.Internal, "failed to get registration flag")
 	}
 
+	if err := s.setSuspensionHeaders(ctx, req.UserId); err != nil {
+		s.log.Warn("Failed to surface suspension", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, "failed to get suspension")
+	}
+
 	var preferredOnRampProviderForUser accountpb.UserFlags_OnRampProvider
 	var supportedOnRampProvidersForUser []accountpb.UserFlags_OnRampProvider
 	if isStaff {
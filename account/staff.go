@@ -0,0 +1,180 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+const maxSuspensionReasonLength = 1000
+
+// Response headers GetUserFlags surfaces a suspension in force with, since the
+// published UserFlags have no field for it yet. They are absent when the account
+// is not suspended.
+const (
+	// SuspensionStateHeader is the SuspensionState.
+	SuspensionStateHeader = "flipcash-suspension-state"
+
+	// SuspensionReasonHeader is the reason staff gave.
+	SuspensionReasonHeader = "flipcash-suspension-reason"
+
+	// SuspensionExpiresAtHeader is when the suspension ends, in RFC 3339. It is
+	// absent when the suspension lasts until lifted.
+	SuspensionExpiresAtHeader = "flipcash-suspension-expires-at"
+)
+
+// ApplySuspension suspends an account, replacing any suspension in force. Only
+// staff may call it.
+func (s *Server) ApplySuspension(ctx context.Context, req *ApplySuspensionRequest) (*Suspension, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("user_id", model.UserIDString(req.UserID)),
+		zap.String("state", string(req.State)),
+	)
+
+	if err := s.requireStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	if !req.State.Valid() {
+		return nil, status.Error(codes.InvalidArgument, "invalid suspension state")
+	}
+	if req.Duration < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid suspension duration")
+	}
+	if err := validateSuspensionReason(req.Reason); err != nil {
+		return nil, err
+	}
+
+	pubKeys, err := s.store.GetPubKeys(ctx, req.UserID)
+	if err != nil {
+		log.Warn("Failed to get keys", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to suspend account")
+	}
+	if len(pubKeys) == 0 {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	suspension := NewSuspension(req.UserID, req.State, req.Reason, caller, req.Duration)
+	if err := s.store.Suspend(ctx, suspension); err != nil {
+		log.Warn("Failed to suspend account", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to suspend account")
+	}
+	log.Info("Account suspended", zap.Duration("duration", req.Duration))
+	return suspension, nil
+}
+
+// LiftSuspension lifts the suspension in force for an account early. Only staff
+// may call it.
+func (s *Server) LiftSuspension(ctx context.Context, req *LiftSuspensionRequest) error {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("user_id", model.UserIDString(req.UserID)),
+	)
+
+	if err := s.requireStaff(ctx, caller, log); err != nil {
+		return err
+	}
+	if err := validateSuspensionReason(req.Reason); err != nil {
+		return err
+	}
+
+	err = s.store.LiftSuspension(ctx, req.UserID, caller, req.Reason, time.Now())
+	if errors.Is(err, ErrNotSuspended) {
+		return status.Error(codes.FailedPrecondition, "account not suspended")
+	} else if err != nil {
+		log.Warn("Failed to lift suspension", zap.Error(err))
+		return status.Error(codes.Internal, "failed to lift suspension")
+	}
+	log.Info("Suspension lifted")
+	return nil
+}
+
+// GetSuspensionHistory returns every suspension applied to an account, most
+// recent first: who applied each, why, and who lifted it. Only staff may call
+// it.
+func (s *Server) GetSuspensionHistory(ctx context.Context, req *GetSuspensionHistoryRequest) ([]*Suspension, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("user_id", model.UserIDString(req.UserID)),
+	)
+
+	if err := s.requireStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+
+	suspensions, err := s.store.GetSuspensions(ctx, req.UserID)
+	if err != nil {
+		log.Warn("Failed to get suspensions", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get suspensions")
+	}
+	return suspensions, nil
+}
+
+// requireStaff gates the staff RPCs on the caller being staff.
+func (s *Server) requireStaff(ctx context.Context, caller *commonpb.UserId, log *zap.Logger) error {
+	isStaff, err := s.store.IsStaff(ctx, caller)
+	if err != nil {
+		log.Warn("Failed to check staff status", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check staff status")
+	}
+	if !isStaff {
+		return status.Error(codes.PermissionDenied, "staff only")
+	}
+	return nil
+}
+
+func validateSuspensionReason(reason string) error {
+	if reason == "" {
+		return status.Error(codes.InvalidArgument, "missing reason")
+	}
+	if utf8.RuneCountInString(reason) > maxSuspensionReasonLength {
+		return status.Error(codes.InvalidArgument, "reason too long")
+	}
+	return nil
+}
+
+// setSuspensionHeaders surfaces the suspension in force for userID, if any, in
+// the response headers.
+func (s *Server) setSuspensionHeaders(ctx context.Context, userID *commonpb.UserId) error {
+	suspension, err := s.store.GetSuspension(ctx, userID)
+	if errors.Is(err, ErrNotSuspended) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	md := metadata.Pairs(
+		SuspensionStateHeader, string(suspension.State),
+		SuspensionReasonHeader, suspension.Reason,
+	)
+	if !suspension.ExpiresAt.IsZero() {
+		md.Set(SuspensionExpiresAtHeader, suspension.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return grpc.SetHeader(ctx, md)
+}
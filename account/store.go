@@ -3,6 +3,7 @@ package account
 import (
 	"context"
 	"errors"
+	"time"
 
 	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 )
@@ -10,10 +11,16 @@ import (
 var (
 	ErrNotFound       = errors.New("not found")
 	ErrManyPublicKeys = errors.New("detected multiple keys for user")
+	ErrNotSuspended   = errors.New("account not suspended")
+	ErrKeyBound       = errors.New("public key already bound")
+	ErrPrimaryKey     = errors.New("public key is the primary key")
 )
 
 type Store interface {
-	// Bind binds a public key to a UserId, or returns the previously bound UserId.
+	// Bind binds a public key to a UserId as its primary key, or returns the
+	// previously bound UserId.
+	//
+	// ErrManyPublicKeys is returned if the user already has a key bound.
 	Bind(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) (*commonpb.UserId, error)
 
 	// GetUserId returns the UserId associated with a public key.
@@ -28,6 +35,31 @@ type Store interface {
 	// GetPubKeys returns the set of public keys associated with an account.
 	GetPubKeys(ctx context.Context, userID *commonpb.UserId) ([]*commonpb.PublicKey, error)
 
+	// AddPubKey binds an additional, non-primary key to an existing account.
+	//
+	// ErrNotFound is returned if the user has no keys bound, and ErrKeyBound if
+	// the key is already bound to any account.
+	AddPubKey(ctx context.Context, userID *commonpb.UserId, key *Key) error
+
+	// GetKeys returns the keys bound to an account along with their metadata,
+	// oldest first.
+	GetKeys(ctx context.Context, userID *commonpb.UserId) ([]*Key, error)
+
+	// GetPrimaryPubKey returns the key bound to an account at registration.
+	//
+	// ErrNotFound is returned if the user has no keys bound.
+	GetPrimaryPubKey(ctx context.Context, userID *commonpb.UserId) (*commonpb.PublicKey, error)
+
+	// RevokePubKey unbinds a key from an account.
+	//
+	// ErrNotFound is returned if the key is not bound to the user, and
+	// ErrPrimaryKey if it is the user's primary key.
+	RevokePubKey(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) error
+
+	// MarkPubKeyUsed records that a key authorized a request at a time. It is a
+	// no-op for an unbound key.
+	MarkPubKeyUsed(ctx context.Context, pubKey *commonpb.PublicKey, at time.Time) error
+
 	// IsAuthorized returns whether or not a pubKey is authorized to perform actions on behalf of the user.
 	IsAuthorized(ctx context.Context, userID *commonpb.UserId, pubKey *commonpb.PublicKey) (bool, error)
 
@@ -39,4 +71,27 @@ type Store interface {
 
 	// SetRegistrationFlag sets wether a userID is a registered account
 	SetRegistrationFlag(ctx context.Context, userID *commonpb.UserId, isRegistered bool) error
+
+	// Suspend puts the suspension into force for its user, lifting any in force
+	// with LiftReasonSuperseded, on behalf of its SuspendedBy.
+	Suspend(ctx context.Context, suspension *Suspension) error
+
+	// GetSuspension returns the suspension in force for a userID.
+	//
+	// ErrNotSuspended is returned if there is none.
+	GetSuspension(ctx context.Context, userID *commonpb.UserId) (*Suspension, error)
+
+	// LiftSuspension lifts the suspension in force for a userID.
+	//
+	// ErrNotSuspended is returned if there is none.
+	LiftSuspension(ctx context.Context, userID, liftedBy *commonpb.UserId, reason string, at time.Time) error
+
+	// GetSuspensions returns every suspension ever applied to a userID, most
+	// recent first.
+	GetSuspensions(ctx context.Context, userID *commonpb.UserId) ([]*Suspension, error)
+
+	// DeleteUser removes a userID's public key bindings and its user record,
+	// along with its staff and registration flags. Suspensions are kept as the
+	// account's audit trail. It is a no-op for an unknown userID.
+	DeleteUser(ctx context.Context, userID *commonpb.UserId) error
 }
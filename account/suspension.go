@@ -0,0 +1,223 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	accountpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/account/v1"
+	activitypb "github.com/code-payments/flipcash2-protobuf-api/generated/go/activity/v1"
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	blocklistpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blocklist/v1"
+	chatpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/chat/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	contactpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/contact/v1"
+	eventpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/event/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+	profilepb "github.com/code-payments/flipcash2-protobuf-api/generated/go/profile/v1"
+	resolverpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/resolver/v1"
+	thirdpartypb "github.com/code-payments/flipcash2-protobuf-api/generated/go/thirdparty/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+)
+
+// LiftReasonSuperseded is the lift reason a store records on a suspension in
+// force when a new one replaces it.
+const LiftReasonSuperseded = "superseded"
+
+// SuspensionState is how far a suspended account is restricted. Each state
+// restricts everything the one before it does.
+type SuspensionState string
+
+const (
+	// SuspensionRestricted stops the account moving funds: it cannot send
+	// payments, open accounts, or launch currencies. It is otherwise unaffected.
+	SuspensionRestricted SuspensionState = "restricted"
+
+	// SuspensionReadOnly also denies the account's write RPCs. It can still
+	// sign in and read.
+	SuspensionReadOnly SuspensionState = "read_only"
+
+	// SuspensionBanned denies the account every authorized RPC.
+	SuspensionBanned SuspensionState = "banned"
+)
+
+// Valid reports whether s is a known state.
+func (s SuspensionState) Valid() bool {
+	return s.severity() > 0
+}
+
+// AllowsWrites reports whether an account in the state may make write RPCs.
+func (s SuspensionState) AllowsWrites() bool {
+	return s.severity() < SuspensionReadOnly.severity()
+}
+
+// AllowsReads reports whether an account in the state may make read RPCs.
+func (s SuspensionState) AllowsReads() bool {
+	return s.severity() < SuspensionBanned.severity()
+}
+
+func (s SuspensionState) severity() int {
+	switch s {
+	case SuspensionRestricted:
+		return 1
+	case SuspensionReadOnly:
+		return 2
+	case SuspensionBanned:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Suspension is one suspension applied to an account by staff. Every suspension
+// is kept once lifted or expired, as the account's audit trail.
+type Suspension struct {
+	ID          string
+	UserID      *commonpb.UserId
+	State       SuspensionState
+	Reason      string
+	SuspendedBy *commonpb.UserId
+	CreatedAt   time.Time
+
+	// ExpiresAt is when the suspension ends on its own. Zero suspends the account
+	// until it is lifted.
+	ExpiresAt time.Time
+
+	// Set once the suspension is lifted by staff, or superseded by a later one.
+	LiftedBy   *commonpb.UserId
+	LiftReason string
+	LiftedAt   time.Time
+}
+
+// InForce reports whether the suspension applies at t: neither lifted nor
+// expired.
+func (s *Suspension) InForce(t time.Time) bool {
+	if !s.LiftedAt.IsZero() {
+		return false
+	}
+	return s.ExpiresAt.IsZero() || s.ExpiresAt.After(t)
+}
+
+func (s *Suspension) Clone() *Suspension {
+	cloned := *s
+	cloned.UserID = proto.Clone(s.UserID).(*commonpb.UserId)
+	cloned.SuspendedBy = proto.Clone(s.SuspendedBy).(*commonpb.UserId)
+	if s.LiftedBy != nil {
+		cloned.LiftedBy = proto.Clone(s.LiftedBy).(*commonpb.UserId)
+	}
+	return &cloned
+}
+
+// NewSuspension returns a suspension of userID in state, starting now and
+// lasting duration, or until lifted if duration is zero.
+func NewSuspension(userID *commonpb.UserId, state SuspensionState, reason string, suspendedBy *commonpb.UserId, duration time.Duration) *Suspension {
+	now := time.Now()
+	s := &Suspension{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		State:       state,
+		Reason:      reason,
+		SuspendedBy: suspendedBy,
+		CreatedAt:   now,
+	}
+	if duration > 0 {
+		s.ExpiresAt = now.Add(duration)
+	}
+	return s
+}
+
+// Suspender suspends accounts outside of the staff RPCs, on behalf of the staff
+// member who decided it, such as when acting on a report. It satisfies
+// report.AccountSuspender.
+type Suspender struct {
+	store    Store
+	state    SuspensionState
+	duration time.Duration
+}
+
+// NewSuspender returns a Suspender applying state for duration, or until lifted
+// if duration is zero.
+func NewSuspender(store Store, state SuspensionState, duration time.Duration) *Suspender {
+	return &Suspender{
+		store:    store,
+		state:    state,
+		duration: duration,
+	}
+}
+
+// SuspendAccount suspends userID. It is idempotent: an account already under a
+// suspension at least as severe is left as it is, so a retry does not extend it.
+func (s *Suspender) SuspendAccount(ctx context.Context, userID *commonpb.UserId, reason string, suspendedBy *commonpb.UserId) error {
+	current, err := s.store.GetSuspension(ctx, userID)
+	if err == nil && current.State.severity() >= s.state.severity() {
+		return nil
+	} else if err != nil && !errors.Is(err, ErrNotSuspended) {
+		return err
+	}
+	return s.store.Suspend(ctx, NewSuspension(userID, s.state, reason, suspendedBy, s.duration))
+}
+
+// readRequests are the authorized requests that only read. Every other
+// authorized request is a write.
+var readRequests = fullNames(
+	&accountpb.GetUserFlagsRequest{},
+	&activitypb.GetLatestNotificationsRequest{},
+	&activitypb.GetPagedNotificationsRequest{},
+	&activitypb.GetBatchNotificationsRequest{},
+	&blobpb.GetBlobsRequest{},
+	&blobpb.GetUploadPolicyRequest{},
+	&blocklistpb.IsBlockedRequest{},
+	&blocklistpb.GetBlocklistRequest{},
+	&chatpb.GetChatRequest{},
+	&chatpb.GetDmChatFeedRequest{},
+	&contactpb.CheckSyncRequest{},
+	&contactpb.GetFlipcashContactsRequest{},
+	&eventpb.StreamEventsRequest_Params{},
+	&messagingpb.GetMessageRequest{},
+	&messagingpb.GetMessagesRequest{},
+	&messagingpb.GetDeltaRequest{},
+	&messagingpb.AdvancePointerRequest{},
+	&messagingpb.GetReactionSummaryRequest{},
+	&messagingpb.GetReactionSummariesRequest{},
+	&messagingpb.GetReactorsRequest{},
+	&profilepb.GetProfileRequest{},
+	&resolverpb.ResolveRequest{},
+	&thirdpartypb.GetJwtRequest{},
+)
+
+// readMethods are the unpublished requests (see auth.NewPayload) that only
+// read, by the method their payload names.
+var readMethods = map[string]struct{}{
+	"flipcash.account.v1.Account/GetDeletion":              {},
+	"flipcash.account.v1.Account/GetExport":                {},
+	"flipcash.account.v1.Account/GetSuspensionHistory":     {},
+	"flipcash.account.v1.Account/ListKeys":                 {},
+	"flipcash.blob.v1.BlobStorage/GetStorageUsage":         {},
+	"flipcash.blob.v1.BlobStorage/ListDeadLetters":         {},
+	"flipcash.moderation.v1.Moderation/GetAuditRecord":     {},
+	"flipcash.moderation.v1.Moderation/ListAppeals":        {},
+	"flipcash.moderation.v1.Moderation/ListReports":        {},
+	"flipcash.moderation.v1.Moderation/ListReportsAgainst": {},
+}
+
+func isReadRequest(m proto.Message) bool {
+	if method, ok := auth.PayloadMethod(m); ok {
+		_, ok := readMethods[method]
+		return ok
+	}
+	_, ok := readRequests[m.ProtoReflect().Descriptor().FullName()]
+	return ok
+}
+
+func fullNames(msgs ...proto.Message) map[protoreflect.FullName]struct{} {
+	names := make(map[protoreflect.FullName]struct{}, len(msgs))
+	for _, m := range msgs {
+		names[m.ProtoReflect().Descriptor().FullName()] = struct{}{}
+	}
+	return names
+}
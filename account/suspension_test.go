@@ -0,0 +1,83 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+func TestReadRequestsAllowedWhenReadOnly(t *testing.T) {
+	ctx := context.Background()
+	userID := model.MustGenerateUserID()
+	store := &suspendedStore{
+		userID:     userID,
+		suspension: NewSuspension(userID, SuspensionReadOnly, "abuse", model.MustGenerateUserID(), time.Hour),
+	}
+	authz := NewAuthorizer(zaptest.NewLogger(t), store, acceptAll{})
+
+	require.NotEmpty(t, readRequests)
+	for name := range readRequests {
+		t.Run(string(name), func(t *testing.T) {
+			mt, err := protoregistry.GlobalTypes.FindMessageByName(name)
+			require.NoError(t, err)
+
+			var authMessage *commonpb.Auth
+			_, err = authz.Authorize(ctx, mt.New().Interface(), &authMessage)
+			require.NoError(t, err)
+		})
+	}
+
+	require.NotEmpty(t, readMethods)
+	for method := range readMethods {
+		t.Run(method, func(t *testing.T) {
+			var authMessage *commonpb.Auth
+			_, err := authz.Authorize(ctx, auth.NewPayload(method, time.Now()), &authMessage)
+			require.NoError(t, err)
+		})
+	}
+
+	var authMessage *commonpb.Auth
+	_, err := authz.Authorize(ctx, &messagingpb.SendMessageRequest{}, &authMessage)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = authz.Authorize(ctx, auth.NewPayload("flipcash.blob.v1.BlobStorage/BlockBlob", time.Now()), &authMessage)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+// suspendedStore resolves every key to one user, under one suspension.
+type suspendedStore struct {
+	Store
+
+	userID     *commonpb.UserId
+	suspension *Suspension
+}
+
+func (s *suspendedStore) GetUserId(_ context.Context, _ *commonpb.PublicKey) (*commonpb.UserId, error) {
+	return s.userID, nil
+}
+
+func (s *suspendedStore) GetSuspension(_ context.Context, _ *commonpb.UserId) (*Suspension, error) {
+	return s.suspension, nil
+}
+
+func (s *suspendedStore) MarkPubKeyUsed(_ context.Context, _ *commonpb.PublicKey, _ time.Time) error {
+	return nil
+}
+
+type acceptAll struct{}
+
+func (acceptAll) Verify(_ context.Context, _ proto.Message, _ *commonpb.Auth) error {
+	return nil
+}
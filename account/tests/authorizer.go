@@ -4,14 +4,22 @@ import (
 	"bytes"
 	"context"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	accountpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/account/v1"
+	blocklistpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blocklist/v1"
 	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	eventpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/event/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+	phonepb "github.com/code-payments/flipcash2-protobuf-api/generated/go/phone/v1"
 
 	"github.com/code-payments/flipcash2-server/account"
 	"github.com/code-payments/flipcash2-server/auth"
@@ -22,12 +30,22 @@ import (
 func RunAuthorizerTests(t *testing.T, s account.Store, teardown func()) {
 	for _, tf := range []func(t *testing.T, s account.Store){
 		testAuthorizer,
+		testAuthorizer_suspension,
 	} {
 		tf(t, s)
 		teardown()
 	}
 }
 
+func RunReplayTests(t *testing.T, s account.Store, nonces account.NonceStore, teardown func()) {
+	for _, tf := range []func(t *testing.T, s account.Store, nonces account.NonceStore){
+		testAuthorizer_replay,
+	} {
+		tf(t, s, nonces)
+		teardown()
+	}
+}
+
 func testAuthorizer(t *testing.T, store account.Store) {
 	log := zaptest.NewLogger(t)
 	authn := auth.NewKeyPairAuthenticator(log)
@@ -92,3 +110,219 @@ func testAuthorizer(t *testing.T, store account.Store) {
 		require.NotNil(t, req.Auth)
 	})
 }
+
+func testAuthorizer_suspension(t *testing.T, store account.Store) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+	authz := account.NewAuthorizer(log, store, auth.NewKeyPairAuthenticator(log))
+
+	userID := model.MustGenerateUserID()
+	signer := model.MustGenerateKeyPair()
+	staff := model.MustGenerateUserID()
+
+	_, err := store.Bind(ctx, userID, signer.Proto())
+	require.NoError(t, err)
+
+	authorize := func(t *testing.T, req proto.Message, authField **commonpb.Auth) error {
+		require.NoError(t, signer.Auth(req, authField))
+		_, err := authz.Authorize(ctx, req, authField)
+		require.NotNil(t, *authField)
+		return err
+	}
+	read := func(t *testing.T) error {
+		req := &messagingpb.GetMessagesRequest{}
+		return authorize(t, req, &req.Auth)
+	}
+	write := func(t *testing.T) error {
+		req := &messagingpb.SendMessageRequest{}
+		return authorize(t, req, &req.Auth)
+	}
+
+	for _, tc := range []struct {
+		state      account.SuspensionState
+		readAllow  bool
+		writeAllow bool
+	}{
+		{account.SuspensionRestricted, true, true},
+		{account.SuspensionReadOnly, true, false},
+		{account.SuspensionBanned, false, false},
+	} {
+		t.Run(string(tc.state), func(t *testing.T) {
+			require.NoError(t, store.Suspend(ctx, account.NewSuspension(userID, tc.state, "abuse", staff, time.Hour)))
+
+			err := read(t)
+			if tc.readAllow {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, codes.PermissionDenied, status.Code(err))
+			}
+
+			err = write(t)
+			if tc.writeAllow {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, codes.PermissionDenied, status.Code(err))
+			}
+		})
+	}
+
+	t.Run("Lifted", func(t *testing.T) {
+		require.NoError(t, store.LiftSuspension(ctx, userID, staff, "appeal granted", time.Now()))
+		require.NoError(t, read(t))
+		require.NoError(t, write(t))
+	})
+}
+
+func testAuthorizer_replay(t *testing.T, store account.Store, nonces account.NonceStore) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+	authn := auth.NewKeyPairAuthenticator(log)
+
+	userID := model.MustGenerateUserID()
+	signer := model.MustGenerateKeyPair()
+	_, err := store.Bind(ctx, userID, signer.Proto())
+	require.NoError(t, err)
+
+	authorize := func(t *testing.T, authz *account.Authorizer, req proto.Message, authField **commonpb.Auth) error {
+		_, err := authz.Authorize(ctx, req, authField)
+		require.NotNil(t, *authField)
+		return err
+	}
+	signedBlock := func(t *testing.T) *blocklistpb.BlockUserRequest {
+		req := &blocklistpb.BlockUserRequest{UserId: model.MustGenerateUserID()}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		return req
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		authz := account.NewAuthorizer(log, store, authn)
+
+		req := signedBlock(t)
+		for range 2 {
+			require.NoError(t, authorize(t, authz, req, &req.Auth))
+		}
+	})
+
+	authz := account.NewAuthorizer(log, store, authn, account.WithReplayProtection(nonces))
+
+	t.Run("DuplicateRejected", func(t *testing.T) {
+		req := signedBlock(t)
+		require.NoError(t, authorize(t, authz, req, &req.Auth))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, authz, req, &req.Auth)))
+
+		// Replays are rejected by every instance sharing the nonce store
+		other := account.NewAuthorizer(log, store, authn, account.WithReplayProtection(nonces))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, other, req, &req.Auth)))
+
+		// A different request is unaffected
+		req = signedBlock(t)
+		require.NoError(t, authorize(t, authz, req, &req.Auth))
+	})
+
+	t.Run("Repeats", func(t *testing.T) {
+		// Signatures are deterministic, so a write repeated unchanged within the
+		// nonce TTL is indistinguishable from a replay
+		blocked := model.MustGenerateUserID()
+		block := &blocklistpb.BlockUserRequest{UserId: blocked}
+		require.NoError(t, signer.Auth(block, &block.Auth))
+		unblock := &blocklistpb.UnblockUserRequest{UserId: blocked}
+		require.NoError(t, signer.Auth(unblock, &unblock.Auth))
+
+		require.NoError(t, authorize(t, authz, block, &block.Auth))
+		require.NoError(t, authorize(t, authz, unblock, &unblock.Auth))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, authz, block, &block.Auth)))
+
+		emoji := &messagingpb.Emoji{Value: "👍"}
+		add := &messagingpb.AddReactionRequest{Emoji: emoji}
+		require.NoError(t, signer.Auth(add, &add.Auth))
+		require.NoError(t, authorize(t, authz, add, &add.Auth))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, authz, add, &add.Auth)))
+
+		code := &phonepb.SendVerificationCodeRequest{PhoneNumber: &phonepb.PhoneNumber{Value: "+12223334444"}}
+		require.NoError(t, signer.Auth(code, &code.Auth))
+		require.NoError(t, authorize(t, authz, code, &code.Auth))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, authz, code, &code.Auth)))
+
+		// Reads are let through
+		flags := &accountpb.GetUserFlagsRequest{UserId: userID}
+		require.NoError(t, signer.Auth(flags, &flags.Auth))
+		for range 2 {
+			require.NoError(t, authorize(t, authz, flags, &flags.Auth))
+		}
+	})
+
+	t.Run("IdempotentRetries", func(t *testing.T) {
+		// The handler answers a retry with the original result
+		clientID := uuid.New()
+		req := &messagingpb.SendMessageRequest{ClientMessageId: &messagingpb.ClientMessageId{Value: clientID[:]}}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		for range 2 {
+			require.NoError(t, authorize(t, authz, req, &req.Auth))
+		}
+	})
+
+	t.Run("DeniedNotClaimed", func(t *testing.T) {
+		staff := model.MustGenerateUserID()
+		require.NoError(t, store.Suspend(ctx, account.NewSuspension(userID, account.SuspensionReadOnly, "abuse", staff, time.Hour)))
+
+		req := signedBlock(t)
+		require.Equal(t, codes.PermissionDenied, status.Code(authorize(t, authz, req, &req.Auth)))
+
+		require.NoError(t, store.LiftSuspension(ctx, userID, staff, "appeal granted", time.Now()))
+		require.NoError(t, authorize(t, authz, req, &req.Auth))
+	})
+
+	t.Run("SignedTimestamp", func(t *testing.T) {
+		signedParams := func(t *testing.T, ts time.Time) *eventpb.StreamEventsRequest_Params {
+			req := &eventpb.StreamEventsRequest_Params{Ts: timestamppb.New(ts)}
+			require.NoError(t, signer.Auth(req, &req.Auth))
+			return req
+		}
+
+		req := signedParams(t, time.Now())
+		require.NoError(t, authorize(t, authz, req, &req.Auth))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, authz, req, &req.Auth)))
+
+		for _, ts := range []time.Time{
+			time.Now().Add(-5 * time.Minute),
+			time.Now().Add(5 * time.Minute),
+			{},
+		} {
+			req := signedParams(t, ts)
+			require.Equal(t, codes.InvalidArgument, status.Code(authorize(t, authz, req, &req.Auth)))
+		}
+
+		missing := &eventpb.StreamEventsRequest_Params{}
+		require.NoError(t, signer.Auth(missing, &missing.Auth))
+		require.Equal(t, codes.InvalidArgument, status.Code(authorize(t, authz, missing, &missing.Auth)))
+
+		wide := account.NewAuthorizer(log, store, authn, account.WithReplayProtection(nonces), account.WithReplayWindow(10*time.Minute))
+		req = signedParams(t, time.Now().Add(-5*time.Minute))
+		require.NoError(t, authorize(t, wide, req, &req.Auth))
+	})
+
+	t.Run("UnpublishedRequest", func(t *testing.T) {
+		signedPayload := func(t *testing.T, ts time.Time) (proto.Message, *commonpb.Auth) {
+			payload := auth.NewPayload("flipcash.blob.v1.BlobStorage/BlockBlob", ts, "00")
+			var authMessage *commonpb.Auth
+			require.NoError(t, signer.Auth(payload, &authMessage))
+			return payload, authMessage
+		}
+
+		payload, authMessage := signedPayload(t, time.Now())
+		require.NoError(t, authorize(t, authz, payload, &authMessage))
+		require.Equal(t, codes.AlreadyExists, status.Code(authorize(t, authz, payload, &authMessage)))
+
+		payload, authMessage = signedPayload(t, time.Now().Add(-5*time.Minute))
+		require.Equal(t, codes.InvalidArgument, status.Code(authorize(t, authz, payload, &authMessage)))
+	})
+
+	t.Run("NonceTTL", func(t *testing.T) {
+		shortLived := account.NewAuthorizer(log, store, authn, account.WithReplayProtection(nonces), account.WithNonceTTL(time.Millisecond))
+
+		req := signedBlock(t)
+		require.NoError(t, authorize(t, shortLived, req, &req.Auth))
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, authorize(t, shortLived, req, &req.Auth))
+	})
+}
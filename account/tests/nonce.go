@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/account"
+)
+
+func RunNonceStoreTests(t *testing.T, s account.NonceStore, teardown func()) {
+	for _, tf := range []func(t *testing.T, s account.NonceStore){
+		testNonceStore_claim,
+		testNonceStore_deleteExpired,
+	} {
+		tf(t, s)
+		teardown()
+	}
+}
+
+func testNonceStore_claim(t *testing.T, s account.NonceStore) {
+	ctx := context.Background()
+
+	nonce := newNonce(t)
+
+	claimed, err := s.Claim(ctx, nonce, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	for range 2 {
+		claimed, err = s.Claim(ctx, nonce, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.False(t, claimed)
+	}
+
+	claimed, err = s.Claim(ctx, newNonce(t), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	// An expired nonce can be claimed again
+	expired := newNonce(t)
+	claimed, err = s.Claim(ctx, expired, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	claimed, err = s.Claim(ctx, expired, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	claimed, err = s.Claim(ctx, expired, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, claimed)
+}
+
+func testNonceStore_deleteExpired(t *testing.T, s account.NonceStore) {
+	ctx := context.Background()
+
+	now := time.Now()
+	live := newNonce(t)
+	claimed, err := s.Claim(ctx, live, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	expired := newNonce(t)
+	claimed, err = s.Claim(ctx, expired, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	require.NoError(t, s.DeleteExpired(ctx, now.Add(2*time.Minute)))
+
+	// The live nonce is still remembered
+	claimed, err = s.Claim(ctx, live, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	// The deleted one is forgotten, even though it had not expired yet
+	claimed, err = s.Claim(ctx, expired, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, claimed)
+}
+
+func newNonce(t *testing.T) []byte {
+	nonce := make([]byte, 64)
+	_, err := rand.Read(nonce)
+	require.NoError(t, err)
+	return nonce
+}
@@ -2,11 +2,17 @@ package tests
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	accountpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/account/v1"
@@ -16,6 +22,7 @@ import (
 	ocp_testutil "github.com/code-payments/ocp-server/testutil"
 
 	"github.com/code-payments/flipcash2-server/account"
+	"github.com/code-payments/flipcash2-server/account/cache"
 	"github.com/code-payments/flipcash2-server/auth"
 	"github.com/code-payments/flipcash2-server/model"
 	"github.com/code-payments/flipcash2-server/protoutil"
@@ -25,6 +32,8 @@ import (
 func RunServerTests(t *testing.T, s account.Store, teardown func()) {
 	for _, tf := range []func(t *testing.T, s account.Store){
 		testServer,
+		testServer_suspensions,
+		testServer_keys,
 	} {
 		tf(t, s)
 		teardown()
@@ -36,10 +45,12 @@ func testServer(t *testing.T, store account.Store) {
 
 	ocpData := ocp_data.NewTestDataProvider()
 
+	authn := auth.NewKeyPairAuthenticator(log)
 	server := account.NewServer(
 		log,
 		store,
-		auth.NewKeyPairAuthenticator(log),
+		authn,
+		account.NewAuthorizer(log, store, authn),
 	)
 
 	cc := testutil.RunGRPCServer(t, log, testutil.WithService(func(s *grpc.Server) {
@@ -94,3 +105,264 @@ func testServer(t *testing.T, store account.Store) {
 		}
 	})
 }
+
+// staffAccounts overlays a set of staff accounts on an account.Store, whose
+// in-memory implementation has none.
+type staffAccounts struct {
+	account.Store
+	staff sync.Map
+}
+
+func (s *staffAccounts) IsStaff(_ context.Context, userID *commonpb.UserId) (bool, error) {
+	_, ok := s.staff.Load(string(userID.Value))
+	return ok, nil
+}
+
+func testServer_suspensions(t *testing.T, store account.Store) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	accounts := &staffAccounts{Store: store}
+	authn := auth.NewKeyPairAuthenticator(log)
+	server := account.NewServer(log, accounts, authn, account.NewAuthorizer(log, accounts, authn))
+
+	cc := testutil.RunGRPCServer(t, log, testutil.WithService(func(s *grpc.Server) {
+		accountpb.RegisterAccountServer(s, server)
+	}))
+	client := accountpb.NewAccountClient(cc)
+
+	userID := model.MustGenerateUserID()
+	signer := model.MustGenerateKeyPair()
+	_, err := store.Bind(ctx, userID, signer.Proto())
+	require.NoError(t, err)
+
+	staff := model.MustGenerateUserID()
+	staffSigner := model.MustGenerateKeyPair()
+	_, err = store.Bind(ctx, staff, staffSigner.Proto())
+	require.NoError(t, err)
+	accounts.staff.Store(string(staff.Value), struct{}{})
+
+	apply := func(t *testing.T, signer model.KeyPair, userID *commonpb.UserId, state account.SuspensionState, reason string, duration time.Duration) (*account.Suspension, error) {
+		req := &account.ApplySuspensionRequest{UserID: userID, State: state, Reason: reason, Duration: duration, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.ApplySuspension(ctx, req)
+	}
+	lift := func(t *testing.T, signer model.KeyPair, userID *commonpb.UserId, reason string) error {
+		req := &account.LiftSuspensionRequest{UserID: userID, Reason: reason, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.LiftSuspension(ctx, req)
+	}
+	getHistory := func(t *testing.T, signer model.KeyPair, userID *commonpb.UserId) ([]*account.Suspension, error) {
+		req := &account.GetSuspensionHistoryRequest{UserID: userID, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.GetSuspensionHistory(ctx, req)
+	}
+
+	getFlagsHeader := func(t *testing.T) metadata.MD {
+		req := &accountpb.GetUserFlagsRequest{UserId: userID}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+
+		var header metadata.MD
+		resp, err := client.GetUserFlags(ctx, req, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Equal(t, accountpb.GetUserFlagsResponse_OK, resp.Result)
+		return header
+	}
+
+	t.Run("StaffOnly", func(t *testing.T) {
+		_, err := apply(t, signer, userID, account.SuspensionBanned, "abuse", 0)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		err = lift(t, signer, userID, "appeal")
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = getHistory(t, signer, userID)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Signed", func(t *testing.T) {
+		// The signature covers the target, so it cannot be replayed at another
+		req := &account.ApplySuspensionRequest{UserID: staff, State: account.SuspensionBanned, Reason: "abuse", Ts: time.Now()}
+		require.NoError(t, staffSigner.Auth(req.Payload(), &req.Auth))
+		req.UserID = userID
+		_, err := server.ApplySuspension(ctx, req)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("InvalidArguments", func(t *testing.T) {
+		_, err := apply(t, staffSigner, userID, "frozen", "abuse", 0)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = apply(t, staffSigner, userID, account.SuspensionBanned, "", 0)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = apply(t, staffSigner, model.MustGenerateUserID(), account.SuspensionBanned, "abuse", 0)
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		err = lift(t, staffSigner, userID, "appeal")
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("SurfacedInUserFlags", func(t *testing.T) {
+		header := getFlagsHeader(t)
+		require.Empty(t, header.Get(account.SuspensionStateHeader))
+
+		suspension, err := apply(t, staffSigner, userID, account.SuspensionReadOnly, "harassment", 24*time.Hour)
+		require.NoError(t, err)
+
+		header = getFlagsHeader(t)
+		require.Equal(t, []string{string(account.SuspensionReadOnly)}, header.Get(account.SuspensionStateHeader))
+		require.Equal(t, []string{"harassment"}, header.Get(account.SuspensionReasonHeader))
+		require.Equal(t, []string{suspension.ExpiresAt.UTC().Format(time.RFC3339)}, header.Get(account.SuspensionExpiresAtHeader))
+
+		_, err = apply(t, staffSigner, userID, account.SuspensionBanned, "ban evasion", 0)
+		require.NoError(t, err)
+
+		header = getFlagsHeader(t)
+		require.Equal(t, []string{string(account.SuspensionBanned)}, header.Get(account.SuspensionStateHeader))
+		require.Empty(t, header.Get(account.SuspensionExpiresAtHeader))
+
+		require.NoError(t, lift(t, staffSigner, userID, "appeal granted"))
+
+		header = getFlagsHeader(t)
+		require.Empty(t, header.Get(account.SuspensionStateHeader))
+	})
+
+	t.Run("History", func(t *testing.T) {
+		history, err := getHistory(t, staffSigner, userID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+
+		require.Equal(t, account.SuspensionBanned, history[0].State)
+		require.Equal(t, "appeal granted", history[0].LiftReason)
+		require.NoError(t, protoutil.ProtoEqualError(staff, history[0].LiftedBy))
+
+		require.Equal(t, account.SuspensionReadOnly, history[1].State)
+		require.Equal(t, account.LiftReasonSuperseded, history[1].LiftReason)
+		require.NoError(t, protoutil.ProtoEqualError(staff, history[1].SuspendedBy))
+	})
+
+	t.Run("Suspender", func(t *testing.T) {
+		restrict := account.NewSuspender(store, account.SuspensionRestricted, time.Hour)
+		for range 2 {
+			require.NoError(t, restrict.SuspendAccount(ctx, userID, "reported", staff))
+		}
+
+		history, err := getHistory(t, staffSigner, userID)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		require.Equal(t, account.SuspensionRestricted, history[0].State)
+
+		// A more severe suspension escalates; a less severe one is a no-op
+		ban := account.NewSuspender(store, account.SuspensionBanned, 0)
+		require.NoError(t, ban.SuspendAccount(ctx, userID, "reported again", staff))
+		require.NoError(t, restrict.SuspendAccount(ctx, userID, "reported", staff))
+
+		suspension, err := store.GetSuspension(ctx, userID)
+		require.NoError(t, err)
+		require.Equal(t, account.SuspensionBanned, suspension.State)
+		require.True(t, suspension.ExpiresAt.IsZero())
+	})
+}
+
+func testServer_keys(t *testing.T, store account.Store) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	// Served through the cache, as in production, so revocation is seen past it.
+	accounts := cache.NewInCache(store)
+	authn := auth.NewKeyPairAuthenticator(log)
+	authz := account.NewAuthorizer(log, accounts, authn)
+	server := account.NewServer(log, accounts, authn, authz)
+
+	userID := model.MustGenerateUserID()
+	primary := model.MustGenerateKeyPair()
+	_, err := store.Bind(ctx, userID, primary.Proto())
+	require.NoError(t, err)
+
+	device := model.MustGenerateKeyPair()
+	var deviceSignature *commonpb.Signature
+	require.NoError(t, device.Sign(userID, &deviceSignature))
+
+	authorize := func(t *testing.T, signer model.KeyPair) error {
+		req := &accountpb.GetUserFlagsRequest{UserId: userID}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		_, err := authz.Authorize(ctx, req, &req.Auth)
+		return err
+	}
+	addKey := func(t *testing.T, signature *commonpb.Signature, label string) (*account.Key, error) {
+		req := &account.AddKeyRequest{PubKey: device.Proto(), Signature: signature, Label: label, Ts: time.Now()}
+		require.NoError(t, primary.Auth(req.Payload(), &req.Auth))
+		return server.AddKey(ctx, req)
+	}
+	listKeys := func(t *testing.T) ([]*account.Key, error) {
+		req := &account.ListKeysRequest{Ts: time.Now()}
+		require.NoError(t, primary.Auth(req.Payload(), &req.Auth))
+		return server.ListKeys(ctx, req)
+	}
+	revokeKey := func(t *testing.T, pubKey *commonpb.PublicKey) error {
+		req := &account.RevokeKeyRequest{PubKey: pubKey, Ts: time.Now()}
+		require.NoError(t, primary.Auth(req.Payload(), &req.Auth))
+		return server.RevokeKey(ctx, req)
+	}
+
+	t.Run("AddKey", func(t *testing.T) {
+		// The new key must sign the caller's UserId
+		var wrongSignature *commonpb.Signature
+		require.NoError(t, device.Sign(model.MustGenerateUserID(), &wrongSignature))
+		_, err := addKey(t, wrongSignature, "tablet")
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = addKey(t, deviceSignature, strings.Repeat("a", 65))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		// The request must be signed by a key already bound to the caller
+		req := &account.AddKeyRequest{PubKey: device.Proto(), Signature: deviceSignature, Label: "tablet", Ts: time.Now()}
+		require.NoError(t, device.Auth(req.Payload(), &req.Auth))
+		_, err = server.AddKey(ctx, req)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		require.Equal(t, codes.PermissionDenied, status.Code(authorize(t, device)))
+
+		key, err := addKey(t, deviceSignature, "tablet")
+		require.NoError(t, err)
+		require.Equal(t, "tablet", key.Label)
+		require.False(t, key.IsPrimary)
+
+		_, err = addKey(t, deviceSignature, "tablet")
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+		require.NoError(t, authorize(t, device))
+	})
+
+	t.Run("ListKeys", func(t *testing.T) {
+		keys, err := listKeys(t)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+
+		require.NoError(t, protoutil.ProtoEqualError(primary.Proto(), keys[0].PubKey))
+		require.True(t, keys[0].IsPrimary)
+		require.False(t, keys[0].LastUsedAt.IsZero())
+
+		require.NoError(t, protoutil.ProtoEqualError(device.Proto(), keys[1].PubKey))
+		require.Equal(t, "tablet", keys[1].Label)
+		require.False(t, keys[1].LastUsedAt.IsZero())
+	})
+
+	t.Run("RevokeKey", func(t *testing.T) {
+		err := revokeKey(t, primary.Proto())
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		err = revokeKey(t, model.MustGenerateKeyPair().Proto())
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		require.NoError(t, revokeKey(t, device.Proto()))
+		require.Equal(t, codes.PermissionDenied, status.Code(authorize(t, device)))
+		require.NoError(t, authorize(t, primary))
+
+		keys, err := listKeys(t)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.NoError(t, protoutil.ProtoEqualError(primary.Proto(), keys[0].PubKey))
+	})
+}
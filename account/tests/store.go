@@ -2,7 +2,9 @@ package tests
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mr-tron/base58"
 	"github.com/stretchr/testify/require"
@@ -17,8 +19,11 @@ import (
 func RunStoreTests(t *testing.T, s account.Store, teardown func()) {
 	for _, tf := range []func(t *testing.T, s account.Store){
 		testStore_keyManagement,
+		testStore_deviceKeys,
 		testStore_batchGetUserIds,
 		testStore_registrationStatus,
+		testStore_suspensions,
+		testStore_deleteUser,
 	} {
 		tf(t, s)
 		teardown()
@@ -66,6 +71,117 @@ func testStore_keyManagement(t *testing.T, s account.Store) {
 	require.NoError(t, err)
 	require.NoError(t, protoutil.SetEqualError([]*commonpb.PublicKey{keyPair}, actualKeyPairs))
 
+	// Concurrent binds of one user bind exactly one key
+	raced := model.MustGenerateUserID()
+	errs := make([]error, 8)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = s.Bind(ctx, raced, model.MustGenerateKeyPair().Proto())
+		})
+	}
+	wg.Wait()
+
+	var bound int
+	for _, err := range errs {
+		if err == nil {
+			bound++
+		} else {
+			require.Equal(t, account.ErrManyPublicKeys, err)
+		}
+	}
+	require.Equal(t, 1, bound)
+
+	actualKeyPairs, err = s.GetPubKeys(ctx, raced)
+	require.NoError(t, err)
+	require.Len(t, actualKeyPairs, 1)
+}
+
+func testStore_deviceKeys(t *testing.T, s account.Store) {
+	ctx := context.Background()
+
+	user := model.MustGenerateUserID()
+	primary := model.MustGenerateKeyPair().Proto()
+	device := &account.Key{
+		PubKey:    model.MustGenerateKeyPair().Proto(),
+		Label:     "tablet",
+		CreatedAt: time.Now().Add(time.Second).Truncate(time.Millisecond),
+	}
+
+	// Keys can only be added to an existing account
+	require.ErrorIs(t, s.AddPubKey(ctx, user, device), account.ErrNotFound)
+	_, err := s.GetPrimaryPubKey(ctx, user)
+	require.ErrorIs(t, err, account.ErrNotFound)
+
+	_, err = s.Bind(ctx, user, primary)
+	require.NoError(t, err)
+	require.NoError(t, s.AddPubKey(ctx, user, device))
+
+	// A key bound to any account cannot be added again
+	require.ErrorIs(t, s.AddPubKey(ctx, user, device), account.ErrKeyBound)
+	other := model.MustGenerateUserID()
+	otherKey := model.MustGenerateKeyPair().Proto()
+	_, err = s.Bind(ctx, other, otherKey)
+	require.NoError(t, err)
+	require.ErrorIs(t, s.AddPubKey(ctx, user, &account.Key{PubKey: otherKey, CreatedAt: time.Now()}), account.ErrKeyBound)
+
+	actualPrimary, err := s.GetPrimaryPubKey(ctx, user)
+	require.NoError(t, err)
+	require.NoError(t, protoutil.ProtoEqualError(primary, actualPrimary))
+
+	authorized, err := s.IsAuthorized(ctx, user, device.PubKey)
+	require.NoError(t, err)
+	require.True(t, authorized)
+
+	actualUser, err := s.GetUserId(ctx, device.PubKey)
+	require.NoError(t, err)
+	require.NoError(t, protoutil.ProtoEqualError(user, actualUser))
+
+	pubKeys, err := s.GetPubKeys(ctx, user)
+	require.NoError(t, err)
+	require.NoError(t, protoutil.SetEqualError([]*commonpb.PublicKey{primary, device.PubKey}, pubKeys))
+
+	// Last-used times only move forward
+	usedAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.MarkPubKeyUsed(ctx, device.PubKey, usedAt))
+	require.NoError(t, s.MarkPubKeyUsed(ctx, device.PubKey, usedAt.Add(-time.Minute)))
+	require.NoError(t, s.MarkPubKeyUsed(ctx, model.MustGenerateKeyPair().Proto(), usedAt))
+
+	keys, err := s.GetKeys(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.NoError(t, protoutil.ProtoEqualError(primary, keys[0].PubKey))
+	require.True(t, keys[0].IsPrimary)
+	require.Empty(t, keys[0].Label)
+	require.True(t, keys[0].LastUsedAt.IsZero())
+	require.NoError(t, protoutil.ProtoEqualError(device.PubKey, keys[1].PubKey))
+	require.False(t, keys[1].IsPrimary)
+	require.Equal(t, "tablet", keys[1].Label)
+	require.True(t, device.CreatedAt.Equal(keys[1].CreatedAt))
+	require.True(t, usedAt.Equal(keys[1].LastUsedAt))
+
+	// Only the user's own, non-primary keys can be revoked
+	require.ErrorIs(t, s.RevokePubKey(ctx, user, primary), account.ErrPrimaryKey)
+	require.ErrorIs(t, s.RevokePubKey(ctx, user, otherKey), account.ErrNotFound)
+	require.ErrorIs(t, s.RevokePubKey(ctx, other, device.PubKey), account.ErrNotFound)
+
+	require.NoError(t, s.RevokePubKey(ctx, user, device.PubKey))
+	require.ErrorIs(t, s.RevokePubKey(ctx, user, device.PubKey), account.ErrNotFound)
+
+	authorized, err = s.IsAuthorized(ctx, user, device.PubKey)
+	require.NoError(t, err)
+	require.False(t, authorized)
+
+	_, err = s.GetUserId(ctx, device.PubKey)
+	require.ErrorIs(t, err, account.ErrNotFound)
+
+	keys, err = s.GetKeys(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.NoError(t, protoutil.ProtoEqualError(primary, keys[0].PubKey))
+
+	// A revoked key can be bound again
+	require.NoError(t, s.AddPubKey(ctx, user, device))
 }
 
 func testStore_batchGetUserIds(t *testing.T, s account.Store) {
@@ -129,3 +245,139 @@ func testStore_registrationStatus(t *testing.T, s account.Store) {
 	require.Nil(t, err)
 	require.False(t, isRegistered)
 }
+
+func testStore_deleteUser(t *testing.T, s account.Store) {
+	ctx := context.Background()
+
+	user := model.MustGenerateUserID()
+	other := model.MustGenerateUserID()
+	staff := model.MustGenerateUserID()
+
+	// Deleting an unknown user is a no-op
+	require.NoError(t, s.DeleteUser(ctx, user))
+
+	keyPair := model.MustGenerateKeyPair().Proto()
+	_, err := s.Bind(ctx, user, keyPair)
+	require.NoError(t, err)
+	require.NoError(t, s.SetRegistrationFlag(ctx, user, true))
+	require.NoError(t, s.Suspend(ctx, account.NewSuspension(user, account.SuspensionBanned, "spam", staff, 0)))
+
+	otherKeyPair := model.MustGenerateKeyPair().Proto()
+	_, err = s.Bind(ctx, other, otherKeyPair)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteUser(ctx, user))
+	require.NoError(t, s.DeleteUser(ctx, user))
+
+	_, err = s.GetUserId(ctx, keyPair)
+	require.ErrorIs(t, err, account.ErrNotFound)
+
+	pubKeys, err := s.GetPubKeys(ctx, user)
+	require.NoError(t, err)
+	require.Empty(t, pubKeys)
+
+	authorized, err := s.IsAuthorized(ctx, user, keyPair)
+	require.NoError(t, err)
+	require.False(t, authorized)
+
+	isRegistered, err := s.IsRegistered(ctx, user)
+	require.NoError(t, err)
+	require.False(t, isRegistered)
+
+	// Suspensions are kept as the audit trail
+	suspensions, err := s.GetSuspensions(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, suspensions, 1)
+
+	// Other users are untouched
+	actual, err := s.GetUserId(ctx, otherKeyPair)
+	require.NoError(t, err)
+	require.Equal(t, other.Value, actual.Value)
+}
+
+func testStore_suspensions(t *testing.T, s account.Store) {
+	ctx := context.Background()
+
+	user := model.MustGenerateUserID()
+	staff := model.MustGenerateUserID()
+	other := model.MustGenerateUserID()
+
+	_, err := s.GetSuspension(ctx, user)
+	require.ErrorIs(t, err, account.ErrNotSuspended)
+	require.ErrorIs(t, s.LiftSuspension(ctx, user, staff, "no reason", time.Now()), account.ErrNotSuspended)
+
+	suspensions, err := s.GetSuspensions(ctx, user)
+	require.NoError(t, err)
+	require.Empty(t, suspensions)
+
+	// An expired suspension is not in force
+	expired := account.NewSuspension(user, account.SuspensionBanned, "spam", staff, time.Minute)
+	expired.CreatedAt = time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	expired.ExpiresAt = expired.CreatedAt.Add(time.Minute)
+	require.NoError(t, s.Suspend(ctx, expired))
+
+	_, err = s.GetSuspension(ctx, user)
+	require.ErrorIs(t, err, account.ErrNotSuspended)
+
+	restricted := account.NewSuspension(user, account.SuspensionRestricted, "chargebacks", staff, 0)
+	restricted.CreatedAt = restricted.CreatedAt.Add(-time.Minute).Truncate(time.Millisecond)
+	require.NoError(t, s.Suspend(ctx, restricted))
+
+	actual, err := s.GetSuspension(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentSuspensions(t, restricted, actual)
+
+	_, err = s.GetSuspension(ctx, other)
+	require.ErrorIs(t, err, account.ErrNotSuspended)
+
+	// A new suspension supersedes the one in force
+	readOnly := account.NewSuspension(user, account.SuspensionReadOnly, "harassment", other, 24*time.Hour)
+	readOnly.CreatedAt = readOnly.CreatedAt.Truncate(time.Millisecond)
+	readOnly.ExpiresAt = readOnly.ExpiresAt.Truncate(time.Millisecond)
+	require.NoError(t, s.Suspend(ctx, readOnly))
+
+	actual, err = s.GetSuspension(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentSuspensions(t, readOnly, actual)
+
+	liftedAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.LiftSuspension(ctx, user, staff, "appeal granted", liftedAt))
+
+	_, err = s.GetSuspension(ctx, user)
+	require.ErrorIs(t, err, account.ErrNotSuspended)
+	require.ErrorIs(t, s.LiftSuspension(ctx, user, staff, "appeal granted", time.Now()), account.ErrNotSuspended)
+
+	// The full history is kept, most recent first
+	suspensions, err = s.GetSuspensions(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, suspensions, 3)
+
+	readOnly.LiftedBy = staff
+	readOnly.LiftReason = "appeal granted"
+	readOnly.LiftedAt = liftedAt
+	assertEquivalentSuspensions(t, readOnly, suspensions[0])
+
+	restricted.LiftedBy = other
+	restricted.LiftReason = account.LiftReasonSuperseded
+	restricted.LiftedAt = readOnly.CreatedAt
+	assertEquivalentSuspensions(t, restricted, suspensions[1])
+
+	assertEquivalentSuspensions(t, expired, suspensions[2])
+}
+
+func assertEquivalentSuspensions(t *testing.T, expected, actual *account.Suspension) {
+	require.Equal(t, expected.ID, actual.ID)
+	require.NoError(t, protoutil.ProtoEqualError(expected.UserID, actual.UserID))
+	require.Equal(t, expected.State, actual.State)
+	require.Equal(t, expected.Reason, actual.Reason)
+	require.NoError(t, protoutil.ProtoEqualError(expected.SuspendedBy, actual.SuspendedBy))
+	require.True(t, expected.CreatedAt.Equal(actual.CreatedAt))
+	require.True(t, expected.ExpiresAt.Equal(actual.ExpiresAt))
+	if expected.LiftedBy == nil {
+		require.Nil(t, actual.LiftedBy)
+	} else {
+		require.NoError(t, protoutil.ProtoEqualError(expected.LiftedBy, actual.LiftedBy))
+	}
+	require.Equal(t, expected.LiftReason, actual.LiftReason)
+	require.True(t, expected.LiftedAt.Equal(actual.LiftedAt))
+}
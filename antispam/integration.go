@@ -2,6 +2,7 @@ package antispam
 
 import (
 	"context"
+	"fmt"
 
 	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 	ocp_transactionpb "github.com/code-payments/ocp-protobuf-api/generated/go/transaction/v1"
@@ -41,7 +42,7 @@ func (i *Integration) AllowOpenAccounts(ctx context.Context, owner *ocp_common.A
 		if !isRegistered {
 			return false, "flipcash user has not completed iap for account creation", nil
 		}
-		return true, "", nil
+		return i.allowUnsuspended(ctx, userID)
 	case ocp_transactionpb.OpenAccountsMetadata_POOL:
 		return true, "", nil
 	default:
@@ -54,11 +55,20 @@ func (i *Integration) AllowWelcomeBonus(_ context.Context, _ *ocp_common.Account
 	return true, "", nil
 }
 
-func (i *Integration) AllowSendPayment(_ context.Context, _, _ *ocp_common.Account, isPublic bool) (bool, string, error) {
+func (i *Integration) AllowSendPayment(ctx context.Context, owner, _ *ocp_common.Account, isPublic bool) (bool, string, error) {
 	if !isPublic {
 		return false, "flipcash payments must be public", nil
 	}
-	return true, "", nil
+
+	userID, err := i.accounts.GetUserId(ctx, &commonpb.PublicKey{Value: owner.PublicKey().ToBytes()})
+	if err == account.ErrNotFound {
+		// Not every owner is a flipcash user's key, so there is no suspension to
+		// honor.
+		return true, "", nil
+	} else if err != nil {
+		return false, "", err
+	}
+	return i.allowUnsuspended(ctx, userID)
 }
 
 func (i *Integration) AllowReceivePayments(_ context.Context, _ *ocp_common.Account, isPublic bool) (bool, string, error) {
@@ -95,5 +105,17 @@ func (i *Integration) AllowCurrencyLaunch(ctx context.Context, owner *common.Acc
 	if !isRegistered {
 		return false, "flipcash user has not completed iap", nil
 	}
-	return true, "", nil
+	return i.allowUnsuspended(ctx, userID)
+}
+
+// allowUnsuspended denies a user under any suspension: even a restricted account
+// cannot move funds.
+func (i *Integration) allowUnsuspended(ctx context.Context, userID *commonpb.UserId) (bool, string, error) {
+	suspension, err := i.accounts.GetSuspension(ctx, userID)
+	if err == account.ErrNotSuspended {
+		return true, "", nil
+	} else if err != nil {
+		return false, "", err
+	}
+	return false, fmt.Sprintf("flipcash user is suspended (%s)", suspension.State), nil
 }
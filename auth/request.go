@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Unpublished RPCs
+//
+// Several servers implement RPCs their published services do not define yet,
+// as Go methods for a transport to call until the protos catch up. Each takes a
+// request struct carrying Auth, authorized through an Authorizer like a
+// published request. There is no request message to sign, so the signature is
+// made over the request's Payload instead, built with NewPayload: a ListValue
+// naming the method, then the time the request was signed, then its arguments.
+// Naming the method keeps a signature for one from authorizing another, and the
+// signed time lets the Authorizer reject a stale request as a replay.
+
+// NewPayload returns the signed payload of an unpublished request to method,
+// signed at ts. method is the RPC's full name, such as
+// flipcash.blob.v1.BlobStorage/BlockBlob. Arguments are strings, so numbers are
+// signed exactly rather than as floating point.
+func NewPayload(method string, ts time.Time, args ...string) *structpb.ListValue {
+	values := make([]*structpb.Value, 0, len(args)+2)
+	values = append(values,
+		structpb.NewStringValue(method),
+		structpb.NewStringValue(ts.UTC().Format(time.RFC3339Nano)),
+	)
+	for _, arg := range args {
+		values = append(values, structpb.NewStringValue(arg))
+	}
+	return &structpb.ListValue{Values: values}
+}
+
+// PayloadMethod returns the method an unpublished request's payload names, and
+// false if m is not such a payload.
+func PayloadMethod(m proto.Message) (string, bool) {
+	method, _, ok := parsePayload(m)
+	return method, ok
+}
+
+// PayloadTimestamp returns the time an unpublished request's payload was
+// signed at, and false if m is not such a payload.
+func PayloadTimestamp(m proto.Message) (time.Time, bool) {
+	_, ts, ok := parsePayload(m)
+	return ts, ok
+}
+
+func parsePayload(m proto.Message) (string, time.Time, bool) {
+	list, ok := m.(*structpb.ListValue)
+	if !ok || len(list.Values) < 2 {
+		return "", time.Time{}, false
+	}
+	method, ok := list.Values[0].Kind.(*structpb.Value_StringValue)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	signedAt, ok := list.Values[1].Kind.(*structpb.Value_StringValue)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, signedAt.StringValue)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return method.StringValue, ts, true
+}
@@ -142,6 +142,13 @@ type AccessStore interface {
 	// returns ErrInvalidGrant if the lookup key is not well-formed.
 	HasGrant(ctx context.Context, blobID *blobpb.BlobId, p Principal, perm Permission) (bool, error)
 
+	// HasAnyGrant reports whether any grant exists on the blob, to any principal
+	// for any permission. Grants are the blob reference graph — a message
+	// attachment or profile picture is granted to its chat or profile — so the
+	// garbage collector treats a READY original with none as unreferenced. It
+	// returns ErrInvalidGrant if the blob id is missing.
+	HasAnyGrant(ctx context.Context, blobID *blobpb.BlobId) (bool, error)
+
 	// Revoke removes a grant. It is idempotent: revoking a grant that does not
 	// exist is a no-op. It returns ErrInvalidGrant if the key is not well-formed.
 	Revoke(ctx context.Context, blobID *blobpb.BlobId, p Principal, perm Permission) error
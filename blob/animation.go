@@ -0,0 +1,614 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/png"
+	"time"
+
+	"github.com/gen2brain/webp"
+	"golang.org/x/image/draw"
+)
+
+// Animated images — GIF, APNG, and animated WebP — are accepted as images in
+// their own right. The original is served as uploaded, so every frame a client
+// could show must be safe: moderation covers the first frame plus a sample
+// spread across the animation, and the limits below bound what decoding all of
+// it can cost. Renditions are static thumbnails of the first frame; the animated
+// original is what a client displays (see planImageRenditions).
+
+const (
+	// maxAnimatedImageFrames bounds the number of frames in an animation. It is
+	// checked from the container structure before any frame is decoded.
+	maxAnimatedImageFrames = 500
+
+	// maxAnimatedImageDuration bounds the total playback time of one loop of an
+	// animation. Chat GIFs are short loops; anything longer belongs in a video.
+	maxAnimatedImageDuration = time.Minute
+
+	// maxAnimatedImagePixels bounds the canvas area times the frame count — the
+	// pixels decoding the whole animation produces. It is the animated
+	// counterpart of maxImagePixels, and just as generous: an animation may cost
+	// no more to decode than the largest still the service accepts.
+	maxAnimatedImagePixels = maxImagePixels
+
+	// animatedModerationSamples is how many frames, spread evenly across the
+	// animation, are moderated in addition to the first. An animation shorter
+	// than that has every frame moderated.
+	animatedModerationSamples = 8
+
+	// defaultAnimationFrameDelay is the delay browsers substitute for a GIF frame
+	// that declares none (or an implausibly short one), and so the delay the
+	// duration limit charges it.
+	defaultAnimationFrameDelay = 100 * time.Millisecond
+)
+
+// animationInfo is what is read from an animated image's container structure,
+// without decoding a frame: how many frames it holds and how long one loop of
+// them plays for.
+type animationInfo struct {
+	frames   int
+	duration time.Duration
+}
+
+// addFrame counts one more frame of the given delay. The running duration
+// saturates just past the limit, so a hostile file declaring enormous delays
+// cannot overflow it.
+func (a *animationInfo) addFrame(delay time.Duration) {
+	a.frames++
+	a.duration = min(a.duration+delay, maxAnimatedImageDuration+time.Second)
+}
+
+// imageAnimation reports whether the encoded image is animated and, if so, its
+// frame count and duration, read from the container structure alone. A
+// malformed stream is reported as not-animated; the regular decode path rejects
+// it on its own.
+func imageAnimation(format string, data []byte) (animationInfo, bool) {
+	switch format {
+	case "gif":
+		return gifAnimation(data)
+	case "png":
+		return pngAnimation(data)
+	case "webp":
+		return webpAnimation(data)
+	default:
+		// JPEG (and anything else that decodes) is single-frame.
+		return animationInfo{}, false
+	}
+}
+
+// checkAnimationLimits rejects an animation whose frame count, duration, or
+// total decoded pixels exceed the limits, given its canvas dimensions.
+func checkAnimationLimits(info animationInfo, width, height int) error {
+	if info.frames > maxAnimatedImageFrames {
+		return fmt.Errorf("animation has %d frames, exceeding the %d frame limit: %w", info.frames, maxAnimatedImageFrames, ErrImageTooLarge)
+	}
+	if info.duration > maxAnimatedImageDuration {
+		return fmt.Errorf("animation duration exceeds the %s limit: %w", maxAnimatedImageDuration, ErrImageTooLarge)
+	}
+	if int64(width)*int64(height)*int64(info.frames) > maxAnimatedImagePixels {
+		return fmt.Errorf("animation of %d %dx%d frames exceeds the %d pixel limit: %w", info.frames, width, height, maxAnimatedImagePixels, ErrImageTooLarge)
+	}
+	return nil
+}
+
+// animationSampleIndices picks which frames of an animation are moderated: the
+// first, then up to animatedModerationSamples more spread evenly through to the
+// last. It returns ascending indices.
+func animationSampleIndices(frames int) []int {
+	if frames <= 1+animatedModerationSamples {
+		indices := make([]int, frames)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+	indices := make([]int, 1+animatedModerationSamples)
+	for i := range indices {
+		indices[i] = i * (frames - 1) / animatedModerationSamples
+	}
+	return indices
+}
+
+// decodeAnimationFrames decodes an animation and returns the fully composited
+// frames at the given ascending indices — each as it would be displayed, with
+// earlier frames' disposal and blending applied — so a sampled frame shows
+// exactly what a viewer sees at that point.
+func decodeAnimationFrames(format string, data []byte, indices []int) ([]image.Image, error) {
+	var (
+		frames []image.Image
+		err    error
+	)
+	switch format {
+	case "gif":
+		frames, err = decodeGIFFrames(data, indices)
+	case "png":
+		frames, err = decodeAPNGFrames(data, indices)
+	case "webp":
+		frames, err = decodeAnimatedWebPFrames(data, indices)
+	default:
+		return nil, fmt.Errorf("unsupported animated format %q: %w", format, ErrImageUnsupportedType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode animation: %v: %w", err, ErrImageCorrupt)
+	}
+	if len(frames) != len(indices) || len(frames) == 0 {
+		return nil, fmt.Errorf("animation is missing frames: %w", ErrImageCorrupt)
+	}
+	return frames, nil
+}
+
+// frameSelector reports, frame by frame in order, whether a frame was asked for.
+type frameSelector struct {
+	indices []int
+	next    int
+}
+
+func (s *frameSelector) wants(i int) bool {
+	if s.next < len(s.indices) && s.indices[s.next] == i {
+		s.next++
+		return true
+	}
+	return false
+}
+
+func cloneRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+// --- GIF ---------------------------------------------------------------------
+
+// gifBlock is one top-level block of a GIF stream: an extension (introducer
+// 0x21, with its label) or an image descriptor (0x2C), plus the first data
+// sub-block that follows it and the block's raw bytes.
+type gifBlock struct {
+	introducer byte
+	label      byte
+	first      []byte
+	raw        []byte
+}
+
+// gifHasColorTable is the flag bit, in both the logical screen and image
+// descriptors, announcing a color table that follows the descriptor.
+const gifHasColorTable = 0x80
+
+// gifHeaderLen returns the length of a GIF stream's header: the signature and
+// version, the logical screen descriptor, and the global color table if any.
+func gifHeaderLen(data []byte) (int, error) {
+	const headerLen = 6 + 7 // signature + version, logical screen descriptor
+	if len(data) < headerLen || string(data[:3]) != "GIF" {
+		return 0, errors.New("not a gif stream")
+	}
+	pos := headerLen
+	if flags := data[10]; flags&gifHasColorTable != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+	return pos, nil
+}
+
+// gifBlocks walks a GIF stream's block structure up to the trailer, without
+// decompressing any image data.
+func gifBlocks(data []byte) ([]gifBlock, error) {
+	pos, err := gifHeaderLen(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []gifBlock
+	for pos < len(data) {
+		start := pos
+		switch data[pos] {
+		case 0x21: // extension: label, then data sub-blocks
+			if pos+2 > len(data) {
+				return nil, errors.New("truncated gif extension")
+			}
+			first, next, err := gifSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, gifBlock{introducer: 0x21, label: data[pos+1], first: first, raw: data[start:next]})
+			pos = next
+		case 0x2C: // image descriptor: 9 bytes, local color table, LZW code size, data
+			if pos+10 > len(data) {
+				return nil, errors.New("truncated gif image descriptor")
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&gifHasColorTable != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+			first, next, err := gifSubBlocks(data, pos+1)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, gifBlock{introducer: 0x2C, first: first, raw: data[start:next]})
+			pos = next
+		case 0x3B: // trailer
+			return blocks, nil
+		default:
+			return nil, fmt.Errorf("unknown gif block 0x%02x", data[pos])
+		}
+	}
+	return blocks, nil
+}
+
+// gifSubBlocks skips a run of length-prefixed data sub-blocks starting at pos,
+// returning the first sub-block and the position after the terminator.
+func gifSubBlocks(data []byte, pos int) ([]byte, int, error) {
+	var first []byte
+	for {
+		if pos >= len(data) {
+			return nil, 0, errors.New("truncated gif data sub-block")
+		}
+		size := int(data[pos])
+		if size == 0 {
+			return first, pos + 1, nil
+		}
+		if pos+1+size > len(data) {
+			return nil, 0, errors.New("truncated gif data sub-block")
+		}
+		if first == nil {
+			first = data[pos+1 : pos+1+size]
+		}
+		pos += 1 + size
+	}
+}
+
+// gifAnimation counts a GIF's frames and sums their delays. A frame's delay is
+// carried by the graphic control extension preceding it, in hundredths of a
+// second; delays browsers clamp are charged as they are displayed.
+func gifAnimation(data []byte) (animationInfo, bool) {
+	blocks, err := gifBlocks(data)
+	if err != nil {
+		return animationInfo{}, false
+	}
+	var (
+		info  animationInfo
+		delay = defaultAnimationFrameDelay
+	)
+	for _, block := range blocks {
+		switch {
+		case block.introducer == 0x21 && block.label == 0xF9 && len(block.first) >= 4:
+			if cs := binary.LittleEndian.Uint16(block.first[1:]); cs >= 2 {
+				delay = time.Duration(cs) * 10 * time.Millisecond
+			}
+		case block.introducer == 0x2C:
+			info.addFrame(delay)
+			delay = defaultAnimationFrameDelay
+		}
+	}
+	return info, info.frames > 1
+}
+
+// gifHasPrivacyMetadata reports whether a GIF carries a comment extension or an
+// embedded XMP packet (an application extension), the two free-form metadata
+// carriers the format has. The NETSCAPE looping extension is harmless and kept.
+func gifHasPrivacyMetadata(data []byte) bool {
+	blocks, err := gifBlocks(data)
+	if err != nil {
+		return false
+	}
+	for _, block := range blocks {
+		if gifIsMetadataBlock(block) {
+			return true
+		}
+	}
+	return false
+}
+
+// gifIsMetadataBlock reports whether a block is a comment extension or an XMP
+// application extension.
+func gifIsMetadataBlock(block gifBlock) bool {
+	if block.introducer != 0x21 {
+		return false
+	}
+	switch block.label {
+	case 0xFE: // comment
+		return true
+	case 0xFF: // application
+		return bytes.HasPrefix(block.first, []byte("XMP Data"))
+	}
+	return false
+}
+
+// decodeGIFFrames decodes every frame of a GIF and composites them in order,
+// honoring each frame's disposal, returning the canvases at indices.
+func decodeGIFFrames(data []byte, indices []int) ([]image.Image, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	selector := &frameSelector{indices: indices}
+	var out []image.Image
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if selector.wants(i) {
+			out = append(out, cloneRGBA(canvas))
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return out, nil
+}
+
+// --- APNG --------------------------------------------------------------------
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngChunk is one chunk of a PNG stream: its type and data.
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// pngChunks walks a PNG stream's chunks up to IEND. CRCs are not checked; the
+// decoder validates the chunks it is handed.
+func pngChunks(data []byte) ([]pngChunk, error) {
+	if len(data) < len(pngSignature) || string(data[:len(pngSignature)]) != pngSignature {
+		return nil, errors.New("not a png stream")
+	}
+	var chunks []pngChunk
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		if length < 0 || pos+12+length > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		chunk := pngChunk{typ: string(data[pos+4 : pos+8]), data: data[pos+8 : pos+8+length]}
+		chunks = append(chunks, chunk)
+		if chunk.typ == "IEND" {
+			break
+		}
+		// Advance past length(4) + type(4) + data(length) + crc(4).
+		pos += 12 + length
+	}
+	return chunks, nil
+}
+
+// pngAnimation reports whether a PNG carries an acTL (animation control) chunk
+// before its first IDAT — i.e. it is an APNG — and counts its frames and their
+// delays from the frame control (fcTL) chunks. The standard decoder renders only
+// the default image, which need not even be one of the frames, so an APNG is
+// always treated as an animation.
+func pngAnimation(data []byte) (animationInfo, bool) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return animationInfo{}, false
+	}
+	var (
+		info     animationInfo
+		animated bool
+		seenIDAT bool
+	)
+	for _, chunk := range chunks {
+		switch chunk.typ {
+		case "acTL":
+			// Animation chunks must precede the first IDAT; a late one is ignored
+			// by players, so the file is a still PNG.
+			animated = animated || !seenIDAT
+		case "IDAT":
+			seenIDAT = true
+		case "fcTL":
+			if len(chunk.data) < 26 {
+				continue
+			}
+			num := binary.BigEndian.Uint16(chunk.data[20:])
+			den := binary.BigEndian.Uint16(chunk.data[22:])
+			if den == 0 {
+				den = 100
+			}
+			info.addFrame(time.Duration(num) * time.Second / time.Duration(den))
+		}
+	}
+	if !animated {
+		return animationInfo{}, false
+	}
+	return info, true
+}
+
+// apngFrame is one APNG frame: its frame control fields and compressed data.
+type apngFrame struct {
+	width, height  uint32
+	xOffset        uint32
+	yOffset        uint32
+	disposeOp      byte
+	blendOp        byte
+	compressedData [][]byte
+}
+
+// decodeAPNGFrames decodes every frame of an APNG and composites them in order,
+// honoring each frame's blend and dispose operations, returning the canvases at
+// indices. Each frame is decoded by the standard PNG decoder, as a standalone
+// PNG assembled from the file's header chunks and the frame's own data.
+func decodeAPNGFrames(data []byte, indices []int) ([]image.Image, error) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, errors.New("missing png header")
+	}
+	ihdr := chunks[0].data
+	canvasWidth, canvasHeight := binary.BigEndian.Uint32(ihdr[0:]), binary.BigEndian.Uint32(ihdr[4:])
+
+	// The chunks every frame needs to decode on its own: the palette and its
+	// transparency.
+	var shared []pngChunk
+	var frames []*apngFrame
+	var current *apngFrame
+	for _, chunk := range chunks[1:] {
+		switch chunk.typ {
+		case "PLTE", "tRNS":
+			shared = append(shared, chunk)
+		case "fcTL":
+			if len(chunk.data) < 26 {
+				return nil, errors.New("truncated frame control")
+			}
+			current = &apngFrame{
+				width:     binary.BigEndian.Uint32(chunk.data[4:]),
+				height:    binary.BigEndian.Uint32(chunk.data[8:]),
+				xOffset:   binary.BigEndian.Uint32(chunk.data[12:]),
+				yOffset:   binary.BigEndian.Uint32(chunk.data[16:]),
+				disposeOp: chunk.data[24],
+				blendOp:   chunk.data[25],
+			}
+			if current.width == 0 || current.height == 0 ||
+				uint64(current.xOffset)+uint64(current.width) > uint64(canvasWidth) ||
+				uint64(current.yOffset)+uint64(current.height) > uint64(canvasHeight) {
+				return nil, errors.New("frame lies outside the canvas")
+			}
+			frames = append(frames, current)
+		case "IDAT":
+			// The default image is the first frame only when a frame control
+			// precedes it; otherwise it is not part of the animation.
+			if current != nil {
+				current.compressedData = append(current.compressedData, chunk.data)
+			}
+		case "fdAT":
+			if current == nil || len(chunk.data) < 4 {
+				return nil, errors.New("frame data without frame control")
+			}
+			// Strip the sequence number; the rest is exactly IDAT data.
+			current.compressedData = append(current.compressedData, chunk.data[4:])
+		}
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, int(canvasWidth), int(canvasHeight)))
+	selector := &frameSelector{indices: indices}
+	var out []image.Image
+	for i, frame := range frames {
+		decoded, err := png.Decode(bytes.NewReader(standalonePNG(ihdr, shared, frame)))
+		if err != nil {
+			return nil, err
+		}
+		region := image.Rect(0, 0, int(frame.width), int(frame.height)).Add(image.Pt(int(frame.xOffset), int(frame.yOffset)))
+
+		const (
+			disposeBackground = 1
+			disposePrevious   = 2
+			blendOver         = 1
+		)
+		var previous *image.RGBA
+		if frame.disposeOp == disposePrevious {
+			previous = cloneRGBA(canvas)
+		}
+		op := draw.Src
+		if frame.blendOp == blendOver {
+			op = draw.Over
+		}
+		draw.Draw(canvas, region, decoded, decoded.Bounds().Min, op)
+		if selector.wants(i) {
+			out = append(out, cloneRGBA(canvas))
+		}
+
+		switch frame.disposeOp {
+		case disposeBackground:
+			draw.Draw(canvas, region, image.Transparent, image.Point{}, draw.Src)
+		case disposePrevious:
+			canvas = previous
+		}
+	}
+	return out, nil
+}
+
+// standalonePNG assembles a single APNG frame into a PNG of its own: the file's
+// header with the frame's dimensions, the shared palette chunks, and the frame's
+// data as IDAT.
+func standalonePNG(ihdr []byte, shared []pngChunk, frame *apngFrame) []byte {
+	header := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(header[0:], frame.width)
+	binary.BigEndian.PutUint32(header[4:], frame.height)
+
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	writePNGChunk(&buf, "IHDR", header)
+	for _, chunk := range shared {
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+	}
+	writePNGChunk(&buf, "IDAT", bytes.Join(frame.compressedData, nil))
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.WriteString(typ)
+	buf.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	_ = binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
+
+// --- WebP --------------------------------------------------------------------
+
+// webpAnimation reports whether a WebP is animated, i.e. an extended (VP8X) file
+// carrying ANIM/ANMF chunks, and counts its ANMF frames and their durations.
+func webpAnimation(data []byte) (animationInfo, bool) {
+	// RIFF container: "RIFF" <fileSize:4> "WEBP", then FourCC-tagged chunks.
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return animationInfo{}, false
+	}
+
+	var (
+		info     animationInfo
+		animated bool
+	)
+	for pos := 12; pos+8 <= len(data); {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		switch fourCC {
+		case "ANIM":
+			animated = true
+		case "ANMF":
+			animated = true
+			// X(3) Y(3) width-1(3) height-1(3) duration(3) flags(1), in ms.
+			if payload := data[pos+8:]; len(payload) >= 15 {
+				ms := uint32(payload[12]) | uint32(payload[13])<<8 | uint32(payload[14])<<16
+				info.addFrame(time.Duration(ms) * time.Millisecond)
+			}
+		}
+		// Chunk payloads are padded to an even length.
+		pos += 8 + size + (size & 1)
+	}
+	return info, animated
+}
+
+// decodeAnimatedWebPFrames decodes an animated WebP, whose decoder composites
+// each frame onto the canvas itself, returning the frames at indices.
+func decodeAnimatedWebPFrames(data []byte, indices []int) ([]image.Image, error) {
+	anim, err := webp.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]image.Image, 0, len(indices))
+	for _, i := range indices {
+		if i >= len(anim.Image) {
+			break
+		}
+		out = append(out, anim.Image[i])
+	}
+	return out, nil
+}
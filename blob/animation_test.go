@@ -0,0 +1,240 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/webp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectImageAnimated(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		mimeType string
+	}{
+		{"gif", encodeTestGIF(t, 5, 10), "image/gif"},
+		{"apng", encodeTestAPNG(t, testFrames(5, 24, 16), 10), "image/png"},
+		{"animated webp", encodeTestAnimatedWebP(t, testFrames(5, 24, 16), 100), "image/webp"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inspection, err := InspectImage(tc.data)
+			require.NoError(t, err)
+			require.Equal(t, tc.mimeType, inspection.MimeType)
+			require.True(t, inspection.Metadata.Animated)
+			require.EqualValues(t, 5, inspection.Metadata.FrameCount)
+			require.EqualValues(t, 24, inspection.Metadata.Width)
+			require.EqualValues(t, 16, inspection.Metadata.Height)
+			require.NotEmpty(t, inspection.Metadata.Blurhash)
+
+			// Every frame after the first is sampled, since there are few enough.
+			require.Len(t, inspection.Frames, 4)
+			requireColorAt(t, inspection.Decoded, testFrameColor(0))
+			for i, frame := range inspection.Frames {
+				requireColorAt(t, frame, testFrameColor(i+1))
+			}
+		})
+	}
+}
+
+func TestInspectImageAnimationLimits(t *testing.T) {
+	t.Run("too many frames", func(t *testing.T) {
+		_, err := InspectImage(encodeTestGIF(t, maxAnimatedImageFrames+1, 2))
+		require.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		// Two frames of 40 seconds each.
+		_, err := InspectImage(encodeTestGIF(t, 2, 4000))
+		require.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("undeclared delays are charged as displayed", func(t *testing.T) {
+		// Browsers play a GIF frame declaring no delay for 100ms, so that is what
+		// each frame is charged.
+		info, animated := gifAnimation(encodeTestGIF(t, 100, 0))
+		require.True(t, animated)
+		require.Equal(t, 10*time.Second, info.duration)
+	})
+
+	t.Run("too many decoded pixels", func(t *testing.T) {
+		require.ErrorIs(t, checkAnimationLimits(animationInfo{frames: 100}, 1000, 1000), ErrImageTooLarge)
+		require.NoError(t, checkAnimationLimits(animationInfo{frames: 10}, 1000, 1000))
+	})
+}
+
+func TestAPNGCompositesFrames(t *testing.T) {
+	// The second frame covers only the left half and blends over the first, so
+	// the composited canvas shows both.
+	first := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	fill(first, color.RGBA{R: 255, A: 255})
+	second := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	fill(second, color.RGBA{B: 255, A: 255})
+
+	data := encodeTestAPNG(t, []image.Image{first, second}, 10)
+	frames, err := decodeAPNGFrames(data, []int{0, 1})
+	require.NoError(t, err)
+	require.Len(t, frames, 2)
+
+	require.Equal(t, color.RGBA{B: 255, A: 255}, frames[1].At(0, 0))
+	require.Equal(t, color.RGBA{R: 255, A: 255}, frames[1].At(7, 0))
+}
+
+func TestAnimationSampleIndices(t *testing.T) {
+	require.Equal(t, []int{0}, animationSampleIndices(1))
+	require.Equal(t, []int{0, 1, 2, 3}, animationSampleIndices(4))
+
+	indices := animationSampleIndices(100)
+	require.Len(t, indices, 1+animatedModerationSamples)
+	require.Equal(t, 0, indices[0])
+	require.Equal(t, 99, indices[len(indices)-1])
+	require.IsIncreasing(t, indices)
+}
+
+func TestPlanImageRenditionsAnimatedIsThumbnailsOnly(t *testing.T) {
+	plans := planImageRenditions(&ImageMetadata{Width: 480, Height: 270, Animated: true, FrameCount: 12}, true)
+	require.NotEmpty(t, plans)
+	for _, plan := range plans {
+		require.Equal(t, RenditionThumbnail, plan.rendition)
+	}
+}
+
+// testFrameColor is the solid color of the i'th test frame.
+func testFrameColor(i int) color.RGBA {
+	return color.RGBA{R: uint8(40 * i), G: 200, B: uint8(255 - 40*i), A: 255}
+}
+
+// testFrames returns n solid, opaque frames, each in its testFrameColor.
+func testFrames(n, width, height int) []image.Image {
+	frames := make([]image.Image, n)
+	for i := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		fill(img, testFrameColor(i))
+		frames[i] = img
+	}
+	return frames
+}
+
+func fill(img *image.RGBA, c color.RGBA) {
+	for y := range img.Bounds().Dy() {
+		for x := range img.Bounds().Dx() {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// requireColorAt asserts the frame's center pixel is close to want; lossy
+// encodings may drift a little.
+func requireColorAt(t *testing.T, img image.Image, want color.RGBA) {
+	t.Helper()
+	bounds := img.Bounds()
+	r, g, b, _ := img.At(bounds.Dx()/2, bounds.Dy()/2).RGBA()
+	near := func(got uint32, want uint8) bool {
+		d := int(got>>8) - int(want)
+		return d >= -24 && d <= 24
+	}
+	require.True(t, near(r, want.R) && near(g, want.G) && near(b, want.B), "got %d,%d,%d want %v", r>>8, g>>8, b>>8, want)
+}
+
+// encodeTestGIF returns a 24x16 GIF of n solid frames, each delayCS hundredths
+// of a second long.
+func encodeTestGIF(t *testing.T, n, delayCS int) []byte {
+	t.Helper()
+	var pal color.Palette
+	for i := range min(n, 6) {
+		pal = append(pal, testFrameColor(i))
+	}
+	anim := &gif.GIF{}
+	for i := range n {
+		frame := image.NewPaletted(image.Rect(0, 0, 24, 16), pal)
+		c := frame.Palette.Index(testFrameColor(i))
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8(c)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delayCS)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, anim))
+	return buf.Bytes()
+}
+
+// gifWithExtension inserts an extension block with the given label and single
+// data sub-block just before a two-frame GIF's trailer.
+func gifWithExtension(t *testing.T, label byte, payload []byte) []byte {
+	t.Helper()
+	base := encodeTestGIF(t, 2, 10)
+	out := append([]byte(nil), base[:len(base)-1]...)
+	out = append(out, 0x21, label, byte(len(payload)))
+	out = append(out, payload...)
+	return append(out, 0x00, 0x3B)
+}
+
+// encodeTestAPNG assembles an APNG from opaque frames: the first is the default
+// image (and frame 0), each later one is placed at the canvas origin and blended
+// over the one before, and every frame lasts delayCS hundredths of a second.
+func encodeTestAPNG(t *testing.T, frames []image.Image, delayCS uint16) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	var seq uint32
+	for i, frame := range frames {
+		var encoded bytes.Buffer
+		require.NoError(t, png.Encode(&encoded, frame))
+		chunks, err := pngChunks(encoded.Bytes())
+		require.NoError(t, err)
+
+		if i == 0 {
+			writePNGChunk(&buf, "IHDR", chunks[0].data)
+			actl := binary.BigEndian.AppendUint32(nil, uint32(len(frames)))
+			writePNGChunk(&buf, "acTL", binary.BigEndian.AppendUint32(actl, 0))
+		}
+
+		bounds := frame.Bounds()
+		fctl := binary.BigEndian.AppendUint32(nil, seq)
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(bounds.Dx()))
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(bounds.Dy()))
+		fctl = binary.BigEndian.AppendUint32(fctl, 0) // x offset
+		fctl = binary.BigEndian.AppendUint32(fctl, 0) // y offset
+		fctl = binary.BigEndian.AppendUint16(fctl, delayCS)
+		fctl = binary.BigEndian.AppendUint16(fctl, 100)
+		fctl = append(fctl, 0, 1) // dispose none, blend over
+		writePNGChunk(&buf, "fcTL", fctl)
+		seq++
+
+		for _, chunk := range chunks {
+			if chunk.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(&buf, "IDAT", chunk.data)
+				continue
+			}
+			writePNGChunk(&buf, "fdAT", append(binary.BigEndian.AppendUint32(nil, seq), chunk.data...))
+			seq++
+		}
+	}
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+// encodeTestAnimatedWebP encodes frames as an animated WebP, each delayMS
+// milliseconds long.
+func encodeTestAnimatedWebP(t *testing.T, frames []image.Image, delayMS int) []byte {
+	t.Helper()
+	delays := make([]int, len(frames))
+	for i := range delays {
+		delays[i] = delayMS
+	}
+	var buf bytes.Buffer
+	require.NoError(t, webp.EncodeAll(&buf, &webp.WEBP{Image: frames, Delay: delays}, webp.Options{Lossless: true}))
+	return buf.Bytes()
+}
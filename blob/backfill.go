@@ -0,0 +1,249 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultBackfillPageSize is how many records one page of the backfill's scan
+// reads.
+const defaultBackfillPageSize = 100
+
+// RenditionBackfill brings READY image originals' rendition manifests up to the
+// current ladder. A rendition's id is a pure function of its original and its
+// output spec, so changing imageRenditionSpecs leaves every original finalized
+// before the change with a manifest that no longer matches what
+// planImageRenditions yields for it — as does enabling AVIF renditions (see
+// WithBackfillAVIFEncoder). The backfill walks every READY original
+// (see Store.ScanReadyOriginals), derives the rungs its manifest lacks from the
+// origin bytes, and re-attaches a manifest of exactly the current plan. Rungs it
+// already holds are kept as they are; renditions that fell out of the ladder are
+// dropped from the manifest, though their records and bytes stay until the
+// original is collected.
+//
+// It is idempotent — an up-to-date original costs only its read — so it is
+// resumed from the cursor of an interrupted run, or simply run again. Rebuilds
+// are paced (see WithBackfillRate) so a full pass does not compete with live
+// finalization for the origin store. A video's poster renditions are derived
+// from its frames rather than its origin bytes, so videos are left to
+// finalization.
+//
+// In dry-run mode it reports what it would rebuild, but reads no origin bytes
+// and writes nothing.
+type RenditionBackfill struct {
+	log       *zap.Logger
+	blobs     Store
+	storage   ObjectStorage
+	finalizer *Finalizer
+
+	pageSize int
+	interval time.Duration
+	dryRun   bool
+	avif     AVIFEncoder
+}
+
+// BackfillOption overrides one of the backfill's knobs.
+type BackfillOption func(*RenditionBackfill)
+
+// WithBackfillPageSize overrides how many records one page of the scan reads.
+func WithBackfillPageSize(n int) BackfillOption {
+	return func(b *RenditionBackfill) { b.pageSize = n }
+}
+
+// WithBackfillRate caps how many originals the backfill rebuilds per second.
+// Originals already up to date are not paced. By default it is unpaced.
+func WithBackfillRate(perSecond int) BackfillOption {
+	return func(b *RenditionBackfill) { b.interval = time.Second / time.Duration(perSecond) }
+}
+
+// WithBackfillDryRun makes the backfill report what it would rebuild without
+// reading origin bytes or writing anything.
+func WithBackfillDryRun() BackfillOption {
+	return func(b *RenditionBackfill) { b.dryRun = true }
+}
+
+// WithBackfillAVIFEncoder plans and derives AVIF renditions, as
+// WithAVIFEncoder does for finalization. The backfill must be configured like
+// the finalizer: without it, the AVIF renditions finalization attached fall
+// outside the plan and are dropped from the manifests.
+func WithBackfillAVIFEncoder(avif AVIFEncoder) BackfillOption {
+	return func(b *RenditionBackfill) { b.avif = avif }
+}
+
+// NewRenditionBackfill returns a RenditionBackfill over the given blob store and
+// object storage.
+func NewRenditionBackfill(log *zap.Logger, blobs Store, storage ObjectStorage, opts ...BackfillOption) *RenditionBackfill {
+	b := &RenditionBackfill{
+		log:     log,
+		blobs:   blobs,
+		storage: storage,
+
+		pageSize: defaultBackfillPageSize,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	var finalizerOpts []FinalizerOption
+	if b.avif != nil {
+		finalizerOpts = append(finalizerOpts, WithAVIFEncoder(b.avif))
+	}
+	b.finalizer = NewFinalizer(log, blobs, storage, nil, finalizerOpts...)
+	return b
+}
+
+// BackfillReport tallies a backfill run.
+type BackfillReport struct {
+	// Scanned is how many READY originals were visited.
+	Scanned int
+
+	// Stale is how many of them had a manifest that differs from the current
+	// plan.
+	Stale int
+
+	// Rebuilt is how many stale originals were brought up to date; in dry-run
+	// mode it stays zero.
+	Rebuilt int
+
+	// Renditions is how many renditions were derived for the rebuilt originals
+	// or, in dry-run mode, would be for the stale ones.
+	Renditions int
+
+	// Failed is how many stale originals could not be rebuilt. They were
+	// logged and passed over; running the backfill again retries them.
+	Failed int
+
+	// Cursor is where to resume the scan: empty once it completed, otherwise the
+	// start of the page it was interrupted in.
+	Cursor string
+}
+
+// Run scans from cursor — empty to start from the beginning — until the scan
+// completes or ctx is cancelled, rebuilding every stale image original it
+// finds, and returns its tally. A cancelled run returns ctx's error alongside
+// the report, whose cursor resumes it; the page it was interrupted in is
+// repeated, harmlessly.
+func (b *RenditionBackfill) Run(ctx context.Context, cursor string) (*BackfillReport, error) {
+	report := &BackfillReport{Cursor: cursor}
+	var nextRebuild time.Time
+	for {
+		page, next, err := b.blobs.ScanReadyOriginals(ctx, report.Cursor, b.pageSize)
+		if err != nil {
+			return report, err
+		}
+
+		for _, record := range page {
+			report.Scanned++
+			if record.ContentKind() != ContentKindImage || record.Image == nil {
+				continue
+			}
+			plans, missing := b.plan(record)
+			if missing == 0 && len(record.Renditions) == len(plans) {
+				continue
+			}
+			report.Stale++
+
+			log := b.log.With(
+				zap.String("blob_id", IDString(record.ID)),
+				zap.Int("missing", missing),
+				zap.Int("manifest", len(record.Renditions)),
+				zap.Int("planned", len(plans)),
+			)
+			if b.dryRun {
+				log.Info("Would rebuild rendition manifest (dry run)")
+				report.Renditions += missing
+				continue
+			}
+
+			if wait := time.Until(nextRebuild); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return report, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+			nextRebuild = time.Now().Add(b.interval)
+
+			if err := b.rebuild(ctx, record, plans); err != nil {
+				if ctx.Err() != nil {
+					return report, ctx.Err()
+				}
+				log.Warn("Failed to rebuild rendition manifest", zap.Error(err))
+				report.Failed++
+				continue
+			}
+			log.Info("Rebuilt rendition manifest")
+			report.Rebuilt++
+			report.Renditions += missing
+		}
+
+		report.Cursor = next
+		if next == "" {
+			return report, nil
+		}
+		b.log.Debug("Backfill page done", zap.String("cursor", next), zap.Int("scanned", report.Scanned))
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+	}
+}
+
+// plan resolves the original's current ladder and counts the rungs its manifest
+// lacks.
+func (b *RenditionBackfill) plan(record *Blob) ([]imageRenditionPlan, int) {
+	have := make(map[string]bool, len(record.Renditions))
+	for _, ref := range record.Renditions {
+		have[IDString(ref.ID)] = true
+	}
+
+	plans := planImageRenditions(record.Image, b.avif != nil)
+	var missing int
+	for _, plan := range plans {
+		if !have[IDString(plan.id(record.ID))] {
+			missing++
+		}
+	}
+	return plans, missing
+}
+
+// rebuild derives the rungs the original's manifest lacks from its origin bytes,
+// one at a time, then re-attaches a manifest of exactly the planned rungs.
+func (b *RenditionBackfill) rebuild(ctx context.Context, record *Blob, plans []imageRenditionPlan) error {
+	existing := make(map[string]RenditionRef, len(record.Renditions))
+	for _, ref := range record.Renditions {
+		existing[IDString(ref.ID)] = ref
+	}
+
+	var inspection *ImageInspection
+	refs := make([]RenditionRef, len(plans))
+	for i, plan := range plans {
+		if ref, ok := existing[IDString(plan.id(record.ID))]; ok {
+			refs[i] = ref
+			continue
+		}
+
+		if inspection == nil {
+			data, err := b.storage.GetOrigin(ctx, record.StorageKey)
+			if errors.Is(err, ErrObjectNotFound) {
+				return errors.New("origin bytes not found")
+			} else if err != nil {
+				return err
+			}
+			if inspection, err = InspectImage(data); err != nil {
+				return err
+			}
+		}
+
+		// The persisted metadata, not the re-inspection's, keeps the derivation
+		// consistent with the renditions finalization already derived.
+		child, err := b.finalizer.generateImageRendition(ctx, record, inspection.Decoded, record.Image, plan)
+		if err != nil {
+			return err
+		}
+		refs[i] = renditionRef(child)
+	}
+	return b.blobs.AttachRenditions(ctx, record.ID, refs)
+}
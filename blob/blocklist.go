@@ -0,0 +1,72 @@
+package blob
+
+import (
+	"context"
+	"time"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+// blocklistMaxDistance is how many bits apart, under each of its hashes, an
+// image may be from a blocked one and still match it. Re-encoding, resizing,
+// and recompression typically move a hash by a handful of bits, while unrelated
+// images sit around half the hash width (32 bits) apart.
+const blocklistMaxDistance = 10
+
+// BlockedHash is an entry on the hash blocklist: the perceptual hash of an image
+// staff removed, which finalization rejects near-duplicates of (see
+// WithHashBlocklist).
+type BlockedHash struct {
+	Hash PerceptualHash
+
+	// SourceBlobID is the removed blob the hash was taken from, if any.
+	SourceBlobID *blobpb.BlobId
+
+	// AddedBy is the staff member who blocked the hash.
+	AddedBy *commonpb.UserId
+
+	CreatedAt time.Time
+}
+
+// HashBlocklist persists the perceptual hashes of known-abusive images. It is
+// curated by staff, so it stays small enough to be listed whole and matched in
+// process: Hamming-distance matching does not reduce to a key lookup.
+type HashBlocklist interface {
+	// Add blocks entry's hash. Adding a hash that is already blocked is a no-op
+	// that keeps the existing entry.
+	Add(ctx context.Context, entry *BlockedHash) error
+
+	// Remove unblocks a hash.
+	//
+	// ErrNotFound is returned if the hash is not blocked.
+	Remove(ctx context.Context, hash PerceptualHash) error
+
+	// List returns every blocked hash, in no particular order.
+	List(ctx context.Context) ([]*BlockedHash, error)
+}
+
+// MatchBlocklist returns the first entry hash is a near-duplicate of, or nil.
+func MatchBlocklist(entries []*BlockedHash, hash PerceptualHash) *BlockedHash {
+	for _, entry := range entries {
+		if hash.Within(entry.Hash, blocklistMaxDistance) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the entry.
+func (b *BlockedHash) Clone() *BlockedHash {
+	if b == nil {
+		return nil
+	}
+	cloned := &BlockedHash{Hash: b.Hash, CreatedAt: b.CreatedAt}
+	if b.SourceBlobID != nil {
+		cloned.SourceBlobID = &blobpb.BlobId{Value: append([]byte(nil), b.SourceBlobID.Value...)}
+	}
+	if b.AddedBy != nil {
+		cloned.AddedBy = &commonpb.UserId{Value: append([]byte(nil), b.AddedBy.Value...)}
+	}
+	return cloned
+}
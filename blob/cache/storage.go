@@ -22,9 +22,10 @@ import (
 // reader is byte-identical to the one every other reader would get. The bytes behind
 // a key are immutable too, so a cached URL can never point at stale content.
 //
-// Upload targets are deliberately NOT cached: a presigned upload is a bearer
-// credential minted per reservation, pinned to that upload's key, MIME type and exact
-// size, and reusing one across uploads would be wrong.
+// Upload targets (multipart part targets included) are deliberately NOT cached: a
+// presigned upload is a bearer credential minted per reservation, pinned to that
+// upload's key, MIME type and exact size, and reusing one across uploads would be
+// wrong.
 type StorageCache struct {
 	storage blob.ObjectStorage
 	urls    *ttlcache.Cache
@@ -90,6 +91,22 @@ func (c *StorageCache) PresignUpload(ctx context.Context, key, mimeType string,
 	return c.storage.PresignUpload(ctx, key, mimeType, sizeBytes)
 }
 
+func (c *StorageCache) CreateMultipartUpload(ctx context.Context, key, mimeType string) (string, error) {
+	return c.storage.CreateMultipartUpload(ctx, key, mimeType)
+}
+
+func (c *StorageCache) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int, sizeBytes uint64) (*blobpb.UploadTarget, error) {
+	return c.storage.PresignUploadPart(ctx, key, uploadID, partNumber, sizeBytes)
+}
+
+func (c *StorageCache) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts int) error {
+	return c.storage.CompleteMultipartUpload(ctx, key, uploadID, parts)
+}
+
+func (c *StorageCache) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	return c.storage.AbortMultipartUpload(ctx, key, uploadID)
+}
+
 func (c *StorageCache) GetUploaded(ctx context.Context, key string) ([]byte, error) {
 	return c.storage.GetUploaded(ctx, key)
 }
@@ -102,6 +119,14 @@ func (c *StorageCache) CopyToOrigin(ctx context.Context, key string) error {
 	return c.storage.CopyToOrigin(ctx, key)
 }
 
+func (c *StorageCache) GetOrigin(ctx context.Context, key string) ([]byte, error) {
+	return c.storage.GetOrigin(ctx, key)
+}
+
+func (c *StorageCache) CopyOrigin(ctx context.Context, srcKey, dstKey string) error {
+	return c.storage.CopyOrigin(ctx, srcKey, dstKey)
+}
+
 func (c *StorageCache) PutOrigin(ctx context.Context, key, mimeType string, data []byte) error {
 	return c.storage.PutOrigin(ctx, key, mimeType, data)
 }
@@ -110,4 +135,15 @@ func (c *StorageCache) DeleteUpload(ctx context.Context, key string) error {
 	return c.storage.DeleteUpload(ctx, key)
 }
 
+func (c *StorageCache) DeletePrefix(ctx context.Context, prefix string) error {
+	return c.storage.DeletePrefix(ctx, prefix)
+}
+
+// InvalidateCDN passes straight through. Cached download URLs are left in place:
+// one is only ever handed to a reader GetBlobs has just authorized, and it could
+// not be recalled from a reader who already holds it anyway.
+func (c *StorageCache) InvalidateCDN(ctx context.Context, prefix string) error {
+	return c.storage.InvalidateCDN(ctx, prefix)
+}
+
 var _ blob.ObjectStorage = (*StorageCache)(nil)
@@ -7,12 +7,13 @@ import (
 	"github.com/ReneKroon/ttlcache"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 
 	"github.com/code-payments/flipcash2-server/blob"
 )
 
 // Cache wraps a blob.Store, caching only blobs that have reached a terminal
-// state (Ready or Rejected).
+// state (Ready, Rejected, or Deleted).
 type Cache struct {
 	db    blob.Store
 	blobs *ttlcache.Cache
@@ -79,18 +80,49 @@ func (c *Cache) GetByIDs(ctx context.Context, ids []*blobpb.BlobId) ([]*blob.Blo
 	return out, nil
 }
 
+// AttachRenditions evicts the blob, since a rendition backfill re-attaches the
+// manifest of an original that is already READY (and so may be cached). Other
+// instances serve the old manifest until evicted; its renditions still exist.
 func (c *Cache) AttachRenditions(ctx context.Context, id *blobpb.BlobId, refs []blob.RenditionRef) error {
-	return c.db.AttachRenditions(ctx, id, refs)
+	if err := c.db.AttachRenditions(ctx, id, refs); err != nil {
+		return err
+	}
+	c.blobs.Remove(string(id.Value))
+	return nil
 }
 
-func (c *Cache) Advance(ctx context.Context, id *blobpb.BlobId, to blob.State, image *blob.ImageMetadata) (bool, error) {
-	return c.db.Advance(ctx, id, to, image)
+func (c *Cache) Advance(ctx context.Context, id *blobpb.BlobId, to blob.State, meta *blob.DerivedMetadata) (bool, error) {
+	return c.db.Advance(ctx, id, to, meta)
 }
 
 func (c *Cache) Reject(ctx context.Context, id *blobpb.BlobId, rejection *blob.RejectionMetadata) (bool, error) {
 	return c.db.Reject(ctx, id, rejection)
 }
 
+// Reinstate evicts the blob, since the rejected record it moves out of is
+// terminal (and so may be cached). Other instances serve the rejection until
+// evicted.
+func (c *Cache) Reinstate(ctx context.Context, id *blobpb.BlobId) (bool, error) {
+	reinstated, err := c.db.Reinstate(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	c.blobs.Remove(string(id.Value))
+	return reinstated, nil
+}
+
+// TakeDown evicts the blob, since the READY record it moves out of is terminal
+// (and so may be cached). Other instances serve it as READY until evicted; its
+// download URLs then fail once its objects are deleted.
+func (c *Cache) TakeDown(ctx context.Context, id *blobpb.BlobId) (bool, error) {
+	takenDown, err := c.db.TakeDown(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	c.blobs.Remove(string(id.Value))
+	return takenDown, nil
+}
+
 func (c *Cache) MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind blob.ContentKind, nextAttemptAt time.Time) error {
 	return c.db.MarkForFinalization(ctx, id, kind, nextAttemptAt)
 }
@@ -110,3 +142,54 @@ func (c *Cache) ClaimForFinalization(ctx context.Context, id *blobpb.BlobId, asO
 func (c *Cache) DelayFinalization(ctx context.Context, id *blobpb.BlobId, nextAttemptAt time.Time) error {
 	return c.db.DelayFinalization(ctx, id, nextAttemptAt)
 }
+
+func (c *Cache) DeadLetterFinalization(ctx context.Context, id *blobpb.BlobId, kind blob.ContentKind, attempts uint32, lastError string, at time.Time) error {
+	return c.db.DeadLetterFinalization(ctx, id, kind, attempts, lastError, at)
+}
+
+func (c *Cache) GetDeadLetters(ctx context.Context, kind blob.ContentKind, limit int) ([]*blob.DeadLetter, error) {
+	return c.db.GetDeadLetters(ctx, kind, limit)
+}
+
+func (c *Cache) MarkForCollection(ctx context.Context, id *blobpb.BlobId, since time.Time) error {
+	return c.db.MarkForCollection(ctx, id, since)
+}
+
+func (c *Cache) GetDueForCollection(ctx context.Context, asOf time.Time, limit int) ([]*blobpb.BlobId, error) {
+	return c.db.GetDueForCollection(ctx, asOf, limit)
+}
+
+func (c *Cache) ScanReadyOriginals(ctx context.Context, cursor string, limit int) ([]*blob.Blob, string, error) {
+	return c.db.ScanReadyOriginals(ctx, cursor, limit)
+}
+
+func (c *Cache) GetOriginalsByOwner(ctx context.Context, owner *commonpb.UserId, cursor string, limit int) ([]*blob.Blob, string, error) {
+	return c.db.GetOriginalsByOwner(ctx, owner, cursor, limit)
+}
+
+// Tombstone evicts the blob once it is collected, so this instance stops
+// serving the terminal record it cached before collection. Other instances keep
+// theirs until evicted; its download URLs then fail like any missing object's.
+func (c *Cache) Tombstone(ctx context.Context, id *blobpb.BlobId, from blob.State) (bool, error) {
+	tombstoned, err := c.db.Tombstone(ctx, id, from)
+	if err == nil && tombstoned {
+		c.blobs.Remove(string(id.Value))
+	}
+	return tombstoned, err
+}
+
+// SetContentHash evicts nothing: only terminal records are cached, and the hash
+// is recorded before a blob reaches a terminal state.
+func (c *Cache) SetContentHash(ctx context.Context, id *blobpb.BlobId, hash []byte) error {
+	return c.db.SetContentHash(ctx, id, hash)
+}
+
+func (c *Cache) GetByContentHash(ctx context.Context, hash []byte, limit int) ([]*blob.Blob, error) {
+	return c.db.GetByContentHash(ctx, hash, limit)
+}
+
+// GetUsage is never cached: it changes with every reservation, and a quota
+// check against a stale total would let an owner overshoot it.
+func (c *Cache) GetUsage(ctx context.Context, owner *commonpb.UserId, day time.Time) (*blob.Usage, error) {
+	return c.db.GetUsage(ctx, owner, day)
+}
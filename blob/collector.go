@@ -0,0 +1,252 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+
+	"github.com/code-payments/flipcash2-server/poll"
+
+	"github.com/code-payments/ocp-server/metrics"
+	"github.com/code-payments/ocp-server/metrics/noop"
+)
+
+const (
+	// defaultCollectorBatchSize is how many candidates one tick pulls from the
+	// collection index.
+	defaultCollectorBatchSize = 100
+
+	// defaultCollectorGracePeriod is how long a blob sits in the collection index
+	// before it is judged: long enough for a client to finish an upload, attach a
+	// READY blob to a message or profile, or read a rejection's reason. It must
+	// stay well under the DynamoDB store's record TTL (7 days), which reclaims
+	// abandoned and rejected records on its own — and with them the index entry
+	// that would have led the collector to their objects.
+	defaultCollectorGracePeriod = 24 * time.Hour
+
+	// defaultCollectorRecheckInterval is how long a still-referenced original is
+	// left alone before it is judged again.
+	defaultCollectorRecheckInterval = 7 * 24 * time.Hour
+)
+
+// Collector garbage-collects blobs that will never be served: reservations
+// whose upload was never completed, rejected uploads, and READY originals that
+// nothing references any more. It walks the store's collection index (see
+// Store), and for each candidate whose grace period has elapsed deletes every
+// object under the media item's storage prefix — the original and all of its
+// renditions, from both the upload and origin stores — then tombstones the
+// records (StateDeleted).
+//
+// The reference graph is the AccessStore: a message attachment is granted to
+// its chat and a profile picture to its profile, so a READY original with no
+// grant at all is referenced by nothing. The owner's implicit access is not a
+// reference. A referenced original is rechecked every recheck interval, so it is
+// collected once its last grant is revoked. A grant racing the collector's
+// judgement is not guarded against: by then the blob has gone unattached for the
+// whole grace period.
+//
+// It is safe to run on every server instance: object deletion is idempotent,
+// and a tombstone only lands on a record still in the state it was judged in.
+//
+// In dry-run mode it judges candidates and logs what it would collect, but
+// deletes and writes nothing.
+type Collector struct {
+	log     *zap.Logger
+	blobs   Store
+	access  AccessStore
+	storage ObjectStorage
+
+	batchSize       int
+	gracePeriod     time.Duration
+	recheckInterval time.Duration
+	dryRun          bool
+}
+
+// CollectorOption overrides one of the collector's knobs.
+type CollectorOption func(*Collector)
+
+// WithCollectorBatchSize overrides how many candidates one tick pulls from the
+// collection index.
+func WithCollectorBatchSize(n int) CollectorOption {
+	return func(c *Collector) { c.batchSize = n }
+}
+
+// WithCollectorGracePeriod overrides how long a blob sits in the collection
+// index before it is judged.
+func WithCollectorGracePeriod(grace time.Duration) CollectorOption {
+	return func(c *Collector) { c.gracePeriod = grace }
+}
+
+// WithCollectorRecheckInterval overrides how long a still-referenced original
+// is left alone before it is judged again.
+func WithCollectorRecheckInterval(interval time.Duration) CollectorOption {
+	return func(c *Collector) { c.recheckInterval = interval }
+}
+
+// WithCollectorDryRun makes the collector log what it would collect without
+// deleting or writing anything.
+func WithCollectorDryRun() CollectorOption {
+	return func(c *Collector) { c.dryRun = true }
+}
+
+// NewCollector returns a Collector over the given blob store, access store, and
+// object storage.
+func NewCollector(log *zap.Logger, blobs Store, access AccessStore, storage ObjectStorage, opts ...CollectorOption) *Collector {
+	c := &Collector{
+		log:     log,
+		blobs:   blobs,
+		access:  access,
+		storage: storage,
+
+		batchSize:       defaultCollectorBatchSize,
+		gracePeriod:     defaultCollectorGracePeriod,
+		recheckInterval: defaultCollectorRecheckInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Start satisfies the OCP worker.Runtime interface: it runs a collection tick
+// every interval until ctx is cancelled (see poll.Loop), whose error it
+// returns. A full batch is not polled again immediately in dry-run mode, where
+// nothing leaves the index and the same batch would come straight back.
+func (c *Collector) Start(ctx context.Context, interval time.Duration) error {
+	return poll.Loop(ctx, interval, func(ctx context.Context) bool {
+		collected, err := c.Process(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			c.log.Warn("Failed to process collection index", zap.Error(err))
+		}
+		return collected == c.batchSize && !c.dryRun
+	})
+}
+
+// Process runs one collection tick: it judges every candidate whose grace
+// period has elapsed and reports how many it handled — collected, deferred, or
+// (in dry-run mode) judged. Zero means nothing is due.
+func (c *Collector) Process(runtimeCtx context.Context) (int, error) {
+	metricsProvider, ok := runtimeCtx.Value(metrics.ProviderContextKey).(metrics.Provider)
+	if !ok || metricsProvider == nil {
+		metricsProvider = noop.NewProvider()
+	}
+	trace := metricsProvider.StartTrace("blob_gc_worker")
+	defer trace.End()
+	tracedCtx := metrics.NewContext(runtimeCtx, trace)
+
+	now := time.Now()
+	due, err := c.blobs.GetDueForCollection(tracedCtx, now.Add(-c.gracePeriod), c.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var handled int
+	for _, id := range due {
+		if err := c.collectOne(tracedCtx, now, id); err != nil {
+			// The entry stays due, so the next tick retries it.
+			c.log.Warn("Failed to collect blob", zap.String("blob_id", IDString(id)), zap.Error(err))
+			continue
+		}
+		handled++
+	}
+	return handled, nil
+}
+
+// collectOne judges a single due candidate and either collects it or pushes
+// its next judgement out.
+func (c *Collector) collectOne(ctx context.Context, now time.Time, id *blobpb.BlobId) error {
+	record, err := c.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		// Reclaimed (TTL) between the poll and now; the index entry went with it.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	log := c.log.With(
+		zap.String("blob_id", IDString(id)),
+		zap.Int("state", int(record.State)),
+	)
+
+	switch record.State {
+	case StatePending, StateRejected:
+		// Never uploaded, or never servable: nothing can reference it.
+	case StateReady:
+		referenced, err := c.access.HasAnyGrant(ctx, id)
+		if err != nil {
+			return err
+		}
+		if referenced {
+			return c.postpone(ctx, id, now.Add(c.recheckInterval))
+		}
+	case StateDeleted:
+		// Already collected; a tombstone is never indexed.
+		return nil
+	default:
+		// Still being finalized. Finalization restamps the blob when it settles,
+		// so look again a grace period from now.
+		return c.postpone(ctx, id, now)
+	}
+
+	if record.ParentID != nil {
+		// Renditions are never indexed; they go with their original.
+		return fmt.Errorf("unexpected rendition %s in collection index", IDString(id))
+	}
+	prefix := storageItemPrefix(record.StorageKey)
+	if prefix == "" {
+		return fmt.Errorf("storage key %q has no item prefix", record.StorageKey)
+	}
+
+	if c.dryRun {
+		log.Info("Would collect blob (dry run)",
+			zap.String("prefix", prefix),
+			zap.Int("renditions", len(record.Renditions)),
+		)
+		return nil
+	}
+
+	// Objects go first: a crash after the deletion leaves the record indexed, so
+	// the next tick repeats the (idempotent) deletions and then tombstones it. The
+	// parts of a multipart upload that was never completed are not objects under
+	// the prefix, so they are discarded on their own.
+	if record.Multipart != nil && record.State == StatePending {
+		if err := c.storage.AbortMultipartUpload(ctx, record.StorageKey, record.Multipart.UploadID); err != nil {
+			return err
+		}
+	}
+	if err := c.storage.DeletePrefix(ctx, prefix); err != nil {
+		return err
+	}
+	// Tombstone the renditions before their original, whose record is the only
+	// path back to them.
+	for _, ref := range record.Renditions {
+		if _, err := c.blobs.Tombstone(ctx, ref.ID, StateReady); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	tombstoned, err := c.blobs.Tombstone(ctx, id, record.State)
+	if err != nil {
+		return err
+	}
+	if !tombstoned {
+		// It moved on after it was judged — e.g. a pending upload completed at the
+		// last moment — with its objects already gone, so finalization will find
+		// no bytes and reject it.
+		log.Warn("Blob changed state while being collected")
+		return nil
+	}
+	log.Info("Collected blob", zap.String("prefix", prefix))
+	return nil
+}
+
+// postpone pushes a candidate's next judgement out to a grace period after since.
+func (c *Collector) postpone(ctx context.Context, id *blobpb.BlobId, since time.Time) error {
+	if c.dryRun {
+		return nil
+	}
+	return c.blobs.MarkForCollection(ctx, id, since)
+}
@@ -19,7 +19,8 @@ import (
 // sk = "<effect>#<perm>#<ptype>#<principal id hex>" (the effect a principal has
 // on a permission). An entry's existence is the authorization, so the item
 // carries no other attributes and the table has no secondary indexes. Reads are
-// exact-key point gets, so the store performs no membership resolution and never
+// exact-key point gets (or, for HasAnyGrant, a one-item query of the blob's
+// partition), so the store performs no membership resolution and never
 // depends on the chat subsystem. Entries are durable: unlike pending blobs they
 // carry no TTL and persist until explicitly revoked.
 //
@@ -80,6 +81,27 @@ func (s *accessStore) HasGrant(ctx context.Context, blobID *blobpb.BlobId, p blo
 	return len(out.Item) > 0, nil
 }
 
+func (s *accessStore) HasAnyGrant(ctx context.Context, blobID *blobpb.BlobId) (bool, error) {
+	if blobID == nil || len(blobID.Value) == 0 {
+		return false, fmt.Errorf("%w: missing blob id", blob.ErrInvalidGrant)
+	}
+
+	// Every entry on the blob shares its partition, so one single-item page
+	// answers whether there are any.
+	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.table),
+		KeyConditionExpression:    aws.String("#pk = :pk"),
+		ExpressionAttributeNames:  map[string]string{"#pk": attrPK},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": avS(blobPK(blobID))},
+		ProjectionExpression:      aws.String("#pk"),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(out.Items) > 0, nil
+}
+
 func (s *accessStore) Revoke(ctx context.Context, blobID *blobpb.BlobId, p blob.Principal, perm blob.Permission) error {
 	if err := (&blob.Grant{BlobID: blobID, Principal: p, Permission: perm}).Validate(); err != nil {
 		return err
@@ -14,7 +14,7 @@ import (
 const aclTable = "blob_acls_test"
 
 func TestBlobAccess_DynamoDBStore(t *testing.T) {
-	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable))
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
 
 	testStore := NewAccessInDynamoDB(testEnv.Client, aclTable)
 	teardown := func() {
@@ -0,0 +1,26 @@
+//go:build integration
+
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	blob_memory "github.com/code-payments/flipcash2-server/blob/memory"
+	"github.com/code-payments/flipcash2-server/blob/tests"
+)
+
+func TestBlob_DynamoDBBackfill(t *testing.T) {
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
+
+	blobs := NewInDynamoDB(testEnv.Client, blobsTable)
+	// As in the worker runner, only the metadata store — and its scan — is
+	// exercised against DynamoDB; the object storage is the in-memory fake.
+	storage := blob_memory.NewInMemoryStorage()
+	teardown := func() {
+		blobs.(*store).reset()
+	}
+	tests.RunBackfillTests(t, blobs, storage, storage.PutObject, teardown)
+}
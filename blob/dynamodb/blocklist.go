@@ -0,0 +1,159 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+// The hash blocklist uses its own table, with every entry in a single partition
+// (pk = blocklistPK) keyed by sk = the hash's String form. Matching is by
+// Hamming distance, which no key lookup answers, so the blocklist is always read
+// whole — one paginated Query of that partition. It is staff-curated and small,
+// so the partition stays far below the point where it would need sharding.
+const (
+	blocklistPK = "blocklist"
+
+	attrSourceBlobID = "source_blob_id" // S, hex; absent when the hash was not taken from a blob
+	attrAddedBy      = "added_by"       // S, staff member's user id, hex
+)
+
+type blocklistStore struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// NewHashBlocklistInDynamoDB returns a blob.HashBlocklist backed by the given
+// DynamoDB blocklist table. Use CreateTables to provision it.
+func NewHashBlocklistInDynamoDB(client *dynamodb.Client, table string) blob.HashBlocklist {
+	return &blocklistStore{
+		client: client,
+		table:  table,
+	}
+}
+
+func (s *blocklistStore) Add(ctx context.Context, entry *blob.BlockedHash) error {
+	item := map[string]types.AttributeValue{
+		attrPK:        avS(blocklistPK),
+		attrSK:        avS(entry.Hash.String()),
+		attrCreatedAt: avUnixNanos(entry.CreatedAt),
+	}
+	if entry.SourceBlobID != nil {
+		item[attrSourceBlobID] = avS(hex.EncodeToString(entry.SourceBlobID.Value))
+	}
+	if entry.AddedBy != nil {
+		item[attrAddedBy] = avS(hex.EncodeToString(entry.AddedBy.Value))
+	}
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+		// The first entry for a hash is kept, so a re-block does not rewrite who
+		// blocked it and when.
+		ConditionExpression: aws.String(fmt.Sprintf("attribute_not_exists(%s)", attrPK)),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *blocklistStore) Remove(ctx context.Context, hash blob.PerceptualHash) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]types.AttributeValue{
+			attrPK: avS(blocklistPK),
+			attrSK: avS(hash.String()),
+		},
+		ConditionExpression: aws.String(fmt.Sprintf("attribute_exists(%s)", attrPK)),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return blob.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *blocklistStore) List(ctx context.Context) ([]*blob.BlockedHash, error) {
+	var entries []*blob.BlockedHash
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(s.table),
+			KeyConditionExpression:    aws.String("#pk = :pk"),
+			ExpressionAttributeNames:  map[string]string{"#pk": attrPK},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": avS(blocklistPK)},
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			entry, err := blockedHashFromItem(item)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+func (s *blocklistStore) reset() {
+	if err := clearTable(context.Background(), s.client, s.table, []string{attrPK, attrSK}); err != nil {
+		panic(err)
+	}
+}
+
+func blockedHashFromItem(item map[string]types.AttributeValue) (*blob.BlockedHash, error) {
+	hash, err := blob.ParsePerceptualHash(stringAttr(item, attrSK))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s attribute: %w", attrSK, err)
+	}
+	createdAt, err := uint64Attr(item, attrCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &blob.BlockedHash{
+		Hash:      hash,
+		CreatedAt: time.Unix(0, int64(createdAt)),
+	}
+	if _, ok := item[attrSourceBlobID]; ok {
+		id, err := hexAttr(item, attrSourceBlobID)
+		if err != nil {
+			return nil, err
+		}
+		entry.SourceBlobID = &blobpb.BlobId{Value: id}
+	}
+	if _, ok := item[attrAddedBy]; ok {
+		id, err := hexAttr(item, attrAddedBy)
+		if err != nil {
+			return nil, err
+		}
+		entry.AddedBy = &commonpb.UserId{Value: id}
+	}
+	return entry, nil
+}
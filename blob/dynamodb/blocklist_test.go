@@ -0,0 +1,24 @@
+//go:build integration
+
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/blob/tests"
+)
+
+const blocklistTable = "blob_hash_blocklist_test"
+
+func TestBlobHashBlocklist_DynamoDBStore(t *testing.T) {
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
+
+	testStore := NewHashBlocklistInDynamoDB(testEnv.Client, blocklistTable)
+	teardown := func() {
+		testStore.(*blocklistStore).reset()
+	}
+	tests.RunHashBlocklistTests(t, testStore, teardown)
+}
@@ -0,0 +1,28 @@
+//go:build integration
+
+package dynamodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	blob_memory "github.com/code-payments/flipcash2-server/blob/memory"
+	"github.com/code-payments/flipcash2-server/blob/tests"
+)
+
+func TestBlob_DynamoDBCollector(t *testing.T) {
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
+
+	blobs := NewInDynamoDB(testEnv.Client, blobsTable)
+	access := NewAccessInDynamoDB(testEnv.Client, aclTable)
+	// As in the worker suite, only the stores — including the collection GSI
+	// the collector polls — are exercised against DynamoDB.
+	storage := blob_memory.NewInMemoryStorage()
+	teardown := func() {
+		blobs.(*store).reset()
+		access.(*accessStore).reset()
+	}
+	tests.RunCollectorTests(t, blobs, access, storage, storage.PutObject, teardown)
+}
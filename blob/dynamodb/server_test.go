@@ -14,7 +14,7 @@ import (
 )
 
 func TestBlob_DynamoDBServer(t *testing.T) {
-	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable))
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
 
 	accounts := account_memory.NewInMemory()
 	blobs := NewInDynamoDB(testEnv.Client, blobsTable)
@@ -1,6 +1,7 @@
 package dynamodb
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
@@ -38,15 +39,30 @@ const (
 	attrStorageKey    = "storage_key"     // S
 	attrMimeType      = "mime_type"       // S
 	attrSizeBytes     = "size_bytes"      // N
+	attrOriginSize    = "origin_size"     // N, present only when finalization rewrote the upload
+	attrMultipartID   = "multipart_id"    // S, storage's multipart upload id; present only on multipart ORIGINALs
+	attrMultipartPart = "multipart_part"  // N, multipart part size; present only on multipart ORIGINALs
+	attrPerceptual    = "perceptual_hash" // S, blob.PerceptualHash.String(); present only on inspected ORIGINALs
 	attrImageWidth    = "image_width"     // N, present only on READY images
 	attrImageHeight   = "image_height"    // N, present only on READY images
 	attrImageBlurhash = "image_blurhash"  // S, present only on READY images
 	attrImageHasAlpha = "image_has_alpha" // BOOL, present only on READY images
+	attrImageAnimated = "image_animated"  // BOOL, present only on READY images
+	attrImageFrames   = "image_frames"    // N, frame count; present only on READY images
+	attrVideoWidth    = "video_width"     // N, present only on inspected videos
+	attrVideoHeight   = "video_height"    // N, present only on inspected videos
+	attrVideoDuration = "video_duration"  // N, nanoseconds; present only on inspected videos
+	attrVideoCodec    = "video_codec"     // S, present only on inspected videos
+	attrVideoRotation = "video_rotation"  // N, degrees; present only on inspected videos
+	attrVideoBlurhash = "video_blurhash"  // S, present only on inspected videos
 	attrExpiresAt     = "expires_at"      // N, Unix seconds; TTL on non-READY blobs
 	attrCreatedAt     = "created_at"      // N, Unix nanos; stamped once at creation
 
 	attrRejectionReason = "rejection_reason" // N, present only on REJECTED blobs
 	attrFlaggedCategory = "flagged_category" // N, present only on REJECTED-by-moderation blobs
+	attrStripFailed     = "strip_failed"     // BOOL, present only when sanitizing a REJECTED blob failed
+
+	attrModerationBypassed = "moderation_bypassed" // BOOL, present only on blobs reinstated on appeal
 
 	attrRenditions = "renditions" // S (JSON manifest), present only on ORIGINALs with generated renditions
 
@@ -56,18 +72,75 @@ const (
 	// one index partition, see finalizeQueuePK — per content kind, each drained
 	// by its own worker. Reaching a terminal state removes the attributes in the
 	// same UpdateItem as the transition, dequeuing atomically.
-	attrFinalizeQueue      = "finalize_queue"      // S, finalizeQueuePK(kind) (GSI hash); present only while queued
-	attrFinalizeDueAt      = "finalize_due_at"     // N, Unix nanos (GSI range); when the task is next due
-	attrFinalizeAttempts   = "finalize_attempts"   // N, failed attempts so far
+	attrFinalizeQueue      = "finalize_queue"       // S, finalizeQueuePK(kind) (GSI hash); present only while queued
+	attrFinalizeDueAt      = "finalize_due_at"      // N, Unix nanos (GSI range); when the task is next due
+	attrFinalizeAttempts   = "finalize_attempts"    // N, failed attempts so far
 	attrFinalizeEnqueuedAt = "finalize_enqueued_at" // N, Unix nanos; set on first mark, never reset (backs the max-age gauge)
+	attrFinalizeLastError  = "finalize_last_error"  // S, the final attempt's error; present only while dead-lettered
 
 	// finalizationQueueIndex is the sparse GSI backing GetDueForFinalization.
 	finalizationQueueIndex = "finalization_queue"
 
+	// The collection index is a second sparse GSI, over every ORIGINAL from
+	// reservation until the garbage collector tombstones it. Renditions never
+	// carry the attributes, so they never appear in it.
+	attrCollectQueue = "collect_queue" // S, collectQueuePK (GSI hash); present only on uncollected originals
+	attrCollectSince = "collect_since" // N, Unix nanos (GSI range); when the collection grace period counts from
+
+	// collectionIndex is the sparse GSI backing GetDueForCollection.
+	collectionIndex = "collection_queue"
+
+	// collectQueuePK is the collection index's single partition value. Like a
+	// finalization queue it can be sharded additively ("collect#1..N-1") should
+	// it ever run hot.
+	collectQueuePK = "collect#0"
+
+	// The content-hash index is a third sparse GSI, over every ORIGINAL whose
+	// upload finalization has hashed, until the garbage collector tombstones it
+	// (unless it was rejected with a verdict on its content, which outlives it).
+	attrContentHash     = "content_hash"      // S, hex SHA-256 (GSI hash); present only on hashed originals
+	attrContentHashedAt = "content_hashed_at" // N, Unix nanos (GSI range); when the hash was first recorded
+
+	// contentHashIndex is the sparse GSI backing GetByContentHash.
+	contentHashIndex = "content_hash_index"
+
+	// The owner index is a fourth GSI, over every blob item, partitioned by
+	// owner (attrUserID) and sorted by creation (attrCreatedAt). It is not
+	// sparse: renditions and tombstones carry both keys too, so the query
+	// filters them out on the projected parent and state.
+	ownerIndex = "owner_index"
+
+	// Each owner's storage accounting lives in the same table, as counter items
+	// beside the blob items: one running total per owner, and one per owner per
+	// UTC day of reservations. The counters are moved by the same transaction as
+	// the blob transition they account for. A blob item carries attrUsageCharged
+	// once its size has been charged, so only a charged blob is refunded — a
+	// record reserved before accounting existed never drives a total negative.
+	attrUsageCharged = "usage_charged" // BOOL, present on blobs charged to their owner's usage
+	attrStoredBytes  = "stored_bytes"  // N, on an owner's total item
+	attrDailyBytes   = "daily_bytes"   // N, on an owner's daily item
+
+	usageKeyPrefix = "usage#"
+
+	// dailyUsageTTL is how long an owner's daily counter outlives the start of
+	// its day: long enough to be read for the whole day from any time zone, then
+	// reclaimed.
+	dailyUsageTTL = 2 * 24 * time.Hour
+
+	// codeConditionalCheckFailed is the cancellation reason code of a
+	// transaction item whose condition failed.
+	codeConditionalCheckFailed = "ConditionalCheckFailed"
+
 	// finalizeQueuePrefix prefixes a queue's partition value; the content kind's
 	// stable numeric value completes it (see finalizeQueuePK).
 	finalizeQueuePrefix = "queue#"
 
+	// deadLetterQueuePrefix prefixes a dead-letter list's partition value in the
+	// finalization queue index; the content kind's stable numeric value
+	// completes it (see deadLetterQueuePK). A dead letter's due time is the
+	// instant it was parked, so its list is longest-parked first.
+	deadLetterQueuePrefix = "deadletter#"
+
 	blobKeyPrefix = "blob#"
 
 	// batchGetMaxKeys is the DynamoDB BatchGetItem per-request key limit.
@@ -78,6 +151,10 @@ const (
 	// durable READY state, so only abandoned uploads (and rejected tombstones)
 	// ever expire; READY blobs persist.
 	pendingBlobTTL = 7 * 24 * time.Hour
+
+	// tombstoneTTL is how long a collected blob's tombstone lingers before
+	// DynamoDB reclaims it.
+	tombstoneTTL = 30 * 24 * time.Hour
 )
 
 type store struct {
@@ -101,15 +178,32 @@ func (s *store) CreatePending(ctx context.Context, b *blob.Blob) error {
 	// A never-completed reservation should not live forever; give the record a
 	// TTL that Advance clears once the blob reaches READY.
 	item[attrExpiresAt] = avUnix(now.Add(pendingBlobTTL))
+	// An original is indexed for collection from the moment it is reserved.
+	if b.ParentID == nil {
+		item[attrCollectQueue] = avS(collectQueuePK)
+		item[attrCollectSince] = avUnixNanos(now)
+	}
 
-	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+	// The reservation and its charge commit together: a replayed create fails
+	// the put's condition and so charges nothing.
+	transactItems := []types.TransactWriteItem{{Put: &types.Put{
 		TableName:           aws.String(s.table),
 		Item:                item,
 		ConditionExpression: aws.String(fmt.Sprintf("attribute_not_exists(%s)", attrPK)),
-	})
+	}}}
+	if b.Owner != nil {
+		item[attrUsageCharged] = avBool(true)
+		transactItems = append(transactItems, s.chargeStored(b.Owner, int64(b.SizeBytes)))
+		if b.ParentID == nil {
+			transactItems = append(transactItems, s.chargeDaily(b.Owner, now, b.SizeBytes))
+		}
+	}
+
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
 	if err != nil {
-		var ccf *types.ConditionalCheckFailedException
-		if errors.As(err, &ccf) {
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) > 0 &&
+			aws.ToString(tce.CancellationReasons[0].Code) == codeConditionalCheckFailed {
 			return blob.ErrExists
 		}
 		return err
@@ -230,7 +324,7 @@ func (s *store) AttachRenditions(ctx context.Context, id *blobpb.BlobId, refs []
 	return nil
 }
 
-func (s *store) Advance(ctx context.Context, id *blobpb.BlobId, to blob.State, image *blob.ImageMetadata) (bool, error) {
+func (s *store) Advance(ctx context.Context, id *blobpb.BlobId, to blob.State, meta *blob.DerivedMetadata) (bool, error) {
 	if to == blob.StateRejected {
 		return false, blob.ErrCannotAdvanceToRejected
 	}
@@ -243,18 +337,39 @@ func (s *store) Advance(ctx context.Context, id *blobpb.BlobId, to blob.State, i
 	}
 
 	update := "SET #state = :to"
-	if image != nil {
-		update += fmt.Sprintf(", %s = :w, %s = :h, %s = :b, %s = :a", attrImageWidth, attrImageHeight, attrImageBlurhash, attrImageHasAlpha)
-		values[":w"] = avInt(int(image.Width))
-		values[":h"] = avInt(int(image.Height))
-		values[":b"] = avS(image.Blurhash)
-		values[":a"] = avBool(image.HasAlpha)
+	if meta != nil && meta.Image != nil {
+		update += fmt.Sprintf(", %s = :w, %s = :h, %s = :b, %s = :a, %s = :an, %s = :fc",
+			attrImageWidth, attrImageHeight, attrImageBlurhash, attrImageHasAlpha, attrImageAnimated, attrImageFrames)
+		values[":w"] = avInt(int(meta.Image.Width))
+		values[":h"] = avInt(int(meta.Image.Height))
+		values[":b"] = avS(meta.Image.Blurhash)
+		values[":a"] = avBool(meta.Image.HasAlpha)
+		values[":an"] = avBool(meta.Image.Animated)
+		values[":fc"] = avInt(int(meta.Image.FrameCount))
+	}
+	if meta != nil && meta.Video != nil {
+		update += fmt.Sprintf(", %s = :vw, %s = :vh, %s = :vd, %s = :vc, %s = :vr, %s = :vb",
+			attrVideoWidth, attrVideoHeight, attrVideoDuration, attrVideoCodec, attrVideoRotation, attrVideoBlurhash)
+		values[":vw"] = avInt(int(meta.Video.Width))
+		values[":vh"] = avInt(int(meta.Video.Height))
+		values[":vd"] = avInt(int(meta.Video.Duration))
+		values[":vc"] = avS(meta.Video.Codec)
+		values[":vr"] = avInt(int(meta.Video.Rotation))
+		values[":vb"] = avS(meta.Video.Blurhash)
+	}
+	if meta != nil && meta.OriginSizeBytes != 0 {
+		update += fmt.Sprintf(", %s = :os", attrOriginSize)
+		values[":os"] = avUint64(meta.OriginSizeBytes)
+	}
+	if meta != nil && meta.PerceptualHash != nil {
+		update += fmt.Sprintf(", %s = :ph", attrPerceptual)
+		values[":ph"] = avS(meta.PerceptualHash.String())
 	}
 	// READY is the durable terminal state: clear the TTL so the blob is never
 	// reclaimed, and dequeue it from the finalization queue — the work is done.
 	// Non-terminal records keep the TTL and expire if they never reach READY.
 	if to == blob.StateReady {
-		update += fmt.Sprintf(" REMOVE %s, %s, %s, %s, %s", attrExpiresAt, attrFinalizeQueue, attrFinalizeDueAt, attrFinalizeAttempts, attrFinalizeEnqueuedAt)
+		update += fmt.Sprintf(" REMOVE %s, %s, %s, %s, %s, %s", attrExpiresAt, attrFinalizeQueue, attrFinalizeDueAt, attrFinalizeAttempts, attrFinalizeEnqueuedAt, attrFinalizeLastError)
 	}
 
 	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
@@ -289,6 +404,7 @@ func (s *store) Reject(ctx context.Context, id *blobpb.BlobId, rejection *blob.R
 		":to":       avInt(int(blob.StateRejected)),
 		":ready":    avInt(int(blob.StateReady)),
 		":rejected": avInt(int(blob.StateRejected)),
+		":deleted":  avInt(int(blob.StateDeleted)),
 	}
 
 	update := "SET #state = :to"
@@ -296,10 +412,14 @@ func (s *store) Reject(ctx context.Context, id *blobpb.BlobId, rejection *blob.R
 		update += fmt.Sprintf(", %s = :reason, %s = :cat", attrRejectionReason, attrFlaggedCategory)
 		values[":reason"] = avInt(int(rejection.Reason))
 		values[":cat"] = avInt(int(rejection.FlaggedCategory))
+		if rejection.StripFailed {
+			update += fmt.Sprintf(", %s = :sf", attrStripFailed)
+			values[":sf"] = avBool(true)
+		}
 	}
 	// Rejection is terminal: dequeue the blob from the finalization queue along
 	// with the transition.
-	update += fmt.Sprintf(" REMOVE %s, %s, %s, %s", attrFinalizeQueue, attrFinalizeDueAt, attrFinalizeAttempts, attrFinalizeEnqueuedAt)
+	update += fmt.Sprintf(" REMOVE %s, %s, %s, %s, %s", attrFinalizeQueue, attrFinalizeDueAt, attrFinalizeAttempts, attrFinalizeEnqueuedAt, attrFinalizeLastError)
 	// REJECTED keeps the TTL set at creation: a rejected record is a tombstone the
 	// client can read for the reason, then DynamoDB reclaims it. Only READY clears
 	// the TTL (in Advance).
@@ -309,7 +429,7 @@ func (s *store) Reject(ctx context.Context, id *blobpb.BlobId, rejection *blob.R
 		Key:              map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
 		UpdateExpression: aws.String(update),
 		// Reject only a non-terminal blob; never overwrite a terminal state.
-		ConditionExpression:       aws.String(fmt.Sprintf("attribute_exists(%s) AND #state <> :ready AND #state <> :rejected", attrPK)),
+		ConditionExpression:       aws.String(fmt.Sprintf("attribute_exists(%s) AND #state <> :ready AND #state <> :rejected AND #state <> :deleted", attrPK)),
 		ExpressionAttributeNames:  names,
 		ExpressionAttributeValues: values,
 		// Distinguish "no such blob" from "already terminal" on failure.
@@ -330,8 +450,76 @@ func (s *store) Reject(ctx context.Context, id *blobpb.BlobId, rejection *blob.R
 	return true, nil
 }
 
-func (s *store) MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind blob.ContentKind, nextAttemptAt time.Time) error {
+func (s *store) Reinstate(ctx context.Context, id *blobpb.BlobId) (bool, error) {
 	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		// The record is live again, so it gets a fresh TTL to reach READY within,
+		// as a new reservation would.
+		UpdateExpression: aws.String(fmt.Sprintf("SET #state = :to, %s = :bypassed, %s = :exp REMOVE %s, %s, %s",
+			attrModerationBypassed, attrExpiresAt, attrRejectionReason, attrFlaggedCategory, attrStripFailed)),
+		// Reinstate only a blob rejected by moderation.
+		ConditionExpression:      aws.String(fmt.Sprintf("attribute_exists(%s) AND #state = :rejected AND %s = :moderation", attrPK, attrRejectionReason)),
+		ExpressionAttributeNames: map[string]string{"#state": attrState},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":to":         avInt(int(blob.StateUploaded)),
+			":rejected":   avInt(int(blob.StateRejected)),
+			":moderation": avInt(int(blob.RejectionReasonModeration)),
+			":bypassed":   avBool(true),
+			":exp":        avUnix(time.Now().Add(pendingBlobTTL)),
+		},
+		// Distinguish "no such blob" from "not a moderation rejection" on failure.
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if len(ccf.Item) == 0 {
+				return false, blob.ErrNotFound
+			}
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *store) TakeDown(ctx context.Context, id *blobpb.BlobId) (bool, error) {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		// The record keeps no TTL, as READY did: the collector tombstones it.
+		UpdateExpression:         aws.String(fmt.Sprintf("SET #state = :to, %s = :reason", attrRejectionReason)),
+		ConditionExpression:      aws.String(fmt.Sprintf("attribute_exists(%s) AND #state = :ready", attrPK)),
+		ExpressionAttributeNames: map[string]string{"#state": attrState},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":to":     avInt(int(blob.StateRejected)),
+			":ready":  avInt(int(blob.StateReady)),
+			":reason": avInt(int(blob.RejectionReasonTakenDown)),
+		},
+		// Distinguish "no such blob" from "not READY" on failure.
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if len(ccf.Item) == 0 {
+				return false, blob.ErrNotFound
+			}
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *store) MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind blob.ContentKind, nextAttemptAt time.Time) error {
+	revived, err := s.reviveDeadLetter(ctx, id, kind, nextAttemptAt)
+	if err != nil || revived {
+		return err
+	}
+
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(s.table),
 		Key:       map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
 		// Re-marking resets the due time (and the queue partition, should the kind
@@ -341,7 +529,7 @@ func (s *store) MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind
 		UpdateExpression: aws.String("SET #q = :q, #due = :due, #attempts = if_not_exists(#attempts, :zero), #enq = if_not_exists(#enq, :enq)"),
 		// Only a live, non-terminal blob is queued: the work behind a terminal one
 		// is already done.
-		ConditionExpression: aws.String(fmt.Sprintf("attribute_exists(%s) AND #state <> :ready AND #state <> :rejected", attrPK)),
+		ConditionExpression: aws.String(fmt.Sprintf("attribute_exists(%s) AND #state <> :ready AND #state <> :rejected AND #state <> :deleted", attrPK)),
 		ExpressionAttributeNames: map[string]string{
 			"#q":        attrFinalizeQueue,
 			"#due":      attrFinalizeDueAt,
@@ -356,6 +544,7 @@ func (s *store) MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind
 			":enq":      avUnixNanos(time.Now()),
 			":ready":    avInt(int(blob.StateReady)),
 			":rejected": avInt(int(blob.StateRejected)),
+			":deleted":  avInt(int(blob.StateDeleted)),
 		},
 		// Distinguish "no such blob" from "already terminal" on failure.
 		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
@@ -374,6 +563,43 @@ func (s *store) MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind
 	return nil
 }
 
+// reviveDeadLetter re-queues a dead-lettered blob with a fresh attempt count
+// and enqueue time, reporting false if the blob is not dead-lettered.
+func (s *store) reviveDeadLetter(ctx context.Context, id *blobpb.BlobId, kind blob.ContentKind, nextAttemptAt time.Time) (bool, error) {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		UpdateExpression:    aws.String("SET #q = :q, #due = :due, #attempts = :zero, #enq = :enq REMOVE #err"),
+		ConditionExpression: aws.String(fmt.Sprintf("attribute_exists(%s) AND begins_with(#q, :dead) AND #state <> :ready AND #state <> :rejected AND #state <> :deleted", attrPK)),
+		ExpressionAttributeNames: map[string]string{
+			"#q":        attrFinalizeQueue,
+			"#due":      attrFinalizeDueAt,
+			"#attempts": attrFinalizeAttempts,
+			"#enq":      attrFinalizeEnqueuedAt,
+			"#err":      attrFinalizeLastError,
+			"#state":    attrState,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":q":        avS(finalizeQueuePK(kind)),
+			":due":      avUnixNanos(nextAttemptAt),
+			":zero":     avInt(0),
+			":enq":      avUnixNanos(time.Now()),
+			":dead":     avS(deadLetterQueuePrefix),
+			":ready":    avInt(int(blob.StateReady)),
+			":rejected": avInt(int(blob.StateRejected)),
+			":deleted":  avInt(int(blob.StateDeleted)),
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func (s *store) GetDueForFinalization(ctx context.Context, kind blob.ContentKind, asOf time.Time, limit int) ([]*blob.FinalizationTask, error) {
 	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(s.table),
@@ -471,14 +697,15 @@ func (s *store) ClaimForFinalization(ctx context.Context, id *blobpb.BlobId, asO
 		// Claim only a blob that is still queued and still due: a dequeued blob was
 		// finalized, and one pushed into the future was claimed or delayed by
 		// another worker first.
-		ConditionExpression: aws.String("attribute_exists(#q) AND #due <= :asOf"),
+		ConditionExpression: aws.String("begins_with(#q, :queued) AND #due <= :asOf"),
 		ExpressionAttributeNames: map[string]string{
 			"#q":   attrFinalizeQueue,
 			"#due": attrFinalizeDueAt,
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":until": avUnixNanos(until),
-			":asOf":  avUnixNanos(asOf),
+			":until":  avUnixNanos(until),
+			":asOf":   avUnixNanos(asOf),
+			":queued": avS(finalizeQueuePrefix),
 		},
 	})
 	if err != nil {
@@ -496,17 +723,18 @@ func (s *store) DelayFinalization(ctx context.Context, id *blobpb.BlobId, nextAt
 		TableName:        aws.String(s.table),
 		Key:              map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
 		UpdateExpression: aws.String("SET #due = :due ADD #attempts :one"),
-		// A blob that left the queue (a concurrent finalize drove it terminal) has
-		// nothing to reschedule.
-		ConditionExpression: aws.String("attribute_exists(#q)"),
+		// A blob that left the queue (a concurrent finalize drove it terminal, or
+		// it was dead-lettered) has nothing to reschedule.
+		ConditionExpression: aws.String("begins_with(#q, :queued)"),
 		ExpressionAttributeNames: map[string]string{
 			"#q":        attrFinalizeQueue,
 			"#due":      attrFinalizeDueAt,
 			"#attempts": attrFinalizeAttempts,
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":due": avUnixNanos(nextAttemptAt),
-			":one": avInt(1),
+			":due":    avUnixNanos(nextAttemptAt),
+			":one":    avInt(1),
+			":queued": avS(finalizeQueuePrefix),
 		},
 	})
 	if err != nil {
@@ -519,6 +747,516 @@ func (s *store) DelayFinalization(ctx context.Context, id *blobpb.BlobId, nextAt
 	return nil
 }
 
+func (s *store) DeadLetterFinalization(ctx context.Context, id *blobpb.BlobId, kind blob.ContentKind, attempts uint32, lastError string, at time.Time) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		// Parking moves the entry to the kind's dead-letter partition of the same
+		// index, keyed on the instant it was parked; the enqueue time is kept.
+		UpdateExpression: aws.String("SET #q = :q, #due = :at, #attempts = :attempts, #err = :err"),
+		// Only a blob still queued is parked: one that left the queue finalized.
+		ConditionExpression: aws.String("begins_with(#q, :queued)"),
+		ExpressionAttributeNames: map[string]string{
+			"#q":        attrFinalizeQueue,
+			"#due":      attrFinalizeDueAt,
+			"#attempts": attrFinalizeAttempts,
+			"#err":      attrFinalizeLastError,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":q":        avS(deadLetterQueuePK(kind)),
+			":at":       avUnixNanos(at),
+			":attempts": avInt(int(attempts)),
+			":err":      avS(lastError),
+			":queued":   avS(finalizeQueuePrefix),
+		},
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *store) GetDeadLetters(ctx context.Context, kind blob.ContentKind, limit int) ([]*blob.DeadLetter, error) {
+	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(finalizationQueueIndex),
+		KeyConditionExpression: aws.String("#q = :q"),
+		ExpressionAttributeNames: map[string]string{
+			"#q": attrFinalizeQueue,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":q": avS(deadLetterQueuePK(kind)),
+		},
+		// The range key is the dead-lettered-at time, so the query is already
+		// longest-parked first.
+		Limit: aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]*blob.DeadLetter, 0, len(out.Items))
+	byPK := make(map[string]*blob.DeadLetter, len(out.Items))
+	for _, item := range out.Items {
+		pk := stringAttr(item, attrPK)
+		idBytes, err := hex.DecodeString(strings.TrimPrefix(pk, blobKeyPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute: %w", attrPK, err)
+		}
+		attempts, err := intAttr(item, attrFinalizeAttempts)
+		if err != nil {
+			return nil, err
+		}
+		enqueuedAtNanos, err := uint64Attr(item, attrFinalizeEnqueuedAt)
+		if err != nil {
+			return nil, err
+		}
+		deadLetteredAtNanos, err := uint64Attr(item, attrFinalizeDueAt)
+		if err != nil {
+			return nil, err
+		}
+		letter := &blob.DeadLetter{
+			ID:             &blobpb.BlobId{Value: idBytes},
+			Kind:           kind,
+			Attempts:       uint32(attempts),
+			EnqueuedAt:     time.Unix(0, int64(enqueuedAtNanos)),
+			DeadLetteredAt: time.Unix(0, int64(deadLetteredAtNanos)),
+		}
+		letters = append(letters, letter)
+		byPK[pk] = letter
+	}
+	if len(letters) == 0 {
+		return letters, nil
+	}
+
+	// The index does not project the last error (dead letters are rare and only
+	// listed by operators), so read it from the base table.
+	keys := make([]map[string]types.AttributeValue, 0, len(byPK))
+	for pk := range byPK {
+		keys = append(keys, map[string]types.AttributeValue{attrPK: avS(pk)})
+	}
+	for start := 0; start < len(keys); start += batchGetMaxKeys {
+		chunk := keys[start:min(start+batchGetMaxKeys, len(keys))]
+		for len(chunk) > 0 {
+			out, err := s.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{
+					s.table: {
+						Keys:                     chunk,
+						ProjectionExpression:     aws.String("#pk, #err"),
+						ExpressionAttributeNames: map[string]string{"#pk": attrPK, "#err": attrFinalizeLastError},
+					},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range out.Responses[s.table] {
+				if letter, ok := byPK[stringAttr(item, attrPK)]; ok {
+					letter.LastError = stringAttr(item, attrFinalizeLastError)
+				}
+			}
+			if unprocessed, ok := out.UnprocessedKeys[s.table]; ok && len(unprocessed.Keys) > 0 {
+				chunk = unprocessed.Keys
+			} else {
+				chunk = nil
+			}
+		}
+	}
+	return letters, nil
+}
+
+func (s *store) MarkForCollection(ctx context.Context, id *blobpb.BlobId, since time.Time) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.table),
+		Key:              map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		UpdateExpression: aws.String("SET #q = :q, #since = :since"),
+		// A collected blob stays out of the index.
+		ConditionExpression: aws.String(fmt.Sprintf("attribute_exists(%s) AND #state <> :deleted", attrPK)),
+		ExpressionAttributeNames: map[string]string{
+			"#q":     attrCollectQueue,
+			"#since": attrCollectSince,
+			"#state": attrState,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":q":       avS(collectQueuePK),
+			":since":   avUnixNanos(since),
+			":deleted": avInt(int(blob.StateDeleted)),
+		},
+		// Distinguish "no such blob" from "already collected" on failure.
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if len(ccf.Item) == 0 {
+				return blob.ErrNotFound
+			}
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *store) GetDueForCollection(ctx context.Context, asOf time.Time, limit int) ([]*blobpb.BlobId, error) {
+	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(collectionIndex),
+		KeyConditionExpression: aws.String("#q = :q AND #since <= :asOf"),
+		ExpressionAttributeNames: map[string]string{
+			"#q":     attrCollectQueue,
+			"#since": attrCollectSince,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":q":    avS(collectQueuePK),
+			":asOf": avUnixNanos(asOf),
+		},
+		// The range key is the stamp, so the query is already oldest-first.
+		Limit: aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]*blobpb.BlobId, 0, len(out.Items))
+	for _, item := range out.Items {
+		idBytes, err := hex.DecodeString(strings.TrimPrefix(stringAttr(item, attrPK), blobKeyPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute: %w", attrPK, err)
+		}
+		ids = append(ids, &blobpb.BlobId{Value: idBytes})
+	}
+	return ids, nil
+}
+
+func (s *store) ScanReadyOriginals(ctx context.Context, cursor string, limit int) ([]*blob.Blob, string, error) {
+	// The cursor is the pk the previous page's scan stopped at. Limit bounds the
+	// items read, not the matches, so a page of renditions and in-flight blobs
+	// comes back short — or empty — rather than reading on.
+	var startKey map[string]types.AttributeValue
+	if cursor != "" {
+		startKey = map[string]types.AttributeValue{attrPK: avS(cursor)}
+	}
+	out, err := s.client.Scan(ctx, &dynamodb.ScanInput{
+		TableName:        aws.String(s.table),
+		FilterExpression: aws.String("begins_with(#pk, :prefix) AND #state = :ready AND attribute_not_exists(#parent)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":     attrPK,
+			"#state":  attrState,
+			"#parent": attrParentID,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": avS(blobKeyPrefix),
+			":ready":  avInt(int(blob.StateReady)),
+		},
+		ExclusiveStartKey: startKey,
+		Limit:             aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	page := make([]*blob.Blob, 0, len(out.Items))
+	for _, item := range out.Items {
+		b, err := fromItem(item)
+		if err != nil {
+			return nil, "", err
+		}
+		page = append(page, b)
+	}
+	return page, stringAttr(out.LastEvaluatedKey, attrPK), nil
+}
+
+func (s *store) GetOriginalsByOwner(ctx context.Context, owner *commonpb.UserId, cursor string, limit int) ([]*blob.Blob, string, error) {
+	ownerHex := hex.EncodeToString(owner.Value)
+
+	// The cursor is the creation time and pk of the last item the previous
+	// page's query evaluated, from which the index key is rebuilt. Limit bounds
+	// the items evaluated, not the matches, so a page of renditions and
+	// tombstones comes back short — or empty — rather than reading on.
+	var startKey map[string]types.AttributeValue
+	if cursor != "" {
+		createdAt, pk, ok := strings.Cut(cursor, "/")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid cursor: %q", cursor)
+		}
+		startKey = map[string]types.AttributeValue{
+			attrPK:        avS(pk),
+			attrUserID:    avS(ownerHex),
+			attrCreatedAt: &types.AttributeValueMemberN{Value: createdAt},
+		}
+	}
+	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(ownerIndex),
+		KeyConditionExpression: aws.String("#owner = :owner"),
+		FilterExpression:       aws.String("attribute_not_exists(#parent) AND #state <> :deleted"),
+		ExpressionAttributeNames: map[string]string{
+			"#owner":  attrUserID,
+			"#parent": attrParentID,
+			"#state":  attrState,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner":   avS(ownerHex),
+			":deleted": avInt(int(blob.StateDeleted)),
+		},
+		ExclusiveStartKey: startKey,
+		Limit:             aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids := make([]*blobpb.BlobId, 0, len(out.Items))
+	for _, item := range out.Items {
+		idBytes, err := hex.DecodeString(strings.TrimPrefix(stringAttr(item, attrPK), blobKeyPrefix))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid %s attribute: %w", attrPK, err)
+		}
+		ids = append(ids, &blobpb.BlobId{Value: idBytes})
+	}
+
+	// Manifests are not projected, so the records are read back from the table
+	// and put back in index order.
+	records, err := s.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	byID := make(map[string]*blob.Blob, len(records))
+	for _, record := range records {
+		byID[string(record.ID.Value)] = record
+	}
+	page := make([]*blob.Blob, 0, len(ids))
+	for _, id := range ids {
+		// The index lags the table, so a blob tombstoned since can still be
+		// listed.
+		if record, ok := byID[string(id.Value)]; ok && record.State != blob.StateDeleted {
+			page = append(page, record)
+		}
+	}
+
+	var next string
+	if len(out.LastEvaluatedKey) > 0 {
+		createdAt, ok := out.LastEvaluatedKey[attrCreatedAt].(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, "", fmt.Errorf("invalid %s attribute", attrCreatedAt)
+		}
+		next = createdAt.Value + "/" + stringAttr(out.LastEvaluatedKey, attrPK)
+	}
+	return page, next, nil
+}
+
+func (s *store) Tombstone(ctx context.Context, id *blobpb.BlobId, from blob.State) (bool, error) {
+	// The refund needs the blob's owner and size, which never change, so they
+	// are read ahead of the transition rather than under its condition.
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(out.Item) == 0 {
+		return false, blob.ErrNotFound
+	}
+	existing, err := fromItem(out.Item)
+	if err != nil {
+		return false, err
+	}
+
+	// The tombstone leaves every index and the manifest behind, and takes a
+	// TTL of its own so DynamoDB eventually reclaims it too. The charge is
+	// cleared with the refund, so the tombstone is never refunded twice.
+	//
+	// A rejection is immutable, so one read ahead of the transition still holds
+	// under its condition. A verdict on the content keeps the blob in the
+	// content-hash index, and its tombstone never expires.
+	verdict := from == blob.StateRejected && existing.State == blob.StateRejected &&
+		existing.Rejection != nil && existing.Rejection.Reason.IsContentVerdict()
+	values := map[string]types.AttributeValue{
+		":from":    avInt(int(from)),
+		":deleted": avInt(int(blob.StateDeleted)),
+	}
+	removed := []string{
+		attrFinalizeQueue, attrFinalizeDueAt, attrFinalizeAttempts, attrFinalizeEnqueuedAt, attrFinalizeLastError,
+		attrCollectQueue, attrCollectSince, attrRenditions, attrUsageCharged,
+	}
+	expression := "SET #state = :deleted"
+	if verdict {
+		removed = append(removed, attrExpiresAt)
+	} else {
+		removed = append(removed, attrContentHash, attrContentHashedAt)
+		expression += fmt.Sprintf(", %s = :exp", attrExpiresAt)
+		values[":exp"] = avUnix(time.Now().Add(tombstoneTTL))
+	}
+
+	update := &types.Update{
+		TableName:        aws.String(s.table),
+		Key:              map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		UpdateExpression: aws.String(expression + " REMOVE " + strings.Join(removed, ", ")),
+		// Only a blob still in the state the collector judged it in is collected.
+		ConditionExpression:       aws.String(fmt.Sprintf("attribute_exists(%s) AND #state = :from AND #state <> :deleted", attrPK)),
+		ExpressionAttributeNames:  map[string]string{"#state": attrState},
+		ExpressionAttributeValues: values,
+	}
+	transactItems := []types.TransactWriteItem{{Update: update}}
+	if existing.Owner != nil && boolAttr(out.Item, attrUsageCharged) {
+		transactItems = append(transactItems, s.chargeStored(existing.Owner, -int64(existing.SizeBytes)))
+	}
+
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	if err != nil {
+		var tce *types.TransactionCanceledException
+		if errors.As(err, &tce) && len(tce.CancellationReasons) > 0 &&
+			aws.ToString(tce.CancellationReasons[0].Code) == codeConditionalCheckFailed {
+			// The blob existed a moment ago, so it moved on (or was collected)
+			// rather than vanished.
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *store) SetContentHash(ctx context.Context, id *blobpb.BlobId, hash []byte) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{attrPK: avS(blobPK(id))},
+		// The first recording fixes the blob's place in the index; a replay
+		// rewrites the same hash without moving it.
+		UpdateExpression: aws.String(fmt.Sprintf("SET %s = :hash, %s = if_not_exists(%s, :now)",
+			attrContentHash, attrContentHashedAt, attrContentHashedAt)),
+		ConditionExpression:      aws.String(fmt.Sprintf("attribute_exists(%s) AND #state <> :deleted", attrPK)),
+		ExpressionAttributeNames: map[string]string{"#state": attrState},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hash":    avS(hex.EncodeToString(hash)),
+			":now":     avUnixNanos(time.Now()),
+			":deleted": avInt(int(blob.StateDeleted)),
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if len(ccf.Item) == 0 {
+				return blob.ErrNotFound
+			}
+			return nil // collected
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *store) GetByContentHash(ctx context.Context, hash []byte, limit int) ([]*blob.Blob, error) {
+	out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                aws.String(s.table),
+		IndexName:                aws.String(contentHashIndex),
+		KeyConditionExpression:   aws.String("#hash = :hash"),
+		ExpressionAttributeNames: map[string]string{"#hash": attrContentHash},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hash": avS(hex.EncodeToString(hash)),
+		},
+		// The range key is the recording time, so the query is already
+		// oldest-first.
+		Limit: aws.Int32(int32(limit)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]*blobpb.BlobId, 0, len(out.Items))
+	for _, item := range out.Items {
+		idBytes, err := hex.DecodeString(strings.TrimPrefix(stringAttr(item, attrPK), blobKeyPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute: %w", attrPK, err)
+		}
+		ids = append(ids, &blobpb.BlobId{Value: idBytes})
+	}
+
+	// Only keys are projected, so the records are read back from the table —
+	// which returns them in no particular order — and put back in index order.
+	records, err := s.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*blob.Blob, len(records))
+	for _, record := range records {
+		byID[string(record.ID.Value)] = record
+	}
+	res := make([]*blob.Blob, 0, len(ids))
+	for _, id := range ids {
+		// The index lags the table, so a blob tombstoned since can still be
+		// listed; it resolves without its hash.
+		if record, ok := byID[string(id.Value)]; ok && bytes.Equal(record.ContentHash, hash) {
+			res = append(res, record)
+		}
+	}
+	return res, nil
+}
+
+func (s *store) GetUsage(ctx context.Context, owner *commonpb.UserId, day time.Time) (*blob.Usage, error) {
+	stored, err := s.readCounter(ctx, storedUsagePK(owner), attrStoredBytes)
+	if err != nil {
+		return nil, err
+	}
+	daily, err := s.readCounter(ctx, dailyUsagePK(owner, day), attrDailyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &blob.Usage{StoredBytes: stored, DailyBytes: daily}, nil
+}
+
+// chargeStored returns the transaction item that moves owner's stored bytes by
+// delta, creating the total on first use.
+func (s *store) chargeStored(owner *commonpb.UserId, delta int64) types.TransactWriteItem {
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:        aws.String(s.table),
+		Key:              map[string]types.AttributeValue{attrPK: avS(storedUsagePK(owner))},
+		UpdateExpression: aws.String(fmt.Sprintf("ADD %s :delta", attrStoredBytes)),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delta": &types.AttributeValueMemberN{Value: strconv.FormatInt(delta, 10)},
+		},
+	}}
+}
+
+// chargeDaily returns the transaction item that adds sizeBytes to owner's
+// reservations for the UTC day containing now, creating the day's counter
+// (with its TTL) on first use.
+func (s *store) chargeDaily(owner *commonpb.UserId, now time.Time, sizeBytes uint64) types.TransactWriteItem {
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:        aws.String(s.table),
+		Key:              map[string]types.AttributeValue{attrPK: avS(dailyUsagePK(owner, now))},
+		UpdateExpression: aws.String(fmt.Sprintf("ADD %s :size SET %s = if_not_exists(%s, :exp)", attrDailyBytes, attrExpiresAt, attrExpiresAt)),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":size": avUint64(sizeBytes),
+			":exp":  avUnix(blob.UsageDay(now).Add(dailyUsageTTL)),
+		},
+	}}
+}
+
+// readCounter returns the named counter on the item at pk, or zero if the item
+// does not exist yet. The read is strongly consistent, since quota checks are
+// made against it.
+func (s *store) readCounter(ctx context.Context, pk, name string) (uint64, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]types.AttributeValue{attrPK: avS(pk)},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := out.Item[name]; !ok {
+		return 0, nil
+	}
+	return uint64Attr(out.Item, name)
+}
+
 func toItem(b *blob.Blob) map[string]types.AttributeValue {
 	item := map[string]types.AttributeValue{
 		attrPK:         avS(blobPK(b.ID)),
@@ -532,11 +1270,31 @@ func toItem(b *blob.Blob) map[string]types.AttributeValue {
 	if b.ParentID != nil {
 		item[attrParentID] = avS(hex.EncodeToString(b.ParentID.Value))
 	}
+	if b.OriginSizeBytes != 0 {
+		item[attrOriginSize] = avUint64(b.OriginSizeBytes)
+	}
+	if b.Multipart != nil {
+		item[attrMultipartID] = avS(b.Multipart.UploadID)
+		item[attrMultipartPart] = avUint64(b.Multipart.PartSizeBytes)
+	}
+	if b.PerceptualHash != nil {
+		item[attrPerceptual] = avS(b.PerceptualHash.String())
+	}
 	if b.Image != nil {
 		item[attrImageWidth] = avInt(int(b.Image.Width))
 		item[attrImageHeight] = avInt(int(b.Image.Height))
 		item[attrImageBlurhash] = avS(b.Image.Blurhash)
 		item[attrImageHasAlpha] = avBool(b.Image.HasAlpha)
+		item[attrImageAnimated] = avBool(b.Image.Animated)
+		item[attrImageFrames] = avInt(int(b.Image.FrameCount))
+	}
+	if b.Video != nil {
+		item[attrVideoWidth] = avInt(int(b.Video.Width))
+		item[attrVideoHeight] = avInt(int(b.Video.Height))
+		item[attrVideoDuration] = avInt(int(b.Video.Duration))
+		item[attrVideoCodec] = avS(b.Video.Codec)
+		item[attrVideoRotation] = avInt(int(b.Video.Rotation))
+		item[attrVideoBlurhash] = avS(b.Video.Blurhash)
 	}
 	return item
 }
@@ -582,6 +1340,41 @@ func fromItem(item map[string]types.AttributeValue) (*blob.Blob, error) {
 		b.ParentID = &blobpb.BlobId{Value: parentBytes}
 	}
 
+	if _, ok := item[attrOriginSize]; ok {
+		originSize, err := uint64Attr(item, attrOriginSize)
+		if err != nil {
+			return nil, err
+		}
+		b.OriginSizeBytes = originSize
+	}
+
+	if _, ok := item[attrMultipartID]; ok {
+		partSize, err := uint64Attr(item, attrMultipartPart)
+		if err != nil {
+			return nil, err
+		}
+		b.Multipart = &blob.MultipartUpload{
+			UploadID:      stringAttr(item, attrMultipartID),
+			PartSizeBytes: partSize,
+		}
+	}
+
+	if _, ok := item[attrContentHash]; ok {
+		hash, err := hexAttr(item, attrContentHash)
+		if err != nil {
+			return nil, err
+		}
+		b.ContentHash = hash
+	}
+
+	if _, ok := item[attrPerceptual]; ok {
+		hash, err := blob.ParsePerceptualHash(stringAttr(item, attrPerceptual))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute: %w", attrPerceptual, err)
+		}
+		b.PerceptualHash = &hash
+	}
+
 	if _, ok := item[attrImageBlurhash]; ok {
 		width, err := intAttr(item, attrImageWidth)
 		if err != nil {
@@ -596,9 +1389,26 @@ func fromItem(item map[string]types.AttributeValue) (*blob.Blob, error) {
 			Height:   uint32(height),
 			Blurhash: stringAttr(item, attrImageBlurhash),
 			HasAlpha: boolAttr(item, attrImageHasAlpha),
+			Animated: boolAttr(item, attrImageAnimated),
+		}
+		// Absent on items written before animation support; they are stills.
+		if _, ok := item[attrImageFrames]; ok {
+			frames, err := intAttr(item, attrImageFrames)
+			if err != nil {
+				return nil, err
+			}
+			b.Image.FrameCount = uint32(frames)
 		}
 	}
 
+	if _, ok := item[attrVideoCodec]; ok {
+		video, err := videoFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		b.Video = video
+	}
+
 	if raw, ok := item[attrRenditions].(*types.AttributeValueMemberS); ok {
 		// A rendition normally shares the original's mime type, BlurHash, and alpha,
 		// stored once on the original item rather than per entry.
@@ -628,11 +1438,40 @@ func fromItem(item map[string]types.AttributeValue) (*blob.Blob, error) {
 			}
 			b.Rejection.FlaggedCategory = moderationpb.FlaggedCategory(category)
 		}
+		b.Rejection.StripFailed = boolAttr(item, attrStripFailed)
 	}
+	b.ModerationBypassed = boolAttr(item, attrModerationBypassed)
 
 	return b, nil
 }
 
+func videoFromItem(item map[string]types.AttributeValue) (*blob.VideoMetadata, error) {
+	width, err := intAttr(item, attrVideoWidth)
+	if err != nil {
+		return nil, err
+	}
+	height, err := intAttr(item, attrVideoHeight)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := intAttr(item, attrVideoDuration)
+	if err != nil {
+		return nil, err
+	}
+	rotation, err := intAttr(item, attrVideoRotation)
+	if err != nil {
+		return nil, err
+	}
+	return &blob.VideoMetadata{
+		Width:    uint32(width),
+		Height:   uint32(height),
+		Duration: time.Duration(duration),
+		Codec:    stringAttr(item, attrVideoCodec),
+		Rotation: uint32(rotation),
+		Blurhash: stringAttr(item, attrVideoBlurhash),
+	}, nil
+}
+
 // renditionRefItem is the JSON shape a manifest entry is stored as. It flattens
 // the reused ImageMetadata (w/h/alpha) so the serialized form stays a compact flat
 // object, and holds the blob id as hex. MimeType, BlurHash, and HasAlpha are stored
@@ -746,6 +1585,18 @@ func unmarshalRenditions(encoded string, def renditionDefaults) ([]blob.Renditio
 
 func blobPK(id *blobpb.BlobId) string { return blobKeyPrefix + hex.EncodeToString(id.Value) }
 
+// storedUsagePK is the partition of owner's stored-bytes total, e.g.
+// "usage#<owner hex>".
+func storedUsagePK(owner *commonpb.UserId) string {
+	return usageKeyPrefix + hex.EncodeToString(owner.Value)
+}
+
+// dailyUsagePK is the partition of owner's reservations for the UTC day
+// containing t, e.g. "usage#<owner hex>#2006-01-02".
+func dailyUsagePK(owner *commonpb.UserId, t time.Time) string {
+	return storedUsagePK(owner) + "#" + blob.UsageDay(t).Format(time.DateOnly)
+}
+
 // finalizeQueuePK is the queue partition a content kind's blobs wait in, e.g.
 // "queue#1" for images. The kind's numeric value is persisted, matching how the
 // other internal enums (state, rendition) are stored, so it must stay stable —
@@ -762,6 +1613,12 @@ func finalizeQueuePK(kind blob.ContentKind) string {
 	return finalizeQueuePrefix + strconv.Itoa(int(kind))
 }
 
+// deadLetterQueuePK is kind's dead-letter partition of the finalization queue
+// index. Like the queue itself it is a single partition, and a far quieter one.
+func deadLetterQueuePK(kind blob.ContentKind) string {
+	return deadLetterQueuePrefix + strconv.Itoa(int(kind))
+}
+
 func avS(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
 func avInt(v int) types.AttributeValue {
 	return &types.AttributeValueMemberN{Value: strconv.Itoa(v)}
@@ -14,7 +14,7 @@ import (
 const blobsTable = "blobs_test"
 
 func TestBlob_DynamoDBStore(t *testing.T) {
-	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable))
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
 
 	testStore := NewInDynamoDB(testEnv.Client, blobsTable)
 	teardown := func() {
@@ -3,6 +3,7 @@ package dynamodb
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -10,36 +11,45 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-// CreateTables provisions the blob domain's DynamoDB tables — the blobs table
-// and the ACL table — and is the single entry point for setting them up. It is
-// idempotent (existing tables are left as-is) and blocks until both are ACTIVE.
-func CreateTables(ctx context.Context, client *dynamodb.Client, blobsTable, aclTable string) error {
+// CreateTables provisions the blob domain's DynamoDB tables — the blobs table,
+// the ACL table, and the hash blocklist table — and is the single entry point
+// for setting them up. It is idempotent (existing tables are left as-is) and
+// blocks until all of them are ACTIVE.
+func CreateTables(ctx context.Context, client *dynamodb.Client, blobsTable, aclTable, blocklistTable string) error {
 	if err := createBlobsTable(ctx, client, blobsTable); err != nil {
 		return err
 	}
-	return createACLTable(ctx, client, aclTable)
+	if err := createACLTable(ctx, client, aclTable); err != nil {
+		return err
+	}
+	return createBlocklistTable(ctx, client, blocklistTable)
 }
 
 // createBlobsTable provisions the blobs table: one item per blob keyed by
 // pk = "blob#<id hex>", with on-demand billing, plus the sparse finalization
-// queue GSI the background worker polls. An original's renditions are recorded
-// as a manifest on the original's item and resolved in the read that fetches it,
-// so there is no by-parent index. It is idempotent (an existing table is left
-// as-is, though a missing queue index is added to it) and blocks until the table
-// and index are ACTIVE.
+// queue GSI the background workers poll and the sparse collection GSI the
+// garbage collector walks, plus the sparse content-hash GSI finalization
+// deduplicates against, plus the owner GSI listing what each user uploaded. An
+// original's renditions are recorded as a manifest on the original's item and
+// resolved in the read that fetches it, so there is no by-parent index. It is idempotent (an existing table is left as-is, though
+// a missing index is added to it) and blocks until the table and indexes are
+// ACTIVE.
 func createBlobsTable(ctx context.Context, client *dynamodb.Client, blobsTable string) error {
 	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
 		TableName:   aws.String(blobsTable),
 		BillingMode: types.BillingModePayPerRequest,
-		AttributeDefinitions: []types.AttributeDefinition{
+		AttributeDefinitions: append([]types.AttributeDefinition{
 			{AttributeName: aws.String(attrPK), AttributeType: types.ScalarAttributeTypeS},
-			{AttributeName: aws.String(attrFinalizeQueue), AttributeType: types.ScalarAttributeTypeS},
-			{AttributeName: aws.String(attrFinalizeDueAt), AttributeType: types.ScalarAttributeTypeN},
-		},
+		}, slices.Concat(finalizationQueueIndexAttributes(), collectionIndexAttributes(), contentHashIndexAttributes(), ownerIndexAttributes())...),
 		KeySchema: []types.KeySchemaElement{
 			{AttributeName: aws.String(attrPK), KeyType: types.KeyTypeHash},
 		},
-		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{finalizationQueueIndexSchema()},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			finalizationQueueIndexSchema(),
+			collectionIndexSchema(),
+			contentHashIndexSchema(),
+			ownerIndexSchema(),
+		},
 	})
 	if err != nil {
 		var inUse *types.ResourceInUseException
@@ -53,7 +63,16 @@ func createBlobsTable(ctx context.Context, client *dynamodb.Client, blobsTable s
 	}, 2*time.Minute); err != nil {
 		return err
 	}
-	if err := ensureFinalizationQueueIndex(ctx, client, blobsTable); err != nil {
+	if err := ensureIndex(ctx, client, blobsTable, finalizationQueueIndexSchema(), finalizationQueueIndexAttributes()); err != nil {
+		return err
+	}
+	if err := ensureIndex(ctx, client, blobsTable, collectionIndexSchema(), collectionIndexAttributes()); err != nil {
+		return err
+	}
+	if err := ensureIndex(ctx, client, blobsTable, contentHashIndexSchema(), contentHashIndexAttributes()); err != nil {
+		return err
+	}
+	if err := ensureIndex(ctx, client, blobsTable, ownerIndexSchema(), ownerIndexAttributes()); err != nil {
 		return err
 	}
 	return enableTTL(ctx, client, blobsTable)
@@ -80,11 +99,89 @@ func finalizationQueueIndexSchema() types.GlobalSecondaryIndex {
 	}
 }
 
-// ensureFinalizationQueueIndex adds the finalization queue GSI to a blobs table
-// that predates it, and blocks until the index is ACTIVE. A table created by
-// createBlobsTable already carries it, so this is a no-op there; it exists so a
-// deploy against an existing production table converges without manual steps.
-func ensureFinalizationQueueIndex(ctx context.Context, client *dynamodb.Client, blobsTable string) error {
+func finalizationQueueIndexAttributes() []types.AttributeDefinition {
+	return []types.AttributeDefinition{
+		{AttributeName: aws.String(attrFinalizeQueue), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String(attrFinalizeDueAt), AttributeType: types.ScalarAttributeTypeN},
+	}
+}
+
+// collectionIndexSchema is the collection GSI: a sparse index over every
+// uncollected original, sorted by the instant its grace period counts from so
+// the collector's poll is a single oldest-first Query. Only keys are projected;
+// the collector reads each candidate's full record before judging it.
+func collectionIndexSchema() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(collectionIndex),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrCollectQueue), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrCollectSince), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+	}
+}
+
+func collectionIndexAttributes() []types.AttributeDefinition {
+	return []types.AttributeDefinition{
+		{AttributeName: aws.String(attrCollectQueue), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String(attrCollectSince), AttributeType: types.ScalarAttributeTypeN},
+	}
+}
+
+// contentHashIndexSchema is the content-hash GSI: a sparse index over every
+// hashed original, partitioned by hash and sorted by when the hash was
+// recorded, so finding earlier uploads of the same bytes is a single
+// oldest-first Query. Only keys are projected; the matches' records are read
+// back from the table.
+func contentHashIndexSchema() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(contentHashIndex),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrContentHash), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrContentHashedAt), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+	}
+}
+
+func contentHashIndexAttributes() []types.AttributeDefinition {
+	return []types.AttributeDefinition{
+		{AttributeName: aws.String(attrContentHash), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String(attrContentHashedAt), AttributeType: types.ScalarAttributeTypeN},
+	}
+}
+
+// ownerIndexSchema is the owner GSI: an index over every blob item, partitioned
+// by owner and sorted by creation, so listing what a user uploaded is a single
+// Query. The parent and state are projected for the query to filter renditions
+// and tombstones on; the matches' records are read back from the table.
+func ownerIndexSchema() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(ownerIndex),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrUserID), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrCreatedAt), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{
+			ProjectionType:   types.ProjectionTypeInclude,
+			NonKeyAttributes: []string{attrParentID, attrState},
+		},
+	}
+}
+
+func ownerIndexAttributes() []types.AttributeDefinition {
+	return []types.AttributeDefinition{
+		{AttributeName: aws.String(attrUserID), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String(attrCreatedAt), AttributeType: types.ScalarAttributeTypeN},
+	}
+}
+
+// ensureIndex adds a GSI to a blobs table that predates it, and blocks until
+// the index is ACTIVE. A table created by createBlobsTable already carries every
+// index, so this is a no-op there; it exists so a deploy against an existing
+// production table converges without manual steps. DynamoDB builds one index at
+// a time, so the indexes are ensured in turn.
+func ensureIndex(ctx context.Context, client *dynamodb.Client, blobsTable string, gsi types.GlobalSecondaryIndex, attributes []types.AttributeDefinition) error {
 	for {
 		desc, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
 			TableName: aws.String(blobsTable),
@@ -95,20 +192,16 @@ func ensureFinalizationQueueIndex(ctx context.Context, client *dynamodb.Client,
 
 		var index *types.GlobalSecondaryIndexDescription
 		for i := range desc.Table.GlobalSecondaryIndexes {
-			if aws.ToString(desc.Table.GlobalSecondaryIndexes[i].IndexName) == finalizationQueueIndex {
+			if aws.ToString(desc.Table.GlobalSecondaryIndexes[i].IndexName) == aws.ToString(gsi.IndexName) {
 				index = &desc.Table.GlobalSecondaryIndexes[i]
 				break
 			}
 		}
 
 		if index == nil {
-			gsi := finalizationQueueIndexSchema()
 			if _, err := client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
-				TableName: aws.String(blobsTable),
-				AttributeDefinitions: []types.AttributeDefinition{
-					{AttributeName: aws.String(attrFinalizeQueue), AttributeType: types.ScalarAttributeTypeS},
-					{AttributeName: aws.String(attrFinalizeDueAt), AttributeType: types.ScalarAttributeTypeN},
-				},
+				TableName:            aws.String(blobsTable),
+				AttributeDefinitions: attributes,
 				GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
 					Create: &types.CreateGlobalSecondaryIndexAction{
 						IndexName:  gsi.IndexName,
@@ -166,6 +259,36 @@ func createACLTable(ctx context.Context, client *dynamodb.Client, aclTable strin
 	}, 2*time.Minute)
 }
 
+// createBlocklistTable provisions the hash blocklist table: one item per blocked
+// hash keyed by pk = "blocklist" and sk = "<phash hex><dhash hex>", with
+// on-demand billing, no secondary indexes, and no TTL — entries persist until
+// staff remove them. It is idempotent (an existing table is left as-is) and
+// blocks until the table is ACTIVE.
+func createBlocklistTable(ctx context.Context, client *dynamodb.Client, blocklistTable string) error {
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(blocklistTable),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(attrPK), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attrSK), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrPK), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrSK), KeyType: types.KeyTypeRange},
+		},
+	})
+	if err != nil {
+		var inUse *types.ResourceInUseException
+		if !errors.As(err, &inUse) {
+			return err
+		}
+		// Already exists; still ensure it is ACTIVE below.
+	}
+	return dynamodb.NewTableExistsWaiter(client).Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(blocklistTable),
+	}, 2*time.Minute)
+}
+
 // enableTTL turns on DynamoDB's TTL feature against the expires_at attribute, so
 // blobs that never reach READY are reclaimed automatically. Enabling TTL when it
 // is already enabled is an error, so it is checked first to stay idempotent.
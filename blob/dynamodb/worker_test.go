@@ -13,7 +13,7 @@ import (
 )
 
 func TestBlob_DynamoDBWorker(t *testing.T) {
-	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable))
+	require.NoError(t, CreateTables(context.Background(), testEnv.Client, blobsTable, aclTable, blocklistTable))
 
 	blobs := NewInDynamoDB(testEnv.Client, blobsTable)
 	// The object storage is always the in-memory fake; only the metadata store —
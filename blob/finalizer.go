@@ -1,9 +1,12 @@
 package blob
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"image"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -17,20 +20,109 @@ import (
 // the bytes are not present in storage yet.
 var errBytesNotUploaded = errors.New("bytes not uploaded")
 
+// duplicateCandidates bounds how many earlier uploads of the same bytes are
+// considered when deduplicating. The oldest come first, and the first settled
+// one decides, so only uploads still in flight are ever passed over.
+const duplicateCandidates = 10
+
+// duplicateClassifier is the classifier a moderation audit record names when an
+// upload was rejected as a duplicate of one moderation already rejected, rather
+// than classified itself.
+const duplicateClassifier = "duplicate"
+
 // Finalizer drives an uploaded blob through the processing pipeline to a
 // terminal state: confirm the upload landed, validate + derive metadata +
 // moderate, copy into the origin store, generate renditions, and clean up. It is
 // the single owner of that pipeline — the background worker runs it off the
 // finalization queue (see Store.MarkForFinalization), while the RPCs only queue
 // the work.
+//
+// Uploads are deduplicated by content: the SHA-256 of the uploaded bytes is
+// recorded on the blob (see Store.SetContentHash), and an earlier upload of the
+// same bytes that already settled decides for it. A match rejected by
+// moderation rejects the upload outright; a READY match spares it the
+// moderation call, and its renditions are copied rather than derived again.
+// Inspection still runs, so the upload's own declared type is checked and its
+// metadata derived as usual.
+//
+// With a hash blocklist (see WithHashBlocklist), inspection also screens the
+// upload's perceptual hash — and those of its sampled frames — against images
+// staff removed, rejecting near-duplicates before the moderator ever sees them.
+// The screen runs on every upload, duplicates included, so blocking a hash
+// also stops re-uploads of bytes that passed moderation before it was blocked.
+//
+// A blob whose moderation rejection was overturned on appeal (see
+// Store.Reinstate) is finalized again without moderation, and without deferring
+// to rejected duplicates of its bytes. The blocklist screen still applies.
 type Finalizer struct {
 	log     *zap.Logger
 	blobs   Store
 	storage ObjectStorage
 
-	// moderator classifies uploaded image bytes during finalization. It is
-	// optional; when nil, moderation is skipped.
+	// moderator classifies uploaded image bytes (and sampled video frames) during
+	// finalization. It is optional; when nil, moderation is skipped.
 	moderator moderation.Client
+
+	// frames decodes still frames out of video bytes, for moderation and the
+	// poster. It is optional; when nil, video blobs are rejected as unsupported,
+	// since neither could be derived.
+	frames VideoFrameExtractor
+
+	// sanitize strips an image's privacy metadata instead of rejecting it over
+	// the metadata (see WithSanitizeMetadata).
+	sanitize bool
+
+	// blocklist holds the perceptual hashes of images staff removed. It is
+	// optional; when nil, uploads are not screened against it.
+	blocklist HashBlocklist
+
+	// avif encodes the AVIF siblings of an opaque original's renditions. It is
+	// optional; when nil, the ladder is WebP only.
+	avif AVIFEncoder
+
+	// audit records every moderation rejection (see WithModerationAudit). It is
+	// optional; when nil, rejections leave no audit record.
+	audit moderation.AuditStore
+}
+
+// FinalizerOption configures an optional capability of the Finalizer.
+type FinalizerOption func(*Finalizer)
+
+// WithVideoFrameExtractor enables video finalization, using frames to decode the
+// moderation samples and poster frame out of a video's bytes.
+func WithVideoFrameExtractor(frames VideoFrameExtractor) FinalizerOption {
+	return func(f *Finalizer) { f.frames = frames }
+}
+
+// WithSanitizeMetadata makes finalization strip an image's privacy metadata
+// rather than reject the image for carrying it: the stripped bytes are
+// re-verified, moderated, and promoted to the origin store in place of the
+// upload. An image whose metadata cannot be stripped is still rejected, with
+// RejectionMetadata.StripFailed set.
+func WithSanitizeMetadata() FinalizerOption {
+	return func(f *Finalizer) { f.sanitize = true }
+}
+
+// WithHashBlocklist screens uploads against the perceptual hashes on blocklist,
+// rejecting near-duplicates of blocked images (RejectionReasonBlocklisted).
+func WithHashBlocklist(blocklist HashBlocklist) FinalizerOption {
+	return func(f *Finalizer) { f.blocklist = blocklist }
+}
+
+// WithAVIFEncoder adds an AVIF sibling, encoded by avif, to every rung of an
+// opaque original's ladder, alongside its WebP rendition. Clients that decode
+// AVIF are served it in the WebP rendition's place (see
+// Integration.ResolveRenditions).
+func WithAVIFEncoder(avif AVIFEncoder) FinalizerOption {
+	return func(f *Finalizer) { f.avif = avif }
+}
+
+// WithModerationAudit records every moderation rejection to audit, which is
+// what lets its owner appeal it. The upload bytes of a moderation rejection are
+// then kept until the blob is collected, rather than cleaned up on rejection, so
+// a rejection overturned on appeal can be finalized again.
+func WithModerationAudit(audit moderation.AuditStore) FinalizerOption {
+	return func(f *Finalizer) { f.audit = audit }
 }
 
 // NewFinalizer returns a Finalizer over the given blob metadata store, object
@@ -40,13 +132,39 @@ func NewFinalizer(
 	blobs Store,
 	storage ObjectStorage,
 	moderator moderation.Client,
+	opts ...FinalizerOption,
 ) *Finalizer {
-	return &Finalizer{
+	f := &Finalizer{
 		log:       log,
 		blobs:     blobs,
 		storage:   storage,
 		moderator: moderator,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// inspectedContent is what inspecting a blob's bytes yields, whatever its kind:
+// the metadata persisted at StateInspected, plus the still image its renditions
+// are derived from — the image itself, or a video's poster frame — and that
+// still's own image metadata.
+type inspectedContent struct {
+	mimeType  string
+	metadata  *DerivedMetadata
+	still     image.Image
+	stillMeta *ImageMetadata
+
+	// frames are the extra frames sampled for moderation and the blocklist
+	// screen: a video's, or an animated image's after its first. A video's are
+	// only extracted on the moderated or screened path, and they are nil for a
+	// still image, which is moderated directly.
+	frames []image.Image
+
+	// sanitized are the stripped bytes to promote in place of the upload, set
+	// only when sanitize mode rewrote them.
+	sanitized []byte
 }
 
 // Finalize drives a blob through its processing pipeline, resuming from whatever
@@ -64,18 +182,23 @@ func (f *Finalizer) Finalize(ctx context.Context, record *Blob) (blobpb.BlobStat
 		return state.ToBlobStatus(), nil
 	}
 
-	if record.ContentKind() != ContentKindImage {
+	switch record.ContentKind() {
+	case ContentKindImage, ContentKindVideo:
+	default:
 		return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, errors.New("unsupported content kind for finalization")
 	}
 
 	var data []byte // the uploaded bytes, fetched once and reused across steps
 
-	// The decoded original and its derived metadata, captured when the inspection
-	// step runs so rendition generation can reuse them without decoding twice. Both
-	// stay nil on a finalize that resumes past inspection, in which case the
-	// generation step re-derives them from the still-present upload bytes.
-	var decoded image.Image
-	var imageMeta *ImageMetadata
+	// The inspected content, captured when the inspection step runs so rendition
+	// generation can reuse the decoded still without decoding twice. It stays nil
+	// on a finalize that resumes past inspection, in which case the generation step
+	// re-derives it from the still-present upload bytes.
+	var content *inspectedContent
+
+	// The SHA-256 of the uploaded bytes: recorded when the inspection step runs,
+	// or already on the record of a finalize that resumes past it.
+	contentHash := record.ContentHash
 
 	// Confirm the client's upload landed, then checkpoint StateUploaded.
 	if state < StateUploaded {
@@ -114,54 +237,101 @@ func (f *Finalizer) Finalize(ctx context.Context, record *Blob) (blobpb.BlobStat
 			// upload broke its declared size contract.
 			return f.reject(ctx, record, &RejectionMetadata{Reason: RejectionReasonTooLarge})
 		}
-		inspection, err := InspectImage(data)
+
+		hash := sha256.Sum256(data)
+		contentHash = hash[:]
+		if err := f.blobs.SetContentHash(ctx, record.ID, contentHash); err != nil {
+			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+		}
+		duplicate, err := f.findDuplicate(ctx, record.ID, contentHash)
+		if err != nil {
+			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+		}
+		if duplicate != nil && duplicate.State != StateReady && !record.ModerationBypassed {
+			// The same bytes already failed moderation, or staff took them down; that
+			// verdict stands.
+			f.log.Debug("Rejecting duplicate of a rejected upload",
+				zap.String("blob_id", IDString(record.ID)),
+				zap.String("duplicate_of", IDString(duplicate.ID)),
+			)
+			rejection := *duplicate.Rejection
+			return f.rejectFlagged(ctx, record, &rejection, f.duplicateFlag(ctx, record, data, duplicate))
+		}
+		// A READY duplicate already passed moderation, and staff cleared a blob
+		// reinstated on appeal, so neither is moderated.
+		moderate := f.moderator != nil && duplicate == nil && !record.ModerationBypassed
+		screen := f.blocklist != nil
+
+		inspected, rejection, err := f.inspect(ctx, record, data, moderate || screen)
 		if err != nil {
-			// Undecodable, unsupported, or oversize bytes: not a servable image.
-			return f.reject(ctx, record, &RejectionMetadata{Reason: rejectionReasonForInspection(err)})
+			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+		}
+		if rejection != nil {
+			// Undecodable, unsupported, or oversize bytes: not servable content.
+			return f.reject(ctx, record, rejection)
 		}
-		if inspection.MimeType != record.MimeType {
+		if inspected.mimeType != record.MimeType {
 			return f.reject(ctx, record, &RejectionMetadata{Reason: RejectionReasonMismatchedType})
 		}
-		if f.moderator != nil {
-			// Moderate a size-bounded rendering, not the full-resolution original:
-			// provider sync endpoints are tuned for small images and cap payload
-			// size, and full resolution adds nothing to classification.
-			payload, err := moderationPayload(data, inspection.Decoded)
+		if screen {
+			// Known-abusive content is rejected here, so it never reaches the
+			// moderation vendor.
+			rejection, err = f.screen(ctx, record, inspected)
 			if err != nil {
 				return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
 			}
-			result, err := f.moderator.ClassifyImage(ctx, payload)
+			if rejection != nil {
+				return f.reject(ctx, record, rejection)
+			}
+		}
+		if moderate {
+			moderated := data
+			if inspected.sanitized != nil {
+				moderated = inspected.sanitized
+			}
+			var result *moderation.Result
+			rejection, result, err = f.moderate(ctx, moderated, inspected)
 			if err != nil {
 				// Could not establish safety; leave the blob un-advanced so the
 				// attempt can be retried rather than wrongly marking it servable.
 				return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
 			}
-			if result.Flagged {
-				return f.reject(ctx, record, &RejectionMetadata{
-					Reason:          RejectionReasonModeration,
-					FlaggedCategory: moderation.HighestFlaggedCategory(result),
-				})
+			if rejection != nil {
+				return f.rejectFlagged(ctx, record, rejection, f.flag(record, moderation.ClassifierName(f.moderator, moderation.MethodImage), data, result))
 			}
 		}
 
-		advanced, err := f.blobs.Advance(ctx, record.ID, StateInspected, inspection.Metadata)
+		advanced, err := f.blobs.Advance(ctx, record.ID, StateInspected, inspected.metadata)
 		if err != nil {
 			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
 		}
 		if !advanced {
 			return f.currentStatus(ctx, record.ID)
 		}
-		// Carry the decoded image and derived metadata forward so the generation
-		// step can derive renditions without re-reading and re-decoding the bytes.
-		decoded = inspection.Decoded
-		imageMeta = inspection.Metadata
+		// Carry the decoded still forward so the generation step can derive
+		// renditions without re-reading and re-decoding the bytes.
+		content = inspected
 		state = StateInspected
 	}
 
 	// Copy the original's bytes into the origin store, then checkpoint
 	// StatePromoted. This is the durable source renditions will be derived from.
+	// In sanitize mode an image's stripped bytes are written there instead,
+	// re-derived when the finalize resumed past inspection; stripping is
+	// deterministic, so they match the size persisted at StateInspected.
 	if state < StatePromoted {
-		if err := f.storage.CopyToOrigin(ctx, record.StorageKey); err != nil {
+		if content == nil && f.sanitize && record.ContentKind() == ContentKindImage {
+			resumed, err := f.reinspect(ctx, record, &data)
+			if err != nil {
+				return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+			}
+			content = resumed
+		}
+		if content != nil && content.sanitized != nil {
+			if err := f.storage.PutOrigin(ctx, record.StorageKey, record.MimeType, content.sanitized); err != nil {
+				return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+			}
+		} else if err := f.storage.CopyToOrigin(ctx, record.StorageKey); err != nil {
 			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
 		}
 		advanced, err := f.blobs.Advance(ctx, record.ID, StatePromoted, nil)
@@ -181,31 +351,31 @@ func (f *Finalizer) Finalize(ctx context.Context, record *Blob) (blobpb.BlobStat
 	// a failure here leaves the blob at StatePromoted for a retry rather than
 	// rejecting an original that already passed moderation.
 	//
-	// Renditions are derived per content kind. Only images exist today, so this
-	// dispatches straight to the image strategy; when another kind is added it is
-	// inspected and generated on its own arm here, with its own ladder, rather than
-	// through the image path.
+	// Every kind's renditions today are image stills run through the image ladder:
+	// an image's own pixels, or a video's poster frame. A kind with renditions of
+	// another shape (e.g. video transcodes) gets its own generation arm here rather
+	// than going through the image path.
 	if state < StateGeneratingRenditions {
-		if decoded == nil {
-			// Resumed past inspection: the decoded image is no longer in hand. The
-			// upload bytes are still present (cleanup runs only at READY), so re-read
-			// and re-derive from them.
-			if data == nil {
-				fetched, err := f.fetchUploaded(ctx, record)
-				if err != nil {
-					return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
-				}
-				data = fetched
-			}
-			inspection, err := InspectImage(data)
+		if content == nil {
+			resumed, err := f.reinspect(ctx, record, &data)
 			if err != nil {
 				return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
 			}
-			decoded = inspection.Decoded
-			imageMeta = inspection.Metadata
+			content = resumed
 		}
 
-		if err := f.generateImageRenditions(ctx, record, decoded, imageMeta); err != nil {
+		// A READY upload of the same bytes already derived this ladder; its
+		// renditions are copied wherever they line up with the plan.
+		duplicate, err := f.findDuplicate(ctx, record.ID, contentHash)
+		if err != nil {
+			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+		}
+		var source []RenditionRef
+		if duplicate != nil && duplicate.State == StateReady {
+			source = duplicate.Renditions
+		}
+
+		if err := f.generateImageRenditions(ctx, record, content.still, content.stillMeta, source); err != nil {
 			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
 		}
 		advanced, err := f.blobs.Advance(ctx, record.ID, StateGeneratingRenditions, nil)
@@ -224,6 +394,11 @@ func (f *Finalizer) Finalize(ctx context.Context, record *Blob) (blobpb.BlobStat
 	// upload bucket's lifecycle reclaims.
 	if state < StateReady {
 		f.cleanupUpload(ctx, record)
+		// Restart the collection grace period first, so the client has the whole
+		// of it to attach the blob before the collector judges it unreferenced.
+		if err := f.blobs.MarkForCollection(ctx, record.ID, time.Now()); err != nil {
+			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+		}
 		advanced, err := f.blobs.Advance(ctx, record.ID, StateReady, nil)
 		if err != nil {
 			return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
@@ -237,6 +412,269 @@ func (f *Finalizer) Finalize(ctx context.Context, record *Blob) (blobpb.BlobStat
 	return state.ToBlobStatus(), nil
 }
 
+// findDuplicate returns the earliest settled upload, other than blob id, of the
+// bytes hashing to hash whose outcome carries over: a READY original, or one
+// rejected with a verdict on its content (see RejectionReason.IsContentVerdict),
+// even once collected. It returns nil for a nil (unrecorded) hash or when
+// nothing matches.
+func (f *Finalizer) findDuplicate(ctx context.Context, id *blobpb.BlobId, hash []byte) (*Blob, error) {
+	if hash == nil {
+		return nil, nil
+	}
+	matches, err := f.blobs.GetByContentHash(ctx, hash, duplicateCandidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		if bytes.Equal(match.ID.Value, id.Value) {
+			continue
+		}
+		if match.State == StateReady || match.Rejection != nil && match.Rejection.Reason.IsContentVerdict() {
+			return match, nil
+		}
+	}
+	return nil, nil
+}
+
+// reinspect re-derives the inspected content of a blob resumed past inspection,
+// whose decoded still is no longer in hand. The upload bytes are still present
+// (cleanup runs only at READY), so they are re-read — once, into *data — and
+// re-derived from, without the moderation samples, since the blob already passed
+// moderation.
+func (f *Finalizer) reinspect(ctx context.Context, record *Blob, data *[]byte) (*inspectedContent, error) {
+	if *data == nil {
+		fetched, err := f.fetchUploaded(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		*data = fetched
+	}
+	inspected, rejection, err := f.inspect(ctx, record, *data, false)
+	if err != nil {
+		return nil, err
+	}
+	if rejection != nil {
+		// These bytes passed inspection once; failing it now is a fault, not a
+		// verdict on the content.
+		return nil, errors.New("re-inspection of previously inspected bytes failed")
+	}
+	return inspected, nil
+}
+
+// inspect validates a blob's bytes as its content kind and derives their
+// metadata and rendition still. A non-nil rejection is a verdict on the bytes —
+// they are not servable content — while a non-nil error is a processing fault
+// the attempt should be retried over. withModerationFrames additionally samples
+// a video's moderation frames, which the blocklist screen checks too; it is off
+// when resuming past inspection.
+func (f *Finalizer) inspect(ctx context.Context, record *Blob, data []byte, withModerationFrames bool) (*inspectedContent, *RejectionMetadata, error) {
+	switch record.ContentKind() {
+	case ContentKindImage:
+		return f.inspectImage(data)
+	case ContentKindVideo:
+		return f.inspectVideo(ctx, data, withModerationFrames)
+	default:
+		return nil, nil, errors.New("unsupported content kind for inspection")
+	}
+}
+
+// inspectImage validates image bytes and derives their metadata. In sanitize
+// mode an image rejected only for its privacy metadata is stripped and inspected
+// again, and it is the stripped bytes that carry on through the pipeline.
+func (f *Finalizer) inspectImage(data []byte) (*inspectedContent, *RejectionMetadata, error) {
+	var sanitized []byte
+	inspection, err := InspectImage(data)
+	if f.sanitize && errors.Is(err, ErrImagePrivacyMetadata) {
+		sanitized, err = SanitizeImage(data)
+		if err == nil {
+			inspection, err = InspectImage(sanitized)
+		}
+		if errors.Is(err, ErrImageStripFailed) || errors.Is(err, ErrImagePrivacyMetadata) {
+			return nil, &RejectionMetadata{Reason: RejectionReasonPrivacyMetadataPresent, StripFailed: true}, nil
+		}
+	}
+	if err != nil {
+		return nil, &RejectionMetadata{Reason: rejectionReasonForInspection(err)}, nil
+	}
+
+	hash := ComputePerceptualHash(inspection.Decoded)
+	metadata := &DerivedMetadata{Image: inspection.Metadata, PerceptualHash: &hash}
+	if sanitized != nil {
+		metadata.OriginSizeBytes = uint64(len(sanitized))
+	}
+	return &inspectedContent{
+		mimeType:  inspection.MimeType,
+		metadata:  metadata,
+		still:     inspection.Decoded,
+		stillMeta: inspection.Metadata,
+		frames:    inspection.Frames,
+		sanitized: sanitized,
+	}, nil, nil
+}
+
+// inspectVideo validates video bytes from their container structure, then
+// extracts the poster frame (and, when asked, the moderation samples) and
+// derives the poster's BlurHash, which the video's metadata carries.
+func (f *Finalizer) inspectVideo(ctx context.Context, data []byte, withModerationFrames bool) (*inspectedContent, *RejectionMetadata, error) {
+	inspection, err := InspectVideo(data)
+	if err != nil {
+		return nil, &RejectionMetadata{Reason: rejectionReasonForInspection(err)}, nil
+	}
+	if f.frames == nil {
+		// Without a decoder neither moderation nor a poster is possible, so the
+		// video cannot be made servable on this deployment.
+		return nil, &RejectionMetadata{Reason: RejectionReasonUnsupportedType}, nil
+	}
+
+	duration := inspection.Metadata.Duration
+	offsets := []time.Duration{videoPosterOffset(duration)}
+	if withModerationFrames {
+		offsets = append(offsets, videoModerationOffsets(duration)...)
+	}
+	frames, err := f.frames.ExtractFrames(ctx, data, offsets)
+	if err != nil {
+		// The container parsed, so a decode failure may be the decoder's rather
+		// than the bytes'; retry, and let exhausted attempts reject as internal.
+		return nil, nil, err
+	}
+	if len(frames) != len(offsets) {
+		return nil, nil, errors.New("frame extractor returned an unexpected number of frames")
+	}
+
+	poster := frames[0]
+	bounds := poster.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 {
+		return nil, &RejectionMetadata{Reason: RejectionReasonCorrupt}, nil
+	}
+	hash, err := imageBlurhash(poster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	video := *inspection.Metadata
+	video.Blurhash = hash
+	perceptual := ComputePerceptualHash(poster)
+	return &inspectedContent{
+		mimeType: inspection.MimeType,
+		metadata: &DerivedMetadata{Video: &video, PerceptualHash: &perceptual},
+		still:    poster,
+		stillMeta: &ImageMetadata{
+			Width:    uint32(bounds.Dx()),
+			Height:   uint32(bounds.Dy()),
+			Blurhash: hash,
+		},
+		frames: frames[1:],
+	}, nil, nil
+}
+
+// screen checks the inspected content against the hash blocklist, returning the
+// blocklist rejection when its still, or any of its sampled frames, is a
+// near-duplicate of a blocked image.
+func (f *Finalizer) screen(ctx context.Context, record *Blob, content *inspectedContent) (*RejectionMetadata, error) {
+	entries, err := f.blocklist.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	hashes := []PerceptualHash{*content.metadata.PerceptualHash}
+	for _, frame := range content.frames {
+		hashes = append(hashes, ComputePerceptualHash(frame))
+	}
+	for _, hash := range hashes {
+		if entry := MatchBlocklist(entries, hash); entry != nil {
+			f.log.Info("Rejecting near-duplicate of a blocked image",
+				zap.String("blob_id", IDString(record.ID)),
+				zap.Stringer("hash", hash),
+				zap.Stringer("blocked_hash", entry.Hash),
+			)
+			return &RejectionMetadata{Reason: RejectionReasonBlocklisted}, nil
+		}
+	}
+	return nil, nil
+}
+
+// moderate classifies the inspected content, returning the moderation rejection
+// when it is flagged. A still image is moderated as a size-bounded rendering of
+// itself; an animation as its first frame plus a sample of the rest, and a video
+// as its poster plus the frames sampled across its duration — any one of which
+// flags the whole blob, and whose result is returned with the rejection. It is a
+// no-op without a moderator.
+func (f *Finalizer) moderate(ctx context.Context, data []byte, content *inspectedContent) (*RejectionMetadata, *moderation.Result, error) {
+	if f.moderator == nil {
+		return nil, nil, nil
+	}
+
+	// Moderate size-bounded renderings, not the full-resolution original:
+	// provider sync endpoints are tuned for small images and cap payload size,
+	// and full resolution adds nothing to classification.
+	var payloads [][]byte
+	if meta := content.metadata.Image; meta != nil && !meta.Animated {
+		payload, err := moderationPayload(data, content.still)
+		if err != nil {
+			return nil, nil, err
+		}
+		payloads = append(payloads, payload)
+	} else {
+		for _, frame := range append([]image.Image{content.still}, content.frames...) {
+			payload, err := encodeWithinBudget(frame, moderationMaxBytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			payloads = append(payloads, payload)
+		}
+	}
+
+	for _, payload := range payloads {
+		result, err := f.moderator.ClassifyImage(ctx, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		if result.Flagged {
+			return &RejectionMetadata{
+				Reason:          RejectionReasonModeration,
+				FlaggedCategory: moderation.HighestFlaggedCategory(result),
+			}, result, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// flag returns the audit record of result flagging record's upload, data, or nil
+// without a moderation audit.
+func (f *Finalizer) flag(record *Blob, classifier string, data []byte, result *moderation.Result) *moderation.AuditRecord {
+	if f.audit == nil {
+		return nil
+	}
+	flag := moderation.NewAuditRecord(record.Owner, moderation.MethodImage, classifier, data, result)
+	flag.BlobID = record.ID
+	return flag
+}
+
+// duplicateFlag returns the audit record of record's upload, data, rejected as a
+// duplicate of the moderation-rejected duplicate. It carries the categories and
+// scores the duplicate was flagged with, when its own record can be found.
+func (f *Finalizer) duplicateFlag(ctx context.Context, record *Blob, data []byte, duplicate *Blob) *moderation.AuditRecord {
+	if f.audit == nil {
+		return nil
+	}
+	result := &moderation.Result{Flagged: true}
+	earlier, err := f.audit.GetAuditRecordByBlob(ctx, duplicate.ID)
+	if err == nil {
+		result.FlaggedCategories = earlier.FlaggedCategories
+		result.CategoryScores = earlier.CategoryScores
+	} else if !errors.Is(err, moderation.ErrAuditRecordNotFound) {
+		f.log.Warn("Failed to get moderation audit record of duplicate",
+			zap.String("blob_id", IDString(record.ID)),
+			zap.String("duplicate_of", IDString(duplicate.ID)),
+			zap.Error(err),
+		)
+	}
+	return f.flag(record, duplicateClassifier, data, result)
+}
+
 // Fail terminally rejects a blob whose finalization attempts are exhausted, so
 // the client sees a definitive (internal) rejection instead of an eternal
 // PROCESSING. It is idempotent: a blob that reached a terminal state first keeps
@@ -253,7 +691,8 @@ func (f *Finalizer) Fail(ctx context.Context, id *blobpb.BlobId) error {
 }
 
 // generateImageRenditions derives every rung of the IMAGE rendition ladder from
-// the decoded original and stores each as its own READY blob: it scales and
+// the decoded still — an image original itself, or a video's poster frame — and
+// stores each as its own READY blob: it scales and
 // encodes the bytes, writes them straight into the origin store, and records a
 // child blob whose ParentID points back at the original. meta supplies the
 // original's persisted dimensions (so the derivation matches what the read path
@@ -266,6 +705,10 @@ func (f *Finalizer) Fail(ctx context.Context, id *blobpb.BlobId) error {
 // id — overwriting the same object and treating an already-present record as the
 // prior attempt to finish advancing — instead of orphaning a duplicate.
 //
+// source is the manifest of an earlier upload of the same bytes, if any. A rung
+// it already holds is copied from it rather than derived, and only a rung it
+// lacks (or whose bytes have since been collected) is derived afresh.
+//
 // The original is never upscaled. Within a role, the first rung whose bound reaches
 // the original's longest side still yields ONE rendition, encoded at the original's
 // own size and typed as that rung's role: even when its dimensions match the
@@ -274,8 +717,8 @@ func (f *Finalizer) Fail(ctx context.Context, id *blobpb.BlobId) error {
 // to reach for at that role. Every larger rung of that SAME role would bound the same
 // size to the same bytes, so they are skipped — but the ladder keeps climbing, so
 // every role in it lands in the manifest for an image of any size.
-func (f *Finalizer) generateImageRenditions(ctx context.Context, parent *Blob, decoded image.Image, meta *ImageMetadata) error {
-	plans := planImageRenditions(meta)
+func (f *Finalizer) generateImageRenditions(ctx context.Context, parent *Blob, decoded image.Image, meta *ImageMetadata, source []RenditionRef) error {
+	plans := planImageRenditions(meta, f.avif != nil)
 
 	// Each planned rung derives, stores, and records independently — its output
 	// spec was fully resolved by the plan — so the expensive part (resample +
@@ -292,7 +735,14 @@ func (f *Finalizer) generateImageRenditions(ctx context.Context, parent *Blob, d
 	eg, egCtx := errgroup.WithContext(ctx)
 	for i, plan := range plans {
 		eg.Go(func() error {
-			child, err := f.generateImageRendition(egCtx, parent, decoded, meta, plan)
+			var child *Blob
+			var err error
+			if ref, ok := matchingRendition(source, plan); ok {
+				child, err = f.copyImageRendition(egCtx, parent, ref, plan)
+			}
+			if child == nil && err == nil {
+				child, err = f.generateImageRendition(egCtx, parent, decoded, meta, plan)
+			}
 			if err != nil {
 				return err
 			}
@@ -322,9 +772,23 @@ type imageRenditionPlan struct {
 	encoding  imageEncoding
 }
 
+// id is the deterministic id of the rendition the plan derives from the given
+// original.
+func (p imageRenditionPlan) id(parentID *blobpb.BlobId) *blobpb.BlobId {
+	return imageRenditionID(parentID, p.rendition, p.width, p.height, p.encoding)
+}
+
 // planImageRenditions resolves which rungs of the IMAGE ladder an original
-// yields, in ladder order. It is a pure function of the original's metadata.
-func planImageRenditions(meta *ImageMetadata) []imageRenditionPlan {
+// yields, in ladder order. It is a pure function of the original's metadata and
+// whether AVIF renditions are enabled.
+//
+// An animated original yields only its THUMBNAIL rungs, static renderings of the
+// first frame: a DISPLAY rendition would freeze the animation, so the original
+// itself is what a client displays.
+//
+// With avif set, each rung of an opaque original is planned twice: its WebP
+// rendition, immediately followed by an AVIF sibling at the same dimensions.
+func planImageRenditions(meta *ImageMetadata, avif bool) []imageRenditionPlan {
 	// Roles whose largest useful rendition has already been planned — see the
 	// reachedOriginal write below.
 	coveredRoles := make(map[RenditionType]bool)
@@ -341,6 +805,9 @@ func planImageRenditions(meta *ImageMetadata) []imageRenditionPlan {
 		if coveredRoles[spec.Rendition] {
 			continue
 		}
+		if meta.Animated && spec.Rendition != RenditionThumbnail {
+			continue
+		}
 
 		width, height := scaledDimensions(meta.Width, meta.Height, spec.MaxLongestSide)
 		plans = append(plans, imageRenditionPlan{
@@ -349,6 +816,14 @@ func planImageRenditions(meta *ImageMetadata) []imageRenditionPlan {
 			height:    height,
 			encoding:  imageEncodingFor(spec.Rendition, meta.HasAlpha),
 		})
+		if avif && !meta.HasAlpha {
+			plans = append(plans, imageRenditionPlan{
+				rendition: spec.Rendition,
+				width:     width,
+				height:    height,
+				encoding:  avifEncodingFor(spec.Rendition),
+			})
+		}
 
 		// The first rung of a role whose bound is at or above the original's longest side
 		// is the "next" rung the original doesn't exceed. It was planned at the original's
@@ -365,13 +840,13 @@ func planImageRenditions(meta *ImageMetadata) []imageRenditionPlan {
 // encodes the original's decoded bytes per the plan, writes them into the
 // origin store, and records the READY child blob, which it returns.
 func (f *Finalizer) generateImageRendition(ctx context.Context, parent *Blob, decoded image.Image, meta *ImageMetadata, plan imageRenditionPlan) (*Blob, error) {
-	id := imageRenditionID(parent.ID, plan.rendition, plan.width, plan.height, plan.encoding)
-	key, err := imageRenditionStorageKey(parent.ID, plan.rendition, plan.width, plan.height, plan.encoding.mimeType)
+	id := plan.id(parent.ID)
+	key, err := imageRenditionStorageKey(parent.ContentKind(), parent.ID, plan.rendition, plan.width, plan.height, plan.encoding.mimeType)
 	if err != nil {
 		return nil, err
 	}
 
-	encoded, err := plan.encoding.encode(resampleImage(decoded, int(plan.width), int(plan.height)))
+	encoded, err := f.encodeRendition(ctx, plan.encoding, resampleImage(decoded, int(plan.width), int(plan.height)))
 	if err != nil {
 		return nil, err
 	}
@@ -403,7 +878,72 @@ func (f *Finalizer) generateImageRendition(ctx context.Context, parent *Blob, de
 	if err := f.blobs.CreatePending(ctx, child); err != nil && !errors.Is(err, ErrExists) {
 		return nil, err
 	}
-	if _, err := f.blobs.Advance(ctx, id, StateReady, child.Image); err != nil {
+	if _, err := f.blobs.Advance(ctx, id, StateReady, &DerivedMetadata{Image: child.Image}); err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// matchingRendition returns the entry of source that a plan would derive: the
+// same role, dimensions, and output type.
+func matchingRendition(source []RenditionRef, plan imageRenditionPlan) (RenditionRef, bool) {
+	for _, ref := range source {
+		if ref.Rendition == plan.rendition && ref.MimeType == plan.encoding.mimeType &&
+			ref.Image != nil && ref.Image.Width == plan.width && ref.Image.Height == plan.height {
+			return ref, true
+		}
+	}
+	return RenditionRef{}, false
+}
+
+// encodeRendition renders a rendition's scaled pixels in the given encoding: AVIF
+// through the configured encoder, anything else natively.
+func (f *Finalizer) encodeRendition(ctx context.Context, encoding imageEncoding, img image.Image) ([]byte, error) {
+	if encoding.mimeType != avifMimeType {
+		return encoding.encode(img)
+	}
+	if f.avif == nil {
+		return nil, errors.New("no avif encoder configured")
+	}
+	return f.avif.EncodeAVIF(ctx, img, encoding.quality)
+}
+
+// copyImageRendition is generateImageRendition for a rung an earlier upload of
+// the same bytes already derived: it copies that rendition's bytes under
+// parent's own key and records the READY child blob from its metadata. It
+// returns a nil child with a nil error when the source's bytes are gone
+// (collected since it was matched), for the caller to derive the rung instead.
+func (f *Finalizer) copyImageRendition(ctx context.Context, parent *Blob, ref RenditionRef, plan imageRenditionPlan) (*Blob, error) {
+	id := plan.id(parent.ID)
+	key, err := imageRenditionStorageKey(parent.ContentKind(), parent.ID, plan.rendition, plan.width, plan.height, plan.encoding.mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bytes before record, as when deriving; CopyOrigin overwrites, so this is
+	// replay-safe too.
+	if err := f.storage.CopyOrigin(ctx, ref.StorageKey, key); errors.Is(err, ErrObjectNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	image := *ref.Image
+	child := &Blob{
+		ID:         id,
+		Rendition:  plan.rendition,
+		ParentID:   parent.ID,
+		Owner:      parent.Owner,
+		State:      StatePending,
+		StorageKey: key,
+		MimeType:   ref.MimeType,
+		SizeBytes:  ref.SizeBytes,
+		Image:      &image,
+	}
+	if err := f.blobs.CreatePending(ctx, child); err != nil && !errors.Is(err, ErrExists) {
+		return nil, err
+	}
+	if _, err := f.blobs.Advance(ctx, id, StateReady, &DerivedMetadata{Image: child.Image}); err != nil {
 		return nil, err
 	}
 	return child, nil
@@ -422,6 +962,18 @@ func (f *Finalizer) fetchUploaded(ctx context.Context, record *Blob) ([]byte, er
 }
 
 func (f *Finalizer) reject(ctx context.Context, record *Blob, rejection *RejectionMetadata) (blobpb.BlobStatus, error) {
+	return f.rejectFlagged(ctx, record, rejection, nil)
+}
+
+// rejectFlagged rejects a blob as reject does, recording flag to the moderation
+// audit if it performed the rejection. Recording is best-effort: the rejection
+// stands regardless, so a failure is logged, not surfaced.
+func (f *Finalizer) rejectFlagged(ctx context.Context, record *Blob, rejection *RejectionMetadata, flag *moderation.AuditRecord) (blobpb.BlobStatus, error) {
+	// Restart the collection grace period first, so the rejection reason stays
+	// readable for the whole of it before the collector reclaims the blob.
+	if err := f.blobs.MarkForCollection(ctx, record.ID, time.Now()); err != nil {
+		return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
+	}
 	advanced, err := f.blobs.Reject(ctx, record.ID, rejection)
 	if err != nil {
 		return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN, err
@@ -431,24 +983,36 @@ func (f *Finalizer) reject(ctx context.Context, record *Blob, rejection *Rejecti
 		// was actually committed rather than asserting REJECTED over it.
 		return f.currentStatus(ctx, record.ID)
 	}
+	if flag != nil {
+		if err := f.audit.PutAuditRecord(ctx, flag); err != nil {
+			f.log.Warn("Failed to record moderation audit record",
+				zap.String("blob_id", IDString(record.ID)),
+				zap.Error(err),
+			)
+		}
+	}
 	// Drop the rejected bytes from the upload store; they are never promoted.
-	f.cleanupUpload(ctx, record)
+	// Those of a moderation rejection are kept for an appeal to reinstate until
+	// the collector reclaims them, if rejections are audited at all.
+	if f.audit == nil || rejection.Reason != RejectionReasonModeration {
+		f.cleanupUpload(ctx, record)
+	}
 	return blobpb.BlobStatus_BLOB_STATUS_REJECTED, nil
 }
 
-// rejectionReasonForInspection classifies an InspectImage failure into the
-// rejection reason it should be recorded under. The byte-level validation
+// rejectionReasonForInspection classifies an InspectImage or InspectVideo
+// failure into the rejection reason it should be recorded under. The byte-level validation
 // failures are wrapped with sentinels; anything else (e.g. a downstream
 // processing fault) is reported as internal.
 func rejectionReasonForInspection(err error) RejectionReason {
 	switch {
-	case errors.Is(err, ErrImageUnsupportedType):
+	case errors.Is(err, ErrImageUnsupportedType), errors.Is(err, ErrVideoUnsupportedType):
 		return RejectionReasonUnsupportedType
-	case errors.Is(err, ErrImageTooLarge):
+	case errors.Is(err, ErrImageTooLarge), errors.Is(err, ErrVideoTooLarge):
 		return RejectionReasonTooLarge
-	case errors.Is(err, ErrImageCorrupt):
+	case errors.Is(err, ErrImageCorrupt), errors.Is(err, ErrVideoCorrupt):
 		return RejectionReasonCorrupt
-	case errors.Is(err, ErrImagePrivacyMetadata):
+	case errors.Is(err, ErrImagePrivacyMetadata), errors.Is(err, ErrVideoPrivacyMetadata):
 		return RejectionReasonPrivacyMetadataPresent
 	default:
 		return RejectionReasonInternal
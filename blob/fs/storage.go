@@ -0,0 +1,720 @@
+// Package fs implements blob.ObjectStorage on the local filesystem, for
+// self-hosted deployments and integration tests that want the real upload path
+// without AWS. It mirrors the two-bucket S3 layout with two directories: clients
+// POST bytes to an HMAC-signed upload URL served by the package's own HTTP
+// handler (see Storage.Handler), which enforces the declared content type and
+// exact size the way an S3 POST policy does; the server reads them back to
+// validate them, and validated bytes are copied into the ORIGIN directory, which
+// the same handler serves through expiring signed download URLs. A multipart
+// upload's parts are PUT to signed part URLs instead, and assembled into the
+// UPLOAD directory on completion. As with S3, the server process never proxies
+// bytes through its RPCs — the handler stands in for the bucket and the CDN.
+package fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+const (
+	// uploadPath and downloadPath are where Handler serves uploads and downloads,
+	// relative to the BaseURL path.
+	uploadPath     = "/upload"
+	uploadPartPath = "/upload-part"
+	downloadPath   = "/objects/"
+
+	// contentTypeSuffix names the sidecar file pinning an object's content type,
+	// which a download is served as. Object keys always end in a media extension,
+	// so a sidecar can never collide with an object.
+	contentTypeSuffix = ".content-type"
+
+	// multipartDir is the directory, beside an item's objects in the UPLOAD
+	// directory, its in-progress multipart uploads keep their parts in: one
+	// subdirectory per upload, holding a file per part and the pinned content
+	// type. Living under the item's prefix, it goes with the item when collected.
+	multipartDir         = ".multipart"
+	multipartContentType = "content-type"
+
+	// uploadIDBytes is the size of a multipart upload's random id.
+	uploadIDBytes = 16
+
+	// The upload form fields, matching the S3 POST form where one exists so a
+	// client posts to either backend the same way: every field of the target's
+	// FormFields, then the bytes as the final "file" part.
+	fieldKey         = "key"
+	fieldContentType = "Content-Type"
+	fieldSize        = "size"
+	fieldExpires     = "expires"
+	fieldSignature   = "signature"
+	fieldFile        = "file"
+
+	// The part URL's query parameters, beside key, size, expires and signature.
+	fieldUploadID   = "upload_id"
+	fieldPartNumber = "part"
+
+	// maxFormFields and maxFieldBytes bound the non-file parts of an upload form,
+	// which are read before the signature is checked.
+	maxFormFields = 16
+	maxFieldBytes = 4 << 10
+
+	// minSigningKeyBytes is the shortest HMAC key accepted: the SHA-256 block
+	// output size, below which the key is weaker than the MAC.
+	minSigningKeyBytes = 32
+)
+
+// Config configures the filesystem backend.
+type Config struct {
+	// UploadDir is the directory clients' uploads land in. It holds untrusted,
+	// not-yet-validated bytes.
+	UploadDir string
+
+	// OriginDir is the directory downloads are served from. Only validated bytes,
+	// promoted out of UploadDir, ever land here.
+	OriginDir string
+
+	// BaseURL is the public URL (scheme, host, and optional path) Handler is
+	// reachable at, e.g. "https://media.example.com/blobs". Upload and download
+	// URLs are minted under it.
+	BaseURL string
+
+	// SigningKey is the HMAC-SHA256 key upload and download URLs are signed with.
+	// It must be at least 32 bytes, and stay secret: anyone holding it can mint
+	// URLs for any key.
+	SigningKey []byte
+
+	// UploadTTL is how long a presigned upload stays valid.
+	UploadTTL time.Duration
+
+	// DownloadTTL is how long a minted download URL stays valid.
+	DownloadTTL time.Duration
+}
+
+// Storage is the filesystem implementation of blob.ObjectStorage.
+type Storage struct {
+	cfg      Config
+	baseURL  string
+	basePath string
+
+	// now is the clock URLs are minted and checked against; a test seam.
+	now func() time.Time
+}
+
+// NewStorage returns a Storage over the configured directories, creating them if
+// they do not exist.
+func NewStorage(cfg Config) (*Storage, error) {
+	if len(cfg.SigningKey) < minSigningKeyBytes {
+		return nil, fmt.Errorf("signing key must be at least %d bytes", minSigningKeyBytes)
+	}
+	if cfg.UploadDir == "" || cfg.OriginDir == "" {
+		return nil, errors.New("upload and origin directories are required")
+	}
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("base url %q must be absolute", cfg.BaseURL)
+	}
+	for _, dir := range []string{cfg.UploadDir, cfg.OriginDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+	return &Storage{
+		cfg:      cfg,
+		baseURL:  strings.TrimRight(cfg.BaseURL, "/"),
+		basePath: strings.TrimRight(base.Path, "/"),
+		now:      time.Now,
+	}, nil
+}
+
+func (s *Storage) PresignUpload(_ context.Context, key, mimeType string, sizeBytes uint64) (*blobpb.UploadTarget, error) {
+	if _, err := objectPath(s.cfg.UploadDir, key); err != nil {
+		return nil, err
+	}
+	expiresAt := s.now().Add(s.cfg.UploadTTL)
+	size := strconv.FormatUint(sizeBytes, 10)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	// The signature is the policy: it pins the key, the Content-Type, and the
+	// exact size, so changing any of the fields invalidates the upload.
+	return &blobpb.UploadTarget{
+		Method: blobpb.UploadTarget_POST,
+		Url:    s.baseURL + uploadPath,
+		FormFields: map[string]string{
+			fieldKey:         key,
+			fieldContentType: mimeType,
+			fieldSize:        size,
+			fieldExpires:     expires,
+			fieldSignature:   s.sign("upload", key, mimeType, size, expires),
+		},
+		ExpiresAt: timestamppb.New(time.Unix(expiresAt.Unix(), 0)),
+	}, nil
+}
+
+func (s *Storage) CreateMultipartUpload(_ context.Context, key, mimeType string) (string, error) {
+	var raw [uploadIDBytes]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(raw[:])
+	dir, err := s.partsDir(key, uploadID)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, multipartContentType), strings.NewReader(mimeType)); err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+func (s *Storage) PresignUploadPart(_ context.Context, key, uploadID string, partNumber int, sizeBytes uint64) (*blobpb.UploadTarget, error) {
+	if _, err := s.partsDir(key, uploadID); err != nil {
+		return nil, err
+	}
+	expiresAt := s.now().Add(s.cfg.UploadTTL)
+	part := strconv.Itoa(partNumber)
+	size := strconv.FormatUint(sizeBytes, 10)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	// As with a whole upload, the signature pins the part to its upload, its
+	// position, and its exact size.
+	query := url.Values{
+		fieldKey:        {key},
+		fieldUploadID:   {uploadID},
+		fieldPartNumber: {part},
+		fieldSize:       {size},
+		fieldExpires:    {expires},
+		fieldSignature:  {s.sign("upload-part", key, uploadID, part, size, expires)},
+	}
+	return &blobpb.UploadTarget{
+		Method:    blobpb.UploadTarget_PUT,
+		Url:       s.baseURL + uploadPartPath + "?" + query.Encode(),
+		Headers:   map[string]string{"Content-Length": size},
+		ExpiresAt: timestamppb.New(time.Unix(expiresAt.Unix(), 0)),
+	}, nil
+}
+
+func (s *Storage) CompleteMultipartUpload(_ context.Context, key, uploadID string, parts int) error {
+	dir, err := s.partsDir(key, uploadID)
+	if err != nil {
+		return err
+	}
+	path, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		return err
+	}
+	mimeType, err := os.ReadFile(filepath.Join(dir, multipartContentType))
+	if errors.Is(err, os.ErrNotExist) {
+		// Assembled by an earlier call (which removed the parts), or never
+		// started.
+		if _, statErr := os.Stat(path); statErr == nil {
+			return nil
+		}
+		return blob.ErrUploadIncomplete
+	} else if err != nil {
+		return fmt.Errorf("failed to read multipart content type: %w", err)
+	}
+
+	readers := make([]io.Reader, 0, parts)
+	for partNumber := 1; partNumber <= parts; partNumber++ {
+		f, err := os.Open(filepath.Join(dir, strconv.Itoa(partNumber)))
+		if errors.Is(err, os.ErrNotExist) {
+			return blob.ErrUploadIncomplete
+		} else if err != nil {
+			return fmt.Errorf("failed to open upload part: %w", err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	// The content type lands first, so an upload is never visible without it.
+	if err := writeFileAtomic(path+contentTypeSuffix, bytes.NewReader(mimeType)); err != nil {
+		return fmt.Errorf("failed to write upload content type: %w", err)
+	}
+	if err := writeFileAtomic(path, io.MultiReader(readers...)); err != nil {
+		return fmt.Errorf("failed to assemble multipart upload: %w", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove upload parts: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) AbortMultipartUpload(_ context.Context, key, uploadID string) error {
+	dir, err := s.partsDir(key, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove upload parts: %w", err)
+	}
+	return nil
+}
+
+// partsDir is the directory a multipart upload of the key keeps its parts in,
+// refusing an upload id this Storage could not have minted.
+func (s *Storage) partsDir(key, uploadID string) (string, error) {
+	path, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		return "", err
+	}
+	if raw, err := hex.DecodeString(uploadID); err != nil || len(raw) != uploadIDBytes {
+		return "", fmt.Errorf("invalid upload id %q", uploadID)
+	}
+	return filepath.Join(filepath.Dir(path), multipartDir, uploadID), nil
+}
+
+func (s *Storage) GetUploaded(_ context.Context, key string) ([]byte, error) {
+	path, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, blob.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploaded object: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Storage) UploadExists(_ context.Context, key string) (bool, error) {
+	path, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat uploaded object: %w", err)
+	}
+	return true, nil
+}
+
+func (s *Storage) CopyToOrigin(ctx context.Context, key string) error {
+	data, err := s.GetUploaded(ctx, key)
+	if errors.Is(err, blob.ErrObjectNotFound) {
+		// A repeated finalization may copy after the upload was already cleaned
+		// up; if the object is already served, that is a no-op success.
+		if path, pathErr := objectPath(s.cfg.OriginDir, key); pathErr == nil {
+			if _, statErr := os.Stat(path); statErr == nil {
+				return nil
+			}
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	uploaded, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		return err
+	}
+	mimeType, err := os.ReadFile(uploaded + contentTypeSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read uploaded content type: %w", err)
+	}
+	return s.PutOrigin(ctx, key, string(mimeType), data)
+}
+
+func (s *Storage) PutOrigin(_ context.Context, key, mimeType string, data []byte) error {
+	path, err := objectPath(s.cfg.OriginDir, key)
+	if err != nil {
+		return err
+	}
+	// The content type lands first, so an object is never visible without it.
+	if err := writeFileAtomic(path+contentTypeSuffix, strings.NewReader(mimeType)); err != nil {
+		return fmt.Errorf("failed to write origin content type: %w", err)
+	}
+	if err := writeFileAtomic(path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write origin object: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetOrigin(_ context.Context, key string) ([]byte, error) {
+	path, err := objectPath(s.cfg.OriginDir, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, blob.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read origin object: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Storage) CopyOrigin(ctx context.Context, srcKey, dstKey string) error {
+	src, err := objectPath(s.cfg.OriginDir, srcKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return blob.ErrObjectNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to read origin object: %w", err)
+	}
+	mimeType, err := os.ReadFile(src + contentTypeSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read origin content type: %w", err)
+	}
+	return s.PutOrigin(ctx, dstKey, string(mimeType), data)
+}
+
+func (s *Storage) DeleteUpload(_ context.Context, key string) error {
+	path, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{path, path + contentTypeSuffix} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete uploaded object: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeletePrefix removes a directory of objects from both stores. Objects are
+// files, so only a directory prefix (ending in "/") is supported — the per-item
+// prefix the garbage collector passes always is one.
+func (s *Storage) DeletePrefix(_ context.Context, prefix string) error {
+	dir := strings.TrimSuffix(prefix, "/")
+	if !strings.HasSuffix(prefix, "/") || dir == "" || !filepath.IsLocal(filepath.FromSlash(dir)) {
+		return fmt.Errorf("invalid object prefix %q", prefix)
+	}
+	for _, root := range []string{s.cfg.UploadDir, s.cfg.OriginDir} {
+		if err := os.RemoveAll(filepath.Join(root, filepath.FromSlash(dir))); err != nil {
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+	}
+	return nil
+}
+
+// InvalidateCDN is a no-op: objects are served straight off the origin
+// directory, with no CDN in front to hold a copy.
+func (s *Storage) InvalidateCDN(_ context.Context, prefix string) error {
+	if prefix == "" {
+		return errors.New("empty object prefix")
+	}
+	return nil
+}
+
+func (s *Storage) SignDownloadURL(_ context.Context, key string) (*blobpb.DownloadUrl, error) {
+	if _, err := objectPath(s.cfg.OriginDir, key); err != nil {
+		return nil, err
+	}
+	expiresAt := s.now().Add(s.cfg.DownloadTTL)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{
+		fieldExpires:   {expires},
+		fieldSignature: {s.sign("download", key, expires)},
+	}
+	return &blobpb.DownloadUrl{
+		Url:       s.baseURL + downloadPath + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(),
+		ExpiresAt: timestamppb.New(time.Unix(expiresAt.Unix(), 0)),
+	}, nil
+}
+
+// Handler serves the upload and download URLs this Storage mints. It must be
+// reachable at Config.BaseURL, with the base path left on the request (it is
+// matched here rather than stripped by the caller).
+func (s *Storage) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.basePath+uploadPath, s.handleUpload)
+	mux.HandleFunc(s.basePath+uploadPartPath, s.handleUploadPart)
+	mux.HandleFunc(s.basePath+downloadPath, s.handleDownload)
+	return mux
+}
+
+// handleUpload accepts a multipart POST of a presigned target's form fields
+// followed by the bytes. Like S3 it answers 403 for a policy the request does not
+// satisfy (a bad signature or an expired target) and 400 for a malformed form or
+// a body that is not exactly the declared size; nothing is stored unless the
+// whole upload is accepted.
+func (s *Storage) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	// The form fields precede the file; read them, bounded, until it starts.
+	fields := make(map[string]string)
+	var file io.Reader
+	for file == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "malformed multipart form", http.StatusBadRequest)
+			return
+		}
+		if part.FormName() == fieldFile {
+			file = part
+			continue
+		}
+		if len(fields) >= maxFormFields {
+			http.Error(w, "too many form fields", http.StatusBadRequest)
+			return
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldBytes+1))
+		if err != nil || len(value) > maxFieldBytes {
+			http.Error(w, "malformed form field", http.StatusBadRequest)
+			return
+		}
+		fields[part.FormName()] = string(value)
+	}
+
+	key, mimeType := fields[fieldKey], fields[fieldContentType]
+	size, expires := fields[fieldSize], fields[fieldExpires]
+	if !s.verify(fields[fieldSignature], "upload", key, mimeType, size, expires) || s.expired(expires) {
+		http.Error(w, "invalid or expired upload policy", http.StatusForbidden)
+		return
+	}
+	sizeBytes, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || sizeBytes < 0 {
+		http.Error(w, "invalid size", http.StatusBadRequest)
+		return
+	}
+	path, err := objectPath(s.cfg.UploadDir, key)
+	if err != nil {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	// The content type lands first, so an upload is never visible without it.
+	if err := writeFileAtomic(path+contentTypeSuffix, strings.NewReader(mimeType)); err != nil {
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	// Read one byte past the declared size, so an oversize body is caught without
+	// buffering it, then check the body ended exactly at the declared size.
+	body := &countingReader{r: io.LimitReader(file, sizeBytes+1)}
+	if err := writeFileAtomicChecked(path, body, func() bool { return body.n == sizeBytes }); err != nil {
+		// Leave no orphaned sidecar behind, unless an earlier upload of the same
+		// key already landed and still needs it.
+		if _, statErr := os.Stat(path); errors.Is(statErr, os.ErrNotExist) {
+			_ = os.Remove(path + contentTypeSuffix)
+		}
+		if errors.Is(err, errSizeMismatch) {
+			http.Error(w, "body does not match the declared size", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUploadPart accepts a PUT of one part of a multipart upload to a signed
+// part URL. It answers like handleUpload: 403 for a bad or expired signature, 400
+// for a body that is not exactly the part's size, and 404 for an upload that is
+// no longer in progress. A part uploaded again replaces the earlier upload of it.
+func (s *Storage) handleUploadPart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	key, uploadID, part := query.Get(fieldKey), query.Get(fieldUploadID), query.Get(fieldPartNumber)
+	size, expires := query.Get(fieldSize), query.Get(fieldExpires)
+	if !s.verify(query.Get(fieldSignature), "upload-part", key, uploadID, part, size, expires) || s.expired(expires) {
+		http.Error(w, "invalid or expired upload part url", http.StatusForbidden)
+		return
+	}
+	partNumber, err := strconv.Atoi(part)
+	if err != nil || partNumber < 1 {
+		http.Error(w, "invalid part number", http.StatusBadRequest)
+		return
+	}
+	sizeBytes, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || sizeBytes < 0 {
+		http.Error(w, "invalid size", http.StatusBadRequest)
+		return
+	}
+	dir, err := s.partsDir(key, uploadID)
+	if err != nil {
+		http.Error(w, "invalid upload", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, multipartContentType)); err != nil {
+		http.Error(w, "no such upload", http.StatusNotFound)
+		return
+	}
+
+	body := &countingReader{r: io.LimitReader(r.Body, sizeBytes+1)}
+	if err := writeFileAtomicChecked(filepath.Join(dir, strconv.Itoa(partNumber)), body, func() bool { return body.n == sizeBytes }); err != nil {
+		if errors.Is(err, errSizeMismatch) {
+			http.Error(w, "body does not match the declared size", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to store upload part", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDownload serves a promoted object to a request bearing a valid,
+// unexpired download signature, as the content type it was stored with.
+func (s *Storage) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, s.basePath+downloadPath)
+	query := r.URL.Query()
+	expires := query.Get(fieldExpires)
+	if !s.verify(query.Get(fieldSignature), "download", key, expires) || s.expired(expires) {
+		http.Error(w, "invalid or expired download url", http.StatusForbidden)
+		return
+	}
+	path, err := objectPath(s.cfg.OriginDir, key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	mimeType, err := os.ReadFile(path + contentTypeSuffix)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "failed to read object", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", string(mimeType))
+	// An object's bytes are immutable, but the URL expires; let caches hold it no
+	// longer than that.
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(s.remaining(expires).Seconds())))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// sign returns the hex HMAC-SHA256 of the purpose-scoped, NUL-joined fields, so
+// an upload signature can never be replayed as a download one or vice versa.
+func (s *Storage) sign(fields ...string) string {
+	mac := hmac.New(sha256.New, s.cfg.SigningKey)
+	mac.Write([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether signature is the valid signature over fields, in
+// constant time.
+func (s *Storage) verify(signature string, fields ...string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(s.sign(fields...))
+	return hmac.Equal(got, want)
+}
+
+// expired reports whether a Unix-seconds expiry has passed, or does not parse.
+func (s *Storage) expired(expires string) bool {
+	return s.remaining(expires) <= 0
+}
+
+func (s *Storage) remaining(expires string) time.Duration {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Unix(unix, 0).Sub(s.now())
+}
+
+// objectPath maps an object key to its file under root, refusing any key that
+// would escape it or that names a content-type sidecar.
+func objectPath(root, key string) (string, error) {
+	local := filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(local) || strings.HasSuffix(key, contentTypeSuffix) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(root, local), nil
+}
+
+// errSizeMismatch is writeFileAtomicChecked's report that its check failed.
+var errSizeMismatch = errors.New("size mismatch")
+
+// writeFileAtomic writes r to path through a temporary file renamed into place,
+// so a reader never observes a partial object.
+func writeFileAtomic(path string, r io.Reader) error {
+	return writeFileAtomicChecked(path, r, func() bool { return true })
+}
+
+// writeFileAtomicChecked is writeFileAtomic, discarding the write unless ok
+// reports true once r is drained.
+func writeFileAtomicChecked(path string, r io.Reader, ok func() bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !ok() {
+		return errSizeMismatch
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,366 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	storage, err := NewStorage(Config{
+		UploadDir:   t.TempDir(),
+		OriginDir:   t.TempDir(),
+		BaseURL:     server.URL + "/blobs",
+		SigningKey:  bytes.Repeat([]byte{0x42}, 32),
+		UploadTTL:   15 * time.Minute,
+		DownloadTTL: 15 * time.Minute,
+	})
+	require.NoError(t, err)
+	mux.Handle("/", storage.Handler())
+	return storage
+}
+
+// post uploads data to target the way a client does, with fields overriding the
+// target's own, and returns the response status.
+func post(t *testing.T, target *blobpb.UploadTarget, data []byte, fields map[string]string) int {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range target.FormFields {
+		if override, ok := fields[name]; ok {
+			value = override
+		}
+		require.NoError(t, form.WriteField(name, value))
+	}
+	if data != nil {
+		file, err := form.CreateFormFile("file", "upload")
+		require.NoError(t, err)
+		_, err = file.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, form.Close())
+
+	resp, err := http.Post(target.Url, form.FormDataContentType(), &body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// put uploads a part to target the way a client does, and returns the response
+// status.
+func put(t *testing.T, target *blobpb.UploadTarget, data []byte) int {
+	req, err := http.NewRequest(target.Method.String(), target.Url, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func get(t *testing.T, rawURL string) (int, string, []byte) {
+	resp, err := http.Get(rawURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, resp.Header.Get("Content-Type"), body
+}
+
+func TestUploadPromoteDownload(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	key := "images/0b7e4a0e-3f6b-4c1e-9d57-6a1f7f0c1d2e/original.png"
+	data := []byte("not really a png, but the storage does not care")
+
+	target, err := storage.PresignUpload(ctx, key, "image/png", uint64(len(data)))
+	require.NoError(t, err)
+	require.Equal(t, blobpb.UploadTarget_POST, target.Method)
+
+	exists, err := storage.UploadExists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = storage.GetUploaded(ctx, key)
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+
+	require.Equal(t, http.StatusNoContent, post(t, target, data, nil))
+
+	exists, err = storage.UploadExists(ctx, key)
+	require.NoError(t, err)
+	require.True(t, exists)
+	uploaded, err := storage.GetUploaded(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, data, uploaded)
+
+	// Nothing is served until it is promoted.
+	download, err := storage.SignDownloadURL(ctx, key)
+	require.NoError(t, err)
+	status, _, _ := get(t, download.Url)
+	require.Equal(t, http.StatusNotFound, status)
+
+	require.NoError(t, storage.CopyToOrigin(ctx, key))
+	status, contentType, body := get(t, download.Url)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "image/png", contentType)
+	require.Equal(t, data, body)
+
+	// Cleanup removes the upload; a replayed copy is then a no-op.
+	require.NoError(t, storage.DeleteUpload(ctx, key))
+	require.NoError(t, storage.DeleteUpload(ctx, key))
+	_, err = storage.GetUploaded(ctx, key)
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+	require.NoError(t, storage.CopyToOrigin(ctx, key))
+}
+
+func TestUploadEnforcesPolicy(t *testing.T) {
+	ctx := context.Background()
+	data := []byte("exactly these bytes")
+
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		fields   map[string]string
+		clock    time.Duration // how far past minting the upload is made
+		expected int
+	}{
+		{name: "short body", data: data[:5], expected: http.StatusBadRequest},
+		{name: "long body", data: append(data, '!'), expected: http.StatusBadRequest},
+		{name: "missing file", expected: http.StatusBadRequest},
+		{name: "different content type", data: data, fields: map[string]string{"Content-Type": "image/gif"}, expected: http.StatusForbidden},
+		{name: "different size", data: data[:5], fields: map[string]string{"size": "5"}, expected: http.StatusForbidden},
+		{name: "different key", data: data, fields: map[string]string{"key": "images/other/original.png"}, expected: http.StatusForbidden},
+		{name: "forged signature", data: data, fields: map[string]string{"signature": strings.Repeat("00", 32)}, expected: http.StatusForbidden},
+		{name: "expired", data: data, clock: time.Hour, expected: http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := newTestStorage(t)
+			key := "images/policy/original.png"
+			target, err := storage.PresignUpload(ctx, key, "image/png", uint64(len(data)))
+			require.NoError(t, err)
+
+			minted := time.Now()
+			storage.now = func() time.Time { return minted.Add(tc.clock) }
+			require.Equal(t, tc.expected, post(t, target, tc.data, tc.fields))
+
+			exists, err := storage.UploadExists(ctx, key)
+			require.NoError(t, err)
+			require.False(t, exists)
+		})
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	key := "images/multipart/original.png"
+	parts := [][]byte{[]byte("first part "), []byte("second part "), []byte("last")}
+
+	uploadID, err := storage.CreateMultipartUpload(ctx, key, "image/png")
+	require.NoError(t, err)
+	targets := make([]*blobpb.UploadTarget, len(parts))
+	for i, part := range parts {
+		targets[i], err = storage.PresignUploadPart(ctx, key, uploadID, i+1, uint64(len(part)))
+		require.NoError(t, err)
+		require.Equal(t, blobpb.UploadTarget_PUT, targets[i].Method)
+	}
+
+	// A part must be exactly its size, and its signature does not transfer to
+	// another part.
+	require.Equal(t, http.StatusBadRequest, put(t, targets[0], parts[0][:3]))
+	parsed, err := url.Parse(targets[0].Url)
+	require.NoError(t, err)
+	query := parsed.Query()
+	query.Set("part", "2")
+	parsed.RawQuery = query.Encode()
+	require.Equal(t, http.StatusForbidden, put(t, &blobpb.UploadTarget{Method: blobpb.UploadTarget_PUT, Url: parsed.String()}, parts[0]))
+
+	// Nothing is assembled until every part landed; a part sent again replaces
+	// the earlier upload of it.
+	require.Equal(t, http.StatusNoContent, put(t, targets[2], []byte("lost")))
+	require.Equal(t, http.StatusNoContent, put(t, targets[0], parts[0]))
+	require.ErrorIs(t, storage.CompleteMultipartUpload(ctx, key, uploadID, len(parts)), blob.ErrUploadIncomplete)
+	exists, err := storage.UploadExists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.Equal(t, http.StatusNoContent, put(t, targets[1], parts[1]))
+	require.Equal(t, http.StatusNoContent, put(t, targets[2], parts[2]))
+	require.NoError(t, storage.CompleteMultipartUpload(ctx, key, uploadID, len(parts)))
+	uploaded, err := storage.GetUploaded(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Join(parts, nil), uploaded)
+
+	// Completing again is a no-op, and the parts are gone.
+	require.NoError(t, storage.CompleteMultipartUpload(ctx, key, uploadID, len(parts)))
+	require.Equal(t, http.StatusNotFound, put(t, targets[1], parts[1]))
+
+	// The assembled upload is promoted like any other, content type included.
+	require.NoError(t, storage.CopyToOrigin(ctx, key))
+	download, err := storage.SignDownloadURL(ctx, key)
+	require.NoError(t, err)
+	status, contentType, body := get(t, download.Url)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "image/png", contentType)
+	require.Equal(t, bytes.Join(parts, nil), body)
+
+	// An aborted upload's parts are discarded.
+	aborted := "images/aborted/original.png"
+	uploadID, err = storage.CreateMultipartUpload(ctx, aborted, "image/png")
+	require.NoError(t, err)
+	target, err := storage.PresignUploadPart(ctx, aborted, uploadID, 1, uint64(len(parts[0])))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, put(t, target, parts[0]))
+	require.NoError(t, storage.AbortMultipartUpload(ctx, aborted, uploadID))
+	require.NoError(t, storage.AbortMultipartUpload(ctx, aborted, uploadID))
+	require.ErrorIs(t, storage.CompleteMultipartUpload(ctx, aborted, uploadID, 1), blob.ErrUploadIncomplete)
+
+	_, err = storage.PresignUploadPart(ctx, key, "../../escape", 1, 1)
+	require.Error(t, err)
+}
+
+func TestDownloadRequiresValidSignature(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	key := "images/signed/original.webp"
+	other := "images/other/original.webp"
+	require.NoError(t, storage.PutOrigin(ctx, key, "image/webp", []byte("webp bytes")))
+	require.NoError(t, storage.PutOrigin(ctx, other, "image/webp", []byte("other bytes")))
+
+	download, err := storage.SignDownloadURL(ctx, key)
+	require.NoError(t, err)
+	status, contentType, body := get(t, download.Url)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "image/webp", contentType)
+	require.Equal(t, []byte("webp bytes"), body)
+
+	parsed, err := url.Parse(download.Url)
+	require.NoError(t, err)
+
+	// A signature does not transfer to another key.
+	moved := *parsed
+	moved.Path = strings.Replace(moved.Path, "signed", "other", 1)
+	status, _, _ = get(t, moved.String())
+	require.Equal(t, http.StatusForbidden, status)
+
+	// Nor does the expiry extend.
+	extended := *parsed
+	query := extended.Query()
+	query.Set("expires", "99999999999")
+	extended.RawQuery = query.Encode()
+	status, _, _ = get(t, extended.String())
+	require.Equal(t, http.StatusForbidden, status)
+
+	// And it stops working once it expires.
+	storage.now = func() time.Time { return time.Now().Add(time.Hour) }
+	status, _, _ = get(t, download.Url)
+	require.Equal(t, http.StatusForbidden, status)
+}
+
+func TestDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	original := "images/collected/original.png"
+	rendition := "images/collected/thumbnail_8x8.webp"
+	kept := "images/kept/original.png"
+	data := []byte("bytes")
+
+	for _, key := range []string{original, kept} {
+		target, err := storage.PresignUpload(ctx, key, "image/png", uint64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, post(t, target, data, nil))
+		require.NoError(t, storage.CopyToOrigin(ctx, key))
+	}
+	require.NoError(t, storage.PutOrigin(ctx, rendition, "image/webp", data))
+
+	require.NoError(t, storage.DeletePrefix(ctx, "images/collected/"))
+	require.NoError(t, storage.DeletePrefix(ctx, "images/collected/"))
+
+	for _, key := range []string{original, rendition} {
+		download, err := storage.SignDownloadURL(ctx, key)
+		require.NoError(t, err)
+		status, _, _ := get(t, download.Url)
+		require.Equal(t, http.StatusNotFound, status)
+	}
+	exists, err := storage.UploadExists(ctx, original)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	download, err := storage.SignDownloadURL(ctx, kept)
+	require.NoError(t, err)
+	status, _, _ := get(t, download.Url)
+	require.Equal(t, http.StatusOK, status)
+
+	for _, prefix := range []string{"", "/", "images", "../images/", "/etc/"} {
+		require.Error(t, storage.DeletePrefix(ctx, prefix), prefix)
+	}
+}
+
+func TestCopyOrigin(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	data := []byte("rendition bytes")
+
+	require.NoError(t, storage.PutOrigin(ctx, "images/first/thumbnail_8x8.webp", "image/webp", data))
+	require.NoError(t, storage.CopyOrigin(ctx, "images/first/thumbnail_8x8.webp", "images/second/thumbnail_8x8.webp"))
+
+	download, err := storage.SignDownloadURL(ctx, "images/second/thumbnail_8x8.webp")
+	require.NoError(t, err)
+	status, contentType, body := get(t, download.Url)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "image/webp", contentType)
+	require.Equal(t, data, body)
+
+	err = storage.CopyOrigin(ctx, "images/missing/thumbnail_8x8.webp", "images/third/thumbnail_8x8.webp")
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+}
+
+func TestGetOrigin(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	data := []byte("original bytes")
+
+	require.NoError(t, storage.PutOrigin(ctx, "images/first/original.png", "image/png", data))
+	got, err := storage.GetOrigin(ctx, "images/first/original.png")
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	_, err = storage.GetOrigin(ctx, "images/missing/original.png")
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+}
+
+func TestKeysCannotEscapeTheirDirectory(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestStorage(t)
+	for _, key := range []string{"", "../outside.png", "/etc/passwd", "images/x/original.png" + contentTypeSuffix} {
+		_, err := storage.PresignUpload(ctx, key, "image/png", 1)
+		require.Error(t, err, key)
+		require.Error(t, storage.PutOrigin(ctx, key, "image/png", []byte{1}), key)
+		_, err = storage.SignDownloadURL(ctx, key)
+		require.Error(t, err, key)
+	}
+}
+
+func TestNewStorageRequiresAStrongKey(t *testing.T) {
+	_, err := NewStorage(Config{
+		UploadDir:  t.TempDir(),
+		OriginDir:  t.TempDir(),
+		BaseURL:    "http://localhost/blobs",
+		SigningKey: []byte("short"),
+	})
+	require.Error(t, err)
+}
@@ -9,7 +9,8 @@ import (
 	"image/jpeg"
 	"math"
 
-	// Register the standard PNG decoder so image.Decode recognizes it.
+	// Register the standard GIF and PNG decoders so image.Decode recognizes them.
+	_ "image/gif"
 	_ "image/png"
 
 	"github.com/buckket/go-blurhash"
@@ -29,10 +30,11 @@ var (
 	ErrImageCorrupt = errors.New("image is corrupt or undecodable")
 
 	// ErrImageUnsupportedType means the bytes are a kind the service does not
-	// accept — an unsupported format, or an animated image.
+	// accept — an unsupported format.
 	ErrImageUnsupportedType = errors.New("unsupported image type")
 
-	// ErrImageTooLarge means the image's pixel dimensions exceed the limits.
+	// ErrImageTooLarge means the image's pixel dimensions — or, for an animation,
+	// its frame count or duration — exceed the limits.
 	ErrImageTooLarge = errors.New("image exceeds dimension limits")
 
 	// ErrImagePrivacyMetadata means the image still carries embedded
@@ -88,6 +90,7 @@ const (
 // imageFormatToMimeType maps the format names returned by image.Decode to the
 // canonical MIME types this service supports.
 var imageFormatToMimeType = map[string]string{
+	"gif":  "image/gif",
 	"jpeg": "image/jpeg",
 	"png":  "image/png",
 	"webp": "image/webp",
@@ -95,17 +98,23 @@ var imageFormatToMimeType = map[string]string{
 
 // mimeTypeToExtension maps a supported MIME type to its canonical file extension
 // (with leading dot), used to give stored objects and signed URLs a meaningful
-// extension.
+// extension. AVIF is only ever a rendition encoding, never an accepted upload
+// type (see SupportedImageMimeTypes).
 var mimeTypeToExtension = map[string]string{
+	"image/avif": ".avif",
+	"image/gif":  ".gif",
 	"image/jpeg": ".jpg",
 	"image/png":  ".png",
 	"image/webp": ".webp",
 }
 
 // extensionForMimeType returns the canonical file extension (with leading dot)
-// for a MIME type, or "" if it is not one of the supported types.
+// for a MIME type, or "" if it is not one of the supported image or video types.
 func extensionForMimeType(mimeType string) string {
-	return mimeTypeToExtension[mimeType]
+	if ext, ok := mimeTypeToExtension[mimeType]; ok {
+		return ext
+	}
+	return videoMimeTypeToExtension[mimeType]
 }
 
 // ImageInspection is the result of decoding image bytes: the MIME type
@@ -116,14 +125,24 @@ type ImageInspection struct {
 
 	// Decoded is the decoded image, retained so callers can derive other
 	// renderings (e.g. the moderation payload) without decoding the bytes again.
+	// For an animation it is the first frame.
 	Decoded image.Image
+
+	// Frames are an animation's moderation samples after the first frame, each
+	// composited as displayed. It is nil for a still image.
+	Frames []image.Image
 }
 
 // InspectImage decodes the bytes as an image and derives their authoritative
 // MIME type, pixel dimensions, and BlurHash. It returns an error if the bytes are
 // not a decodable image of a supported format, if the image's pixel count exceeds
-// maxImagePixels, or if it still carries privacy-sensitive metadata; callers treat
-// any of these as a rejection.
+// maxImagePixels, if an animation exceeds its frame, duration, or pixel limits, or
+// if it still carries privacy-sensitive metadata; callers treat any of these as a
+// rejection.
+//
+// An animated image (GIF, APNG, animated WebP) is decoded frame by frame: its
+// metadata is derived from the first frame, and a sample of composited frames is
+// returned alongside it for moderation.
 func InspectImage(data []byte) (*ImageInspection, error) {
 	// Read only the header first to bound the pixel count before decoding the
 	// full image into memory. int64 math avoids overflow on a hostile header that
@@ -138,11 +157,14 @@ func InspectImage(data []byte) (*ImageInspection, error) {
 	if config.Width > maxImageDimension || config.Height > maxImageDimension {
 		return nil, fmt.Errorf("image dimensions %dx%d exceed the %d per-axis limit: %w", config.Width, config.Height, maxImageDimension, ErrImageTooLarge)
 	}
-	// Reject animated images: only the first frame would be inspected and
-	// moderated, but the whole animation would be served — a moderation bypass.
-	// Detected from the container structure, so no extra frames are decoded.
-	if isImageAnimated(headerFormat, data) {
-		return nil, fmt.Errorf("animated %s images are not supported: %w", headerFormat, ErrImageUnsupportedType)
+	// Bound an animation before decoding any of it: the pixel cap above covers a
+	// single canvas, not the number of frames. Read from the container structure,
+	// so a hostile multi-frame file costs nothing to judge.
+	animation, animated := imageAnimation(headerFormat, data)
+	if animated {
+		if err := checkAnimationLimits(animation, config.Width, config.Height); err != nil {
+			return nil, err
+		}
 	}
 	// Reject images that still carry the metadata the client is required to strip:
 	// the uploaded bytes are served to recipients verbatim, so an unstripped photo
@@ -152,6 +174,10 @@ func InspectImage(data []byte) (*ImageInspection, error) {
 		return nil, fmt.Errorf("%s image carries metadata that must be stripped before upload: %w", headerFormat, ErrImagePrivacyMetadata)
 	}
 
+	if animated {
+		return inspectAnimatedImage(headerFormat, data, animation)
+	}
+
 	img, format, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %v: %w", err, ErrImageCorrupt)
@@ -168,9 +194,9 @@ func InspectImage(data []byte) (*ImageInspection, error) {
 		return nil, fmt.Errorf("image has invalid dimensions %dx%d: %w", width, height, ErrImageCorrupt)
 	}
 
-	hash, err := blurhash.Encode(blurhashComponentsX, blurhashComponentsY, downscaleForBlurhash(img))
+	hash, err := imageBlurhash(img)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compute blurhash: %w", err)
+		return nil, err
 	}
 
 	return &ImageInspection{
@@ -185,6 +211,56 @@ func InspectImage(data []byte) (*ImageInspection, error) {
 	}, nil
 }
 
+// inspectAnimatedImage decodes an animation's first frame and moderation sample,
+// and derives its metadata from the first frame — the frame a client shows before
+// playback starts, and the one its thumbnails are made of.
+func inspectAnimatedImage(format string, data []byte, animation animationInfo) (*ImageInspection, error) {
+	mimeType, ok := imageFormatToMimeType[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image format %q: %w", format, ErrImageUnsupportedType)
+	}
+	frames, err := decodeAnimationFrames(format, data, animationSampleIndices(animation.frames))
+	if err != nil {
+		return nil, err
+	}
+
+	first := frames[0]
+	bounds := first.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("image has invalid dimensions %dx%d: %w", width, height, ErrImageCorrupt)
+	}
+
+	hash, err := imageBlurhash(first)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImageInspection{
+		MimeType: mimeType,
+		Metadata: &ImageMetadata{
+			Width:      uint32(width),
+			Height:     uint32(height),
+			Blurhash:   hash,
+			HasAlpha:   hasAlpha(first),
+			Animated:   true,
+			FrameCount: uint32(animation.frames),
+		},
+		Decoded: first,
+		Frames:  frames[1:],
+	}, nil
+}
+
+// imageBlurhash computes the BlurHash placeholder for a decoded image, over a
+// small downscaled copy of it.
+func imageBlurhash(img image.Image) (string, error) {
+	hash, err := blurhash.Encode(blurhashComponentsX, blurhashComponentsY, downscaleForBlurhash(img))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute blurhash: %w", err)
+	}
+	return hash, nil
+}
+
 const (
 	// moderationMaxBytes caps the payload sent to the moderation provider. Sync
 	// image-moderation endpoints are tuned for small images (and impose
@@ -310,49 +386,12 @@ func downscaleForBlurhash(img image.Image) image.Image {
 	return scaleTo(img, dstWidth, dstHeight)
 }
 
-// isImageAnimated reports whether the encoded image holds more than one frame. It
-// inspects the container structure directly rather than decoding pixels, so a
-// hostile multi-frame file cannot be used to exhaust memory (the pixel-count cap
-// in InspectImage bounds a single canvas, not the number of frames).
-//
-// Animated images are rejected by the service: only their first frame is
-// inspected and moderated, while the full animation would be served — a
-// moderation bypass. A malformed stream is reported as not-animated; the regular
-// decode path rejects it on its own.
+// isImageAnimated reports whether the encoded image holds more than one frame.
+// It inspects the container structure directly rather than decoding pixels, so a
+// hostile multi-frame file cannot be used to exhaust memory. See imageAnimation.
 func isImageAnimated(format string, data []byte) bool {
-	switch format {
-	case "png":
-		return pngIsAnimated(data)
-	case "webp":
-		return webpIsAnimated(data)
-	default:
-		// JPEG (and anything else that decodes) is single-frame.
-		return false
-	}
-}
-
-// pngIsAnimated reports whether a PNG carries an acTL (animation control) chunk
-// before its first IDAT — i.e. it is an APNG. The standard decoder renders only
-// the default image, so the animation would otherwise be served unmoderated.
-func pngIsAnimated(data []byte) bool {
-	const signature = "\x89PNG\r\n\x1a\n"
-	if len(data) < len(signature) || string(data[:len(signature)]) != signature {
-		return false
-	}
-
-	for pos := len(signature); pos+8 <= len(data); {
-		length := int(binary.BigEndian.Uint32(data[pos:]))
-		switch string(data[pos+4 : pos+8]) {
-		case "acTL":
-			return true
-		case "IDAT":
-			// Animation chunks must precede the first IDAT, so this is a still PNG.
-			return false
-		}
-		// Advance past length(4) + type(4) + data(length) + crc(4).
-		pos += 12 + length
-	}
-	return false
+	_, animated := imageAnimation(format, data)
+	return animated
 }
 
 // hasPrivacyMetadata reports whether the encoded image carries an embedded
@@ -366,7 +405,9 @@ func pngIsAnimated(data []byte) bool {
 // stored bytes are served to recipients verbatim, and rejecting an image keeps
 // them that way, where rewriting the file server-side would break the declared
 // size the upload is pinned to. It is the same reject-don't-correct posture
-// finalization already takes on a mismatched size or type.
+// finalization already takes on a mismatched size or type. A deployment can opt
+// out of that posture for images (see WithSanitizeMetadata), in which case
+// SanitizeImage strips the metadata and the rewritten bytes are what get served.
 //
 // The check is structural — it walks the container looking for the segments,
 // decoding no pixels — and it reports nothing on a malformed stream, since the
@@ -390,6 +431,8 @@ func hasPrivacyMetadata(format string, data []byte) bool {
 		return pngHasPrivacyMetadata(data)
 	case "webp":
 		return webpHasPrivacyMetadata(data)
+	case "gif":
+		return gifHasPrivacyMetadata(data)
 	default:
 		return false
 	}
@@ -410,24 +453,44 @@ var jpegAllowedAppMarkers = map[byte]bool{
 }
 
 // jpegHasPrivacyMetadata walks a JPEG's marker segments looking for a
-// metadata-carrying one. It stops at the start of scan: everything past SOS is
-// entropy-coded pixel data, and the metadata segments all precede it.
+// metadata-carrying one.
 func jpegHasPrivacyMetadata(data []byte) bool {
+	found := false
+	// A malformed stream is not reported; the decoder rejects it on its own.
+	_, _ = walkJPEGSegments(data, func(marker byte, _ []byte) {
+		if jpegIsMetadataSegment(marker) {
+			found = true
+		}
+	})
+	return found
+}
+
+// jpegIsMetadataSegment reports whether a marker segment is one that must be
+// stripped: a comment, or an APPn outside jpegAllowedAppMarkers.
+func jpegIsMetadataSegment(marker byte) bool {
+	const markerCOM = 0xFE // free-form comment
+	return marker == markerCOM || (marker >= 0xE0 && marker <= 0xEF && !jpegAllowedAppMarkers[marker])
+}
+
+// walkJPEGSegments visits a JPEG's marker segments in order, each with its raw
+// bytes (marker included), and returns the offset of the start of scan. It stops
+// there: everything past SOS is entropy-coded pixel data, and the metadata
+// segments all precede it. Segments before a malformation are still visited.
+func walkJPEGSegments(data []byte, visit func(marker byte, segment []byte)) (int, error) {
 	const (
 		markerSOI = 0xD8 // start of image
 		markerTEM = 0x01 // temporary — standalone, no payload
 		markerSOS = 0xDA // start of scan
 		markerEOI = 0xD9 // end of image
-		markerCOM = 0xFE // free-form comment
 	)
 
 	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
-		return false
+		return 0, errors.New("not a jpeg stream")
 	}
 
 	for pos := 2; pos+1 < len(data); {
 		if data[pos] != 0xFF {
-			return false // malformed; let the decoder reject it
+			return 0, errors.New("malformed jpeg marker")
 		}
 		marker := data[pos+1]
 
@@ -438,26 +501,25 @@ func jpegHasPrivacyMetadata(data []byte) bool {
 			continue
 		case marker == markerSOI || marker == markerTEM || (marker >= 0xD0 && marker <= 0xD7):
 			// Standalone markers (the RSTn restart markers included): no payload.
+			visit(marker, data[pos:pos+2])
 			pos += 2
 			continue
 		case marker == markerSOS || marker == markerEOI:
-			return false
+			return pos, nil
 		}
 
 		// Every remaining marker carries a big-endian length that counts itself.
 		if pos+4 > len(data) {
-			return false
+			return 0, errors.New("truncated jpeg segment")
 		}
 		length := int(binary.BigEndian.Uint16(data[pos+2:]))
-		if length < 2 {
-			return false // malformed
-		}
-		if marker == markerCOM || (marker >= 0xE0 && marker <= 0xEF && !jpegAllowedAppMarkers[marker]) {
-			return true
+		if length < 2 || pos+2+length > len(data) {
+			return 0, errors.New("malformed jpeg segment length")
 		}
+		visit(marker, data[pos:pos+2+length])
 		pos += 2 + length
 	}
-	return false
+	return 0, errors.New("jpeg stream has no scan")
 }
 
 // pngMetadataChunks are the PNG chunk types that carry personal data: eXIf holds
@@ -515,27 +577,6 @@ func webpHasPrivacyMetadata(data []byte) bool {
 	return false
 }
 
-// webpIsAnimated reports whether a WebP is animated, i.e. an extended (VP8X) file
-// carrying ANIM/ANMF chunks. The decoder in use cannot decode animated WebP and
-// already rejects it, but this makes the policy independent of that limitation.
-func webpIsAnimated(data []byte) bool {
-	// RIFF container: "RIFF" <fileSize:4> "WEBP", then FourCC-tagged chunks.
-	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
-		return false
-	}
-
-	for pos := 12; pos+8 <= len(data); {
-		switch string(data[pos : pos+4]) {
-		case "ANIM", "ANMF":
-			return true
-		}
-		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
-		// Chunk payloads are padded to an even length.
-		pos += 8 + size + (size & 1)
-	}
-	return false
-}
-
 // hasAlpha reports whether img carries a non-opaque alpha channel. The standard
 // library image types implement Opaque(), which scans for any non-opaque pixel;
 // a type that does not expose it is treated as potentially transparent, so its
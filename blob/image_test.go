@@ -104,6 +104,8 @@ func TestIsImageAnimated(t *testing.T) {
 		{"apng", "png", encodeAPNG(t), true},
 		{"static webp", "webp", webpRIFF("VP8 ", 16), false},
 		{"animated webp", "webp", webpRIFF("ANIM", 6), true},
+		{"static gif", "gif", encodeTestGIF(t, 1, 10), false},
+		{"animated gif", "gif", encodeTestGIF(t, 3, 10), true},
 		{"jpeg is never animated", "jpeg", nil, false},
 		{"unknown format is not inspected", "tiff", []byte("II*\x00unrecognized"), false},
 		{"empty", "png", nil, false},
@@ -138,11 +140,12 @@ func TestInspectImageRejectsOversizedDimensions(t *testing.T) {
 	require.ErrorContains(t, err, "exceed")
 }
 
-func TestInspectImageRejectsAnimated(t *testing.T) {
-	t.Run("apng is rejected", func(t *testing.T) {
-		_, err := InspectImage(encodeAPNG(t))
-		require.ErrorContains(t, err, "animated")
-	})
+func TestInspectImageRejectsAnimationWithoutFrames(t *testing.T) {
+	// An APNG is always inspected as an animation, whose frames — not the default
+	// image — are what a player shows. Declaring one without any frame data is
+	// corrupt, never a fallback to the default image.
+	_, err := InspectImage(encodeAPNG(t))
+	require.ErrorIs(t, err, ErrImageCorrupt)
 }
 
 func TestHasPrivacyMetadata(t *testing.T) {
@@ -176,6 +179,11 @@ func TestHasPrivacyMetadata(t *testing.T) {
 		{"webp with xmp", "webp", webpRIFF("XMP ", 8), true},
 		{"webp keeps icc profile", "webp", webpRIFF("ICCP", 8), false},
 
+		{"clean gif", "gif", encodeTestGIF(t, 2, 10), false},
+		{"gif with comment", "gif", gifWithExtension(t, 0xFE, []byte("shot at home")), true},
+		{"gif with xmp", "gif", gifWithExtension(t, 0xFF, []byte("XMP DataXMP")), true},
+		{"gif keeps looping extension", "gif", gifWithExtension(t, 0xFF, []byte("NETSCAPE2.0")), false},
+
 		{"unknown format is not inspected", "tiff", []byte("II*\x00"), false},
 		{"truncated jpeg", "jpeg", []byte{0xFF, 0xD8, 0xFF}, false},
 		{"empty", "jpeg", nil, false},
@@ -187,14 +195,18 @@ func TestHasPrivacyMetadata(t *testing.T) {
 	}
 }
 
-func TestGifIsUnsupported(t *testing.T) {
-	// GIF support was removed: it is not an accepted upload type, and its decoder is
-	// not registered, so bytes that sneak past the declared-type gate do not decode
-	// and are rejected as undecodable rather than served.
-	require.False(t, SupportedImageMimeTypes["image/gif"])
+func TestGifIsSupported(t *testing.T) {
+	// GIF is an accepted upload type, still or animated.
+	require.True(t, SupportedImageMimeTypes["image/gif"])
+
+	inspection, err := InspectImage(encodeTestGIF(t, 1, 0))
+	require.NoError(t, err)
+	require.Equal(t, "image/gif", inspection.MimeType)
+	require.False(t, inspection.Metadata.Animated)
 
+	// A header with no image data behind it is still undecodable.
 	rawGIF := []byte("GIF89a\x10\x00\x0c\x00\x00\x00\x00\x3b")
-	_, err := InspectImage(rawGIF)
+	_, err = InspectImage(rawGIF)
 	require.ErrorIs(t, err, ErrImageCorrupt)
 }
 
@@ -4,11 +4,23 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ocp_headers "github.com/code-payments/ocp-server/grpc/headers"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
 	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/moderation"
 )
 
+// supportedImageFormatsHeaderName is the ASCII request header a client lists the
+// rendition formats it decodes in, beyond the WebP every client does, as
+// comma-separated MIME types (e.g. "image/avif").
+const supportedImageFormatsHeaderName = "x-flipcash-supported-image-formats"
+
 // ErrBlobNotShareable is returned by Integration.ShareIntoChat when a referenced
 // blob cannot be attached to a chat — it does not exist, is not owned by the
 // sharer, is not a READY original, or is not an image. When it is returned none of
@@ -36,7 +48,8 @@ var (
 
 	// ErrBlobRejected means the blob failed validation or moderation. This is
 	// terminal for that id, since the bytes behind it are immutable: to try again
-	// the client must upload a new blob.
+	// the client must upload a new blob, unless a moderation rejection is
+	// overturned on appeal (see ReinstateBlob).
 	ErrBlobRejected = errors.New("blob rejected")
 
 	// ErrBlobInvalid means the blob is READY but unusable on this surface — it is a
@@ -46,8 +59,11 @@ var (
 
 // Integration is the surface other domains (messaging and profile today) use to
 // attach blobs to a resource they own: it validates and grants read access when
-// the blob is attached (ShareIntoChat, SetAsProfilePicture), and resolves the
-// blobs' metadata on read (Resolve).
+// the blob is attached (ShareIntoChat, SetAsProfilePicture), revokes it when the
+// blob is detached (RevokeFromChat, RevokeProfilePicture), and resolves the
+// blobs' metadata on read (Resolve). It is also how the moderation domain's
+// appeal workflow acts on rejected blobs (HoldBlob, ReinstateBlob), and how the
+// report domain acts on reported ones (BlobOwner, HasReadGrant, RejectBlob).
 type Integration struct {
 	blobs   Store
 	storage ObjectStorage
@@ -111,8 +127,9 @@ func (i *Integration) ShareIntoChat(ctx context.Context, sharerID *commonpb.User
 //
 // Granting the profile — rather than each viewer — is what makes a profile picture
 // public: every caller is covered by the profile principal (see ProfileResolver),
-// so exactly the blobs granted to it are readable through it. Grants are never
-// revoked, so a picture stays readable through the profile once set.
+// so exactly the blobs granted to it are readable through it. A picture stays
+// readable through the profile until it is replaced and RevokeProfilePicture
+// withdraws its grant.
 //
 // The ownership check matters for the same reason it does on a chat share: a
 // BlobId is a bearer capability, so without it a user could publish a blob they
@@ -143,6 +160,205 @@ func (i *Integration) SetAsProfilePicture(ctx context.Context, ownerID *commonpb
 	})
 }
 
+// RevokeFromChat withdraws the chat's read access to blobs, for when the message
+// that attached them is deleted. It is idempotent, so a retried delete revokes
+// harmlessly. An empty blobIDs is a no-op.
+//
+// The grant is per (blob, chat), not per message, so revoking a blob stops it
+// resolving through the chat for every message that attached it. The caller
+// passes only the blobs no other live message in the chat still references.
+//
+// A blob left with no grant at all is purged from the CDN (see revokeGrant).
+func (i *Integration) RevokeFromChat(ctx context.Context, chatID *commonpb.ChatId, blobIDs []*blobpb.BlobId) error {
+	chat := PrincipalForChat(chatID)
+	for _, id := range blobIDs {
+		if err := i.revokeGrant(ctx, id, chat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeProfilePicture withdraws ownerID's public profile's read access to a
+// blob, for when it is replaced as the profile picture. It is idempotent.
+//
+// A blob left with no grant at all is purged from the CDN (see revokeGrant).
+func (i *Integration) RevokeProfilePicture(ctx context.Context, ownerID *commonpb.UserId, blobID *blobpb.BlobId) error {
+	return i.revokeGrant(ctx, blobID, PrincipalForProfile(ownerID))
+}
+
+// HoldBlob keeps a rejected blob from being collected until at least until, by
+// restamping it in the collection index, so an appeal against the rejection can
+// still reinstate it. It is a no-op on a blob that is missing or not rejected.
+func (i *Integration) HoldBlob(ctx context.Context, id *blobpb.BlobId, until time.Time) error {
+	record, err := i.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if record.State != StateRejected {
+		return nil
+	}
+	return i.blobs.MarkForCollection(ctx, id, until)
+}
+
+// ReinstateBlob undoes a moderation rejection overturned on appeal: the blob is
+// moved back to StateUploaded with moderation bypassed (see Store.Reinstate) and
+// queued for finalization, which promotes it from the upload bytes kept since
+// its rejection. It is idempotent, re-queueing a blob already reinstated.
+//
+// moderation.ErrBlobNotReinstatable is returned for a blob that is missing, not
+// a moderation rejection, or whose upload bytes are gone.
+func (i *Integration) ReinstateBlob(ctx context.Context, id *blobpb.BlobId) error {
+	record, err := i.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return moderation.ErrBlobNotReinstatable
+	} else if err != nil {
+		return err
+	}
+	if record.ModerationBypassed {
+		// A no-op once it is READY.
+		return i.blobs.MarkForFinalization(ctx, id, record.ContentKind(), time.Now())
+	}
+	if record.State != StateRejected || record.Rejection == nil || record.Rejection.Reason != RejectionReasonModeration {
+		return moderation.ErrBlobNotReinstatable
+	}
+
+	exists, err := i.storage.UploadExists(ctx, record.StorageKey)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return moderation.ErrBlobNotReinstatable
+	}
+
+	reinstated, err := i.blobs.Reinstate(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return moderation.ErrBlobNotReinstatable
+	} else if err != nil {
+		return err
+	}
+	if !reinstated {
+		// Collected since it was read.
+		return moderation.ErrBlobNotReinstatable
+	}
+	return i.blobs.MarkForFinalization(ctx, id, record.ContentKind(), time.Now())
+}
+
+// BlobOwner returns the user who uploaded an original, for a report against it.
+// ErrBlobNotFound is returned for a blob that is missing, collected, or a
+// rendition (reports are made against the original).
+func (i *Integration) BlobOwner(ctx context.Context, id *blobpb.BlobId) (*commonpb.UserId, error) {
+	record, err := i.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrBlobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if record.State == StateDeleted || record.ParentID != nil {
+		return nil, ErrBlobNotFound
+	}
+	return record.Owner, nil
+}
+
+// HasReadGrant reports whether an original is granted to principal, for a report
+// against it by a user who must have been able to read it. As in
+// Server.canRead, the grant is half the decision: the caller still resolves
+// whether the user is covered by principal.
+func (i *Integration) HasReadGrant(ctx context.Context, id *blobpb.BlobId, principal Principal) (bool, error) {
+	return i.access.HasGrant(ctx, id, principal, PermissionRead)
+}
+
+// RejectBlob takes down an original staff found abusive on review of a report:
+// a READY blob is moved to StateRejected (see Store.TakeDown) and one still
+// finalizing is rejected outright, both under RejectionReasonTakenDown. Its
+// objects are deleted and purged from the CDN straight away, rather than a
+// grace period later, so download URLs already minted stop working, and it is
+// queued for the collector to tombstone. Uploads of the same bytes are rejected
+// as duplicates from then on, the tombstone included (see Store.Tombstone);
+// near-duplicates are caught only once its hash is blocked too (see
+// Server.BlockBlob). It is idempotent.
+//
+// ErrBlobNotFound is returned for a blob that is missing, collected, or a
+// rendition.
+func (i *Integration) RejectBlob(ctx context.Context, id *blobpb.BlobId) error {
+	record, err := i.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return ErrBlobNotFound
+	} else if err != nil {
+		return err
+	}
+	if record.State == StateDeleted || record.ParentID != nil {
+		return ErrBlobNotFound
+	}
+
+	// Still finalizing, it is rejected outright; READY, it is taken down. The
+	// takedown also catches a blob that reached READY since the read, so it is
+	// never left servable. One already rejected, for any reason, stays as it is.
+	if !record.State.Terminal() {
+		if _, err := i.blobs.Reject(ctx, id, &RejectionMetadata{Reason: RejectionReasonTakenDown}); err != nil {
+			return err
+		}
+	}
+	if _, err := i.blobs.TakeDown(ctx, id); errors.Is(err, ErrNotFound) {
+		return ErrBlobNotFound
+	} else if err != nil {
+		return err
+	}
+
+	prefix := storageItemPrefix(record.StorageKey)
+	if prefix == "" {
+		return fmt.Errorf("storage key %q has no item prefix", record.StorageKey)
+	}
+	if err := i.storage.DeletePrefix(ctx, prefix); err != nil {
+		return err
+	}
+	if err := i.storage.InvalidateCDN(ctx, prefix); err != nil {
+		return err
+	}
+	return i.blobs.MarkForCollection(ctx, id, time.Now())
+}
+
+// revokeGrant revokes principal's read grant on blobID, then purges the blob's
+// item from the CDN if that left it with no grant anywhere. Once it has none it
+// is readable by its owner alone — and unreferenced, so the collector reclaims it
+// — so no edge should keep serving copies fetched for other readers. A blob
+// still granted elsewhere (shared into another chat, say) is left alone, since
+// those readers may still fetch it.
+//
+// Revocation takes effect on the next read: Server.canRead consults the grant
+// every time. The purge only narrows how long cached copies linger.
+func (i *Integration) revokeGrant(ctx context.Context, blobID *blobpb.BlobId, principal Principal) error {
+	if err := i.access.Revoke(ctx, blobID, principal, PermissionRead); err != nil {
+		return err
+	}
+
+	granted, err := i.access.HasAnyGrant(ctx, blobID)
+	if err != nil {
+		return err
+	}
+	if granted {
+		return nil
+	}
+
+	records, err := i.blobs.GetByIDs(ctx, []*blobpb.BlobId{blobID})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		// Only a served original has anything cached; its renditions live under
+		// the same item prefix, so one purge covers them all.
+		if record.State != StateReady || record.ParentID != nil {
+			continue
+		}
+		if prefix := storageItemPrefix(record.StorageKey); prefix != "" {
+			return i.storage.InvalidateCDN(ctx, prefix)
+		}
+	}
+	return nil
+}
+
 // mimeTypeFilter reports whether a surface accepts content of the given MIME type.
 //
 // Each attach point supplies its own, because what a surface can carry is a property
@@ -158,10 +374,10 @@ func imagesOnly(mimeType string) bool {
 	return SupportedImageMimeTypes[mimeType]
 }
 
-// chatMedia accepts what a chat message may carry. Images only today; when video or
-// audio lands, its MIME types are admitted HERE — together with the rendition and
-// moderation paths those kinds need — rather than by widening what counts as an
-// image.
+// chatMedia accepts what a chat message may carry. Images only today: videos are
+// finalized (moderated, with poster renditions), but the wire metadata cannot yet
+// describe one, so a client could not render it inline. When it can, video MIME
+// types are admitted HERE rather than by widening what counts as an image.
 func chatMedia(mimeType string) bool {
 	return SupportedImageMimeTypes[mimeType]
 }
@@ -190,6 +406,8 @@ func validateAttachable(record *Blob, owner *commonpb.UserId, accepts mimeTypeFi
 	case StateReady:
 	case StateRejected:
 		return ErrBlobRejected
+	case StateDeleted:
+		return ErrBlobNotFound
 	default:
 		return ErrBlobNotReady
 	}
@@ -211,6 +429,10 @@ func validateAttachable(record *Blob, owner *commonpb.UserId, accepts mimeTypeFi
 // descriptors. Unknown or not-yet-READY ids are omitted; an empty input yields a
 // nil map.
 //
+// The set is negotiated per client: a rung with an AVIF rendition is served as
+// AVIF to a client that lists it in the request's supported formats (see
+// supportedImageFormatsHeaderName), and as WebP to everyone else.
+//
 // It reads only the originals — the whole rendition set is denormalized onto each
 // original's record as a manifest — so resolving a page of media costs a single
 // batched store read rather than a per-original index query. Each rendition's wire
@@ -230,6 +452,8 @@ func (i *Integration) ResolveRenditions(ctx context.Context, ids []*blobpb.BlobI
 		return nil, err
 	}
 
+	avif := supportsImageFormat(ctx, avifMimeType)
+
 	out := make(map[string][]*blobpb.Rendition, len(records))
 	for _, original := range records {
 		// Only a READY original is servable and carries a rendition manifest; a
@@ -251,7 +475,7 @@ func (i *Integration) ResolveRenditions(ctx context.Context, ids []*blobpb.BlobI
 
 		// The manifest is already in ladder order (small to large); mint each
 		// rendition's metadata from it without re-reading the child records.
-		for _, ref := range original.Renditions {
+		for _, ref := range selectRenditions(original.Renditions, avif) {
 			meta, err := buildMetadata(ctx, i.storage, ref.asBlob(original))
 			if err != nil {
 				return nil, err
@@ -267,3 +491,58 @@ func (i *Integration) ResolveRenditions(ctx context.Context, ids []*blobpb.BlobI
 	}
 	return out, nil
 }
+
+// supportsImageFormat reports whether the caller listed mimeType among the
+// request's supported formats. A call without the header — including one made
+// outside a gRPC request, which carries no headers at all — supports none.
+func supportsImageFormat(ctx context.Context, mimeType string) bool {
+	value, err := ocp_headers.GetASCIIHeaderByName(ctx, supportedImageFormatsHeaderName)
+	if err != nil {
+		return false
+	}
+	for _, format := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(format), mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectRenditions narrows a manifest to what the client is served, in manifest
+// order: a rung's AVIF rendition in place of its WebP one when avif is set, and
+// never an AVIF rendition otherwise. A rung without an AVIF sibling (a
+// transparent original's, or one finalized before AVIF was enabled) is served
+// as it is either way.
+func selectRenditions(refs []RenditionRef, avif bool) []RenditionRef {
+	selected := make([]RenditionRef, 0, len(refs))
+	for _, ref := range refs {
+		if ref.MimeType == avifMimeType {
+			if avif {
+				selected = append(selected, ref)
+			}
+			continue
+		}
+		if avif && hasAVIFSibling(refs, ref) {
+			continue
+		}
+		selected = append(selected, ref)
+	}
+	return selected
+}
+
+// hasAVIFSibling reports whether refs holds an AVIF rendition of the same rung
+// as ref: the same role at the same dimensions.
+func hasAVIFSibling(refs []RenditionRef, ref RenditionRef) bool {
+	if ref.Image == nil {
+		return false
+	}
+	for _, other := range refs {
+		if other.MimeType == avifMimeType && other.Rendition == ref.Rendition && other.Image != nil &&
+			other.Image.Width == ref.Image.Width && other.Image.Height == ref.Image.Height {
+			return true
+		}
+	}
+	return false
+}
+
+var _ moderation.AppealedBlobs = (*Integration)(nil)
@@ -3,16 +3,20 @@ package blob_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
+	ocp_headers "github.com/code-payments/ocp-server/grpc/headers"
+
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
 	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 
 	"github.com/code-payments/flipcash2-server/blob"
 	"github.com/code-payments/flipcash2-server/blob/memory"
 	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/moderation"
 )
 
 func newBlobID(t *testing.T) *blobpb.BlobId {
@@ -119,6 +123,216 @@ func TestIntegration_ShareIntoChat(t *testing.T) {
 	t.Run("an unknown blob is rejected", func(t *testing.T) {
 		require.ErrorIs(t, integration.ShareIntoChat(ctx, owner, chatID, []*blobpb.BlobId{newBlobID(t)}), blob.ErrBlobNotShareable)
 	})
+
+	t.Run("a collected blob is rejected", func(t *testing.T) {
+		id := putReadyOriginal(t, store, owner)
+		_, err := store.Tombstone(ctx, id, blob.StateReady)
+		require.NoError(t, err)
+		require.ErrorIs(t, integration.ShareIntoChat(ctx, owner, chatID, []*blobpb.BlobId{id}), blob.ErrBlobNotShareable)
+	})
+}
+
+func TestIntegration_Revoke(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemory()
+	storage := memory.NewInMemoryStorage()
+	access := memory.NewInMemoryAccessStore()
+	integration := blob.NewIntegration(store, storage, access)
+
+	owner := model.MustGenerateUserID()
+	chatID := newChatID()
+	otherChatID := newChatID()
+
+	hasGrant := func(id *blobpb.BlobId, p blob.Principal) bool {
+		has, err := access.HasGrant(ctx, id, p, blob.PermissionRead)
+		require.NoError(t, err)
+		return has
+	}
+
+	t.Run("revoking from a chat leaves other chats granted", func(t *testing.T) {
+		id := putReadyOriginal(t, store, owner)
+		require.NoError(t, integration.ShareIntoChat(ctx, owner, chatID, []*blobpb.BlobId{id}))
+		require.NoError(t, integration.ShareIntoChat(ctx, owner, otherChatID, []*blobpb.BlobId{id}))
+
+		require.NoError(t, integration.RevokeFromChat(ctx, chatID, []*blobpb.BlobId{id}))
+		require.False(t, hasGrant(id, blob.PrincipalForChat(chatID)))
+		require.True(t, hasGrant(id, blob.PrincipalForChat(otherChatID)))
+
+		// Still readable elsewhere, so nothing is purged from the CDN.
+		require.Empty(t, storage.Invalidations())
+
+		// Revoking the last grant purges the blob's item.
+		require.NoError(t, integration.RevokeFromChat(ctx, otherChatID, []*blobpb.BlobId{id}))
+		require.Equal(t, []string{"images/x/"}, storage.Invalidations())
+
+		// Idempotent: revoking again is not an error.
+		require.NoError(t, integration.RevokeFromChat(ctx, chatID, []*blobpb.BlobId{id}))
+	})
+
+	t.Run("empty input is a no-op", func(t *testing.T) {
+		require.NoError(t, integration.RevokeFromChat(ctx, chatID, nil))
+	})
+
+	t.Run("a replaced profile picture is revoked", func(t *testing.T) {
+		id := putReadyOriginal(t, store, owner)
+		require.NoError(t, integration.SetAsProfilePicture(ctx, owner, id))
+		require.True(t, hasGrant(id, blob.PrincipalForProfile(owner)))
+
+		before := len(storage.Invalidations())
+		require.NoError(t, integration.RevokeProfilePicture(ctx, owner, id))
+		require.False(t, hasGrant(id, blob.PrincipalForProfile(owner)))
+		require.Len(t, storage.Invalidations(), before+1)
+	})
+
+	t.Run("an unknown blob revokes without a purge", func(t *testing.T) {
+		before := len(storage.Invalidations())
+		require.NoError(t, integration.RevokeProfilePicture(ctx, owner, newBlobID(t)))
+		require.Len(t, storage.Invalidations(), before)
+	})
+}
+
+func TestIntegration_AppealedBlobs(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemory()
+	storage := memory.NewInMemoryStorage()
+	integration := blob.NewIntegration(store, storage, memory.NewInMemoryAccessStore())
+
+	// putRejected stages an uploaded original and rejects it for reason.
+	putRejected := func(t *testing.T, reason blob.RejectionReason) *blob.Blob {
+		id := newBlobID(t)
+		key, err := blob.StorageKey(id, "image/png")
+		require.NoError(t, err)
+		record := &blob.Blob{
+			ID:         id,
+			Rendition:  blob.RenditionOriginal,
+			Owner:      model.MustGenerateUserID(),
+			State:      blob.StatePending,
+			StorageKey: key,
+			MimeType:   "image/png",
+			SizeBytes:  1,
+		}
+		require.NoError(t, store.CreatePending(ctx, record))
+		storage.PutObject(key, []byte("x"))
+		_, err = store.Advance(ctx, id, blob.StateUploaded, nil)
+		require.NoError(t, err)
+		require.NoError(t, store.MarkForCollection(ctx, id, time.Now()))
+		_, err = store.Reject(ctx, id, &blob.RejectionMetadata{Reason: reason})
+		require.NoError(t, err)
+		return record
+	}
+
+	t.Run("a held blob is not due for collection until the hold lapses", func(t *testing.T) {
+		record := putRejected(t, blob.RejectionReasonModeration)
+		until := time.Now().Add(time.Hour)
+		require.NoError(t, integration.HoldBlob(ctx, record.ID, until))
+
+		due, err := store.GetDueForCollection(ctx, time.Now(), 100)
+		require.NoError(t, err)
+		for _, id := range due {
+			require.NotEqual(t, record.ID.Value, id.Value)
+		}
+		due, err = store.GetDueForCollection(ctx, until, 100)
+		require.NoError(t, err)
+		require.Contains(t, due, record.ID)
+
+		// Holding a blob that is not rejected, or is missing, does nothing.
+		ready := putReadyOriginal(t, store, model.MustGenerateUserID())
+		require.NoError(t, integration.HoldBlob(ctx, ready, until))
+		require.NoError(t, integration.HoldBlob(ctx, newBlobID(t), until))
+	})
+
+	t.Run("an overturned moderation rejection is queued for finalization", func(t *testing.T) {
+		record := putRejected(t, blob.RejectionReasonModeration)
+		require.NoError(t, integration.ReinstateBlob(ctx, record.ID))
+
+		got, err := store.GetByID(ctx, record.ID)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateUploaded, got.State)
+		require.True(t, got.ModerationBypassed)
+		due, err := store.GetDueForFinalization(ctx, blob.ContentKindImage, time.Now(), 100)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		require.Equal(t, record.ID.Value, due[0].ID.Value)
+
+		// Idempotent.
+		require.NoError(t, integration.ReinstateBlob(ctx, record.ID))
+	})
+
+	t.Run("a blob that cannot be reinstated", func(t *testing.T) {
+		corrupt := putRejected(t, blob.RejectionReasonCorrupt)
+		require.ErrorIs(t, integration.ReinstateBlob(ctx, corrupt.ID), moderation.ErrBlobNotReinstatable)
+
+		collected := putRejected(t, blob.RejectionReasonModeration)
+		require.NoError(t, storage.DeleteUpload(ctx, collected.StorageKey))
+		require.ErrorIs(t, integration.ReinstateBlob(ctx, collected.ID), moderation.ErrBlobNotReinstatable)
+		got, err := store.GetByID(ctx, collected.ID)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateRejected, got.State)
+
+		require.ErrorIs(t, integration.ReinstateBlob(ctx, newBlobID(t)), moderation.ErrBlobNotReinstatable)
+	})
+}
+
+func TestIntegration_ReportedBlobs(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemory()
+	storage := memory.NewInMemoryStorage()
+	integration := blob.NewIntegration(store, storage, memory.NewInMemoryAccessStore())
+
+	t.Run("a reported blob's owner is resolved", func(t *testing.T) {
+		owner := model.MustGenerateUserID()
+		id := putReadyOriginal(t, store, owner)
+		got, err := integration.BlobOwner(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, owner.Value, got.Value)
+
+		_, err = integration.BlobOwner(ctx, newBlobID(t))
+		require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	})
+
+	t.Run("a ready blob is taken down and its objects deleted", func(t *testing.T) {
+		id := putReadyOriginal(t, store, model.MustGenerateUserID())
+		storage.PutObject("images/x/original.png", []byte("x"))
+
+		require.NoError(t, integration.RejectBlob(ctx, id))
+		got, err := store.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateRejected, got.State)
+		require.Equal(t, blob.RejectionReasonTakenDown, got.Rejection.Reason)
+		require.Equal(t, blobpb.RejectionReason_REJECTION_REASON_MODERATION, got.Rejection.ToProto().Reason)
+
+		_, err = storage.GetOrigin(ctx, "images/x/original.png")
+		require.Error(t, err)
+		require.Contains(t, storage.Invalidations(), "images/x/")
+		due, err := store.GetDueForCollection(ctx, time.Now().Add(time.Second), 100)
+		require.NoError(t, err)
+		require.Contains(t, due, id)
+
+		// Idempotent.
+		require.NoError(t, integration.RejectBlob(ctx, id))
+	})
+
+	t.Run("a blob still finalizing is rejected", func(t *testing.T) {
+		id := newBlobID(t)
+		require.NoError(t, store.CreatePending(ctx, &blob.Blob{
+			ID:         id,
+			Rendition:  blob.RenditionOriginal,
+			Owner:      model.MustGenerateUserID(),
+			State:      blob.StatePending,
+			StorageKey: "images/y/original.png",
+			MimeType:   "image/png",
+			SizeBytes:  1,
+		}))
+		require.NoError(t, integration.RejectBlob(ctx, id))
+		got, err := store.GetByID(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateRejected, got.State)
+		require.Equal(t, blob.RejectionReasonTakenDown, got.Rejection.Reason)
+	})
+
+	t.Run("an unknown blob is not found", func(t *testing.T) {
+		require.ErrorIs(t, integration.RejectBlob(ctx, newBlobID(t)), blob.ErrBlobNotFound)
+	})
 }
 
 func TestIntegration_ResolveRenditions(t *testing.T) {
@@ -174,3 +388,59 @@ func TestIntegration_ResolveRenditions(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, empty)
 }
+
+func TestIntegration_ResolveRenditionsNegotiatesAVIF(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemory()
+	integration := blob.NewIntegration(store, memory.NewInMemoryStorage(), memory.NewInMemoryAccessStore())
+
+	// A thumbnail rung with only WebP (as a transparent original's would be), and
+	// a display rung with WebP and its AVIF sibling.
+	ready := putReadyOriginal(t, store, model.MustGenerateUserID())
+	thumbID, displayID, avifID := newBlobID(t), newBlobID(t), newBlobID(t)
+	require.NoError(t, store.AttachRenditions(ctx, ready, []blob.RenditionRef{
+		{
+			ID: thumbID, Rendition: blob.RenditionThumbnail, MimeType: "image/webp", SizeBytes: 10,
+			StorageKey: "images/x/thumbnail_160x120.webp", Image: &blob.ImageMetadata{Width: 160, Height: 120},
+		},
+		{
+			ID: displayID, Rendition: blob.RenditionDisplay, MimeType: "image/webp", SizeBytes: 100,
+			StorageKey: "images/x/display_800x600.webp", Image: &blob.ImageMetadata{Width: 800, Height: 600},
+		},
+		{
+			ID: avifID, Rendition: blob.RenditionDisplay, MimeType: "image/avif", SizeBytes: 60,
+			StorageKey: "images/x/display_800x600.avif", Image: &blob.ImageMetadata{Width: 800, Height: 600},
+		},
+	}))
+
+	resolve := func(ctx context.Context) []*blobpb.Rendition {
+		resolved, err := integration.ResolveRenditions(ctx, []*blobpb.BlobId{ready})
+		require.NoError(t, err)
+		renditions := resolved[string(ready.Value)]
+		require.Len(t, renditions, 3)
+		return renditions
+	}
+	withFormats := func(formats string) context.Context {
+		ctx, err := ocp_headers.ContextWithHeaders(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, ocp_headers.SetASCIIHeader(ctx, "x-flipcash-supported-image-formats", formats))
+		return ctx
+	}
+
+	// Without the header — or without AVIF in it — the WebP fallback is served.
+	for _, ctx := range []context.Context{ctx, withFormats(""), withFormats("image/heic")} {
+		renditions := resolve(ctx)
+		require.Equal(t, thumbID.Value, renditions[1].BlobId.Value)
+		require.Equal(t, displayID.Value, renditions[2].BlobId.Value)
+		require.Equal(t, "image/webp", renditions[2].Blob.MimeType)
+	}
+
+	// A client decoding AVIF gets the AVIF sibling in the WebP rendition's place,
+	// and the WebP rendition where a rung has no sibling.
+	renditions := resolve(withFormats("image/heic, IMAGE/AVIF"))
+	require.Equal(t, thumbID.Value, renditions[1].BlobId.Value)
+	require.Equal(t, avifID.Value, renditions[2].BlobId.Value)
+	require.Equal(t, blobpb.Rendition_DISPLAY, renditions[2].Role)
+	require.Equal(t, "image/avif", renditions[2].Blob.MimeType)
+	require.EqualValues(t, 60, renditions[2].Blob.SizeBytes)
+}
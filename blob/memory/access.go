@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
@@ -49,6 +50,24 @@ func (m *accessMemory) HasGrant(_ context.Context, blobID *blobpb.BlobId, p blob
 	return ok, nil
 }
 
+func (m *accessMemory) HasAnyGrant(_ context.Context, blobID *blobpb.BlobId) (bool, error) {
+	if blobID == nil || len(blobID.Value) == 0 {
+		return false, fmt.Errorf("%w: missing blob id", blob.ErrInvalidGrant)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	// Every key on the blob leads with its quoted id, which is self-delimiting.
+	prefix := fmt.Sprintf("%q|", blobID.Value)
+	for key := range m.grants {
+		if strings.HasPrefix(key, prefix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (m *accessMemory) Revoke(_ context.Context, blobID *blobpb.BlobId, p blob.Principal, perm blob.Permission) error {
 	if err := (&blob.Grant{BlobID: blobID, Principal: p, Permission: perm}).Validate(); err != nil {
 		return err
@@ -0,0 +1,17 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/code-payments/flipcash2-server/blob/tests"
+)
+
+func TestBlob_MemoryBackfill(t *testing.T) {
+	blobs := NewInMemory()
+	storage := NewInMemoryStorage()
+	teardown := func() {
+		blobs.(*memory).reset()
+		storage.reset()
+	}
+	tests.RunBackfillTests(t, blobs, storage, storage.PutObject, teardown)
+}
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+type blocklistMemory struct {
+	sync.Mutex
+
+	entries map[blob.PerceptualHash]*blob.BlockedHash
+}
+
+// NewInMemoryHashBlocklist returns an in-memory blob.HashBlocklist for tests.
+func NewInMemoryHashBlocklist() blob.HashBlocklist {
+	return &blocklistMemory{
+		entries: make(map[blob.PerceptualHash]*blob.BlockedHash),
+	}
+}
+
+func (m *blocklistMemory) Add(_ context.Context, entry *blob.BlockedHash) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.entries[entry.Hash]; !ok {
+		m.entries[entry.Hash] = entry.Clone()
+	}
+	return nil
+}
+
+func (m *blocklistMemory) Remove(_ context.Context, hash blob.PerceptualHash) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.entries[hash]; !ok {
+		return blob.ErrNotFound
+	}
+	delete(m.entries, hash)
+	return nil
+}
+
+func (m *blocklistMemory) List(_ context.Context) ([]*blob.BlockedHash, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	entries := make([]*blob.BlockedHash, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry.Clone())
+	}
+	return entries, nil
+}
+
+func (m *blocklistMemory) reset() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.entries = make(map[blob.PerceptualHash]*blob.BlockedHash)
+}
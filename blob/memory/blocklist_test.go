@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/code-payments/flipcash2-server/blob/tests"
+)
+
+func TestBlobHashBlocklist_MemoryStore(t *testing.T) {
+	testStore := NewInMemoryHashBlocklist()
+	teardown := func() {
+		testStore.(*blocklistMemory).reset()
+	}
+	tests.RunHashBlocklistTests(t, testStore, teardown)
+}
@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/code-payments/flipcash2-server/blob/tests"
+)
+
+func TestBlob_MemoryCollector(t *testing.T) {
+	blobs := NewInMemory()
+	access := NewInMemoryAccessStore()
+	storage := NewInMemoryStorage()
+	teardown := func() {
+		blobs.(*memory).reset()
+		access.(*accessMemory).reset()
+		storage.reset()
+	}
+	tests.RunCollectorTests(t, blobs, access, storage, storage.PutObject, teardown)
+}
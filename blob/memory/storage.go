@@ -1,7 +1,13 @@
 package memory
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,13 +38,26 @@ type Storage struct {
 
 	uploaded map[string][]byte
 	served   map[string][]byte
+
+	// multipart maps an in-progress multipart upload's id to its parts.
+	multipart map[string]*multipartUpload
+
+	// invalidated records every prefix InvalidateCDN was asked to purge, in
+	// order, for tests to assert on.
+	invalidated []string
+}
+
+type multipartUpload struct {
+	key   string
+	parts map[int][]byte
 }
 
 // NewInMemoryStorage returns an in-memory blob.ObjectStorage for tests.
 func NewInMemoryStorage() *Storage {
 	return &Storage{
-		uploaded: make(map[string][]byte),
-		served:   make(map[string][]byte),
+		uploaded:  make(map[string][]byte),
+		served:    make(map[string][]byte),
+		multipart: make(map[string]*multipartUpload),
 	}
 }
 
@@ -54,6 +73,61 @@ func (s *Storage) PresignUpload(_ context.Context, key, mimeType string, sizeByt
 	}, nil
 }
 
+func (s *Storage) CreateMultipartUpload(_ context.Context, key, _ string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	uploadID := blob.IDString(blob.MustGenerateID())
+	s.multipart[uploadID] = &multipartUpload{key: key, parts: make(map[int][]byte)}
+	return uploadID, nil
+}
+
+func (s *Storage) PresignUploadPart(_ context.Context, key, uploadID string, partNumber int, sizeBytes uint64) (*blobpb.UploadTarget, error) {
+	query := url.Values{
+		"uploadId":   {uploadID},
+		"partNumber": {strconv.Itoa(partNumber)},
+	}
+	return &blobpb.UploadTarget{
+		Method:    blobpb.UploadTarget_PUT,
+		Url:       uploadURL + key + "?" + query.Encode(),
+		Headers:   map[string]string{"Content-Length": strconv.FormatUint(sizeBytes, 10)},
+		ExpiresAt: timestamppb.New(time.Now().Add(uploadTTL)),
+	}, nil
+}
+
+func (s *Storage) CompleteMultipartUpload(_ context.Context, key, uploadID string, parts int) error {
+	s.Lock()
+	defer s.Unlock()
+
+	upload, ok := s.multipart[uploadID]
+	if !ok || upload.key != key {
+		// Already assembled, or never started.
+		if _, ok := s.uploaded[key]; ok {
+			return nil
+		}
+		return blob.ErrUploadIncomplete
+	}
+	var assembled bytes.Buffer
+	for partNumber := 1; partNumber <= parts; partNumber++ {
+		part, ok := upload.parts[partNumber]
+		if !ok {
+			return blob.ErrUploadIncomplete
+		}
+		assembled.Write(part)
+	}
+	s.uploaded[key] = assembled.Bytes()
+	delete(s.multipart, uploadID)
+	return nil
+}
+
+func (s *Storage) AbortMultipartUpload(_ context.Context, _, uploadID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.multipart, uploadID)
+	return nil
+}
+
 func (s *Storage) GetUploaded(_ context.Context, key string) ([]byte, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -99,6 +173,29 @@ func (s *Storage) PutOrigin(_ context.Context, key, _ string, data []byte) error
 	return nil
 }
 
+func (s *Storage) GetOrigin(_ context.Context, key string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	data, ok := s.served[key]
+	if !ok {
+		return nil, blob.ErrObjectNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *Storage) CopyOrigin(_ context.Context, srcKey, dstKey string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	data, ok := s.served[srcKey]
+	if !ok {
+		return blob.ErrObjectNotFound
+	}
+	s.served[dstKey] = data
+	return nil
+}
+
 func (s *Storage) DeleteUpload(_ context.Context, key string) error {
 	s.Lock()
 	defer s.Unlock()
@@ -107,6 +204,36 @@ func (s *Storage) DeleteUpload(_ context.Context, key string) error {
 	return nil
 }
 
+func (s *Storage) DeletePrefix(_ context.Context, prefix string) error {
+	if prefix == "" {
+		return errors.New("empty object prefix")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, objects := range []map[string][]byte{s.uploaded, s.served} {
+		for key := range objects {
+			if strings.HasPrefix(key, prefix) {
+				delete(objects, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Storage) InvalidateCDN(_ context.Context, prefix string) error {
+	if prefix == "" {
+		return errors.New("empty object prefix")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.invalidated = append(s.invalidated, prefix)
+	return nil
+}
+
 func (s *Storage) SignDownloadURL(_ context.Context, key string) (*blobpb.DownloadUrl, error) {
 	return &blobpb.DownloadUrl{
 		Url:       downloadURLPrefix + key,
@@ -114,12 +241,37 @@ func (s *Storage) SignDownloadURL(_ context.Context, key string) (*blobpb.Downlo
 	}, nil
 }
 
-// SimulateUpload stores bytes as if the client had POSTed them to the presigned
-// target, keyed by the target's "key" form field.
+// SimulateUpload stores bytes as if the client had sent them to the presigned
+// target: POSTed whole, keyed by the target's "key" form field, or PUT as a part
+// of the multipart upload its URL names.
 func (s *Storage) SimulateUpload(target *blobpb.UploadTarget, data []byte) {
+	if target.Method == blobpb.UploadTarget_PUT {
+		s.putPart(target.Url, data)
+		return
+	}
 	s.PutObject(target.FormFields["key"], data)
 }
 
+func (s *Storage) putPart(rawURL string, data []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	query := parsed.Query()
+	upload, ok := s.multipart[query.Get("uploadId")]
+	if !ok {
+		return
+	}
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil {
+		return
+	}
+	upload.parts[partNumber] = append([]byte(nil), data...)
+}
+
 // PutObject stores bytes directly into the upload store, bypassing the presign
 // dance.
 func (s *Storage) PutObject(key string, data []byte) {
@@ -129,12 +281,22 @@ func (s *Storage) PutObject(key string, data []byte) {
 	s.uploaded[key] = append([]byte(nil), data...)
 }
 
+// Invalidations returns the prefixes InvalidateCDN has purged, in call order.
+func (s *Storage) Invalidations() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return slices.Clone(s.invalidated)
+}
+
 func (s *Storage) reset() {
 	s.Lock()
 	defer s.Unlock()
 
 	s.uploaded = make(map[string][]byte)
 	s.served = make(map[string][]byte)
+	s.multipart = make(map[string]*multipartUpload)
+	s.invalidated = nil
 }
 
 var _ blob.ObjectStorage = (*Storage)(nil)
@@ -1,12 +1,16 @@
 package memory
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 
 	"github.com/code-payments/flipcash2-server/blob"
 )
@@ -21,6 +25,15 @@ type queueEntry struct {
 	attempts   uint32
 	dueAt      time.Time
 	enqueuedAt time.Time
+
+	// deadLetteredAt is set once the entry is parked on its kind's dead-letter
+	// list, along with the last attempt's error; a parked entry is never due.
+	deadLetteredAt time.Time
+	lastError      string
+}
+
+func (e *queueEntry) deadLettered() bool {
+	return !e.deadLetteredAt.IsZero()
 }
 
 type memory struct {
@@ -29,15 +42,33 @@ type memory struct {
 	// blobs maps string(id.Value) to the stored record.
 	blobs map[string]*blob.Blob
 
-	// queue maps string(id.Value) to the blob's finalization-queue entry.
+	// queue maps string(id.Value) to the blob's finalization-queue entry,
+	// dead-lettered or not.
 	queue map[string]*queueEntry
+
+	// collect maps string(id.Value) to the instant an indexed original's
+	// collection grace period counts from.
+	collect map[string]time.Time
+
+	// hashed maps string(id.Value) to when an original's content hash was
+	// first recorded; the hash itself lives on the record.
+	hashed map[string]time.Time
+
+	// stored maps string(owner.Value) to the owner's stored bytes; daily maps a
+	// dailyKey to the bytes the owner reserved that UTC day.
+	stored map[string]uint64
+	daily  map[string]uint64
 }
 
 // NewInMemory returns an in-memory blob.Store for tests.
 func NewInMemory() blob.Store {
 	return &memory{
-		blobs: make(map[string]*blob.Blob),
-		queue: make(map[string]*queueEntry),
+		blobs:   make(map[string]*blob.Blob),
+		queue:   make(map[string]*queueEntry),
+		collect: make(map[string]time.Time),
+		hashed:  make(map[string]time.Time),
+		stored:  make(map[string]uint64),
+		daily:   make(map[string]uint64),
 	}
 }
 
@@ -50,7 +81,17 @@ func (m *memory) CreatePending(_ context.Context, b *blob.Blob) error {
 		return blob.ErrExists
 	}
 
+	now := time.Now()
 	m.blobs[key] = b.Clone()
+	if b.ParentID == nil {
+		m.collect[key] = now
+	}
+	if b.Owner != nil {
+		m.stored[string(b.Owner.Value)] += b.SizeBytes
+		if b.ParentID == nil {
+			m.daily[dailyKey(b.Owner, now)] += b.SizeBytes
+		}
+	}
 	return nil
 }
 
@@ -99,7 +140,7 @@ func (m *memory) AttachRenditions(_ context.Context, id *blobpb.BlobId, refs []b
 	return nil
 }
 
-func (m *memory) Advance(_ context.Context, id *blobpb.BlobId, to blob.State, image *blob.ImageMetadata) (bool, error) {
+func (m *memory) Advance(_ context.Context, id *blobpb.BlobId, to blob.State, meta *blob.DerivedMetadata) (bool, error) {
 	if to == blob.StateRejected {
 		return false, blob.ErrCannotAdvanceToRejected
 	}
@@ -120,10 +161,21 @@ func (m *memory) Advance(_ context.Context, id *blobpb.BlobId, to blob.State, im
 	}
 
 	b.State = to
-	if image != nil {
-		imageCopy := *image
+	if meta != nil && meta.Image != nil {
+		imageCopy := *meta.Image
 		b.Image = &imageCopy
 	}
+	if meta != nil && meta.Video != nil {
+		videoCopy := *meta.Video
+		b.Video = &videoCopy
+	}
+	if meta != nil && meta.OriginSizeBytes != 0 {
+		b.OriginSizeBytes = meta.OriginSizeBytes
+	}
+	if meta != nil && meta.PerceptualHash != nil {
+		hashCopy := *meta.PerceptualHash
+		b.PerceptualHash = &hashCopy
+	}
 	// Reaching the terminal READY state dequeues the blob: the finalization work
 	// is done.
 	if to == blob.StateReady {
@@ -158,6 +210,41 @@ func (m *memory) Reject(_ context.Context, id *blobpb.BlobId, rejection *blob.Re
 	return true, nil
 }
 
+func (m *memory) Reinstate(_ context.Context, id *blobpb.BlobId) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	b, ok := m.blobs[string(id.Value)]
+	if !ok {
+		return false, blob.ErrNotFound
+	}
+	if b.State != blob.StateRejected || b.Rejection == nil || b.Rejection.Reason != blob.RejectionReasonModeration {
+		return false, nil
+	}
+
+	b.State = blob.StateUploaded
+	b.Rejection = nil
+	b.ModerationBypassed = true
+	return true, nil
+}
+
+func (m *memory) TakeDown(_ context.Context, id *blobpb.BlobId) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	b, ok := m.blobs[string(id.Value)]
+	if !ok {
+		return false, blob.ErrNotFound
+	}
+	if b.State != blob.StateReady {
+		return false, nil
+	}
+
+	b.State = blob.StateRejected
+	b.Rejection = &blob.RejectionMetadata{Reason: blob.RejectionReasonTakenDown}
+	return true, nil
+}
+
 func (m *memory) MarkForFinalization(_ context.Context, id *blobpb.BlobId, kind blob.ContentKind, nextAttemptAt time.Time) error {
 	m.Lock()
 	defer m.Unlock()
@@ -175,7 +262,8 @@ func (m *memory) MarkForFinalization(_ context.Context, id *blobpb.BlobId, kind
 	// Re-marking resets the due time (and the queue, should the kind differ) but
 	// preserves the attempt count and the original enqueue time, so a client
 	// re-completing cannot wipe the backoff bookkeeping or hide the entry's age.
-	if entry, ok := m.queue[key]; ok {
+	// A dead-lettered entry is retried afresh instead.
+	if entry, ok := m.queue[key]; ok && !entry.deadLettered() {
 		entry.kind = kind
 		entry.dueAt = nextAttemptAt
 		return nil
@@ -190,7 +278,7 @@ func (m *memory) GetDueForFinalization(_ context.Context, kind blob.ContentKind,
 
 	due := make([]*blob.FinalizationTask, 0)
 	for key, entry := range m.queue {
-		if entry.kind != kind || entry.dueAt.After(asOf) {
+		if entry.kind != kind || entry.deadLettered() || entry.dueAt.After(asOf) {
 			continue
 		}
 		due = append(due, &blob.FinalizationTask{
@@ -212,7 +300,7 @@ func (m *memory) GetFinalizationQueueStats(_ context.Context, kind blob.ContentK
 
 	stats := &blob.FinalizationQueueStats{}
 	for _, entry := range m.queue {
-		if entry.kind != kind {
+		if entry.kind != kind || entry.deadLettered() {
 			continue
 		}
 		stats.Depth++
@@ -228,7 +316,7 @@ func (m *memory) ClaimForFinalization(_ context.Context, id *blobpb.BlobId, asOf
 	defer m.Unlock()
 
 	entry, ok := m.queue[string(id.Value)]
-	if !ok || entry.dueAt.After(asOf) {
+	if !ok || entry.deadLettered() || entry.dueAt.After(asOf) {
 		return false, nil
 	}
 	entry.dueAt = until
@@ -242,7 +330,7 @@ func (m *memory) DelayFinalization(_ context.Context, id *blobpb.BlobId, nextAtt
 	// A blob that left the queue (a concurrent finalize drove it terminal) has
 	// nothing to reschedule.
 	entry, ok := m.queue[string(id.Value)]
-	if !ok {
+	if !ok || entry.deadLettered() {
 		return nil
 	}
 	entry.attempts++
@@ -250,10 +338,248 @@ func (m *memory) DelayFinalization(_ context.Context, id *blobpb.BlobId, nextAtt
 	return nil
 }
 
+func (m *memory) DeadLetterFinalization(_ context.Context, id *blobpb.BlobId, kind blob.ContentKind, attempts uint32, lastError string, at time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	entry, ok := m.queue[string(id.Value)]
+	if !ok || entry.deadLettered() {
+		return nil
+	}
+	entry.kind = kind
+	entry.attempts = attempts
+	entry.lastError = lastError
+	entry.deadLetteredAt = at
+	return nil
+}
+
+func (m *memory) GetDeadLetters(_ context.Context, kind blob.ContentKind, limit int) ([]*blob.DeadLetter, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	letters := make([]*blob.DeadLetter, 0)
+	for key, entry := range m.queue {
+		if entry.kind != kind || !entry.deadLettered() {
+			continue
+		}
+		letters = append(letters, &blob.DeadLetter{
+			ID:             &blobpb.BlobId{Value: append([]byte(nil), key...)},
+			Kind:           entry.kind,
+			LastError:      entry.lastError,
+			Attempts:       entry.attempts,
+			EnqueuedAt:     entry.enqueuedAt,
+			DeadLetteredAt: entry.deadLetteredAt,
+		})
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].DeadLetteredAt.Before(letters[j].DeadLetteredAt) })
+	if len(letters) > limit {
+		letters = letters[:limit]
+	}
+	return letters, nil
+}
+
+func (m *memory) MarkForCollection(_ context.Context, id *blobpb.BlobId, since time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+
+	key := string(id.Value)
+	b, ok := m.blobs[key]
+	if !ok {
+		return blob.ErrNotFound
+	}
+	if b.State == blob.StateDeleted {
+		return nil
+	}
+	m.collect[key] = since
+	return nil
+}
+
+func (m *memory) GetDueForCollection(_ context.Context, asOf time.Time, limit int) ([]*blobpb.BlobId, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	type candidate struct {
+		key   string
+		since time.Time
+	}
+	due := make([]candidate, 0)
+	for key, since := range m.collect {
+		if !since.After(asOf) {
+			due = append(due, candidate{key: key, since: since})
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].since.Before(due[j].since) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	ids := make([]*blobpb.BlobId, len(due))
+	for i, c := range due {
+		ids[i] = &blobpb.BlobId{Value: []byte(c.key)}
+	}
+	return ids, nil
+}
+
+func (m *memory) ScanReadyOriginals(_ context.Context, cursor string, limit int) ([]*blob.Blob, string, error) {
+	after, err := hex.DecodeString(cursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	// Ids are visited in byte order, so the cursor is simply the last one
+	// visited.
+	keys := make([]string, 0, len(m.blobs))
+	for key := range m.blobs {
+		if key > string(after) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var page []*blob.Blob
+	for i, key := range keys {
+		if i == limit {
+			return page, hex.EncodeToString([]byte(keys[i-1])), nil
+		}
+		b := m.blobs[key]
+		if b.ParentID == nil && b.State == blob.StateReady {
+			page = append(page, b.Clone())
+		}
+	}
+	return page, "", nil
+}
+
+func (m *memory) GetOriginalsByOwner(_ context.Context, owner *commonpb.UserId, cursor string, limit int) ([]*blob.Blob, string, error) {
+	after, err := hex.DecodeString(cursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	// Like ScanReadyOriginals, ids are visited in byte order and the cursor is
+	// the last one returned.
+	var keys []string
+	for key, b := range m.blobs {
+		if key > string(after) && b.ParentID == nil && b.State != blob.StateDeleted && bytes.Equal(b.Owner.GetValue(), owner.Value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+		cursor = hex.EncodeToString([]byte(keys[limit-1]))
+	} else {
+		cursor = ""
+	}
+	page := make([]*blob.Blob, len(keys))
+	for i, key := range keys {
+		page[i] = m.blobs[key].Clone()
+	}
+	return page, cursor, nil
+}
+
+func (m *memory) Tombstone(_ context.Context, id *blobpb.BlobId, from blob.State) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	key := string(id.Value)
+	b, ok := m.blobs[key]
+	if !ok {
+		return false, blob.ErrNotFound
+	}
+	if b.State != from || b.State == blob.StateDeleted {
+		return false, nil
+	}
+
+	b.State = blob.StateDeleted
+	b.Renditions = nil
+	if b.Rejection == nil || !b.Rejection.Reason.IsContentVerdict() {
+		b.ContentHash = nil
+		delete(m.hashed, key)
+	}
+	delete(m.queue, key)
+	delete(m.collect, key)
+	if b.Owner != nil {
+		m.stored[string(b.Owner.Value)] -= b.SizeBytes
+	}
+	return true, nil
+}
+
+func (m *memory) SetContentHash(_ context.Context, id *blobpb.BlobId, hash []byte) error {
+	m.Lock()
+	defer m.Unlock()
+
+	key := string(id.Value)
+	b, ok := m.blobs[key]
+	if !ok {
+		return blob.ErrNotFound
+	}
+	if b.State == blob.StateDeleted {
+		return nil
+	}
+	b.ContentHash = append([]byte(nil), hash...)
+	if _, ok := m.hashed[key]; !ok {
+		m.hashed[key] = time.Now()
+	}
+	return nil
+}
+
+func (m *memory) GetByContentHash(_ context.Context, hash []byte, limit int) ([]*blob.Blob, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	type match struct {
+		b        *blob.Blob
+		hashedAt time.Time
+	}
+	matches := make([]match, 0)
+	for key, hashedAt := range m.hashed {
+		if b := m.blobs[key]; bytes.Equal(b.ContentHash, hash) {
+			matches = append(matches, match{b: b, hashedAt: hashedAt})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].hashedAt.Before(matches[j].hashedAt) })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	res := make([]*blob.Blob, len(matches))
+	for i, match := range matches {
+		res[i] = match.b.Clone()
+	}
+	return res, nil
+}
+
+func (m *memory) GetUsage(_ context.Context, owner *commonpb.UserId, day time.Time) (*blob.Usage, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return &blob.Usage{
+		StoredBytes: m.stored[string(owner.Value)],
+		DailyBytes:  m.daily[dailyKey(owner, day)],
+	}, nil
+}
+
+// dailyKey is the daily map key for owner's usage during the UTC day that
+// contains t.
+func dailyKey(owner *commonpb.UserId, t time.Time) string {
+	return string(owner.Value) + "|" + blob.UsageDay(t).Format(time.DateOnly)
+}
+
 func (m *memory) reset() {
 	m.Lock()
 	defer m.Unlock()
 
 	m.blobs = make(map[string]*blob.Blob)
 	m.queue = make(map[string]*queueEntry)
+	m.collect = make(map[string]time.Time)
+	m.hashed = make(map[string]time.Time)
+	m.stored = make(map[string]uint64)
+	m.daily = make(map[string]uint64)
 }
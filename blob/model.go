@@ -26,9 +26,9 @@ var (
 // ContentKind identifies which processing family a blob's bytes belong to:
 // which validation, moderation, and rendition pipeline they go through, and
 // which finalization queue they wait in. It is derived from the blob's pinned
-// MIME type, never stored on its own. Images are the only kind supported today;
-// video, audio, etc. each become their own kind — with their own queue and
-// worker tuning — as they are added.
+// MIME type, never stored on its own. Images and videos are supported today;
+// audio, etc. each become their own kind — with their own queue and worker
+// tuning — as they are added.
 //
 // The values are persisted (in finalization queue partition keys), so they must
 // be stable forever.
@@ -39,16 +39,24 @@ const (
 
 	// ContentKindImage is a still image.
 	ContentKindImage
+
+	// ContentKindVideo is a video in an MP4 or QuickTime container. Its bytes are
+	// served as uploaded; the server derives only poster-frame stills from it.
+	ContentKindVideo
 )
 
 // ContentKindForMimeType maps a declared MIME type to its processing family.
 // An unsupported type maps to ContentKindUnknown, which nothing may be queued
 // under.
 func ContentKindForMimeType(mimeType string) ContentKind {
-	if SupportedImageMimeTypes[mimeType] {
+	switch {
+	case SupportedImageMimeTypes[mimeType]:
 		return ContentKindImage
+	case SupportedVideoMimeTypes[mimeType]:
+		return ContentKindVideo
+	default:
+		return ContentKindUnknown
 	}
-	return ContentKindUnknown
 }
 
 // String names the kind for logs and metric dimensions.
@@ -56,6 +64,8 @@ func (k ContentKind) String() string {
 	switch k {
 	case ContentKindImage:
 		return "image"
+	case ContentKindVideo:
+		return "video"
 	default:
 		return "unknown"
 	}
@@ -90,19 +100,76 @@ const (
 	RenditionThumbnail
 )
 
-// ImageMetadata holds the server-derived, intrinsic descriptors of a still
-// image. Every field is derived once from the stored bytes and is immutable.
+// ImageMetadata holds the server-derived, intrinsic descriptors of an image.
+// Every field is derived once from the stored bytes and is immutable.
 //
 // This is the IMAGE variant of a blob's kind-specific metadata. It is populated
-// only for blobs whose bytes are an image; other content kinds (video, audio,
-// ...) will each carry their own distinct metadata type, mirroring the
-// blobpb.BlobMetadata.kind oneof. Images are simply the only kind supported
-// today.
+// only for blobs whose bytes are an image; other content kinds each carry their
+// own distinct metadata type (see VideoMetadata), mirroring the
+// blobpb.BlobMetadata.kind oneof.
+//
+// The wire ImageMetadata has no animation fields yet, so Animated and
+// FrameCount are server-side only for now: until the proto gains them, a client
+// learns an image is animated only by decoding the original (an APNG is served
+// as image/png, so the MIME type alone does not tell).
 type ImageMetadata struct {
 	Width    uint32
 	Height   uint32
 	Blurhash string
 	HasAlpha bool
+
+	// Animated is set on an animated original (GIF, APNG, animated WebP), whose
+	// Width, Height, and Blurhash describe its first frame. Its renditions are
+	// static thumbnails of that frame, so a client plays the original itself.
+	Animated bool
+
+	// FrameCount is an animated original's number of frames; zero for a still.
+	FrameCount uint32
+}
+
+// VideoMetadata holds the server-derived, intrinsic descriptors of a video,
+// read from its container structure during finalization. Like ImageMetadata it
+// is derived once and immutable.
+//
+// The wire BlobMetadata has no video variant yet, so these descriptors are
+// server-side only for now: a client learns a video's shape from its poster
+// renditions, which are ordinary images.
+type VideoMetadata struct {
+	// Width and Height are the presentation dimensions as displayed — Rotation
+	// already applied — so a portrait phone video recorded sideways reports a
+	// portrait size.
+	Width  uint32
+	Height uint32
+
+	// Duration is the presentation duration from the movie header.
+	Duration time.Duration
+
+	// Codec names the video track's codec family (e.g. "h264", "hevc").
+	Codec string
+
+	// Rotation is the clockwise display rotation, in degrees (0, 90, 180, or
+	// 270), the container asks players to apply.
+	Rotation uint32
+
+	// Blurhash is the BlurHash of the poster frame, the placeholder a client
+	// shows before the video or its poster loads.
+	Blurhash string
+}
+
+// DerivedMetadata carries the kind-specific metadata finalization derives from a
+// blob's bytes, persisted by Store.Advance. At most one field is set — the one
+// matching the blob's content kind.
+type DerivedMetadata struct {
+	Image *ImageMetadata
+	Video *VideoMetadata
+
+	// OriginSizeBytes is the size of the bytes finalization will promote in place
+	// of the upload, set only when it rewrote them (see WithSanitizeMetadata).
+	OriginSizeBytes uint64
+
+	// PerceptualHash is the perceptual hash of the blob's still: the image
+	// itself, or a video's poster frame.
+	PerceptualHash *PerceptualHash
 }
 
 // State is the blob's internal, fine-grained lifecycle state. It records how far
@@ -114,8 +181,10 @@ type ImageMetadata struct {
 //
 // The success path advances strictly forward — Pending → Uploaded → Inspected →
 // Promoted → GeneratingRenditions → Ready — with Rejected an alternative
-// terminal. The ordering of the constants is significant: a blob is only ever
-// advanced to a higher-ranked state.
+// terminal. Deleted is the garbage collector's tombstone, reachable from any
+// state once a blob's bytes are gone (see Collector). The ordering of the
+// constants is significant: a blob is only ever advanced to a higher-ranked
+// state.
 type State int
 
 const (
@@ -149,11 +218,17 @@ const (
 
 	// StateRejected means the bytes failed validation or moderation. Terminal.
 	StateRejected
+
+	// StateDeleted is the tombstone the garbage collector leaves once it has
+	// deleted a blob's objects: an abandoned reservation, a rejected upload, or
+	// a READY original nothing references any more. The record resolves as if it
+	// did not exist. Terminal.
+	StateDeleted
 )
 
 // Terminal reports whether no further processing is possible from this state.
 func (s State) Terminal() bool {
-	return s == StateReady || s == StateRejected
+	return s == StateReady || s == StateRejected || s == StateDeleted
 }
 
 // ToBlobStatus maps the internal state onto the public lifecycle status. A blob
@@ -171,6 +246,8 @@ func (s State) ToBlobStatus() blobpb.BlobStatus {
 	case StateRejected:
 		return blobpb.BlobStatus_BLOB_STATUS_REJECTED
 	default:
+		// A collected (StateDeleted) blob has no public status; callers treat it
+		// as not found before it gets here.
 		return blobpb.BlobStatus_BLOB_STATUS_UNKNOWN
 	}
 }
@@ -190,12 +267,31 @@ const (
 	RejectionReasonCorrupt
 	RejectionReasonInternal
 	RejectionReasonPrivacyMetadataPresent
+
+	// RejectionReasonBlocklisted is a near-duplicate of an image staff removed
+	// (see HashBlocklist). The wire enum has no reason of its own for it, so it
+	// surfaces as MODERATION, with no flagged category.
+	RejectionReasonBlocklisted
+
+	// RejectionReasonTakenDown is a READY blob staff took down after a report
+	// against it. Like RejectionReasonBlocklisted it surfaces as MODERATION, with
+	// no flagged category, and it is not a verdict an appeal can overturn.
+	RejectionReasonTakenDown
 )
 
+// IsContentVerdict reports whether the reason is a verdict on the uploaded bytes
+// themselves, which carries over to later uploads of the same bytes: they
+// failed moderation, or staff took them down. Other reasons either concern the
+// upload rather than its bytes (a mismatched declared type, an internal
+// failure), or are inspection's own, which re-running reproduces cheaply.
+func (r RejectionReason) IsContentVerdict() bool {
+	return r == RejectionReasonModeration || r == RejectionReasonTakenDown
+}
+
 // ToProto maps the internal reason onto the public blobpb.RejectionReason.
 func (r RejectionReason) ToProto() blobpb.RejectionReason {
 	switch r {
-	case RejectionReasonModeration:
+	case RejectionReasonModeration, RejectionReasonBlocklisted, RejectionReasonTakenDown:
 		return blobpb.RejectionReason_REJECTION_REASON_MODERATION
 	case RejectionReasonUnsupportedType:
 		return blobpb.RejectionReason_REJECTION_REASON_UNSUPPORTED_TYPE
@@ -222,6 +318,11 @@ type RejectionMetadata struct {
 	// FlaggedCategory is the moderation category that tripped, set only when
 	// Reason is RejectionReasonModeration; it is NONE (the zero value) otherwise.
 	FlaggedCategory moderationpb.FlaggedCategory
+
+	// StripFailed is set on a RejectionReasonPrivacyMetadataPresent rejection
+	// when sanitize mode tried to strip the metadata and could not, as opposed to
+	// the metadata merely being present on a deployment that does not strip.
+	StripFailed bool
 }
 
 // ToProto renders the rejection metadata for the wire. A nil receiver renders to
@@ -244,7 +345,8 @@ func (r *RejectionMetadata) ToProto() *blobpb.RejectionMetadata {
 // not match them. They are immutable for the life of the blob: finalization
 // re-validates the stored bytes against them and REJECTs the blob on any
 // mismatch rather than overwriting them. Only the derived kind-specific
-// metadata is filled in at finalization.
+// metadata is filled in at finalization — and, when finalization rewrote the
+// bytes it promoted, their size (see OriginSizeBytes).
 type Blob struct {
 	ID *blobpb.BlobId
 
@@ -276,13 +378,38 @@ type Blob struct {
 	// SizeBytes is the declared size, pinned at reservation and immutable.
 	SizeBytes uint64
 
+	// Multipart is set on an ORIGINAL reserved for a multipart upload (see
+	// Server.InitiateMultipartUpload), and nil for a single-request upload.
+	Multipart *MultipartUpload
+
+	// OriginSizeBytes is the size of the bytes promoted to the origin store, set
+	// only when finalization rewrote the upload rather than copying it (see
+	// WithSanitizeMetadata). Zero means the origin holds the upload verbatim.
+	OriginSizeBytes uint64
+
 	// Image is the derived IMAGE metadata, set only when this blob is an image
 	// and READY. It is the image variant of the blob's kind-specific metadata;
-	// as additional content kinds are supported they will be carried by their
-	// own sibling fields here (e.g. Video, Audio), one per blobpb.BlobMetadata
-	// kind variant. Only images exist today.
+	// each other content kind is carried by its own sibling field here, one per
+	// blobpb.BlobMetadata kind variant.
 	Image *ImageMetadata
 
+	// Video is the derived VIDEO metadata, set only when this blob is a video
+	// that passed inspection. A video's poster renditions are images and carry
+	// Image, not Video.
+	Video *VideoMetadata
+
+	// ContentHash is the SHA-256 of the uploaded bytes, recorded on an ORIGINAL
+	// once finalization has confirmed their size (see Store.SetContentHash), and
+	// dropped when the blob is collected unless its rejection is a verdict on
+	// them (see RejectionReason.IsContentVerdict). It is what lets a later upload
+	// of the same bytes reuse this blob's verdict and renditions.
+	ContentHash []byte
+
+	// PerceptualHash is the perceptual hash of an ORIGINAL's still — the image
+	// itself, or a video's poster frame — set once it passed inspection. It is
+	// what staff block when they remove the blob (see HashBlocklist).
+	PerceptualHash *PerceptualHash
+
 	// Renditions is the manifest of derived renditions, populated ONLY on an
 	// ORIGINAL and only once its renditions have been generated. Each entry is a
 	// compact, immutable copy of a child rendition blob's servable metadata,
@@ -297,6 +424,21 @@ type Blob struct {
 	// Rejection records why this blob was rejected, set only when State is
 	// StateRejected; it is nil for any non-rejected blob.
 	Rejection *RejectionMetadata
+
+	// ModerationBypassed is set once a moderation rejection of this blob was
+	// overturned on appeal (see Store.Reinstate). Finalization then skips
+	// moderation, and the rejected duplicates of its bytes it would otherwise
+	// defer to.
+	ModerationBypassed bool
+}
+
+// ServedSizeBytes is the size of the bytes a download of this blob returns: the
+// rewritten origin's when finalization sanitized it, else the declared size.
+func (b *Blob) ServedSizeBytes() uint64 {
+	if b.OriginSizeBytes != 0 {
+		return b.OriginSizeBytes
+	}
+	return b.SizeBytes
 }
 
 // RenditionRef is a single entry in an original's rendition manifest: the
@@ -374,6 +516,9 @@ func (b *Blob) Clone() *Blob {
 		StorageKey: b.StorageKey,
 		MimeType:   b.MimeType,
 		SizeBytes:  b.SizeBytes,
+
+		OriginSizeBytes:    b.OriginSizeBytes,
+		ModerationBypassed: b.ModerationBypassed,
 	}
 	if b.ID != nil {
 		cloned.ID = &blobpb.BlobId{Value: append([]byte(nil), b.ID.Value...)}
@@ -384,10 +529,25 @@ func (b *Blob) Clone() *Blob {
 	if b.Owner != nil {
 		cloned.Owner = &commonpb.UserId{Value: append([]byte(nil), b.Owner.Value...)}
 	}
+	if b.Multipart != nil {
+		multipart := *b.Multipart
+		cloned.Multipart = &multipart
+	}
 	if b.Image != nil {
 		image := *b.Image
 		cloned.Image = &image
 	}
+	if b.Video != nil {
+		video := *b.Video
+		cloned.Video = &video
+	}
+	if b.ContentHash != nil {
+		cloned.ContentHash = append([]byte(nil), b.ContentHash...)
+	}
+	if b.PerceptualHash != nil {
+		hash := *b.PerceptualHash
+		cloned.PerceptualHash = &hash
+	}
 	if b.Renditions != nil {
 		cloned.Renditions = make([]RenditionRef, len(b.Renditions))
 		for i, ref := range b.Renditions {
@@ -437,6 +597,24 @@ type FinalizationTask struct {
 	NextAttemptAt time.Time
 }
 
+// DeadLetter is a blob parked off its finalization queue once its attempts were
+// exhausted (see WithWorkerDeadLetter), awaiting an operator's retry.
+type DeadLetter struct {
+	ID   *blobpb.BlobId
+	Kind ContentKind
+
+	// LastError is the error of the final failed attempt.
+	LastError string
+
+	// Attempts is the number of failed attempts the blob was parked after.
+	Attempts uint32
+
+	// EnqueuedAt is when the blob was first queued for finalization, and
+	// DeadLetteredAt when it was parked.
+	EnqueuedAt     time.Time
+	DeadLetteredAt time.Time
+}
+
 func MustGenerateID() *blobpb.BlobId {
 	id, err := uuid.NewRandom()
 	if err != nil {
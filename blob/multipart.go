@@ -0,0 +1,56 @@
+package blob
+
+import (
+	"errors"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+)
+
+// multipartPartSizeBytes is the size of every part of a multipart upload but
+// the last, which carries the remainder. It sits above S3's 5 MiB minimum part
+// size, and is small enough that a part lost to a flaky mobile network is cheap
+// to send again.
+const multipartPartSizeBytes = 8 * 1024 * 1024
+
+// ErrUploadIncomplete is returned by ObjectStorage.CompleteMultipartUpload when
+// a part has not landed (or the upload is unknown and was never assembled).
+var ErrUploadIncomplete = errors.New("upload incomplete")
+
+// MultipartUpload is the multipart half of a reservation made through
+// Server.InitiateMultipartUpload: the storage's id for the in-progress upload,
+// and the size its parts were cut at.
+type MultipartUpload struct {
+	UploadID      string
+	PartSizeBytes uint64
+}
+
+// Parts is how many parts an upload of sizeBytes is cut into.
+func (m *MultipartUpload) Parts(sizeBytes uint64) int {
+	if sizeBytes == 0 {
+		return 1
+	}
+	return int((sizeBytes + m.PartSizeBytes - 1) / m.PartSizeBytes)
+}
+
+// PartSize is the size of the given (1-based) part of an upload of sizeBytes.
+func (m *MultipartUpload) PartSize(sizeBytes uint64, partNumber int) uint64 {
+	if partNumber < m.Parts(sizeBytes) {
+		return m.PartSizeBytes
+	}
+	return sizeBytes - uint64(partNumber-1)*m.PartSizeBytes
+}
+
+// MultipartUploadResult is the outcome of Server.InitiateMultipartUpload. Result
+// and PolicyVersion mean what they do on an InitiateExternalUploadResponse; the
+// rest is set only when Result is OK.
+type MultipartUploadResult struct {
+	Result        blobpb.InitiateExternalUploadResponse_Result
+	PolicyVersion *blobpb.PolicyVersion
+
+	BlobID        *blobpb.BlobId
+	PartSizeBytes uint64
+
+	// Parts holds one presigned target per part, in order: Parts[i] takes
+	// part i+1.
+	Parts []*blobpb.UploadTarget
+}
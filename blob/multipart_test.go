@@ -0,0 +1,33 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultipartUpload_Parts(t *testing.T) {
+	m := &MultipartUpload{PartSizeBytes: 10}
+
+	for _, tc := range []struct {
+		size  uint64
+		parts int
+		last  uint64
+	}{
+		{size: 0, parts: 1, last: 0},
+		{size: 1, parts: 1, last: 1},
+		{size: 10, parts: 1, last: 10},
+		{size: 11, parts: 2, last: 1},
+		{size: 25, parts: 3, last: 5},
+		{size: 30, parts: 3, last: 10},
+	} {
+		require.Equal(t, tc.parts, m.Parts(tc.size), "size %d", tc.size)
+		require.Equal(t, tc.last, m.PartSize(tc.size, tc.parts), "size %d", tc.size)
+
+		var total uint64
+		for part := 1; part <= tc.parts; part++ {
+			total += m.PartSize(tc.size, part)
+		}
+		require.Equal(t, tc.size, total, "size %d", tc.size)
+	}
+}
@@ -0,0 +1,165 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// ErrInvalidPerceptualHash is returned when parsing a malformed perceptual hash
+// string.
+var ErrInvalidPerceptualHash = errors.New("invalid perceptual hash")
+
+const (
+	// phashSampleSize is the side of the grayscale square a pHash is computed
+	// over, and phashLowFrequencies the side of the low-frequency corner of its
+	// DCT that the hash keeps.
+	phashSampleSize     = 32
+	phashLowFrequencies = 8
+
+	// dhashWidth and dhashHeight are the grayscale sample a dHash compares
+	// horizontally adjacent pixels across: 8 comparisons per row, 8 rows.
+	dhashWidth  = 9
+	dhashHeight = 8
+)
+
+// PerceptualHash is a pair of 64-bit perceptual fingerprints of an image. Unlike
+// a content hash, visually similar images — re-encoded, resized, lightly
+// recompressed or color-adjusted — hash to values a few bits apart, so the
+// Hamming distance between two hashes measures how alike the images look.
+//
+// PHash is the DCT-based hash: the sign of each low-frequency coefficient of a
+// 32x32 grayscale sample relative to their median. DHash is the gradient hash:
+// whether each pixel of a 9x8 grayscale sample is brighter than its right-hand
+// neighbour. They fail differently, so matching against both is far less prone
+// to false positives than matching against either.
+type PerceptualHash struct {
+	PHash uint64
+	DHash uint64
+}
+
+// ComputePerceptualHash returns the perceptual hash of img.
+func ComputePerceptualHash(img image.Image) PerceptualHash {
+	return PerceptualHash{
+		PHash: phash(img),
+		DHash: dhash(img),
+	}
+}
+
+// Distance returns the Hamming distance between h and other under each hash.
+func (h PerceptualHash) Distance(other PerceptualHash) (phash, dhash int) {
+	return bits.OnesCount64(h.PHash ^ other.PHash), bits.OnesCount64(h.DHash ^ other.DHash)
+}
+
+// Within reports whether h and other are within maxDistance bits of each other
+// under both hashes.
+func (h PerceptualHash) Within(other PerceptualHash, maxDistance int) bool {
+	p, d := h.Distance(other)
+	return p <= maxDistance && d <= maxDistance
+}
+
+// String renders the hash as 32 hex digits: the pHash, then the dHash.
+func (h PerceptualHash) String() string {
+	return fmt.Sprintf("%016x%016x", h.PHash, h.DHash)
+}
+
+// ParsePerceptualHash parses the String form of a perceptual hash.
+func ParsePerceptualHash(s string) (PerceptualHash, error) {
+	if len(s) != 32 {
+		return PerceptualHash{}, fmt.Errorf("%w: %q", ErrInvalidPerceptualHash, s)
+	}
+	p, err := strconv.ParseUint(s[:16], 16, 64)
+	if err != nil {
+		return PerceptualHash{}, fmt.Errorf("%w: %q", ErrInvalidPerceptualHash, s)
+	}
+	d, err := strconv.ParseUint(s[16:], 16, 64)
+	if err != nil {
+		return PerceptualHash{}, fmt.Errorf("%w: %q", ErrInvalidPerceptualHash, s)
+	}
+	return PerceptualHash{PHash: p, DHash: d}, nil
+}
+
+func phash(img image.Image) uint64 {
+	const n = phashSampleSize
+	samples := grayscaleSamples(img, n, n)
+
+	// A 2D DCT-II is separable: transform each row, then each column of the
+	// result.
+	cosines := make([]float64, n*n)
+	for k := range n {
+		for i := range n {
+			cosines[k*n+i] = math.Cos(math.Pi / n * (float64(i) + 0.5) * float64(k))
+		}
+	}
+	rows := make([]float64, n*n)
+	for y := range n {
+		for k := range n {
+			var sum float64
+			for x := range n {
+				sum += samples[y*n+x] * cosines[k*n+x]
+			}
+			rows[y*n+k] = sum
+		}
+	}
+
+	// Only the low-frequency corner carries the image's structure; the rest is
+	// detail that re-encoding and resizing disturb.
+	const m = phashLowFrequencies
+	low := make([]float64, 0, m*m)
+	for v := range m {
+		for u := range m {
+			var sum float64
+			for y := range n {
+				sum += rows[y*n+u] * cosines[v*n+y]
+			}
+			low = append(low, sum)
+		}
+	}
+
+	sorted := slices.Clone(low)
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range low {
+		if c > median {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+func dhash(img image.Image) uint64 {
+	samples := grayscaleSamples(img, dhashWidth, dhashHeight)
+
+	var hash uint64
+	for y := range dhashHeight {
+		for x := range dhashWidth - 1 {
+			if samples[y*dhashWidth+x] > samples[y*dhashWidth+x+1] {
+				hash |= 1 << (y*(dhashWidth-1) + x)
+			}
+		}
+	}
+	return hash
+}
+
+// grayscaleSamples shrinks img to width x height with an area-averaging filter,
+// so every source pixel contributes, and returns its luminance row by row.
+func grayscaleSamples(img image.Image, width, height int) []float64 {
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	samples := make([]float64, 0, width*height)
+	for y := range height {
+		for x := range width {
+			samples = append(samples, float64(dst.GrayAt(x, y).Y))
+		}
+	}
+	return samples
+}
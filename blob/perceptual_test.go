@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerceptualHash_NearDuplicatesMatch(t *testing.T) {
+	original := smoothImage(400, 300, 1)
+	hash := ComputePerceptualHash(original)
+
+	// A lossy re-encode and a downscale look the same, so they hash within the
+	// blocklist's distance.
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, original, &jpeg.Options{Quality: 40}))
+	reencoded, err := jpeg.Decode(&buf)
+	require.NoError(t, err)
+	require.True(t, hash.Within(ComputePerceptualHash(reencoded), blocklistMaxDistance))
+	require.True(t, hash.Within(ComputePerceptualHash(limitLongestSide(original, 120)), blocklistMaxDistance))
+
+	// Different images do not.
+	require.False(t, hash.Within(ComputePerceptualHash(smoothImage(400, 300, 2)), blocklistMaxDistance))
+	require.False(t, hash.Within(ComputePerceptualHash(randomImage(400, 300)), blocklistMaxDistance))
+}
+
+func TestPerceptualHash_StringRoundTrip(t *testing.T) {
+	hash := ComputePerceptualHash(smoothImage(64, 64, 1))
+	require.Len(t, hash.String(), 32)
+
+	parsed, err := ParsePerceptualHash(hash.String())
+	require.NoError(t, err)
+	require.Equal(t, hash, parsed)
+
+	for _, s := range []string{"", "abc", hash.String()[:31] + "z", hash.String() + "0"} {
+		_, err := ParsePerceptualHash(s)
+		require.ErrorIs(t, err, ErrInvalidPerceptualHash)
+	}
+}
+
+func TestMatchBlocklist(t *testing.T) {
+	hash := PerceptualHash{PHash: 0xffff, DHash: 0xff00}
+	near := &BlockedHash{Hash: PerceptualHash{PHash: 0xfff0, DHash: 0xff0f}}
+	far := &BlockedHash{Hash: PerceptualHash{PHash: 0xffff, DHash: ^uint64(0xff00)}}
+
+	require.Nil(t, MatchBlocklist(nil, hash))
+	require.Nil(t, MatchBlocklist([]*BlockedHash{far}, hash))
+	require.Same(t, near, MatchBlocklist([]*BlockedHash{far, near}, hash))
+}
+
+// smoothImage returns a deterministic, photo-like image of smooth gradients;
+// seed picks which one.
+func smoothImage(width, height int, seed float64) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			v := 128 + 127*math.Sin(seed*fx*6+fy*4)*math.Cos(fy*5*seed-fx*3)
+			img.Set(x, y, color.RGBA{R: uint8(v), G: uint8(255 - v), B: uint8(fx * 255), A: 255})
+		}
+	}
+	return img
+}
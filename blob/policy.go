@@ -31,12 +31,12 @@ var currentPolicy = buildUploadPolicy()
 var currentPolicyVersion = currentPolicy.Version
 
 // buildUploadPolicy assembles the upload policy advertised to clients: one
-// constraint entry per supported image MIME type, each pinned to the same byte,
-// dimension, and pixel ceilings the server enforces authoritatively when it
-// reserves the upload (InitiateExternalUpload) and inspects the stored bytes
-// (InspectImage). The policy is advisory — it lets a client validate and resize
-// before uploading — but it never advertises a limit the server does not itself
-// enforce. It is called once, to initialize currentPolicy.
+// constraint entry per supported MIME type, each pinned to the same byte (and,
+// for images, dimension and pixel) ceilings the server enforces authoritatively
+// when it reserves the upload (InitiateExternalUpload) and inspects the stored
+// bytes (InspectImage, InspectVideo). The policy is advisory — it lets a client
+// validate and resize before uploading — but it never advertises a limit the
+// server does not itself enforce. It is called once, to initialize currentPolicy.
 func buildUploadPolicy() *blobpb.UploadPolicy {
 	constraints := buildMimeTypeConstraints()
 	return &blobpb.UploadPolicy{
@@ -47,31 +47,41 @@ func buildUploadPolicy() *blobpb.UploadPolicy {
 }
 
 // buildMimeTypeConstraints returns the per-MIME-type constraints, one exact-type
-// entry for every image type the server accepts. Every entry is an exact type
-// (no wildcards), so the "most specific first" ordering the proto asks for is
-// trivially satisfied; they are emitted in a stable, sorted order so the derived
-// policy version is deterministic. There is deliberately no "image/*" or "*/*"
-// fallback: a type with no matching entry is one the server does not accept.
+// entry for every image and video type the server accepts. Every entry is an
+// exact type (no wildcards), so the "most specific first" ordering the proto asks
+// for is trivially satisfied; they are emitted in a stable, sorted order so the
+// derived policy version is deterministic. There is deliberately no "image/*" or
+// "*/*" fallback: a type with no matching entry is one the server does not accept.
+//
+// The wire constraints have no video variant yet, so a video entry carries only
+// its byte ceiling; the duration and dimension limits are enforced at inspection.
 func buildMimeTypeConstraints() []*blobpb.MimeTypeConstraints {
-	mimeTypes := make([]string, 0, len(SupportedImageMimeTypes))
+	mimeTypes := make([]string, 0, len(SupportedImageMimeTypes)+len(SupportedVideoMimeTypes))
 	for mimeType := range SupportedImageMimeTypes {
 		mimeTypes = append(mimeTypes, mimeType)
 	}
+	for mimeType := range SupportedVideoMimeTypes {
+		mimeTypes = append(mimeTypes, mimeType)
+	}
 	sort.Strings(mimeTypes)
 
 	constraints := make([]*blobpb.MimeTypeConstraints, 0, len(mimeTypes))
 	for _, mimeType := range mimeTypes {
-		constraints = append(constraints, &blobpb.MimeTypeConstraints{
+		kind := ContentKindForMimeType(mimeType)
+		constraint := &blobpb.MimeTypeConstraints{
 			MimeTypePattern: mimeType,
-			MaxSizeBytes:    MaxOriginalImageSizeBytes,
-			Kind: &blobpb.MimeTypeConstraints_Image{
+			MaxSizeBytes:    maxOriginalSizeBytes(kind),
+		}
+		if kind == ContentKindImage {
+			constraint.Kind = &blobpb.MimeTypeConstraints_Image{
 				Image: &blobpb.ImageConstraints{
 					MaxWidth:  maxImageDimension,
 					MaxHeight: maxImageDimension,
 					MaxPixels: maxImagePixels,
 				},
-			},
-		})
+			}
+		}
+		constraints = append(constraints, constraint)
 	}
 	return constraints
 }
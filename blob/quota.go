@@ -0,0 +1,88 @@
+package blob
+
+import (
+	"context"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/account"
+)
+
+// Usage is an owner's storage accounting, as maintained by the Store on blob
+// transitions (see Store.GetUsage).
+type Usage struct {
+	// StoredBytes is the declared size of every blob the owner holds that has
+	// not been collected — renditions included, whatever the blob's state.
+	StoredBytes uint64
+
+	// DailyBytes is the declared size of the ORIGINALs the owner reserved
+	// during the requested UTC day. Server-derived renditions are not uploads
+	// and do not count against it.
+	DailyBytes uint64
+}
+
+// Quota bounds how much an owner may upload. A zero limit is unbounded, so the
+// zero Quota exempts its holder entirely.
+type Quota struct {
+	// DailyBytes bounds the declared size of the originals reserved in one UTC
+	// day.
+	DailyBytes uint64
+
+	// TotalBytes bounds the owner's stored bytes, renditions included.
+	TotalBytes uint64
+}
+
+var (
+	// DefaultQuota is the quota in force for a regular account. The daily
+	// limit leaves room for a handful of maximum-size videos; the total limit
+	// is well above anything a chat participant accumulates in normal use.
+	DefaultQuota = Quota{
+		DailyBytes: 1024 * 1024 * 1024,      // 1 GiB
+		TotalBytes: 10 * 1024 * 1024 * 1024, // 10 GiB
+	}
+
+	// DefaultStaffQuota is the quota in force for a staff account: exempt.
+	DefaultStaffQuota = Quota{}
+)
+
+// allows reports whether an owner with the given usage may reserve another
+// sizeBytes under this quota.
+func (q Quota) allows(usage *Usage, sizeBytes uint64) bool {
+	if q.DailyBytes != 0 && usage.DailyBytes+sizeBytes > q.DailyBytes {
+		return false
+	}
+	if q.TotalBytes != 0 && usage.StoredBytes+sizeBytes > q.TotalBytes {
+		return false
+	}
+	return true
+}
+
+// UsageReport is an owner's current usage alongside the quota it is measured
+// against.
+type UsageReport struct {
+	Usage Usage
+	Quota Quota
+
+	// Day is the UTC day Usage.DailyBytes covers, truncated to midnight.
+	Day time.Time
+}
+
+// quotaFor resolves the quota in force for owner: staff accounts get the staff
+// quota, everyone else the regular one.
+func quotaFor(ctx context.Context, accounts account.Store, owner *commonpb.UserId, regular, staff Quota) (Quota, error) {
+	isStaff, err := accounts.IsStaff(ctx, owner)
+	if err != nil {
+		return Quota{}, err
+	}
+	if isStaff {
+		return staff, nil
+	}
+	return regular, nil
+}
+
+// UsageDay truncates t to the start of the UTC day it falls in — the bucket
+// daily usage is accounted under.
+func UsageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -2,6 +2,7 @@ package blob
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"math"
@@ -16,15 +17,16 @@ import (
 // Renditions are derived per CONTENT KIND: what variants a piece of media is
 // stored as, and how they are produced, depends on whether it is an image, a
 // video, audio, and so on. An image ladder is pixel sizes encoded as WebP; a
-// video's would be resolutions and bitrates plus a poster-frame still. Only images
-// are supported today, so this file holds the IMAGE rendition strategy —
-// everything named image* — alongside the kind-agnostic machinery every strategy
-// shares (the RenditionType roles, the deterministic-id derivation, and the
-// geometry helper).
+// video's would be resolutions and bitrates plus a poster-frame still. This file
+// holds the IMAGE rendition strategy — everything named image* — alongside the
+// kind-agnostic machinery every strategy shares (the RenditionType roles, the
+// deterministic-id derivation, and the geometry helper).
 //
-// When another kind is added, it brings its own ladder and generation (e.g.
-// videoRenditionSpecs and a generateVideoRenditions), selected by kind during
-// finalization; it does NOT widen the image ladder. The shared pieces below —
+// A video is served as uploaded today; its only renditions are poster stills,
+// which are images, so it reuses the image ladder over its poster frame rather
+// than carrying a ladder of its own. When video transcodes are added they bring
+// their own ladder and generation, selected by kind during finalization; they do
+// NOT widen the image ladder. The shared pieces below —
 // RenditionID, scaledDimensions, and RenditionType.ToProtoRole — are written to
 // serve any raster-or-timed kind, not just images.
 
@@ -131,7 +133,8 @@ var imageRenditionSpecs = []imageRenditionSpec{
 
 // imageEncoding is how a rendition's pixels are turned into bytes: the output
 // format plus the format-specific parameters that determine those bytes. Renditions
-// are always WebP today — it decodes on every client OS version we support and beats
+// are WebP — with an optional AVIF sibling per rung for clients that decode it (see
+// avifEncodingFor) — since WebP decodes on every client OS version we support and beats
 // both JPEG and PNG on size at equal quality, and it covers both a lossy mode for
 // photographs and a lossless mode for flat graphics, so a single format serves opaque
 // and transparent sources alike. The encoding is derived jointly from the role and the
@@ -170,6 +173,33 @@ func imageEncodingFor(rendition RenditionType, hasAlpha bool) imageEncoding {
 	return imageEncoding{mimeType: "image/webp", quality: quality}
 }
 
+// avifMimeType is the MIME type of the AVIF renditions (see AVIFEncoder).
+const avifMimeType = "image/avif"
+
+// AVIFEncoder encodes a rendition's pixels as AVIF. Go has no native AVIF
+// encoder, so — like VideoFrameExtractor — one is supplied by the wiring (e.g. a
+// libavif-backed implementation); without one the ladder carries no AVIF
+// renditions.
+type AVIFEncoder interface {
+	// EncodeAVIF encodes the opaque img as a lossy AVIF at the given quality
+	// (1-100).
+	EncodeAVIF(ctx context.Context, img image.Image, quality int) ([]byte, error)
+}
+
+// avifEncodingFor picks the encoding for a rendition's AVIF sibling: the same
+// rung as its WebP counterpart, encoded lossy at a quality tuned per role. AVIF's
+// quality scale runs lower than WebP's for the same perceived quality, so these
+// land visually alongside imageEncodingFor's WebP qualities at a substantially
+// smaller size. Only an opaque source gets one (see planImageRenditions): a
+// transparent one is kept lossless, which AVIF does not do smaller than WebP.
+func avifEncodingFor(rendition RenditionType) imageEncoding {
+	quality := 60
+	if rendition == RenditionThumbnail {
+		quality = 50
+	}
+	return imageEncoding{mimeType: avifMimeType, quality: quality}
+}
+
 // fingerprint is the encoding's contribution to a rendition id: the parameters that
 // determine the bytes for THIS format. Lossy WebP folds in its quality, so retuning
 // it mints new ids; lossless WebP's bytes are determined by the pixels alone, so it
@@ -208,29 +238,34 @@ func imageRenditionSlug(rendition RenditionType) string {
 }
 
 // imageRenditionStorageKey derives the object key an image rendition's bytes live
-// under. It groups renditions beneath the same per-media prefix as the original
-// (images/<uuid>/...) and names them by role and dimensions, so distinct rungs
+// under. It groups renditions beneath the same per-media prefix as their original
+// (images/<uuid>/... for an image, videos/<uuid>/... for a video's poster stills;
+// see storageKeyPrefix) and names them by role and dimensions, so distinct rungs
 // never collide and the key is self-describing:
 //
 //	images/<parent-uuid>/display_1600x900.webp
 //
 // The dimensions are in the key — not just the id — because two rungs of the same
 // role differ only by size, and a ladder retune must land on a new key rather than
-// overwrite the old bytes. Like the original's StorageKey it hardcodes the image
-// layout; another kind uses its own prefix and key scheme.
-func imageRenditionStorageKey(parentID *blobpb.BlobId, rendition RenditionType, width, height uint32, mimeType string) (string, error) {
+// overwrite the old bytes. The rendition itself is always an image, so only an
+// image mime type resolves an extension here.
+func imageRenditionStorageKey(parentKind ContentKind, parentID *blobpb.BlobId, rendition RenditionType, width, height uint32, mimeType string) (string, error) {
 	if err := parentID.Validate(); err != nil {
 		return "", err
 	}
+	prefix := storageKeyPrefix(parentKind)
+	if prefix == "" {
+		return "", fmt.Errorf("unsupported parent content kind %s for storage key", parentKind)
+	}
 	slug := imageRenditionSlug(rendition)
 	if slug == "" {
 		return "", fmt.Errorf("unsupported rendition type %d for storage key", rendition)
 	}
-	ext := extensionForMimeType(mimeType)
+	ext := mimeTypeToExtension[mimeType]
 	if ext == "" {
 		return "", fmt.Errorf("unsupported rendition mime type %q for storage key", mimeType)
 	}
-	return fmt.Sprintf("images/%s/%s_%dx%d%s", IDString(parentID), slug, width, height, ext), nil
+	return fmt.Sprintf("%s/%s/%s_%dx%d%s", prefix, IDString(parentID), slug, width, height, ext), nil
 }
 
 // resampleImage returns img scaled to width x height using a Catmull-Rom filter —
@@ -14,9 +14,9 @@ import (
 
 func TestScaledDimensions(t *testing.T) {
 	tests := []struct {
-		name                   string
-		w, h, max              uint32
-		wantW, wantH           uint32
+		name         string
+		w, h, max    uint32
+		wantW, wantH uint32
 	}{
 		{"already within bound is unchanged", 800, 600, 1600, 800, 600},
 		{"bound equal to longest side is unchanged", 1600, 900, 1600, 1600, 900},
@@ -73,21 +73,26 @@ func TestImageRenditionIDLosslessIgnoresQuality(t *testing.T) {
 func TestImageRenditionStorageKey(t *testing.T) {
 	parent := MustGenerateID()
 
-	key, err := imageRenditionStorageKey(parent, RenditionDisplay, 1600, 900, "image/webp")
+	key, err := imageRenditionStorageKey(ContentKindImage, parent, RenditionDisplay, 1600, 900, "image/webp")
 	require.NoError(t, err)
 	require.Equal(t, "images/"+IDString(parent)+"/display_1600x900.webp", key)
 
-	key, err = imageRenditionStorageKey(parent, RenditionThumbnail, 160, 90, "image/webp")
+	key, err = imageRenditionStorageKey(ContentKindImage, parent, RenditionThumbnail, 160, 90, "image/webp")
 	require.NoError(t, err)
 	require.Equal(t, "images/"+IDString(parent)+"/thumbnail_160x90.webp", key)
 
 	// An ORIGINAL is not a derived rendition, so it has no rendition slug.
-	_, err = imageRenditionStorageKey(parent, RenditionOriginal, 100, 100, "image/webp")
+	_, err = imageRenditionStorageKey(ContentKindImage, parent, RenditionOriginal, 100, 100, "image/webp")
 	require.Error(t, err)
 
 	// A non-image mime type has no extension in the image scheme.
-	_, err = imageRenditionStorageKey(parent, RenditionDisplay, 100, 100, "video/mp4")
+	_, err = imageRenditionStorageKey(ContentKindImage, parent, RenditionDisplay, 100, 100, "video/mp4")
 	require.Error(t, err)
+
+	// A video's poster stills are images grouped under the video's own prefix.
+	key, err = imageRenditionStorageKey(ContentKindVideo, parent, RenditionThumbnail, 160, 90, "image/webp")
+	require.NoError(t, err)
+	require.Equal(t, "videos/"+IDString(parent)+"/thumbnail_160x90.webp", key)
 }
 
 func TestImageEncodingFor(t *testing.T) {
@@ -109,6 +114,48 @@ func TestImageEncodingFor(t *testing.T) {
 	require.Equal(t, 80, display.quality)
 }
 
+func TestAVIFEncodingFor(t *testing.T) {
+	thumb := avifEncodingFor(RenditionThumbnail)
+	require.Equal(t, "image/avif", thumb.mimeType)
+	require.False(t, thumb.lossless)
+	require.Equal(t, 50, thumb.quality)
+
+	display := avifEncodingFor(RenditionDisplay)
+	require.Equal(t, "image/avif", display.mimeType)
+	require.Equal(t, 60, display.quality)
+
+	// The sibling is a distinct rendition of the same rung.
+	parent := MustGenerateID()
+	webp := imageRenditionID(parent, RenditionDisplay, 800, 600, imageEncodingFor(RenditionDisplay, false))
+	require.NotEqual(t, webp.Value, imageRenditionID(parent, RenditionDisplay, 800, 600, display).Value)
+
+	key, err := imageRenditionStorageKey(ContentKindImage, parent, RenditionDisplay, 800, 600, "image/avif")
+	require.NoError(t, err)
+	require.Equal(t, "images/"+IDString(parent)+"/display_800x600.avif", key)
+	require.False(t, SupportedImageMimeTypes["image/avif"], "AVIF is a rendition encoding, not an upload type")
+}
+
+func TestPlanImageRenditionsAVIFSiblings(t *testing.T) {
+	opaque := &ImageMetadata{Width: 1200, Height: 900}
+	webpOnly := planImageRenditions(opaque, false)
+	withAVIF := planImageRenditions(opaque, true)
+	require.Len(t, withAVIF, 2*len(webpOnly))
+
+	// Each rung is its WebP rendition followed by its AVIF sibling.
+	for i, plan := range webpOnly {
+		require.Equal(t, plan, withAVIF[2*i])
+		sibling := withAVIF[2*i+1]
+		require.Equal(t, plan.rendition, sibling.rendition)
+		require.Equal(t, plan.width, sibling.width)
+		require.Equal(t, plan.height, sibling.height)
+		require.Equal(t, avifEncodingFor(plan.rendition), sibling.encoding)
+	}
+
+	// A transparent original stays lossless WebP only.
+	transparent := &ImageMetadata{Width: 1200, Height: 900, HasAlpha: true}
+	require.Equal(t, planImageRenditions(transparent, false), planImageRenditions(transparent, true))
+}
+
 func TestImageRenditionSpecsLadder(t *testing.T) {
 	// The ladder is ordered small to large so hydration emits it in that order.
 	require.Equal(t, []imageRenditionSpec{
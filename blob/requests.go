@@ -0,0 +1,111 @@
+package blob
+
+import (
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+)
+
+// The requests below are for the Server methods backing unpublished
+// BlobStorage RPCs, each signed over its Payload (see auth.NewPayload).
+
+// GetStorageUsageRequest asks for the caller's storage usage.
+type GetStorageUsageRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *GetStorageUsageRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "GetStorageUsage")
+}
+
+// InitiateMultipartUploadRequest reserves a multipart upload for the caller.
+type InitiateMultipartUploadRequest struct {
+	MimeType  string
+	SizeBytes uint64
+	Ts        time.Time
+	Auth      *commonpb.Auth
+}
+
+func (r *InitiateMultipartUploadRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "InitiateMultipartUpload", r.MimeType, strconv.FormatUint(r.SizeBytes, 10))
+}
+
+// PresignUploadPartsRequest renews the targets of the caller's multipart upload.
+type PresignUploadPartsRequest struct {
+	BlobID *blobpb.BlobId
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *PresignUploadPartsRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "PresignUploadParts", hex.EncodeToString(r.BlobID.GetValue()))
+}
+
+// BlockBlobRequest adds a blob's perceptual hash to the hash blocklist.
+type BlockBlobRequest struct {
+	BlobID *blobpb.BlobId
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *BlockBlobRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "BlockBlob", hex.EncodeToString(r.BlobID.GetValue()))
+}
+
+// UnblockHashRequest removes a hash from the hash blocklist.
+type UnblockHashRequest struct {
+	Hash PerceptualHash
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *UnblockHashRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "UnblockHash", r.Hash.String())
+}
+
+// ListDeadLettersRequest lists a page of dead-lettered blobs of a kind.
+type ListDeadLettersRequest struct {
+	Kind  ContentKind
+	Limit int
+	Ts    time.Time
+	Auth  *commonpb.Auth
+}
+
+func (r *ListDeadLettersRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ListDeadLetters", r.Kind.String(), strconv.Itoa(r.Limit))
+}
+
+// RetryDeadLetterRequest re-queues one dead-lettered blob.
+type RetryDeadLetterRequest struct {
+	BlobID *blobpb.BlobId
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *RetryDeadLetterRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "RetryDeadLetter", hex.EncodeToString(r.BlobID.GetValue()))
+}
+
+// RetryDeadLettersRequest re-queues every dead-lettered blob of a kind.
+type RetryDeadLettersRequest struct {
+	Kind ContentKind
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *RetryDeadLettersRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "RetryDeadLetters", r.Kind.String())
+}
+
+// requestPayload is the signed payload of an unpublished BlobStorage request.
+func requestPayload(ts time.Time, method string, args ...string) proto.Message {
+	return auth.NewPayload("flipcash.blob.v1.BlobStorage/"+method, ts, args...)
+}
@@ -3,7 +3,11 @@
 // presigned policy in the UPLOAD bucket, the server reads them back to validate
 // them, and validated bytes are copied into the ORIGIN bucket (fronted by
 // CloudFront) and removed from the upload bucket. Downloads are short-lived
-// CloudFront signed URLs against the origin bucket. The server never proxies
+// CloudFront signed URLs against the origin bucket. A large upload may instead
+// go up as an S3 multipart upload of presigned UploadPart requests, assembled in
+// the upload bucket before anything reads it back. When a blob's last grant is
+// revoked, its cached copies are purged through a CloudFront invalidation. The
+// server never proxies
 // blob bytes — it only reads them back (via GetUploaded) to derive metadata
 // during finalization.
 package s3
@@ -19,13 +23,18 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/feature/cloudfront/sign"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
@@ -41,13 +50,21 @@ import (
 const (
 	sigV4Algorithm = "AWS4-HMAC-SHA256"
 	s3Service      = "s3"
+
+	// CloudFront is a global service: its API has a single endpoint, and requests
+	// to it are always signed for us-east-1.
+	cloudFrontService  = "cloudfront"
+	cloudFrontRegion   = "us-east-1"
+	cloudFrontEndpoint = "https://cloudfront.amazonaws.com/2020-05-31/distribution/"
+	cloudFrontXMLNS    = "http://cloudfront.amazonaws.com/doc/2020-05-31/"
 )
 
 // Config configures the two-bucket S3 + CloudFront backend.
 type Config struct {
-	// UploadBucket is the bucket clients upload to via presigned POST policies. It
-	// holds untrusted, not-yet-validated bytes and should carry a lifecycle rule
-	// to expire abandoned uploads.
+	// UploadBucket is the bucket clients upload to via presigned POST policies
+	// (or, for multipart uploads, presigned UploadPart requests). It holds
+	// untrusted, not-yet-validated bytes and should carry lifecycle rules to
+	// expire abandoned uploads and abort incomplete multipart uploads.
 	UploadBucket string
 
 	// OriginBucket is the bucket the CDN serves from. Only validated bytes,
@@ -82,6 +99,12 @@ type Config struct {
 	// CloudFrontKeyID is the CloudFront public key id paired with PrivateKey.
 	CloudFrontKeyID string
 
+	// DistributionID is the id of the CloudFront distribution behind CDNBaseURL
+	// (e.g. "E2QWRUHAPOMQZL"). InvalidateCDN creates invalidations on it. When
+	// empty, InvalidateCDN is a no-op and revoked blobs age out of edge caches
+	// on their own.
+	DistributionID string
+
 	// PrivateKey is the RSA private key whose public half is registered with
 	// CloudFront; it signs download URLs.
 	PrivateKey *rsa.PrivateKey
@@ -90,9 +113,10 @@ type Config struct {
 // Storage is the two-bucket S3 + CloudFront implementation of
 // blob.ObjectStorage.
 type Storage struct {
-	cfg    Config
-	client *s3.Client
-	signer *sign.URLSigner
+	cfg     Config
+	client  *s3.Client
+	presign *s3.PresignClient
+	signer  *sign.URLSigner
 }
 
 // NewStorage builds a Storage over an existing S3 client. The client is
@@ -101,9 +125,10 @@ type Storage struct {
 // credentials and the configured Region are used to sign upload POST policies.
 func NewStorage(client *s3.Client, cfg Config) *Storage {
 	return &Storage{
-		cfg:    cfg,
-		client: client,
-		signer: sign.NewURLSigner(cfg.CloudFrontKeyID, cfg.PrivateKey),
+		cfg:     cfg,
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		signer:  sign.NewURLSigner(cfg.CloudFrontKeyID, cfg.PrivateKey),
 	}
 }
 
@@ -227,6 +252,115 @@ func (s *Storage) PresignUpload(ctx context.Context, key, mimeType string, sizeB
 	}, nil
 }
 
+func (s *Storage) CreateMultipartUpload(ctx context.Context, key, mimeType string) (string, error) {
+	out, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.cfg.UploadBucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(mimeType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+func (s *Storage) PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int, sizeBytes uint64) (*blobpb.UploadTarget, error) {
+	// The Content-Length is a signed header, so S3 rejects a part of any other
+	// size, as the POST policy's content-length-range does for a whole upload.
+	req, err := s.presign.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.cfg.UploadBucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(int32(partNumber)),
+		ContentLength: aws.Int64(int64(sizeBytes)),
+	}, s3.WithPresignExpires(s.cfg.UploadTTL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload part: %w", err)
+	}
+
+	// Host is implied by the URL; every other signed header must be sent as is.
+	headers := make(map[string]string, len(req.SignedHeader))
+	for name, values := range req.SignedHeader {
+		if strings.EqualFold(name, "Host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+	headers["Content-Length"] = strconv.FormatUint(sizeBytes, 10)
+
+	return &blobpb.UploadTarget{
+		Method:    blobpb.UploadTarget_PUT,
+		Url:       req.URL,
+		Headers:   headers,
+		ExpiresAt: timestamppb.New(time.Now().Add(s.cfg.UploadTTL)),
+	}, nil
+}
+
+func (s *Storage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts int) error {
+	// S3 assembles the parts it is told about by ETag, so list what landed and
+	// refuse to complete until every part has.
+	etags := make(map[int32]string, parts)
+	pages := s3.NewListPartsPaginator(s.client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.cfg.UploadBucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if isNoSuchUpload(err) {
+			// Completed by an earlier call, or aborted: which one is told by
+			// whether the assembled object is there.
+			exists, err := s.UploadExists(ctx, key)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return blob.ErrUploadIncomplete
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to list upload parts: %w", err)
+		}
+		for _, part := range page.Parts {
+			etags[aws.ToInt32(part.PartNumber)] = aws.ToString(part.ETag)
+		}
+	}
+
+	completed := make([]s3types.CompletedPart, 0, parts)
+	for partNumber := int32(1); partNumber <= int32(parts); partNumber++ {
+		etag, ok := etags[partNumber]
+		if !ok {
+			return blob.ErrUploadIncomplete
+		}
+		completed = append(completed, s3types.CompletedPart{
+			PartNumber: aws.Int32(partNumber),
+			ETag:       aws.String(etag),
+		})
+	}
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.cfg.UploadBucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.cfg.UploadBucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil && !isNoSuchUpload(err) {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
 // uploadEndpoint is the URL the upload POST is sent to: the configured override
 // when set, otherwise the regional virtual-hosted-style endpoint for the bucket.
 func (s *Storage) uploadEndpoint(region string) string {
@@ -304,6 +438,43 @@ func (s *Storage) PutOrigin(ctx context.Context, key, mimeType string, data []by
 	return nil
 }
 
+func (s *Storage) GetOrigin(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.OriginBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, blob.ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to get origin object: %w", err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read origin object: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Storage) CopyOrigin(ctx context.Context, srcKey, dstKey string) error {
+	// A server-side copy within the origin bucket; CopyObject carries the
+	// source's content type over by default.
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.cfg.OriginBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(s.cfg.OriginBucket + "/" + srcKey),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return blob.ErrObjectNotFound
+		}
+		return fmt.Errorf("failed to copy object within origin bucket: %w", err)
+	}
+	return nil
+}
+
 func (s *Storage) DeleteUpload(ctx context.Context, key string) error {
 	// S3 DeleteObject is idempotent: deleting an absent key returns success.
 	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -315,6 +486,52 @@ func (s *Storage) DeleteUpload(ctx context.Context, key string) error {
 	return nil
 }
 
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	if prefix == "" {
+		return errors.New("refusing to delete an empty object prefix")
+	}
+	for _, bucket := range []string{s.cfg.UploadBucket, s.cfg.OriginBucket} {
+		if err := s.deletePrefix(ctx, bucket, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePrefix deletes every object under prefix in bucket, one listed page
+// (at most 1000 keys, the DeleteObjects limit) at a time.
+func (s *Storage) deletePrefix(ctx context.Context, bucket, prefix string) error {
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects for deletion: %w", err)
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		objects := make([]s3types.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, s3types.ObjectIdentifier{Key: object.Key})
+		}
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+		// A batch delete succeeds as a request even when individual keys fail.
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("failed to delete object %q: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
 func (s *Storage) SignDownloadURL(_ context.Context, key string) (*blobpb.DownloadUrl, error) {
 	expiresAt := time.Now().Add(s.cfg.DownloadTTL)
 	rawURL := strings.TrimRight(s.cfg.CDNBaseURL, "/") + "/" + key
@@ -328,6 +545,74 @@ func (s *Storage) SignDownloadURL(_ context.Context, key string) (*blobpb.Downlo
 	}, nil
 }
 
+// InvalidateCDN creates a CloudFront invalidation for every path under prefix.
+// It does not wait for the invalidation to complete; CloudFront typically
+// finishes one within a minute or two.
+//
+// The request is made against the CloudFront API directly, signed with the S3
+// client's credentials, since the only CloudFront call the backend makes does
+// not warrant another service client.
+func (s *Storage) InvalidateCDN(ctx context.Context, prefix string) error {
+	if prefix == "" {
+		return errors.New("refusing to invalidate an empty object prefix")
+	}
+	if s.cfg.DistributionID == "" {
+		return nil
+	}
+
+	// The caller reference only has to be unique per distribution: CloudFront
+	// treats a repeated one as a retry of the same invalidation.
+	now := time.Now().UTC()
+	body, err := xml.Marshal(invalidationBatch{
+		XMLNS:           cloudFrontXMLNS,
+		Paths:           invalidationPaths{Quantity: 1, Items: []string{"/" + prefix + "*"}},
+		CallerReference: fmt.Sprintf("%s@%d", prefix, now.UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := cloudFrontEndpoint + url.PathEscape(s.cfg.DistributionID) + "/invalidation"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+
+	creds, err := s.client.Options().Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), cloudFrontService, cloudFrontRegion, now); err != nil {
+		return fmt.Errorf("failed to sign invalidation: %w", err)
+	}
+
+	resp, err := s.client.Options().HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to create invalidation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to create invalidation: %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// invalidationBatch is the body of a CloudFront CreateInvalidation request.
+type invalidationBatch struct {
+	XMLName         xml.Name          `xml:"InvalidationBatch"`
+	XMLNS           string            `xml:"xmlns,attr"`
+	Paths           invalidationPaths `xml:"Paths"`
+	CallerReference string            `xml:"CallerReference"`
+}
+
+type invalidationPaths struct {
+	Quantity int      `xml:"Quantity"`
+	Items    []string `xml:"Items>Path"`
+}
+
 // sigV4SigningKey derives the SigV4 signing key for the S3 service.
 func sigV4SigningKey(secret, shortDate, region string) []byte {
 	kDate := hmacSHA256([]byte("AWS4"+secret), shortDate)
@@ -342,6 +627,17 @@ func hmacSHA256(key []byte, data string) []byte {
 	return h.Sum(nil)
 }
 
+// isNoSuchUpload reports whether an S3 error means the multipart upload is not
+// in progress: it was completed or aborted.
+func isNoSuchUpload(err error) bool {
+	var noSuchUpload *s3types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchUpload"
+}
+
 // isNotFound reports whether an S3 error means the object does not exist.
 func isNotFound(err error) bool {
 	var noSuchKey *s3types.NoSuchKey
@@ -0,0 +1,369 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+
+	"github.com/gen2brain/webp"
+)
+
+// ErrImageStripFailed means an image's privacy metadata could not be stripped
+// server-side (see SanitizeImage), so it must be rejected as uploaded.
+var ErrImageStripFailed = errors.New("image metadata could not be stripped")
+
+const (
+	// sanitizeJPEGQuality is the quality a JPEG is re-encoded at when its
+	// orientation has to be baked into the pixels. High, since the result replaces
+	// the original rather than being a rendition of it.
+	sanitizeJPEGQuality = 92
+
+	// sanitizeWebPQuality is the lossy WebP counterpart of sanitizeJPEGQuality.
+	sanitizeWebPQuality = 90
+)
+
+// SanitizeImage returns data with its privacy metadata stripped — the segments
+// and chunks hasPrivacyMetadata rejects — keeping everything else, the color
+// management data included, byte for byte.
+//
+// Stripping EXIF discards its Orientation tag, which is load-bearing for display.
+// An image that declares a non-identity orientation therefore has it applied to
+// the pixels and is re-encoded in its own format, which is the one case where the
+// pixel data changes; an animation cannot be re-encoded that way, so it fails.
+//
+// The result is not re-verified here; the caller runs it back through
+// InspectImage. Any failure wraps ErrImageStripFailed.
+func SanitizeImage(data []byte) ([]byte, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %v: %w", err, ErrImageStripFailed)
+	}
+
+	orientation := exifOrientation(format, data)
+	stripped, err := stripPrivacyMetadata(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to strip %s metadata: %v: %w", format, err, ErrImageStripFailed)
+	}
+	if orientation == 1 {
+		return stripped, nil
+	}
+	if isImageAnimated(format, data) {
+		return nil, fmt.Errorf("cannot apply exif orientation %d to an animated %s: %w", orientation, format, ErrImageStripFailed)
+	}
+	reoriented, err := reorientImage(format, stripped, orientation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply exif orientation %d: %v: %w", orientation, err, ErrImageStripFailed)
+	}
+	return reoriented, nil
+}
+
+// stripPrivacyMetadata structurally removes the metadata segments and chunks
+// from an encoded image, decoding no pixels.
+func stripPrivacyMetadata(format string, data []byte) ([]byte, error) {
+	switch format {
+	case "jpeg":
+		return stripJPEG(data)
+	case "png":
+		return stripPNG(data)
+	case "webp":
+		return stripWebP(data)
+	case "gif":
+		return stripGIF(data)
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+}
+
+// stripJPEG drops a JPEG's comment and disallowed APPn segments, copying the
+// rest — and everything from the start of scan on — verbatim.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := []byte{0xFF, 0xD8}
+	scan, err := walkJPEGSegments(data, func(marker byte, segment []byte) {
+		if marker != 0xD8 && !jpegIsMetadataSegment(marker) {
+			out = append(out, segment...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(out, data[scan:]...), nil
+}
+
+// stripPNG drops a PNG's pngMetadataChunks, rewriting the rest.
+func stripPNG(data []byte) ([]byte, error) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	for _, chunk := range chunks {
+		if !pngMetadataChunks[chunk.typ] {
+			writePNGChunk(&buf, chunk.typ, chunk.data)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// VP8X flag bits announcing the metadata chunks an extended WebP carries.
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP drops a WebP's EXIF and XMP chunks, clears the VP8X flags that
+// announced them, and rewrites the RIFF size to match.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("not a webp stream")
+	}
+	out := append([]byte(nil), data[:12]...)
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, errors.New("truncated webp chunk")
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		// Chunk payloads are padded to an even length; tolerate a final chunk
+		// missing its padding byte.
+		end := pos + 8 + size + (size & 1)
+		if size < 0 || pos+8+size > len(data) {
+			return nil, errors.New("truncated webp chunk")
+		}
+		end = min(end, len(data))
+		chunk := data[pos:end]
+		switch string(chunk[:4]) {
+		case "EXIF", "XMP ":
+			pos = end
+			continue
+		case "VP8X":
+			if len(chunk) > 8 {
+				chunk = append([]byte(nil), chunk...)
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		}
+		out = append(out, chunk...)
+		pos = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// stripGIF drops a GIF's comment and XMP extensions (see gifIsMetadataBlock).
+func stripGIF(data []byte) ([]byte, error) {
+	header, err := gifHeaderLen(data)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := gifBlocks(data)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), data[:header]...)
+	for _, block := range blocks {
+		if !gifIsMetadataBlock(block) {
+			out = append(out, block.raw...)
+		}
+	}
+	return append(out, 0x3B), nil
+}
+
+// exifOrientation returns the EXIF Orientation (1-8) an encoded image declares,
+// or 1 — the identity — when it carries no EXIF, or none that parses.
+func exifOrientation(format string, data []byte) int {
+	var tiff []byte
+	switch format {
+	case "jpeg":
+		_, _ = walkJPEGSegments(data, func(marker byte, segment []byte) {
+			const exifHeader = "Exif\x00\x00"
+			if marker == 0xE1 && tiff == nil && bytes.HasPrefix(segment[4:], []byte(exifHeader)) {
+				tiff = segment[4+len(exifHeader):]
+			}
+		})
+	case "png":
+		chunks, _ := pngChunks(data)
+		for _, chunk := range chunks {
+			if chunk.typ == "eXIf" {
+				tiff = chunk.data
+				break
+			}
+		}
+	case "webp":
+		for pos := 12; pos+8 <= len(data); {
+			size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+			if string(data[pos:pos+4]) == "EXIF" && pos+8+size <= len(data) {
+				// Some writers keep the JPEG APP1 header on the payload.
+				tiff = bytes.TrimPrefix(data[pos+8:pos+8+size], []byte("Exif\x00\x00"))
+				break
+			}
+			pos += 8 + size + (size & 1)
+		}
+	}
+	return tiffOrientation(tiff)
+}
+
+// tiffOrientation reads the Orientation tag (0x0112) out of IFD0 of a TIFF-
+// structured EXIF block, returning 1 when it is absent or out of range.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := range entries {
+		// Each entry: tag(2) type(2) count(4) value(4); a SHORT value is
+		// left-justified in the value field.
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// reorientImage decodes a (stripped) still image, applies orientation to its
+// pixels, and re-encodes it in the same format. The color-management data the
+// original carried is spliced back into the result, so the colors don't shift;
+// WebP is the exception, as carrying an ICC profile would mean rebuilding the
+// extended container around the encoder's output.
+func reorientImage(format string, data []byte, orientation int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	oriented := applyOrientation(img, orientation)
+
+	switch format {
+	case "jpeg":
+		encoded, err := encodeJPEG(oriented, sanitizeJPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		// Carry the ICC profile (APP2) across, right after SOI.
+		var icc []byte
+		_, _ = walkJPEGSegments(data, func(marker byte, segment []byte) {
+			if marker == 0xE2 {
+				icc = append(icc, segment...)
+			}
+		})
+		out := append([]byte(nil), encoded[:2]...)
+		out = append(out, icc...)
+		return append(out, encoded[2:]...), nil
+	case "png":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, oriented); err != nil {
+			return nil, err
+		}
+		return withPNGColorChunks(buf.Bytes(), data)
+	case "webp":
+		var buf bytes.Buffer
+		if err := webp.Encode(&buf, oriented, webp.Options{Quality: sanitizeWebPQuality, Method: webpRenditionEncodeMethod}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+}
+
+// pngColorChunks are the color-management chunks, which must precede the image
+// data and so are placed right after IHDR.
+var pngColorChunks = map[string]bool{
+	"iCCP": true,
+	"sRGB": true,
+	"gAMA": true,
+	"cHRM": true,
+}
+
+// withPNGColorChunks copies the original's color-management chunks into a
+// freshly encoded PNG, which carries none of its own.
+func withPNGColorChunks(encoded, original []byte) ([]byte, error) {
+	encodedChunks, err := pngChunks(encoded)
+	if err != nil {
+		return nil, err
+	}
+	originalChunks, err := pngChunks(original)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	for _, chunk := range encodedChunks {
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+		if chunk.typ != "IHDR" {
+			continue
+		}
+		for _, color := range originalChunks {
+			if pngColorChunks[color.typ] {
+				writePNGChunk(&buf, color.typ, color.data)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// applyOrientation returns img transformed so it displays upright, per the EXIF
+// Orientation values: 2-4 mirror and/or rotate by 180 degrees, and 5-8 transpose
+// the axes, swapping the dimensions. The result has straight alpha.
+func applyOrientation(img image.Image, orientation int) *image.NRGBA {
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	if orientation == 1 {
+		return src
+	}
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counter-clockwise
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
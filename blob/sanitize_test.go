@@ -0,0 +1,260 @@
+package blob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/gen2brain/webp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeImageStripsMetadata(t *testing.T) {
+	still := testFrames(1, 24, 16)[0]
+	jpegData, err := encodeJPEG(still, 90)
+	require.NoError(t, err)
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, still))
+
+	for _, tc := range []struct {
+		name   string
+		format string
+		data   []byte
+	}{
+		{"jpeg exif and comment", "jpeg", withJPEGSegments(jpegData,
+			jpegTestSegment(0xE1, append([]byte("Exif\x00\x00"), testEXIF(binary.BigEndian, 1)...)),
+			jpegTestSegment(0xFE, []byte("taken at home")),
+		)},
+		{"png exif and text", "png", withPNGChunks(t, pngData.Bytes(),
+			pngChunk{typ: "eXIf", data: testEXIF(binary.LittleEndian, 1)},
+			pngChunk{typ: "tEXt", data: []byte("Author\x00someone")},
+		)},
+		{"webp exif and xmp", "webp", encodeTestExtendedWebP(t, still,
+			pngChunk{typ: "EXIF", data: testEXIF(binary.LittleEndian, 1)},
+			pngChunk{typ: "XMP ", data: []byte("<x:xmpmeta/>")},
+		)},
+		{"gif comment", "gif", gifWithExtension(t, 0xFE, []byte("taken at home"))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.True(t, hasPrivacyMetadata(tc.format, tc.data))
+			_, err := InspectImage(tc.data)
+			require.ErrorIs(t, err, ErrImagePrivacyMetadata)
+
+			sanitized, err := SanitizeImage(tc.data)
+			require.NoError(t, err)
+			require.False(t, hasPrivacyMetadata(tc.format, sanitized))
+			inspection, err := InspectImage(sanitized)
+			require.NoError(t, err)
+			require.EqualValues(t, 24, inspection.Metadata.Width)
+			require.EqualValues(t, 16, inspection.Metadata.Height)
+		})
+	}
+}
+
+func TestSanitizeImageKeepsColorManagement(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, testFrames(1, 8, 8)[0]))
+	gama := binary.BigEndian.AppendUint32(nil, 45455)
+	data := withPNGChunks(t, encoded.Bytes(),
+		pngChunk{typ: "gAMA", data: gama},
+		pngChunk{typ: "eXIf", data: testEXIF(binary.BigEndian, 1)},
+	)
+
+	sanitized, err := SanitizeImage(data)
+	require.NoError(t, err)
+	chunks, err := pngChunks(sanitized)
+	require.NoError(t, err)
+	var types []string
+	for _, chunk := range chunks {
+		types = append(types, chunk.typ)
+	}
+	require.Contains(t, types, "gAMA")
+	require.NotContains(t, types, "eXIf")
+}
+
+func TestSanitizeImageAppliesOrientation(t *testing.T) {
+	// A 4x2 image with only its top-left pixel red.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	fill(src, color.RGBA{B: 255, A: 255})
+	src.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, src))
+
+	for _, tc := range []struct {
+		orientation   int
+		width, height int
+		redX, redY    int
+	}{
+		{orientation: 2, width: 4, height: 2, redX: 3, redY: 0},
+		{orientation: 3, width: 4, height: 2, redX: 3, redY: 1},
+		{orientation: 6, width: 2, height: 4, redX: 1, redY: 0},
+		{orientation: 8, width: 2, height: 4, redX: 0, redY: 3},
+	} {
+		data := withPNGChunks(t, encoded.Bytes(), pngChunk{typ: "eXIf", data: testEXIF(binary.BigEndian, uint16(tc.orientation))})
+		sanitized, err := SanitizeImage(data)
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(sanitized))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, tc.width, tc.height), img.Bounds(), "orientation %d", tc.orientation)
+		r, _, b, _ := img.At(tc.redX, tc.redY).RGBA()
+		require.True(t, r == 0xFFFF && b == 0, "orientation %d", tc.orientation)
+	}
+
+	t.Run("jpeg keeps its icc profile", func(t *testing.T) {
+		jpegData, err := encodeJPEG(testFrames(1, 40, 20)[0], 90)
+		require.NoError(t, err)
+		data := withJPEGSegments(jpegData,
+			jpegTestSegment(0xE1, append([]byte("Exif\x00\x00"), testEXIF(binary.LittleEndian, 6)...)),
+			jpegTestSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01not a real profile")),
+		)
+
+		sanitized, err := SanitizeImage(data)
+		require.NoError(t, err)
+		require.Contains(t, string(sanitized), "ICC_PROFILE")
+		inspection, err := InspectImage(sanitized)
+		require.NoError(t, err)
+		require.EqualValues(t, 20, inspection.Metadata.Width)
+		require.EqualValues(t, 40, inspection.Metadata.Height)
+	})
+}
+
+func TestSanitizeImageFailsOnOrientedAnimation(t *testing.T) {
+	data := withPNGChunks(t, encodeTestAPNG(t, testFrames(3, 8, 8), 10),
+		pngChunk{typ: "eXIf", data: testEXIF(binary.BigEndian, 6)},
+	)
+	_, err := SanitizeImage(data)
+	require.ErrorIs(t, err, ErrImageStripFailed)
+
+	// Without an orientation to apply, the animation is stripped in place.
+	data = withPNGChunks(t, encodeTestAPNG(t, testFrames(3, 8, 8), 10),
+		pngChunk{typ: "eXIf", data: testEXIF(binary.BigEndian, 1)},
+	)
+	sanitized, err := SanitizeImage(data)
+	require.NoError(t, err)
+	inspection, err := InspectImage(sanitized)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, inspection.Metadata.FrameCount)
+}
+
+func TestFinalizerSanitizeRejection(t *testing.T) {
+	data := withPNGChunks(t, encodeTestAPNG(t, testFrames(3, 8, 8), 10),
+		pngChunk{typ: "eXIf", data: testEXIF(binary.BigEndian, 6)},
+	)
+
+	_, rejection, err := (&Finalizer{}).inspectImage(data)
+	require.NoError(t, err)
+	require.Equal(t, RejectionReasonPrivacyMetadataPresent, rejection.Reason)
+	require.False(t, rejection.StripFailed)
+
+	_, rejection, err = (&Finalizer{sanitize: true}).inspectImage(data)
+	require.NoError(t, err)
+	require.Equal(t, RejectionReasonPrivacyMetadataPresent, rejection.Reason)
+	require.True(t, rejection.StripFailed)
+}
+
+func TestTIFFOrientation(t *testing.T) {
+	require.Equal(t, 6, tiffOrientation(testEXIF(binary.BigEndian, 6)))
+	require.Equal(t, 8, tiffOrientation(testEXIF(binary.LittleEndian, 8)))
+	require.Equal(t, 1, tiffOrientation(testEXIF(binary.BigEndian, 9)))
+	require.Equal(t, 1, tiffOrientation(nil))
+	require.Equal(t, 1, tiffOrientation([]byte("not tiff at all")))
+}
+
+// testEXIF returns a TIFF-structured EXIF block whose IFD0 holds only the
+// Orientation tag.
+func testEXIF(order binary.AppendByteOrder, orientation uint16) []byte {
+	var out []byte
+	if order == binary.LittleEndian {
+		out = []byte("II")
+	} else {
+		out = []byte("MM")
+	}
+	out = order.AppendUint16(out, 42)
+	out = order.AppendUint32(out, 8)
+	out = order.AppendUint16(out, 1)           // one entry
+	out = order.AppendUint16(out, 0x0112)      // Orientation
+	out = order.AppendUint16(out, 3)           // SHORT
+	out = order.AppendUint32(out, 1)           // count
+	out = order.AppendUint16(out, orientation) // value, left-justified
+	out = order.AppendUint16(out, 0)
+	return order.AppendUint32(out, 0) // no next IFD
+}
+
+func jpegTestSegment(marker byte, payload []byte) []byte {
+	out := []byte{0xFF, marker}
+	out = binary.BigEndian.AppendUint16(out, uint16(2+len(payload)))
+	return append(out, payload...)
+}
+
+// withJPEGSegments inserts raw marker segments right after a JPEG's SOI.
+func withJPEGSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte(nil), data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+// withPNGChunks inserts chunks right after a PNG's IHDR.
+func withPNGChunks(t *testing.T, data []byte, extra ...pngChunk) []byte {
+	t.Helper()
+	chunks, err := pngChunks(data)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	for _, chunk := range chunks {
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+		if chunk.typ == "IHDR" {
+			for _, e := range extra {
+				writePNGChunk(&buf, e.typ, e.data)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// encodeTestExtendedWebP wraps a lossless WebP's bitstream in an extended (VP8X)
+// container carrying the given extra chunks, flagged as the container requires.
+func encodeTestExtendedWebP(t *testing.T, img image.Image, extra ...pngChunk) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	require.NoError(t, webp.Encode(&encoded, img, webp.Options{Lossless: true}))
+	bitstream := encoded.Bytes()[12:]
+
+	bounds := img.Bounds()
+	vp8x := make([]byte, 10)
+	for _, e := range extra {
+		switch e.typ {
+		case "EXIF":
+			vp8x[0] |= webpFlagEXIF
+		case "XMP ":
+			vp8x[0] |= webpFlagXMP
+		}
+	}
+	w, h := uint32(bounds.Dx()-1), uint32(bounds.Dy()-1)
+	vp8x[4], vp8x[5], vp8x[6] = byte(w), byte(w>>8), byte(w>>16)
+	vp8x[7], vp8x[8], vp8x[9] = byte(h), byte(h>>8), byte(h>>16)
+
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	out = appendWebPChunk(out, "VP8X", vp8x)
+	out = append(out, bitstream...)
+	for _, e := range extra {
+		out = appendWebPChunk(out, e.typ, e.data)
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
+func appendWebPChunk(out []byte, fourcc string, payload []byte) []byte {
+	out = append(out, fourcc...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
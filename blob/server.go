@@ -35,9 +35,49 @@ type Server struct {
 
 	requireStaff bool
 
+	// quota and staffQuota bound how much a regular and a staff account may
+	// upload, respectively.
+	quota      Quota
+	staffQuota Quota
+
+	// blocklist is the hash blocklist staff curate through BlockBlob and
+	// UnblockHash; nil disables both.
+	blocklist HashBlocklist
+
+	// partSize is the size multipart uploads are cut into.
+	partSize uint64
+
 	blobpb.UnimplementedBlobStorageServer
 }
 
+// ServerOption overrides one of the server's knobs.
+type ServerOption func(*Server)
+
+// WithQuota overrides the upload quota in force for regular accounts.
+func WithQuota(quota Quota) ServerOption {
+	return func(s *Server) { s.quota = quota }
+}
+
+// WithStaffQuota overrides the upload quota in force for staff accounts, which
+// are exempt by default.
+func WithStaffQuota(quota Quota) ServerOption {
+	return func(s *Server) { s.staffQuota = quota }
+}
+
+// WithHashBlocklistAdmin enables the staff operations that curate the hash
+// blocklist (BlockBlob and UnblockHash). Finalization screens uploads against
+// the same blocklist through its own option (see WithHashBlocklist).
+func WithHashBlocklistAdmin(blocklist HashBlocklist) ServerOption {
+	return func(s *Server) { s.blocklist = blocklist }
+}
+
+// WithMultipartPartSize overrides the size multipart uploads are cut into. S3
+// refuses parts under 5 MiB (but the last), so it only goes lower against other
+// storages.
+func WithMultipartPartSize(sizeBytes uint64) ServerOption {
+	return func(s *Server) { s.partSize = sizeBytes }
+}
+
 func NewServer(
 	log *zap.Logger,
 	authz auth.Authorizer,
@@ -47,8 +87,9 @@ func NewServer(
 	access AccessStore,
 	resolver PrincipalResolver,
 	requireStaff bool,
+	opts ...ServerOption,
 ) *Server {
-	return &Server{
+	s := &Server{
 		log:          log,
 		authz:        authz,
 		accounts:     accounts,
@@ -57,7 +98,14 @@ func NewServer(
 		access:       access,
 		resolver:     resolver,
 		requireStaff: requireStaff,
+		quota:        DefaultQuota,
+		staffQuota:   DefaultStaffQuota,
+		partSize:     multipartPartSizeBytes,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // GetUploadPolicy returns the upload constraints in force for the caller. It is
@@ -99,6 +147,181 @@ func (s *Server) InitiateExternalUpload(ctx context.Context, req *blobpb.Initiat
 		zap.Uint64("size_bytes", req.SizeBytes),
 	)
 
+	denial, err := s.admitUpload(ctx, owner, req.MimeType, req.SizeBytes, log)
+	if err != nil || denial != nil {
+		return denial, err
+	}
+
+	id := MustGenerateID()
+	log = log.With(zap.String("blob_id", IDString(id)))
+
+	// admitUpload validated the mime type as a supported kind, so this resolves; an
+	// error here would mean the kind and storage layout mappings drifted.
+	key, err := StorageKey(id, req.MimeType)
+	if err != nil {
+		log.Warn("Failed to derive storage key", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+
+	target, err := s.storage.PresignUpload(ctx, key, req.MimeType, req.SizeBytes)
+	if err != nil {
+		log.Warn("Failed to presign upload target", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+
+	record := &Blob{
+		ID:         id,
+		Rendition:  RenditionOriginal,
+		Owner:      owner,
+		State:      StatePending,
+		StorageKey: key,
+		MimeType:   req.MimeType,
+		SizeBytes:  req.SizeBytes,
+	}
+	if err := s.blobs.CreatePending(ctx, record); err != nil {
+		log.Warn("Failed to reserve blob", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+
+	return &blobpb.InitiateExternalUploadResponse{
+		Result:       blobpb.InitiateExternalUploadResponse_OK,
+		BlobId:       id,
+		UploadTarget: target,
+	}, nil
+}
+
+// InitiateMultipartUpload reserves an upload like InitiateExternalUpload, subject
+// to the same checks, but as a multipart upload: the bytes go up as fixed-size
+// parts (the last carrying the remainder; see WithMultipartPartSize), each to
+// its own presigned target, so a large video on a flaky network resends only the
+// part that failed rather than the whole upload. Once every part has landed,
+// CompleteExternalUpload assembles them into the upload store, from where the
+// blob is validated and promoted exactly as a single-request upload is.
+func (s *Server) InitiateMultipartUpload(ctx context.Context, req *InitiateMultipartUploadRequest) (*MultipartUploadResult, error) {
+	owner, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	mimeType, sizeBytes := req.MimeType, req.SizeBytes
+
+	log := s.log.With(
+		zap.String("owner_id", model.UserIDString(owner)),
+		zap.String("mime_type", mimeType),
+		zap.Uint64("size_bytes", sizeBytes),
+	)
+
+	denial, err := s.admitUpload(ctx, owner, mimeType, sizeBytes, log)
+	if err != nil {
+		return nil, err
+	}
+	if denial != nil {
+		return &MultipartUploadResult{Result: denial.Result, PolicyVersion: denial.PolicyVersion}, nil
+	}
+
+	id := MustGenerateID()
+	log = log.With(zap.String("blob_id", IDString(id)))
+
+	key, err := StorageKey(id, mimeType)
+	if err != nil {
+		log.Warn("Failed to derive storage key", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+
+	uploadID, err := s.storage.CreateMultipartUpload(ctx, key, mimeType)
+	if err != nil {
+		log.Warn("Failed to create multipart upload", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+
+	record := &Blob{
+		ID:         id,
+		Rendition:  RenditionOriginal,
+		Owner:      owner,
+		State:      StatePending,
+		StorageKey: key,
+		MimeType:   mimeType,
+		SizeBytes:  sizeBytes,
+		Multipart:  &MultipartUpload{UploadID: uploadID, PartSizeBytes: s.partSize},
+	}
+	targets, err := s.presignParts(ctx, record)
+	if err != nil {
+		log.Warn("Failed to presign upload parts", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+	if err := s.blobs.CreatePending(ctx, record); err != nil {
+		log.Warn("Failed to reserve blob", zap.Error(err))
+		if err := s.storage.AbortMultipartUpload(ctx, key, uploadID); err != nil {
+			log.Warn("Failed to abort multipart upload", zap.Error(err))
+		}
+		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	}
+
+	return &MultipartUploadResult{
+		Result:        blobpb.InitiateExternalUploadResponse_OK,
+		BlobID:        id,
+		PartSizeBytes: record.Multipart.PartSizeBytes,
+		Parts:         targets,
+	}, nil
+}
+
+// PresignUploadParts mints fresh targets for every part of the owner's pending
+// multipart upload, for a client whose targets expired before it finished. Parts
+// already uploaded need not be sent again.
+func (s *Server) PresignUploadParts(ctx context.Context, req *PresignUploadPartsRequest) ([]*blobpb.UploadTarget, error) {
+	owner, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	id := req.BlobID
+
+	log := s.log.With(
+		zap.String("owner_id", model.UserIDString(owner)),
+		zap.String("blob_id", IDString(id)),
+	)
+
+	record, err := s.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "blob not found")
+	} else if err != nil {
+		log.Warn("Failed to load blob", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to presign upload parts")
+	}
+	// Scoped to the uploader, as completion is.
+	if record.Owner == nil || !bytes.Equal(record.Owner.Value, owner.Value) || record.State == StateDeleted {
+		return nil, status.Error(codes.NotFound, "blob not found")
+	}
+	if record.Multipart == nil {
+		return nil, status.Error(codes.FailedPrecondition, "not a multipart upload")
+	}
+	if record.State != StatePending {
+		return nil, status.Error(codes.FailedPrecondition, "upload already completed")
+	}
+
+	targets, err := s.presignParts(ctx, record)
+	if err != nil {
+		log.Warn("Failed to presign upload parts", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to presign upload parts")
+	}
+	return targets, nil
+}
+
+// presignParts mints a target for each part of record's multipart upload.
+func (s *Server) presignParts(ctx context.Context, record *Blob) ([]*blobpb.UploadTarget, error) {
+	parts := record.Multipart.Parts(record.SizeBytes)
+	targets := make([]*blobpb.UploadTarget, 0, parts)
+	for partNumber := 1; partNumber <= parts; partNumber++ {
+		target, err := s.storage.PresignUploadPart(ctx, record.StorageKey, record.Multipart.UploadID, partNumber, record.Multipart.PartSize(record.SizeBytes, partNumber))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// admitUpload runs the checks an upload reservation must pass, returning the
+// denial to answer with when one fails, or nil when the upload is admitted.
+func (s *Server) admitUpload(ctx context.Context, owner *commonpb.UserId, mimeType string, sizeBytes uint64, log *zap.Logger) (*blobpb.InitiateExternalUploadResponse, error) {
 	// Uploads are gated on registration (and, while the feature is staff-gated, on
 	// staff), like other write paths.
 	allowed, err := s.uploadAllowed(ctx, owner, log)
@@ -114,7 +337,8 @@ func (s *Server) InitiateExternalUpload(ctx context.Context, req *blobpb.Initiat
 	// bytes land, surfacing the specific reason so the client can react instead of
 	// guessing at a generic denial. A policy-driven denial echoes the policy
 	// version so a client running on a stale cached policy knows to re-fetch.
-	if !SupportedImageMimeTypes[req.MimeType] {
+	kind := ContentKindForMimeType(mimeType)
+	if kind == ContentKindUnknown {
 		log.Debug("Rejecting upload of unsupported mime type")
 		return &blobpb.InitiateExternalUploadResponse{
 			Result:        blobpb.InitiateExternalUploadResponse_UNSUPPORTED_TYPE,
@@ -122,7 +346,7 @@ func (s *Server) InitiateExternalUpload(ctx context.Context, req *blobpb.Initiat
 		}, nil
 	}
 
-	if req.SizeBytes > MaxOriginalImageSizeBytes {
+	if sizeBytes > maxOriginalSizeBytes(kind) {
 		log.Debug("Rejecting oversize upload")
 		return &blobpb.InitiateExternalUploadResponse{
 			Result:        blobpb.InitiateExternalUploadResponse_TOO_LARGE,
@@ -130,42 +354,295 @@ func (s *Server) InitiateExternalUpload(ctx context.Context, req *blobpb.Initiat
 		}, nil
 	}
 
-	id := MustGenerateID()
-	log = log.With(zap.String("blob_id", IDString(id)))
-
-	// The mime type was validated as a supported image above, so this resolves; an
-	// error here would mean the two lists drifted out of sync.
-	key, err := StorageKey(id, req.MimeType)
+	// Quotas are checked against the owner's committed usage, so concurrent
+	// reservations can each pass and together overshoot a limit by up to one
+	// upload apiece — a bounded overrun, not worth serializing reservations for.
+	report, err := s.usageReport(ctx, owner, time.Now())
 	if err != nil {
-		log.Warn("Failed to derive storage key", zap.Error(err))
+		log.Warn("Failed to get storage usage", zap.Error(err))
 		return nil, status.Error(codes.Internal, "failed to initiate upload")
 	}
+	if !report.Quota.allows(&report.Usage, sizeBytes) {
+		log.Debug("Rejecting upload over quota",
+			zap.Uint64("stored_bytes", report.Usage.StoredBytes),
+			zap.Uint64("daily_bytes", report.Usage.DailyBytes),
+		)
+		return &blobpb.InitiateExternalUploadResponse{Result: blobpb.InitiateExternalUploadResponse_QUOTA_EXCEEDED}, nil
+	}
+	return nil, nil
+}
 
-	target, err := s.storage.PresignUpload(ctx, key, req.MimeType, req.SizeBytes)
+// maxOriginalSizeBytes is the declared-size ceiling for an ORIGINAL upload of the
+// given content kind, as advertised in the upload policy.
+func maxOriginalSizeBytes(kind ContentKind) uint64 {
+	switch kind {
+	case ContentKindImage:
+		return MaxOriginalImageSizeBytes
+	case ContentKindVideo:
+		return MaxOriginalVideoSizeBytes
+	default:
+		return 0
+	}
+}
+
+// GetStorageUsage reports the owner's storage usage and the quota it is
+// measured against.
+func (s *Server) GetStorageUsage(ctx context.Context, req *GetStorageUsageRequest) (*UsageReport, error) {
+	owner, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
 	if err != nil {
-		log.Warn("Failed to presign upload target", zap.Error(err))
-		return nil, status.Error(codes.Internal, "failed to initiate upload")
+		return nil, err
 	}
 
-	record := &Blob{
-		ID:         id,
-		Rendition:  RenditionOriginal,
-		Owner:      owner,
-		State:      StatePending,
-		StorageKey: key,
-		MimeType:   req.MimeType,
-		SizeBytes:  req.SizeBytes,
+	report, err := s.usageReport(ctx, owner, time.Now())
+	if err != nil {
+		s.log.Warn("Failed to get storage usage",
+			zap.String("owner_id", model.UserIDString(owner)),
+			zap.Error(err),
+		)
+		return nil, status.Error(codes.Internal, "failed to get storage usage")
 	}
-	if err := s.blobs.CreatePending(ctx, record); err != nil {
-		log.Warn("Failed to reserve blob", zap.Error(err))
-		return nil, status.Error(codes.Internal, "failed to initiate upload")
+	return report, nil
+}
+
+// BlockBlob adds a removed blob's perceptual hash to the hash blocklist, so
+// finalization rejects re-uploads of the image and its near-duplicates. Only
+// staff may call it. The blob may already have been collected: its record keeps
+// the hash.
+func (s *Server) BlockBlob(ctx context.Context, req *BlockBlobRequest) (*BlockedHash, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
 	}
+	id := req.BlobID
 
-	return &blobpb.InitiateExternalUploadResponse{
-		Result:       blobpb.InitiateExternalUploadResponse_OK,
-		BlobId:       id,
-		UploadTarget: target,
-	}, nil
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("blob_id", IDString(id)),
+	)
+
+	if err := s.requireBlocklistAdmin(ctx, caller, log); err != nil {
+		return nil, err
+	}
+
+	record, err := s.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "blob not found")
+	} else if err != nil {
+		log.Warn("Failed to get blob", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to block blob")
+	}
+	if record.PerceptualHash == nil {
+		// A rendition, or an original that never passed inspection.
+		return nil, status.Error(codes.FailedPrecondition, "blob has no perceptual hash")
+	}
+
+	entry := &BlockedHash{
+		Hash:         *record.PerceptualHash,
+		SourceBlobID: record.ID,
+		AddedBy:      caller,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.blocklist.Add(ctx, entry); err != nil {
+		log.Warn("Failed to add blocked hash", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to block blob")
+	}
+	log.Info("Blocked blob", zap.Stringer("hash", entry.Hash))
+	return entry, nil
+}
+
+// UnblockHash removes a hash from the hash blocklist. Only staff may call it.
+func (s *Server) UnblockHash(ctx context.Context, req *UnblockHashRequest) error {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return err
+	}
+	hash := req.Hash
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.Stringer("hash", hash),
+	)
+
+	if err := s.requireBlocklistAdmin(ctx, caller, log); err != nil {
+		return err
+	}
+
+	err = s.blocklist.Remove(ctx, hash)
+	if errors.Is(err, ErrNotFound) {
+		return status.Error(codes.NotFound, "hash not blocked")
+	} else if err != nil {
+		log.Warn("Failed to remove blocked hash", zap.Error(err))
+		return status.Error(codes.Internal, "failed to unblock hash")
+	}
+	log.Info("Unblocked hash")
+	return nil
+}
+
+// maxDeadLetterListLimit caps, and is the default for, how many dead letters
+// one ListDeadLetters call returns.
+const maxDeadLetterListLimit = 100
+
+// ListDeadLetters returns up to limit blobs of kind whose finalization was
+// dead-lettered (see WithWorkerDeadLetter), longest parked first. A zero limit,
+// or one over the cap, lists a full page. Only staff may call it.
+func (s *Server) ListDeadLetters(ctx context.Context, req *ListDeadLettersRequest) ([]*DeadLetter, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	kind, limit := req.Kind, req.Limit
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.Stringer("kind", kind),
+	)
+
+	if err := s.requireStaffCaller(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	if kind == ContentKindUnknown {
+		return nil, status.Error(codes.InvalidArgument, "unknown content kind")
+	}
+	if limit <= 0 || limit > maxDeadLetterListLimit {
+		limit = maxDeadLetterListLimit
+	}
+
+	letters, err := s.blobs.GetDeadLetters(ctx, kind, limit)
+	if err != nil {
+		log.Warn("Failed to get dead letters", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list dead letters")
+	}
+	return letters, nil
+}
+
+// RetryDeadLetter re-queues a dead-lettered blob for finalization with a fresh
+// attempt count, once the cause of its failures is fixed. Re-queuing a blob
+// that is merely in flight just makes it due now. Only staff may call it.
+func (s *Server) RetryDeadLetter(ctx context.Context, req *RetryDeadLetterRequest) error {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return err
+	}
+	id := req.BlobID
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("blob_id", IDString(id)),
+	)
+
+	if err := s.requireStaffCaller(ctx, caller, log); err != nil {
+		return err
+	}
+
+	record, err := s.blobs.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return status.Error(codes.NotFound, "blob not found")
+	} else if err != nil {
+		log.Warn("Failed to get blob", zap.Error(err))
+		return status.Error(codes.Internal, "failed to retry dead letter")
+	}
+	if record.State.Terminal() {
+		return status.Error(codes.FailedPrecondition, "blob already finalized")
+	}
+
+	err = s.blobs.MarkForFinalization(ctx, id, record.ContentKind(), time.Now())
+	if errors.Is(err, ErrNotFound) {
+		return status.Error(codes.NotFound, "blob not found")
+	} else if err != nil {
+		log.Warn("Failed to re-queue blob for finalization", zap.Error(err))
+		return status.Error(codes.Internal, "failed to retry dead letter")
+	}
+	log.Info("Re-queued dead-lettered blob")
+	return nil
+}
+
+// RetryDeadLetters re-queues every blob of kind on the dead-letter list, as
+// RetryDeadLetter does one, and returns how many it re-queued. Only staff may
+// call it.
+func (s *Server) RetryDeadLetters(ctx context.Context, req *RetryDeadLettersRequest) (int, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return 0, err
+	}
+	kind := req.Kind
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.Stringer("kind", kind),
+	)
+
+	if err := s.requireStaffCaller(ctx, caller, log); err != nil {
+		return 0, err
+	}
+	if kind == ContentKindUnknown {
+		return 0, status.Error(codes.InvalidArgument, "unknown content kind")
+	}
+
+	// Each re-queued blob leaves the list, so the first page is always the next.
+	var retried int
+	for {
+		letters, err := s.blobs.GetDeadLetters(ctx, kind, maxDeadLetterListLimit)
+		if err != nil {
+			log.Warn("Failed to get dead letters", zap.Error(err), zap.Int("retried", retried))
+			return retried, status.Error(codes.Internal, "failed to retry dead letters")
+		}
+		if len(letters) == 0 {
+			break
+		}
+		for _, letter := range letters {
+			err := s.blobs.MarkForFinalization(ctx, letter.ID, kind, time.Now())
+			if errors.Is(err, ErrNotFound) {
+				// Reclaimed (TTL) since it was listed; its dead letter went with it.
+				continue
+			} else if err != nil {
+				log.Warn("Failed to re-queue blob for finalization",
+					zap.String("blob_id", IDString(letter.ID)),
+					zap.Error(err),
+					zap.Int("retried", retried),
+				)
+				return retried, status.Error(codes.Internal, "failed to retry dead letters")
+			}
+			retried++
+		}
+	}
+	log.Info("Re-queued dead-lettered blobs", zap.Int("retried", retried))
+	return retried, nil
+}
+
+// requireBlocklistAdmin gates the blocklist operations: they must be enabled,
+// and the caller must be staff.
+func (s *Server) requireBlocklistAdmin(ctx context.Context, caller *commonpb.UserId, log *zap.Logger) error {
+	if s.blocklist == nil {
+		return status.Error(codes.Unimplemented, "hash blocklist not enabled")
+	}
+	return s.requireStaffCaller(ctx, caller, log)
+}
+
+// requireStaffCaller gates the staff-only operations on the caller being staff.
+func (s *Server) requireStaffCaller(ctx context.Context, caller *commonpb.UserId, log *zap.Logger) error {
+	isStaff, err := s.accounts.IsStaff(ctx, caller)
+	if err != nil {
+		log.Warn("Failed to check staff status", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check staff status")
+	}
+	if !isStaff {
+		return status.Error(codes.PermissionDenied, "staff only")
+	}
+	return nil
+}
+
+// usageReport assembles owner's usage as of now alongside the quota in force
+// for them.
+func (s *Server) usageReport(ctx context.Context, owner *commonpb.UserId, now time.Time) (*UsageReport, error) {
+	quota, err := quotaFor(ctx, s.accounts, owner, s.quota, s.staffQuota)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.blobs.GetUsage(ctx, owner, now)
+	if err != nil {
+		return nil, err
+	}
+	return &UsageReport{Usage: *usage, Quota: quota, Day: UsageDay(now)}, nil
 }
 
 // uploadAllowed reports whether the caller may upload: they must be registered,
@@ -223,8 +700,8 @@ func (s *Server) CompleteExternalUpload(ctx context.Context, req *blobpb.Complet
 	}
 
 	// Completion is scoped to the uploader: another user holding the id cannot
-	// finalize someone else's pending upload.
-	if record.Owner == nil || !bytes.Equal(record.Owner.Value, owner.Value) {
+	// finalize someone else's pending upload. A collected blob is gone.
+	if record.Owner == nil || !bytes.Equal(record.Owner.Value, owner.Value) || record.State == StateDeleted {
 		return &blobpb.CompleteExternalUploadResponse{Result: blobpb.CompleteExternalUploadResponse_NOT_FOUND}, nil
 	}
 
@@ -239,6 +716,19 @@ func (s *Server) CompleteExternalUpload(ctx context.Context, req *blobpb.Complet
 		}, nil
 	}
 
+	// A multipart upload's parts are assembled into the upload store first, where
+	// the result is quarantined like any other upload. A part that has not landed
+	// reads as the upload not having happened yet.
+	if record.Multipart != nil && record.State == StatePending {
+		err := s.storage.CompleteMultipartUpload(ctx, record.StorageKey, record.Multipart.UploadID, record.Multipart.Parts(record.SizeBytes))
+		if errors.Is(err, ErrUploadIncomplete) {
+			return &blobpb.CompleteExternalUploadResponse{Result: blobpb.CompleteExternalUploadResponse_NOT_UPLOADED}, nil
+		} else if err != nil {
+			log.Warn("Failed to assemble multipart upload", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to complete upload")
+		}
+	}
+
 	// Confirm the bytes actually landed before queueing work: a completion ahead
 	// of the upload is the client's error to observe and retry, not a doomed task
 	// for the worker to spin on.
@@ -308,6 +798,10 @@ func (s *Server) GetBlobs(ctx context.Context, req *blobpb.GetBlobsRequest) (*bl
 
 	resolved := make([]*blobpb.Blob, 0, len(records))
 	for _, record := range records {
+		// A collected blob resolves as if it did not exist.
+		if record.State == StateDeleted {
+			continue
+		}
 		allowed, err := s.canRead(ctx, caller, record, req.Context)
 		if err != nil {
 			s.log.Warn("Failed to authorize blob",
@@ -364,6 +858,11 @@ func (s *Server) GetBlobs(ctx context.Context, req *blobpb.GetBlobsRequest) (*bl
 // read any blob id they can guess, and the grant alone would ignore who is
 // asking. A caller who may not read is reported (false, nil) so GetBlobs skips
 // the record, leaving it indistinguishable from one that does not exist.
+//
+// Nothing about the decision is cached: the grant and the coverage are both
+// re-evaluated on every call, so a revoked grant (a deleted message, a replaced
+// profile picture) or a member who left the chat stops resolving on the very
+// next read. A download URL minted before then stays valid until it expires.
 func (s *Server) canRead(ctx context.Context, caller *commonpb.UserId, record *Blob, accessContext *blobpb.AccessContext) (bool, error) {
 	if record.Owner != nil && bytes.Equal(record.Owner.Value, caller.Value) {
 		return true, nil
@@ -422,10 +921,11 @@ func buildMetadata(ctx context.Context, storage ObjectStorage, record *Blob) (*b
 
 	metadata := &blobpb.BlobMetadata{
 		MimeType:    record.MimeType,
-		SizeBytes:   record.SizeBytes,
+		SizeBytes:   record.ServedSizeBytes(),
 		DownloadUrl: downloadURL,
 	}
 	if record.Image != nil {
+		// Animated and FrameCount have no wire field yet; see ImageMetadata.
 		metadata.Kind = &blobpb.BlobMetadata_Image{
 			Image: &blobpb.ImageMetadata{
 				Width:    record.Image.Width,
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
+	"strings"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
 )
@@ -23,8 +25,8 @@ var ErrObjectNotFound = errors.New("object not found")
 // CDN URLs for serving. It is deliberately provider-agnostic. The production
 // implementation is two S3 buckets (presigned PUT into the upload bucket,
 // GetObject to read it back, a server-side copy into the origin bucket) with a
-// CloudFront CDN in front of the origin bucket; an in-memory implementation
-// backs tests.
+// CloudFront CDN in front of the origin bucket; a filesystem implementation
+// (blob/fs) serves self-hosted deployments, and an in-memory one backs tests.
 type ObjectStorage interface {
 	// PresignUpload mints a short-lived, presigned target the client uploads the
 	// bytes to directly, into the UPLOAD store under the given key. The target
@@ -32,6 +34,29 @@ type ObjectStorage interface {
 	// upload that does not match.
 	PresignUpload(ctx context.Context, key, mimeType string, sizeBytes uint64) (*blobpb.UploadTarget, error)
 
+	// CreateMultipartUpload starts a multipart upload into the UPLOAD store under
+	// the given key, pinning the content type the assembled object is stored
+	// with, and returns the upload's id. Nothing is visible under the key until
+	// CompleteMultipartUpload assembles the parts.
+	CreateMultipartUpload(ctx context.Context, key, mimeType string) (string, error)
+
+	// PresignUploadPart mints a short-lived, presigned target for one (1-based)
+	// part of a multipart upload, pinned to the part's exact size. A part may be
+	// uploaded any number of times; the last upload of it wins, which is what
+	// lets a client retry a single part on a flaky network.
+	PresignUploadPart(ctx context.Context, key, uploadID string, partNumber int, sizeBytes uint64) (*blobpb.UploadTarget, error)
+
+	// CompleteMultipartUpload assembles parts 1 through parts, in order, into the
+	// object under the key in the UPLOAD store, where it is quarantined like any
+	// single-request upload. It returns ErrUploadIncomplete if a part has not
+	// landed. Once assembled, completing again is a no-op success.
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts int) error
+
+	// AbortMultipartUpload discards a multipart upload that was never
+	// assembled, along with its parts. It is idempotent: aborting an unknown or
+	// already-assembled upload is not an error.
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+
 	// GetUploaded returns the bytes a client uploaded under the key in the UPLOAD
 	// store, or ErrObjectNotFound if no bytes are present yet. Finalization reads
 	// them back to validate the content before promoting it.
@@ -61,11 +86,40 @@ type ObjectStorage interface {
 	// idempotent.
 	PutOrigin(ctx context.Context, key, mimeType string, data []byte) error
 
+	// GetOrigin returns the bytes served under the key from the ORIGIN store, or
+	// ErrObjectNotFound if nothing is. Maintenance jobs read an original back
+	// through it to derive what finalization did not (see RenditionBackfill).
+	GetOrigin(ctx context.Context, key string) ([]byte, error)
+
+	// CopyOrigin copies an object already served from the ORIGIN store to
+	// another key in it, content type included. It is how a deduplicated upload
+	// reuses the renditions already derived from identical bytes (see
+	// Finalizer): each gets its own copy under its own item's prefix, so the two
+	// blobs are collected independently. It overwrites any object already under
+	// dstKey, and returns ErrObjectNotFound if nothing is served under srcKey.
+	CopyOrigin(ctx context.Context, srcKey, dstKey string) error
+
 	// DeleteUpload removes an object from the UPLOAD store. It is best-effort
 	// cleanup run after a blob reaches a terminal state, and is idempotent:
 	// deleting an absent key is not an error.
 	DeleteUpload(ctx context.Context, key string) error
 
+	// DeletePrefix removes every object under the given key prefix from both the
+	// UPLOAD and ORIGIN stores. The garbage collector passes a media item's
+	// directory (see StorageKey), removing the original together with every
+	// rendition grouped beneath it. It is idempotent: a prefix with nothing under
+	// it is not an error. An empty prefix is refused rather than emptying the
+	// stores.
+	DeletePrefix(ctx context.Context, prefix string) error
+
+	// InvalidateCDN purges every copy the CDN has cached of objects under the
+	// given key prefix, so a blob whose access was revoked is no longer served
+	// from an edge. Download URLs minted before it still fetch from the origin
+	// until they expire. It is optional: a backend with no CDN (or none
+	// configured) treats it as a no-op. An empty prefix is refused rather than
+	// purging the whole distribution.
+	InvalidateCDN(ctx context.Context, prefix string) error
+
 	// SignDownloadURL mints a fresh, short-lived CDN URL for fetching a promoted
 	// object's bytes from the ORIGIN store, paired with the instant it expires. It
 	// is authorized at mint time and expires on its own, so callers mint a new one
@@ -73,33 +127,56 @@ type ObjectStorage interface {
 	SignDownloadURL(ctx context.Context, key string) (*blobpb.DownloadUrl, error)
 }
 
-// StorageKey derives the object key for an image blob's bytes from its id and
-// mime type. Images can have server-derived renditions (display, thumbnail, ...),
-// so an image's bytes live under a per-media-item directory keyed by its id,
-// leaving room to group its renditions under the same prefix:
+// StorageKey derives the object key for an original blob's bytes from its id and
+// mime type. Media can have server-derived renditions (display, thumbnail, ...),
+// so an original's bytes live under a per-media-item directory keyed by its id,
+// leaving room to group its renditions under the same prefix. The directory is
+// namespaced by content kind:
 //
 //	images/<uuid>/original.jpg
+//	videos/<uuid>/original.mp4
 //
-// Only images are supported today, so a non-image mime type is rejected outright
-// rather than being silently forced into the image layout. Other kinds (videos,
-// files) may want a different prefix and layout — keyed off the kind, not merely
-// a resolvable extension — so adding one has to make a deliberate decision here.
-// The extension is derived from the (immutable) mime type, and the same key is
-// used in both the upload and origin stores.
+// A mime type of no supported kind is rejected outright rather than being
+// silently forced into some layout, so adding a kind has to make a deliberate
+// decision in storageKeyPrefix. The extension is derived from the (immutable)
+// mime type, and the same key is used in both the upload and origin stores.
 func StorageKey(id *blobpb.BlobId, mimeType string) (string, error) {
-	// Gate on the image kind explicitly, not merely on a resolvable extension: if
-	// mimeTypeToExtension later grows video/file entries, this still rejects them
-	// so they cannot inherit the image layout by accident.
 	if err := id.Validate(); err != nil {
 		return "", err
 	}
-	if !SupportedImageMimeTypes[mimeType] {
-		return "", fmt.Errorf("unsupported non-image mime type %q for storage key", mimeType)
+	prefix := storageKeyPrefix(ContentKindForMimeType(mimeType))
+	if prefix == "" {
+		return "", fmt.Errorf("unsupported mime type %q for storage key", mimeType)
 	}
 	ext := extensionForMimeType(mimeType)
 	if ext == "" {
-		// An image kind with no registered extension means the two image maps drifted.
-		return "", fmt.Errorf("missing extension for image mime type %q", mimeType)
+		// A supported kind with no registered extension means the maps drifted.
+		return "", fmt.Errorf("missing extension for mime type %q", mimeType)
+	}
+	return fmt.Sprintf("%s/%s/original%s", prefix, IDString(id), ext), nil
+}
+
+// storageItemPrefix is the per-media-item directory a storage key lives in —
+// "images/<uuid>/" for both an original and its renditions — or "" for a key
+// not laid out under one.
+func storageItemPrefix(key string) string {
+	dir := path.Dir(key)
+	if strings.Count(dir, "/") != 1 || strings.HasPrefix(dir, "/") || strings.HasSuffix(dir, "/") {
+		return ""
+	}
+	return dir + "/"
+}
+
+// storageKeyPrefix is the top-level directory a content kind's objects live
+// under, or "" for a kind with no storage layout. An original and all of its
+// renditions share it, whatever the renditions' own types.
+func storageKeyPrefix(kind ContentKind) string {
+	switch kind {
+	case ContentKindImage:
+		return "images"
+	case ContentKindVideo:
+		return "videos"
+	default:
+		return ""
 	}
-	return fmt.Sprintf("images/%s/original%s", IDString(id), ext), nil
 }
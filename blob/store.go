@@ -5,6 +5,7 @@ import (
 	"time"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 )
 
 // Store persists server-authoritative blob metadata. The bytes themselves live
@@ -18,10 +19,31 @@ import (
 // the queue is bookkeeping ON the blob record, so reaching a terminal state
 // removes a blob from its queue atomically. The store does not interpret the
 // kind; callers queue a blob under the kind they derived from it.
+//
+// It likewise carries the collection index the garbage collector walks (see
+// Collector): every ORIGINAL is indexed from reservation under the instant its
+// grace period counts from, so abandoned, rejected, and unreferenced blobs can
+// be found without scanning the table. Renditions are never indexed — they are
+// collected with their original.
+//
+// It also indexes ORIGINALs by the hash of their content (see SetContentHash),
+// so finalization can find an earlier upload of the same bytes and reuse its
+// verdict instead of repeating the work.
+//
+// Finally, it keeps each owner's storage accounting (see Usage) current as
+// blobs come and go: CreatePending charges a blob's declared size and
+// Tombstone refunds it, each atomically with the transition, so the totals
+// never drift from the records behind them. Blobs are charged at their
+// declared size for their whole life, including while rejected and awaiting
+// collection; a sanitized origin only ever shrinks.
 type Store interface {
 	// CreatePending inserts a freshly reserved blob in the PENDING state. The
 	// blob may be an ORIGINAL (nil ParentID) or a server-created rendition of an
-	// existing original (ParentID set).
+	// existing original (ParentID set). An ORIGINAL enters the collection index
+	// as of its creation, so a reservation that is never completed is eventually
+	// collected. The blob's declared size is charged to its owner's stored
+	// bytes and, for an ORIGINAL, to the owner's daily bytes for the current
+	// UTC day.
 	//
 	// ErrExists is returned if a blob with the same id already exists.
 	CreatePending(ctx context.Context, blob *Blob) error
@@ -49,8 +71,9 @@ type Store interface {
 	AttachRenditions(ctx context.Context, id *blobpb.BlobId, refs []RenditionRef) error
 
 	// Advance moves a blob forward along the success path to a later lifecycle
-	// state, persisting derived metadata when provided (image is set only on the
-	// transition into StateInspected). It advances strictly forward and never out
+	// state, persisting derived metadata when provided (meta is set only on the
+	// transition into StateInspected, and on a rendition's transition to READY).
+	// Only the kind-specific fields meta sets are written. It advances strictly forward and never out
 	// of a terminal state, so a replayed or concurrent finalize is idempotent:
 	// advancing to a state the blob is already at or past is a no-op. The declared
 	// MimeType and SizeBytes are never changed. Reaching StateReady also removes
@@ -66,7 +89,7 @@ type Store interface {
 	// caller can stop instead of applying further side effects on a stale view.
 	//
 	// ErrNotFound is returned if no blob exists for the given id.
-	Advance(ctx context.Context, id *blobpb.BlobId, to State, image *ImageMetadata) (bool, error)
+	Advance(ctx context.Context, id *blobpb.BlobId, to State, meta *DerivedMetadata) (bool, error)
 
 	// Reject moves a non-terminal blob to the terminal StateRejected, recording
 	// why. Like Advance it transitions only out of a non-terminal state and is
@@ -79,12 +102,38 @@ type Store interface {
 	// ErrNotFound is returned if no blob exists for the given id.
 	Reject(ctx context.Context, id *blobpb.BlobId, rejection *RejectionMetadata) (bool, error)
 
+	// Reinstate moves a blob rejected by moderation (RejectionReasonModeration)
+	// back to StateUploaded, clearing its rejection and setting
+	// ModerationBypassed, so a finalization resumed from it skips moderation. It
+	// is how a rejection overturned on appeal is undone; the caller queues the
+	// blob for finalization afterwards. It is idempotent: it reports whether it
+	// performed the transition, and a false with a nil error means the blob is
+	// not a moderation rejection (already reinstated, collected, or rejected for
+	// another reason).
+	//
+	// ErrNotFound is returned if no blob exists for the given id.
+	Reinstate(ctx context.Context, id *blobpb.BlobId) (bool, error)
+
+	// TakeDown moves a READY original to StateRejected under
+	// RejectionReasonTakenDown, so it stops resolving as servable. It is how staff
+	// act on a report against content that already passed finalization, which
+	// Reject (non-terminal blobs only) cannot reach. Its renditions are left as
+	// they are: they go with their original when it is collected. It is
+	// idempotent: it reports whether it performed the transition, and a false with
+	// a nil error means the blob is not READY (still finalizing, already
+	// rejected, or collected).
+	//
+	// ErrNotFound is returned if no blob exists for the given id.
+	TakeDown(ctx context.Context, id *blobpb.BlobId) (bool, error)
+
 	// MarkForFinalization queues a blob on its content kind's finalization
 	// queue, due at nextAttemptAt. It is idempotent: re-marking an already-queued
 	// blob resets its due time (and moves it if the kind changed, though a blob's
 	// kind never legitimately changes) but preserves its failed-attempt count,
 	// and marking a blob that already reached a terminal state is a no-op (the
-	// work is done, so nothing is queued).
+	// work is done, so nothing is queued). Marking a dead-lettered blob takes it
+	// off the dead-letter list and re-queues it with a fresh attempt count and
+	// enqueue time: it is how an operator retries it.
 	//
 	// ErrNotFound is returned if no blob exists for the given id.
 	MarkForFinalization(ctx context.Context, id *blobpb.BlobId, kind ContentKind, nextAttemptAt time.Time) error
@@ -117,4 +166,77 @@ type Store interface {
 	// a no-op on a blob that is no longer queued (a concurrent finalize drove it
 	// terminal, dequeuing it).
 	DelayFinalization(ctx context.Context, id *blobpb.BlobId, nextAttemptAt time.Time) error
+
+	// DeadLetterFinalization moves a queued blob off the finalization queue and
+	// onto kind's dead-letter list, recording its failed-attempt
+	// count and the last attempt's error. The blob itself is left in whatever
+	// state it reached, so MarkForFinalization resumes it. It is a no-op on a
+	// blob that is no longer queued.
+	DeadLetterFinalization(ctx context.Context, id *blobpb.BlobId, kind ContentKind, attempts uint32, lastError string, at time.Time) error
+
+	// GetDeadLetters returns up to limit blobs dead-lettered under kind, longest
+	// parked first.
+	GetDeadLetters(ctx context.Context, kind ContentKind, limit int) ([]*DeadLetter, error)
+
+	// MarkForCollection (re)stamps a blob in the collection index: it becomes a
+	// collection candidate once the collector's grace period has elapsed since
+	// the given instant, which may lie in the future to defer a recheck. It is a
+	// no-op on a blob that has already been collected (StateDeleted).
+	//
+	// ErrNotFound is returned if no blob exists for the given id.
+	MarkForCollection(ctx context.Context, id *blobpb.BlobId, since time.Time) error
+
+	// GetDueForCollection returns the ids of up to limit indexed blobs stamped at
+	// or before asOf, oldest first.
+	GetDueForCollection(ctx context.Context, asOf time.Time, limit int) ([]*blobpb.BlobId, error)
+
+	// ScanReadyOriginals pages through every READY original, rendition
+	// manifests included, for maintenance jobs that must visit them all (see
+	// RenditionBackfill). cursor is empty to start a scan, or the cursor a
+	// previous page returned to resume it; the returned cursor is empty once the
+	// scan is complete. The order is unspecified but stable, and a page may hold
+	// fewer than limit blobs — even none — before the scan is complete.
+	ScanReadyOriginals(ctx context.Context, cursor string, limit int) ([]*Blob, string, error)
+
+	// GetOriginalsByOwner pages through the ORIGINALs owner reserved, in any
+	// state but StateDeleted, rendition manifests included, for jobs that act on
+	// everything a user uploaded (see the export package). cursor is empty to
+	// start, or the cursor a previous page returned to resume; the returned
+	// cursor is empty once every original has been visited. The order is
+	// unspecified but stable, and a page may hold fewer than limit blobs — even
+	// none — before the owner's originals are exhausted.
+	GetOriginalsByOwner(ctx context.Context, owner *commonpb.UserId, cursor string, limit int) ([]*Blob, string, error)
+
+	// Tombstone moves a blob to the terminal StateDeleted, provided it is still
+	// in the from state, removing it from the finalization, collection, and
+	// content-hash indexes, dropping its rendition manifest, and refunding its declared size
+	// from its owner's stored bytes. It reports whether it performed the
+	// transition: false with a nil error means the blob moved on since the
+	// caller read it (e.g. a pending upload was completed), or was already
+	// collected.
+	//
+	// A blob rejected with a verdict on its content (see
+	// RejectionReason.IsContentVerdict) keeps its content hash, its place in the
+	// content-hash index, and its rejection, and its tombstone is never reclaimed,
+	// so later uploads of the same bytes are still rejected once it is collected.
+	//
+	// ErrNotFound is returned if no blob exists for the given id.
+	Tombstone(ctx context.Context, id *blobpb.BlobId, from State) (bool, error)
+
+	// SetContentHash records the SHA-256 of an ORIGINAL's uploaded bytes,
+	// indexing it for GetByContentHash as of the first time it is recorded.
+	// Recording the same hash again (a replayed finalize) is a no-op, and so is
+	// recording one on a collected blob.
+	//
+	// ErrNotFound is returned if no blob exists for the given id.
+	SetContentHash(ctx context.Context, id *blobpb.BlobId, hash []byte) error
+
+	// GetByContentHash returns up to limit ORIGINALs whose content hash is hash,
+	// in the order their hashes were recorded, oldest first. The results may be
+	// in any state but StateDeleted: Tombstone drops a blob from the index.
+	GetByContentHash(ctx context.Context, hash []byte, limit int) ([]*Blob, error)
+
+	// GetUsage returns owner's storage accounting, with DailyBytes covering the
+	// UTC day that contains day. An owner with nothing stored has zero usage.
+	GetUsage(ctx context.Context, owner *commonpb.UserId, day time.Time) (*Usage, error)
 }
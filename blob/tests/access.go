@@ -17,6 +17,7 @@ import (
 func RunAccessStoreTests(t *testing.T, store blob.AccessStore, teardown func()) {
 	for _, tf := range []func(t *testing.T, store blob.AccessStore){
 		testAccessGrantHasRevoke,
+		testAccessHasAnyGrant,
 		testAccessNoCollision,
 		testAccessProfileGrant,
 		testAccessValidation,
@@ -36,6 +37,44 @@ func newChatID(t *testing.T) *commonpb.ChatId {
 	return &commonpb.ChatId{Value: value}
 }
 
+func testAccessHasAnyGrant(t *testing.T, store blob.AccessStore) {
+	ctx := context.Background()
+
+	blobID := blob.MustGenerateID()
+	other := blob.MustGenerateID()
+	chat := blob.PrincipalForChat(newChatID(t))
+	profile := blob.PrincipalForProfile(model.MustGenerateUserID())
+
+	has, err := store.HasAnyGrant(ctx, blobID)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// Any principal counts, and only grants on this blob do.
+	require.NoError(t, store.Grant(ctx, &blob.Grant{BlobID: other, Principal: chat, Permission: blob.PermissionRead}))
+	has, err = store.HasAnyGrant(ctx, blobID)
+	require.NoError(t, err)
+	require.False(t, has)
+	for _, p := range []blob.Principal{chat, profile} {
+		require.NoError(t, store.Grant(ctx, &blob.Grant{BlobID: blobID, Principal: p, Permission: blob.PermissionRead}))
+	}
+	has, err = store.HasAnyGrant(ctx, blobID)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	// It holds until the last grant is revoked.
+	require.NoError(t, store.Revoke(ctx, blobID, chat, blob.PermissionRead))
+	has, err = store.HasAnyGrant(ctx, blobID)
+	require.NoError(t, err)
+	require.True(t, has)
+	require.NoError(t, store.Revoke(ctx, blobID, profile, blob.PermissionRead))
+	has, err = store.HasAnyGrant(ctx, blobID)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	_, err = store.HasAnyGrant(ctx, nil)
+	require.ErrorIs(t, err, blob.ErrInvalidGrant)
+}
+
 func testAccessGrantHasRevoke(t *testing.T, store blob.AccessStore) {
 	ctx := context.Background()
 
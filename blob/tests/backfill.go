@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+// RunBackfillTests runs the shared blob.RenditionBackfill test suite against the
+// given metadata store. As in RunWorkerTests, the object storage is a fake
+// injected by the caller along with a direct put hook.
+func RunBackfillTests(
+	t *testing.T,
+	blobs blob.Store,
+	storage blob.ObjectStorage,
+	putObject putObjectFunc,
+	teardown func(),
+) {
+	for _, tf := range []func(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc){
+		testBackfillRebuildsStaleManifests,
+		testBackfillDryRun,
+		testBackfillResumesFromCursor,
+		testBackfillAddsAVIFRenditions,
+	} {
+		tf(t, blobs, storage, putObject)
+		teardown()
+	}
+}
+
+// readyOriginal finalizes a fresh PNG original through the worker.
+func (h *workerHarness) readyOriginal(t *testing.T) *blob.Blob {
+	record := h.stageUpload(t, makePNG(t, 400, 300), true)
+	h.mark(t, record)
+	h.process(t, 1)
+	got := h.state(t, record)
+	require.Equal(t, blob.StateReady, got.State)
+	require.Greater(t, len(got.Renditions), 1)
+	return got
+}
+
+// makeStale rewrites the original's manifest as one finalized under an older
+// ladder: missing all but its first rung, and carrying a rung the current ladder
+// no longer plans.
+func makeStale(t *testing.T, blobs blob.Store, record *blob.Blob) {
+	retired := blob.RenditionRef{
+		ID:         blob.MustGenerateID(),
+		Rendition:  blob.RenditionDisplay,
+		MimeType:   "image/jpeg",
+		SizeBytes:  1,
+		StorageKey: record.StorageKey + ".retired",
+		Image:      &blob.ImageMetadata{Width: 1, Height: 1},
+	}
+	refs := []blob.RenditionRef{record.Renditions[0], retired}
+	require.NoError(t, blobs.AttachRenditions(context.Background(), record.ID, refs))
+}
+
+func testBackfillRebuildsStaleManifests(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	recorder := &originRecorder{ObjectStorage: storage}
+	h := newWorkerHarness(t, blobs, storage, putObject, nil)
+
+	stale := []*blob.Blob{h.readyOriginal(t), h.readyOriginal(t)}
+	current := h.readyOriginal(t)
+	for _, record := range stale {
+		makeStale(t, blobs, record)
+	}
+
+	backfill := blob.NewRenditionBackfill(zaptest.NewLogger(t), blobs, recorder, blob.WithBackfillRate(20))
+	start := time.Now()
+	report, err := backfill.Run(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Scanned)
+	require.Equal(t, 2, report.Stale)
+	require.Equal(t, 2, report.Rebuilt)
+	require.Zero(t, report.Failed)
+	require.Equal(t, 2*(len(current.Renditions)-1), report.Renditions)
+	require.Empty(t, report.Cursor)
+	// Two rebuilds at 20 per second are spaced at least 50ms apart.
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	for _, record := range stale {
+		got := h.state(t, record)
+
+		// The manifest is the full ladder again, in ladder order, the retired rung
+		// dropped and the kept one untouched.
+		require.Len(t, got.Renditions, len(record.Renditions))
+		for i, ref := range got.Renditions {
+			require.Equal(t, record.Renditions[i].ID.Value, ref.ID.Value)
+			require.Equal(t, record.Renditions[i].StorageKey, ref.StorageKey)
+			require.Equal(t, blob.StateReady, h.state(t, &blob.Blob{ID: ref.ID}).State)
+		}
+		require.Nil(t, recorder.get(record.Renditions[0].StorageKey), "a rung the manifest held is not derived again")
+		for _, ref := range got.Renditions[1:] {
+			require.NotNil(t, recorder.get(ref.StorageKey))
+		}
+	}
+	require.Len(t, h.state(t, current).Renditions, len(current.Renditions))
+
+	// A second pass finds nothing to do.
+	report, err = backfill.Run(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Scanned)
+	require.Zero(t, report.Stale)
+	require.Zero(t, report.Rebuilt)
+	require.Zero(t, report.Renditions)
+}
+
+func testBackfillDryRun(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	recorder := &originRecorder{ObjectStorage: storage}
+	h := newWorkerHarness(t, blobs, storage, putObject, nil)
+
+	record := h.readyOriginal(t)
+	makeStale(t, blobs, record)
+
+	dryRun := blob.NewRenditionBackfill(zaptest.NewLogger(t), blobs, recorder, blob.WithBackfillDryRun())
+	report, err := dryRun.Run(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Scanned)
+	require.Equal(t, 1, report.Stale)
+	require.Zero(t, report.Rebuilt)
+	require.Equal(t, len(record.Renditions)-1, report.Renditions)
+
+	// Nothing was written.
+	require.Len(t, h.state(t, record).Renditions, 2)
+	for _, ref := range record.Renditions {
+		require.Nil(t, recorder.get(ref.StorageKey))
+	}
+
+	// A live run then does what the dry run reported.
+	live := blob.NewRenditionBackfill(zaptest.NewLogger(t), blobs, recorder)
+	report, err = live.Run(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Rebuilt)
+	require.Len(t, h.state(t, record).Renditions, len(record.Renditions))
+}
+
+func testBackfillResumesFromCursor(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	h := newWorkerHarness(t, blobs, storage, putObject, nil)
+
+	var records []*blob.Blob
+	for range 4 {
+		record := h.readyOriginal(t)
+		makeStale(t, blobs, record)
+		records = append(records, record)
+	}
+
+	// The scan fails partway through, after its first page.
+	flaky := &flakyScanStore{Store: blobs, pages: 1}
+	report, err := blob.NewRenditionBackfill(zaptest.NewLogger(t), flaky, storage, blob.WithBackfillPageSize(2)).Run(ctx, "")
+	require.ErrorIs(t, err, errScanFailed)
+	require.NotEmpty(t, report.Cursor)
+	rebuilt := report.Rebuilt
+
+	// Resuming from its cursor covers the rest without revisiting the first page.
+	resumed, err := blob.NewRenditionBackfill(zaptest.NewLogger(t), blobs, storage, blob.WithBackfillPageSize(2)).Run(ctx, report.Cursor)
+	require.NoError(t, err)
+	require.Empty(t, resumed.Cursor)
+	require.Equal(t, len(records), report.Scanned+resumed.Scanned)
+	require.Equal(t, len(records), rebuilt+resumed.Rebuilt)
+
+	for _, record := range records {
+		require.Len(t, h.state(t, record).Renditions, len(record.Renditions))
+	}
+}
+
+func testBackfillAddsAVIFRenditions(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	h := newWorkerHarness(t, blobs, storage, putObject, nil)
+
+	// Finalized before AVIF was enabled: WebP only.
+	record := h.readyOriginal(t)
+	encoder := &fakeAVIFEncoder{}
+	backfill := blob.NewRenditionBackfill(zaptest.NewLogger(t), blobs, storage, blob.WithBackfillAVIFEncoder(encoder))
+	report, err := backfill.Run(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Rebuilt)
+	require.Equal(t, len(record.Renditions), report.Renditions)
+	require.EqualValues(t, len(record.Renditions), encoder.calls.Load())
+
+	// Each WebP rendition is kept, now followed by its AVIF sibling.
+	got := h.state(t, record)
+	require.Len(t, got.Renditions, 2*len(record.Renditions))
+	for i, ref := range record.Renditions {
+		require.Equal(t, ref.ID.Value, got.Renditions[2*i].ID.Value)
+		require.Equal(t, "image/avif", got.Renditions[2*i+1].MimeType)
+	}
+}
+
+var errScanFailed = errors.New("scan failed")
+
+// flakyScanStore is a blob.Store whose scan fails once it has served the given
+// number of pages.
+type flakyScanStore struct {
+	blob.Store
+	pages int
+}
+
+func (s *flakyScanStore) ScanReadyOriginals(ctx context.Context, cursor string, limit int) ([]*blob.Blob, string, error) {
+	if s.pages == 0 {
+		return nil, "", errScanFailed
+	}
+	s.pages--
+	return s.Store.ScanReadyOriginals(ctx, cursor, limit)
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// RunHashBlocklistTests runs the shared blob.HashBlocklist test suite.
+func RunHashBlocklistTests(t *testing.T, list blob.HashBlocklist, teardown func()) {
+	for _, tf := range []func(t *testing.T, list blob.HashBlocklist){
+		testHashBlocklistAddListRemove,
+		testHashBlocklistAddKeepsFirstEntry,
+	} {
+		tf(t, list)
+		teardown()
+	}
+}
+
+func testHashBlocklistAddListRemove(t *testing.T, list blob.HashBlocklist) {
+	ctx := context.Background()
+
+	entries, err := list.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	staff := model.MustGenerateUserID()
+	fromBlob := &blob.BlockedHash{
+		Hash:         blob.PerceptualHash{PHash: 0x0123456789abcdef, DHash: 0xfedcba9876543210},
+		SourceBlobID: blob.MustGenerateID(),
+		AddedBy:      staff,
+		CreatedAt:    time.Now().Truncate(time.Millisecond),
+	}
+	bare := &blob.BlockedHash{
+		Hash:      blob.PerceptualHash{PHash: 1, DHash: 2},
+		CreatedAt: time.Now().Truncate(time.Millisecond),
+	}
+	require.NoError(t, list.Add(ctx, fromBlob))
+	require.NoError(t, list.Add(ctx, bare))
+
+	entries, err = list.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	byHash := make(map[blob.PerceptualHash]*blob.BlockedHash)
+	for _, entry := range entries {
+		byHash[entry.Hash] = entry
+	}
+	requireBlockedHash(t, fromBlob, byHash[fromBlob.Hash])
+	requireBlockedHash(t, bare, byHash[bare.Hash])
+
+	require.NoError(t, list.Remove(ctx, fromBlob.Hash))
+	require.ErrorIs(t, list.Remove(ctx, fromBlob.Hash), blob.ErrNotFound)
+
+	entries, err = list.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	requireBlockedHash(t, bare, entries[0])
+}
+
+func testHashBlocklistAddKeepsFirstEntry(t *testing.T, list blob.HashBlocklist) {
+	ctx := context.Background()
+
+	hash := blob.PerceptualHash{PHash: 42, DHash: 24}
+	first := &blob.BlockedHash{Hash: hash, AddedBy: model.MustGenerateUserID(), CreatedAt: time.Now().Truncate(time.Millisecond)}
+	second := &blob.BlockedHash{Hash: hash, AddedBy: model.MustGenerateUserID(), CreatedAt: first.CreatedAt.Add(time.Hour)}
+	require.NoError(t, list.Add(ctx, first))
+	require.NoError(t, list.Add(ctx, second))
+
+	entries, err := list.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	requireBlockedHash(t, first, entries[0])
+}
+
+func requireBlockedHash(t *testing.T, expected, actual *blob.BlockedHash) {
+	require.NotNil(t, actual)
+	require.Equal(t, expected.Hash, actual.Hash)
+	require.True(t, expected.CreatedAt.Equal(actual.CreatedAt))
+	if expected.SourceBlobID == nil {
+		require.Nil(t, actual.SourceBlobID)
+	} else {
+		require.Equal(t, expected.SourceBlobID.Value, actual.SourceBlobID.Value)
+	}
+	if expected.AddedBy == nil {
+		require.Nil(t, actual.AddedBy)
+	} else {
+		require.Equal(t, expected.AddedBy.Value, actual.AddedBy.Value)
+	}
+}
@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"context"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+// RunCollectorTests runs the shared blob.Collector test suite against the given
+// metadata and access stores. As in RunWorkerTests, the object storage is a
+// fake injected by the caller along with a direct put hook.
+func RunCollectorTests(
+	t *testing.T,
+	blobs blob.Store,
+	access blob.AccessStore,
+	storage blob.ObjectStorage,
+	putObject putObjectFunc,
+	teardown func(),
+) {
+	for _, tf := range []func(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc){
+		testCollectorCollectsAbandonedAndRejected,
+		testCollectorWaitsOutGracePeriod,
+		testCollectorKeepsReferencedOriginals,
+		testCollectorDefersInFlightBlobs,
+		testCollectorDryRun,
+		testCollectorKeepsContentVerdicts,
+	} {
+		tf(t, blobs, access, storage, putObject)
+		teardown()
+	}
+}
+
+// collectorHarness is a collector over the suite's stores, recording the
+// prefixes it deletes, plus a worker to finalize blobs into READY originals.
+type collectorHarness struct {
+	*workerHarness
+	collector *blob.Collector
+	access    blob.AccessStore
+	deleted   *prefixRecorder
+}
+
+func newCollectorHarness(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc, opts ...blob.CollectorOption) *collectorHarness {
+	deleted := &prefixRecorder{ObjectStorage: storage}
+	return &collectorHarness{
+		workerHarness: newWorkerHarness(t, blobs, deleted, putObject, &fakeModerator{}),
+		collector:     blob.NewCollector(zaptest.NewLogger(t), blobs, access, deleted, opts...),
+		access:        access,
+		deleted:       deleted,
+	}
+}
+
+// collect runs one collector tick and asserts how many candidates it handled.
+func (h *collectorHarness) collect(t *testing.T, expected int) {
+	handled, err := h.collector.Process(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, expected, handled)
+}
+
+// ready finalizes a fresh PNG original through the worker.
+func (h *collectorHarness) ready(t *testing.T) *blob.Blob {
+	record := h.stageUpload(t, makePNG(t, 100, 80), true)
+	h.mark(t, record)
+	h.process(t, 1)
+	got := h.state(t, record)
+	require.Equal(t, blob.StateReady, got.State)
+	require.NotEmpty(t, got.Renditions)
+	return got
+}
+
+func testCollectorCollectsAbandonedAndRejected(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	h := newCollectorHarness(t, blobs, access, storage, putObject, blob.WithCollectorGracePeriod(0))
+
+	// Uploaded but never completed, so it never left PENDING.
+	abandoned := h.stageUpload(t, makePNG(t, 20, 20), true)
+	rejected := h.stageUpload(t, makePNG(t, 20, 20), false)
+	_, err := blobs.Reject(ctx, rejected.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonCorrupt})
+	require.NoError(t, err)
+
+	h.collect(t, 2)
+
+	for _, record := range []*blob.Blob{abandoned, rejected} {
+		require.Equal(t, blob.StateDeleted, h.state(t, record).State)
+		require.True(t, h.deleted.has(itemPrefix(record)))
+	}
+	_, err = storage.GetUploaded(ctx, abandoned.StorageKey)
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+
+	// Tombstones leave the index.
+	h.collect(t, 0)
+}
+
+func testCollectorWaitsOutGracePeriod(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc) {
+	h := newCollectorHarness(t, blobs, access, storage, putObject, blob.WithCollectorGracePeriod(time.Hour))
+	record := h.stageUpload(t, makePNG(t, 20, 20), true)
+
+	h.collect(t, 0)
+	require.Equal(t, blob.StatePending, h.state(t, record).State)
+	require.False(t, h.deleted.has(itemPrefix(record)))
+}
+
+func testCollectorKeepsReferencedOriginals(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	// With no grace and no recheck interval, every indexed blob is judged on
+	// every tick.
+	h := newCollectorHarness(t, blobs, access, storage, putObject,
+		blob.WithCollectorGracePeriod(0),
+		blob.WithCollectorRecheckInterval(0),
+	)
+
+	referenced := h.ready(t)
+	unreferenced := h.ready(t)
+	chat := blob.PrincipalForChat(newChatID(t))
+	require.NoError(t, access.Grant(ctx, &blob.Grant{BlobID: referenced.ID, Principal: chat, Permission: blob.PermissionRead}))
+
+	h.collect(t, 2)
+
+	// The unreferenced original goes, and its renditions with it.
+	require.Equal(t, blob.StateDeleted, h.state(t, unreferenced).State)
+	require.True(t, h.deleted.has(itemPrefix(unreferenced)))
+	for _, ref := range unreferenced.Renditions {
+		got, err := blobs.GetByID(ctx, ref.ID)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateDeleted, got.State)
+	}
+
+	// The referenced one stays until its last grant is revoked.
+	got := h.state(t, referenced)
+	require.Equal(t, blob.StateReady, got.State)
+	require.NotEmpty(t, got.Renditions)
+	require.False(t, h.deleted.has(itemPrefix(referenced)))
+
+	h.collect(t, 1)
+	require.Equal(t, blob.StateReady, h.state(t, referenced).State)
+
+	require.NoError(t, access.Revoke(ctx, referenced.ID, chat, blob.PermissionRead))
+	h.collect(t, 1)
+	require.Equal(t, blob.StateDeleted, h.state(t, referenced).State)
+	require.True(t, h.deleted.has(itemPrefix(referenced)))
+}
+
+func testCollectorDefersInFlightBlobs(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	h := newCollectorHarness(t, blobs, access, storage, putObject, blob.WithCollectorGracePeriod(0))
+	record := h.stageUpload(t, makePNG(t, 20, 20), true)
+	_, err := blobs.Advance(ctx, record.ID, blob.StateUploaded, nil)
+	require.NoError(t, err)
+
+	h.collect(t, 1)
+	require.Equal(t, blob.StateUploaded, h.state(t, record).State)
+	require.False(t, h.deleted.has(itemPrefix(record)))
+
+	// It is judged again once it settles.
+	h.mark(t, record)
+	h.process(t, 1)
+	require.Equal(t, blob.StateReady, h.state(t, record).State)
+	h.collect(t, 1)
+	require.Equal(t, blob.StateDeleted, h.state(t, record).State)
+}
+
+func testCollectorDryRun(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	h := newCollectorHarness(t, blobs, access, storage, putObject, blob.WithCollectorGracePeriod(0), blob.WithCollectorDryRun())
+	record := h.stageUpload(t, makePNG(t, 20, 20), true)
+
+	// Judged on every tick, but nothing is deleted or written.
+	h.collect(t, 1)
+	h.collect(t, 1)
+	require.Equal(t, blob.StatePending, h.state(t, record).State)
+	require.False(t, h.deleted.has(itemPrefix(record)))
+	_, err := storage.GetUploaded(ctx, record.StorageKey)
+	require.NoError(t, err)
+}
+
+// itemPrefix is the storage prefix an original and its renditions share.
+func itemPrefix(record *blob.Blob) string {
+	return path.Dir(record.StorageKey) + "/"
+}
+
+// prefixRecorder is object storage that records the prefixes deleted with
+// DeletePrefix, which the suite cannot otherwise observe in the origin store.
+type prefixRecorder struct {
+	blob.ObjectStorage
+
+	mu       sync.Mutex
+	prefixes map[string]bool
+}
+
+func (r *prefixRecorder) DeletePrefix(ctx context.Context, prefix string) error {
+	r.mu.Lock()
+	if r.prefixes == nil {
+		r.prefixes = make(map[string]bool)
+	}
+	r.prefixes[prefix] = true
+	r.mu.Unlock()
+	return r.ObjectStorage.DeletePrefix(ctx, prefix)
+}
+
+func (r *prefixRecorder) has(prefix string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prefixes[prefix]
+}
+
+func testCollectorKeepsContentVerdicts(t *testing.T, blobs blob.Store, access blob.AccessStore, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	h := newCollectorHarness(t, blobs, access, storage, putObject, blob.WithCollectorGracePeriod(0))
+	integration := blob.NewIntegration(blobs, h.deleted, access)
+
+	// reupload finalizes another upload of the same bytes.
+	reupload := func(t *testing.T, data []byte) *blob.Blob {
+		record := h.stageUpload(t, data, true)
+		h.mark(t, record)
+		h.process(t, 1)
+		return h.state(t, record)
+	}
+
+	t.Run("taken down", func(t *testing.T) {
+		data := makePNG(t, 100, 80)
+		record := h.stageUpload(t, data, true)
+		h.mark(t, record)
+		h.process(t, 1)
+		require.Equal(t, blob.StateReady, h.state(t, record).State)
+
+		require.NoError(t, integration.RejectBlob(ctx, record.ID))
+		h.collect(t, 1)
+		require.Equal(t, blob.StateDeleted, h.state(t, record).State)
+
+		got := reupload(t, data)
+		require.Equal(t, blob.StateRejected, got.State)
+		require.Equal(t, blob.RejectionReasonTakenDown, got.Rejection.Reason)
+		h.collect(t, 1)
+	})
+
+	t.Run("rejected by moderation", func(t *testing.T) {
+		flagging := newWorkerHarness(t, blobs, h.deleted, putObject, &fakeModerator{flagged: true})
+		data := makePNG(t, 90, 70)
+		record := flagging.stageUpload(t, data, true)
+		flagging.mark(t, record)
+		flagging.process(t, 1)
+		require.Equal(t, blob.RejectionReasonModeration, h.state(t, record).Rejection.Reason)
+
+		h.collect(t, 1)
+		require.Equal(t, blob.StateDeleted, h.state(t, record).State)
+
+		// The harness's own moderator passes everything, so only the verdict
+		// carried over rejects the re-upload.
+		got := reupload(t, data)
+		require.Equal(t, blob.StateRejected, got.State)
+		require.Equal(t, blob.RejectionReasonModeration, got.Rejection.Reason)
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -54,6 +56,10 @@ func RunServerTests(
 		testModeration,
 		testRenditionGeneration,
 		testGetBlobs,
+		testQuotas,
+		testHashBlocklist,
+		testDeadLetterAdmin,
+		testMultipartUpload,
 	} {
 		// A fresh resolver per test func; the access store is reset by teardown.
 		resolver := newFakeResolver()
@@ -70,12 +76,12 @@ type harness struct {
 	worker *blob.Worker
 }
 
-func newHarness(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver blob.PrincipalResolver, moderator moderation.Client) *harness {
+func newHarness(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver blob.PrincipalResolver, moderator moderation.Client, opts ...blob.ServerOption) *harness {
 	log := zaptest.NewLogger(t)
 	authn := auth.NewKeyPairAuthenticator(log)
 	authz := account.NewAuthorizer(log, accounts, authn)
 	return &harness{
-		server: blob.NewServer(log, authz, accounts, blobs, storage, access, resolver, false),
+		server: blob.NewServer(log, authz, accounts, blobs, storage, access, resolver, false, opts...),
 		worker: blob.NewWorker(log, blobs, blob.NewFinalizer(log, blobs, storage, moderator), blob.ContentKindImage),
 	}
 }
@@ -136,22 +142,32 @@ func testGetUploadPolicy(t *testing.T, accounts account.Store, blobs blob.Store,
 		require.NotNil(t, policy.Ttl)
 		require.Positive(t, policy.Ttl.AsDuration())
 
-		// Exactly one entry per supported image type, with no wildcard fallback.
-		require.Len(t, policy.MimeTypeConstraints, len(blob.SupportedImageMimeTypes))
+		// Exactly one entry per supported image and video type, with no wildcard
+		// fallback.
+		supported := len(blob.SupportedImageMimeTypes) + len(blob.SupportedVideoMimeTypes)
+		require.Len(t, policy.MimeTypeConstraints, supported)
 		seen := make(map[string]bool)
 		for _, c := range policy.MimeTypeConstraints {
-			require.True(t, blob.SupportedImageMimeTypes[c.MimeTypePattern], "unexpected pattern %q", c.MimeTypePattern)
 			require.False(t, seen[c.MimeTypePattern], "duplicate pattern %q", c.MimeTypePattern)
 			seen[c.MimeTypePattern] = true
 
-			require.EqualValues(t, blob.MaxOriginalImageSizeBytes, c.MaxSizeBytes)
-			img := c.GetImage()
-			require.NotNil(t, img)
-			require.Positive(t, img.MaxWidth)
-			require.Positive(t, img.MaxHeight)
-			require.Positive(t, img.MaxPixels)
+			switch {
+			case blob.SupportedImageMimeTypes[c.MimeTypePattern]:
+				require.EqualValues(t, blob.MaxOriginalImageSizeBytes, c.MaxSizeBytes)
+				img := c.GetImage()
+				require.NotNil(t, img)
+				require.Positive(t, img.MaxWidth)
+				require.Positive(t, img.MaxHeight)
+				require.Positive(t, img.MaxPixels)
+			case blob.SupportedVideoMimeTypes[c.MimeTypePattern]:
+				// There is no video variant on the wire; only the byte ceiling applies.
+				require.EqualValues(t, blob.MaxOriginalVideoSizeBytes, c.MaxSizeBytes)
+				require.Nil(t, c.GetImage())
+			default:
+				require.Fail(t, "unexpected pattern", c.MimeTypePattern)
+			}
 		}
-		require.Len(t, seen, len(blob.SupportedImageMimeTypes))
+		require.Len(t, seen, supported)
 	})
 
 	t.Run("version matches the one echoed on a policy-driven denial", func(t *testing.T) {
@@ -240,6 +256,28 @@ func testInitiateExternalUpload(t *testing.T, accounts account.Store, blobs blob
 		require.Equal(t, blob.RenditionOriginal, record.Rendition)
 		require.Nil(t, record.ParentID)
 	})
+
+	t.Run("video is bounded by the video size limit", func(t *testing.T) {
+		_, signer := registerUser(t, accounts)
+
+		// Larger than any image may be, but within the video limit.
+		req := &blobpb.InitiateExternalUploadRequest{MimeType: "video/mp4", SizeBytes: blob.MaxOriginalImageSizeBytes + 1}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		resp, err := h.server.InitiateExternalUpload(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, resp.Result)
+
+		record, err := blobs.GetByID(context.Background(), resp.BlobId)
+		require.NoError(t, err)
+		require.Equal(t, blob.ContentKindVideo, record.ContentKind())
+		require.True(t, strings.HasPrefix(record.StorageKey, "videos/"), record.StorageKey)
+
+		req = &blobpb.InitiateExternalUploadRequest{MimeType: "video/mp4", SizeBytes: blob.MaxOriginalVideoSizeBytes + 1}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		resp, err = h.server.InitiateExternalUpload(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_TOO_LARGE, resp.Result)
+	})
 }
 
 func testUploadLifecycle(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver *fakeResolver, upload uploadFunc) {
@@ -367,9 +405,12 @@ func testModeration(t *testing.T, accounts account.Store, blobs blob.Store, stor
 	})
 
 	t.Run("clean image is ready", func(t *testing.T) {
+		// Different bytes from the flagged upload above, whose verdict would
+		// otherwise carry over to them (see testDeduplication).
+		cleanBytes := makePNG(t, 7, 7)
 		h := newHarness(t, accounts, blobs, storage, access, resolver, &fakeModerator{flagged: false})
-		blobID, target := initiate(t, h, signer, "image/png", uint64(len(imageBytes)))
-		upload(target, imageBytes)
+		blobID, target := initiate(t, h, signer, "image/png", uint64(len(cleanBytes)))
+		upload(target, cleanBytes)
 
 		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, blobID))
 	})
@@ -790,6 +831,411 @@ func testRenditionGeneration(t *testing.T, accounts account.Store, blobs blob.St
 	})
 }
 
+func testQuotas(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver *fakeResolver, upload uploadFunc) {
+	ctx := context.Background()
+	imageBytes := makePNG(t, 2000, 1000)
+	size := uint64(len(imageBytes))
+
+	// initiateResult runs InitiateExternalUpload and returns its result code.
+	initiateResult := func(t *testing.T, h *harness, signer model.KeyPair, sizeBytes uint64) blobpb.InitiateExternalUploadResponse_Result {
+		req := &blobpb.InitiateExternalUploadRequest{MimeType: "image/png", SizeBytes: sizeBytes}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		resp, err := h.server.InitiateExternalUpload(ctx, req)
+		require.NoError(t, err)
+		return resp.Result
+	}
+
+	t.Run("usage counts uploads and their renditions", func(t *testing.T) {
+		h := newHarness(t, accounts, blobs, storage, access, resolver, nil)
+		_, signer := registerUser(t, accounts)
+
+		report, err := getStorageUsage(t, h, signer)
+		require.NoError(t, err)
+		require.Zero(t, report.Usage)
+		require.Equal(t, blob.DefaultQuota, report.Quota)
+		require.Equal(t, blob.UsageDay(time.Now()), report.Day)
+
+		blobID, target := initiate(t, h, signer, "image/png", size)
+		upload(target, imageBytes)
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, blobID))
+		record, err := blobs.GetByID(ctx, blobID)
+		require.NoError(t, err)
+		require.NotEmpty(t, record.Renditions)
+
+		stored := size
+		for _, ref := range record.Renditions {
+			stored += ref.SizeBytes
+		}
+		report, err = getStorageUsage(t, h, signer)
+		require.NoError(t, err)
+		require.Equal(t, stored, report.Usage.StoredBytes)
+		require.Equal(t, size, report.Usage.DailyBytes)
+	})
+
+	t.Run("the daily quota bounds the day's reservations", func(t *testing.T) {
+		h := newHarness(t, accounts, blobs, storage, access, resolver, nil, blob.WithQuota(blob.Quota{DailyBytes: 2 * size}))
+		_, signer := registerUser(t, accounts)
+
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, initiateResult(t, h, signer, size))
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, initiateResult(t, h, signer, size))
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_QUOTA_EXCEEDED, initiateResult(t, h, signer, 1))
+
+		// Another account has its own allowance.
+		_, other := registerUser(t, accounts)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, initiateResult(t, h, other, size))
+	})
+
+	t.Run("the total quota bounds stored bytes, renditions included", func(t *testing.T) {
+		h := newHarness(t, accounts, blobs, storage, access, resolver, nil, blob.WithQuota(blob.Quota{TotalBytes: 2 * size}))
+		_, signer := registerUser(t, accounts)
+
+		// One finalized upload plus its renditions leaves less than another
+		// upload's worth of room.
+		blobID, target := initiate(t, h, signer, "image/png", size)
+		upload(target, imageBytes)
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, blobID))
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_QUOTA_EXCEEDED, initiateResult(t, h, signer, size))
+
+		report, err := getStorageUsage(t, h, signer)
+		require.NoError(t, err)
+		require.Less(t, report.Usage.StoredBytes, report.Quota.TotalBytes)
+		room := report.Quota.TotalBytes - report.Usage.StoredBytes
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, initiateResult(t, h, signer, room))
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_QUOTA_EXCEEDED, initiateResult(t, h, signer, 1))
+	})
+
+	t.Run("staff have their own quota", func(t *testing.T) {
+		staff := &staffAccounts{Store: accounts}
+		exempt := newHarness(t, staff, blobs, storage, access, resolver, nil, blob.WithQuota(blob.Quota{DailyBytes: 1, TotalBytes: 1}))
+		owner, signer := registerUser(t, accounts)
+		staff.add(owner)
+
+		// Exempt by default...
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, initiateResult(t, exempt, signer, size))
+		report, err := getStorageUsage(t, exempt, signer)
+		require.NoError(t, err)
+		require.Equal(t, blob.DefaultStaffQuota, report.Quota)
+
+		// ...or held to a higher limit when one is configured.
+		limited := newHarness(t, staff, blobs, storage, access, resolver, nil,
+			blob.WithQuota(blob.Quota{DailyBytes: 1}),
+			blob.WithStaffQuota(blob.Quota{DailyBytes: 2 * size}),
+		)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, initiateResult(t, limited, signer, size))
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_QUOTA_EXCEEDED, initiateResult(t, limited, signer, size))
+	})
+}
+
+func testHashBlocklist(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver *fakeResolver, upload uploadFunc) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+	staff := &staffAccounts{Store: accounts}
+	blocklist := &fakeBlocklist{}
+	authz := account.NewAuthorizer(log, staff, auth.NewKeyPairAuthenticator(log))
+	h := &harness{
+		server: blob.NewServer(log, authz, staff, blobs, storage, access, resolver, false, blob.WithHashBlocklistAdmin(blocklist)),
+		worker: blob.NewWorker(log, blobs, blob.NewFinalizer(log, blobs, storage, nil, blob.WithHashBlocklist(blocklist)), blob.ContentKindImage),
+	}
+
+	staffID, staffSigner := registerUser(t, accounts)
+	staff.add(staffID)
+	_, signer := registerUser(t, accounts)
+
+	photo := makePhotoPNG(t, 320, 240)
+	removed, target := initiate(t, h, signer, "image/png", uint64(len(photo)))
+	upload(target, photo)
+	require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, removed))
+
+	t.Run("only staff manage the blocklist", func(t *testing.T) {
+		_, err := blockBlob(t, h, signer, removed)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		require.Equal(t, codes.PermissionDenied, status.Code(unblockHash(t, h, signer, blob.PerceptualHash{})))
+
+		_, err = blockBlob(t, h, staffSigner, blob.MustGenerateID())
+		require.Equal(t, codes.NotFound, status.Code(err))
+		require.Equal(t, codes.NotFound, status.Code(unblockHash(t, h, staffSigner, blob.PerceptualHash{})))
+
+		disabled := newHarness(t, staff, blobs, storage, access, resolver, nil)
+		_, err = blockBlob(t, disabled, staffSigner, removed)
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	reencoded := reencodeJPEG(t, photo)
+	uploadReencoded := func(t *testing.T) *blobpb.CompleteExternalUploadResponse {
+		blobID, target := initiate(t, h, signer, "image/jpeg", uint64(len(reencoded)))
+		upload(target, reencoded)
+		return completeAndProcess(t, h, signer, blobID)
+	}
+
+	t.Run("blocked images are rejected until unblocked", func(t *testing.T) {
+		entry, err := blockBlob(t, h, staffSigner, removed)
+		require.NoError(t, err)
+		record, err := blobs.GetByID(ctx, removed)
+		require.NoError(t, err)
+		require.Equal(t, *record.PerceptualHash, entry.Hash)
+		require.Equal(t, removed.Value, entry.SourceBlobID.Value)
+		require.Equal(t, staffID.Value, entry.AddedBy.Value)
+
+		resp := uploadReencoded(t)
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_REJECTED, resp.Status)
+		require.Equal(t, blobpb.RejectionReason_REJECTION_REASON_MODERATION, resp.RejectionMetadata.Reason)
+		require.Equal(t, moderationpb.FlaggedCategory_NONE, resp.RejectionMetadata.FlaggedCategory)
+
+		require.NoError(t, unblockHash(t, h, staffSigner, entry.Hash))
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, uploadReencoded(t).Status)
+	})
+}
+
+func testDeadLetterAdmin(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver *fakeResolver, upload uploadFunc) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+	staff := &staffAccounts{Store: accounts}
+	authz := account.NewAuthorizer(log, staff, auth.NewKeyPairAuthenticator(log))
+	h := &harness{
+		server: blob.NewServer(log, authz, staff, blobs, storage, access, resolver, false),
+		worker: blob.NewWorker(log, blobs, blob.NewFinalizer(log, blobs, storage, nil), blob.ContentKindImage),
+	}
+
+	staffID, staffSigner := registerUser(t, accounts)
+	staff.add(staffID)
+	_, signer := registerUser(t, accounts)
+
+	// Three completed uploads, parked as the worker would once their attempts
+	// ran out.
+	var parked []*blobpb.BlobId
+	for i := range 3 {
+		data := makePNG(t, 40+i, 30)
+		blobID, target := initiate(t, h, signer, "image/png", uint64(len(data)))
+		upload(target, data)
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_PROCESSING, completeResponse(t, h, signer, blobID).Status)
+		require.NoError(t, blobs.DeadLetterFinalization(ctx, blobID, blob.ContentKindImage, 8, "origin unavailable", time.Now()))
+		parked = append(parked, blobID)
+	}
+	h.drain(t)
+
+	t.Run("only staff manage dead letters", func(t *testing.T) {
+		_, err := listDeadLetters(t, h, signer, blob.ContentKindImage, 0)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		require.Equal(t, codes.PermissionDenied, status.Code(retryDeadLetter(t, h, signer, parked[0])))
+		_, err = retryDeadLetters(t, h, signer, blob.ContentKindImage)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = listDeadLetters(t, h, staffSigner, blob.ContentKindUnknown, 0)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Equal(t, codes.NotFound, status.Code(retryDeadLetter(t, h, staffSigner, blob.MustGenerateID())))
+	})
+
+	t.Run("dead letters are listed per kind", func(t *testing.T) {
+		letters, err := listDeadLetters(t, h, staffSigner, blob.ContentKindImage, 0)
+		require.NoError(t, err)
+		require.Len(t, letters, len(parked))
+		for i, letter := range letters {
+			require.Equal(t, parked[i].Value, letter.ID.Value)
+			require.Equal(t, "origin unavailable", letter.LastError)
+			require.EqualValues(t, 8, letter.Attempts)
+		}
+
+		letters, err = listDeadLetters(t, h, staffSigner, blob.ContentKindImage, 1)
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+
+		letters, err = listDeadLetters(t, h, staffSigner, blob.ContentKindVideo, 0)
+		require.NoError(t, err)
+		require.Empty(t, letters)
+	})
+
+	t.Run("retrying one re-queues just it", func(t *testing.T) {
+		require.NoError(t, retryDeadLetter(t, h, staffSigner, parked[0]))
+		h.drain(t)
+		record, err := blobs.GetByID(ctx, parked[0])
+		require.NoError(t, err)
+		require.Equal(t, blob.StateReady, record.State)
+
+		// A finalized blob has nothing to retry.
+		require.Equal(t, codes.FailedPrecondition, status.Code(retryDeadLetter(t, h, staffSigner, parked[0])))
+
+		letters, err := listDeadLetters(t, h, staffSigner, blob.ContentKindImage, 0)
+		require.NoError(t, err)
+		require.Len(t, letters, len(parked)-1)
+	})
+
+	t.Run("retrying all re-queues the rest", func(t *testing.T) {
+		retried, err := retryDeadLetters(t, h, staffSigner, blob.ContentKindImage)
+		require.NoError(t, err)
+		require.Equal(t, len(parked)-1, retried)
+		h.drain(t)
+		for _, blobID := range parked[1:] {
+			record, err := blobs.GetByID(ctx, blobID)
+			require.NoError(t, err)
+			require.Equal(t, blob.StateReady, record.State)
+		}
+
+		letters, err := listDeadLetters(t, h, staffSigner, blob.ContentKindImage, 0)
+		require.NoError(t, err)
+		require.Empty(t, letters)
+		retried, err = retryDeadLetters(t, h, staffSigner, blob.ContentKindImage)
+		require.NoError(t, err)
+		require.Zero(t, retried)
+	})
+}
+
+func testMultipartUpload(t *testing.T, accounts account.Store, blobs blob.Store, storage blob.ObjectStorage, access blob.AccessStore, resolver *fakeResolver, upload uploadFunc) {
+	ctx := context.Background()
+	const partSize = 4 << 10
+	h := newHarness(t, accounts, blobs, storage, access, resolver, nil, blob.WithMultipartPartSize(partSize))
+	_, signer := registerUser(t, accounts)
+	_, other := registerUser(t, accounts)
+
+	t.Run("reservation is checked like a single upload", func(t *testing.T) {
+		unregistered := model.MustGenerateKeyPair()
+		_, err := accounts.Bind(ctx, model.MustGenerateUserID(), unregistered.Proto())
+		require.NoError(t, err)
+		result, err := initiateMultipart(t, h, unregistered, "image/png", 1024)
+		require.NoError(t, err)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_DENIED, result.Result)
+
+		result, err = initiateMultipart(t, h, signer, "application/pdf", 1024)
+		require.NoError(t, err)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_UNSUPPORTED_TYPE, result.Result)
+		require.NotNil(t, result.PolicyVersion)
+		require.Nil(t, result.BlobID)
+		require.Empty(t, result.Parts)
+	})
+
+	t.Run("the signature covers the method, its arguments and its time", func(t *testing.T) {
+		req := &blob.InitiateMultipartUploadRequest{MimeType: "image/png", SizeBytes: 1024, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		req.SizeBytes = 2048
+		_, err := h.server.InitiateMultipartUpload(ctx, req)
+		require.Error(t, err)
+
+		usage := &blob.GetStorageUsageRequest{Ts: time.Now()}
+		require.NoError(t, signer.Auth(usage.Payload(), &usage.Auth))
+		_, err = h.server.InitiateMultipartUpload(ctx, &blob.InitiateMultipartUploadRequest{MimeType: "image/png", SizeBytes: 1024, Ts: usage.Ts, Auth: usage.Auth})
+		require.Error(t, err)
+
+		req = &blob.InitiateMultipartUploadRequest{MimeType: "image/png", SizeBytes: 1024, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		req.Ts = req.Ts.Add(time.Second)
+		_, err = h.server.InitiateMultipartUpload(ctx, req)
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("parts are assembled and finalized", func(t *testing.T) {
+		photo := makePhotoPNG(t, 320, 240)
+		result, err := initiateMultipart(t, h, signer, "image/png", uint64(len(photo)))
+		require.NoError(t, err)
+		require.Equal(t, blobpb.InitiateExternalUploadResponse_OK, result.Result)
+		require.EqualValues(t, partSize, result.PartSizeBytes)
+		require.Len(t, result.Parts, (len(photo)+partSize-1)/partSize)
+		require.Greater(t, len(result.Parts), 2)
+
+		chunk := func(i int) []byte { return photo[i*partSize : min((i+1)*partSize, len(photo))] }
+		last := len(result.Parts) - 1
+		for i, target := range result.Parts[:last] {
+			require.Equal(t, blobpb.UploadTarget_PUT, target.Method)
+			upload(target, chunk(i))
+		}
+
+		// Completing before every part landed reports the upload as missing.
+		req := &blobpb.CompleteExternalUploadRequest{BlobId: result.BlobID}
+		require.NoError(t, signer.Auth(req, &req.Auth))
+		resp, err := h.server.CompleteExternalUpload(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, blobpb.CompleteExternalUploadResponse_NOT_UPLOADED, resp.Result)
+		record, err := blobs.GetByID(ctx, result.BlobID)
+		require.NoError(t, err)
+		require.Equal(t, blob.StatePending, record.State)
+		require.NotNil(t, record.Multipart)
+		require.EqualValues(t, partSize, record.Multipart.PartSizeBytes)
+
+		// Fresh targets are only minted for the uploader's own pending upload.
+		_, err = presignUploadParts(t, h, other, result.BlobID)
+		require.Equal(t, codes.NotFound, status.Code(err))
+		renewed, err := presignUploadParts(t, h, signer, result.BlobID)
+		require.NoError(t, err)
+		require.Len(t, renewed, len(result.Parts))
+
+		// A part is retried on its own: the first is sent again, the last through
+		// a renewed target.
+		upload(renewed[0], chunk(0))
+		upload(renewed[last], chunk(last))
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, result.BlobID))
+
+		record, err = blobs.GetByID(ctx, result.BlobID)
+		require.NoError(t, err)
+		require.EqualValues(t, len(photo), record.SizeBytes)
+		require.NotNil(t, record.Image)
+		require.EqualValues(t, 320, record.Image.Width)
+
+		// Completing again reports the committed status; the upload is done, so
+		// no more targets are minted.
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, result.BlobID))
+		_, err = presignUploadParts(t, h, signer, result.BlobID)
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("single uploads have no parts", func(t *testing.T) {
+		data := makePNG(t, 40, 30)
+		blobID, target := initiate(t, h, signer, "image/png", uint64(len(data)))
+		upload(target, data)
+		_, err := presignUploadParts(t, h, signer, blobID)
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		require.Equal(t, blobpb.BlobStatus_BLOB_STATUS_READY, complete(t, h, signer, blobID))
+	})
+}
+
+// The helpers below sign and send the requests of the Server methods backing
+// unpublished RPCs.
+
+func getStorageUsage(t *testing.T, h *harness, signer model.KeyPair) (*blob.UsageReport, error) {
+	req := &blob.GetStorageUsageRequest{Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.GetStorageUsage(context.Background(), req)
+}
+
+func initiateMultipart(t *testing.T, h *harness, signer model.KeyPair, mimeType string, sizeBytes uint64) (*blob.MultipartUploadResult, error) {
+	req := &blob.InitiateMultipartUploadRequest{MimeType: mimeType, SizeBytes: sizeBytes, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.InitiateMultipartUpload(context.Background(), req)
+}
+
+func presignUploadParts(t *testing.T, h *harness, signer model.KeyPair, blobID *blobpb.BlobId) ([]*blobpb.UploadTarget, error) {
+	req := &blob.PresignUploadPartsRequest{BlobID: blobID, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.PresignUploadParts(context.Background(), req)
+}
+
+func blockBlob(t *testing.T, h *harness, signer model.KeyPair, blobID *blobpb.BlobId) (*blob.BlockedHash, error) {
+	req := &blob.BlockBlobRequest{BlobID: blobID, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.BlockBlob(context.Background(), req)
+}
+
+func unblockHash(t *testing.T, h *harness, signer model.KeyPair, hash blob.PerceptualHash) error {
+	req := &blob.UnblockHashRequest{Hash: hash, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.UnblockHash(context.Background(), req)
+}
+
+func listDeadLetters(t *testing.T, h *harness, signer model.KeyPair, kind blob.ContentKind, limit int) ([]*blob.DeadLetter, error) {
+	req := &blob.ListDeadLettersRequest{Kind: kind, Limit: limit, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.ListDeadLetters(context.Background(), req)
+}
+
+func retryDeadLetter(t *testing.T, h *harness, signer model.KeyPair, blobID *blobpb.BlobId) error {
+	req := &blob.RetryDeadLetterRequest{BlobID: blobID, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.RetryDeadLetter(context.Background(), req)
+}
+
+func retryDeadLetters(t *testing.T, h *harness, signer model.KeyPair, kind blob.ContentKind) (int, error) {
+	req := &blob.RetryDeadLettersRequest{Kind: kind, Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return h.server.RetryDeadLetters(context.Background(), req)
+}
+
 // initiate runs InitiateExternalUpload and returns the reserved id and target.
 func initiate(t *testing.T, h *harness, signer model.KeyPair, mimeType string, sizeBytes uint64) (*blobpb.BlobId, *blobpb.UploadTarget) {
 	req := &blobpb.InitiateExternalUploadRequest{MimeType: mimeType, SizeBytes: sizeBytes}
@@ -919,6 +1365,25 @@ func resolverKey(principal blob.Principal, user *commonpb.UserId) string {
 	return fmt.Sprintf("%d|%q|%q", int(principal.Type), principal.ID, user.Value)
 }
 
+// staffAccounts overlays a set of staff accounts on an account.Store, whose
+// own staff flag the in-memory store cannot set.
+type staffAccounts struct {
+	account.Store
+
+	staff sync.Map
+}
+
+func (a *staffAccounts) add(userID *commonpb.UserId) {
+	a.staff.Store(string(userID.Value), struct{}{})
+}
+
+func (a *staffAccounts) IsStaff(ctx context.Context, userID *commonpb.UserId) (bool, error) {
+	if _, ok := a.staff.Load(string(userID.Value)); ok {
+		return true, nil
+	}
+	return a.Store.IsStaff(ctx, userID)
+}
+
 type fakeModerator struct {
 	flagged bool
 	// categories, when set, are returned as the flagged categories (each given a
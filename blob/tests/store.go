@@ -1,13 +1,16 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
 	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 	moderationpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/moderation/v1"
 
 	"github.com/code-payments/flipcash2-server/blob"
@@ -19,9 +22,18 @@ func RunStoreTests(t *testing.T, store blob.Store, teardown func()) {
 	for _, tf := range []func(t *testing.T, store blob.Store){
 		testStoreCreateAndGet,
 		testStoreAdvance,
+		testStoreVideoMetadata,
 		testStoreReject,
+		testStoreReinstate,
+		testStoreTakeDown,
 		testStoreRenditions,
 		testStoreFinalizationQueue,
+		testStoreDeadLetters,
+		testStoreCollection,
+		testStoreUsage,
+		testStoreContentHash,
+		testStoreScanReadyOriginals,
+		testStoreGetOriginalsByOwner,
 	} {
 		tf(t, store)
 		teardown()
@@ -64,11 +76,17 @@ func testStoreCreateAndGet(t *testing.T, store blob.Store) {
 	require.Equal(t, original.StorageKey, got.StorageKey)
 	require.Equal(t, original.Owner.Value, got.Owner.Value)
 	require.Nil(t, got.ParentID)
+	require.Nil(t, got.Multipart)
 
 	// GetByIDs returns only the ids that exist.
 	second := pendingOriginal(t)
+	second.Multipart = &blob.MultipartUpload{UploadID: "upload-1", PartSizeBytes: 5 << 20}
 	require.NoError(t, store.CreatePending(ctx, second))
 
+	got, err = store.GetByID(ctx, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, second.Multipart, got.Multipart)
+
 	found, err := store.GetByIDs(ctx, []*blobpb.BlobId{original.ID, blob.MustGenerateID(), second.ID})
 	require.NoError(t, err)
 	require.Len(t, found, 2)
@@ -94,7 +112,7 @@ func testStoreAdvance(t *testing.T, store blob.Store) {
 
 	// The metadata is persisted at the StateInspected checkpoint.
 	image := &blob.ImageMetadata{Width: 100, Height: 200, Blurhash: "LEHV6nWB", HasAlpha: true}
-	advanced, err = store.Advance(ctx, original.ID, blob.StateInspected, image)
+	advanced, err = store.Advance(ctx, original.ID, blob.StateInspected, &blob.DerivedMetadata{Image: image})
 	require.NoError(t, err)
 	require.True(t, advanced)
 	got, err = store.GetByID(ctx, original.ID)
@@ -108,6 +126,8 @@ func testStoreAdvance(t *testing.T, store blob.Store) {
 	// The declared type and size are never altered.
 	require.Equal(t, original.MimeType, got.MimeType)
 	require.Equal(t, original.SizeBytes, got.SizeBytes)
+	require.Zero(t, got.OriginSizeBytes)
+	require.Equal(t, original.SizeBytes, got.ServedSizeBytes())
 
 	advanced, err = store.Advance(ctx, original.ID, blob.StatePromoted, nil)
 	require.NoError(t, err)
@@ -120,6 +140,19 @@ func testStoreAdvance(t *testing.T, store blob.Store) {
 	require.Equal(t, blob.StateReady, got.State)
 	require.NotNil(t, got.Image) // retained across later advances
 
+	// A sanitized original records the size of its rewritten bytes alongside the
+	// declared size, which is left as pinned.
+	sanitized := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, sanitized))
+	advanced, err = store.Advance(ctx, sanitized.ID, blob.StateInspected, &blob.DerivedMetadata{Image: image, OriginSizeBytes: 512})
+	require.NoError(t, err)
+	require.True(t, advanced)
+	got, err = store.GetByID(ctx, sanitized.ID)
+	require.NoError(t, err)
+	require.Equal(t, sanitized.SizeBytes, got.SizeBytes)
+	require.EqualValues(t, 512, got.OriginSizeBytes)
+	require.EqualValues(t, 512, got.ServedSizeBytes())
+
 	// Advancing is forward-only and idempotent: a backward target, or a terminal
 	// blob, does not transition and reports advanced == false.
 	advanced, err = store.Advance(ctx, original.ID, blob.StateUploaded, nil)
@@ -136,6 +169,52 @@ func testStoreAdvance(t *testing.T, store blob.Store) {
 	require.False(t, advanced)
 }
 
+func testStoreVideoMetadata(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	id := blob.MustGenerateID()
+	key, err := blob.StorageKey(id, "video/mp4")
+	require.NoError(t, err)
+	original := &blob.Blob{
+		ID:         id,
+		Rendition:  blob.RenditionOriginal,
+		Owner:      model.MustGenerateUserID(),
+		State:      blob.StatePending,
+		StorageKey: key,
+		MimeType:   "video/mp4",
+		SizeBytes:  4321,
+	}
+	require.NoError(t, store.CreatePending(ctx, original))
+
+	// A video's metadata is persisted at the StateInspected checkpoint, just as an
+	// image's is, and leaves the image metadata unset.
+	video := &blob.VideoMetadata{
+		Width:    1080,
+		Height:   1920,
+		Duration: 12500 * time.Millisecond,
+		Codec:    "hevc",
+		Rotation: 90,
+		Blurhash: "LEHV6nWB",
+	}
+	advanced, err := store.Advance(ctx, id, blob.StateInspected, &blob.DerivedMetadata{Video: video})
+	require.NoError(t, err)
+	require.True(t, advanced)
+
+	got, err := store.GetByID(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, blob.ContentKindVideo, got.ContentKind())
+	require.Nil(t, got.Image)
+	require.Equal(t, video, got.Video)
+
+	advanced, err = store.Advance(ctx, id, blob.StateReady, nil)
+	require.NoError(t, err)
+	require.True(t, advanced)
+	found, err := store.GetByIDs(ctx, []*blobpb.BlobId{id})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, video, found[0].Video) // retained across later advances
+}
+
 func testStoreReject(t *testing.T, store blob.Store) {
 	ctx := context.Background()
 
@@ -171,6 +250,21 @@ func testStoreReject(t *testing.T, store blob.Store) {
 	require.NoError(t, err)
 	require.Equal(t, blob.RejectionReasonModeration, got.Rejection.Reason)
 	require.Equal(t, moderationpb.FlaggedCategory_NSFW, got.Rejection.FlaggedCategory)
+	require.False(t, got.Rejection.StripFailed)
+
+	// A failed sanitize round-trips as such.
+	unstrippable := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, unstrippable))
+	advanced, err = store.Reject(ctx, unstrippable.ID, &blob.RejectionMetadata{
+		Reason:      blob.RejectionReasonPrivacyMetadataPresent,
+		StripFailed: true,
+	})
+	require.NoError(t, err)
+	require.True(t, advanced)
+	got, err = store.GetByID(ctx, unstrippable.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.RejectionReasonPrivacyMetadataPresent, got.Rejection.Reason)
+	require.True(t, got.Rejection.StripFailed)
 
 	// Rejection is terminal and idempotent: a second reject neither transitions nor
 	// overwrites the recorded reason.
@@ -197,6 +291,126 @@ func testStoreReject(t *testing.T, store blob.Store) {
 	require.Nil(t, got.Rejection)
 }
 
+func testStoreReinstate(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	reinstated, err := store.Reinstate(ctx, blob.MustGenerateID())
+	require.ErrorIs(t, err, blob.ErrNotFound)
+	require.False(t, reinstated)
+
+	// A moderation rejection is undone: the blob is back at StateUploaded, with
+	// moderation bypassed, and can be queued and finalized again.
+	flagged := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, flagged))
+	_, err = store.Advance(ctx, flagged.ID, blob.StateUploaded, nil)
+	require.NoError(t, err)
+	_, err = store.Reject(ctx, flagged.ID, &blob.RejectionMetadata{
+		Reason:          blob.RejectionReasonModeration,
+		FlaggedCategory: moderationpb.FlaggedCategory_NSFW,
+	})
+	require.NoError(t, err)
+
+	reinstated, err = store.Reinstate(ctx, flagged.ID)
+	require.NoError(t, err)
+	require.True(t, reinstated)
+	got, err := store.GetByID(ctx, flagged.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateUploaded, got.State)
+	require.Nil(t, got.Rejection)
+	require.True(t, got.ModerationBypassed)
+
+	require.NoError(t, store.MarkForFinalization(ctx, flagged.ID, blob.ContentKindImage, time.Now()))
+	due, err := store.GetDueForFinalization(ctx, blob.ContentKindImage, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, flagged.ID.Value, due[0].ID.Value)
+
+	// Reinstating again is a no-op.
+	reinstated, err = store.Reinstate(ctx, flagged.ID)
+	require.NoError(t, err)
+	require.False(t, reinstated)
+
+	// The bypass survives the blob's way to READY.
+	for _, to := range []blob.State{blob.StateInspected, blob.StatePromoted, blob.StateReady} {
+		_, err = store.Advance(ctx, flagged.ID, to, nil)
+		require.NoError(t, err)
+	}
+	got, err = store.GetByID(ctx, flagged.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateReady, got.State)
+	require.True(t, got.ModerationBypassed)
+
+	// Any other rejection stands, as does a blob never rejected.
+	corrupt := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, corrupt))
+	_, err = store.Reject(ctx, corrupt.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonCorrupt})
+	require.NoError(t, err)
+	pending := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, pending))
+	for _, id := range []*blobpb.BlobId{corrupt.ID, pending.ID} {
+		reinstated, err = store.Reinstate(ctx, id)
+		require.NoError(t, err)
+		require.False(t, reinstated)
+	}
+	got, err = store.GetByID(ctx, corrupt.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateRejected, got.State)
+	require.Equal(t, blob.RejectionReasonCorrupt, got.Rejection.Reason)
+	require.False(t, got.ModerationBypassed)
+}
+
+func testStoreTakeDown(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	takenDown, err := store.TakeDown(ctx, blob.MustGenerateID())
+	require.ErrorIs(t, err, blob.ErrNotFound)
+	require.False(t, takenDown)
+
+	// A READY original is moved to StateRejected.
+	ready := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, ready))
+	for _, to := range []blob.State{blob.StateUploaded, blob.StateInspected, blob.StatePromoted, blob.StateReady} {
+		_, err = store.Advance(ctx, ready.ID, to, nil)
+		require.NoError(t, err)
+	}
+
+	takenDown, err = store.TakeDown(ctx, ready.ID)
+	require.NoError(t, err)
+	require.True(t, takenDown)
+	got, err := store.GetByID(ctx, ready.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateRejected, got.State)
+	require.Equal(t, blob.RejectionReasonTakenDown, got.Rejection.Reason)
+
+	// Taking it down again is a no-op, and it is not a rejection an appeal can
+	// reinstate.
+	takenDown, err = store.TakeDown(ctx, ready.ID)
+	require.NoError(t, err)
+	require.False(t, takenDown)
+	reinstated, err := store.Reinstate(ctx, ready.ID)
+	require.NoError(t, err)
+	require.False(t, reinstated)
+
+	// A blob still finalizing, or rejected by finalization, is left alone.
+	pending := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, pending))
+	corrupt := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, corrupt))
+	_, err = store.Reject(ctx, corrupt.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonCorrupt})
+	require.NoError(t, err)
+	for _, id := range []*blobpb.BlobId{pending.ID, corrupt.ID} {
+		takenDown, err = store.TakeDown(ctx, id)
+		require.NoError(t, err)
+		require.False(t, takenDown)
+	}
+	got, err = store.GetByID(ctx, pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StatePending, got.State)
+	got, err = store.GetByID(ctx, corrupt.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.RejectionReasonCorrupt, got.Rejection.Reason)
+}
+
 func testStoreFinalizationQueue(t *testing.T, store blob.Store) {
 	ctx := context.Background()
 	now := time.Now()
@@ -328,6 +542,86 @@ func testStoreFinalizationQueue(t *testing.T, store blob.Store) {
 	require.Empty(t, due)
 }
 
+func testStoreDeadLetters(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	letters, err := store.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Empty(t, letters)
+
+	first := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, first))
+	second := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, second))
+	for _, record := range []*blob.Blob{first, second} {
+		require.NoError(t, store.MarkForFinalization(ctx, record.ID, blob.ContentKindImage, now))
+		require.NoError(t, store.DelayFinalization(ctx, record.ID, now))
+	}
+
+	// Dead-lettering takes a blob off the queue — nothing is due, claimable, or
+	// counted — and records why it was parked.
+	require.NoError(t, store.DeadLetterFinalization(ctx, first.ID, blob.ContentKindImage, 5, "first failure", now.Add(time.Second)))
+	require.NoError(t, store.DeadLetterFinalization(ctx, second.ID, blob.ContentKindImage, 6, "second failure", now))
+	due, err := store.GetDueForFinalization(ctx, blob.ContentKindImage, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+	claimed, err := store.ClaimForFinalization(ctx, first.ID, now.Add(time.Hour), now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.False(t, claimed)
+	stats, err := store.GetFinalizationQueueStats(ctx, blob.ContentKindImage)
+	require.NoError(t, err)
+	require.Zero(t, stats.Depth)
+
+	// A delay does not resurrect a dead letter, and parking it again keeps the
+	// first record.
+	require.NoError(t, store.DelayFinalization(ctx, first.ID, now))
+	require.NoError(t, store.DeadLetterFinalization(ctx, first.ID, blob.ContentKindImage, 9, "again", now.Add(time.Minute)))
+
+	// Longest parked first, partitioned by kind, and the limit is honored.
+	letters, err = store.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	require.Equal(t, second.ID.Value, letters[0].ID.Value)
+	require.Equal(t, first.ID.Value, letters[1].ID.Value)
+	require.Equal(t, blob.ContentKindImage, letters[1].Kind)
+	require.Equal(t, "first failure", letters[1].LastError)
+	require.EqualValues(t, 5, letters[1].Attempts)
+	require.True(t, letters[1].DeadLetteredAt.Equal(now.Add(time.Second)))
+	require.False(t, letters[1].EnqueuedAt.IsZero())
+	require.False(t, letters[1].EnqueuedAt.After(time.Now()))
+	letters, err = store.GetDeadLetters(ctx, blob.ContentKindImage, 1)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, second.ID.Value, letters[0].ID.Value)
+	letters, err = store.GetDeadLetters(ctx, blob.ContentKindVideo, 10)
+	require.NoError(t, err)
+	require.Empty(t, letters)
+
+	// Re-marking retries it afresh: back on the queue with no attempts behind it.
+	require.NoError(t, store.MarkForFinalization(ctx, first.ID, blob.ContentKindImage, now))
+	due, err = store.GetDueForFinalization(ctx, blob.ContentKindImage, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, first.ID.Value, due[0].ID.Value)
+	require.Zero(t, due[0].Attempts)
+	letters, err = store.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, second.ID.Value, letters[0].ID.Value)
+
+	// A terminal transition clears a dead letter along with the queue entry.
+	advanced, err := store.Reject(ctx, second.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonInternal})
+	require.NoError(t, err)
+	require.True(t, advanced)
+	letters, err = store.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Empty(t, letters)
+	advanced, err = store.Reject(ctx, first.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonInternal})
+	require.NoError(t, err)
+	require.True(t, advanced)
+}
+
 func testStoreRenditions(t *testing.T, store blob.Store) {
 	ctx := context.Background()
 
@@ -344,8 +638,8 @@ func testStoreRenditions(t *testing.T, store blob.Store) {
 
 	refs := []blob.RenditionRef{
 		{
-			ID:         blob.MustGenerateID(),
-			Rendition:  blob.RenditionThumbnail,
+			ID:        blob.MustGenerateID(),
+			Rendition: blob.RenditionThumbnail,
 			// Shares the original's mime type (the common case): it must still round-trip
 			// even when the store dedups it against the original.
 			MimeType:   "image/png",
@@ -356,8 +650,8 @@ func testStoreRenditions(t *testing.T, store blob.Store) {
 			Image: &blob.ImageMetadata{Width: 160, Height: 90, Blurhash: "LKO2", HasAlpha: true},
 		},
 		{
-			ID:         blob.MustGenerateID(),
-			Rendition:  blob.RenditionDisplay,
+			ID:        blob.MustGenerateID(),
+			Rendition: blob.RenditionDisplay,
 			// Differs from the original's mime type: it must be preserved as its own.
 			MimeType:   "image/jpeg",
 			SizeBytes:  222,
@@ -406,3 +700,399 @@ func testStoreRenditions(t *testing.T, store blob.Store) {
 	// Attaching to an original that does not exist is ErrNotFound.
 	require.ErrorIs(t, store.AttachRenditions(ctx, blob.MustGenerateID(), refs), blob.ErrNotFound)
 }
+
+func testStoreCollection(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	// Marking or tombstoning an unknown blob reports not-found.
+	require.ErrorIs(t, store.MarkForCollection(ctx, blob.MustGenerateID(), time.Now()), blob.ErrNotFound)
+	_, err := store.Tombstone(ctx, blob.MustGenerateID(), blob.StatePending)
+	require.ErrorIs(t, err, blob.ErrNotFound)
+
+	// An original is indexed from reservation; a rendition never is.
+	first := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, first))
+	second := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, second))
+	rendition := pendingOriginal(t)
+	rendition.Rendition = blob.RenditionThumbnail
+	rendition.ParentID = first.ID
+	require.NoError(t, store.CreatePending(ctx, rendition))
+
+	// due reads the index, keeping only this test's blobs (in order), so records
+	// left by earlier tests do not interfere.
+	due := func(asOf time.Time, limit int) []*blobpb.BlobId {
+		ids, err := store.GetDueForCollection(ctx, asOf, limit)
+		require.NoError(t, err)
+		var ours []*blobpb.BlobId
+		for _, id := range ids {
+			for _, b := range []*blob.Blob{first, second, rendition} {
+				if bytes.Equal(id.Value, b.ID.Value) {
+					ours = append(ours, id)
+				}
+			}
+		}
+		return ours
+	}
+
+	requireSameIDs(t, due(time.Now(), 1000), first.ID, second.ID)
+	require.Empty(t, due(time.Now().Add(-time.Hour), 1000))
+
+	// Restamping moves a blob in the index, here into the future.
+	require.NoError(t, store.MarkForCollection(ctx, first.ID, time.Now().Add(time.Hour)))
+	requireSameIDs(t, due(time.Now(), 1000), second.ID)
+
+	// Results come back oldest first, under the limit.
+	require.NoError(t, store.MarkForCollection(ctx, second.ID, time.Unix(1, 0)))
+	require.NoError(t, store.MarkForCollection(ctx, first.ID, time.Unix(2, 0)))
+	requireSameIDs(t, due(time.Now(), 1), second.ID)
+	requireSameIDs(t, due(time.Now(), 2), second.ID, first.ID)
+
+	// A tombstone lands only on a blob still in the expected state.
+	require.NoError(t, store.MarkForFinalization(ctx, second.ID, blob.ContentKindImage, time.Now()))
+	tombstoned, err := store.Tombstone(ctx, second.ID, blob.StateRejected)
+	require.NoError(t, err)
+	require.False(t, tombstoned)
+	tombstoned, err = store.Tombstone(ctx, second.ID, blob.StatePending)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+	got, err := store.GetByID(ctx, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateDeleted, got.State)
+
+	// It leaves both indexes, stays out of them, and is terminal.
+	requireSameIDs(t, due(time.Now(), 1000), first.ID)
+	tasks, err := store.GetDueForFinalization(ctx, blob.ContentKindImage, time.Now(), 1000)
+	require.NoError(t, err)
+	for _, task := range tasks {
+		require.NotEqual(t, second.ID.Value, task.ID.Value)
+	}
+	require.NoError(t, store.MarkForCollection(ctx, second.ID, time.Now()))
+	require.NoError(t, store.MarkForFinalization(ctx, second.ID, blob.ContentKindImage, time.Now()))
+	requireSameIDs(t, due(time.Now(), 1000), first.ID)
+	tasks, err = store.GetDueForFinalization(ctx, blob.ContentKindImage, time.Now(), 1000)
+	require.NoError(t, err)
+	for _, task := range tasks {
+		require.NotEqual(t, second.ID.Value, task.ID.Value)
+	}
+	advanced, err := store.Advance(ctx, second.ID, blob.StateUploaded, nil)
+	require.NoError(t, err)
+	require.False(t, advanced)
+	advanced, err = store.Reject(ctx, second.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonCorrupt})
+	require.NoError(t, err)
+	require.False(t, advanced)
+	tombstoned, err = store.Tombstone(ctx, second.ID, blob.StateDeleted)
+	require.NoError(t, err)
+	require.False(t, tombstoned)
+
+	// A READY original's tombstone drops its rendition manifest.
+	for _, to := range []blob.State{blob.StateUploaded, blob.StateInspected, blob.StatePromoted, blob.StateReady} {
+		_, err = store.Advance(ctx, first.ID, to, nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.AttachRenditions(ctx, first.ID, []blob.RenditionRef{{
+		ID:         rendition.ID,
+		Rendition:  blob.RenditionThumbnail,
+		MimeType:   "image/png",
+		SizeBytes:  1,
+		StorageKey: rendition.StorageKey,
+		Image:      &blob.ImageMetadata{Width: 1, Height: 1},
+	}}))
+	tombstoned, err = store.Tombstone(ctx, first.ID, blob.StateReady)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+	got, err = store.GetByID(ctx, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateDeleted, got.State)
+	require.Empty(t, got.Renditions)
+	require.Empty(t, due(time.Now(), 1000))
+}
+
+func testStoreUsage(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// An owner with nothing stored has zero usage.
+	owner := model.MustGenerateUserID()
+	usage, err := store.GetUsage(ctx, owner, now)
+	require.NoError(t, err)
+	require.Zero(t, *usage)
+
+	// Reserving an original charges both its owner's total and the day's
+	// reservations.
+	original := pendingOriginal(t)
+	original.Owner = owner
+	original.SizeBytes = 1000
+	require.NoError(t, store.CreatePending(ctx, original))
+	requireUsage(t, store, owner, now, 1000, 1000)
+
+	// A replayed create charges nothing.
+	require.ErrorIs(t, store.CreatePending(ctx, original), blob.ErrExists)
+	requireUsage(t, store, owner, now, 1000, 1000)
+
+	// A rendition counts towards the stored total, but is not an upload.
+	rendition := pendingOriginal(t)
+	rendition.Owner = owner
+	rendition.Rendition = blob.RenditionThumbnail
+	rendition.ParentID = original.ID
+	rendition.SizeBytes = 200
+	require.NoError(t, store.CreatePending(ctx, rendition))
+	requireUsage(t, store, owner, now, 1200, 1000)
+
+	// Daily usage is bucketed by UTC day; other owners are unaffected.
+	usage, err = store.GetUsage(ctx, owner, now.Add(-48*time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 1200, usage.StoredBytes)
+	require.Zero(t, usage.DailyBytes)
+	requireUsage(t, store, model.MustGenerateUserID(), now, 0, 0)
+
+	// Tombstoning refunds the stored total — once — but not the day's
+	// reservations.
+	_, err = store.Advance(ctx, rendition.ID, blob.StateReady, nil)
+	require.NoError(t, err)
+	tombstoned, err := store.Tombstone(ctx, rendition.ID, blob.StateReady)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+	requireUsage(t, store, owner, now, 1000, 1000)
+	tombstoned, err = store.Tombstone(ctx, rendition.ID, blob.StateReady)
+	require.NoError(t, err)
+	require.False(t, tombstoned)
+	requireUsage(t, store, owner, now, 1000, 1000)
+
+	// A tombstone that does not land refunds nothing.
+	tombstoned, err = store.Tombstone(ctx, original.ID, blob.StateRejected)
+	require.NoError(t, err)
+	require.False(t, tombstoned)
+	requireUsage(t, store, owner, now, 1000, 1000)
+
+	tombstoned, err = store.Tombstone(ctx, original.ID, blob.StatePending)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+	requireUsage(t, store, owner, now, 0, 1000)
+}
+
+func testStoreContentHash(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	// Hashes unique to this run, so records left by earlier tests never match.
+	hashed, otherHashed := sha256.Sum256(blob.MustGenerateID().Value), sha256.Sum256(blob.MustGenerateID().Value)
+	hash, other := hashed[:], otherHashed[:]
+
+	require.ErrorIs(t, store.SetContentHash(ctx, blob.MustGenerateID(), hash), blob.ErrNotFound)
+	matches, err := store.GetByContentHash(ctx, hash, 10)
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	first := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, first))
+	second := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, second))
+	third := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, third))
+
+	require.NoError(t, store.SetContentHash(ctx, first.ID, hash))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, store.SetContentHash(ctx, second.ID, hash))
+	require.NoError(t, store.SetContentHash(ctx, third.ID, other))
+
+	got, err := store.GetByID(ctx, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, hash, got.ContentHash)
+
+	// Matches come back oldest first, under the limit; a replayed recording
+	// does not move a blob.
+	require.NoError(t, store.SetContentHash(ctx, first.ID, hash))
+	matches, err = store.GetByContentHash(ctx, hash, 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	require.Equal(t, first.ID.Value, matches[0].ID.Value)
+	require.Equal(t, second.ID.Value, matches[1].ID.Value)
+	require.Equal(t, hash, matches[0].ContentHash)
+	matches, err = store.GetByContentHash(ctx, hash, 1)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, first.ID.Value, matches[0].ID.Value)
+
+	// A collected blob leaves the index, and is not re-indexed.
+	tombstoned, err := store.Tombstone(ctx, first.ID, blob.StatePending)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+	require.NoError(t, store.SetContentHash(ctx, first.ID, hash))
+	got, err = store.GetByID(ctx, first.ID)
+	require.NoError(t, err)
+	require.Nil(t, got.ContentHash)
+	matches, err = store.GetByContentHash(ctx, hash, 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, second.ID.Value, matches[0].ID.Value)
+
+	// A blob rejected with a verdict on its content stays in the index once
+	// collected, its rejection intact; any other rejection leaves it.
+	_, err = store.Reject(ctx, second.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonModeration})
+	require.NoError(t, err)
+	_, err = store.Reject(ctx, third.ID, &blob.RejectionMetadata{Reason: blob.RejectionReasonCorrupt})
+	require.NoError(t, err)
+	for _, id := range []*blobpb.BlobId{second.ID, third.ID} {
+		tombstoned, err = store.Tombstone(ctx, id, blob.StateRejected)
+		require.NoError(t, err)
+		require.True(t, tombstoned)
+	}
+	matches, err = store.GetByContentHash(ctx, hash, 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, second.ID.Value, matches[0].ID.Value)
+	require.Equal(t, blob.StateDeleted, matches[0].State)
+	require.Equal(t, hash, matches[0].ContentHash)
+	require.Equal(t, blob.RejectionReasonModeration, matches[0].Rejection.Reason)
+	matches, err = store.GetByContentHash(ctx, other, 10)
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+func testStoreScanReadyOriginals(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	var ready []*blob.Blob
+	for range 5 {
+		original := pendingOriginal(t)
+		require.NoError(t, store.CreatePending(ctx, original))
+		_, err := store.Advance(ctx, original.ID, blob.StateReady, nil)
+		require.NoError(t, err)
+		ready = append(ready, original)
+	}
+	require.NoError(t, store.AttachRenditions(ctx, ready[0].ID, []blob.RenditionRef{{
+		ID:         blob.MustGenerateID(),
+		Rendition:  blob.RenditionThumbnail,
+		MimeType:   "image/png",
+		StorageKey: "images/x/thumbnail_160x90.png",
+	}}))
+
+	pending := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, pending))
+
+	rendition := pendingOriginal(t)
+	rendition.Rendition = blob.RenditionThumbnail
+	rendition.ParentID = ready[0].ID
+	require.NoError(t, store.CreatePending(ctx, rendition))
+	_, err := store.Advance(ctx, rendition.ID, blob.StateReady, nil)
+	require.NoError(t, err)
+
+	// Page through the whole scan with a small page. The store may hold records
+	// from elsewhere, so only ours are checked.
+	seen := make(map[string]*blob.Blob)
+	var cursor string
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 1000, "scan never completed")
+
+		var page []*blob.Blob
+		page, cursor, err = store.ScanReadyOriginals(ctx, cursor, 2)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 2)
+		for _, record := range page {
+			require.Equal(t, blob.StateReady, record.State)
+			require.Nil(t, record.ParentID)
+			require.NotContains(t, seen, blob.IDString(record.ID), "a record is visited once")
+			seen[blob.IDString(record.ID)] = record
+		}
+		if cursor == "" {
+			break
+		}
+	}
+
+	for _, original := range ready {
+		require.Contains(t, seen, blob.IDString(original.ID))
+	}
+	require.NotContains(t, seen, blob.IDString(pending.ID))
+	require.NotContains(t, seen, blob.IDString(rendition.ID))
+
+	// The manifest comes back with the record.
+	require.Len(t, seen[blob.IDString(ready[0].ID)].Renditions, 1)
+}
+
+func testStoreGetOriginalsByOwner(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+
+	owner := model.MustGenerateUserID()
+
+	page, cursor, err := store.GetOriginalsByOwner(ctx, owner, "", 10)
+	require.NoError(t, err)
+	require.Empty(t, page)
+	require.Empty(t, cursor)
+
+	var owned []*blob.Blob
+	for range 5 {
+		original := pendingOriginal(t)
+		original.Owner = owner
+		require.NoError(t, store.CreatePending(ctx, original))
+		owned = append(owned, original)
+	}
+	_, err = store.Advance(ctx, owned[0].ID, blob.StateReady, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.AttachRenditions(ctx, owned[0].ID, []blob.RenditionRef{{
+		ID:         blob.MustGenerateID(),
+		Rendition:  blob.RenditionThumbnail,
+		MimeType:   "image/png",
+		StorageKey: "images/x/thumbnail_160x90.png",
+	}}))
+
+	rendition := pendingOriginal(t)
+	rendition.Owner = owner
+	rendition.Rendition = blob.RenditionThumbnail
+	rendition.ParentID = owned[0].ID
+	require.NoError(t, store.CreatePending(ctx, rendition))
+
+	collected := pendingOriginal(t)
+	collected.Owner = owner
+	require.NoError(t, store.CreatePending(ctx, collected))
+	tombstoned, err := store.Tombstone(ctx, collected.ID, blob.StatePending)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+
+	other := pendingOriginal(t)
+	require.NoError(t, store.CreatePending(ctx, other))
+
+	seen := make(map[string]*blob.Blob)
+	cursor = ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 1000, "listing never completed")
+
+		page, cursor, err = store.GetOriginalsByOwner(ctx, owner, cursor, 2)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 2)
+		for _, record := range page {
+			require.Equal(t, owner.Value, record.Owner.Value)
+			require.Nil(t, record.ParentID)
+			require.NotContains(t, seen, blob.IDString(record.ID), "a record is visited once")
+			seen[blob.IDString(record.ID)] = record
+		}
+		if cursor == "" {
+			break
+		}
+	}
+
+	require.Len(t, seen, len(owned))
+	for _, original := range owned {
+		require.Contains(t, seen, blob.IDString(original.ID))
+	}
+	require.Equal(t, blob.StateReady, seen[blob.IDString(owned[0].ID)].State)
+	require.Equal(t, blob.StatePending, seen[blob.IDString(owned[1].ID)].State)
+
+	// The manifest comes back with the record.
+	require.Len(t, seen[blob.IDString(owned[0].ID)].Renditions, 1)
+}
+
+func requireUsage(t *testing.T, store blob.Store, owner *commonpb.UserId, day time.Time, stored, daily uint64) {
+	t.Helper()
+	usage, err := store.GetUsage(context.Background(), owner, day)
+	require.NoError(t, err)
+	require.Equal(t, stored, usage.StoredBytes, "stored bytes")
+	require.Equal(t, daily, usage.DailyBytes, "daily bytes")
+}
+
+func requireSameIDs(t *testing.T, actual []*blobpb.BlobId, expected ...*blobpb.BlobId) {
+	t.Helper()
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.Equal(t, expected[i].Value, actual[i].Value)
+	}
+}
@@ -1,18 +1,35 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
 	moderationpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/moderation/v1"
 
 	"github.com/code-payments/flipcash2-server/blob"
 	"github.com/code-payments/flipcash2-server/model"
 	"github.com/code-payments/flipcash2-server/moderation"
+	moderation_memory "github.com/code-payments/flipcash2-server/moderation/memory"
 )
 
 // putObjectFunc stores bytes directly into the upload store under a key, as if
@@ -35,8 +52,21 @@ func RunWorkerTests(
 		testWorkerRejectsFlaggedBlob,
 		testWorkerRetriesUntilBytesArrive,
 		testWorkerExhaustedAttemptsRejectAsInternal,
+		testWorkerDeadLettersExhaustedBlob,
 		testWorkerSkipsClaimedWork,
 		testWorkerProcessesBatchAcrossBlobs,
+		testWorkerFinalizesAnimatedImage,
+		testWorkerSanitizesPrivacyMetadata,
+		testWorkerFinalizesVideo,
+		testWorkerRejectsFlaggedVideoFrame,
+		testWorkerQueuesAreIsolatedByKind,
+		testWorkerDeduplicatesReadyUpload,
+		testWorkerDeduplicatesRejectedUpload,
+		testWorkerAuditsModerationRejections,
+		testWorkerFinalizesReinstatedBlob,
+		testWorkerRederivesCollectedDuplicateRenditions,
+		testWorkerRejectsBlocklistedUpload,
+		testWorkerDerivesAVIFRenditions,
 	} {
 		tf(t, blobs, storage, putObject)
 		teardown()
@@ -63,11 +93,29 @@ func newWorkerHarness(t *testing.T, blobs blob.Store, storage blob.ObjectStorage
 	}
 }
 
-// stageUpload reserves a pending original for the given bytes and, when
+// newVideoWorkerHarness is newWorkerHarness for the video queue, with frames
+// decoded by the given extractor.
+func newVideoWorkerHarness(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc, moderator moderation.Client, frames blob.VideoFrameExtractor) *workerHarness {
+	log := zaptest.NewLogger(t)
+	finalizer := blob.NewFinalizer(log, blobs, storage, moderator, blob.WithVideoFrameExtractor(frames))
+	return &workerHarness{
+		worker:    blob.NewWorker(log, blobs, finalizer, blob.ContentKindVideo),
+		blobs:     blobs,
+		storage:   storage,
+		putObject: putObject,
+	}
+}
+
+// stageUpload reserves a pending PNG original for the given bytes and, when
 // uploaded is set, stores them as the client's finished upload.
 func (h *workerHarness) stageUpload(t *testing.T, data []byte, uploaded bool) *blob.Blob {
+	return h.stageUploadAs(t, "image/png", data, uploaded)
+}
+
+// stageUploadAs is stageUpload for an original of the given MIME type.
+func (h *workerHarness) stageUploadAs(t *testing.T, mimeType string, data []byte, uploaded bool) *blob.Blob {
 	id := blob.MustGenerateID()
-	key, err := blob.StorageKey(id, "image/png")
+	key, err := blob.StorageKey(id, mimeType)
 	require.NoError(t, err)
 
 	record := &blob.Blob{
@@ -76,7 +124,7 @@ func (h *workerHarness) stageUpload(t *testing.T, data []byte, uploaded bool) *b
 		Owner:      model.MustGenerateUserID(),
 		State:      blob.StatePending,
 		StorageKey: key,
-		MimeType:   "image/png",
+		MimeType:   mimeType,
 		SizeBytes:  uint64(len(data)),
 	}
 	require.NoError(t, h.blobs.CreatePending(context.Background(), record))
@@ -189,6 +237,58 @@ func testWorkerExhaustedAttemptsRejectAsInternal(t *testing.T, blobs blob.Store,
 	h.process(t, 0)
 }
 
+func testWorkerDeadLettersExhaustedBlob(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	opts := []blob.WorkerOption{
+		blob.WithWorkerBackoff(time.Nanosecond, time.Nanosecond),
+		blob.WithWorkerMaxAttempts(2),
+	}
+	h := newWorkerHarness(t, blobs, storage, putObject, nil, append(opts, blob.WithWorkerDeadLetter())...)
+	data := makePNG(t, 40, 30)
+	record := h.stageUpload(t, data, false) // bytes not there yet
+	h.mark(t, record)
+
+	// The attempt that exhausts the budget parks the blob with its error rather
+	// than rejecting it, and it is no longer polled.
+	for range 2 {
+		h.process(t, 1)
+		require.Equal(t, blob.StatePending, h.state(t, record).State)
+	}
+	h.process(t, 0)
+
+	letters, err := blobs.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, record.ID.Value, letters[0].ID.Value)
+	require.EqualValues(t, 2, letters[0].Attempts)
+	require.NotEmpty(t, letters[0].LastError)
+	require.False(t, letters[0].DeadLetteredAt.Before(letters[0].EnqueuedAt))
+
+	// Once the cause is fixed, re-queuing it finalizes it.
+	h.putObject(record.StorageKey, data)
+	h.mark(t, record)
+	h.process(t, 1)
+	require.Equal(t, blob.StateReady, h.state(t, record).State)
+	letters, err = blobs.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Empty(t, letters)
+
+	// A task already exhausted when it is polled is parked too.
+	exhausted := h.stageUpload(t, data, false)
+	h.mark(t, exhausted)
+	rejecting := newWorkerHarness(t, blobs, storage, putObject, nil, opts...)
+	for range 2 {
+		rejecting.process(t, 1)
+	}
+	h.process(t, 1)
+	require.Equal(t, blob.StatePending, h.state(t, exhausted).State)
+	letters, err = blobs.GetDeadLetters(ctx, blob.ContentKindImage, 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.Equal(t, exhausted.ID.Value, letters[0].ID.Value)
+	require.EqualValues(t, 2, letters[0].Attempts)
+}
+
 func testWorkerSkipsClaimedWork(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
 	h := newWorkerHarness(t, blobs, storage, putObject, nil)
 	record := h.stageUpload(t, makePNG(t, 40, 30), true)
@@ -218,3 +318,645 @@ func testWorkerProcessesBatchAcrossBlobs(t *testing.T, blobs blob.Store, storage
 		require.Equal(t, blob.StateReady, h.state(t, record).State)
 	}
 }
+
+func testWorkerFinalizesAnimatedImage(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	moderator := &countingModerator{}
+	h := newWorkerHarness(t, blobs, storage, putObject, moderator)
+	record := h.stageUploadAs(t, "image/gif", makeAnimatedGIF(t, 4), true)
+	h.mark(t, record)
+
+	h.process(t, 1)
+
+	got := h.state(t, record)
+	require.Equal(t, blob.StateReady, got.State)
+	require.NotNil(t, got.Image)
+	require.True(t, got.Image.Animated)
+	require.EqualValues(t, 4, got.Image.FrameCount)
+
+	// Every frame of a short animation is moderated, not just the first.
+	require.EqualValues(t, 4, moderator.images.Load())
+
+	// Only static thumbnails are derived; the animated original is the display.
+	require.NotEmpty(t, got.Renditions)
+	for _, ref := range got.Renditions {
+		require.Equal(t, blob.RenditionThumbnail, ref.Rendition)
+		require.False(t, ref.Image.Animated)
+	}
+}
+
+func testWorkerSanitizesPrivacyMetadata(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	log := zaptest.NewLogger(t)
+	origins := &originRecorder{ObjectStorage: storage}
+	finalizer := blob.NewFinalizer(log, blobs, origins, &fakeModerator{}, blob.WithSanitizeMetadata())
+	h := &workerHarness{
+		worker:    blob.NewWorker(log, blobs, finalizer, blob.ContentKindImage),
+		blobs:     blobs,
+		storage:   origins,
+		putObject: putObject,
+	}
+
+	data := makePNGWithExif(t, 60, 40)
+	record := h.stageUpload(t, data, true)
+	h.mark(t, record)
+
+	h.process(t, 1)
+
+	got := h.state(t, record)
+	require.Equal(t, blob.StateReady, got.State)
+	require.NotNil(t, got.Image)
+	require.EqualValues(t, 60, got.Image.Width)
+	require.EqualValues(t, 40, got.Image.Height)
+
+	// The stripped bytes, not the upload, were promoted, and their size is what
+	// is served; the declared size is left as pinned.
+	sanitized := origins.get(record.StorageKey)
+	require.NotNil(t, sanitized)
+	require.NotContains(t, string(sanitized), "eXIf")
+	require.Len(t, data, int(got.SizeBytes))
+	require.EqualValues(t, len(sanitized), got.ServedSizeBytes())
+	inspection, err := blob.InspectImage(sanitized)
+	require.NoError(t, err)
+	require.EqualValues(t, 60, inspection.Metadata.Width)
+}
+
+func testWorkerFinalizesVideo(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	frames := &fakeFrameExtractor{width: 640, height: 360}
+	h := newVideoWorkerHarness(t, blobs, storage, putObject, &fakeModerator{}, frames)
+	record := h.stageUploadAs(t, "video/mp4", makeMP4(t, 640, 360, 20*time.Second), true)
+	h.mark(t, record)
+
+	h.process(t, 1)
+
+	got := h.state(t, record)
+	require.Equal(t, blob.StateReady, got.State)
+	require.Nil(t, got.Image)
+	require.NotNil(t, got.Video)
+	require.EqualValues(t, 640, got.Video.Width)
+	require.EqualValues(t, 360, got.Video.Height)
+	require.Equal(t, 20*time.Second, got.Video.Duration)
+	require.Equal(t, "h264", got.Video.Codec)
+	require.NotEmpty(t, got.Video.Blurhash)
+
+	// The poster and every moderation sample were extracted in one pass.
+	require.Len(t, frames.offsets, 9)
+	require.Equal(t, time.Second, frames.offsets[0])
+
+	// The poster ladder is stored alongside the video, as images.
+	require.NotEmpty(t, got.Renditions)
+	for _, ref := range got.Renditions {
+		require.True(t, strings.HasPrefix(ref.StorageKey, "videos/"), ref.StorageKey)
+		require.True(t, blob.SupportedImageMimeTypes[ref.MimeType], ref.MimeType)
+		require.NotNil(t, ref.Image)
+	}
+
+	h.process(t, 0)
+}
+
+func testWorkerRejectsFlaggedVideoFrame(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	h := newVideoWorkerHarness(t, blobs, storage, putObject, &fakeModerator{flagged: true, categories: []string{"violence"}}, &fakeFrameExtractor{width: 320, height: 240})
+	record := h.stageUploadAs(t, "video/mp4", makeMP4(t, 320, 240, 5*time.Second), true)
+	h.mark(t, record)
+
+	h.process(t, 1)
+
+	got := h.state(t, record)
+	require.Equal(t, blob.StateRejected, got.State)
+	require.NotNil(t, got.Rejection)
+	require.Equal(t, blob.RejectionReasonModeration, got.Rejection.Reason)
+	require.Empty(t, got.Renditions)
+}
+
+func testWorkerQueuesAreIsolatedByKind(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	images := newWorkerHarness(t, blobs, storage, putObject, nil)
+	videos := newVideoWorkerHarness(t, blobs, storage, putObject, nil, &fakeFrameExtractor{width: 320, height: 240})
+
+	video := videos.stageUploadAs(t, "video/mp4", makeMP4(t, 320, 240, 5*time.Second), true)
+	videos.mark(t, video)
+
+	// The image worker never sees the video's task; the video worker does.
+	images.process(t, 0)
+	require.Equal(t, blob.StatePending, images.state(t, video).State)
+	videos.process(t, 1)
+	require.Equal(t, blob.StateReady, videos.state(t, video).State)
+}
+
+// fakeFrameExtractor decodes every requested offset to a solid frame of a fixed
+// size, recording the offsets it was asked for.
+func testWorkerDeduplicatesReadyUpload(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	moderator := &countingModerator{}
+	recorder := &originRecorder{ObjectStorage: storage}
+	h := newWorkerHarness(t, blobs, recorder, putObject, moderator)
+	data := makePNG(t, 400, 300)
+
+	first := h.stageUpload(t, data, true)
+	h.mark(t, first)
+	h.process(t, 1)
+	original := h.state(t, first)
+	require.Equal(t, blob.StateReady, original.State)
+	require.Len(t, original.ContentHash, 32)
+	require.EqualValues(t, 1, moderator.images.Load())
+
+	// The same bytes again skip moderation, and their renditions are copies of
+	// the first upload's rather than derived afresh.
+	second := h.stageUpload(t, data, true)
+	h.mark(t, second)
+	h.process(t, 1)
+	duplicate := h.state(t, second)
+	require.Equal(t, blob.StateReady, duplicate.State)
+	require.Equal(t, original.ContentHash, duplicate.ContentHash)
+	require.EqualValues(t, 1, moderator.images.Load())
+	require.Equal(t, original.Image, duplicate.Image)
+
+	require.Len(t, duplicate.Renditions, len(original.Renditions))
+	for i, ref := range duplicate.Renditions {
+		source := original.Renditions[i]
+		require.NotEqual(t, source.ID.Value, ref.ID.Value)
+		require.True(t, strings.HasPrefix(ref.StorageKey, itemPrefix(duplicate)), ref.StorageKey)
+		require.Nil(t, recorder.get(ref.StorageKey), "a copied rendition is not derived")
+		require.Equal(t, source.SizeBytes, ref.SizeBytes)
+		require.Equal(t, source.Image, ref.Image)
+
+		child, err := blobs.GetByID(context.Background(), ref.ID)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateReady, child.State)
+		require.Equal(t, duplicate.ID.Value, child.ParentID.Value)
+		require.Equal(t, duplicate.Owner.Value, child.Owner.Value)
+	}
+}
+
+func testWorkerDeduplicatesRejectedUpload(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	data := makePNG(t, 50, 50)
+
+	flagging := newWorkerHarness(t, blobs, storage, putObject, &fakeModerator{flagged: true, categories: []string{"general_nsfw"}})
+	first := flagging.stageUpload(t, data, true)
+	flagging.mark(t, first)
+	flagging.process(t, 1)
+	require.Equal(t, blob.StateRejected, flagging.state(t, first).State)
+
+	// The verdict on the bytes stands, even before a moderator that would pass
+	// them — which is never asked.
+	moderator := &countingModerator{}
+	h := newWorkerHarness(t, blobs, storage, putObject, moderator)
+	second := h.stageUpload(t, data, true)
+	h.mark(t, second)
+	h.process(t, 1)
+	got := h.state(t, second)
+	require.Equal(t, blob.StateRejected, got.State)
+	require.Equal(t, blob.RejectionReasonModeration, got.Rejection.Reason)
+	require.Equal(t, moderationpb.FlaggedCategory_NSFW, got.Rejection.FlaggedCategory)
+	require.Zero(t, moderator.images.Load())
+
+	// A rejection that is not a verdict on the bytes does not carry over.
+	other := makePNG(t, 51, 51)
+	hash := sha256.Sum256(other)
+	exhausted := h.stageUpload(t, other, true)
+	require.NoError(t, blobs.SetContentHash(context.Background(), exhausted.ID, hash[:]))
+	require.NoError(t, blob.NewFinalizer(zaptest.NewLogger(t), blobs, storage, nil).Fail(context.Background(), exhausted.ID))
+	require.Equal(t, blob.RejectionReasonInternal, h.state(t, exhausted).Rejection.Reason)
+	third := h.stageUpload(t, other, true)
+	h.mark(t, third)
+	h.process(t, 1)
+	require.Equal(t, blob.StateReady, h.state(t, third).State)
+	require.EqualValues(t, 1, moderator.images.Load())
+}
+
+func testWorkerAuditsModerationRejections(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	audit := moderation_memory.NewInMemory()
+	log := zaptest.NewLogger(t)
+	finalizer := blob.NewFinalizer(log, blobs, storage, &fakeModerator{flagged: true, categories: []string{"general_nsfw"}}, blob.WithModerationAudit(audit))
+	h := &workerHarness{
+		worker:    blob.NewWorker(log, blobs, finalizer, blob.ContentKindImage),
+		blobs:     blobs,
+		storage:   storage,
+		putObject: putObject,
+	}
+
+	data := makePNG(t, 50, 50)
+	hash := sha256.Sum256(data)
+	first := h.stageUpload(t, data, true)
+	h.mark(t, first)
+	h.process(t, 1)
+	require.Equal(t, blob.StateRejected, h.state(t, first).State)
+
+	record, err := audit.GetAuditRecordByBlob(ctx, first.ID)
+	require.NoError(t, err)
+	require.Equal(t, first.Owner.Value, record.UserID.Value)
+	require.Equal(t, moderation.MethodImage, record.Method)
+	require.Equal(t, "*tests.fakeModerator", record.Classifier)
+	require.Equal(t, hex.EncodeToString(hash[:]), record.ContentHash)
+	require.Equal(t, []string{"general_nsfw"}, record.FlaggedCategories)
+	require.Equal(t, map[string]float64{"general_nsfw": 1}, record.CategoryScores)
+	require.Equal(t, moderation.DecisionRejected, record.Decision)
+
+	// The upload bytes are kept for an appeal to reinstate.
+	uploaded, err := storage.GetUploaded(ctx, first.StorageKey)
+	require.NoError(t, err)
+	require.Equal(t, data, uploaded)
+
+	// A duplicate rejected on the earlier verdict is recorded too, carrying the
+	// earlier flag's categories.
+	second := h.stageUpload(t, data, true)
+	h.mark(t, second)
+	h.process(t, 1)
+	require.Equal(t, blob.StateRejected, h.state(t, second).State)
+	record, err = audit.GetAuditRecordByBlob(ctx, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, second.Owner.Value, record.UserID.Value)
+	require.Equal(t, "duplicate", record.Classifier)
+	require.Equal(t, []string{"general_nsfw"}, record.FlaggedCategories)
+
+	// Other rejections are not moderation's, and are neither recorded nor kept.
+	corrupt := h.stageUpload(t, []byte("not an image"), true)
+	h.mark(t, corrupt)
+	h.process(t, 1)
+	require.Equal(t, blob.RejectionReasonCorrupt, h.state(t, corrupt).Rejection.Reason)
+	_, err = audit.GetAuditRecordByBlob(ctx, corrupt.ID)
+	require.ErrorIs(t, err, moderation.ErrAuditRecordNotFound)
+	_, err = storage.GetUploaded(ctx, corrupt.StorageKey)
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+}
+
+func testWorkerFinalizesReinstatedBlob(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	moderator := &countingModerator{fakeModerator: fakeModerator{flagged: true, categories: []string{"general_nsfw"}}}
+	log := zaptest.NewLogger(t)
+	finalizer := blob.NewFinalizer(log, blobs, storage, moderator, blob.WithModerationAudit(moderation_memory.NewInMemory()))
+	h := &workerHarness{
+		worker:    blob.NewWorker(log, blobs, finalizer, blob.ContentKindImage),
+		blobs:     blobs,
+		storage:   storage,
+		putObject: putObject,
+	}
+
+	// Two uploads of the same bytes: the first is flagged, the second rejected
+	// on its verdict.
+	data := makePNG(t, 50, 50)
+	first := h.stageUpload(t, data, true)
+	h.mark(t, first)
+	h.process(t, 1)
+	second := h.stageUpload(t, data, true)
+	h.mark(t, second)
+	h.process(t, 1)
+	require.Equal(t, blob.StateRejected, h.state(t, second).State)
+	require.EqualValues(t, 1, moderator.images.Load())
+
+	// Overturning the second's rejection finalizes it from its kept bytes without
+	// moderation, and without deferring to the first's standing rejection.
+	reinstated, err := blobs.Reinstate(ctx, second.ID)
+	require.NoError(t, err)
+	require.True(t, reinstated)
+	h.mark(t, second)
+	h.process(t, 1)
+	got := h.state(t, second)
+	require.Equal(t, blob.StateReady, got.State)
+	require.Nil(t, got.Rejection)
+	require.NotEmpty(t, got.Renditions)
+	require.EqualValues(t, 1, moderator.images.Load())
+	require.Equal(t, blob.StateRejected, h.state(t, first).State)
+}
+
+func testWorkerRederivesCollectedDuplicateRenditions(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	recorder := &originRecorder{ObjectStorage: storage}
+	h := newWorkerHarness(t, blobs, recorder, putObject, nil)
+	data := makePNG(t, 400, 300)
+
+	first := h.stageUpload(t, data, true)
+	h.mark(t, first)
+	h.process(t, 1)
+
+	// The first upload's objects are collected between the match and the copy:
+	// its record still matches, but its renditions' bytes are gone.
+	require.NoError(t, storage.DeletePrefix(context.Background(), itemPrefix(first)))
+
+	second := h.stageUpload(t, data, true)
+	h.mark(t, second)
+	h.process(t, 1)
+	duplicate := h.state(t, second)
+	require.Equal(t, blob.StateReady, duplicate.State)
+	require.NotEmpty(t, duplicate.Renditions)
+	for _, ref := range duplicate.Renditions {
+		require.NotNil(t, recorder.get(ref.StorageKey), "a rendition whose source is gone is derived")
+	}
+}
+
+type fakeFrameExtractor struct {
+	width, height int
+
+	mu      sync.Mutex
+	offsets []time.Duration
+}
+
+func (e *fakeFrameExtractor) ExtractFrames(_ context.Context, _ []byte, offsets []time.Duration) ([]image.Image, error) {
+	e.mu.Lock()
+	e.offsets = append([]time.Duration(nil), offsets...)
+	e.mu.Unlock()
+
+	frames := make([]image.Image, len(offsets))
+	for i := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, e.width, e.height))
+		draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: uint8(i * 30), G: 90, B: 160, A: 255}}, image.Point{}, draw.Src)
+		frames[i] = img
+	}
+	return frames, nil
+}
+
+// makeMP4 returns a minimal H.264 MP4 container of the given presentation size
+// and duration. It carries only the structure blob.InspectVideo reads; the
+// frames themselves come from fakeFrameExtractor.
+func makeMP4(t *testing.T, width, height uint32, duration time.Duration) []byte {
+	box := func(typ string, parts ...[]byte) []byte {
+		payload := bytes.Join(parts, nil)
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+		return append(append(out, typ...), payload...)
+	}
+
+	mvhd := make([]byte, 12)
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 1000) // timescale
+	mvhd = binary.BigEndian.AppendUint32(mvhd, uint32(duration/time.Millisecond))
+	mvhd = append(mvhd, make([]byte, 80)...)
+
+	tkhd := make([]byte, 40)
+	for _, v := range []uint32{1 << 16, 0, 0, 0, 1 << 16, 0, 0, 0, 1 << 30} { // identity matrix
+		tkhd = binary.BigEndian.AppendUint32(tkhd, v)
+	}
+	tkhd = binary.BigEndian.AppendUint32(tkhd, width<<16)
+	tkhd = binary.BigEndian.AppendUint32(tkhd, height<<16)
+
+	hdlr := append(make([]byte, 8), "vide"...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	stsd := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+	stsd = append(stsd, box("avc1", make([]byte, 8))...)
+
+	data := bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), make([]byte, 4)),
+		box("moov",
+			box("mvhd", mvhd),
+			box("trak",
+				box("tkhd", tkhd),
+				box("mdia",
+					box("hdlr", hdlr),
+					box("minf", box("stbl", box("stsd", stsd))),
+				),
+			),
+		),
+		box("mdat", make([]byte, 64)),
+	}, nil)
+
+	_, err := blob.InspectVideo(data)
+	require.NoError(t, err)
+	return data
+}
+
+// originRecorder is object storage that records the bytes written to the origin
+// store with PutOrigin, which the suite cannot otherwise read back.
+type originRecorder struct {
+	blob.ObjectStorage
+
+	mu      sync.Mutex
+	written map[string][]byte
+}
+
+func (r *originRecorder) PutOrigin(ctx context.Context, key, mimeType string, data []byte) error {
+	r.mu.Lock()
+	if r.written == nil {
+		r.written = make(map[string][]byte)
+	}
+	r.written[key] = append([]byte(nil), data...)
+	r.mu.Unlock()
+	return r.ObjectStorage.PutOrigin(ctx, key, mimeType, data)
+}
+
+func (r *originRecorder) get(key string) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.written[key]
+}
+
+func testWorkerRejectsBlocklistedUpload(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	log := zaptest.NewLogger(t)
+	moderator := &countingModerator{}
+	blocklist := &fakeBlocklist{}
+	finalizer := blob.NewFinalizer(log, blobs, storage, moderator, blob.WithHashBlocklist(blocklist))
+	h := &workerHarness{
+		worker:    blob.NewWorker(log, blobs, finalizer, blob.ContentKindImage),
+		blobs:     blobs,
+		storage:   storage,
+		putObject: putObject,
+	}
+
+	// Finalization records the perceptual hash staff block by.
+	data := makePhotoPNG(t, 320, 240)
+	removed := h.stageUpload(t, data, true)
+	h.mark(t, removed)
+	h.process(t, 1)
+	got := h.state(t, removed)
+	require.Equal(t, blob.StateReady, got.State)
+	require.NotNil(t, got.PerceptualHash)
+	require.EqualValues(t, 1, moderator.images.Load())
+	require.NoError(t, blocklist.Add(context.Background(), &blob.BlockedHash{Hash: *got.PerceptualHash, CreatedAt: time.Now()}))
+
+	// A re-encode of the removed image is different bytes that look the same: it
+	// is rejected, without reaching the moderator.
+	reencoded := h.stageUploadAs(t, "image/jpeg", reencodeJPEG(t, data), true)
+	h.mark(t, reencoded)
+	h.process(t, 1)
+	got = h.state(t, reencoded)
+	require.Equal(t, blob.StateRejected, got.State)
+	require.Equal(t, blob.RejectionReasonBlocklisted, got.Rejection.Reason)
+	require.Equal(t, blobpb.RejectionReason_REJECTION_REASON_MODERATION, got.Rejection.ToProto().Reason)
+	require.EqualValues(t, 1, moderator.images.Load())
+
+	// So is a byte-for-byte re-upload, though the original passed moderation
+	// before its hash was blocked.
+	duplicate := h.stageUpload(t, data, true)
+	h.mark(t, duplicate)
+	h.process(t, 1)
+	require.Equal(t, blob.StateRejected, h.state(t, duplicate).State)
+
+	// An unrelated image is unaffected.
+	unrelated := h.stageUpload(t, makeCheckerPNG(t, 64, 64), true)
+	h.mark(t, unrelated)
+	h.process(t, 1)
+	require.Equal(t, blob.StateReady, h.state(t, unrelated).State)
+	require.EqualValues(t, 2, moderator.images.Load())
+}
+
+// countingModerator passes everything, counting the images it classifies.
+func testWorkerDerivesAVIFRenditions(t *testing.T, blobs blob.Store, storage blob.ObjectStorage, putObject putObjectFunc) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+	recorder := &originRecorder{ObjectStorage: storage}
+	encoder := &fakeAVIFEncoder{}
+	finalizer := blob.NewFinalizer(log, blobs, recorder, nil, blob.WithAVIFEncoder(encoder))
+	h := &workerHarness{
+		worker:    blob.NewWorker(log, blobs, finalizer, blob.ContentKindImage),
+		blobs:     blobs,
+		storage:   recorder,
+		putObject: putObject,
+	}
+
+	opaque := h.stageUpload(t, makePNG(t, 400, 300), true)
+	h.mark(t, opaque)
+	h.process(t, 1)
+	got := h.state(t, opaque)
+	require.Equal(t, blob.StateReady, got.State)
+
+	// Every rung is its WebP rendition followed by an AVIF sibling of the same
+	// role and size, both READY and stored.
+	require.NotEmpty(t, got.Renditions)
+	require.Zero(t, len(got.Renditions)%2)
+	for i := 0; i < len(got.Renditions); i += 2 {
+		webp, avif := got.Renditions[i], got.Renditions[i+1]
+		require.Equal(t, "image/webp", webp.MimeType)
+		require.Equal(t, "image/avif", avif.MimeType)
+		require.True(t, strings.HasSuffix(avif.StorageKey, ".avif"), avif.StorageKey)
+		require.Equal(t, webp.Rendition, avif.Rendition)
+		require.Equal(t, webp.Image.Width, avif.Image.Width)
+		require.Equal(t, webp.Image.Height, avif.Image.Height)
+		require.Equal(t, recorder.get(avif.StorageKey), []byte(fakeAVIFBytes))
+
+		child, err := blobs.GetByID(ctx, avif.ID)
+		require.NoError(t, err)
+		require.Equal(t, blob.StateReady, child.State)
+		require.Equal(t, "image/avif", child.MimeType)
+	}
+	require.EqualValues(t, len(got.Renditions)/2, encoder.calls.Load())
+
+	// A transparent original stays lossless WebP only.
+	transparent := h.stageUpload(t, makeTransparentPNG(t, 400, 300), true)
+	h.mark(t, transparent)
+	h.process(t, 1)
+	got = h.state(t, transparent)
+	require.Equal(t, blob.StateReady, got.State)
+	for _, ref := range got.Renditions {
+		require.Equal(t, "image/webp", ref.MimeType)
+	}
+}
+
+// fakeAVIFBytes is what fakeAVIFEncoder encodes every image to.
+const fakeAVIFBytes = "fake avif"
+
+// fakeAVIFEncoder is a blob.AVIFEncoder that counts its calls and encodes every
+// image to fakeAVIFBytes.
+type fakeAVIFEncoder struct {
+	calls atomic.Int64
+}
+
+func (e *fakeAVIFEncoder) EncodeAVIF(_ context.Context, img image.Image, quality int) ([]byte, error) {
+	e.calls.Add(1)
+	if img.Bounds().Empty() || quality < 1 || quality > 100 {
+		return nil, errors.New("invalid avif encode")
+	}
+	return []byte(fakeAVIFBytes), nil
+}
+
+type countingModerator struct {
+	fakeModerator
+	images atomic.Int64
+}
+
+func (m *countingModerator) ClassifyImage(ctx context.Context, data []byte) (*moderation.Result, error) {
+	m.images.Add(1)
+	return m.fakeModerator.ClassifyImage(ctx, data)
+}
+
+// makeAnimatedGIF returns a 40x30 GIF of n differently colored frames.
+func makeAnimatedGIF(t *testing.T, n int) []byte {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := range n {
+		frame := image.NewPaletted(image.Rect(0, 0, 40, 30), palette)
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8((p + i) % 2)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, anim))
+	return buf.Bytes()
+}
+
+// fakeBlocklist is a blob.HashBlocklist over a plain slice.
+type fakeBlocklist struct {
+	sync.Mutex
+	entries []*blob.BlockedHash
+}
+
+func (b *fakeBlocklist) Add(_ context.Context, entry *blob.BlockedHash) error {
+	b.Lock()
+	defer b.Unlock()
+	for _, existing := range b.entries {
+		if existing.Hash == entry.Hash {
+			return nil
+		}
+	}
+	b.entries = append(b.entries, entry.Clone())
+	return nil
+}
+
+func (b *fakeBlocklist) Remove(_ context.Context, hash blob.PerceptualHash) error {
+	b.Lock()
+	defer b.Unlock()
+	for i, existing := range b.entries {
+		if existing.Hash == hash {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			return nil
+		}
+	}
+	return blob.ErrNotFound
+}
+
+func (b *fakeBlocklist) List(context.Context) ([]*blob.BlockedHash, error) {
+	b.Lock()
+	defer b.Unlock()
+	entries := make([]*blob.BlockedHash, 0, len(b.entries))
+	for _, entry := range b.entries {
+		entries = append(entries, entry.Clone())
+	}
+	return entries, nil
+}
+
+// reencodeJPEG decodes image bytes and re-encodes them as a JPEG: different
+// bytes of the same picture.
+func reencodeJPEG(t *testing.T, data []byte) []byte {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}))
+	return buf.Bytes()
+}
+
+// makePhotoPNG returns a PNG of smooth, photo-like shading. Unlike makePNG's
+// repeating gradients, its low frequencies are distinct, so its perceptual hash
+// is stable under re-encoding the way a real photo's is.
+func makePhotoPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			v := 128 + 127*math.Sin(fx*6+fy*4)*math.Cos(fy*5-fx*3)
+			img.Set(x, y, color.RGBA{R: uint8(v), G: uint8(255 - v), B: uint8(fx * 255), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// makeCheckerPNG returns a black-and-white checkerboard PNG, a picture nothing
+// like makePhotoPNG's shading.
+func makeCheckerPNG(t *testing.T, width, height int) []byte {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			if (x/8+y/8)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
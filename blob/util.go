@@ -1,7 +1,7 @@
 package blob
 
-// SupportedImageMimeTypes is the set of MIME types a client may declare for an
-// upload. It is derived from the decodable formats, so the type pinned into the
+// SupportedImageMimeTypes is the set of image MIME types a client may declare
+// for an upload. It is derived from the decodable formats, so the type pinned into the
 // upload policy is always one the server can re-derive and validate from the
 // stored bytes. It is the single source of truth for "is this an image we
 // accept".
@@ -12,3 +12,15 @@ var SupportedImageMimeTypes = func() map[string]bool {
 	}
 	return set
 }()
+
+// SupportedVideoMimeTypes is the set of video MIME types a client may declare
+// for an upload. Like SupportedImageMimeTypes it is derived from what inspection
+// can recognize (the container brands), so a declared type is always one the
+// server can re-derive from the stored bytes.
+var SupportedVideoMimeTypes = func() map[string]bool {
+	set := make(map[string]bool, len(videoBrandToMimeType))
+	for _, mimeType := range videoBrandToMimeType {
+		set[mimeType] = true
+	}
+	return set
+}()
@@ -0,0 +1,552 @@
+package blob
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"time"
+)
+
+// InspectVideo failure categories, the video counterparts of the InspectImage
+// sentinels. Finalization classifies a rejection by them (see
+// rejectionReasonForInspection).
+var (
+	// ErrVideoCorrupt means the container could not be parsed, or is missing the
+	// structure every playable file carries (a movie header, a video track).
+	ErrVideoCorrupt = errors.New("video is corrupt or unparseable")
+
+	// ErrVideoUnsupportedType means the container is well-formed but holds
+	// something the service does not accept — an unrecognized brand, an
+	// unsupported codec, more than one video track, or a non-rotation transform.
+	ErrVideoUnsupportedType = errors.New("unsupported video type")
+
+	// ErrVideoTooLarge means the video's duration or dimensions exceed the limits.
+	ErrVideoTooLarge = errors.New("video exceeds duration or dimension limits")
+
+	// ErrVideoPrivacyMetadata means the container still carries metadata the
+	// client was required to strip — capture location, device, or XMP.
+	ErrVideoPrivacyMetadata = errors.New("video carries privacy-sensitive metadata")
+)
+
+const (
+	// MaxOriginalVideoSizeBytes bounds the declared size of an ORIGINAL video
+	// upload. It is pinned into the upload policy, so storage rejects anything
+	// larger before a single byte lands.
+	MaxOriginalVideoSizeBytes = 100 * 1024 * 1024 // 100 MiB
+
+	// maxVideoDuration bounds a video's presentation duration. Chat video is
+	// short-form; the cap also bounds how much a single upload can cost the
+	// moderation pass, which samples across the whole duration.
+	maxVideoDuration = 3 * time.Minute
+
+	// maxVideoDimension bounds the longer presentation side. 4K (3840x2160) is
+	// the largest a phone records today.
+	maxVideoDimension = 4096
+
+	// videoModerationSamples is how many frames, spread evenly across the
+	// duration, are moderated in addition to the poster. A video is served whole,
+	// so moderating the poster alone would leave the rest of it unchecked.
+	videoModerationSamples = 8
+
+	// maxVideoPosterOffset is the latest point the poster frame is taken from.
+	// The very first frame is often black (a fade-in, a camera warming up), so
+	// the poster comes from slightly in, or the midpoint of a shorter video.
+	maxVideoPosterOffset = time.Second
+)
+
+// videoBrandToMimeType maps an ISO-BMFF major brand (the ftyp box's first
+// field) to the canonical MIME type it is accepted as. QuickTime declares its
+// own brand; everything else here is a flavor of MP4.
+var videoBrandToMimeType = map[string]string{
+	"qt  ": "video/quicktime",
+	"isom": "video/mp4",
+	"iso2": "video/mp4",
+	"iso4": "video/mp4",
+	"iso5": "video/mp4",
+	"iso6": "video/mp4",
+	"mp41": "video/mp4",
+	"mp42": "video/mp4",
+	"avc1": "video/mp4",
+	"M4V ": "video/mp4",
+}
+
+// videoMimeTypeToExtension maps a supported video MIME type to its canonical
+// file extension (with leading dot).
+var videoMimeTypeToExtension = map[string]string{
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+}
+
+// videoCodecs maps the sample-entry FourCC of a video track to the codec family
+// it is recorded as. H.264 and HEVC are what phones record and what every
+// client OS can play back, so they are the only video codecs accepted.
+var videoCodecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+}
+
+// audioCodecs are the sample-entry FourCCs accepted on an audio track: AAC,
+// which is what every phone records alongside H.264/HEVC.
+var audioCodecs = map[string]bool{
+	"mp4a": true,
+}
+
+// videoPrivacyBoxes are the box types that carry personal data in an ISO-BMFF
+// or QuickTime file: ©xyz and loci hold the capture location, keys is the
+// QuickTime metadata key table phones record location, make, model, and capture
+// time under, and XMP_ is an embedded XMP packet. Like the PNG chunk list it is a
+// blocklist — the encoder tag ffmpeg and most editors leave behind (©too) is
+// harmless and kept.
+var videoPrivacyBoxes = map[string]bool{
+	"\xa9xyz": true,
+	"loci":    true,
+	"keys":    true,
+	"XMP_":    true,
+}
+
+// xmpUUID is the extended type of a uuid box carrying an XMP packet.
+const xmpUUID = "\xbe\x7a\xcf\xcb\x97\xa9\x42\xe8\x9c\x71\x99\x94\x91\xe3\xaf\xac"
+
+// VideoFrameExtractor decodes still frames out of a video's bytes. Go has no
+// native video decoder, so finalization depends on one supplied by the wiring
+// (e.g. an ffmpeg-backed implementation) to moderate a video and derive its
+// poster.
+type VideoFrameExtractor interface {
+	// ExtractFrames returns one decoded frame per offset, in order, each the
+	// frame presented at (or nearest before) that offset with the container's
+	// rotation already applied, so the pixels are upright.
+	ExtractFrames(ctx context.Context, data []byte, offsets []time.Duration) ([]image.Image, error)
+}
+
+// VideoInspection is the result of inspecting video bytes: the MIME type
+// authoritatively derived from the container plus the intrinsic video metadata.
+// The poster's BlurHash is filled in by finalization once the poster frame has
+// been extracted.
+type VideoInspection struct {
+	MimeType string
+	Metadata *VideoMetadata
+}
+
+// isoBox is one box of an ISO-BMFF (MP4/QuickTime) container: its FourCC and
+// its payload, the bytes after the header.
+type isoBox struct {
+	typ     string
+	payload []byte
+}
+
+// InspectVideo parses the bytes as an MP4 or QuickTime container and derives
+// their authoritative MIME type, presentation dimensions, duration, codec, and
+// rotation. It reads only the container structure — no frame is decoded — and
+// returns an error if the container is malformed, holds an unsupported codec,
+// exceeds the duration or dimension limits, or still carries privacy-sensitive
+// metadata; callers treat any of these as a rejection.
+func InspectVideo(data []byte) (*VideoInspection, error) {
+	boxes, err := parseBoxes(data, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(boxes) == 0 || boxes[0].typ != "ftyp" || len(boxes[0].payload) < 4 {
+		return nil, fmt.Errorf("missing file type box: %w", ErrVideoCorrupt)
+	}
+	brand := string(boxes[0].payload[:4])
+	mimeType, ok := videoBrandToMimeType[brand]
+	if !ok {
+		return nil, fmt.Errorf("unsupported container brand %q: %w", brand, ErrVideoUnsupportedType)
+	}
+
+	// Walk the whole tree for metadata before judging anything else: a file that
+	// must be rejected for privacy is rejected as such, whatever else is wrong.
+	hasPrivacy, err := videoHasPrivacyMetadata(boxes)
+	if err != nil {
+		return nil, err
+	}
+	if hasPrivacy {
+		return nil, fmt.Errorf("video carries metadata that must be stripped before upload: %w", ErrVideoPrivacyMetadata)
+	}
+
+	moov := findBox(boxes, "moov")
+	if moov == nil {
+		return nil, fmt.Errorf("missing movie box: %w", ErrVideoCorrupt)
+	}
+	children, err := parseBoxes(moov.payload, false)
+	if err != nil {
+		return nil, err
+	}
+	if findBox(children, "mvex") != nil {
+		// A fragmented file's movie header does not describe its full duration, so
+		// the limits below could not be enforced against it.
+		return nil, fmt.Errorf("fragmented video is not supported: %w", ErrVideoUnsupportedType)
+	}
+
+	mvhd := findBox(children, "mvhd")
+	if mvhd == nil {
+		return nil, fmt.Errorf("missing movie header: %w", ErrVideoCorrupt)
+	}
+	duration, err := parseMovieDuration(mvhd.payload)
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("video has no duration: %w", ErrVideoCorrupt)
+	}
+	if duration > maxVideoDuration {
+		return nil, fmt.Errorf("video duration %s exceeds the %s limit: %w", duration, maxVideoDuration, ErrVideoTooLarge)
+	}
+
+	var video *videoTrack
+	for _, box := range children {
+		if box.typ != "trak" {
+			continue
+		}
+		track, err := parseTrack(box.payload)
+		if err != nil {
+			return nil, err
+		}
+		switch track.handler {
+		case "vide":
+			if video != nil {
+				return nil, fmt.Errorf("video has more than one video track: %w", ErrVideoUnsupportedType)
+			}
+			video = track
+		case "soun":
+			if !audioCodecs[track.sampleEntry] {
+				return nil, fmt.Errorf("unsupported audio codec %q: %w", track.sampleEntry, ErrVideoUnsupportedType)
+			}
+		}
+	}
+	if video == nil {
+		return nil, fmt.Errorf("missing video track: %w", ErrVideoCorrupt)
+	}
+
+	codec, ok := videoCodecs[video.sampleEntry]
+	if !ok {
+		return nil, fmt.Errorf("unsupported video codec %q: %w", video.sampleEntry, ErrVideoUnsupportedType)
+	}
+	width, height := video.width, video.height
+	if video.rotation == 90 || video.rotation == 270 {
+		width, height = height, width
+	}
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("video has invalid dimensions %dx%d: %w", width, height, ErrVideoCorrupt)
+	}
+	if max(width, height) > maxVideoDimension {
+		return nil, fmt.Errorf("video dimensions %dx%d exceed the %d limit: %w", width, height, maxVideoDimension, ErrVideoTooLarge)
+	}
+
+	return &VideoInspection{
+		MimeType: mimeType,
+		Metadata: &VideoMetadata{
+			Width:    width,
+			Height:   height,
+			Duration: duration,
+			Codec:    codec,
+			Rotation: video.rotation,
+		},
+	}, nil
+}
+
+// videoTrack is what inspection reads from one trak box.
+type videoTrack struct {
+	handler     string // the media handler type: "vide", "soun", ...
+	sampleEntry string // the first sample description's FourCC, i.e. the codec
+	width       uint32 // the coded presentation width, before rotation
+	height      uint32 // the coded presentation height, before rotation
+	rotation    uint32 // clockwise degrees, from the track matrix
+}
+
+// parseTrack reads a trak box's header (dimensions, rotation), its media
+// handler, and the codec of its first sample description.
+func parseTrack(trak []byte) (*videoTrack, error) {
+	children, err := parseBoxes(trak, false)
+	if err != nil {
+		return nil, err
+	}
+	tkhd := findBox(children, "tkhd")
+	if tkhd == nil {
+		return nil, fmt.Errorf("track is missing its header: %w", ErrVideoCorrupt)
+	}
+	track, err := parseTrackHeader(tkhd.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	mdia := findBox(children, "mdia")
+	if mdia == nil {
+		return nil, fmt.Errorf("track is missing its media box: %w", ErrVideoCorrupt)
+	}
+	media, err := parseBoxes(mdia.payload, false)
+	if err != nil {
+		return nil, err
+	}
+	hdlr := findBox(media, "hdlr")
+	if hdlr == nil || len(hdlr.payload) < 12 {
+		return nil, fmt.Errorf("track is missing its handler: %w", ErrVideoCorrupt)
+	}
+	// version+flags(4), pre_defined(4), then the handler type.
+	track.handler = string(hdlr.payload[8:12])
+
+	stsd, err := findPath(media, "minf", "stbl", "stsd")
+	if err != nil {
+		return nil, err
+	}
+	// version+flags(4), entry_count(4), then the first entry's size(4) and type(4).
+	if stsd == nil || len(stsd.payload) < 16 || binary.BigEndian.Uint32(stsd.payload[4:]) == 0 {
+		return nil, fmt.Errorf("track is missing its sample description: %w", ErrVideoCorrupt)
+	}
+	track.sampleEntry = string(stsd.payload[12:16])
+	return track, nil
+}
+
+// parseTrackHeader reads a tkhd payload's presentation dimensions and display
+// rotation. Only the four pure rotations are accepted: anything else in the
+// matrix (a mirror, a skew, a scale) would be rendered inconsistently across
+// players, and the poster extracted from it could differ from what is shown.
+func parseTrackHeader(tkhd []byte) (*videoTrack, error) {
+	if len(tkhd) < 1 {
+		return nil, fmt.Errorf("truncated track header: %w", ErrVideoCorrupt)
+	}
+	// version+flags(4), then times and ids whose width depends on the version,
+	// then reserved(8), layer(2), alternate_group(2), volume(2), reserved(2),
+	// matrix(36), width(4), height(4).
+	matrixAt := 4 + 20 + 8 + 8
+	if tkhd[0] == 1 {
+		matrixAt = 4 + 32 + 8 + 8
+	}
+	if len(tkhd) < matrixAt+36+8 {
+		return nil, fmt.Errorf("truncated track header: %w", ErrVideoCorrupt)
+	}
+	m := tkhd[matrixAt:]
+	a := int32(binary.BigEndian.Uint32(m[0:]))
+	b := int32(binary.BigEndian.Uint32(m[4:]))
+	c := int32(binary.BigEndian.Uint32(m[12:]))
+	d := int32(binary.BigEndian.Uint32(m[16:]))
+
+	const one = 1 << 16 // the matrix entries are 16.16 fixed point
+	var rotation uint32
+	switch [4]int32{a, b, c, d} {
+	case [4]int32{one, 0, 0, one}:
+		rotation = 0
+	case [4]int32{0, one, -one, 0}:
+		rotation = 90
+	case [4]int32{-one, 0, 0, -one}:
+		rotation = 180
+	case [4]int32{0, -one, one, 0}:
+		rotation = 270
+	default:
+		return nil, fmt.Errorf("unsupported track transform: %w", ErrVideoUnsupportedType)
+	}
+
+	// Width and height are 16.16 fixed point; the fraction is dropped.
+	dims := tkhd[matrixAt+36:]
+	return &videoTrack{
+		width:    binary.BigEndian.Uint32(dims[0:]) >> 16,
+		height:   binary.BigEndian.Uint32(dims[4:]) >> 16,
+		rotation: rotation,
+	}, nil
+}
+
+// parseMovieDuration reads an mvhd payload's duration, converting it from the
+// movie timescale.
+func parseMovieDuration(mvhd []byte) (time.Duration, error) {
+	if len(mvhd) < 1 {
+		return 0, fmt.Errorf("truncated movie header: %w", ErrVideoCorrupt)
+	}
+	var timescale, duration uint64
+	if mvhd[0] == 1 {
+		// version+flags(4), creation(8), modification(8), timescale(4), duration(8)
+		if len(mvhd) < 32 {
+			return 0, fmt.Errorf("truncated movie header: %w", ErrVideoCorrupt)
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:]))
+		duration = binary.BigEndian.Uint64(mvhd[24:])
+	} else {
+		// version+flags(4), creation(4), modification(4), timescale(4), duration(4)
+		if len(mvhd) < 20 {
+			return 0, fmt.Errorf("truncated movie header: %w", ErrVideoCorrupt)
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:]))
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("movie header has no timescale: %w", ErrVideoCorrupt)
+	}
+	// Compare in the movie's own units first, so a hostile duration cannot
+	// overflow the conversion into nanoseconds.
+	if duration/timescale > uint64(maxVideoDuration/time.Second) {
+		return 0, fmt.Errorf("video duration exceeds the %s limit: %w", maxVideoDuration, ErrVideoTooLarge)
+	}
+	// Split whole and fractional seconds: duration*time.Second alone overflows
+	// for a large timescale.
+	whole := time.Duration(duration/timescale) * time.Second
+	fraction := time.Duration(duration%timescale) * time.Second / time.Duration(timescale)
+	return whole + fraction, nil
+}
+
+// videoHasPrivacyMetadata walks the moov box (and any top-level meta box) for a
+// box that carries personal data. It is the video counterpart of
+// hasPrivacyMetadata, with the same posture: the uploaded bytes are served
+// verbatim, so the client strips the metadata and the server only detects it.
+//
+// Metadata hides at several depths — moov/udta, moov/meta, trak/udta, and
+// inside meta's item list — so the walk descends into every container box
+// rather than probing fixed paths.
+func videoHasPrivacyMetadata(top []isoBox) (bool, error) {
+	for _, box := range top {
+		switch box.typ {
+		case "moov", "meta", "udta":
+			found, err := boxesHavePrivacyMetadata(box.typ, box.payload, 0)
+			if err != nil || found {
+				return found, err
+			}
+		case "uuid":
+			if len(box.payload) >= 16 && string(box.payload[:16]) == xmpUUID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// videoContainerBoxes are the box types whose payload is itself a list of
+// boxes, and so are descended into by the privacy walk.
+var videoContainerBoxes = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"udta": true,
+	"meta": true,
+	"ilst": true,
+}
+
+// maxVideoBoxDepth bounds the privacy walk's recursion, so a hostile file
+// nesting containers cannot exhaust the stack. Real files nest a handful deep.
+const maxVideoBoxDepth = 16
+
+func boxesHavePrivacyMetadata(typ string, payload []byte, depth int) (bool, error) {
+	if depth > maxVideoBoxDepth {
+		return false, fmt.Errorf("boxes nested too deeply: %w", ErrVideoCorrupt)
+	}
+	// An ISO-BMFF meta box is a full box (version+flags before its children); a
+	// QuickTime one is not. Tell them apart by whether a box header follows.
+	if typ == "meta" && len(payload) >= 12 && !isBoxType(payload[4:8]) && isBoxType(payload[8:12]) {
+		payload = payload[4:]
+	}
+	children, err := parseBoxes(payload, false)
+	if err != nil {
+		return false, err
+	}
+	for _, child := range children {
+		if videoPrivacyBoxes[child.typ] {
+			return true, nil
+		}
+		if child.typ == "uuid" && len(child.payload) >= 16 && string(child.payload[:16]) == xmpUUID {
+			return true, nil
+		}
+		if !videoContainerBoxes[child.typ] {
+			continue
+		}
+		found, err := boxesHavePrivacyMetadata(child.typ, child.payload, depth+1)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// isBoxType reports whether four bytes look like a box FourCC: printable ASCII,
+// or the © (0xA9) prefix QuickTime's user-data atoms use.
+func isBoxType(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 || c > 0x7E) && c != 0xA9 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBoxes splits data into its sequence of boxes. A box whose size field is 1
+// carries a 64-bit size after its type; a size of 0 extends to the end of the
+// data, which is only legal for the last top-level box (typically mdat). Any box
+// that overruns the data is reported as corrupt.
+func parseBoxes(data []byte, topLevel bool) ([]isoBox, error) {
+	var boxes []isoBox
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 8 {
+			return nil, fmt.Errorf("truncated box header: %w", ErrVideoCorrupt)
+		}
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			if !topLevel {
+				return nil, fmt.Errorf("open-ended %q box is not top-level: %w", typ, ErrVideoCorrupt)
+			}
+			size = uint64(len(data) - pos)
+		case 1:
+			if len(data)-pos < 16 {
+				return nil, fmt.Errorf("truncated box header: %w", ErrVideoCorrupt)
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)-pos) {
+			return nil, fmt.Errorf("%q box overruns its container: %w", typ, ErrVideoCorrupt)
+		}
+		boxes = append(boxes, isoBox{typ: typ, payload: data[pos+int(header) : pos+int(size)]})
+		pos += int(size)
+	}
+	return boxes, nil
+}
+
+// findBox returns the first box of the given type, or nil.
+func findBox(boxes []isoBox, typ string) *isoBox {
+	for i := range boxes {
+		if boxes[i].typ == typ {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// findPath descends through nested container boxes along path, returning the
+// box at its end or nil if any step is absent.
+func findPath(boxes []isoBox, path ...string) (*isoBox, error) {
+	var box *isoBox
+	for i, typ := range path {
+		if box = findBox(boxes, typ); box == nil {
+			return nil, nil
+		}
+		if i == len(path)-1 {
+			break
+		}
+		children, err := parseBoxes(box.payload, false)
+		if err != nil {
+			return nil, err
+		}
+		boxes = children
+	}
+	return box, nil
+}
+
+// videoPosterOffset is where a video's poster frame is taken from: a second in,
+// or the midpoint of anything shorter.
+func videoPosterOffset(duration time.Duration) time.Duration {
+	return min(duration/2, maxVideoPosterOffset)
+}
+
+// videoModerationOffsets are the points the moderation frames are sampled at:
+// the centers of videoModerationSamples equal slices of the duration, so the
+// samples cover the whole video without landing on its first or last frame.
+func videoModerationOffsets(duration time.Duration) []time.Duration {
+	offsets := make([]time.Duration, videoModerationSamples)
+	for i := range offsets {
+		offsets[i] = duration * time.Duration(2*i+1) / (2 * videoModerationSamples)
+	}
+	return offsets
+}
@@ -0,0 +1,196 @@
+package blob
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testVideo describes a synthetic MP4/QuickTime container: only the structure
+// InspectVideo reads is written, so no frame data is needed.
+type testVideo struct {
+	brand      string
+	codec      string
+	audioCodec string // no audio track when empty
+	width      uint32
+	height     uint32
+	matrix     [4]int32 // a, b, c, d in 16.16 fixed point; identity when zero
+	duration   time.Duration
+
+	// moovExtra are additional raw boxes appended inside moov.
+	moovExtra [][]byte
+}
+
+func isoTestBox(typ string, parts ...[]byte) []byte {
+	size := 8
+	for _, p := range parts {
+		size += len(p)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(size))
+	out = append(out, typ...)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func testTrack(handler, codec string, width, height uint32, matrix [4]int32) []byte {
+	if matrix == ([4]int32{}) {
+		matrix = [4]int32{1 << 16, 0, 0, 1 << 16}
+	}
+	tkhd := make([]byte, 4+20+8+8)
+	for _, v := range []int32{matrix[0], matrix[1], 0, matrix[2], matrix[3], 0, 0, 0, 1 << 30} {
+		tkhd = binary.BigEndian.AppendUint32(tkhd, uint32(v))
+	}
+	tkhd = binary.BigEndian.AppendUint32(tkhd, width<<16)
+	tkhd = binary.BigEndian.AppendUint32(tkhd, height<<16)
+
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+
+	stsd := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+	stsd = append(stsd, isoTestBox(codec, make([]byte, 8))...)
+
+	return isoTestBox("trak",
+		isoTestBox("tkhd", tkhd),
+		isoTestBox("mdia",
+			isoTestBox("hdlr", hdlr),
+			isoTestBox("minf", isoTestBox("stbl", isoTestBox("stsd", stsd))),
+		),
+	)
+}
+
+func makeTestVideo(v testVideo) []byte {
+	if v.brand == "" {
+		v.brand = "isom"
+	}
+	if v.codec == "" {
+		v.codec = "avc1"
+	}
+	if v.width == 0 && v.height == 0 {
+		v.width, v.height = 1920, 1080
+	}
+	if v.duration == 0 {
+		v.duration = 10 * time.Second
+	}
+
+	mvhd := make([]byte, 12)
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 1000)
+	mvhd = binary.BigEndian.AppendUint32(mvhd, uint32(v.duration/time.Millisecond))
+	mvhd = append(mvhd, make([]byte, 80)...)
+
+	moov := [][]byte{isoTestBox("mvhd", mvhd), testTrack("vide", v.codec, v.width, v.height, v.matrix)}
+	if v.audioCodec != "" {
+		moov = append(moov, testTrack("soun", v.audioCodec, 0, 0, [4]int32{}))
+	}
+	moov = append(moov, v.moovExtra...)
+
+	out := isoTestBox("ftyp", []byte(v.brand), make([]byte, 4))
+	out = append(out, isoTestBox("moov", moov...)...)
+	return append(out, isoTestBox("mdat", make([]byte, 32))...)
+}
+
+func TestInspectVideo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		video    testVideo
+		mimeType string
+		expected VideoMetadata
+	}{
+		{
+			name:     "mp4 h264",
+			video:    testVideo{audioCodec: "mp4a"},
+			mimeType: "video/mp4",
+			expected: VideoMetadata{Width: 1920, Height: 1080, Duration: 10 * time.Second, Codec: "h264"},
+		},
+		{
+			name:     "quicktime hevc",
+			video:    testVideo{brand: "qt  ", codec: "hvc1"},
+			mimeType: "video/quicktime",
+			expected: VideoMetadata{Width: 1920, Height: 1080, Duration: 10 * time.Second, Codec: "hevc"},
+		},
+		{
+			name:     "rotated 90 swaps the presentation dimensions",
+			video:    testVideo{matrix: [4]int32{0, 1 << 16, -1 << 16, 0}},
+			mimeType: "video/mp4",
+			expected: VideoMetadata{Width: 1080, Height: 1920, Duration: 10 * time.Second, Codec: "h264", Rotation: 90},
+		},
+		{
+			name:     "rotated 180 keeps the dimensions",
+			video:    testVideo{matrix: [4]int32{-1 << 16, 0, 0, -1 << 16}},
+			mimeType: "video/mp4",
+			expected: VideoMetadata{Width: 1920, Height: 1080, Duration: 10 * time.Second, Codec: "h264", Rotation: 180},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inspection, err := InspectVideo(makeTestVideo(tc.video))
+			require.NoError(t, err)
+			require.Equal(t, tc.mimeType, inspection.MimeType)
+			require.Equal(t, tc.expected, *inspection.Metadata)
+		})
+	}
+}
+
+func TestInspectVideoRejects(t *testing.T) {
+	udta := func(children ...[]byte) []byte { return isoTestBox("udta", children...) }
+
+	valid := makeTestVideo(testVideo{})
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"unknown brand", makeTestVideo(testVideo{brand: "3gp4"}), ErrVideoUnsupportedType},
+		{"unsupported video codec", makeTestVideo(testVideo{codec: "vp09"}), ErrVideoUnsupportedType},
+		{"unsupported audio codec", makeTestVideo(testVideo{audioCodec: "Opus"}), ErrVideoUnsupportedType},
+		{"skewed transform", makeTestVideo(testVideo{matrix: [4]int32{1 << 16, 1 << 15, 0, 1 << 16}}), ErrVideoUnsupportedType},
+		{"fragmented", makeTestVideo(testVideo{moovExtra: [][]byte{isoTestBox("mvex")}}), ErrVideoUnsupportedType},
+		{"second video track", makeTestVideo(testVideo{moovExtra: [][]byte{testTrack("vide", "avc1", 640, 480, [4]int32{})}}), ErrVideoUnsupportedType},
+		{"too long", makeTestVideo(testVideo{duration: maxVideoDuration + time.Second}), ErrVideoTooLarge},
+		{"too wide", makeTestVideo(testVideo{width: maxVideoDimension + 1, height: 1080}), ErrVideoTooLarge},
+		{"capture location", makeTestVideo(testVideo{moovExtra: [][]byte{udta(isoTestBox("\xa9xyz", []byte("+37.7-122.4/")))}}), ErrVideoPrivacyMetadata},
+		{"quicktime metadata keys", makeTestVideo(testVideo{moovExtra: [][]byte{isoTestBox("meta", isoTestBox("hdlr", make([]byte, 24)), isoTestBox("keys", make([]byte, 8)))}}), ErrVideoPrivacyMetadata},
+		{"iso metadata item list", makeTestVideo(testVideo{moovExtra: [][]byte{udta(isoTestBox("meta", make([]byte, 4), isoTestBox("ilst", isoTestBox("loci", make([]byte, 8)))))}}), ErrVideoPrivacyMetadata},
+		{"not a container", []byte("definitely not a video"), ErrVideoCorrupt},
+		{"truncated", valid[:len(valid)/2], ErrVideoCorrupt},
+		{"missing movie box", isoTestBox("ftyp", []byte("isom"), make([]byte, 4)), ErrVideoCorrupt},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := InspectVideo(tc.data)
+			require.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestInspectVideoKeepsHarmlessMetadata(t *testing.T) {
+	// The encoder tag every editor leaves behind carries nothing personal.
+	data := makeTestVideo(testVideo{moovExtra: [][]byte{isoTestBox("udta", isoTestBox("\xa9too", []byte("Lavf60.3.100")))}})
+	_, err := InspectVideo(data)
+	require.NoError(t, err)
+}
+
+func TestParseMovieDurationLargeTimescale(t *testing.T) {
+	// A version 1 header, whose 64-bit duration can reach the limit at a
+	// timescale where converting straight to nanoseconds overflows.
+	const timescale = 4_000_000_000
+	mvhd := make([]byte, 20)
+	mvhd[0] = 1
+	mvhd = binary.BigEndian.AppendUint32(mvhd, timescale)
+	mvhd = binary.BigEndian.AppendUint64(mvhd, 179*timescale+timescale/2)
+
+	duration, err := parseMovieDuration(mvhd)
+	require.NoError(t, err)
+	require.Equal(t, 179*time.Second+500*time.Millisecond, duration)
+}
+
+func TestVideoFrameOffsets(t *testing.T) {
+	require.Equal(t, time.Second, videoPosterOffset(time.Minute))
+	require.Equal(t, 250*time.Millisecond, videoPosterOffset(500*time.Millisecond))
+
+	offsets := videoModerationOffsets(16 * time.Second)
+	require.Len(t, offsets, videoModerationSamples)
+	require.Equal(t, time.Second, offsets[0])
+	require.Equal(t, 15*time.Second, offsets[len(offsets)-1])
+}
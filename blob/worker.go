@@ -10,6 +10,8 @@ import (
 
 	"go.uber.org/zap"
 
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+
 	"github.com/code-payments/ocp-server/metrics"
 	"github.com/code-payments/ocp-server/metrics/noop"
 )
@@ -45,6 +47,15 @@ const (
 	// forever.
 	defaultWorkerFinalizeTimeout = time.Minute
 
+	// defaultVideoWorkerMaxConcurrency, defaultVideoWorkerClaimLease, and
+	// defaultVideoWorkerFinalizeTimeout are the video queue's defaults. A video
+	// costs a frame extraction and a moderation call per sampled frame, so it
+	// runs one at a time under a longer bound; the lease keeps its margin over
+	// the timeout.
+	defaultVideoWorkerMaxConcurrency  = 1
+	defaultVideoWorkerClaimLease      = 10 * time.Minute
+	defaultVideoWorkerFinalizeTimeout = 5 * time.Minute
+
 	// workerQueueStatsEventName is the metric event carrying a kind's queue
 	// gauges — depth and max age — emitted once per second by every running
 	// worker (mirroring the OCP task runtime's polling gauge). Charted over
@@ -90,6 +101,7 @@ type Worker struct {
 	maxBackoffDelay time.Duration
 	claimLease      time.Duration
 	finalizeTimeout time.Duration
+	deadLetter      bool
 }
 
 // WorkerOption overrides one of the worker's tuning knobs.
@@ -106,7 +118,7 @@ func WithWorkerMaxConcurrency(n int) WorkerOption {
 }
 
 // WithWorkerMaxAttempts overrides how many failed attempts a blob gets before
-// it is terminally rejected.
+// it is terminally rejected (or dead-lettered; see WithWorkerDeadLetter).
 func WithWorkerMaxAttempts(n uint32) WorkerOption {
 	return func(w *Worker) { w.maxAttempts = n }
 }
@@ -129,8 +141,17 @@ func WithWorkerFinalizeTimeout(timeout time.Duration) WorkerOption {
 	return func(w *Worker) { w.finalizeTimeout = timeout }
 }
 
+// WithWorkerDeadLetter parks a blob that exhausts its attempts on its kind's
+// dead-letter list (see Store.DeadLetterFinalization) instead of terminally
+// rejecting it. The client keeps seeing it as processing until an operator
+// fixes the cause and re-queues it, or it expires unfinalized.
+func WithWorkerDeadLetter() WorkerOption {
+	return func(w *Worker) { w.deadLetter = true }
+}
+
 // NewWorker returns a Worker draining kind's finalization queue over the given
-// blob store and finalizer.
+// blob store and finalizer. The video queue starts from its own, slower
+// defaults; options apply on top of either.
 func NewWorker(log *zap.Logger, blobs Store, finalizer *Finalizer, kind ContentKind, opts ...WorkerOption) *Worker {
 	w := &Worker{
 		log:       log,
@@ -146,6 +167,11 @@ func NewWorker(log *zap.Logger, blobs Store, finalizer *Finalizer, kind ContentK
 		claimLease:      defaultWorkerClaimLease,
 		finalizeTimeout: defaultWorkerFinalizeTimeout,
 	}
+	if kind == ContentKindVideo {
+		w.maxConcurrency = defaultVideoWorkerMaxConcurrency
+		w.claimLease = defaultVideoWorkerClaimLease
+		w.finalizeTimeout = defaultVideoWorkerFinalizeTimeout
+	}
 	for _, opt := range opts {
 		opt(w)
 	}
@@ -219,8 +245,8 @@ func (w *Worker) Process(runtimeCtx context.Context) (int, error) {
 	return int(processed.Load()), nil
 }
 
-// processOne drives a single due task: exhausted tasks are terminally failed,
-// anything else is claimed and run through the finalization pipeline, with a
+// processOne drives a single due task: exhausted tasks are terminally failed
+// (or dead-lettered), anything else is claimed and run through the finalization pipeline, with a
 // failed attempt rescheduled under backoff. It reports whether this worker took
 // the task on.
 func (w *Worker) processOne(ctx context.Context, task *FinalizationTask) bool {
@@ -230,6 +256,10 @@ func (w *Worker) processOne(ctx context.Context, task *FinalizationTask) bool {
 	// rejection instead of another try, so the client sees a definitive status.
 	// Fail is idempotent, so racing another worker here is harmless.
 	if task.Attempts >= w.maxAttempts {
+		if w.deadLetter {
+			w.deadLetterTask(ctx, log, task.ID, task.Attempts, errAttemptsExhausted)
+			return true
+		}
 		log.Warn("Blob exhausted its finalization attempts; rejecting",
 			zap.Uint32("attempts", task.Attempts))
 		if err := w.finalizer.Fail(ctx, task.ID); err != nil {
@@ -271,6 +301,10 @@ func (w *Worker) processOne(ctx context.Context, task *FinalizationTask) bool {
 		// The pipeline stopped short of a terminal state (it resumes from its
 		// last checkpoint next time); reschedule under backoff.
 		attempts := task.Attempts + 1
+		if w.deadLetter && attempts >= w.maxAttempts {
+			w.deadLetterTask(ctx, log, task.ID, attempts, err)
+			return true
+		}
 		delay := w.backoffDelay(attempts)
 		log.Warn("Blob finalization attempt failed; rescheduling",
 			zap.Error(err),
@@ -284,6 +318,21 @@ func (w *Worker) processOne(ctx context.Context, task *FinalizationTask) bool {
 	return true
 }
 
+// errAttemptsExhausted is the error a task is dead-lettered with when it is
+// found exhausted at poll time, its last attempt's error long gone.
+var errAttemptsExhausted = errors.New("finalization attempts exhausted")
+
+// deadLetterTask parks an exhausted task on the kind's dead-letter list.
+func (w *Worker) deadLetterTask(ctx context.Context, log *zap.Logger, id *blobpb.BlobId, attempts uint32, cause error) {
+	log.Warn("Blob exhausted its finalization attempts; dead-lettering",
+		zap.Error(cause),
+		zap.Uint32("attempts", attempts),
+	)
+	if err := w.blobs.DeadLetterFinalization(ctx, id, w.kind, attempts, cause.Error(), time.Now()); err != nil {
+		log.Warn("Failed to dead-letter exhausted blob", zap.Error(err))
+	}
+}
+
 // queueStatsGaugeWorker emits this worker's kind's finalization queue gauges —
 // depth and max age — as a recurring metric event until ctx is cancelled, so
 // dashboards can chart whether the queue is growing or draining and whether
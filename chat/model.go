@@ -100,6 +100,11 @@ func IsDmChatType(chatType chatpb.ChatType) bool {
 	return slices.Contains(dmChatTypes, chatType)
 }
 
+// DmChatTypes returns every DM chat type, for callers that walk each DM feed.
+func DmChatTypes() []chatpb.ChatType {
+	return slices.Clone(dmChatTypes)
+}
+
 // DeriveDmChatType reports which DM type's canonical derivation over the
 // members produces chatID, letting callers that already hold a chat's members
 // recover its type without a store read. It returns UNKNOWN when no DM type
@@ -159,6 +159,14 @@ func (m *memory) Replace(
 	return nil
 }
 
+func (m *memory) DeleteContactList(_ context.Context, userID *commonpb.UserId) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.users, string(userID.Value))
+	return nil
+}
+
 func zeroChecksum() []byte {
 	return make([]byte, contact.ChecksumSize)
 }
@@ -283,3 +283,25 @@ func dbReplace(
 		return nil
 	})
 }
+
+// dbDeleteContactList removes the user's contact list row and its entries.
+func dbDeleteContactList(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) error {
+	encodedUserID := pg.Encode(userID.Value)
+
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(
+			ctx,
+			`DELETE FROM `+contactListEntriesTableName+` WHERE "userId" = $1`,
+			encodedUserID,
+		); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			`DELETE FROM `+contactListsTableName+` WHERE "userId" = $1`,
+			encodedUserID,
+		)
+		return err
+	})
+}
@@ -56,6 +56,10 @@ func (s *store) Replace(
 	return dbReplace(ctx, s.pool, userID, hashes, expectedChecksum)
 }
 
+func (s *store) DeleteContactList(ctx context.Context, userID *commonpb.UserId) error {
+	return dbDeleteContactList(ctx, s.pool, userID)
+}
+
 func (s *store) reset() {
 	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+contactListEntriesTableName)
 	if err != nil {
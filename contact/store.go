@@ -95,4 +95,8 @@ type Store interface {
 		hashes []*commonpb.Hash,
 		expectedChecksum *commonpb.Hash,
 	) error
+
+	// DeleteContactList removes the user's contact list row and every entry in
+	// it. It is a no-op when the user has no stored contact list.
+	DeleteContactList(ctx context.Context, userID *commonpb.UserId) error
 }
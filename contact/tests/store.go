@@ -30,6 +30,7 @@ func RunStoreTests(t *testing.T, s contact.Store, createUser CreateUserFunc, tea
 		testStore_GetHashes_AfterDelta,
 		testStore_GetUserIdsByPhoneHash,
 		testStore_IsContact,
+		testStore_DeleteContactList,
 	} {
 		tf(t, s, createUser)
 		teardown()
@@ -384,6 +385,39 @@ func testStore_IsContact(t *testing.T, s contact.Store, createUser CreateUserFun
 	require.False(t, got)
 }
 
+func testStore_DeleteContactList(t *testing.T, s contact.Store, createUser CreateUserFunc) {
+	ctx := context.Background()
+
+	h1 := hash("a")
+	h2 := hash("b")
+
+	userA := createUser(t)
+	userB := createUser(t)
+
+	// Deleting a list that was never uploaded is a no-op.
+	require.NoError(t, s.DeleteContactList(ctx, userA))
+
+	require.NoError(t, s.Replace(ctx, userA, []*commonpb.Hash{h1, h2}, xor(contact.ZeroChecksum(), h1, h2)))
+	require.NoError(t, s.Replace(ctx, userB, []*commonpb.Hash{h1}, xor(contact.ZeroChecksum(), h1)))
+
+	require.NoError(t, s.DeleteContactList(ctx, userA))
+	require.NoError(t, s.DeleteContactList(ctx, userA))
+
+	_, err := s.GetChecksum(ctx, userA)
+	require.ErrorIs(t, err, contact.ErrNotFound)
+	_, err = s.GetHashes(ctx, userA)
+	require.ErrorIs(t, err, contact.ErrNotFound)
+
+	owners, err := s.GetUserIdsByPhoneHash(ctx, h1)
+	require.NoError(t, err)
+	require.Equal(t, userIdValues([]*commonpb.UserId{userB}), userIdValues(owners))
+
+	// Other users' lists are untouched.
+	hashes, err := s.GetHashes(ctx, userB)
+	require.NoError(t, err)
+	require.Equal(t, hashValues(h1), hashValues(hashes...))
+}
+
 func userIdValues(ids []*commonpb.UserId) [][]byte {
 	out := make([][]byte, len(ids))
 	for i, id := range ids {
@@ -0,0 +1,12 @@
+-- CreateTable
+CREATE TABLE "flipcash_moderation_results" (
+    "key" TEXT NOT NULL,
+    "flagged" BOOLEAN NOT NULL,
+    "flaggedCategories" TEXT[],
+    "categoryScores" JSONB NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+    "expiresAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "flipcash_moderation_results_pkey" PRIMARY KEY ("key")
+);
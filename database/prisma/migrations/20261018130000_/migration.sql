@@ -0,0 +1,19 @@
+-- CreateTable
+CREATE TABLE "flipcash_moderation_disagreements" (
+    "id" BIGSERIAL NOT NULL,
+    "method" TEXT NOT NULL,
+    "contentHash" TEXT NOT NULL,
+    "content" TEXT NOT NULL DEFAULT '',
+    "primaryFlagged" BOOLEAN NOT NULL,
+    "primaryCategories" TEXT[],
+    "primaryScores" JSONB NOT NULL,
+    "candidateFlagged" BOOLEAN NOT NULL,
+    "candidateCategories" TEXT[],
+    "candidateScores" JSONB NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "flipcash_moderation_disagreements_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_moderation_disagreements_method_createdAt_idx" ON "flipcash_moderation_disagreements"("method", "createdAt");
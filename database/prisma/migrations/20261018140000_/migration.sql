@@ -0,0 +1,42 @@
+-- CreateTable
+CREATE TABLE "flipcash_moderation_audit_records" (
+    "id" TEXT NOT NULL,
+    "userId" TEXT NOT NULL,
+    "method" TEXT NOT NULL,
+    "classifier" TEXT NOT NULL,
+    "contentHash" TEXT NOT NULL,
+    "flaggedCategories" TEXT[],
+    "categoryScores" JSONB NOT NULL,
+    "decision" TEXT NOT NULL,
+    "blobId" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "flipcash_moderation_audit_records_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "flipcash_moderation_appeals" (
+    "id" TEXT NOT NULL,
+    "recordId" TEXT NOT NULL,
+    "userId" TEXT NOT NULL,
+    "reason" TEXT NOT NULL,
+    "state" TEXT NOT NULL,
+    "resolvedBy" TEXT,
+    "note" TEXT NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "resolvedAt" TIMESTAMP(3),
+
+    CONSTRAINT "flipcash_moderation_appeals_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_moderation_audit_records_userId_createdAt_idx" ON "flipcash_moderation_audit_records"("userId", "createdAt");
+
+-- CreateIndex
+CREATE INDEX "flipcash_moderation_audit_records_blobId_idx" ON "flipcash_moderation_audit_records"("blobId");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "flipcash_moderation_appeals_recordId_key" ON "flipcash_moderation_appeals"("recordId");
+
+-- CreateIndex
+CREATE INDEX "flipcash_moderation_appeals_state_createdAt_idx" ON "flipcash_moderation_appeals"("state", "createdAt");
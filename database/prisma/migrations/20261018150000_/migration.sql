@@ -0,0 +1,27 @@
+-- CreateTable
+CREATE TABLE "flipcash_reports" (
+    "id" TEXT NOT NULL,
+    "reporterId" TEXT NOT NULL,
+    "kind" TEXT NOT NULL,
+    "subjectId" TEXT NOT NULL,
+    "chatId" TEXT,
+    "messageId" BIGINT,
+    "blobId" TEXT,
+    "snapshot" TEXT,
+    "reason" TEXT NOT NULL,
+    "blocked" BOOLEAN NOT NULL,
+    "state" TEXT NOT NULL,
+    "actions" TEXT[],
+    "resolvedBy" TEXT,
+    "note" TEXT NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "resolvedAt" TIMESTAMP(3),
+
+    CONSTRAINT "flipcash_reports_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_reports_state_createdAt_idx" ON "flipcash_reports"("state", "createdAt");
+
+-- CreateIndex
+CREATE INDEX "flipcash_reports_subjectId_createdAt_idx" ON "flipcash_reports"("subjectId", "createdAt");
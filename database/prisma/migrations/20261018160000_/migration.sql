@@ -0,0 +1,3 @@
+-- AlterTable
+ALTER TABLE "flipcash_moderation_audit_records" ADD COLUMN     "chatId" TEXT,
+ADD COLUMN     "messageId" BIGINT;
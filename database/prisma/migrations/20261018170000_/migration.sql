@@ -0,0 +1,18 @@
+-- CreateTable
+CREATE TABLE "flipcash_account_suspensions" (
+    "id" TEXT NOT NULL,
+    "userId" TEXT NOT NULL,
+    "state" TEXT NOT NULL,
+    "reason" TEXT NOT NULL,
+    "suspendedBy" TEXT NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "expiresAt" TIMESTAMP(3),
+    "liftedBy" TEXT,
+    "liftReason" TEXT NOT NULL DEFAULT '',
+    "liftedAt" TIMESTAMP(3),
+
+    CONSTRAINT "flipcash_account_suspensions_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_account_suspensions_userId_createdAt_idx" ON "flipcash_account_suspensions"("userId", "createdAt");
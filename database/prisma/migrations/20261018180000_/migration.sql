@@ -0,0 +1,15 @@
+-- CreateTable
+CREATE TABLE "flipcash_account_deletions" (
+    "userId" TEXT NOT NULL,
+    "state" TEXT NOT NULL,
+    "completedSteps" TEXT[],
+    "requestedAt" TIMESTAMP(3) NOT NULL,
+    "scheduledFor" TIMESTAMP(3) NOT NULL,
+    "cancelledAt" TIMESTAMP(3),
+    "completedAt" TIMESTAMP(3),
+
+    CONSTRAINT "flipcash_account_deletions_pkey" PRIMARY KEY ("userId")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_account_deletions_state_scheduledFor_idx" ON "flipcash_account_deletions"("state", "scheduledFor");
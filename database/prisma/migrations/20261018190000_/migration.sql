@@ -0,0 +1,12 @@
+-- CreateTable
+CREATE TABLE "flipcash_data_exports" (
+    "userId" TEXT NOT NULL,
+    "state" TEXT NOT NULL,
+    "requestedAt" TIMESTAMP(3) NOT NULL,
+    "completedAt" TIMESTAMP(3),
+
+    CONSTRAINT "flipcash_data_exports_pkey" PRIMARY KEY ("userId")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_data_exports_state_requestedAt_idx" ON "flipcash_data_exports"("state", "requestedAt");
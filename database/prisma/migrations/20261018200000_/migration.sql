@@ -0,0 +1,13 @@
+-- DropIndex
+DROP INDEX "flipcash_publickeys_userId_key";
+
+-- AlterTable
+ALTER TABLE "flipcash_publickeys" ADD COLUMN     "isPrimary" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "label" TEXT NOT NULL DEFAULT '',
+ADD COLUMN     "lastUsedAt" TIMESTAMP(3);
+
+-- Every key bound so far is its user's only key
+UPDATE "flipcash_publickeys" SET "isPrimary" = true;
+
+-- CreateIndex
+CREATE INDEX "flipcash_publickeys_userId_idx" ON "flipcash_publickeys"("userId");
@@ -0,0 +1,10 @@
+-- CreateTable
+CREATE TABLE "flipcash_request_nonces" (
+    "nonce" TEXT NOT NULL,
+    "expiresAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "flipcash_request_nonces_pkey" PRIMARY KEY ("nonce")
+);
+
+-- CreateIndex
+CREATE INDEX "flipcash_request_nonces_expiresAt_idx" ON "flipcash_request_nonces"("expiresAt");
@@ -0,0 +1,2 @@
+-- CreateIndex
+CREATE INDEX "flipcash_data_exports_state_completedAt_idx" ON "flipcash_data_exports"("state", "completedAt");
@@ -0,0 +1,4 @@
+-- CreateIndex
+-- A user has at most one primary key. Prisma cannot declare a partial index, so
+-- it is maintained here only.
+CREATE UNIQUE INDEX "flipcash_publickeys_userId_primary_key" ON "flipcash_publickeys"("userId") WHERE "isPrimary";
@@ -0,0 +1,65 @@
+// Package deletion deletes user accounts on request, after a grace period the
+// user can cancel during.
+//
+// The deletion itself is a job (see Worker) that runs a fixed list of Deleters
+// in order, one per subsystem holding the user's data, recording each as it
+// finishes so that a job interrupted by a failure or a restart resumes at the
+// first unfinished step. Every deleter is idempotent, since a step can be
+// rerun after it did its work but before it was recorded.
+//
+// The deleters in this package should be registered in this order:
+//
+//	NewMessageDeleter   (tombstones the user's messages, revoking their media)
+//	NewProfileDeleter   (revokes the profile picture, then clears the profile)
+//	NewContactDeleter
+//	NewPushTokenDeleter
+//	NewSettingsDeleter
+//	NewBlocklistDeleter
+//	NewBadgeDeleter
+//	NewExportDeleter    (removes the data export request and its archive)
+//	NewAccountDeleter   (last: removes the user record and key bindings)
+//
+// Blobs are not deleted directly. Once the message and profile deleters have
+// revoked the grants referencing the user's uploads, blob.Collector collects
+// them like any other unreferenced blob.
+//
+// Some records are retained on purpose, for trust and safety: account
+// suspensions, abuse reports (with their message snapshots), and the
+// moderation audit log. Other users' data that mentions the user, such as
+// their blocklists and contact lists, is theirs and is left alone.
+package deletion
+
+import (
+	"context"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+// Deleter deletes, or anonymizes, one subsystem's data for a user.
+type Deleter interface {
+	// Name identifies the deleter's step in a request's progress. It must be
+	// stable across releases and unique among the registered deleters.
+	Name() string
+
+	// DeleteUserData deletes the user's data. It must be idempotent: it may be
+	// called again after it succeeded, or after it partially failed.
+	DeleteUserData(ctx context.Context, userID *commonpb.UserId) error
+}
+
+// DeleterFunc adapts a function to a Deleter under the given step name.
+func DeleterFunc(name string, fn func(ctx context.Context, userID *commonpb.UserId) error) Deleter {
+	return &funcDeleter{name: name, fn: fn}
+}
+
+type funcDeleter struct {
+	name string
+	fn   func(ctx context.Context, userID *commonpb.UserId) error
+}
+
+func (d *funcDeleter) Name() string {
+	return d.name
+}
+
+func (d *funcDeleter) DeleteUserData(ctx context.Context, userID *commonpb.UserId) error {
+	return d.fn(ctx, userID)
+}
@@ -0,0 +1,202 @@
+package deletion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/account"
+	"github.com/code-payments/flipcash2-server/badge"
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/blocklist"
+	"github.com/code-payments/flipcash2-server/chat"
+	"github.com/code-payments/flipcash2-server/contact"
+	"github.com/code-payments/flipcash2-server/database"
+	"github.com/code-payments/flipcash2-server/export"
+	"github.com/code-payments/flipcash2-server/messaging"
+	"github.com/code-payments/flipcash2-server/profile"
+	"github.com/code-payments/flipcash2-server/push"
+	"github.com/code-payments/flipcash2-server/settings"
+)
+
+// deleterPageSize is how many chats, messages, or blocklist entries a deleter
+// reads per page.
+const deleterPageSize = 100
+
+// Step names. They are persisted in each request's progress, so they must
+// never change.
+const (
+	StepMessages   = "messages"
+	StepProfile    = "profile"
+	StepContacts   = "contacts"
+	StepPushTokens = "push_tokens"
+	StepSettings   = "settings"
+	StepBlocklist  = "blocklist"
+	StepBadge      = "badge"
+	StepExports    = "exports"
+	StepAccount    = "account"
+)
+
+// NewMessageDeleter tombstones every message the user sent in their DMs, on
+// the system's behalf, which also revokes the chats' access to the messages'
+// media. Messages that are not ordinary chat content, such as payment
+// messages, are kept: they record the other member's history too, and carry
+// no content the user wrote.
+func NewMessageDeleter(chats chat.Store, messages messaging.Store, sender *messaging.Sender) Deleter {
+	return DeleterFunc(StepMessages, func(ctx context.Context, userID *commonpb.UserId) error {
+		snapshot := time.Now()
+		for _, chatType := range chat.DmChatTypes() {
+			var cursor *chat.DmFeedCursor
+			for {
+				page, err := chats.GetDmFeedPage(ctx, userID, chatType, snapshot, cursor, deleterPageSize)
+				if err != nil {
+					return err
+				}
+				for _, c := range page {
+					if err := tombstoneSentMessages(ctx, messages, sender, c.ID, userID); err != nil {
+						return err
+					}
+				}
+				if len(page) < deleterPageSize {
+					break
+				}
+				last := page[len(page)-1]
+				cursor = &chat.DmFeedCursor{LastActivity: last.LastActivity, ChatID: last.ID}
+			}
+		}
+		return nil
+	})
+}
+
+func tombstoneSentMessages(ctx context.Context, messages messaging.Store, sender *messaging.Sender, chatID *commonpb.ChatId, userID *commonpb.UserId) error {
+	opts := []database.QueryOption{database.WithAscending(), database.WithLimit(deleterPageSize)}
+	for {
+		page, err := messages.GetMessages(ctx, chatID, opts...)
+		if err != nil {
+			return err
+		}
+		for _, msg := range page {
+			if msg.SenderID == nil || !bytes.Equal(msg.SenderID.Value, userID.Value) {
+				continue
+			}
+			if msg.IsDeleted() || !msg.IsDeletable() {
+				continue
+			}
+			_, err := sender.Tombstone(ctx, chatID, msg.ID)
+			if err != nil && !errors.Is(err, messaging.ErrMessageNotFound) && !errors.Is(err, messaging.ErrMessageNotDeletable) {
+				return err
+			}
+		}
+		if len(page) < deleterPageSize {
+			return nil
+		}
+		opts = append(opts, database.WithPagingToken(messaging.PageTokenFromID(page[len(page)-1].ID)))
+	}
+}
+
+// NewProfileDeleter revokes the public profile's access to the user's profile
+// picture, then clears the profile: display name, picture, phone number, email
+// address, and linked X account.
+func NewProfileDeleter(profiles profile.Store, media profile.Media) Deleter {
+	return DeleterFunc(StepProfile, func(ctx context.Context, userID *commonpb.UserId) error {
+		pictures, err := profiles.GetProfilePictures(ctx, []*commonpb.UserId{userID})
+		if err != nil {
+			return err
+		}
+		if picture, ok := pictures[string(userID.Value)]; ok {
+			if err := media.RevokeProfilePicture(ctx, userID, picture); err != nil {
+				return err
+			}
+		}
+		return profiles.DeleteProfile(ctx, userID)
+	})
+}
+
+// NewContactDeleter deletes the user's uploaded contact list.
+func NewContactDeleter(contacts contact.Store) Deleter {
+	return DeleterFunc(StepContacts, contacts.DeleteContactList)
+}
+
+// NewPushTokenDeleter deletes every push token the user registered.
+func NewPushTokenDeleter(tokens push.TokenStore) Deleter {
+	return DeleterFunc(StepPushTokens, func(ctx context.Context, userID *commonpb.UserId) error {
+		userTokens, err := tokens.GetTokens(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, token := range userTokens {
+			if err := tokens.DeleteToken(ctx, token.Type, token.Token); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// NewSettingsDeleter resets the user's region and locale to the defaults.
+// Where settings live on the user record, the account deleter removes them
+// with it.
+func NewSettingsDeleter(prefs settings.Store) Deleter {
+	return DeleterFunc(StepSettings, func(ctx context.Context, userID *commonpb.UserId) error {
+		err := prefs.SetRegion(ctx, userID, settings.DefaultRegion)
+		if errors.Is(err, settings.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		err = prefs.SetLocale(ctx, userID, settings.DefaultLocale)
+		if errors.Is(err, settings.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// NewBlocklistDeleter empties the user's blocklist.
+func NewBlocklistDeleter(blocklists blocklist.Store) Deleter {
+	return DeleterFunc(StepBlocklist, func(ctx context.Context, userID *commonpb.UserId) error {
+		for {
+			// Each page unblocks its entries, so the next page starts from the top.
+			page, err := blocklists.GetBlocklistPage(ctx, userID, nil, deleterPageSize)
+			if err != nil {
+				return err
+			}
+			for _, entry := range page {
+				if _, err := blocklists.Unblock(ctx, userID, entry.UserID); err != nil {
+					return err
+				}
+			}
+			if len(page) < deleterPageSize {
+				return nil
+			}
+		}
+	})
+}
+
+// NewBadgeDeleter zeroes the user's badge count.
+func NewBadgeDeleter(badges badge.Store) Deleter {
+	return DeleterFunc(StepBadge, badges.Reset)
+}
+
+// NewExportDeleter deletes the user's data export request, then its archive.
+// An export the job is still assembling finds its request gone when it
+// completes, and deletes the archive it wrote itself.
+func NewExportDeleter(exports export.Store, storage blob.ObjectStorage) Deleter {
+	return DeleterFunc(StepExports, func(ctx context.Context, userID *commonpb.UserId) error {
+		if err := exports.DeleteRequest(ctx, userID); err != nil {
+			return err
+		}
+		return export.DeleteArchive(ctx, storage, userID)
+	})
+}
+
+// NewAccountDeleter removes the user record and its key bindings, after which
+// the user's keys no longer authorize anything. It must be registered last:
+// in Postgres the profile and settings live on the user record, and the
+// contact list and X profile reference it.
+func NewAccountDeleter(accounts account.Store) Deleter {
+	return DeleterFunc(StepAccount, accounts.DeleteUser)
+}
@@ -0,0 +1,207 @@
+package deletion_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	chatpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/chat/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	eventpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/event/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+	pushpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/push/v1"
+
+	"github.com/code-payments/flipcash2-server/account"
+	account_memory "github.com/code-payments/flipcash2-server/account/memory"
+	badge_memory "github.com/code-payments/flipcash2-server/badge/memory"
+	"github.com/code-payments/flipcash2-server/blob"
+	blob_memory "github.com/code-payments/flipcash2-server/blob/memory"
+	blocklist_memory "github.com/code-payments/flipcash2-server/blocklist/memory"
+	"github.com/code-payments/flipcash2-server/chat"
+	chat_memory "github.com/code-payments/flipcash2-server/chat/memory"
+	"github.com/code-payments/flipcash2-server/contact"
+	contact_memory "github.com/code-payments/flipcash2-server/contact/memory"
+	"github.com/code-payments/flipcash2-server/deletion"
+	"github.com/code-payments/flipcash2-server/deletion/memory"
+	"github.com/code-payments/flipcash2-server/event"
+	"github.com/code-payments/flipcash2-server/export"
+	export_memory "github.com/code-payments/flipcash2-server/export/memory"
+	"github.com/code-payments/flipcash2-server/messaging"
+	messaging_memory "github.com/code-payments/flipcash2-server/messaging/memory"
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/profile"
+	profile_memory "github.com/code-payments/flipcash2-server/profile/memory"
+	"github.com/code-payments/flipcash2-server/push"
+	push_memory "github.com/code-payments/flipcash2-server/push/memory"
+	"github.com/code-payments/flipcash2-server/settings"
+	settings_memory "github.com/code-payments/flipcash2-server/settings/memory"
+	ocp_data "github.com/code-payments/ocp-server/ocp/data"
+)
+
+func TestDeleters_Cascade(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	accounts := account_memory.NewInMemory()
+	profiles := profile_memory.NewInMemory()
+	contacts := contact_memory.NewInMemory()
+	tokens := push_memory.NewInMemory()
+	prefs := settings_memory.NewInMemory()
+	blocklists := blocklist_memory.NewInMemory()
+	badges := badge_memory.NewInMemory()
+	chats := chat_memory.NewInMemory()
+	messages := messaging_memory.NewInMemory()
+	exports := export_memory.NewInMemory()
+	access := blob_memory.NewInMemoryAccessStore()
+	storage := blob_memory.NewInMemoryStorage()
+	media := blob.NewIntegration(blob_memory.NewInMemory(), storage, access)
+	bus := event.NewBus[*commonpb.UserId, *eventpb.Event]()
+	sender := messaging.NewSender(log, badges, chats, messages, profiles, blocklists, media, ocp_data.NewTestDataProvider(), push.NewNoOpPusher(), bus)
+
+	addUser := func() (*commonpb.UserId, *commonpb.PublicKey) {
+		userID := model.MustGenerateUserID()
+		pubKey := model.MustGenerateKeyPair().Proto()
+		_, err := accounts.Bind(ctx, userID, pubKey)
+		require.NoError(t, err)
+		return userID, pubKey
+	}
+	user, pubKey := addUser()
+	other, _ := addUser()
+
+	// Give the user data in every subsystem
+	require.NoError(t, accounts.SetRegistrationFlag(ctx, user, true))
+
+	picture := blob.MustGenerateID()
+	require.NoError(t, access.Grant(ctx, &blob.Grant{BlobID: picture, Principal: blob.PrincipalForProfile(user), Permission: blob.PermissionRead}))
+	require.NoError(t, profiles.SetProfilePicture(ctx, user, picture))
+	require.NoError(t, profiles.SetDisplayName(ctx, user, "name"))
+	require.NoError(t, profiles.LinkPhoneNumber(ctx, user, "+12223334444", &commonpb.Hash{Value: []byte("phone-hash")}))
+	require.NoError(t, profiles.LinkEmailAddress(ctx, user, "someone@gmail.com"))
+
+	contactHash := &commonpb.Hash{Value: []byte("contact-hash")}
+	require.NoError(t, contacts.Replace(ctx, user, []*commonpb.Hash{contactHash}, contactHash))
+	require.NoError(t, tokens.AddToken(ctx, user, &commonpb.AppInstallId{Value: "install"}, pushpb.TokenType_FCM_APNS, "token"))
+	require.NoError(t, prefs.SetRegion(ctx, user, &commonpb.Region{Value: "cad"}))
+	_, err := blocklists.Block(ctx, user, other, time.Now())
+	require.NoError(t, err)
+	_, err = badges.Increment(ctx, user, 3)
+	require.NoError(t, err)
+	require.NoError(t, exports.CreateRequest(ctx, export.NewRequest(user)))
+	require.NoError(t, exports.CompleteRequest(ctx, user, time.Now()))
+	require.NoError(t, storage.PutOrigin(ctx, export.ArchiveKey(user), export.ArchiveMimeType, []byte("archive")))
+
+	chatID := &commonpb.ChatId{Value: append(append([]byte{}, user.Value...), other.Value...)}
+	require.NoError(t, chats.PutChat(ctx, &chat.Chat{
+		ID:           chatID,
+		Type:         chatpb.ChatType_CONTACT_DM,
+		Members:      []*commonpb.UserId{user, other},
+		LastActivity: time.Now(),
+	}))
+	putMessage := func(senderID *commonpb.UserId, text string) *messaging.Message {
+		clientID := make([]byte, 16)
+		_, err := rand.Read(clientID)
+		require.NoError(t, err)
+		content := []*messagingpb.Content{{
+			Type: &messagingpb.Content_Text{Text: &messagingpb.TextContent{Text: text}},
+		}}
+		msg, _, err := messages.PutMessage(ctx, chatID, senderID, content, time.Now().UTC(), &messagingpb.ClientMessageId{Value: clientID}, true)
+		require.NoError(t, err)
+		return msg
+	}
+	sent := putMessage(user, "from the user")
+	received := putMessage(other, "to the user")
+
+	deleters := []deletion.Deleter{
+		deletion.NewMessageDeleter(chats, messages, sender),
+		deletion.NewProfileDeleter(profiles, media),
+		deletion.NewContactDeleter(contacts),
+		deletion.NewPushTokenDeleter(tokens),
+		deletion.NewSettingsDeleter(prefs),
+		deletion.NewBlocklistDeleter(blocklists),
+		deletion.NewBadgeDeleter(badges),
+		deletion.NewExportDeleter(exports, storage),
+		deletion.NewAccountDeleter(accounts),
+	}
+	store := memory.NewInMemory()
+	worker := deletion.NewWorker(log, store, deleters)
+	require.NoError(t, store.CreateRequest(ctx, deletion.NewRequest(user, 0)))
+
+	completed, err := worker.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, completed)
+
+	// Every deleter is idempotent, so a step retried after a crash is harmless
+	for _, deleter := range deleters {
+		require.NoError(t, deleter.DeleteUserData(ctx, user), deleter.Name())
+	}
+
+	request, err := store.GetRequest(ctx, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StateCompleted, request.State)
+	require.Len(t, request.CompletedSteps, len(deleters))
+
+	// Messages
+	msg, err := messages.GetMessage(ctx, chatID, sent.ID)
+	require.NoError(t, err)
+	require.True(t, msg.IsDeleted())
+	msg, err = messages.GetMessage(ctx, chatID, received.ID)
+	require.NoError(t, err)
+	require.False(t, msg.IsDeleted())
+
+	// Profile
+	_, err = profiles.GetProfile(ctx, user, true)
+	require.ErrorIs(t, err, profile.ErrNotFound)
+	granted, err := access.HasAnyGrant(ctx, picture)
+	require.NoError(t, err)
+	require.False(t, granted)
+
+	// Contacts
+	_, err = contacts.GetChecksum(ctx, user)
+	require.ErrorIs(t, err, contact.ErrNotFound)
+
+	// Push tokens
+	userTokens, err := tokens.GetTokens(ctx, user)
+	require.NoError(t, err)
+	require.Empty(t, userTokens)
+
+	// Settings
+	userSettings, err := prefs.GetSettings(ctx, user)
+	require.NoError(t, err)
+	require.Equal(t, settings.DefaultRegion.Value, userSettings.Region.Value)
+	require.Equal(t, settings.DefaultLocale.Value, userSettings.Locale.Value)
+
+	// Blocklist
+	blockedCount, err := blocklists.GetBlockedCount(ctx, user)
+	require.NoError(t, err)
+	require.Zero(t, blockedCount)
+	isBlocked, err := blocklists.IsBlocked(ctx, user, other)
+	require.NoError(t, err)
+	require.False(t, isBlocked)
+
+	// Badge
+	count, err := badges.Get(ctx, user)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Data export
+	_, err = exports.GetRequest(ctx, user)
+	require.ErrorIs(t, err, export.ErrRequestNotFound)
+	_, err = storage.GetOrigin(ctx, export.ArchiveKey(user))
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+
+	// Account
+	_, err = accounts.GetUserId(ctx, pubKey)
+	require.ErrorIs(t, err, account.ErrNotFound)
+	isRegistered, err := accounts.IsRegistered(ctx, user)
+	require.NoError(t, err)
+	require.False(t, isRegistered)
+
+	// The other user is untouched
+	pubKeys, err := accounts.GetPubKeys(ctx, other)
+	require.NoError(t, err)
+	require.Len(t, pubKeys, 1)
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/deletion"
+)
+
+type store struct {
+	sync.Mutex
+
+	// maps a userID to its deletion request
+	requests map[string]*deletion.Request
+}
+
+// NewInMemory returns an in-memory deletion.Store.
+func NewInMemory() deletion.Store {
+	return &store{
+		requests: make(map[string]*deletion.Request),
+	}
+}
+
+func (s *store) reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.requests = make(map[string]*deletion.Request)
+}
+
+func (s *store) CreateRequest(_ context.Context, request *deletion.Request) error {
+	s.Lock()
+	defer s.Unlock()
+
+	existing, ok := s.requests[string(request.UserID.Value)]
+	if ok && existing.State != deletion.StateCancelled {
+		return deletion.ErrRequestExists
+	}
+	s.requests[string(request.UserID.Value)] = request.Clone()
+	return nil
+}
+
+func (s *store) GetRequest(_ context.Context, userID *commonpb.UserId) (*deletion.Request, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return nil, deletion.ErrRequestNotFound
+	}
+	return request.Clone(), nil
+}
+
+func (s *store) CancelRequest(_ context.Context, userID *commonpb.UserId, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return deletion.ErrRequestNotFound
+	}
+	if request.State != deletion.StatePending {
+		return deletion.ErrNotPending
+	}
+	request.State = deletion.StateCancelled
+	request.CancelledAt = at
+	return nil
+}
+
+func (s *store) GetDueRequests(_ context.Context, asOf time.Time, limit int) ([]*deletion.Request, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*deletion.Request, 0)
+	for _, request := range s.requests {
+		switch {
+		case request.State == deletion.StateInProgress,
+			request.State == deletion.StatePending && !request.ScheduledFor.After(asOf):
+			res = append(res, request.Clone())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].ScheduledFor.Before(res[j].ScheduledFor) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) StartRequest(_ context.Context, userID *commonpb.UserId) error {
+	s.Lock()
+	defer s.Unlock()
+
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return deletion.ErrRequestNotFound
+	}
+	switch request.State {
+	case deletion.StatePending:
+		request.State = deletion.StateInProgress
+		return nil
+	case deletion.StateInProgress:
+		return nil
+	default:
+		return deletion.ErrNotPending
+	}
+}
+
+func (s *store) CompleteStep(_ context.Context, userID *commonpb.UserId, step string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	request, err := s.getInProgress(userID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(request.CompletedSteps, step) {
+		request.CompletedSteps = append(request.CompletedSteps, step)
+	}
+	return nil
+}
+
+func (s *store) CompleteRequest(_ context.Context, userID *commonpb.UserId, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	request, err := s.getInProgress(userID)
+	if err != nil {
+		return err
+	}
+	request.State = deletion.StateCompleted
+	request.CompletedAt = at
+	return nil
+}
+
+func (s *store) getInProgress(userID *commonpb.UserId) (*deletion.Request, error) {
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return nil, deletion.ErrRequestNotFound
+	}
+	if request.State != deletion.StateInProgress {
+		return nil, deletion.ErrNotInProgress
+	}
+	return request, nil
+}
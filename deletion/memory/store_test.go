@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/code-payments/flipcash2-server/deletion/tests"
+)
+
+func TestDeletion_MemoryStore(t *testing.T) {
+	testStore := NewInMemory()
+	teardown := func() {
+		testStore.(*store).reset()
+	}
+	tests.RunStoreTests(t, testStore, teardown)
+}
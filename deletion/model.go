@@ -0,0 +1,74 @@
+package deletion
+
+import (
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+// State is where a deletion request is in its lifecycle.
+type State string
+
+const (
+	// StatePending is a request waiting out its grace period. The user may
+	// still cancel it.
+	StatePending State = "pending"
+
+	// StateCancelled is a request the user cancelled during its grace period.
+	// The user may request deletion again afterwards.
+	StateCancelled State = "cancelled"
+
+	// StateInProgress is a request whose deleters are running. It can no
+	// longer be cancelled.
+	StateInProgress State = "in_progress"
+
+	// StateCompleted is a request every deleter has finished.
+	StateCompleted State = "completed"
+)
+
+// Request is a user's request to delete their account, and the job's progress
+// through it. There is at most one request per user.
+type Request struct {
+	UserID *commonpb.UserId
+
+	State State
+
+	RequestedAt time.Time
+
+	// ScheduledFor is when the grace period ends and the job may start.
+	ScheduledFor time.Time
+
+	// CompletedSteps are the names of the deleters that have finished, in the
+	// order they finished. A resumed job skips them.
+	CompletedSteps []string
+
+	CancelledAt time.Time
+	CompletedAt time.Time
+}
+
+// NewRequest returns a pending request for userID, made now and scheduled
+// after gracePeriod.
+func NewRequest(userID *commonpb.UserId, gracePeriod time.Duration) *Request {
+	now := time.Now()
+	return &Request{
+		UserID:       userID,
+		State:        StatePending,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(gracePeriod),
+	}
+}
+
+// HasCompletedStep reports whether the named deleter has finished.
+func (r *Request) HasCompletedStep(step string) bool {
+	return slices.Contains(r.CompletedSteps, step)
+}
+
+func (r *Request) Clone() *Request {
+	cloned := *r
+	cloned.UserID = proto.Clone(r.UserID).(*commonpb.UserId)
+	cloned.CompletedSteps = slices.Clone(r.CompletedSteps)
+	return &cloned
+}
@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/deletion"
+
+	pg "github.com/code-payments/flipcash2-server/database/postgres"
+)
+
+const (
+	deletionsTableName = "flipcash_account_deletions"
+	allDeletionFields  = `"userId", "state", "completedSteps", "requestedAt", "scheduledFor", "cancelledAt", "completedAt"`
+)
+
+type deletionModel struct {
+	UserID         string     `db:"userId"`
+	State          string     `db:"state"`
+	CompletedSteps []string   `db:"completedSteps"`
+	RequestedAt    time.Time  `db:"requestedAt"`
+	ScheduledFor   time.Time  `db:"scheduledFor"`
+	CancelledAt    *time.Time `db:"cancelledAt"`
+	CompletedAt    *time.Time `db:"completedAt"`
+}
+
+func toDeletionModel(r *deletion.Request) *deletionModel {
+	return &deletionModel{
+		UserID:       pg.Encode(r.UserID.Value),
+		State:        string(r.State),
+		RequestedAt:  r.RequestedAt.UTC(),
+		ScheduledFor: r.ScheduledFor.UTC(),
+	}
+}
+
+func fromDeletionModel(m *deletionModel) (*deletion.Request, error) {
+	userID, err := pg.Decode(m.UserID)
+	if err != nil {
+		return nil, err
+	}
+	r := &deletion.Request{
+		UserID:         &commonpb.UserId{Value: userID},
+		State:          deletion.State(m.State),
+		RequestedAt:    m.RequestedAt,
+		ScheduledFor:   m.ScheduledFor,
+		CompletedSteps: m.CompletedSteps,
+	}
+	if m.CancelledAt != nil {
+		r.CancelledAt = *m.CancelledAt
+	}
+	if m.CompletedAt != nil {
+		r.CompletedAt = *m.CompletedAt
+	}
+	return r, nil
+}
+
+func (m *deletionModel) dbCreate(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + deletionsTableName + `(` + allDeletionFields + `)
+			VALUES ($1, $2, '{}', $3, $4, NULL, NULL)
+			ON CONFLICT ("userId") DO UPDATE
+				SET "state" = $2, "completedSteps" = '{}', "requestedAt" = $3, "scheduledFor" = $4, "cancelledAt" = NULL, "completedAt" = NULL
+				WHERE ` + deletionsTableName + `."state" = $5`
+		tag, err := tx.Exec(
+			ctx,
+			query,
+			m.UserID,
+			m.State,
+			m.RequestedAt,
+			m.ScheduledFor,
+			string(deletion.StateCancelled),
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return deletion.ErrRequestExists
+		}
+		return nil
+	})
+}
+
+func dbGetRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) (*deletionModel, error) {
+	res := &deletionModel{}
+	query := `SELECT ` + allDeletionFields + ` FROM ` + deletionsTableName + `
+		WHERE "userId" = $1`
+	err := pgxscan.Get(ctx, pool, res, query, pg.Encode(userID.Value))
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, deletion.ErrRequestNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbCancelRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, at time.Time) error {
+	query := `UPDATE ` + deletionsTableName + `
+		SET "state" = $3, "cancelledAt" = $4
+		WHERE "userId" = $1 AND "state" = $2`
+	return dbTransition(ctx, pool, userID, deletion.ErrNotPending, query, string(deletion.StatePending), string(deletion.StateCancelled), at.UTC())
+}
+
+func dbGetDueRequests(ctx context.Context, pool *pgxpool.Pool, asOf time.Time, limit int) ([]*deletionModel, error) {
+	var res []*deletionModel
+	query := `SELECT ` + allDeletionFields + ` FROM ` + deletionsTableName + `
+		WHERE ("state" = $1 AND "scheduledFor" <= $2) OR "state" = $3
+		ORDER BY "scheduledFor" ASC
+		LIMIT $4`
+	err := pgxscan.Select(
+		ctx,
+		pool,
+		&res,
+		query,
+		string(deletion.StatePending),
+		asOf.UTC(),
+		string(deletion.StateInProgress),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbStartRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) error {
+	query := `UPDATE ` + deletionsTableName + `
+		SET "state" = $3
+		WHERE "userId" = $1 AND "state" IN ($2, $3)`
+	return dbTransition(ctx, pool, userID, deletion.ErrNotPending, query, string(deletion.StatePending), string(deletion.StateInProgress))
+}
+
+func dbCompleteStep(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, step string) error {
+	query := `UPDATE ` + deletionsTableName + `
+		SET "completedSteps" = CASE WHEN $3 = ANY("completedSteps") THEN "completedSteps" ELSE array_append("completedSteps", $3) END
+		WHERE "userId" = $1 AND "state" = $2`
+	return dbTransition(ctx, pool, userID, deletion.ErrNotInProgress, query, string(deletion.StateInProgress), step)
+}
+
+func dbCompleteRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, at time.Time) error {
+	query := `UPDATE ` + deletionsTableName + `
+		SET "state" = $3, "completedAt" = $4
+		WHERE "userId" = $1 AND "state" = $2`
+	return dbTransition(ctx, pool, userID, deletion.ErrNotInProgress, query, string(deletion.StateInProgress), string(deletion.StateCompleted), at.UTC())
+}
+
+// dbTransition runs an update conditioned on the request's state, whose first
+// argument is the user. When it matches no row, it tells a request in the wrong
+// state (wrongState) from a missing one.
+func dbTransition(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, wrongState error, query string, args ...any) error {
+	encodedUserID := pg.Encode(userID.Value)
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, query, append([]any{encodedUserID}, args...)...)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+
+		var exists bool
+		query := `SELECT EXISTS (SELECT 1 FROM ` + deletionsTableName + ` WHERE "userId" = $1)`
+		if err := tx.QueryRow(ctx, query, encodedUserID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return wrongState
+		}
+		return deletion.ErrRequestNotFound
+	})
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/deletion"
+)
+
+type store struct {
+	pool *pgxpool.Pool
+}
+
+// NewInPostgres returns a deletion.Store backed by Postgres.
+func NewInPostgres(pool *pgxpool.Pool) deletion.Store {
+	return &store{
+		pool: pool,
+	}
+}
+
+func (s *store) CreateRequest(ctx context.Context, request *deletion.Request) error {
+	return toDeletionModel(request).dbCreate(ctx, s.pool)
+}
+
+func (s *store) GetRequest(ctx context.Context, userID *commonpb.UserId) (*deletion.Request, error) {
+	model, err := dbGetRequest(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	return fromDeletionModel(model)
+}
+
+func (s *store) CancelRequest(ctx context.Context, userID *commonpb.UserId, at time.Time) error {
+	return dbCancelRequest(ctx, s.pool, userID, at)
+}
+
+func (s *store) GetDueRequests(ctx context.Context, asOf time.Time, limit int) ([]*deletion.Request, error) {
+	models, err := dbGetDueRequests(ctx, s.pool, asOf, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*deletion.Request, len(models))
+	for i, model := range models {
+		res[i], err = fromDeletionModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) StartRequest(ctx context.Context, userID *commonpb.UserId) error {
+	return dbStartRequest(ctx, s.pool, userID)
+}
+
+func (s *store) CompleteStep(ctx context.Context, userID *commonpb.UserId, step string) error {
+	return dbCompleteStep(ctx, s.pool, userID, step)
+}
+
+func (s *store) CompleteRequest(ctx context.Context, userID *commonpb.UserId, at time.Time) error {
+	return dbCompleteRequest(ctx, s.pool, userID, at)
+}
+
+func (s *store) reset() {
+	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+deletionsTableName)
+	if err != nil {
+		panic(err)
+	}
+}
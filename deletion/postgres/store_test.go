@@ -0,0 +1,27 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/deletion/tests"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestDeletion_PostgresStore(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), testEnv.DatabaseUrl)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	testStore := NewInPostgres(pool)
+	teardown := func() {
+		testStore.(*store).reset()
+	}
+	tests.RunStoreTests(t, testStore, teardown)
+}
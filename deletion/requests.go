@@ -0,0 +1,49 @@
+package deletion
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+)
+
+// The requests below are for the Server methods backing unpublished Account
+// RPCs, each signed over its Payload (see auth.NewPayload).
+
+// RequestDeletionRequest schedules deletion of the caller's account.
+type RequestDeletionRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *RequestDeletionRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "RequestDeletion")
+}
+
+// CancelDeletionRequest cancels the caller's pending deletion request.
+type CancelDeletionRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *CancelDeletionRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "CancelDeletion")
+}
+
+// GetDeletionRequest asks for the caller's deletion request.
+type GetDeletionRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *GetDeletionRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "GetDeletion")
+}
+
+// requestPayload is the signed payload of an unpublished Account request.
+func requestPayload(ts time.Time, method string, args ...string) proto.Message {
+	return auth.NewPayload("flipcash.account.v1.Account/"+method, ts, args...)
+}
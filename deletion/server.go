@@ -0,0 +1,122 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// defaultGracePeriod is how long a request waits before the job starts on it,
+// during which the user can change their mind and cancel it.
+const defaultGracePeriod = 30 * 24 * time.Hour
+
+// Server is the self-service side of account deletion: users request deletion
+// of their own account, check on it, and cancel it during the grace period.
+type Server struct {
+	log   *zap.Logger
+	authz auth.Authorizer
+	store Store
+
+	gracePeriod time.Duration
+}
+
+type ServerOption func(*Server)
+
+// WithGracePeriod overrides how long a request waits before the job starts on
+// it.
+func WithGracePeriod(gracePeriod time.Duration) ServerOption {
+	return func(s *Server) {
+		s.gracePeriod = gracePeriod
+	}
+}
+
+func NewServer(log *zap.Logger, authz auth.Authorizer, store Store, opts ...ServerOption) *Server {
+	s := &Server{
+		log:   log,
+		authz: authz,
+		store: store,
+
+		gracePeriod: defaultGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// RequestDeletion schedules deletion of the caller's account after the grace
+// period. It is idempotent: while a request is pending or in progress, that
+// request is returned unchanged.
+func (s *Server) RequestDeletion(ctx context.Context, req *RequestDeletionRequest) (*Request, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(zap.String("user_id", model.UserIDString(caller)))
+
+	request := NewRequest(caller, s.gracePeriod)
+	err = s.store.CreateRequest(ctx, request)
+	if errors.Is(err, ErrRequestExists) {
+		existing, err := s.store.GetRequest(ctx, caller)
+		if err != nil {
+			log.Warn("Failed to get deletion request", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to request deletion")
+		}
+		return existing, nil
+	} else if err != nil {
+		log.Warn("Failed to create deletion request", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to request deletion")
+	}
+	log.Info("Account deletion requested", zap.Time("scheduled_for", request.ScheduledFor))
+	return request, nil
+}
+
+// CancelDeletion cancels the caller's pending deletion request. Once the job
+// has started on it, it can no longer be cancelled.
+func (s *Server) CancelDeletion(ctx context.Context, req *CancelDeletionRequest) error {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return err
+	}
+
+	log := s.log.With(zap.String("user_id", model.UserIDString(caller)))
+
+	err = s.store.CancelRequest(ctx, caller, time.Now())
+	switch {
+	case errors.Is(err, ErrRequestNotFound):
+		return status.Error(codes.NotFound, "deletion not requested")
+	case errors.Is(err, ErrNotPending):
+		return status.Error(codes.FailedPrecondition, "deletion not pending")
+	case err != nil:
+		log.Warn("Failed to cancel deletion request", zap.Error(err))
+		return status.Error(codes.Internal, "failed to cancel deletion")
+	}
+	log.Info("Account deletion cancelled")
+	return nil
+}
+
+// GetDeletion returns the caller's deletion request, including a cancelled
+// one.
+func (s *Server) GetDeletion(ctx context.Context, req *GetDeletionRequest) (*Request, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := s.store.GetRequest(ctx, caller)
+	if errors.Is(err, ErrRequestNotFound) {
+		return nil, status.Error(codes.NotFound, "deletion not requested")
+	} else if err != nil {
+		s.log.Warn("Failed to get deletion request", zap.String("user_id", model.UserIDString(caller)), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get deletion")
+	}
+	return request, nil
+}
@@ -0,0 +1,63 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+var (
+	// ErrRequestNotFound is returned when the user has no deletion request.
+	ErrRequestNotFound = errors.New("deletion request not found")
+
+	// ErrRequestExists is returned by Store.CreateRequest when the user already
+	// has a request that was not cancelled.
+	ErrRequestExists = errors.New("deletion request already exists")
+
+	// ErrNotPending is returned when a request has moved past the state the
+	// operation requires: cancelling a request that is no longer pending, or
+	// starting one that was cancelled or has completed.
+	ErrNotPending = errors.New("deletion request not pending")
+
+	// ErrNotInProgress is returned when recording progress on a request that is
+	// not in progress.
+	ErrNotInProgress = errors.New("deletion request not in progress")
+)
+
+// Store persists deletion requests and the job's progress through them.
+type Store interface {
+	// CreateRequest records a pending request. It replaces a cancelled request
+	// for the same user, and returns ErrRequestExists for any other.
+	CreateRequest(ctx context.Context, request *Request) error
+
+	// GetRequest returns the user's request, or ErrRequestNotFound.
+	GetRequest(ctx context.Context, userID *commonpb.UserId) (*Request, error)
+
+	// CancelRequest cancels the user's pending request at the given time. It
+	// returns ErrRequestNotFound if there is no request, and ErrNotPending if it
+	// is no longer pending.
+	CancelRequest(ctx context.Context, userID *commonpb.UserId, at time.Time) error
+
+	// GetDueRequests returns up to limit requests the job should work on, oldest
+	// schedule first: pending requests scheduled at or before asOf, and requests
+	// already in progress.
+	GetDueRequests(ctx context.Context, asOf time.Time, limit int) ([]*Request, error)
+
+	// StartRequest moves the user's pending request in progress. It is a no-op
+	// for a request already in progress. It returns ErrRequestNotFound if there
+	// is no request, and ErrNotPending if it was cancelled or has completed.
+	StartRequest(ctx context.Context, userID *commonpb.UserId) error
+
+	// CompleteStep records that the named deleter finished for the user's
+	// request in progress. Recording a step twice is a no-op. It returns
+	// ErrRequestNotFound if there is no request, and ErrNotInProgress if it is
+	// not in progress.
+	CompleteStep(ctx context.Context, userID *commonpb.UserId, step string) error
+
+	// CompleteRequest marks the user's request in progress completed at the
+	// given time. It returns ErrRequestNotFound if there is no request, and
+	// ErrNotInProgress if it is not in progress.
+	CompleteRequest(ctx context.Context, userID *commonpb.UserId, at time.Time) error
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/deletion"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+func RunStoreTests(t *testing.T, s deletion.Store, teardown func()) {
+	for _, tf := range []func(t *testing.T, s deletion.Store){
+		testStore_lifecycle,
+		testStore_cancellation,
+		testStore_dueRequests,
+	} {
+		tf(t, s)
+		teardown()
+	}
+}
+
+func testStore_lifecycle(t *testing.T, s deletion.Store) {
+	ctx := context.Background()
+
+	user := model.MustGenerateUserID()
+
+	_, err := s.GetRequest(ctx, user)
+	require.ErrorIs(t, err, deletion.ErrRequestNotFound)
+	require.ErrorIs(t, s.StartRequest(ctx, user), deletion.ErrRequestNotFound)
+	require.ErrorIs(t, s.CompleteStep(ctx, user, "profile"), deletion.ErrRequestNotFound)
+	require.ErrorIs(t, s.CompleteRequest(ctx, user, time.Now()), deletion.ErrRequestNotFound)
+
+	request := newRequest(user, time.Hour)
+	require.NoError(t, s.CreateRequest(ctx, request))
+	require.ErrorIs(t, s.CreateRequest(ctx, newRequest(user, time.Hour)), deletion.ErrRequestExists)
+
+	actual, err := s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, request, actual)
+
+	// Progress is only recorded once the request is in progress
+	require.ErrorIs(t, s.CompleteStep(ctx, user, "profile"), deletion.ErrNotInProgress)
+	require.ErrorIs(t, s.CompleteRequest(ctx, user, time.Now()), deletion.ErrNotInProgress)
+
+	require.NoError(t, s.StartRequest(ctx, user))
+	require.NoError(t, s.StartRequest(ctx, user))
+
+	require.NoError(t, s.CompleteStep(ctx, user, "profile"))
+	require.NoError(t, s.CompleteStep(ctx, user, "contacts"))
+	require.NoError(t, s.CompleteStep(ctx, user, "profile"))
+
+	request.State = deletion.StateInProgress
+	request.CompletedSteps = []string{"profile", "contacts"}
+	actual, err = s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, request, actual)
+
+	// A request in progress can neither be cancelled nor replaced
+	require.ErrorIs(t, s.CancelRequest(ctx, user, time.Now()), deletion.ErrNotPending)
+	require.ErrorIs(t, s.CreateRequest(ctx, newRequest(user, 0)), deletion.ErrRequestExists)
+
+	completedAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.CompleteRequest(ctx, user, completedAt))
+	require.ErrorIs(t, s.CompleteRequest(ctx, user, time.Now()), deletion.ErrNotInProgress)
+	require.ErrorIs(t, s.CompleteStep(ctx, user, "badge"), deletion.ErrNotInProgress)
+	require.ErrorIs(t, s.StartRequest(ctx, user), deletion.ErrNotPending)
+	require.ErrorIs(t, s.CreateRequest(ctx, newRequest(user, 0)), deletion.ErrRequestExists)
+
+	request.State = deletion.StateCompleted
+	request.CompletedAt = completedAt
+	actual, err = s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, request, actual)
+}
+
+func testStore_cancellation(t *testing.T, s deletion.Store) {
+	ctx := context.Background()
+
+	user := model.MustGenerateUserID()
+
+	require.ErrorIs(t, s.CancelRequest(ctx, user, time.Now()), deletion.ErrRequestNotFound)
+
+	request := newRequest(user, time.Hour)
+	require.NoError(t, s.CreateRequest(ctx, request))
+
+	cancelledAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.CancelRequest(ctx, user, cancelledAt))
+	require.ErrorIs(t, s.CancelRequest(ctx, user, time.Now()), deletion.ErrNotPending)
+	require.ErrorIs(t, s.StartRequest(ctx, user), deletion.ErrNotPending)
+
+	request.State = deletion.StateCancelled
+	request.CancelledAt = cancelledAt
+	actual, err := s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, request, actual)
+
+	// A cancelled request is replaced by a new one
+	renewed := newRequest(user, 2*time.Hour)
+	require.NoError(t, s.CreateRequest(ctx, renewed))
+
+	actual, err = s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, renewed, actual)
+}
+
+func testStore_dueRequests(t *testing.T, s deletion.Store) {
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Millisecond)
+
+	due, err := s.GetDueRequests(ctx, now, 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	scheduled := func(offset time.Duration) *deletion.Request {
+		request := newRequest(model.MustGenerateUserID(), 0)
+		request.ScheduledFor = now.Add(offset)
+		require.NoError(t, s.CreateRequest(ctx, request))
+		return request
+	}
+
+	older := scheduled(-2 * time.Hour)
+	newer := scheduled(-time.Hour)
+	exact := scheduled(0)
+	future := scheduled(time.Hour)
+	cancelled := scheduled(-3 * time.Hour)
+	completed := scheduled(-4 * time.Hour)
+	started := scheduled(-30 * time.Minute)
+
+	require.NoError(t, s.CancelRequest(ctx, cancelled.UserID, now))
+	require.NoError(t, s.StartRequest(ctx, completed.UserID))
+	require.NoError(t, s.CompleteRequest(ctx, completed.UserID, now))
+	require.NoError(t, s.StartRequest(ctx, started.UserID))
+
+	due, err = s.GetDueRequests(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 4)
+	require.Equal(t, older.UserID.Value, due[0].UserID.Value)
+	require.Equal(t, newer.UserID.Value, due[1].UserID.Value)
+	require.Equal(t, started.UserID.Value, due[2].UserID.Value)
+	require.Equal(t, deletion.StateInProgress, due[2].State)
+	require.Equal(t, exact.UserID.Value, due[3].UserID.Value)
+
+	due, err = s.GetDueRequests(ctx, now, 2)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	require.Equal(t, older.UserID.Value, due[0].UserID.Value)
+
+	// A request in progress stays due whatever its schedule
+	due, err = s.GetDueRequests(ctx, now.Add(-3*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, started.UserID.Value, due[0].UserID.Value)
+
+	due, err = s.GetDueRequests(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 5)
+	require.Equal(t, future.UserID.Value, due[4].UserID.Value)
+}
+
+func newRequest(userID *commonpb.UserId, gracePeriod time.Duration) *deletion.Request {
+	request := deletion.NewRequest(userID, gracePeriod)
+	request.RequestedAt = request.RequestedAt.Truncate(time.Millisecond)
+	request.ScheduledFor = request.ScheduledFor.Truncate(time.Millisecond)
+	return request
+}
+
+func assertEquivalentRequests(t *testing.T, expected, actual *deletion.Request) {
+	require.Equal(t, expected.UserID.Value, actual.UserID.Value)
+	require.Equal(t, expected.State, actual.State)
+	require.True(t, expected.RequestedAt.Equal(actual.RequestedAt))
+	require.True(t, expected.ScheduledFor.Equal(actual.ScheduledFor))
+	require.Equal(t, len(expected.CompletedSteps), len(actual.CompletedSteps))
+	for i := range expected.CompletedSteps {
+		require.Equal(t, expected.CompletedSteps[i], actual.CompletedSteps[i])
+	}
+	require.True(t, expected.CancelledAt.Equal(actual.CancelledAt))
+	require.True(t, expected.CompletedAt.Equal(actual.CompletedAt))
+}
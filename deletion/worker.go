@@ -0,0 +1,144 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/poll"
+
+	"github.com/code-payments/ocp-server/metrics"
+	"github.com/code-payments/ocp-server/metrics/noop"
+)
+
+// defaultWorkerBatchSize is how many due requests one tick pulls from the store.
+const defaultWorkerBatchSize = 10
+
+// Worker runs the deletion job: it picks up each request whose grace period
+// has elapsed, moves it in progress, and runs every registered deleter it has
+// not yet completed, in order, recording each as it finishes. A deleter that
+// fails stops the request where it is, and the next tick resumes it there.
+//
+// It is safe to run on every server instance: two instances working on the
+// same request run the same idempotent deleters, and recording a step twice is
+// a no-op.
+type Worker struct {
+	log      *zap.Logger
+	store    Store
+	deleters []Deleter
+
+	batchSize int
+}
+
+// WorkerOption overrides one of the worker's knobs.
+type WorkerOption func(*Worker)
+
+// WithWorkerBatchSize overrides how many due requests one tick pulls from the
+// store.
+func WithWorkerBatchSize(n int) WorkerOption {
+	return func(w *Worker) { w.batchSize = n }
+}
+
+// NewWorker returns a Worker running deleters, in order, for each due request.
+func NewWorker(log *zap.Logger, store Store, deleters []Deleter, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		log:      log,
+		store:    store,
+		deleters: deleters,
+
+		batchSize: defaultWorkerBatchSize,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start satisfies the OCP worker.Runtime interface: it polls for due requests
+// every interval until ctx is cancelled (see poll.Loop), whose error it
+// returns.
+func (w *Worker) Start(ctx context.Context, interval time.Duration) error {
+	return poll.Loop(ctx, interval, func(ctx context.Context) bool {
+		completed, err := w.Process(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			w.log.Warn("Failed to process deletion requests", zap.Error(err))
+		}
+		return completed == w.batchSize
+	})
+}
+
+// Process runs one tick over the due requests and reports how many it
+// completed. Zero means nothing is due, or nothing due could be completed.
+func (w *Worker) Process(runtimeCtx context.Context) (int, error) {
+	metricsProvider, ok := runtimeCtx.Value(metrics.ProviderContextKey).(metrics.Provider)
+	if !ok || metricsProvider == nil {
+		metricsProvider = noop.NewProvider()
+	}
+	trace := metricsProvider.StartTrace("account_deletion_worker")
+	defer trace.End()
+	tracedCtx := metrics.NewContext(runtimeCtx, trace)
+
+	due, err := w.store.GetDueRequests(tracedCtx, time.Now(), w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var completed int
+	for _, request := range due {
+		done, err := w.processOne(tracedCtx, request)
+		if err != nil {
+			// The request stays due, so the next tick resumes it.
+			w.log.Warn("Failed to process deletion request", zap.String("user_id", model.UserIDString(request.UserID)), zap.Error(err))
+			continue
+		}
+		if done {
+			completed++
+		}
+	}
+	return completed, nil
+}
+
+// processOne runs the deleters a single due request has not completed, and
+// reports whether the request is now complete.
+func (w *Worker) processOne(ctx context.Context, request *Request) (bool, error) {
+	log := w.log.With(zap.String("user_id", model.UserIDString(request.UserID)))
+
+	if request.State == StatePending {
+		err := w.store.StartRequest(ctx, request.UserID)
+		if errors.Is(err, ErrNotPending) || errors.Is(err, ErrRequestNotFound) {
+			// Cancelled between the poll and now.
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		log.Info("Account deletion started")
+	}
+
+	for _, deleter := range w.deleters {
+		step := deleter.Name()
+		if request.HasCompletedStep(step) {
+			continue
+		}
+		if err := deleter.DeleteUserData(ctx, request.UserID); err != nil {
+			log.Warn("Deletion step failed", zap.String("step", step), zap.Error(err))
+			return false, err
+		}
+		if err := w.store.CompleteStep(ctx, request.UserID, step); err != nil {
+			return false, err
+		}
+		log.Debug("Deletion step completed", zap.String("step", step))
+	}
+
+	err := w.store.CompleteRequest(ctx, request.UserID, time.Now())
+	if errors.Is(err, ErrNotInProgress) {
+		// Another instance finished it first.
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	log.Info("Account deletion completed")
+	return true, nil
+}
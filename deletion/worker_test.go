@@ -0,0 +1,228 @@
+package deletion_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/deletion"
+	"github.com/code-payments/flipcash2-server/deletion/memory"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// recordingDeleters are deleters that record each run, and fail while their
+// step is set to fail.
+type recordingDeleters struct {
+	sync.Mutex
+	runs    []string
+	failing map[string]bool
+}
+
+func (r *recordingDeleters) deleter(name string) deletion.Deleter {
+	return deletion.DeleterFunc(name, func(_ context.Context, userID *commonpb.UserId) error {
+		r.Lock()
+		defer r.Unlock()
+		if r.failing[name] {
+			return errors.New("step failed")
+		}
+		r.runs = append(r.runs, name+":"+model.UserIDString(userID))
+		return nil
+	})
+}
+
+func (r *recordingDeleters) setFailing(name string, failing bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.failing[name] = failing
+}
+
+func (r *recordingDeleters) takeRuns() []string {
+	r.Lock()
+	defer r.Unlock()
+	runs := r.runs
+	r.runs = nil
+	return runs
+}
+
+type workerEnv struct {
+	ctx      context.Context
+	store    deletion.Store
+	deleters *recordingDeleters
+	authz    *auth.StaticAuthorizer
+	server   *deletion.Server
+	worker   *deletion.Worker
+	keys     map[string]model.KeyPair
+}
+
+func newWorkerEnv(t *testing.T, gracePeriod time.Duration) *workerEnv {
+	log := zaptest.NewLogger(t)
+	store := memory.NewInMemory()
+	deleters := &recordingDeleters{failing: make(map[string]bool)}
+	authz := auth.NewStaticAuthorizer(log)
+	return &workerEnv{
+		ctx:      context.Background(),
+		store:    store,
+		deleters: deleters,
+		authz:    authz,
+		server:   deletion.NewServer(log, authz, store, deletion.WithGracePeriod(gracePeriod)),
+		worker: deletion.NewWorker(log, store, []deletion.Deleter{
+			deleters.deleter("first"),
+			deleters.deleter("second"),
+			deleters.deleter("third"),
+		}),
+		keys: make(map[string]model.KeyPair),
+	}
+}
+
+func (e *workerEnv) addUser() *commonpb.UserId {
+	userID, keyPair := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	e.authz.Add(userID, keyPair)
+	e.keys[string(userID.Value)] = keyPair
+	return userID
+}
+
+func (e *workerEnv) requestDeletion(t *testing.T, caller *commonpb.UserId) (*deletion.Request, error) {
+	req := &deletion.RequestDeletionRequest{Ts: time.Now()}
+	require.NoError(t, e.keys[string(caller.Value)].Auth(req.Payload(), &req.Auth))
+	return e.server.RequestDeletion(e.ctx, req)
+}
+
+func (e *workerEnv) cancelDeletion(t *testing.T, caller *commonpb.UserId) error {
+	req := &deletion.CancelDeletionRequest{Ts: time.Now()}
+	require.NoError(t, e.keys[string(caller.Value)].Auth(req.Payload(), &req.Auth))
+	return e.server.CancelDeletion(e.ctx, req)
+}
+
+func (e *workerEnv) getDeletion(t *testing.T, caller *commonpb.UserId) (*deletion.Request, error) {
+	req := &deletion.GetDeletionRequest{Ts: time.Now()}
+	require.NoError(t, e.keys[string(caller.Value)].Auth(req.Payload(), &req.Auth))
+	return e.server.GetDeletion(e.ctx, req)
+}
+
+func requireCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+}
+
+func TestWorker_RunsDeletersInOrder(t *testing.T) {
+	e := newWorkerEnv(t, 0)
+
+	user := e.addUser()
+	_, err := e.requestDeletion(t, user)
+	require.NoError(t, err)
+
+	completed, err := e.worker.Process(e.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, completed)
+
+	id := model.UserIDString(user)
+	require.Equal(t, []string{"first:" + id, "second:" + id, "third:" + id}, e.deleters.takeRuns())
+
+	request, err := e.getDeletion(t, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StateCompleted, request.State)
+	require.Equal(t, []string{"first", "second", "third"}, request.CompletedSteps)
+	require.False(t, request.CompletedAt.IsZero())
+
+	// A completed request is not picked up again
+	completed, err = e.worker.Process(e.ctx)
+	require.NoError(t, err)
+	require.Zero(t, completed)
+	require.Empty(t, e.deleters.takeRuns())
+}
+
+func TestWorker_ResumesAfterFailure(t *testing.T) {
+	e := newWorkerEnv(t, 0)
+
+	user := e.addUser()
+	_, err := e.requestDeletion(t, user)
+	require.NoError(t, err)
+
+	e.deleters.setFailing("second", true)
+
+	completed, err := e.worker.Process(e.ctx)
+	require.NoError(t, err)
+	require.Zero(t, completed)
+
+	id := model.UserIDString(user)
+	require.Equal(t, []string{"first:" + id}, e.deleters.takeRuns())
+
+	request, err := e.getDeletion(t, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StateInProgress, request.State)
+	require.Equal(t, []string{"first"}, request.CompletedSteps)
+
+	// Once started, the request can no longer be cancelled
+	requireCode(t, e.cancelDeletion(t, user), codes.FailedPrecondition)
+
+	// The next tick resumes at the failed step
+	e.deleters.setFailing("second", false)
+
+	completed, err = e.worker.Process(e.ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, completed)
+	require.Equal(t, []string{"second:" + id, "third:" + id}, e.deleters.takeRuns())
+
+	request, err = e.getDeletion(t, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StateCompleted, request.State)
+}
+
+func TestWorker_GracePeriodAndCancellation(t *testing.T) {
+	e := newWorkerEnv(t, time.Hour)
+
+	user := e.addUser()
+
+	requireCode(t, e.cancelDeletion(t, user), codes.NotFound)
+	_, err := e.getDeletion(t, user)
+	requireCode(t, err, codes.NotFound)
+
+	request, err := e.requestDeletion(t, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StatePending, request.State)
+	require.WithinDuration(t, time.Now().Add(time.Hour), request.ScheduledFor, time.Minute)
+
+	// Requesting again returns the pending request unchanged
+	again, err := e.requestDeletion(t, user)
+	require.NoError(t, err)
+	require.True(t, request.ScheduledFor.Equal(again.ScheduledFor))
+
+	// Nothing runs during the grace period
+	completed, err := e.worker.Process(e.ctx)
+	require.NoError(t, err)
+	require.Zero(t, completed)
+	require.Empty(t, e.deleters.takeRuns())
+
+	require.NoError(t, e.cancelDeletion(t, user))
+	requireCode(t, e.cancelDeletion(t, user), codes.FailedPrecondition)
+
+	request, err = e.getDeletion(t, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StateCancelled, request.State)
+
+	// A cancelled request never runs, even once its schedule passes
+	overdue := e.addUser()
+	require.NoError(t, e.store.CreateRequest(e.ctx, deletion.NewRequest(overdue, -time.Minute)))
+	require.NoError(t, e.cancelDeletion(t, overdue))
+
+	completed, err = e.worker.Process(e.ctx)
+	require.NoError(t, err)
+	require.Zero(t, completed)
+	require.Empty(t, e.deleters.takeRuns())
+
+	// The user can request deletion again after cancelling
+	request, err = e.requestDeletion(t, user)
+	require.NoError(t, err)
+	require.Equal(t, deletion.StatePending, request.State)
+}
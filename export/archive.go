@@ -0,0 +1,90 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// manifest is the archive's manifest.json.
+type manifest struct {
+	UserID      string    `json:"userId"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	Sections    []string  `json:"sections"`
+}
+
+const manifestSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "manifest",
+  "type": "object",
+  "required": ["userId", "generatedAt", "sections"],
+  "additionalProperties": false,
+  "properties": {
+    "userId": {"type": "string", "format": "uuid"},
+    "generatedAt": {"type": "string", "format": "date-time"},
+    "sections": {"type": "array", "items": {"type": "string"}}
+  }
+}
+`
+
+// buildArchive runs every exporter for the user and zips their sections, with
+// the manifest and schemas, into an archive.
+func buildArchive(ctx context.Context, userID *commonpb.UserId, exporters []Exporter, generatedAt time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	m := &manifest{
+		UserID:      model.UserIDString(userID),
+		GeneratedAt: generatedAt.UTC(),
+		Sections:    make([]string, 0, len(exporters)),
+	}
+	for _, exporter := range exporters {
+		section := exporter.Section()
+		document, err := exporter.Export(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", section, err)
+		}
+		if err := writeJSON(zw, section+".json", document); err != nil {
+			return nil, err
+		}
+		if err := writeFile(zw, "schemas/"+section+".schema.json", []byte(exporter.Schema())); err != nil {
+			return nil, err
+		}
+		m.Sections = append(m.Sections, section)
+	}
+
+	if err := writeJSON(zw, "manifest.json", m); err != nil {
+		return nil, err
+	}
+	if err := writeFile(zw, "schemas/manifest.schema.json", []byte(manifestSchema)); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(zw *zip.Writer, name string, document any) error {
+	encoded, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return writeFile(zw, name, encoded)
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
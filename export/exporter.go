@@ -0,0 +1,63 @@
+// Package export assembles a user's data into a downloadable archive on
+// request.
+//
+// The archive is assembled by a job (see Worker) from a list of Exporters, one
+// per subsystem holding the user's data. Each contributes one section: a JSON
+// document, and the JSON schema it conforms to. The archive is a zip laid out
+// as:
+//
+//	manifest.json                 (who, when, and which sections)
+//	<section>.json                (one per exporter)
+//	schemas/manifest.schema.json
+//	schemas/<section>.schema.json (one per exporter)
+//
+// It is written to the blob origin store with blob.ObjectStorage.PutOrigin,
+// under a key of its own (see ArchiveKey), and downloaded through a URL minted
+// by SignDownloadURL whenever the user asks for it. Each user has at most one
+// archive: a fresh export overwrites the last.
+package export
+
+import (
+	"context"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+// Exporter exports one subsystem's data for a user, as one section of the
+// archive.
+type Exporter interface {
+	// Section names the section, and its files in the archive. It must be
+	// unique among the registered exporters.
+	Section() string
+
+	// Schema returns the JSON schema the section's document conforms to.
+	Schema() string
+
+	// Export returns the user's section document, which is marshalled with
+	// encoding/json.
+	Export(ctx context.Context, userID *commonpb.UserId) (any, error)
+}
+
+// ExporterFunc adapts a function to an Exporter of the given section and
+// schema.
+func ExporterFunc(section, schema string, fn func(ctx context.Context, userID *commonpb.UserId) (any, error)) Exporter {
+	return &funcExporter{section: section, schema: schema, fn: fn}
+}
+
+type funcExporter struct {
+	section string
+	schema  string
+	fn      func(ctx context.Context, userID *commonpb.UserId) (any, error)
+}
+
+func (e *funcExporter) Section() string {
+	return e.section
+}
+
+func (e *funcExporter) Schema() string {
+	return e.schema
+}
+
+func (e *funcExporter) Export(ctx context.Context, userID *commonpb.UserId) (any, error) {
+	return e.fn(ctx, userID)
+}
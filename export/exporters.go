@@ -0,0 +1,524 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/blocklist"
+	"github.com/code-payments/flipcash2-server/chat"
+	"github.com/code-payments/flipcash2-server/contact"
+	"github.com/code-payments/flipcash2-server/database"
+	"github.com/code-payments/flipcash2-server/messaging"
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/profile"
+	"github.com/code-payments/flipcash2-server/settings"
+)
+
+// exporterPageSize is how many chats, messages, blocklist entries, or blobs an
+// exporter reads per page.
+const exporterPageSize = 100
+
+// Section names. They name the archive's files, so they must never change.
+const (
+	SectionProfile    = "profile"
+	SectionIdentities = "identities"
+	SectionSettings   = "settings"
+	SectionContacts   = "contacts"
+	SectionBlocklist  = "blocklist"
+	SectionChats      = "chats"
+	SectionBlobs      = "blobs"
+)
+
+type profileSection struct {
+	DisplayName      string `json:"displayName,omitempty"`
+	ProfilePictureID string `json:"profilePictureId,omitempty"`
+}
+
+const profileSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "profile",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "displayName": {"type": "string"},
+    "profilePictureId": {"type": "string", "format": "uuid"}
+  }
+}
+`
+
+// NewProfileExporter exports the user's public profile: their display name
+// and the blob id of their profile picture, which the blobs section lists.
+func NewProfileExporter(profiles profile.Store) Exporter {
+	return ExporterFunc(SectionProfile, profileSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		section := &profileSection{}
+
+		userProfile, err := profiles.GetProfile(ctx, userID, false)
+		if err == nil {
+			section.DisplayName = userProfile.DisplayName
+		} else if !errors.Is(err, profile.ErrNotFound) {
+			return nil, err
+		}
+
+		pictures, err := profiles.GetProfilePictures(ctx, []*commonpb.UserId{userID})
+		if err != nil {
+			return nil, err
+		}
+		if picture, ok := pictures[string(userID.Value)]; ok {
+			section.ProfilePictureID = blob.IDString(picture)
+		}
+		return section, nil
+	})
+}
+
+type identitiesSection struct {
+	PhoneNumber  string            `json:"phoneNumber,omitempty"`
+	EmailAddress string            `json:"emailAddress,omitempty"`
+	XAccount     *xAccountIdentity `json:"xAccount,omitempty"`
+}
+
+type xAccountIdentity struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name,omitempty"`
+}
+
+const identitiesSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "identities",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "phoneNumber": {"type": "string"},
+    "emailAddress": {"type": "string"},
+    "xAccount": {
+      "type": "object",
+      "required": ["id", "username"],
+      "additionalProperties": false,
+      "properties": {
+        "id": {"type": "string"},
+        "username": {"type": "string"},
+        "name": {"type": "string"}
+      }
+    }
+  }
+}
+`
+
+// NewIdentitiesExporter exports the identities linked to the user: their
+// phone number, email address, and X account.
+func NewIdentitiesExporter(profiles profile.Store) Exporter {
+	return ExporterFunc(SectionIdentities, identitiesSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		section := &identitiesSection{}
+
+		userProfile, err := profiles.GetProfile(ctx, userID, true)
+		if errors.Is(err, profile.ErrNotFound) {
+			return section, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		section.PhoneNumber = userProfile.GetPhoneNumber().GetValue()
+		section.EmailAddress = userProfile.GetEmailAddress().GetValue()
+		for _, social := range userProfile.SocialProfiles {
+			if x := social.GetX(); x != nil {
+				section.XAccount = &xAccountIdentity{
+					ID:       x.Id,
+					Username: x.Username,
+					Name:     x.Name,
+				}
+			}
+		}
+		return section, nil
+	})
+}
+
+type settingsSection struct {
+	Region string `json:"region"`
+	Locale string `json:"locale"`
+}
+
+const settingsSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "settings",
+  "type": "object",
+  "required": ["region", "locale"],
+  "additionalProperties": false,
+  "properties": {
+    "region": {"type": "string"},
+    "locale": {"type": "string"}
+  }
+}
+`
+
+// NewSettingsExporter exports the user's region and locale, which are the
+// defaults for a user who never set them.
+func NewSettingsExporter(prefs settings.Store) Exporter {
+	return ExporterFunc(SectionSettings, settingsSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		userSettings, err := prefs.GetSettings(ctx, userID)
+		if errors.Is(err, settings.ErrNotFound) {
+			userSettings = &settings.Settings{Region: settings.DefaultRegion, Locale: settings.DefaultLocale}
+		} else if err != nil {
+			return nil, err
+		}
+		return &settingsSection{
+			Region: userSettings.Region.GetValue(),
+			Locale: userSettings.Locale.GetValue(),
+		}, nil
+	})
+}
+
+type contactsSection struct {
+	PhoneNumberHashCount int `json:"phoneNumberHashCount"`
+}
+
+const contactsSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "contacts",
+  "type": "object",
+  "required": ["phoneNumberHashCount"],
+  "additionalProperties": false,
+  "properties": {
+    "phoneNumberHashCount": {"type": "integer", "minimum": 0}
+  }
+}
+`
+
+// NewContactsExporter exports how many phone numbers the user's uploaded
+// contact list holds. The list itself is stored only as keyed hashes, which
+// mean nothing outside the server, so only their count is exported.
+func NewContactsExporter(contacts contact.Store) Exporter {
+	return ExporterFunc(SectionContacts, contactsSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		hashes, err := contacts.GetHashes(ctx, userID)
+		if err != nil && !errors.Is(err, contact.ErrNotFound) {
+			return nil, err
+		}
+		return &contactsSection{PhoneNumberHashCount: len(hashes)}, nil
+	})
+}
+
+type blocklistSection struct {
+	Blocked []*blockedUser `json:"blocked"`
+}
+
+type blockedUser struct {
+	UserID    string    `json:"userId"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+const blocklistSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "blocklist",
+  "type": "object",
+  "required": ["blocked"],
+  "additionalProperties": false,
+  "properties": {
+    "blocked": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["userId", "blockedAt"],
+        "additionalProperties": false,
+        "properties": {
+          "userId": {"type": "string", "format": "uuid"},
+          "blockedAt": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}
+`
+
+// NewBlocklistExporter exports the users the user has blocked, most recently
+// blocked first.
+func NewBlocklistExporter(blocklists blocklist.Store) Exporter {
+	return ExporterFunc(SectionBlocklist, blocklistSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		section := &blocklistSection{Blocked: []*blockedUser{}}
+
+		var cursor *blocklist.Cursor
+		for {
+			page, err := blocklists.GetBlocklistPage(ctx, userID, cursor, exporterPageSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range page {
+				section.Blocked = append(section.Blocked, &blockedUser{
+					UserID:    model.UserIDString(entry.UserID),
+					BlockedAt: entry.BlockedAt.UTC(),
+				})
+			}
+			if len(page) < exporterPageSize {
+				return section, nil
+			}
+			last := page[len(page)-1]
+			cursor = &blocklist.Cursor{BlockedAt: last.BlockedAt, UserID: last.UserID}
+		}
+	})
+}
+
+type chatsSection struct {
+	Chats []*exportedChat `json:"chats"`
+}
+
+type exportedChat struct {
+	ChatID       string              `json:"chatId"`
+	Type         string              `json:"type"`
+	Members      []string            `json:"members"`
+	SentMessages []*exportedMessage  `json:"sentMessages"`
+	Reactions    []*exportedReaction `json:"reactions"`
+}
+
+type exportedMessage struct {
+	MessageID    uint64            `json:"messageId"`
+	SentAt       time.Time         `json:"sentAt"`
+	LastEditedAt *time.Time        `json:"lastEditedAt,omitempty"`
+	Content      []json.RawMessage `json:"content"`
+}
+
+type exportedReaction struct {
+	MessageID uint64 `json:"messageId"`
+	Emoji     string `json:"emoji"`
+}
+
+const chatsSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "chats",
+  "type": "object",
+  "required": ["chats"],
+  "additionalProperties": false,
+  "properties": {
+    "chats": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["chatId", "type", "members", "sentMessages", "reactions"],
+        "additionalProperties": false,
+        "properties": {
+          "chatId": {"type": "string", "pattern": "^[0-9a-f]+$"},
+          "type": {"type": "string"},
+          "members": {"type": "array", "items": {"type": "string", "format": "uuid"}},
+          "sentMessages": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["messageId", "sentAt", "content"],
+              "additionalProperties": false,
+              "properties": {
+                "messageId": {"type": "integer", "minimum": 0},
+                "sentAt": {"type": "string", "format": "date-time"},
+                "lastEditedAt": {"type": "string", "format": "date-time"},
+                "content": {
+                  "description": "The message's content items, in the canonical protobuf JSON mapping of flipcash.messaging.v1.Content.",
+                  "type": "array",
+                  "items": {"type": "object"}
+                }
+              }
+            }
+          },
+          "reactions": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["messageId", "emoji"],
+              "additionalProperties": false,
+              "properties": {
+                "messageId": {"type": "integer", "minimum": 0},
+                "emoji": {"type": "string"}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// NewChatsExporter exports the user's DMs: each chat's members, the messages
+// the user sent in it, and the reactions they left on its messages. Messages
+// the user deleted are left out, as are the other members' messages, which
+// are theirs.
+func NewChatsExporter(chats chat.Store, messages messaging.Store) Exporter {
+	return ExporterFunc(SectionChats, chatsSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		section := &chatsSection{Chats: []*exportedChat{}}
+
+		snapshot := time.Now()
+		for _, chatType := range chat.DmChatTypes() {
+			var cursor *chat.DmFeedCursor
+			for {
+				page, err := chats.GetDmFeedPage(ctx, userID, chatType, snapshot, cursor, exporterPageSize)
+				if err != nil {
+					return nil, err
+				}
+				for _, c := range page {
+					exported, err := exportChat(ctx, messages, c, userID)
+					if err != nil {
+						return nil, err
+					}
+					section.Chats = append(section.Chats, exported)
+				}
+				if len(page) < exporterPageSize {
+					break
+				}
+				last := page[len(page)-1]
+				cursor = &chat.DmFeedCursor{LastActivity: last.LastActivity, ChatID: last.ID}
+			}
+		}
+		return section, nil
+	})
+}
+
+func exportChat(ctx context.Context, messages messaging.Store, c *chat.Chat, userID *commonpb.UserId) (*exportedChat, error) {
+	exported := &exportedChat{
+		ChatID:       hex.EncodeToString(c.ID.Value),
+		Type:         c.Type.String(),
+		Members:      make([]string, len(c.Members)),
+		SentMessages: []*exportedMessage{},
+		Reactions:    []*exportedReaction{},
+	}
+	for i, member := range c.Members {
+		exported.Members[i] = model.UserIDString(member)
+	}
+
+	opts := []database.QueryOption{database.WithAscending(), database.WithLimit(exporterPageSize)}
+	for {
+		page, err := messages.GetMessages(ctx, c.ID, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range page {
+			if msg.SenderID == nil || !bytes.Equal(msg.SenderID.Value, userID.Value) || msg.IsDeleted() {
+				continue
+			}
+			sent, err := exportMessage(msg)
+			if err != nil {
+				return nil, err
+			}
+			exported.SentMessages = append(exported.SentMessages, sent)
+		}
+		if len(page) < exporterPageSize {
+			break
+		}
+		opts = append(opts, database.WithPagingToken(messaging.PageTokenFromID(page[len(page)-1].ID)))
+	}
+
+	opts = []database.QueryOption{database.WithAscending(), database.WithLimit(exporterPageSize)}
+	for {
+		summaries, err := messages.GetReactionSummaries(ctx, c.ID, opts...)
+		if err != nil {
+			return nil, err
+		}
+		var refs []messaging.ReactionRef
+		for _, summary := range summaries {
+			for _, reaction := range summary.Reactions {
+				refs = append(refs, messaging.ReactionRef{MessageID: summary.MessageID, Emoji: reaction.Emoji})
+			}
+		}
+		own, err := messages.GetSelfReactions(ctx, c.ID, userID, refs)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range own {
+			exported.Reactions = append(exported.Reactions, &exportedReaction{MessageID: ref.MessageID.Value, Emoji: ref.Emoji})
+		}
+		if len(summaries) < exporterPageSize {
+			break
+		}
+		opts = append(opts, database.WithPagingToken(messaging.PageTokenFromID(summaries[len(summaries)-1].MessageID)))
+	}
+	return exported, nil
+}
+
+func exportMessage(msg *messaging.Message) (*exportedMessage, error) {
+	exported := &exportedMessage{
+		MessageID: msg.ID.Value,
+		SentAt:    msg.Timestamp.UTC(),
+		Content:   make([]json.RawMessage, len(msg.Content)),
+	}
+	if !msg.LastEditedTs.IsZero() {
+		lastEditedAt := msg.LastEditedTs.UTC()
+		exported.LastEditedAt = &lastEditedAt
+	}
+	for i, content := range msg.Content {
+		encoded, err := protojson.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		exported.Content[i] = encoded
+	}
+	return exported, nil
+}
+
+type blobsSection struct {
+	Blobs []*exportedBlob `json:"blobs"`
+}
+
+type exportedBlob struct {
+	BlobID    string `json:"blobId"`
+	MimeType  string `json:"mimeType"`
+	SizeBytes uint64 `json:"sizeBytes"`
+	Status    string `json:"status"`
+}
+
+const blobsSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "blobs",
+  "type": "object",
+  "required": ["blobs"],
+  "additionalProperties": false,
+  "properties": {
+    "blobs": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["blobId", "mimeType", "sizeBytes", "status"],
+        "additionalProperties": false,
+        "properties": {
+          "blobId": {"type": "string", "format": "uuid"},
+          "mimeType": {"type": "string"},
+          "sizeBytes": {"type": "integer", "minimum": 0},
+          "status": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
+
+// NewBlobsExporter exports the media the user uploaded and has not had
+// collected. The archive lists the blobs rather than embedding their bytes,
+// which the user can already download through the chats and profile that
+// reference them.
+func NewBlobsExporter(blobs blob.Store) Exporter {
+	return ExporterFunc(SectionBlobs, blobsSchema, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+		section := &blobsSection{Blobs: []*exportedBlob{}}
+
+		var cursor string
+		for {
+			page, next, err := blobs.GetOriginalsByOwner(ctx, userID, cursor, exporterPageSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, record := range page {
+				section.Blobs = append(section.Blobs, &exportedBlob{
+					BlobID:    blob.IDString(record.ID),
+					MimeType:  record.MimeType,
+					SizeBytes: record.SizeBytes,
+					Status:    record.State.ToBlobStatus().String(),
+				})
+			}
+			if next == "" {
+				return section, nil
+			}
+			cursor = next
+		}
+	})
+}
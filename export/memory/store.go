@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/export"
+)
+
+type store struct {
+	sync.Mutex
+
+	// maps a userID to its export request
+	requests map[string]*export.Request
+}
+
+// NewInMemory returns an in-memory export.Store.
+func NewInMemory() export.Store {
+	return &store{
+		requests: make(map[string]*export.Request),
+	}
+}
+
+func (s *store) reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.requests = make(map[string]*export.Request)
+}
+
+func (s *store) CreateRequest(_ context.Context, request *export.Request) error {
+	s.Lock()
+	defer s.Unlock()
+
+	existing, ok := s.requests[string(request.UserID.Value)]
+	if ok && existing.State == export.StatePending {
+		return export.ErrRequestExists
+	}
+	s.requests[string(request.UserID.Value)] = request.Clone()
+	return nil
+}
+
+func (s *store) GetRequest(_ context.Context, userID *commonpb.UserId) (*export.Request, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return nil, export.ErrRequestNotFound
+	}
+	return request.Clone(), nil
+}
+
+func (s *store) GetPendingRequests(_ context.Context, limit int) ([]*export.Request, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*export.Request, 0)
+	for _, request := range s.requests {
+		if request.State == export.StatePending {
+			res = append(res, request.Clone())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].RequestedAt.Before(res[j].RequestedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) CompleteRequest(_ context.Context, userID *commonpb.UserId, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return export.ErrRequestNotFound
+	}
+	if request.State != export.StatePending {
+		return export.ErrNotPending
+	}
+	request.State = export.StateCompleted
+	request.CompletedAt = at
+	return nil
+}
+
+func (s *store) GetCompletedRequests(_ context.Context, completedBefore time.Time, limit int) ([]*export.Request, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*export.Request, 0)
+	for _, request := range s.requests {
+		if request.State == export.StateCompleted && request.CompletedAt.Before(completedBefore) {
+			res = append(res, request.Clone())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CompletedAt.Before(res[j].CompletedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) ExpireRequest(_ context.Context, userID *commonpb.UserId, completedBefore time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	request, ok := s.requests[string(userID.Value)]
+	if !ok {
+		return export.ErrRequestNotFound
+	}
+	if request.State != export.StateCompleted || !request.CompletedAt.Before(completedBefore) {
+		return export.ErrNotExpirable
+	}
+	request.State = export.StateExpired
+	return nil
+}
+
+func (s *store) DeleteRequest(_ context.Context, userID *commonpb.UserId) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.requests, string(userID.Value))
+	return nil
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/blob"
+)
+
+// ArchiveMimeType is the content type the archive is stored and served with.
+const ArchiveMimeType = "application/zip"
+
+// State is where an export request is in its lifecycle.
+type State string
+
+const (
+	// StatePending is a request waiting for the job to assemble its archive.
+	StatePending State = "pending"
+
+	// StateCompleted is a request whose archive is ready to download. The user
+	// may request a fresh export afterwards, which replaces it.
+	StateCompleted State = "completed"
+
+	// StateExpired is a completed request whose archive the job deleted once
+	// the retention period passed. The user may request a fresh export.
+	StateExpired State = "expired"
+)
+
+// Request is a user's request to export their data. There is at most one
+// request per user, and at most one archive: a fresh export overwrites the
+// last, and it is deleted once the request expires.
+type Request struct {
+	UserID *commonpb.UserId
+
+	State State
+
+	RequestedAt time.Time
+	CompletedAt time.Time
+}
+
+// NewRequest returns a pending request for userID, made now.
+func NewRequest(userID *commonpb.UserId) *Request {
+	return &Request{
+		UserID:      userID,
+		State:       StatePending,
+		RequestedAt: time.Now(),
+	}
+}
+
+func (r *Request) Clone() *Request {
+	cloned := *r
+	cloned.UserID = proto.Clone(r.UserID).(*commonpb.UserId)
+	return &cloned
+}
+
+// ArchivePrefix is the origin key prefix a user's export archive lives under.
+func ArchivePrefix(userID *commonpb.UserId) string {
+	return "exports/" + hex.EncodeToString(userID.Value) + "/"
+}
+
+// DeleteArchive deletes a user's export archive, if any, and purges it from the
+// CDN so download URLs already signed stop working.
+func DeleteArchive(ctx context.Context, storage blob.ObjectStorage, userID *commonpb.UserId) error {
+	if err := storage.DeletePrefix(ctx, ArchivePrefix(userID)); err != nil {
+		return err
+	}
+	return storage.InvalidateCDN(ctx, ArchivePrefix(userID))
+}
+
+// ArchiveKey is the origin key of a user's export archive:
+//
+//	exports/<user id hex>/takeout.zip
+func ArchiveKey(userID *commonpb.UserId) string {
+	return ArchivePrefix(userID) + "takeout.zip"
+}
@@ -0,0 +1,175 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/export"
+
+	pg "github.com/code-payments/flipcash2-server/database/postgres"
+)
+
+const (
+	exportsTableName = "flipcash_data_exports"
+	allExportFields  = `"userId", "state", "requestedAt", "completedAt"`
+)
+
+type exportModel struct {
+	UserID      string     `db:"userId"`
+	State       string     `db:"state"`
+	RequestedAt time.Time  `db:"requestedAt"`
+	CompletedAt *time.Time `db:"completedAt"`
+}
+
+func toExportModel(r *export.Request) *exportModel {
+	return &exportModel{
+		UserID:      pg.Encode(r.UserID.Value),
+		State:       string(r.State),
+		RequestedAt: r.RequestedAt.UTC(),
+	}
+}
+
+func fromExportModel(m *exportModel) (*export.Request, error) {
+	userID, err := pg.Decode(m.UserID)
+	if err != nil {
+		return nil, err
+	}
+	r := &export.Request{
+		UserID:      &commonpb.UserId{Value: userID},
+		State:       export.State(m.State),
+		RequestedAt: m.RequestedAt,
+	}
+	if m.CompletedAt != nil {
+		r.CompletedAt = *m.CompletedAt
+	}
+	return r, nil
+}
+
+func (m *exportModel) dbCreate(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + exportsTableName + `(` + allExportFields + `)
+			VALUES ($1, $2, $3, NULL)
+			ON CONFLICT ("userId") DO UPDATE
+				SET "state" = $2, "requestedAt" = $3, "completedAt" = NULL
+				WHERE ` + exportsTableName + `."state" <> $4`
+		tag, err := tx.Exec(
+			ctx,
+			query,
+			m.UserID,
+			m.State,
+			m.RequestedAt,
+			string(export.StatePending),
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return export.ErrRequestExists
+		}
+		return nil
+	})
+}
+
+func dbGetRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) (*exportModel, error) {
+	res := &exportModel{}
+	query := `SELECT ` + allExportFields + ` FROM ` + exportsTableName + `
+		WHERE "userId" = $1`
+	err := pgxscan.Get(ctx, pool, res, query, pg.Encode(userID.Value))
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, export.ErrRequestNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetPendingRequests(ctx context.Context, pool *pgxpool.Pool, limit int) ([]*exportModel, error) {
+	var res []*exportModel
+	query := `SELECT ` + allExportFields + ` FROM ` + exportsTableName + `
+		WHERE "state" = $1
+		ORDER BY "requestedAt" ASC
+		LIMIT $2`
+	err := pgxscan.Select(ctx, pool, &res, query, string(export.StatePending), limit)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbCompleteRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, at time.Time) error {
+	encodedUserID := pg.Encode(userID.Value)
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `UPDATE ` + exportsTableName + `
+			SET "state" = $3, "completedAt" = $4
+			WHERE "userId" = $1 AND "state" = $2`
+		tag, err := tx.Exec(ctx, query, encodedUserID, string(export.StatePending), string(export.StateCompleted), at.UTC())
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+
+		var exists bool
+		query = `SELECT EXISTS (SELECT 1 FROM ` + exportsTableName + ` WHERE "userId" = $1)`
+		if err := tx.QueryRow(ctx, query, encodedUserID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return export.ErrNotPending
+		}
+		return export.ErrRequestNotFound
+	})
+}
+
+func dbGetCompletedRequests(ctx context.Context, pool *pgxpool.Pool, completedBefore time.Time, limit int) ([]*exportModel, error) {
+	var res []*exportModel
+	query := `SELECT ` + allExportFields + ` FROM ` + exportsTableName + `
+		WHERE "state" = $1 AND "completedAt" < $2
+		ORDER BY "completedAt" ASC
+		LIMIT $3`
+	err := pgxscan.Select(ctx, pool, &res, query, string(export.StateCompleted), completedBefore.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbExpireRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, completedBefore time.Time) error {
+	encodedUserID := pg.Encode(userID.Value)
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `UPDATE ` + exportsTableName + `
+			SET "state" = $3
+			WHERE "userId" = $1 AND "state" = $2 AND "completedAt" < $4`
+		tag, err := tx.Exec(ctx, query, encodedUserID, string(export.StateCompleted), string(export.StateExpired), completedBefore.UTC())
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+
+		var exists bool
+		query = `SELECT EXISTS (SELECT 1 FROM ` + exportsTableName + ` WHERE "userId" = $1)`
+		if err := tx.QueryRow(ctx, query, encodedUserID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return export.ErrNotExpirable
+		}
+		return export.ErrRequestNotFound
+	})
+}
+
+func dbDeleteRequest(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) error {
+	query := `DELETE FROM ` + exportsTableName + ` WHERE "userId" = $1`
+	_, err := pool.Exec(ctx, query, pg.Encode(userID.Value))
+	return err
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/export"
+)
+
+type store struct {
+	pool *pgxpool.Pool
+}
+
+// NewInPostgres returns an export.Store backed by Postgres.
+func NewInPostgres(pool *pgxpool.Pool) export.Store {
+	return &store{
+		pool: pool,
+	}
+}
+
+func (s *store) CreateRequest(ctx context.Context, request *export.Request) error {
+	return toExportModel(request).dbCreate(ctx, s.pool)
+}
+
+func (s *store) GetRequest(ctx context.Context, userID *commonpb.UserId) (*export.Request, error) {
+	model, err := dbGetRequest(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	return fromExportModel(model)
+}
+
+func (s *store) GetPendingRequests(ctx context.Context, limit int) ([]*export.Request, error) {
+	models, err := dbGetPendingRequests(ctx, s.pool, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*export.Request, len(models))
+	for i, model := range models {
+		res[i], err = fromExportModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) CompleteRequest(ctx context.Context, userID *commonpb.UserId, at time.Time) error {
+	return dbCompleteRequest(ctx, s.pool, userID, at)
+}
+
+func (s *store) GetCompletedRequests(ctx context.Context, completedBefore time.Time, limit int) ([]*export.Request, error) {
+	models, err := dbGetCompletedRequests(ctx, s.pool, completedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*export.Request, len(models))
+	for i, model := range models {
+		res[i], err = fromExportModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) ExpireRequest(ctx context.Context, userID *commonpb.UserId, completedBefore time.Time) error {
+	return dbExpireRequest(ctx, s.pool, userID, completedBefore)
+}
+
+func (s *store) DeleteRequest(ctx context.Context, userID *commonpb.UserId) error {
+	return dbDeleteRequest(ctx, s.pool, userID)
+}
+
+func (s *store) reset() {
+	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+exportsTableName)
+	if err != nil {
+		panic(err)
+	}
+}
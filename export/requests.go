@@ -0,0 +1,39 @@
+package export
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+)
+
+// The requests below are for the Server methods backing unpublished Account
+// RPCs, each signed over its Payload (see auth.NewPayload).
+
+// RequestExportRequest queues an export of the caller's data.
+type RequestExportRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *RequestExportRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "RequestExport")
+}
+
+// GetExportRequest asks for the caller's export request and its archive.
+type GetExportRequest struct {
+	Ts   time.Time
+	Auth *commonpb.Auth
+}
+
+func (r *GetExportRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "GetExport")
+}
+
+// requestPayload is the signed payload of an unpublished Account request.
+func requestPayload(ts time.Time, method string, args ...string) proto.Message {
+	return auth.NewPayload("flipcash.account.v1.Account/"+method, ts, args...)
+}
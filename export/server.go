@@ -0,0 +1,125 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// defaultCooldown is how long after an export completes before the user can
+// request a fresh one. Assembling an archive reads everything the user has,
+// so it is not something to run on every tap.
+const defaultCooldown = 24 * time.Hour
+
+// Server is the self-service side of data export: users request an export of
+// their own data, and fetch the archive once it is ready.
+type Server struct {
+	log     *zap.Logger
+	authz   auth.Authorizer
+	store   Store
+	storage blob.ObjectStorage
+
+	cooldown time.Duration
+}
+
+type ServerOption func(*Server)
+
+// WithCooldown overrides how long after an export completes before the user
+// can request a fresh one.
+func WithCooldown(cooldown time.Duration) ServerOption {
+	return func(s *Server) {
+		s.cooldown = cooldown
+	}
+}
+
+func NewServer(log *zap.Logger, authz auth.Authorizer, store Store, storage blob.ObjectStorage, opts ...ServerOption) *Server {
+	s := &Server{
+		log:     log,
+		authz:   authz,
+		store:   store,
+		storage: storage,
+
+		cooldown: defaultCooldown,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// RequestExport queues an export of the caller's data, which the job assembles
+// asynchronously, or renews one that expired. It is idempotent: while a request
+// is pending, or within the cooldown after one completed, that request is
+// returned unchanged.
+func (s *Server) RequestExport(ctx context.Context, req *RequestExportRequest) (*Request, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(zap.String("user_id", model.UserIDString(caller)))
+
+	existing, err := s.store.GetRequest(ctx, caller)
+	if err == nil && existing.State == StateCompleted && time.Since(existing.CompletedAt) < s.cooldown {
+		return existing, nil
+	} else if err != nil && !errors.Is(err, ErrRequestNotFound) {
+		log.Warn("Failed to get export request", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to request export")
+	}
+
+	request := NewRequest(caller)
+	err = s.store.CreateRequest(ctx, request)
+	if errors.Is(err, ErrRequestExists) {
+		existing, err := s.store.GetRequest(ctx, caller)
+		if err != nil {
+			log.Warn("Failed to get export request", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to request export")
+		}
+		return existing, nil
+	} else if err != nil {
+		log.Warn("Failed to create export request", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to request export")
+	}
+	log.Info("Data export requested")
+	return request, nil
+}
+
+// GetExport returns the caller's export request and, once it has completed, a
+// freshly signed URL to download the archive from. The URL is nil while the
+// request is pending, and once it has expired.
+func (s *Server) GetExport(ctx context.Context, req *GetExportRequest) (*Request, *blobpb.DownloadUrl, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log := s.log.With(zap.String("user_id", model.UserIDString(caller)))
+
+	request, err := s.store.GetRequest(ctx, caller)
+	if errors.Is(err, ErrRequestNotFound) {
+		return nil, nil, status.Error(codes.NotFound, "export not requested")
+	} else if err != nil {
+		log.Warn("Failed to get export request", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "failed to get export")
+	}
+	if request.State != StateCompleted {
+		return request, nil, nil
+	}
+
+	downloadURL, err := s.storage.SignDownloadURL(ctx, ArchiveKey(caller))
+	if err != nil {
+		log.Warn("Failed to sign export download url", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "failed to get export")
+	}
+	return request, downloadURL, nil
+}
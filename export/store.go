@@ -0,0 +1,56 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+var (
+	// ErrRequestNotFound is returned when the user has no export request.
+	ErrRequestNotFound = errors.New("export request not found")
+
+	// ErrRequestExists is returned by Store.CreateRequest when the user already
+	// has a pending request.
+	ErrRequestExists = errors.New("export request already pending")
+
+	// ErrNotPending is returned when completing a request that is not pending.
+	ErrNotPending = errors.New("export request not pending")
+
+	// ErrNotExpirable is returned when expiring a request that is not completed,
+	// or that completed too recently.
+	ErrNotExpirable = errors.New("export request not expirable")
+)
+
+// Store persists export requests.
+type Store interface {
+	// CreateRequest records a pending request. It replaces a completed or
+	// expired request for the same user, and returns ErrRequestExists for a
+	// pending one.
+	CreateRequest(ctx context.Context, request *Request) error
+
+	// GetRequest returns the user's request, or ErrRequestNotFound.
+	GetRequest(ctx context.Context, userID *commonpb.UserId) (*Request, error)
+
+	// GetPendingRequests returns up to limit pending requests, oldest first.
+	GetPendingRequests(ctx context.Context, limit int) ([]*Request, error)
+
+	// CompleteRequest marks the user's pending request completed at the given
+	// time. It returns ErrRequestNotFound if there is no request, and
+	// ErrNotPending if it is not pending.
+	CompleteRequest(ctx context.Context, userID *commonpb.UserId, at time.Time) error
+
+	// GetCompletedRequests returns up to limit completed requests that completed
+	// before a time, oldest first.
+	GetCompletedRequests(ctx context.Context, completedBefore time.Time, limit int) ([]*Request, error)
+
+	// ExpireRequest marks the user's completed request expired, provided it
+	// completed before a time. It returns ErrRequestNotFound if there is no
+	// request, and ErrNotExpirable if it is not a completed request that old.
+	ExpireRequest(ctx context.Context, userID *commonpb.UserId, completedBefore time.Time) error
+
+	// DeleteRequest deletes the user's request. It is a no-op if there is none.
+	DeleteRequest(ctx context.Context, userID *commonpb.UserId) error
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/export"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+func RunStoreTests(t *testing.T, s export.Store, teardown func()) {
+	for _, tf := range []func(t *testing.T, s export.Store){
+		testStore_lifecycle,
+		testStore_pendingRequests,
+		testStore_expiry,
+	} {
+		tf(t, s)
+		teardown()
+	}
+}
+
+func testStore_lifecycle(t *testing.T, s export.Store) {
+	ctx := context.Background()
+
+	user := model.MustGenerateUserID()
+
+	_, err := s.GetRequest(ctx, user)
+	require.ErrorIs(t, err, export.ErrRequestNotFound)
+	require.ErrorIs(t, s.CompleteRequest(ctx, user, time.Now()), export.ErrRequestNotFound)
+
+	request := newRequest(user)
+	require.NoError(t, s.CreateRequest(ctx, request))
+	require.ErrorIs(t, s.CreateRequest(ctx, newRequest(user)), export.ErrRequestExists)
+
+	actual, err := s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, request, actual)
+
+	completedAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.CompleteRequest(ctx, user, completedAt))
+	require.ErrorIs(t, s.CompleteRequest(ctx, user, time.Now()), export.ErrNotPending)
+
+	request.State = export.StateCompleted
+	request.CompletedAt = completedAt
+	actual, err = s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, request, actual)
+
+	// A completed request is replaced by a new one
+	renewed := newRequest(user)
+	renewed.RequestedAt = renewed.RequestedAt.Add(time.Minute)
+	require.NoError(t, s.CreateRequest(ctx, renewed))
+
+	actual, err = s.GetRequest(ctx, user)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, renewed, actual)
+}
+
+func testStore_pendingRequests(t *testing.T, s export.Store) {
+	ctx := context.Background()
+
+	pending, err := s.GetPendingRequests(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	now := time.Now().Truncate(time.Millisecond)
+	requested := func(offset time.Duration) *export.Request {
+		request := newRequest(model.MustGenerateUserID())
+		request.RequestedAt = now.Add(offset)
+		require.NoError(t, s.CreateRequest(ctx, request))
+		return request
+	}
+
+	newer := requested(-time.Hour)
+	older := requested(-2 * time.Hour)
+	newest := requested(0)
+	completed := requested(-3 * time.Hour)
+	require.NoError(t, s.CompleteRequest(ctx, completed.UserID, now))
+
+	pending, err = s.GetPendingRequests(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	require.Equal(t, older.UserID.Value, pending[0].UserID.Value)
+	require.Equal(t, newer.UserID.Value, pending[1].UserID.Value)
+	require.Equal(t, newest.UserID.Value, pending[2].UserID.Value)
+	for _, request := range pending {
+		require.Equal(t, export.StatePending, request.State)
+	}
+
+	pending, err = s.GetPendingRequests(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, older.UserID.Value, pending[0].UserID.Value)
+}
+
+func testStore_expiry(t *testing.T, s export.Store) {
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Millisecond)
+	completed := func(offset time.Duration) *export.Request {
+		request := newRequest(model.MustGenerateUserID())
+		require.NoError(t, s.CreateRequest(ctx, request))
+		require.NoError(t, s.CompleteRequest(ctx, request.UserID, now.Add(offset)))
+		return request
+	}
+
+	newer := completed(-time.Hour)
+	older := completed(-2 * time.Hour)
+	recent := completed(0)
+	pending := newRequest(model.MustGenerateUserID())
+	require.NoError(t, s.CreateRequest(ctx, pending))
+
+	cutoff := now.Add(-time.Minute)
+	expiring, err := s.GetCompletedRequests(ctx, cutoff, 10)
+	require.NoError(t, err)
+	require.Len(t, expiring, 2)
+	require.Equal(t, older.UserID.Value, expiring[0].UserID.Value)
+	require.Equal(t, newer.UserID.Value, expiring[1].UserID.Value)
+
+	expiring, err = s.GetCompletedRequests(ctx, cutoff, 1)
+	require.NoError(t, err)
+	require.Len(t, expiring, 1)
+	require.Equal(t, older.UserID.Value, expiring[0].UserID.Value)
+
+	require.ErrorIs(t, s.ExpireRequest(ctx, model.MustGenerateUserID(), cutoff), export.ErrRequestNotFound)
+	require.ErrorIs(t, s.ExpireRequest(ctx, recent.UserID, cutoff), export.ErrNotExpirable)
+	require.ErrorIs(t, s.ExpireRequest(ctx, pending.UserID, cutoff), export.ErrNotExpirable)
+
+	require.NoError(t, s.ExpireRequest(ctx, older.UserID, cutoff))
+	require.ErrorIs(t, s.ExpireRequest(ctx, older.UserID, cutoff), export.ErrNotExpirable)
+	actual, err := s.GetRequest(ctx, older.UserID)
+	require.NoError(t, err)
+	require.Equal(t, export.StateExpired, actual.State)
+
+	expiring, err = s.GetCompletedRequests(ctx, cutoff, 10)
+	require.NoError(t, err)
+	require.Len(t, expiring, 1)
+	require.Equal(t, newer.UserID.Value, expiring[0].UserID.Value)
+
+	// An expired request is replaced by a new one
+	renewed := newRequest(older.UserID)
+	require.NoError(t, s.CreateRequest(ctx, renewed))
+	actual, err = s.GetRequest(ctx, older.UserID)
+	require.NoError(t, err)
+	assertEquivalentRequests(t, renewed, actual)
+
+	// Deleting is idempotent
+	require.NoError(t, s.DeleteRequest(ctx, newer.UserID))
+	require.NoError(t, s.DeleteRequest(ctx, newer.UserID))
+	_, err = s.GetRequest(ctx, newer.UserID)
+	require.ErrorIs(t, err, export.ErrRequestNotFound)
+}
+
+func newRequest(userID *commonpb.UserId) *export.Request {
+	request := export.NewRequest(userID)
+	request.RequestedAt = request.RequestedAt.Truncate(time.Millisecond)
+	return request
+}
+
+func assertEquivalentRequests(t *testing.T, expected, actual *export.Request) {
+	require.Equal(t, expected.UserID.Value, actual.UserID.Value)
+	require.Equal(t, expected.State, actual.State)
+	require.True(t, expected.RequestedAt.Equal(actual.RequestedAt))
+	require.True(t, expected.CompletedAt.Equal(actual.CompletedAt))
+}
@@ -0,0 +1,185 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/poll"
+
+	"github.com/code-payments/ocp-server/metrics"
+	"github.com/code-payments/ocp-server/metrics/noop"
+)
+
+const (
+	// defaultWorkerBatchSize is how many pending, or expiring, requests one
+	// tick pulls from the store.
+	defaultWorkerBatchSize = 10
+
+	// defaultArchiveRetention is how long after an export completes its
+	// archive is kept.
+	defaultArchiveRetention = 7 * 24 * time.Hour
+)
+
+// Worker runs the export job: it picks up each pending request, runs every
+// registered exporter for the user, writes the archive to the origin store,
+// and marks the request completed. An exporter that fails leaves the request
+// pending, and the next tick starts it over. Each tick also deletes the
+// archives of requests completed longer ago than the retention period, and
+// marks those requests expired.
+//
+// It is safe to run on every server instance: two instances working on the
+// same request write equivalent archives to the same key, and completing a
+// request twice is harmless.
+type Worker struct {
+	log       *zap.Logger
+	store     Store
+	storage   blob.ObjectStorage
+	exporters []Exporter
+
+	batchSize int
+	retention time.Duration
+}
+
+// WorkerOption overrides one of the worker's knobs.
+type WorkerOption func(*Worker)
+
+// WithWorkerBatchSize overrides how many pending requests one tick pulls from
+// the store.
+func WithWorkerBatchSize(n int) WorkerOption {
+	return func(w *Worker) { w.batchSize = n }
+}
+
+// WithArchiveRetention overrides how long after an export completes its
+// archive is kept.
+func WithArchiveRetention(retention time.Duration) WorkerOption {
+	return func(w *Worker) { w.retention = retention }
+}
+
+// NewWorker returns a Worker assembling each pending request's archive from
+// exporters, whose sections appear in the manifest in the given order.
+func NewWorker(log *zap.Logger, store Store, storage blob.ObjectStorage, exporters []Exporter, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		log:       log,
+		store:     store,
+		storage:   storage,
+		exporters: exporters,
+
+		batchSize: defaultWorkerBatchSize,
+		retention: defaultArchiveRetention,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start satisfies the OCP worker.Runtime interface: it polls for pending
+// requests every interval until ctx is cancelled (see poll.Loop), whose error
+// it returns.
+func (w *Worker) Start(ctx context.Context, interval time.Duration) error {
+	return poll.Loop(ctx, interval, func(ctx context.Context) bool {
+		completed, err := w.Process(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			w.log.Warn("Failed to process export requests", zap.Error(err))
+		}
+		return completed == w.batchSize
+	})
+}
+
+// Process runs one tick over the pending requests and reports how many it
+// completed. Zero means nothing is pending, or nothing pending could be
+// completed.
+func (w *Worker) Process(runtimeCtx context.Context) (int, error) {
+	metricsProvider, ok := runtimeCtx.Value(metrics.ProviderContextKey).(metrics.Provider)
+	if !ok || metricsProvider == nil {
+		metricsProvider = noop.NewProvider()
+	}
+	trace := metricsProvider.StartTrace("data_export_worker")
+	defer trace.End()
+	tracedCtx := metrics.NewContext(runtimeCtx, trace)
+
+	// Expiry is retried next tick, and must not hold pending exports up.
+	if err := w.expireArchives(tracedCtx); err != nil {
+		w.log.Warn("Failed to expire export archives", zap.Error(err))
+	}
+
+	pending, err := w.store.GetPendingRequests(tracedCtx, w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var completed int
+	for _, request := range pending {
+		if err := w.processOne(tracedCtx, request); err != nil {
+			// The request stays pending, so the next tick retries it.
+			w.log.Warn("Failed to process export request", zap.String("user_id", model.UserIDString(request.UserID)), zap.Error(err))
+			continue
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+// processOne assembles and writes a single pending request's archive, then
+// completes the request.
+func (w *Worker) processOne(ctx context.Context, request *Request) error {
+	log := w.log.With(zap.String("user_id", model.UserIDString(request.UserID)))
+
+	archive, err := buildArchive(ctx, request.UserID, w.exporters, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := w.storage.PutOrigin(ctx, ArchiveKey(request.UserID), ArchiveMimeType, archive); err != nil {
+		return err
+	}
+	// The key is reused across exports, so an edge may still hold the last
+	// archive.
+	if err := w.storage.InvalidateCDN(ctx, ArchivePrefix(request.UserID)); err != nil {
+		return err
+	}
+
+	err = w.store.CompleteRequest(ctx, request.UserID, time.Now())
+	if errors.Is(err, ErrNotPending) {
+		// Another instance finished it first.
+		return nil
+	} else if errors.Is(err, ErrRequestNotFound) {
+		// The account was deleted while the archive was assembled, after its
+		// deletion step removed any archive; this one must not outlive it.
+		return DeleteArchive(ctx, w.storage, request.UserID)
+	} else if err != nil {
+		return err
+	}
+	log.Info("Data export completed", zap.Int("archive_bytes", len(archive)))
+	return nil
+}
+
+// expireArchives deletes the archives of requests completed longer ago than the
+// retention period, then marks the requests expired. The archive goes first, so
+// a request whose deletion fails is picked up again next tick.
+func (w *Worker) expireArchives(ctx context.Context) error {
+	completedBefore := time.Now().Add(-w.retention)
+	requests, err := w.store.GetCompletedRequests(ctx, completedBefore, w.batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		if err := DeleteArchive(ctx, w.storage, request.UserID); err != nil {
+			return err
+		}
+		err := w.store.ExpireRequest(ctx, request.UserID, completedBefore)
+		if errors.Is(err, ErrNotExpirable) || errors.Is(err, ErrRequestNotFound) {
+			// Renewed or deleted since it was read.
+			continue
+		} else if err != nil {
+			return err
+		}
+		w.log.Info("Data export expired", zap.String("user_id", model.UserIDString(request.UserID)))
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	chatpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/chat/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+	profilepb "github.com/code-payments/flipcash2-protobuf-api/generated/go/profile/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/blob"
+	blob_memory "github.com/code-payments/flipcash2-server/blob/memory"
+	blocklist_memory "github.com/code-payments/flipcash2-server/blocklist/memory"
+	"github.com/code-payments/flipcash2-server/chat"
+	chat_memory "github.com/code-payments/flipcash2-server/chat/memory"
+	contact_memory "github.com/code-payments/flipcash2-server/contact/memory"
+	"github.com/code-payments/flipcash2-server/export"
+	"github.com/code-payments/flipcash2-server/export/memory"
+	messaging_memory "github.com/code-payments/flipcash2-server/messaging/memory"
+	"github.com/code-payments/flipcash2-server/model"
+	profile_memory "github.com/code-payments/flipcash2-server/profile/memory"
+	settings_memory "github.com/code-payments/flipcash2-server/settings/memory"
+)
+
+func requestExport(t *testing.T, ctx context.Context, server *export.Server, signer model.KeyPair) (*export.Request, error) {
+	req := &export.RequestExportRequest{Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return server.RequestExport(ctx, req)
+}
+
+func getExport(t *testing.T, ctx context.Context, server *export.Server, signer model.KeyPair) (*export.Request, *blobpb.DownloadUrl, error) {
+	req := &export.GetExportRequest{Ts: time.Now()}
+	require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+	return server.GetExport(ctx, req)
+}
+
+func requireCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+}
+
+// readArchive unzips the user's archive from storage into its files.
+func readArchive(t *testing.T, storage blob.ObjectStorage, userID *commonpb.UserId) map[string][]byte {
+	data, err := storage.GetOrigin(context.Background(), export.ArchiveKey(userID))
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		files[f.Name] = contents
+	}
+	return files
+}
+
+// requireConforms checks a section against the top level of its schema: every
+// required property is present, and no property the schema does not declare.
+func requireConforms(t *testing.T, files map[string][]byte, section string) map[string]any {
+	var schema struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(files["schemas/"+section+".schema.json"], &schema), section)
+
+	var document map[string]any
+	require.NoError(t, json.Unmarshal(files[section+".json"], &document), section)
+	for _, required := range schema.Required {
+		require.Contains(t, document, required, section)
+	}
+	for property := range document {
+		require.Contains(t, schema.Properties, property, section)
+	}
+	return document
+}
+
+func TestWorker_ExportsEverySection(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	profiles := profile_memory.NewInMemory()
+	prefs := settings_memory.NewInMemory()
+	contacts := contact_memory.NewInMemory()
+	blocklists := blocklist_memory.NewInMemory()
+	chats := chat_memory.NewInMemory()
+	messages := messaging_memory.NewInMemory()
+	blobs := blob_memory.NewInMemory()
+	storage := blob_memory.NewInMemoryStorage()
+
+	user, userKey := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	other := model.MustGenerateUserID()
+
+	require.NoError(t, profiles.SetDisplayName(ctx, user, "name"))
+	picture := blob.MustGenerateID()
+	require.NoError(t, profiles.SetProfilePicture(ctx, user, picture))
+	require.NoError(t, profiles.LinkPhoneNumber(ctx, user, "+12223334444", &commonpb.Hash{Value: []byte("phone-hash")}))
+	require.NoError(t, profiles.LinkEmailAddress(ctx, user, "someone@gmail.com"))
+	require.NoError(t, profiles.LinkXAccount(ctx, user, &profilepb.XProfile{Id: "123", Username: "someone", Name: "Someone"}, "access-token"))
+
+	require.NoError(t, prefs.SetRegion(ctx, user, &commonpb.Region{Value: "cad"}))
+
+	hashes := []*commonpb.Hash{{Value: []byte("contact-1")}, {Value: []byte("contact-2")}}
+	require.NoError(t, contacts.Replace(ctx, user, hashes, &commonpb.Hash{Value: []byte("checksum")}))
+
+	blockedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	_, err := blocklists.Block(ctx, user, other, blockedAt)
+	require.NoError(t, err)
+
+	key, err := blob.StorageKey(picture, "image/png")
+	require.NoError(t, err)
+	require.NoError(t, blobs.CreatePending(ctx, &blob.Blob{
+		ID:         picture,
+		Rendition:  blob.RenditionOriginal,
+		Owner:      user,
+		State:      blob.StatePending,
+		StorageKey: key,
+		MimeType:   "image/png",
+		SizeBytes:  1234,
+	}))
+
+	chatID := &commonpb.ChatId{Value: append(append([]byte{}, user.Value...), other.Value...)}
+	require.NoError(t, chats.PutChat(ctx, &chat.Chat{
+		ID:           chatID,
+		Type:         chatpb.ChatType_CONTACT_DM,
+		Members:      []*commonpb.UserId{user, other},
+		LastActivity: time.Now(),
+	}))
+	putMessage := func(senderID *commonpb.UserId, text string) *messagingpb.MessageId {
+		clientID := make([]byte, 16)
+		_, err := rand.Read(clientID)
+		require.NoError(t, err)
+		content := []*messagingpb.Content{{
+			Type: &messagingpb.Content_Text{Text: &messagingpb.TextContent{Text: text}},
+		}}
+		msg, _, err := messages.PutMessage(ctx, chatID, senderID, content, time.Now().UTC(), &messagingpb.ClientMessageId{Value: clientID}, true)
+		require.NoError(t, err)
+		return msg.ID
+	}
+	sent := putMessage(user, "from the user")
+	received := putMessage(other, "to the user")
+	_, _, _, err = messages.AddReaction(ctx, chatID, received, user, "👍", time.Now())
+	require.NoError(t, err)
+	_, _, _, err = messages.AddReaction(ctx, chatID, sent, other, "🔥", time.Now())
+	require.NoError(t, err)
+
+	authz := auth.NewStaticAuthorizer(log)
+	authz.Add(user, userKey)
+
+	store := memory.NewInMemory()
+	server := export.NewServer(log, authz, store, storage)
+	worker := export.NewWorker(log, store, storage, []export.Exporter{
+		export.NewProfileExporter(profiles),
+		export.NewIdentitiesExporter(profiles),
+		export.NewSettingsExporter(prefs),
+		export.NewContactsExporter(contacts),
+		export.NewBlocklistExporter(blocklists),
+		export.NewChatsExporter(chats, messages),
+		export.NewBlobsExporter(blobs),
+	})
+
+	_, _, err = getExport(t, ctx, server, userKey)
+	requireCode(t, err, codes.NotFound)
+
+	request, err := requestExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+	require.Equal(t, export.StatePending, request.State)
+
+	request, downloadURL, err := getExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+	require.Equal(t, export.StatePending, request.State)
+	require.Nil(t, downloadURL)
+
+	completed, err := worker.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, completed)
+
+	request, downloadURL, err = getExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+	require.Equal(t, export.StateCompleted, request.State)
+	require.NotNil(t, downloadURL)
+	require.True(t, strings.HasSuffix(downloadURL.Url, export.ArchiveKey(user)))
+	require.Contains(t, storage.Invalidations(), export.ArchivePrefix(user))
+
+	files := readArchive(t, storage, user)
+
+	var manifest map[string]any
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	require.Equal(t, model.UserIDString(user), manifest["userId"])
+	require.Equal(t, []any{
+		export.SectionProfile,
+		export.SectionIdentities,
+		export.SectionSettings,
+		export.SectionContacts,
+		export.SectionBlocklist,
+		export.SectionChats,
+		export.SectionBlobs,
+	}, manifest["sections"])
+	require.Contains(t, files, "schemas/manifest.schema.json")
+
+	profileDoc := requireConforms(t, files, export.SectionProfile)
+	require.Equal(t, "name", profileDoc["displayName"])
+	require.Equal(t, blob.IDString(picture), profileDoc["profilePictureId"])
+
+	identities := requireConforms(t, files, export.SectionIdentities)
+	require.Equal(t, "+12223334444", identities["phoneNumber"])
+	require.Equal(t, "someone@gmail.com", identities["emailAddress"])
+	require.Equal(t, "someone", identities["xAccount"].(map[string]any)["username"])
+
+	settingsDoc := requireConforms(t, files, export.SectionSettings)
+	require.Equal(t, "cad", settingsDoc["region"])
+	require.Equal(t, "en", settingsDoc["locale"])
+
+	contactsDoc := requireConforms(t, files, export.SectionContacts)
+	require.EqualValues(t, 2, contactsDoc["phoneNumberHashCount"])
+
+	blocklistDoc := requireConforms(t, files, export.SectionBlocklist)
+	blocked := blocklistDoc["blocked"].([]any)
+	require.Len(t, blocked, 1)
+	require.Equal(t, model.UserIDString(other), blocked[0].(map[string]any)["userId"])
+
+	chatsDoc := requireConforms(t, files, export.SectionChats)
+	exportedChats := chatsDoc["chats"].([]any)
+	require.Len(t, exportedChats, 1)
+	exportedChat := exportedChats[0].(map[string]any)
+	require.Equal(t, chatpb.ChatType_CONTACT_DM.String(), exportedChat["type"])
+
+	// Only the user's own messages and reactions are exported
+	sentMessages := exportedChat["sentMessages"].([]any)
+	require.Len(t, sentMessages, 1)
+	sentMessage := sentMessages[0].(map[string]any)
+	require.EqualValues(t, sent.Value, sentMessage["messageId"])
+	require.Contains(t, string(files[export.SectionChats+".json"]), "from the user")
+	require.NotContains(t, string(files[export.SectionChats+".json"]), "to the user")
+
+	reactions := exportedChat["reactions"].([]any)
+	require.Len(t, reactions, 1)
+	require.EqualValues(t, received.Value, reactions[0].(map[string]any)["messageId"])
+	require.Equal(t, "👍", reactions[0].(map[string]any)["emoji"])
+
+	blobsDoc := requireConforms(t, files, export.SectionBlobs)
+	exportedBlobs := blobsDoc["blobs"].([]any)
+	require.Len(t, exportedBlobs, 1)
+	require.Equal(t, blob.IDString(picture), exportedBlobs[0].(map[string]any)["blobId"])
+
+	// A completed request is not picked up again
+	completed, err = worker.Process(ctx)
+	require.NoError(t, err)
+	require.Zero(t, completed)
+}
+
+func TestWorker_RetriesFailedExport(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	failing := true
+	storage := blob_memory.NewInMemoryStorage()
+	store := memory.NewInMemory()
+	worker := export.NewWorker(log, store, storage, []export.Exporter{
+		export.ExporterFunc("flaky", `{"type": "object"}`, func(context.Context, *commonpb.UserId) (any, error) {
+			if failing {
+				return nil, errors.New("export failed")
+			}
+			return map[string]string{}, nil
+		}),
+	})
+
+	user := model.MustGenerateUserID()
+	require.NoError(t, store.CreateRequest(ctx, export.NewRequest(user)))
+
+	completed, err := worker.Process(ctx)
+	require.NoError(t, err)
+	require.Zero(t, completed)
+
+	request, err := store.GetRequest(ctx, user)
+	require.NoError(t, err)
+	require.Equal(t, export.StatePending, request.State)
+	_, err = storage.GetOrigin(ctx, export.ArchiveKey(user))
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+
+	failing = false
+
+	completed, err = worker.Process(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, completed)
+
+	files := readArchive(t, storage, user)
+	require.Contains(t, files, "flaky.json")
+	require.Contains(t, files, "schemas/flaky.schema.json")
+}
+
+func TestWorker_ExpiresArchives(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	storage := blob_memory.NewInMemoryStorage()
+	store := memory.NewInMemory()
+	authz := auth.NewStaticAuthorizer(log)
+	server := export.NewServer(log, authz, store, storage)
+
+	user, userKey := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	authz.Add(user, userKey)
+	_, err := requestExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+
+	retained := export.NewWorker(log, store, storage, nil, export.WithArchiveRetention(time.Hour))
+	for range 2 {
+		_, err = retained.Process(ctx)
+		require.NoError(t, err)
+	}
+	request, downloadURL, err := getExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+	require.Equal(t, export.StateCompleted, request.State)
+	require.NotNil(t, downloadURL)
+
+	// Past the retention period, the archive is deleted and purged
+	expiring := export.NewWorker(log, store, storage, nil, export.WithArchiveRetention(0))
+	_, err = expiring.Process(ctx)
+	require.NoError(t, err)
+
+	request, downloadURL, err = getExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+	require.Equal(t, export.StateExpired, request.State)
+	require.Nil(t, downloadURL)
+	_, err = storage.GetOrigin(ctx, export.ArchiveKey(user))
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+	require.Contains(t, storage.Invalidations(), export.ArchivePrefix(user))
+
+	// An expired export can be requested afresh
+	request, err = requestExport(t, ctx, server, userKey)
+	require.NoError(t, err)
+	require.Equal(t, export.StatePending, request.State)
+}
+
+func TestWorker_RequestDeletedDuringExport(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	storage := blob_memory.NewInMemoryStorage()
+	store := memory.NewInMemory()
+	user := model.MustGenerateUserID()
+
+	// The account's deletion step runs while the archive is assembled
+	worker := export.NewWorker(log, store, storage, []export.Exporter{
+		export.ExporterFunc("racing", `{"type": "object"}`, func(ctx context.Context, userID *commonpb.UserId) (any, error) {
+			if err := store.DeleteRequest(ctx, userID); err != nil {
+				return nil, err
+			}
+			return map[string]string{}, export.DeleteArchive(ctx, storage, userID)
+		}),
+	})
+	require.NoError(t, store.CreateRequest(ctx, export.NewRequest(user)))
+
+	_, err := worker.Process(ctx)
+	require.NoError(t, err)
+
+	_, err = store.GetRequest(ctx, user)
+	require.ErrorIs(t, err, export.ErrRequestNotFound)
+	_, err = storage.GetOrigin(ctx, export.ArchiveKey(user))
+	require.ErrorIs(t, err, blob.ErrObjectNotFound)
+}
+
+func TestServer_Cooldown(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	storage := blob_memory.NewInMemoryStorage()
+	store := memory.NewInMemory()
+	worker := export.NewWorker(log, store, storage, nil)
+	authz := auth.NewStaticAuthorizer(log)
+
+	user, userKey := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	authz.Add(user, userKey)
+
+	for _, tc := range []struct {
+		cooldown time.Duration
+		renewed  bool
+	}{
+		{cooldown: time.Hour, renewed: false},
+		{cooldown: 0, renewed: true},
+	} {
+		server := export.NewServer(log, authz, store, storage, export.WithCooldown(tc.cooldown))
+
+		first, err := requestExport(t, ctx, server, userKey)
+		require.NoError(t, err)
+
+		// Requesting again while pending returns the pending request unchanged
+		again, err := requestExport(t, ctx, server, userKey)
+		require.NoError(t, err)
+		require.True(t, first.RequestedAt.Equal(again.RequestedAt))
+
+		_, err = worker.Process(ctx)
+		require.NoError(t, err)
+
+		next, err := requestExport(t, ctx, server, userKey)
+		require.NoError(t, err)
+		if tc.renewed {
+			require.Equal(t, export.StatePending, next.State)
+			_, err = worker.Process(ctx)
+			require.NoError(t, err)
+		} else {
+			require.Equal(t, export.StateCompleted, next.State)
+		}
+	}
+}
@@ -125,6 +125,15 @@ func (c *Cache) GetMessage(ctx context.Context, chatID *commonpb.ChatId, message
 	return msg, err
 }
 
+func (c *Cache) GetMessageByClientID(ctx context.Context, chatID *commonpb.ChatId, clientMessageID *messagingpb.ClientMessageId) (*messaging.Message, error) {
+	msg, err := c.db.GetMessageByClientID(ctx, chatID, clientMessageID)
+	if err == nil {
+		// A returned message is a confirmed existing ID for the chat.
+		c.observe(chatID, msg.ID.Value)
+	}
+	return msg, err
+}
+
 func (c *Cache) GetMessages(ctx context.Context, chatID *commonpb.ChatId, opts ...database.QueryOption) ([]*messaging.Message, error) {
 	msgs, err := c.db.GetMessages(ctx, chatID, opts...)
 	if err == nil {
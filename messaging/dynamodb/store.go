@@ -724,6 +724,30 @@ func (s *store) getMessage(ctx context.Context, chatID *commonpb.ChatId, message
 	return messageFromItem(chatID, out.Item)
 }
 
+func (s *store) GetMessageByClientID(ctx context.Context, chatID *commonpb.ChatId, clientMessageID *messagingpb.ClientMessageId) (*messaging.Message, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.messagesTable),
+		Key: map[string]types.AttributeValue{
+			attrPK: avS(chatPK(chatID)),
+			attrSK: avS(cmidSK(clientMessageID)),
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, messaging.ErrMessageNotFound
+	}
+	seq, err := parseN(out.Item[attrSeq])
+	if err != nil {
+		return nil, err
+	}
+	// As on PutMessage's idempotent path: the marker was read strongly-consistent,
+	// so read the message it points at the same way.
+	return s.getMessage(ctx, chatID, &messagingpb.MessageId{Value: seq}, true)
+}
+
 func (s *store) MessageExists(ctx context.Context, chatID *commonpb.ChatId, messageID *messagingpb.MessageId) (bool, error) {
 	// Project to pk only so the content blobs are never read or decoded.
 	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
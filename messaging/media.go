@@ -13,11 +13,13 @@ import (
 	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
 
 	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/database"
 )
 
 // Media is the blob-side integration messaging uses for media: it shares the
-// blobs a message references into the chat on send (ShareIntoChat) and resolves
-// their rendition sets on read (ResolveRenditions). It is implemented by
+// blobs a message references into the chat on send (ShareIntoChat), revokes the
+// chat's access when the message is deleted (RevokeFromChat), and resolves their
+// rendition sets on read (ResolveRenditions). It is implemented by
 // blob.Integration.
 //
 // ShareIntoChat returns blob.ErrBlobNotShareable when a referenced blob may not be
@@ -27,6 +29,7 @@ import (
 // set keyed by string(BlobId.Value), omitting unknown or not-yet-READY ids.
 type Media interface {
 	ShareIntoChat(ctx context.Context, sharerID *commonpb.UserId, chatID *commonpb.ChatId, blobIDs []*blobpb.BlobId) error
+	RevokeFromChat(ctx context.Context, chatID *commonpb.ChatId, blobIDs []*blobpb.BlobId) error
 	ResolveRenditions(ctx context.Context, ids []*blobpb.BlobId) (map[string][]*blobpb.Rendition, error)
 }
 
@@ -72,6 +75,58 @@ func (s *Server) shareMessageMedia(ctx context.Context, log *zap.Logger, senderI
 	return false, nil
 }
 
+// revokePageSize bounds each page of the chat read while looking for other
+// messages that still reference a deleted message's media.
+const revokePageSize = 100
+
+// revokeMessageMedia withdraws the chat's read access to the blobs a deleted
+// message carried. The grant is per (blob, chat), not per message, so a blob
+// another live message in the chat still references keeps its grant; finding
+// those pages through the chat, which is only done when the message carried
+// media. It runs once the tombstone has committed, so a deletion that loses to a
+// concurrent edit leaves the surviving message's media readable.
+func revokeMessageMedia(ctx context.Context, messages Store, media Media, chatID *commonpb.ChatId, deleted *Message) error {
+	blobIDs := mediaBlobIDs(deleted.Content)
+	if len(blobIDs) == 0 || media == nil {
+		return nil
+	}
+
+	unreferenced := make(map[string]*blobpb.BlobId, len(blobIDs))
+	for _, id := range blobIDs {
+		unreferenced[string(id.Value)] = id
+	}
+	opts := []database.QueryOption{database.WithAscending(), database.WithLimit(revokePageSize)}
+	for len(unreferenced) > 0 {
+		page, err := messages.GetMessages(ctx, chatID, opts...)
+		if err != nil {
+			return err
+		}
+		for _, msg := range page {
+			if msg.ID.Value == deleted.ID.Value || msg.IsDeleted() {
+				continue
+			}
+			for _, id := range mediaBlobIDs(msg.Content) {
+				delete(unreferenced, string(id.Value))
+			}
+		}
+		if len(page) < revokePageSize {
+			break
+		}
+		opts = append(opts, database.WithPagingToken(PageTokenFromID(page[len(page)-1].ID)))
+	}
+	if len(unreferenced) == 0 {
+		return nil
+	}
+
+	revoked := make([]*blobpb.BlobId, 0, len(unreferenced))
+	for _, id := range blobIDs {
+		if _, ok := unreferenced[string(id.Value)]; ok {
+			revoked = append(revoked, id)
+		}
+	}
+	return media.RevokeFromChat(ctx, chatID, revoked)
+}
+
 // mediaBlobIDs returns the blob ids a message references — whether the media is
 // the message body or the body of a reply — or nil when there is no media. It
 // assumes the content already passed clientAllowedContent.
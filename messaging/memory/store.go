@@ -191,6 +191,21 @@ func (m *memory) GetMessage(_ context.Context, chatID *commonpb.ChatId, messageI
 	return msg.Clone(), nil
 }
 
+func (m *memory) GetMessageByClientID(_ context.Context, chatID *commonpb.ChatId, clientMessageID *messagingpb.ClientMessageId) (*messaging.Message, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	cs := m.chats[string(chatID.Value)]
+	if cs == nil {
+		return nil, messaging.ErrMessageNotFound
+	}
+	seq, ok := cs.byClient[string(clientMessageID.Value)]
+	if !ok {
+		return nil, messaging.ErrMessageNotFound
+	}
+	return cs.messages[seq].Clone(), nil
+}
+
 func (m *memory) EditMessage(
 	_ context.Context,
 	chatID *commonpb.ChatId,
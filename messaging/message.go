@@ -132,6 +132,27 @@ func (s *Server) SendMessage(ctx context.Context, req *messagingpb.SendMessageRe
 		}
 	}
 
+	// A retry of a send that was already persisted returns the original message
+	// without screening its text, or sharing its media, again.
+	if msgProto, err := s.sender.getRetried(ctx, log, req.ChatId, req.ClientMessageId); err != nil {
+		return nil, err
+	} else if msgProto != nil {
+		return &messagingpb.SendMessageResponse{
+			Result:  messagingpb.SendMessageResponse_OK,
+			Message: msgProto,
+		}, nil
+	}
+
+	// Text is screened before any media is shared, so a caption moderation refuses
+	// leaves no grant behind. Refused text is reported as DENIED, since the
+	// messaging protos have no result of its own for it.
+	verdict, err := s.sender.screenText(ctx, log, req.ChatId, userID, req.Content)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "")
+	} else if verdict.blocked {
+		return &messagingpb.SendMessageResponse{Result: messagingpb.SendMessageResponse_DENIED}, nil
+	}
+
 	// Share any media into the chat — validate the sender owns each blob and it is
 	// a READY original, then grant the chat read access — before the message is
 	// persisted and broadcast, so the grants are durable before any recipient can
@@ -142,7 +163,7 @@ func (s *Server) SendMessage(ctx context.Context, req *messagingpb.SendMessageRe
 		return &messagingpb.SendMessageResponse{Result: messagingpb.SendMessageResponse_DENIED}, nil
 	}
 
-	msgProto, err := s.sender.Send(ctx, req.ChatId, userID, req.Content, req.ClientMessageId, true)
+	msgProto, err := s.sender.send(ctx, req.ChatId, userID, req.Content, req.ClientMessageId, true, verdict)
 	if err != nil {
 		return nil, err
 	}
@@ -212,6 +233,15 @@ func (s *Server) EditMessage(ctx context.Context, req *messagingpb.EditMessageRe
 		}
 	}
 
+	// The replacement text is screened as a send's is, before any media is shared,
+	// and refused text is likewise reported as DENIED.
+	verdict, err := s.sender.screenText(ctx, log, req.ChatId, userID, req.Content)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "")
+	} else if verdict.blocked {
+		return &messagingpb.EditMessageResponse{Result: messagingpb.EditMessageResponse_DENIED}, nil
+	}
+
 	// New media on an edit is shared into the chat just like a send, before the
 	// edit is persisted and broadcast.
 	if denied, err := s.shareMessageMedia(ctx, log, userID, req.ChatId, req.Content); err != nil {
@@ -240,6 +270,8 @@ func (s *Server) EditMessage(ctx context.Context, req *messagingpb.EditMessageRe
 		return nil, status.Error(codes.Internal, "")
 	}
 
+	s.sender.applyTextVerdict(ctx, log, req.ChatId, updated, verdict)
+
 	// The edit rides only the event log: no new_messages (so no push, and no spurious
 	// "new message" on pre-event-log clients) and no unread/pointer change. Members
 	// apply the edit live via the message_edited event, or pick it up on their next
@@ -336,6 +368,13 @@ func (s *Server) DeleteMessage(ctx context.Context, req *messagingpb.DeleteMessa
 		return nil, status.Error(codes.Internal, "")
 	}
 
+	// The media the message carried stops being readable through the chat. The
+	// tombstone has already committed, so a failure here must not keep members from
+	// learning of the deletion below.
+	if err := revokeMessageMedia(ctx, s.messages, s.media, req.ChatId, msg); err != nil {
+		log.With(zap.Error(err)).Warn("Failure revoking media from chat")
+	}
+
 	// The tombstone rides only the event log: no new_messages (so no push, and no
 	// spurious "new message" on pre-event-log clients) and no unread/pointer change.
 	// Members apply the deletion live via the message_deleted event, or pick it up
@@ -0,0 +1,301 @@
+package messaging
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	chatpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/chat/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/chat"
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+const (
+	// textScanTimeout bounds the background classification and tombstoning of a
+	// message sent under TextModerationTombstone.
+	textScanTimeout = 30 * time.Second
+
+	// defaultTextScanConcurrency bounds how many background text scans run at
+	// once (see WithTextScanConcurrency).
+	defaultTextScanConcurrency = 16
+)
+
+// ErrTextBlocked is returned by Sender.Send when the message's text is refused
+// under TextModerationBlock.
+var ErrTextBlocked = errors.New("message text blocked by moderation")
+
+// errMessageChanged is returned by tombstone when the message was edited or
+// deleted after the event sequence it was asked to tombstone.
+var errMessageChanged = errors.New("message changed")
+
+// TextModerationAction is what text moderation does with a message's text.
+type TextModerationAction int
+
+const (
+	// TextModerationOff leaves text unclassified.
+	TextModerationOff TextModerationAction = iota
+
+	// TextModerationBlock classifies text before it is written and refuses it if
+	// flagged.
+	TextModerationBlock
+
+	// TextModerationFlag classifies text before it is written and lets it through
+	// if flagged, recording the flag for staff to review.
+	TextModerationFlag
+
+	// TextModerationTombstone writes text unclassified, then classifies it in the
+	// background and tombstones the message, on the system's behalf, if flagged.
+	TextModerationTombstone
+)
+
+// TextModerationPolicy is the text moderation applied to one chat type.
+type TextModerationPolicy struct {
+	Action TextModerationAction
+
+	// FailClosed refuses text the moderation client fails to classify, or
+	// tombstones it under TextModerationTombstone. By default such text is let
+	// through. Text in a language the client does not support is always let
+	// through.
+	FailClosed bool
+}
+
+// SenderOption configures optional Sender behavior.
+type SenderOption func(*Sender)
+
+// WithTextModeration classifies the text of user-authored messages, and of
+// edits, with moderator, under the policy of the chat's type. Chat types without
+// a policy are not moderated. Text is a message's body, a media caption, or
+// either as the body of a reply.
+func WithTextModeration(moderator moderation.Client, policies map[chatpb.ChatType]TextModerationPolicy) SenderOption {
+	return func(s *Sender) {
+		s.moderator = moderator
+		s.textPolicies = policies
+	}
+}
+
+// WithTextScanConcurrency bounds how many messages sent under
+// TextModerationTombstone are scanned in the background at once. A write that
+// finds every scan busy waits for one to finish, for as long as its other side
+// effects may take, and past that leaves its message unscanned. Defaults to 16.
+func WithTextScanConcurrency(n int) SenderOption {
+	return func(s *Sender) {
+		s.scanConcurrency = n
+	}
+}
+
+// WithModerationAudit records every message text moderation flags to audit: a
+// refused text under DecisionRejected (which its sender may appeal), a flagged
+// message under DecisionFlagged, and a tombstoned one under DecisionTombstoned.
+func WithModerationAudit(audit moderation.AuditStore) SenderOption {
+	return func(s *Sender) {
+		s.audit = audit
+	}
+}
+
+// textVerdict is the outcome of screening a message's text before it is
+// written.
+type textVerdict struct {
+	// blocked refuses the write.
+	blocked bool
+
+	// flag, when set, is recorded against the message once it is written.
+	flag *moderation.AuditRecord
+
+	// scan classifies the message's text in the background once it is written,
+	// under policy.
+	scan   bool
+	policy TextModerationPolicy
+}
+
+// screenText applies the text moderation policy of the chat's type to content
+// senderID is about to write. A system message, content without text, or a chat
+// type without a policy passes unscreened. The only error is a failure to look up
+// the chat, which is logged.
+func (s *Sender) screenText(ctx context.Context, log *zap.Logger, chatID *commonpb.ChatId, senderID *commonpb.UserId, content []*messagingpb.Content) (*textVerdict, error) {
+	verdict := &textVerdict{}
+	if s.moderator == nil || senderID == nil {
+		return verdict, nil
+	}
+	text := messageText(content)
+	if strings.TrimSpace(text) == "" {
+		return verdict, nil
+	}
+
+	policy, err := s.textPolicy(ctx, chatID)
+	if err != nil {
+		log.With(zap.Error(err)).Warn("Failure getting chat for text moderation")
+		return nil, err
+	}
+	switch policy.Action {
+	case TextModerationBlock, TextModerationFlag:
+	case TextModerationTombstone:
+		verdict.scan = true
+		verdict.policy = policy
+		return verdict, nil
+	default:
+		return verdict, nil
+	}
+
+	result, err := s.moderator.ClassifyText(ctx, text)
+	switch {
+	case errors.Is(err, moderation.ErrUnsupportedLanguage):
+		return verdict, nil
+	case err != nil:
+		log.With(zap.Error(err), zap.Bool("fail_closed", policy.FailClosed)).Warn("Failure classifying message text")
+		verdict.blocked = policy.FailClosed
+		return verdict, nil
+	case !result.Flagged:
+		return verdict, nil
+	}
+
+	record := moderation.NewAuditRecord(senderID, moderation.MethodText, moderation.ClassifierName(s.moderator, moderation.MethodText), []byte(text), result)
+	if policy.Action == TextModerationBlock {
+		log.Info("Message text blocked", zap.Strings("categories", result.FlaggedCategories))
+		verdict.blocked = true
+		s.recordFlag(ctx, log, record)
+		return verdict, nil
+	}
+	record.Decision = moderation.DecisionFlagged
+	verdict.flag = record
+	return verdict, nil
+}
+
+// applyTextVerdict carries out what remains of verdict once msg is written:
+// recording its flag, or starting its background scan.
+func (s *Sender) applyTextVerdict(ctx context.Context, log *zap.Logger, chatID *commonpb.ChatId, msg *Message, verdict *textVerdict) {
+	if verdict.flag != nil {
+		log.Info("Message text flagged", zap.Strings("categories", verdict.flag.FlaggedCategories))
+		verdict.flag.ChatID = chatID
+		verdict.flag.MessageID = msg.ID
+		s.recordFlag(ctx, log, verdict.flag)
+	}
+	if verdict.scan {
+		s.startTextScan(ctx, log, chatID, msg.Clone(), verdict.policy)
+	}
+}
+
+// startTextScan runs scanText for msg once one of the Sender's scan slots is
+// free. It waits up to sideEffectTimeout for one, detached from the caller's
+// cancellation, and otherwise drops the scan.
+func (s *Sender) startTextScan(ctx context.Context, log *zap.Logger, chatID *commonpb.ChatId, msg *Message, policy TextModerationPolicy) {
+	waitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sideEffectTimeout)
+	defer cancel()
+
+	select {
+	case s.scanSlots <- struct{}{}:
+	case <-waitCtx.Done():
+		log.Warn("Text scans saturated, message left unscanned", zap.Uint64("message_id", msg.ID.Value))
+		return
+	}
+
+	s.scans.Go(func() {
+		defer func() { <-s.scanSlots }()
+		s.scanText(ctx, log, chatID, msg, policy)
+	})
+}
+
+// WaitForTextScans blocks until every background text scan started so far has
+// finished. It is for graceful shutdown and tests.
+func (s *Sender) WaitForTextScans() {
+	s.scans.Wait()
+}
+
+// scanText classifies the text of msg, as written, and tombstones it if flagged.
+// A message edited or deleted since is left alone: an edit is scanned in its
+// own right.
+func (s *Sender) scanText(ctx context.Context, log *zap.Logger, chatID *commonpb.ChatId, msg *Message, policy TextModerationPolicy) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), textScanTimeout)
+	defer cancel()
+
+	log = log.With(zap.Uint64("message_id", msg.ID.Value))
+
+	var record *moderation.AuditRecord
+	text := messageText(msg.Content)
+	result, err := s.moderator.ClassifyText(ctx, text)
+	switch {
+	case errors.Is(err, moderation.ErrUnsupportedLanguage):
+		return
+	case err != nil:
+		log.With(zap.Error(err), zap.Bool("fail_closed", policy.FailClosed)).Warn("Failure classifying message text")
+		if !policy.FailClosed {
+			return
+		}
+	case !result.Flagged:
+		return
+	default:
+		record = moderation.NewAuditRecord(msg.SenderID, moderation.MethodText, moderation.ClassifierName(s.moderator, moderation.MethodText), []byte(text), result)
+		record.Decision = moderation.DecisionTombstoned
+		record.ChatID = chatID
+		record.MessageID = msg.ID
+	}
+
+	_, err = s.tombstone(ctx, chatID, msg.ID, msg.EventSequence)
+	switch {
+	case errors.Is(err, errMessageChanged), errors.Is(err, ErrMessageNotFound):
+		log.Info("Message changed before it could be tombstoned")
+		return
+	case err != nil:
+		log.With(zap.Error(err)).Warn("Failure tombstoning message")
+		return
+	}
+	log.Info("Message tombstoned by text moderation")
+
+	if record != nil {
+		s.recordFlag(ctx, log, record)
+	}
+}
+
+// textPolicy returns the text moderation policy of the chat's type. A chat that
+// no longer exists has none.
+func (s *Sender) textPolicy(ctx context.Context, chatID *commonpb.ChatId) (TextModerationPolicy, error) {
+	if len(s.textPolicies) == 0 {
+		return TextModerationPolicy{}, nil
+	}
+	c, err := s.chats.GetChatByID(ctx, chatID)
+	if errors.Is(err, chat.ErrChatNotFound) {
+		return TextModerationPolicy{}, nil
+	} else if err != nil {
+		return TextModerationPolicy{}, err
+	}
+	return s.textPolicies[c.Type], nil
+}
+
+// recordFlag writes record to the audit store, if there is one. The verdict
+// stands whether or not it is recorded, so a failure is only logged.
+func (s *Sender) recordFlag(ctx context.Context, log *zap.Logger, record *moderation.AuditRecord) {
+	if s.audit == nil {
+		return
+	}
+	if err := s.audit.PutAuditRecord(ctx, record); err != nil {
+		log.With(zap.Error(err)).Warn("Failure recording moderation flag")
+	}
+}
+
+// messageText returns the user-authored text of content — the text body or a
+// media caption, of the message or of its reply — or "" when there is none.
+func messageText(content []*messagingpb.Content) string {
+	if len(content) != 1 {
+		return ""
+	}
+	body := content[0]
+	if reply, ok := body.Type.(*messagingpb.Content_Reply); ok {
+		if len(reply.Reply.Content) != 1 {
+			return ""
+		}
+		body = reply.Reply.Content[0]
+	}
+	switch c := body.Type.(type) {
+	case *messagingpb.Content_Text:
+		return c.Text.GetText()
+	case *messagingpb.Content_Media:
+		return c.Media.GetCaption().GetText()
+	default:
+		return ""
+	}
+}
@@ -2,6 +2,7 @@ package messaging
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,6 +21,7 @@ import (
 	"github.com/code-payments/flipcash2-server/chat"
 	"github.com/code-payments/flipcash2-server/event"
 	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/moderation"
 	"github.com/code-payments/flipcash2-server/profile"
 	"github.com/code-payments/flipcash2-server/push"
 	ocp_data "github.com/code-payments/ocp-server/ocp/data"
@@ -55,6 +57,21 @@ type Sender struct {
 	pusher push.Pusher
 
 	eventBus *event.Bus[*commonpb.UserId, *eventpb.Event]
+
+	// moderator and textPolicies moderate message text (see WithTextModeration).
+	// They are optional; when unset, text is not moderated.
+	moderator    moderation.Client
+	textPolicies map[chatpb.ChatType]TextModerationPolicy
+
+	// audit records text moderation flags (see WithModerationAudit). It is
+	// optional; when nil, flags leave no audit record.
+	audit moderation.AuditStore
+
+	// scanSlots bounds the background text scans in flight to scanConcurrency
+	// (see WithTextScanConcurrency); scans tracks them.
+	scanConcurrency int
+	scanSlots       chan struct{}
+	scans           sync.WaitGroup
 }
 
 func NewSender(
@@ -68,8 +85,9 @@ func NewSender(
 	ocpData ocp_data.Provider,
 	pusher push.Pusher,
 	eventBus *event.Bus[*commonpb.UserId, *eventpb.Event],
+	opts ...SenderOption,
 ) *Sender {
-	return &Sender{
+	s := &Sender{
 		log:        log,
 		badges:     badges,
 		chats:      chats,
@@ -80,7 +98,14 @@ func NewSender(
 		ocpData:    ocpData,
 		pusher:     pusher,
 		eventBus:   eventBus,
+
+		scanConcurrency: defaultTextScanConcurrency,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.scanSlots = make(chan struct{}, s.scanConcurrency)
+	return s
 }
 
 // Send persists content as a message in the chat and performs every side effect
@@ -98,6 +123,10 @@ func NewSender(
 // idempotent on (chatID, clientMessageID): a retry returns the originally
 // persisted message and skips the side effects, which already ran on the first
 // send — re-running them would duplicate pushes to members.
+//
+// With text moderation enabled (see WithTextModeration), a user-authored
+// message's text is screened before it is persisted, and ErrTextBlocked is
+// returned if it is refused.
 func (s *Sender) Send(
 	ctx context.Context,
 	chatID *commonpb.ChatId,
@@ -105,6 +134,20 @@ func (s *Sender) Send(
 	content []*messagingpb.Content,
 	clientMessageID *messagingpb.ClientMessageId,
 	countsTowardUnread bool,
+) (*messagingpb.Message, error) {
+	return s.send(ctx, chatID, senderID, content, clientMessageID, countsTowardUnread, nil)
+}
+
+// send is Send, taking the verdict of text screening the caller already did, or
+// nil to screen here.
+func (s *Sender) send(
+	ctx context.Context,
+	chatID *commonpb.ChatId,
+	senderID *commonpb.UserId,
+	content []*messagingpb.Content,
+	clientMessageID *messagingpb.ClientMessageId,
+	countsTowardUnread bool,
+	verdict *textVerdict,
 ) (*messagingpb.Message, error) {
 	log := s.log
 	if senderID != nil {
@@ -127,6 +170,25 @@ func (s *Sender) Send(
 		return nil, errors.Wrap(err, "client message id failed validation")
 	}
 
+	if verdict == nil {
+		// A retry is answered before screening, so its text isn't classified
+		// again. The caller that passes a verdict has already checked.
+		msgProto, err := s.getRetried(ctx, log, chatID, clientMessageID)
+		if err != nil {
+			return nil, err
+		} else if msgProto != nil {
+			return msgProto, nil
+		}
+
+		verdict, err = s.screenText(ctx, log, chatID, senderID, content)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "")
+		}
+	}
+	if verdict.blocked {
+		return nil, ErrTextBlocked
+	}
+
 	msg, created, err := s.messages.PutMessage(ctx, chatID, senderID, content, time.Now().UTC(), clientMessageID, countsTowardUnread)
 	if err != nil {
 		log.With(zap.Error(err)).Warn("Failure persisting message")
@@ -135,15 +197,8 @@ func (s *Sender) Send(
 
 	// Build the message proto once and resolve its media metadata, so the proto
 	// returned to the caller (the SendMessage response) and the one broadcast to
-	// members carry the same hydrated message. Best-effort and a no-op for
-	// media-free sends; the message is already persisted, so a resolution failure
-	// just leaves Blob unset for clients to re-fetch.
-	msgProto := msg.ToProto()
-	if s.media != nil {
-		if err := hydrateMedia(ctx, s.media, []*messagingpb.Message{msgProto}); err != nil {
-			log.With(zap.Error(err)).Warn("Failure resolving media metadata")
-		}
-	}
+	// members carry the same hydrated message.
+	msgProto := s.hydratedProto(ctx, log, msg)
 
 	// A retried send (same client message ID) already ran every side effect when
 	// the message was first persisted — most importantly the push to members.
@@ -162,6 +217,8 @@ func (s *Sender) Send(
 	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sideEffectTimeout)
 	defer cancel()
 
+	s.applyTextVerdict(ctx, log, chatID, msg, verdict)
+
 	// The sender has implicitly read their own message, so advance their READ
 	// pointer past it. The target is the message we just persisted, so its
 	// existence is guaranteed — advance directly without a separate existence read.
@@ -215,3 +272,102 @@ func (s *Sender) Send(
 
 	return msgProto, nil
 }
+
+// getRetried returns the message a prior send with clientMessageID persisted,
+// as send returns it, or nil if there is none.
+func (s *Sender) getRetried(ctx context.Context, log *zap.Logger, chatID *commonpb.ChatId, clientMessageID *messagingpb.ClientMessageId) (*messagingpb.Message, error) {
+	msg, err := s.messages.GetMessageByClientID(ctx, chatID, clientMessageID)
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		return nil, nil
+	case err != nil:
+		log.With(zap.Error(err)).Warn("Failure getting message by client message id")
+		return nil, status.Error(codes.Internal, "")
+	}
+	return s.hydratedProto(ctx, log, msg), nil
+}
+
+// hydratedProto builds msg's proto and resolves its media metadata. Resolution
+// is best-effort and a no-op for media-free messages; the message is already
+// persisted, so a failure just leaves Blob unset for clients to re-fetch.
+func (s *Sender) hydratedProto(ctx context.Context, log *zap.Logger, msg *Message) *messagingpb.Message {
+	msgProto := msg.ToProto()
+	if s.media != nil {
+		if err := hydrateMedia(ctx, s.media, []*messagingpb.Message{msgProto}); err != nil {
+			log.With(zap.Error(err)).Warn("Failure resolving media metadata")
+		}
+	}
+	return msgProto
+}
+
+// maxTombstoneAttempts bounds how many times Tombstone retries a deletion that
+// lost to a concurrent edit.
+const maxTombstoneAttempts = 3
+
+// ErrMessageNotDeletable is returned by Sender.Tombstone for a message that is
+// not ordinary chat content (see Message.IsDeletable).
+var ErrMessageNotDeletable = errors.New("message not deletable")
+
+// Tombstone deletes a message on the system's behalf — moderation acting on
+// abusive content, say — rather than its sender's. It performs the side effects
+// of the DeleteMessage RPC: the chat's read access to the message's media is
+// revoked, and the tombstone, attributed to no one, is broadcast to members as a
+// message_deleted event. Unlike the RPC it takes no expected event sequence: a
+// deletion that loses to a concurrent edit is retried against the edited
+// message, since the intent is to remove whatever the message now says. It is
+// idempotent, returning the existing tombstone for a message already deleted.
+//
+// ErrMessageNotFound is returned for a missing message, and
+// ErrMessageNotDeletable for one that is not ordinary chat content.
+func (s *Sender) Tombstone(ctx context.Context, chatID *commonpb.ChatId, messageID *messagingpb.MessageId) (*messagingpb.Message, error) {
+	return s.tombstone(ctx, chatID, messageID, 0)
+}
+
+// tombstone is Tombstone. A non-zero atEventSeq tombstones the message only as of
+// that event sequence, returning errMessageChanged once it has been edited or
+// deleted since.
+func (s *Sender) tombstone(ctx context.Context, chatID *commonpb.ChatId, messageID *messagingpb.MessageId, atEventSeq uint64) (*messagingpb.Message, error) {
+	for attempt := 1; ; attempt++ {
+		msg, err := s.messages.GetMessage(ctx, chatID, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if atEventSeq != 0 && msg.EventSequence != atEventSeq {
+			return nil, errMessageChanged
+		}
+		if msg.IsDeleted() {
+			return msg.ToProto(), nil
+		}
+		if !msg.IsDeletable() {
+			return nil, ErrMessageNotDeletable
+		}
+
+		updated, err := s.messages.DeleteMessage(ctx, chatID, messageID, nil, time.Now().UTC(), msg.EventSequence)
+		if errors.Is(err, ErrEventSequenceConflict) {
+			if updated.IsDeleted() {
+				return updated.ToProto(), nil
+			}
+			if attempt < maxTombstoneAttempts {
+				continue
+			}
+			return nil, err
+		} else if err != nil {
+			return nil, err
+		}
+
+		// The tombstone is durable; as with a send, its side effects must not be
+		// aborted by the caller going away.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sideEffectTimeout)
+		defer cancel()
+
+		if err := revokeMessageMedia(ctx, s.messages, s.media, chatID, msg); err != nil {
+			s.log.With(zap.Error(err)).Warn("Failure revoking media from chat")
+		}
+
+		updatedProto := updated.ToProto()
+		publishChatUpdate(ctx, s.log, s.badges, s.chats, s.profiles, s.blocklists, s.ocpData, s.pusher, s.eventBus, chatID, &eventpb.ChatUpdate{
+			Events: &messagingpb.EventBatch{Events: []*messagingpb.Event{NewMessageDeletedEvent(updatedProto)}},
+		}, nil, nil)
+		return updatedProto, nil
+	}
+}
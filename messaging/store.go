@@ -139,6 +139,13 @@ type Store interface {
 	// GetMessage returns a single message by ID, or ErrMessageNotFound.
 	GetMessage(ctx context.Context, chatID *commonpb.ChatId, messageID *messagingpb.MessageId) (*Message, error)
 
+	// GetMessageByClientID returns the message a prior PutMessage persisted with
+	// clientMessageID, or ErrMessageNotFound. It lets a retried send be answered
+	// before any per-send work (e.g. content screening) is repeated. A miss is not
+	// authoritative once the idempotency marker has expired; PutMessage remains
+	// the source of truth.
+	GetMessageByClientID(ctx context.Context, chatID *commonpb.ChatId, clientMessageID *messagingpb.ClientMessageId) (*Message, error)
+
 	// MessageExists reports whether a message exists in the chat. It is a
 	// lightweight existence check that does not read or decode the message body,
 	// for callers (e.g. the reaction read paths) that only need to distinguish a
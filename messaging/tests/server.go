@@ -3,8 +3,10 @@ package tests
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -30,6 +32,9 @@ import (
 	"github.com/code-payments/flipcash2-server/event"
 	"github.com/code-payments/flipcash2-server/messaging"
 	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/moderation"
+	moderation_memory "github.com/code-payments/flipcash2-server/moderation/memory"
+	"github.com/code-payments/flipcash2-server/moderation/noop"
 	"github.com/code-payments/flipcash2-server/profile"
 	"github.com/code-payments/flipcash2-server/testutil"
 	ocp_data "github.com/code-payments/ocp-server/ocp/data"
@@ -49,6 +54,11 @@ func RunServerTests(t *testing.T, badges badge.Store, blocklists blocklist.Store
 		testServer_SendMessage_Broadcast,
 		testServer_EditMessage,
 		testServer_DeleteMessage,
+		testServer_Tombstone,
+		testServer_TextModeration_Block,
+		testServer_TextModeration_Flag,
+		testServer_TextModeration_Tombstone,
+		testServer_TextModeration_ScanConcurrency,
 		testServer_GetMessage_NotFound,
 		testServer_GetMessages_NotFound,
 		testServer_GetMessages_Paging,
@@ -91,12 +101,13 @@ type serverEnv struct {
 	keysB  model.KeyPair
 
 	blocklist blocklist.Store
+	sender    *messaging.Sender
 
 	blobStore  blob.Store
 	blobAccess blob.AccessStore
 }
 
-func newServerEnv(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) *serverEnv {
+func newServerEnv(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store, opts ...messaging.SenderOption) *serverEnv {
 	ctx := context.Background()
 	log := zaptest.NewLogger(t)
 
@@ -130,7 +141,8 @@ func newServerEnv(t *testing.T, badges badge.Store, blocklists blocklist.Store,
 	env.blobAccess = blobAccess
 	media := blob.NewIntegration(blobStore, blob_memory.NewInMemoryStorage(), blobAccess)
 
-	sender := messaging.NewSender(log, badges, chats, messages, profiles, blocklists, media, ocp_data.NewTestDataProvider(), env.pusher, bus)
+	sender := messaging.NewSender(log, badges, chats, messages, profiles, blocklists, media, ocp_data.NewTestDataProvider(), env.pusher, bus, opts...)
+	env.sender = sender
 	server := messaging.NewServer(log, authz, chats, messages, media, sender)
 	cc := testutil.RunGRPCServer(t, log, testutil.WithService(func(s *grpc.Server) {
 		messagingpb.RegisterMessagingServer(s, server)
@@ -975,6 +987,367 @@ func testServer_DeleteMessage(t *testing.T, badges badge.Store, blocklists block
 	cannot, err := e.deleteMessage(e.keysA, sysMsg.ID, sysMsg.EventSequence)
 	require.NoError(t, err)
 	require.Equal(t, messagingpb.DeleteMessageResponse_CANNOT_DELETE, cannot.Result)
+
+	// Deleting a media message revokes the chat's read grant on its blobs.
+	ownedBlob := e.putReadyBlob(e.userA)
+	media, err := e.sendContent(e.keysA, mediaContent(ownedBlob), generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, media.Result)
+	require.True(t, e.chatGrantedRead(ownedBlob))
+
+	// A delete that conflicts leaves the grant in place.
+	conflictMedia, err := e.deleteMessage(e.keysA, media.Message.MessageId, media.Message.EventSequence+1)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.DeleteMessageResponse_CONFLICT, conflictMedia.Result)
+	require.True(t, e.chatGrantedRead(ownedBlob))
+
+	// While another live message attaches the same blob, the grant stays.
+	again, err := e.sendContent(e.keysA, mediaContent(ownedBlob), generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, again.Result)
+
+	deletedMedia, err := e.deleteMessage(e.keysA, media.Message.MessageId, media.Message.EventSequence)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.DeleteMessageResponse_OK, deletedMedia.Result)
+	require.True(t, e.chatGrantedRead(ownedBlob))
+
+	deletedAgain, err := e.deleteMessage(e.keysA, again.Message.MessageId, again.Message.EventSequence)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.DeleteMessageResponse_OK, deletedAgain.Result)
+	require.False(t, e.chatGrantedRead(ownedBlob))
+}
+
+func testServer_Tombstone(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) {
+	e := newServerEnv(t, badges, blocklists, chats, messages, profiles)
+
+	sent, err := e.send(e.keysA, "abusive", generateClientID())
+	require.NoError(t, err)
+	msgID := sent.Message.MessageId
+
+	// An edit since the caller last looked doesn't stop a system deletion.
+	edited, err := e.editMessage(e.keysA, msgID, textContent("still abusive"), sent.Message.EventSequence)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.EditMessageResponse_OK, edited.Result)
+
+	tombstone, err := e.sender.Tombstone(e.ctx, e.chatID, msgID)
+	require.NoError(t, err)
+	deleted := tombstone.Content[0].GetDeleted()
+	require.NotNil(t, deleted)
+	require.Nil(t, deleted.DeletedBy)
+	e.waitForMessageDeleted(e.userB, msgID.Value)
+
+	// Idempotent: the existing tombstone comes back without a new event.
+	again, err := e.sender.Tombstone(e.ctx, e.chatID, msgID)
+	require.NoError(t, err)
+	require.Equal(t, tombstone.EventSequence, again.EventSequence)
+
+	_, err = e.sender.Tombstone(e.ctx, e.chatID, &messagingpb.MessageId{Value: 999})
+	require.ErrorIs(t, err, messaging.ErrMessageNotFound)
+
+	sysMsg, _, err := messages.PutMessage(e.ctx, e.chatID, nil, systemContent("system"), at(50), generateClientID(), false)
+	require.NoError(t, err)
+	_, err = e.sender.Tombstone(e.ctx, e.chatID, sysMsg.ID)
+	require.ErrorIs(t, err, messaging.ErrMessageNotDeletable)
+
+	// Media the message carried stops being readable through the chat.
+	ownedBlob := e.putReadyBlob(e.userA)
+	media, err := e.sendContent(e.keysA, mediaContent(ownedBlob), generateClientID())
+	require.NoError(t, err)
+	require.True(t, e.chatGrantedRead(ownedBlob))
+	_, err = e.sender.Tombstone(e.ctx, e.chatID, media.Message.MessageId)
+	require.NoError(t, err)
+	require.False(t, e.chatGrantedRead(ownedBlob))
+}
+
+// textModerator flags text containing "bad" and fails to classify text
+// containing "error". Text containing "slow" is held until release is closed.
+type textModerator struct {
+	moderation.Client
+
+	mu         sync.Mutex
+	classified []string
+	release    chan struct{}
+}
+
+func newTextModerator() *textModerator {
+	return &textModerator{Client: noop.NewClient(), release: make(chan struct{})}
+}
+
+func (m *textModerator) ClassifyText(_ context.Context, text string) (*moderation.Result, error) {
+	if strings.Contains(text, "slow") {
+		<-m.release
+	}
+
+	m.mu.Lock()
+	m.classified = append(m.classified, text)
+	m.mu.Unlock()
+
+	switch {
+	case strings.Contains(text, "error"):
+		return nil, errors.New("vendor unavailable")
+	case strings.Contains(text, "bad"):
+		return &moderation.Result{
+			Flagged:           true,
+			FlaggedCategories: []string{"harassment"},
+			CategoryScores:    map[string]float64{"harassment": 0.9},
+		}, nil
+	}
+	return &moderation.Result{}, nil
+}
+
+func (m *textModerator) hasClassified(text string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Contains(m.classified, text)
+}
+
+func (m *textModerator) timesClassified(text string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int
+	for _, classified := range m.classified {
+		if classified == text {
+			n++
+		}
+	}
+	return n
+}
+
+func captionedMediaContent(blobID *blobpb.BlobId, caption string) []*messagingpb.Content {
+	content := mediaContent(blobID)
+	content[0].GetMedia().Caption = &messagingpb.TextContent{Text: caption}
+	return content
+}
+
+func testServer_TextModeration_Block(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) {
+	moderator := newTextModerator()
+	audit := moderation_memory.NewInMemory()
+	e := newServerEnv(t, badges, blocklists, chats, messages, profiles,
+		messaging.WithTextModeration(moderator, map[chatpb.ChatType]messaging.TextModerationPolicy{
+			chatpb.ChatType_CONTACT_DM: {Action: messaging.TextModerationBlock, FailClosed: true},
+		}),
+		messaging.WithModerationAudit(audit),
+	)
+
+	sent, err := e.send(e.keysA, "hello", generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, sent.Result)
+
+	blocked, err := e.send(e.keysA, "bad words", generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_DENIED, blocked.Result)
+	require.Nil(t, blocked.Message)
+
+	records, err := audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, moderation.MethodText, records[0].Method)
+	require.Equal(t, moderation.DecisionRejected, records[0].Decision)
+	require.Nil(t, records[0].MessageID)
+
+	// Fail closed: text that can't be classified is refused, but isn't a flag.
+	blocked, err = e.send(e.keysA, "error", generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_DENIED, blocked.Result)
+
+	// A reply's text and a caption are screened, and a refused caption leaves its
+	// media unshared.
+	reply := []*messagingpb.Content{{Type: &messagingpb.Content_Reply{Reply: &messagingpb.ReplyContent{
+		RepliedMessageId: sent.Message.MessageId,
+		Content:          textContent("bad reply"),
+	}}}}
+	blocked, err = e.sendContent(e.keysA, reply, generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_DENIED, blocked.Result)
+
+	ownedBlob := e.putReadyBlob(e.userA)
+	blocked, err = e.sendContent(e.keysA, captionedMediaContent(ownedBlob, "bad caption"), generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_DENIED, blocked.Result)
+	require.False(t, e.chatGrantedRead(ownedBlob))
+
+	resp, err := e.getMessagesByOptions(e.keysA, &commonpb.QueryOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{sent.Message.MessageId.Value}, protoMessageIDs(resp.Messages.Messages))
+
+	// An edit is held to the same policy, and leaves the message as it was.
+	edited, err := e.editMessage(e.keysA, sent.Message.MessageId, textContent("bad edit"), sent.Message.EventSequence)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.EditMessageResponse_DENIED, edited.Result)
+	got, err := e.getMessage(e.keysA, sent.Message.MessageId)
+	require.NoError(t, err)
+	require.Equal(t, "hello", got.Message.Content[0].GetText().Text)
+
+	records, err = audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	// Internal sends are screened too.
+	_, err = e.sender.Send(e.ctx, e.chatID, e.userA, textContent("bad internal"), generateClientID(), true)
+	require.ErrorIs(t, err, messaging.ErrTextBlocked)
+
+	// A chat type without a policy is not moderated.
+	tipChatID := generateChatID()
+	require.NoError(t, chats.PutChat(e.ctx, &chat.Chat{
+		ID:           tipChatID,
+		Type:         chatpb.ChatType_TIP_DM,
+		Members:      []*commonpb.UserId{e.userA, e.userB},
+		LastActivity: at(1),
+	}))
+	sent, err = e.sendContentToChat(e.keysA, tipChatID, textContent("bad tip"), generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, sent.Result)
+	require.False(t, moderator.hasClassified("bad tip"))
+}
+
+func testServer_TextModeration_Flag(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) {
+	moderator := newTextModerator()
+	audit := moderation_memory.NewInMemory()
+	e := newServerEnv(t, badges, blocklists, chats, messages, profiles,
+		messaging.WithTextModeration(moderator, map[chatpb.ChatType]messaging.TextModerationPolicy{
+			chatpb.ChatType_CONTACT_DM: {Action: messaging.TextModerationFlag},
+		}),
+		messaging.WithModerationAudit(audit),
+	)
+
+	clientID := generateClientID()
+	sent, err := e.send(e.keysA, "bad words", clientID)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, sent.Result)
+	e.waitForNewMessage(e.userB, sent.Message.MessageId.Value)
+
+	// A retry returns the persisted message without classifying its text, or
+	// flagging it, again.
+	retried, err := e.send(e.keysA, "bad words", clientID)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, retried.Result)
+	require.Equal(t, sent.Message.MessageId.Value, retried.Message.MessageId.Value)
+	retriedMsg, err := e.sender.Send(e.ctx, e.chatID, e.userA, textContent("bad words"), clientID, true)
+	require.NoError(t, err)
+	require.Equal(t, sent.Message.MessageId.Value, retriedMsg.MessageId.Value)
+	require.Equal(t, 1, moderator.timesClassified("bad words"))
+
+	records, err := audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, moderation.DecisionFlagged, records[0].Decision)
+	require.Equal(t, e.chatID.Value, records[0].ChatID.Value)
+	require.Equal(t, sent.Message.MessageId.Value, records[0].MessageID.Value)
+
+	// Fail open: text that can't be classified is let through unflagged.
+	unclassified, err := e.send(e.keysA, "error", generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, unclassified.Result)
+
+	edited, err := e.editMessage(e.keysA, sent.Message.MessageId, textContent("still bad"), sent.Message.EventSequence)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.EditMessageResponse_OK, edited.Result)
+
+	records, err = audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, moderation.DecisionFlagged, records[0].Decision)
+	require.Equal(t, sent.Message.MessageId.Value, records[0].MessageID.Value)
+}
+
+func testServer_TextModeration_Tombstone(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) {
+	moderator := newTextModerator()
+	audit := moderation_memory.NewInMemory()
+	e := newServerEnv(t, badges, blocklists, chats, messages, profiles,
+		messaging.WithTextModeration(moderator, map[chatpb.ChatType]messaging.TextModerationPolicy{
+			chatpb.ChatType_CONTACT_DM: {Action: messaging.TextModerationTombstone, FailClosed: true},
+		}),
+		messaging.WithModerationAudit(audit),
+	)
+
+	// Flagged text is sent, then tombstoned on the system's behalf.
+	sent, err := e.send(e.keysA, "bad words", generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, sent.Result)
+	e.waitForMessageDeleted(e.userB, sent.Message.MessageId.Value)
+	got, err := e.getMessage(e.keysA, sent.Message.MessageId)
+	require.NoError(t, err)
+	require.Nil(t, got.Message.Content[0].GetDeleted().DeletedBy)
+
+	require.Eventually(t, func() bool {
+		records, err := audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+		require.NoError(t, err)
+		return len(records) == 1
+	}, time.Second, 10*time.Millisecond)
+	records, err := audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+	require.NoError(t, err)
+	require.Equal(t, moderation.DecisionTombstoned, records[0].Decision)
+	require.Equal(t, sent.Message.MessageId.Value, records[0].MessageID.Value)
+
+	// Fail closed: text that can't be classified is tombstoned too.
+	unclassified, err := e.send(e.keysA, "error", generateClientID())
+	require.NoError(t, err)
+	e.waitForMessageDeleted(e.userB, unclassified.Message.MessageId.Value)
+
+	clean, err := e.send(e.keysA, "hello", generateClientID())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return moderator.hasClassified("hello") }, time.Second, 10*time.Millisecond)
+
+	// A message edited while its scan runs is left to the edit's own scan.
+	slow, err := e.send(e.keysA, "slow bad", generateClientID())
+	require.NoError(t, err)
+	edited, err := e.editMessage(e.keysA, slow.Message.MessageId, textContent("fine"), slow.Message.EventSequence)
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.EditMessageResponse_OK, edited.Result)
+	close(moderator.release)
+	require.Eventually(t, func() bool {
+		return moderator.hasClassified("slow bad") && moderator.hasClassified("fine")
+	}, time.Second, 10*time.Millisecond)
+
+	// Let any stray tombstone land before checking nothing else was deleted.
+	time.Sleep(50 * time.Millisecond)
+	for _, msgID := range []*messagingpb.MessageId{clean.Message.MessageId, slow.Message.MessageId} {
+		got, err := e.getMessage(e.keysA, msgID)
+		require.NoError(t, err)
+		require.Nil(t, got.Message.Content[0].GetDeleted())
+	}
+
+	records, err = audit.GetAuditRecordsByUser(e.ctx, e.userA, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+}
+
+func testServer_TextModeration_ScanConcurrency(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) {
+	moderator := newTextModerator()
+	e := newServerEnv(t, badges, blocklists, chats, messages, profiles,
+		messaging.WithTextModeration(moderator, map[chatpb.ChatType]messaging.TextModerationPolicy{
+			chatpb.ChatType_CONTACT_DM: {Action: messaging.TextModerationTombstone},
+		}),
+		messaging.WithTextScanConcurrency(1),
+	)
+
+	// The only scan slot is held by a scan that can't finish yet.
+	slow, err := e.send(e.keysA, "slow bad", generateClientID())
+	require.NoError(t, err)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, slow.Result)
+
+	// So the next message's scan waits for it rather than running alongside.
+	queued := make(chan error, 1)
+	var sent *messagingpb.SendMessageResponse
+	go func() {
+		var err error
+		sent, err = e.send(e.keysA, "bad words", generateClientID())
+		queued <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.False(t, moderator.hasClassified("bad words"))
+
+	close(moderator.release)
+	require.NoError(t, <-queued)
+	require.Equal(t, messagingpb.SendMessageResponse_OK, sent.Result)
+	e.sender.WaitForTextScans()
+	require.True(t, moderator.hasClassified("bad words"))
+
+	for _, msgID := range []*messagingpb.MessageId{slow.Message.MessageId, sent.Message.MessageId} {
+		got, err := e.getMessage(e.keysA, msgID)
+		require.NoError(t, err)
+		require.NotNil(t, got.Message.Content[0].GetDeleted())
+	}
 }
 
 func testServer_GetMessages_NotFound(t *testing.T, badges badge.Store, blocklists blocklist.Store, chats chat.Store, messages messaging.Store, profiles profile.Store) {
@@ -90,6 +90,17 @@ func testStore_PutMessage_Idempotent(t *testing.T, s messaging.Store) {
 	require.Equal(t, first.ID.Value, again.ID.Value)
 	require.True(t, again.Timestamp.Equal(first.Timestamp))
 
+	// The persisted message can be looked up by its client message ID ahead of a
+	// retry.
+	byClient, err := s.GetMessageByClientID(ctx, chatID, clientID)
+	require.NoError(t, err)
+	require.Equal(t, first.ID.Value, byClient.ID.Value)
+	require.Equal(t, "hello", byClient.Content[0].GetText().Text)
+	_, err = s.GetMessageByClientID(ctx, chatID, generateClientID())
+	require.ErrorIs(t, err, messaging.ErrMessageNotFound)
+	_, err = s.GetMessageByClientID(ctx, generateChatID(), clientID)
+	require.ErrorIs(t, err, messaging.ErrMessageNotFound)
+
 	// A different client message ID advances to the next ID.
 	next, created, err := s.PutMessage(ctx, chatID, sender, textContent("world"), at(3), generateClientID(), true)
 	require.NoError(t, err)
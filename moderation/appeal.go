@@ -0,0 +1,306 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+const (
+	maxAppealReasonLength = 1000
+	maxAppealNoteLength   = 1000
+	maxAppealListLimit    = 100
+
+	// appealHoldPeriod is how long a blob under appeal is kept from collection,
+	// which is how long staff have to resolve the appeal and still reinstate it.
+	appealHoldPeriod = 30 * 24 * time.Hour
+)
+
+// Appeal asks staff to review a recorded flag, with the caller's reason. Only
+// the user whose content was flagged may appeal it, and only once.
+func (s *Server) Appeal(ctx context.Context, req *AppealRequest) (*Appeal, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("record_id", req.RecordID),
+	)
+
+	if err := s.requireAppeals(); err != nil {
+		return nil, err
+	}
+
+	record, err := s.audit.GetAuditRecord(ctx, req.RecordID)
+	if errors.Is(err, ErrAuditRecordNotFound) {
+		return nil, status.Error(codes.NotFound, "moderation record not found")
+	} else if err != nil {
+		log.Warn("Failed to get moderation audit record", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to appeal")
+	}
+	return s.appeal(ctx, log, caller, record, req.Reason)
+}
+
+// AppealBlobRejection appeals the moderation rejection of a blob the caller
+// uploaded, which is what a client holding the blob's RejectionMetadata can
+// refer to.
+func (s *Server) AppealBlobRejection(ctx context.Context, req *AppealBlobRejectionRequest) (*Appeal, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("blob_id", blobIDString(req.BlobID)),
+	)
+
+	if err := s.requireAppeals(); err != nil {
+		return nil, err
+	}
+
+	record, err := s.audit.GetAuditRecordByBlob(ctx, req.BlobID)
+	if errors.Is(err, ErrAuditRecordNotFound) {
+		return nil, status.Error(codes.NotFound, "moderation record not found")
+	} else if err != nil {
+		log.Warn("Failed to get moderation audit record", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to appeal")
+	}
+	return s.appeal(ctx, log, caller, record, req.Reason)
+}
+
+func (s *Server) appeal(ctx context.Context, log *zap.Logger, caller *commonpb.UserId, record *AuditRecord, reason string) (*Appeal, error) {
+	// Someone else's record is reported as missing, so its existence is not
+	// confirmed to the caller.
+	if !bytes.Equal(record.UserID.Value, caller.Value) {
+		return nil, status.Error(codes.NotFound, "moderation record not found")
+	}
+	if record.Decision != DecisionRejected {
+		return nil, status.Error(codes.FailedPrecondition, "nothing to appeal")
+	}
+	if utf8.RuneCountInString(reason) > maxAppealReasonLength {
+		return nil, status.Error(codes.InvalidArgument, "reason too long")
+	}
+
+	appeal := &Appeal{
+		ID:        uuid.NewString(),
+		RecordID:  record.ID,
+		UserID:    caller,
+		Reason:    reason,
+		State:     AppealStatePending,
+		CreatedAt: time.Now(),
+	}
+	err := s.appeals.CreateAppeal(ctx, appeal)
+	if errors.Is(err, ErrAppealExists) {
+		return nil, status.Error(codes.AlreadyExists, "already appealed")
+	} else if err != nil {
+		log.Warn("Failed to create moderation appeal", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to appeal")
+	}
+
+	// A rejected blob is collected a grace period after its rejection, so it is
+	// held for the review; otherwise overturning would find nothing to reinstate.
+	// It is held only once the appeal is created, so a repeat appeal cannot
+	// extend the hold. Failing to hold it does not fail the appeal, which an
+	// overturn resolves on the record alone if the blob is collected meanwhile.
+	if record.BlobID != nil && s.blobs != nil {
+		if err := s.blobs.HoldBlob(ctx, record.BlobID, appeal.CreatedAt.Add(appealHoldPeriod)); err != nil {
+			log.Warn("Failed to hold appealed blob", zap.String("blob_id", blobIDString(record.BlobID)), zap.Error(err))
+		}
+	}
+
+	log.Info("Moderation flag appealed", zap.String("appeal_id", appeal.ID))
+	return appeal, nil
+}
+
+// ListAppeals returns a page of appeals in a state, oldest first, so the pending
+// queue is worked in order. A zero limit, or one over the cap, lists a full page.
+// Only staff may call it.
+func (s *Server) ListAppeals(ctx context.Context, req *ListAppealsRequest) ([]*Appeal, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("state", string(req.State)),
+	)
+
+	if err := s.requireAppealsStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxAppealListLimit {
+		limit = maxAppealListLimit
+	}
+
+	appeals, err := s.appeals.GetAppealsByState(ctx, req.State, limit)
+	if err != nil {
+		log.Warn("Failed to get moderation appeals", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list appeals")
+	}
+	return appeals, nil
+}
+
+// GetAuditRecord returns a recorded flag, for staff reviewing an appeal against
+// it. Only staff may call it.
+func (s *Server) GetAuditRecord(ctx context.Context, req *GetAuditRecordRequest) (*AuditRecord, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("record_id", req.RecordID),
+	)
+
+	if err := s.requireAppealsStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+
+	record, err := s.audit.GetAuditRecord(ctx, req.RecordID)
+	if errors.Is(err, ErrAuditRecordNotFound) {
+		return nil, status.Error(codes.NotFound, "moderation record not found")
+	} else if err != nil {
+		log.Warn("Failed to get moderation audit record", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get moderation record")
+	}
+	return record, nil
+}
+
+// ResolveAppeal resolves a pending appeal, upholding the flag or overturning it,
+// with the caller's note. Overturning a blob's rejection reinstates the blob:
+// it is finalized again with moderation bypassed. A blob already collected (its
+// appeal outlasted the hold) cannot be, so the appeal is then overturned on the
+// record alone and the user must upload again. Only staff may call it.
+func (s *Server) ResolveAppeal(ctx context.Context, req *ResolveAppealRequest) (*Appeal, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("appeal_id", req.AppealID),
+		zap.Bool("overturn", req.Overturn),
+	)
+
+	if err := s.requireAppealsStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(req.Note) > maxAppealNoteLength {
+		return nil, status.Error(codes.InvalidArgument, "note too long")
+	}
+
+	appeal, err := s.appeals.GetAppeal(ctx, req.AppealID)
+	if errors.Is(err, ErrAppealNotFound) {
+		return nil, status.Error(codes.NotFound, "appeal not found")
+	} else if err != nil {
+		log.Warn("Failed to get moderation appeal", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to resolve appeal")
+	}
+	if appeal.State != AppealStatePending {
+		return nil, status.Error(codes.FailedPrecondition, "appeal already resolved")
+	}
+
+	state := AppealStateUpheld
+	if req.Overturn {
+		state = AppealStateOverturned
+
+		// Reinstated before the appeal is resolved, so a failure leaves it pending
+		// for a retry. Reinstating is idempotent.
+		if err := s.reinstate(ctx, log, appeal); err != nil {
+			return nil, err
+		}
+	}
+
+	resolvedAt := time.Now()
+	err = s.appeals.ResolveAppeal(ctx, req.AppealID, state, caller, req.Note, resolvedAt)
+	if errors.Is(err, ErrAppealResolved) {
+		return nil, status.Error(codes.FailedPrecondition, "appeal already resolved")
+	} else if err != nil {
+		log.Warn("Failed to resolve moderation appeal", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to resolve appeal")
+	}
+
+	appeal.State = state
+	appeal.ResolvedBy = caller
+	appeal.Note = req.Note
+	appeal.ResolvedAt = resolvedAt
+	log.Info("Moderation appeal resolved")
+	return appeal, nil
+}
+
+// reinstate hands the blob behind an overturned appeal, if any, back to
+// finalization.
+func (s *Server) reinstate(ctx context.Context, log *zap.Logger, appeal *Appeal) error {
+	record, err := s.audit.GetAuditRecord(ctx, appeal.RecordID)
+	if err != nil {
+		log.Warn("Failed to get moderation audit record", zap.Error(err))
+		return status.Error(codes.Internal, "failed to resolve appeal")
+	}
+	if record.BlobID == nil || s.blobs == nil {
+		return nil
+	}
+
+	err = s.blobs.ReinstateBlob(ctx, record.BlobID)
+	if errors.Is(err, ErrBlobNotReinstatable) {
+		log.Info("Blob behind overturned appeal can no longer be reinstated", zap.String("blob_id", blobIDString(record.BlobID)))
+		return nil
+	} else if err != nil {
+		log.Warn("Failed to reinstate blob", zap.String("blob_id", blobIDString(record.BlobID)), zap.Error(err))
+		return status.Error(codes.Internal, "failed to resolve appeal")
+	}
+	log.Info("Reinstated blob behind overturned appeal", zap.String("blob_id", blobIDString(record.BlobID)))
+	return nil
+}
+
+func (s *Server) requireAppeals() error {
+	if s.appeals == nil || s.audit == nil {
+		return status.Error(codes.Unimplemented, "appeals not enabled")
+	}
+	return nil
+}
+
+// requireAppealsStaff gates the staff side of the appeal workflow: it must be
+// enabled, and the caller must be staff.
+func (s *Server) requireAppealsStaff(ctx context.Context, caller *commonpb.UserId, log *zap.Logger) error {
+	if err := s.requireAppeals(); err != nil {
+		return err
+	}
+	isStaff, err := s.accounts.IsStaff(ctx, caller)
+	if err != nil {
+		log.Warn("Failed to check staff status", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check staff status")
+	}
+	if !isStaff {
+		return status.Error(codes.PermissionDenied, "staff only")
+	}
+	return nil
+}
+
+// blobIDString renders a blob id as blob.IDString does, for logs that line up
+// with the blob domain's.
+func blobIDString(id *blobpb.BlobId) string {
+	parsed, err := uuid.FromBytes(id.GetValue())
+	if err != nil {
+		return "<invalid>"
+	}
+	return parsed.String()
+}
@@ -0,0 +1,331 @@
+package moderation_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	moderationpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/moderation/v1"
+
+	"github.com/code-payments/flipcash2-server/account"
+	account_memory "github.com/code-payments/flipcash2-server/account/memory"
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/moderation"
+	"github.com/code-payments/flipcash2-server/moderation/memory"
+)
+
+// flaggingClient flags every text and image it is asked about, unless clean.
+type flaggingClient struct {
+	clean bool
+}
+
+func (c *flaggingClient) result() (*moderation.Result, error) {
+	if c.clean {
+		return &moderation.Result{}, nil
+	}
+	return &moderation.Result{
+		Flagged:           true,
+		FlaggedCategories: []string{"hate"},
+		CategoryScores:    map[string]float64{"hate": 0.9},
+	}, nil
+}
+
+func (c *flaggingClient) ClassifyText(context.Context, string) (*moderation.Result, error) {
+	return c.result()
+}
+
+func (c *flaggingClient) ClassifyImage(context.Context, []byte) (*moderation.Result, error) {
+	return c.result()
+}
+
+func (c *flaggingClient) ClassifyCurrencyName(context.Context, string) (*moderation.Result, error) {
+	return &moderation.Result{}, nil
+}
+
+func (c *flaggingClient) ClassifyDisplayName(context.Context, string) (*moderation.Result, error) {
+	return &moderation.Result{}, nil
+}
+
+func (c *flaggingClient) ClassifierName(moderation.Method) string {
+	return "flagging"
+}
+
+// staffAccounts is an account.Store whose staff are added in the test.
+type staffAccounts struct {
+	account.Store
+	staff sync.Map
+}
+
+func (a *staffAccounts) IsStaff(_ context.Context, userID *commonpb.UserId) (bool, error) {
+	_, ok := a.staff.Load(string(userID.Value))
+	return ok, nil
+}
+
+// fakeBlobs is a moderation.AppealedBlobs recording what it was asked to do.
+type fakeBlobs struct {
+	sync.Mutex
+	held       map[string]time.Time
+	reinstated [][]byte
+	err        error
+}
+
+func (b *fakeBlobs) HoldBlob(_ context.Context, id *blobpb.BlobId, until time.Time) error {
+	b.Lock()
+	defer b.Unlock()
+	b.held[string(id.Value)] = until
+	return nil
+}
+
+func (b *fakeBlobs) ReinstateBlob(_ context.Context, id *blobpb.BlobId) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.reinstated = append(b.reinstated, id.Value)
+	return nil
+}
+
+func TestServer_RecordsFlags(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	authz := auth.NewStaticAuthorizer(log)
+	client := &flaggingClient{}
+	audit := memory.NewInMemory()
+	server := moderation.NewServer(log, authz, client, model.MustGenerateKeyPair(), moderation.WithAuditStore(audit))
+
+	userID := model.MustGenerateUserID()
+	keyPair := model.MustGenerateKeyPair()
+	authz.Add(userID, keyPair)
+
+	textReq := &moderationpb.ModerateTextRequest{Text: "bad content"}
+	require.NoError(t, keyPair.Auth(textReq, &textReq.Auth))
+	textResp, err := server.ModerateText(ctx, textReq)
+	require.NoError(t, err)
+	require.False(t, textResp.IsAllowed)
+
+	imageData := append([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}, []byte("fake-image")...)
+	imageReq := &moderationpb.ModerateImageRequest{ImageData: imageData}
+	require.NoError(t, keyPair.Auth(imageReq, &imageReq.Auth))
+	imageResp, err := server.ModerateImage(ctx, imageReq)
+	require.NoError(t, err)
+	require.False(t, imageResp.IsAllowed)
+
+	records, err := audit.GetAuditRecordsByUser(ctx, userID, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	image, text := records[0], records[1]
+	require.Equal(t, moderation.MethodText, text.Method)
+	require.Equal(t, "flagging", text.Classifier)
+	textHash := sha256.Sum256([]byte("bad content"))
+	require.Equal(t, hex.EncodeToString(textHash[:]), text.ContentHash)
+	require.Equal(t, []string{"hate"}, text.FlaggedCategories)
+	require.Equal(t, map[string]float64{"hate": 0.9}, text.CategoryScores)
+	require.Equal(t, moderation.DecisionRejected, text.Decision)
+	require.Nil(t, text.BlobID)
+
+	require.Equal(t, moderation.MethodImage, image.Method)
+	imageHash := sha256.Sum256(imageData)
+	require.Equal(t, hex.EncodeToString(imageHash[:]), image.ContentHash)
+
+	// Allowed content leaves no record.
+	client.clean = true
+	cleanReq := &moderationpb.ModerateTextRequest{Text: "hello world"}
+	require.NoError(t, keyPair.Auth(cleanReq, &cleanReq.Auth))
+	cleanResp, err := server.ModerateText(ctx, cleanReq)
+	require.NoError(t, err)
+	require.True(t, cleanResp.IsAllowed)
+
+	records, err = audit.GetAuditRecordsByUser(ctx, userID, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+}
+
+func TestServer_Appeals(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	store := memory.NewInMemory()
+	accounts := &staffAccounts{Store: account_memory.NewInMemory()}
+	blobs := &fakeBlobs{held: make(map[string]time.Time)}
+	authz := auth.NewStaticAuthorizer(log)
+	server := moderation.NewServer(log, authz, &flaggingClient{}, model.MustGenerateKeyPair(),
+		moderation.WithAuditStore(store),
+		moderation.WithAppeals(store, accounts, blobs),
+	)
+
+	owner, ownerKey := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	other, otherKey := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	staff, staffKey := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	authz.Add(owner, ownerKey)
+	authz.Add(other, otherKey)
+	authz.Add(staff, staffKey)
+	accounts.staff.Store(string(staff.Value), struct{}{})
+
+	appealRecord := func(t *testing.T, signer model.KeyPair, recordID, reason string) (*moderation.Appeal, error) {
+		req := &moderation.AppealRequest{RecordID: recordID, Reason: reason, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.Appeal(ctx, req)
+	}
+	appealBlob := func(t *testing.T, signer model.KeyPair, blobID *blobpb.BlobId, reason string) (*moderation.Appeal, error) {
+		req := &moderation.AppealBlobRejectionRequest{BlobID: blobID, Reason: reason, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.AppealBlobRejection(ctx, req)
+	}
+	listAppeals := func(t *testing.T, signer model.KeyPair, state moderation.AppealState) ([]*moderation.Appeal, error) {
+		req := &moderation.ListAppealsRequest{State: state, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.ListAppeals(ctx, req)
+	}
+	getRecord := func(t *testing.T, signer model.KeyPair, recordID string) (*moderation.AuditRecord, error) {
+		req := &moderation.GetAuditRecordRequest{RecordID: recordID, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.GetAuditRecord(ctx, req)
+	}
+	resolve := func(t *testing.T, signer model.KeyPair, appealID string, overturn bool, note string) (*moderation.Appeal, error) {
+		req := &moderation.ResolveAppealRequest{AppealID: appealID, Overturn: overturn, Note: note, Ts: time.Now()}
+		require.NoError(t, signer.Auth(req.Payload(), &req.Auth))
+		return server.ResolveAppeal(ctx, req)
+	}
+
+	flagged := &moderation.Result{Flagged: true, FlaggedCategories: []string{"hate"}, CategoryScores: map[string]float64{"hate": 0.9}}
+	putRecord := func(blobID *blobpb.BlobId) *moderation.AuditRecord {
+		record := moderation.NewAuditRecord(owner, moderation.MethodImage, "flagging", []byte("content"), flagged)
+		record.BlobID = blobID
+		require.NoError(t, store.PutAuditRecord(ctx, record))
+		return record
+	}
+	newBlobID := func() *blobpb.BlobId {
+		return &blobpb.BlobId{Value: model.MustGenerateUserID().Value}
+	}
+
+	t.Run("a blob rejection is appealed and overturned", func(t *testing.T) {
+		blobID := newBlobID()
+		record := putRecord(blobID)
+
+		// Only the owner can appeal, and someone else's record is reported missing.
+		_, err := appealBlob(t, otherKey, blobID, "reason")
+		require.Equal(t, codes.NotFound, status.Code(err))
+		_, err = appealBlob(t, ownerKey, newBlobID(), "reason")
+		require.Equal(t, codes.NotFound, status.Code(err))
+		_, err = appealBlob(t, ownerKey, blobID, strings.Repeat("a", 1001))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		appeal, err := appealBlob(t, ownerKey, blobID, "it is a painting")
+		require.NoError(t, err)
+		require.Equal(t, record.ID, appeal.RecordID)
+		require.Equal(t, moderation.AppealStatePending, appeal.State)
+
+		// The blob is held from collection while the appeal is pending.
+		held := blobs.held[string(blobID.Value)]
+		require.True(t, held.After(time.Now().Add(7*24*time.Hour)))
+
+		// A verdict is appealed once, and a repeat does not extend the hold.
+		_, err = appealRecord(t, ownerKey, record.ID, "again")
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+		require.Equal(t, held, blobs.held[string(blobID.Value)])
+
+		// Staff review the queue.
+		_, err = listAppeals(t, ownerKey, moderation.AppealStatePending)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		pending, err := listAppeals(t, staffKey, moderation.AppealStatePending)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		require.Equal(t, appeal.ID, pending[0].ID)
+
+		_, err = getRecord(t, ownerKey, record.ID)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		got, err := getRecord(t, staffKey, record.ID)
+		require.NoError(t, err)
+		require.Equal(t, record.ContentHash, got.ContentHash)
+
+		// Overturning reinstates the blob.
+		_, err = resolve(t, ownerKey, appeal.ID, true, "")
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		resolved, err := resolve(t, staffKey, appeal.ID, true, "not hateful")
+		require.NoError(t, err)
+		require.Equal(t, moderation.AppealStateOverturned, resolved.State)
+		require.Equal(t, staff.Value, resolved.ResolvedBy.Value)
+		require.Equal(t, "not hateful", resolved.Note)
+		require.Equal(t, [][]byte{blobID.Value}, blobs.reinstated)
+
+		stored, err := store.GetAppeal(ctx, appeal.ID)
+		require.NoError(t, err)
+		require.Equal(t, moderation.AppealStateOverturned, stored.State)
+
+		_, err = resolve(t, staffKey, appeal.ID, false, "")
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("an upheld appeal reinstates nothing", func(t *testing.T) {
+		record := putRecord(newBlobID())
+		appeal, err := appealRecord(t, ownerKey, record.ID, "")
+		require.NoError(t, err)
+
+		before := len(blobs.reinstated)
+		resolved, err := resolve(t, staffKey, appeal.ID, false, "")
+		require.NoError(t, err)
+		require.Equal(t, moderation.AppealStateUpheld, resolved.State)
+		require.Len(t, blobs.reinstated, before)
+	})
+
+	t.Run("a failed reinstatement leaves the appeal pending", func(t *testing.T) {
+		record := putRecord(newBlobID())
+		appeal, err := appealRecord(t, ownerKey, record.ID, "")
+		require.NoError(t, err)
+
+		blobs.err = errors.New("unavailable")
+		_, err = resolve(t, staffKey, appeal.ID, true, "")
+		require.Equal(t, codes.Internal, status.Code(err))
+		stored, err := store.GetAppeal(ctx, appeal.ID)
+		require.NoError(t, err)
+		require.Equal(t, moderation.AppealStatePending, stored.State)
+
+		// A blob that can no longer be reinstated is overturned on the record alone.
+		blobs.err = moderation.ErrBlobNotReinstatable
+		resolved, err := resolve(t, staffKey, appeal.ID, true, "")
+		require.NoError(t, err)
+		require.Equal(t, moderation.AppealStateOverturned, resolved.State)
+		blobs.err = nil
+	})
+}
+
+func TestServer_AppealsDisabled(t *testing.T) {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+	authz := auth.NewStaticAuthorizer(log)
+	server := moderation.NewServer(log, authz, &flaggingClient{}, model.MustGenerateKeyPair())
+
+	userID, keyPair := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	authz.Add(userID, keyPair)
+
+	appealReq := &moderation.AppealRequest{RecordID: "record", Ts: time.Now()}
+	require.NoError(t, keyPair.Auth(appealReq.Payload(), &appealReq.Auth))
+	_, err := server.Appeal(ctx, appealReq)
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+
+	listReq := &moderation.ListAppealsRequest{State: moderation.AppealStatePending, Ts: time.Now()}
+	require.NoError(t, keyPair.Auth(listReq.Payload(), &listReq.Auth))
+	_, err = server.ListAppeals(ctx, listReq)
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+
+	resolveReq := &moderation.ResolveAppealRequest{AppealID: "appeal", Overturn: true, Ts: time.Now()}
+	require.NoError(t, keyPair.Auth(resolveReq.Payload(), &resolveReq.Auth))
+	_, err = server.ResolveAppeal(ctx, resolveReq)
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -0,0 +1,186 @@
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+)
+
+var (
+	// ErrAuditRecordNotFound is returned when no audit record matches.
+	ErrAuditRecordNotFound = errors.New("moderation audit record not found")
+
+	// ErrAppealNotFound is returned when no appeal matches.
+	ErrAppealNotFound = errors.New("moderation appeal not found")
+
+	// ErrAppealExists is returned by AppealStore.CreateAppeal when the audit
+	// record was already appealed. A verdict is appealed at most once.
+	ErrAppealExists = errors.New("moderation appeal already exists")
+
+	// ErrAppealResolved is returned by AppealStore.ResolveAppeal when the appeal
+	// was already resolved.
+	ErrAppealResolved = errors.New("moderation appeal already resolved")
+
+	// ErrBlobNotReinstatable is returned by AppealedBlobs.ReinstateBlob when the
+	// blob can no longer be reinstated: it or its bytes were collected, or it is
+	// not a moderation rejection.
+	ErrBlobNotReinstatable = errors.New("blob not reinstatable")
+)
+
+// Decision is what was done with flagged content.
+type Decision string
+
+const (
+	// DecisionRejected means the content was refused: the text or name was not
+	// allowed, or the blob was rejected.
+	DecisionRejected Decision = "rejected"
+
+	// DecisionFlagged means the content was let through and flagged for staff to
+	// review: a chat message sent under a flag-only policy.
+	DecisionFlagged Decision = "flagged"
+
+	// DecisionTombstoned means the content was removed after it was let through:
+	// a chat message tombstoned by a background scan.
+	DecisionTombstoned Decision = "tombstoned"
+)
+
+// AuditRecord is the durable trace of one flag: whose content was flagged, by
+// which classifier, with what scores, and what was done about it. Only a hash of
+// the content is kept.
+type AuditRecord struct {
+	ID string
+
+	UserID *commonpb.UserId
+
+	// Method is the classification that flagged the content, and Classifier the
+	// client that performed it (see ClassifierName).
+	Method     Method
+	Classifier string
+
+	// ContentHash is the hex SHA-256 of the content as the user submitted it.
+	ContentHash string
+
+	FlaggedCategories []string
+	CategoryScores    map[string]float64
+
+	Decision Decision
+
+	// BlobID is set when the flagged content was an uploaded blob, which is what
+	// lets an overturned appeal reinstate it.
+	BlobID *blobpb.BlobId
+
+	// ChatID and MessageID are set when the flagged content was a chat message
+	// that was sent, so staff can find it.
+	ChatID    *commonpb.ChatId
+	MessageID *messagingpb.MessageId
+
+	CreatedAt time.Time
+}
+
+// NewAuditRecord returns a record of result flagging content submitted by userID,
+// with a fresh ID, under DecisionRejected.
+func NewAuditRecord(userID *commonpb.UserId, method Method, classifier string, content []byte, result *Result) *AuditRecord {
+	hash := sha256.Sum256(content)
+	cloned := result.Clone()
+	return &AuditRecord{
+		ID:                uuid.NewString(),
+		UserID:            userID,
+		Method:            method,
+		Classifier:        classifier,
+		ContentHash:       hex.EncodeToString(hash[:]),
+		FlaggedCategories: cloned.FlaggedCategories,
+		CategoryScores:    cloned.CategoryScores,
+		Decision:          DecisionRejected,
+		CreatedAt:         time.Now(),
+	}
+}
+
+// AppealState is where an appeal is in its review.
+type AppealState string
+
+const (
+	AppealStatePending    AppealState = "pending"
+	AppealStateUpheld     AppealState = "upheld"
+	AppealStateOverturned AppealState = "overturned"
+)
+
+// Appeal is a user's request that staff review the verdict behind an audit
+// record.
+type Appeal struct {
+	ID       string
+	RecordID string
+	UserID   *commonpb.UserId
+
+	// Reason is the user's explanation.
+	Reason string
+
+	State AppealState
+
+	// ResolvedBy and Note are the staff member who resolved the appeal and their
+	// note, set with ResolvedAt once State is no longer pending.
+	ResolvedBy *commonpb.UserId
+	Note       string
+
+	CreatedAt  time.Time
+	ResolvedAt time.Time
+}
+
+// AuditStore persists the audit trail of flags.
+type AuditStore interface {
+	// PutAuditRecord records a flag.
+	PutAuditRecord(ctx context.Context, record *AuditRecord) error
+
+	// GetAuditRecord returns the record with the given ID, or
+	// ErrAuditRecordNotFound.
+	GetAuditRecord(ctx context.Context, id string) (*AuditRecord, error)
+
+	// GetAuditRecordByBlob returns the most recent record of a flag on the blob,
+	// or ErrAuditRecordNotFound.
+	GetAuditRecordByBlob(ctx context.Context, id *blobpb.BlobId) (*AuditRecord, error)
+
+	// GetAuditRecordsByUser returns up to limit records of flags on the user's
+	// content, most recent first.
+	GetAuditRecordsByUser(ctx context.Context, userID *commonpb.UserId, limit int) ([]*AuditRecord, error)
+}
+
+// AppealStore persists appeals against flags.
+type AppealStore interface {
+	// CreateAppeal records a pending appeal, or returns ErrAppealExists if its
+	// audit record was already appealed.
+	CreateAppeal(ctx context.Context, appeal *Appeal) error
+
+	// GetAppeal returns the appeal with the given ID, or ErrAppealNotFound.
+	GetAppeal(ctx context.Context, id string) (*Appeal, error)
+
+	// GetAppealsByState returns up to limit appeals in state, oldest first.
+	GetAppealsByState(ctx context.Context, state AppealState, limit int) ([]*Appeal, error)
+
+	// ResolveAppeal moves a pending appeal to state (upheld or overturned),
+	// recording who resolved it, their note, and when. It returns
+	// ErrAppealNotFound if there is no such appeal, and ErrAppealResolved if it
+	// is no longer pending.
+	ResolveAppeal(ctx context.Context, id string, state AppealState, resolvedBy *commonpb.UserId, note string, at time.Time) error
+}
+
+// AppealedBlobs is what the appeal workflow needs of the blob domain, which
+// implements it (that domain depends on this package rather than the reverse).
+type AppealedBlobs interface {
+	// HoldBlob keeps a rejected blob from being collected until at least until,
+	// so an appeal against its rejection can still reinstate it when resolved.
+	// It is a no-op on a blob that is not rejected.
+	HoldBlob(ctx context.Context, id *blobpb.BlobId, until time.Time) error
+
+	// ReinstateBlob puts a blob whose moderation rejection was overturned on
+	// appeal back through finalization, this time without moderation. It is
+	// idempotent, and returns ErrBlobNotReinstatable for a blob it cannot
+	// reinstate.
+	ReinstateBlob(ctx context.Context, id *blobpb.BlobId) error
+}
@@ -0,0 +1,276 @@
+// Package cache implements a moderation.Client decorator that remembers
+// classification results, so identical content — the same currency name, the
+// same avatar re-uploaded — is only sent to the vendor once.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/code-payments/ocp-server/metrics"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+const (
+	defaultTTL        = 24 * time.Hour
+	defaultMaxEntries = 10_000
+
+	cacheLookupEventName      = "ModerationCacheLookup"
+	vendorCallsSavedCountName = "ModerationCacheVendorCallsSaved"
+)
+
+// kind is the classification a result was produced by. It is part of the key,
+// since the same bytes classified as text and as a currency name have unrelated
+// results.
+type kind string
+
+const (
+	kindText         kind = "text"
+	kindImage        kind = "image"
+	kindCurrencyName kind = "currency_name"
+	kindDisplayName  kind = "display_name"
+)
+
+// outcome is how a lookup was answered, as reported in the lookup event.
+type outcome string
+
+const (
+	outcomeHit       outcome = "hit"
+	outcomeSharedHit outcome = "shared_hit"
+	outcomeMiss      outcome = "miss"
+)
+
+// Stats counts how the client's lookups were answered since it was created.
+// Every hit, local or shared, is a vendor call saved.
+type Stats struct {
+	Hits       uint64
+	SharedHits uint64
+	Misses     uint64
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTTL sets how long a result is reused before the content is classified
+// again. Defaults to 24 hours. Vendor models and the thresholds on their scores
+// change over time, so this bounds how stale a reused result can be.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxEntries bounds how many results are held in process, evicting the least
+// recently used past it. Defaults to 10,000.
+func WithMaxEntries(maxEntries int) Option {
+	return func(c *Client) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// WithSharedStore backs the in-process cache with a store shared across server
+// instances (see moderation/postgres), consulted on a local miss and written on
+// every vendor call.
+func WithSharedStore(store moderation.ResultStore) Option {
+	return func(c *Client) {
+		c.shared = store
+	}
+}
+
+// Client is a moderation.Client that caches the results of the client it wraps,
+// keyed by the SHA-256 of the content together with the kind of classification.
+// Only results are cached: an error, ErrUnsupportedLanguage included, always
+// reaches the caller and is retried on the next call.
+//
+// It composes with composite.NewClient either way round: wrap the composite to
+// cache every classification, or wrap only the vendors worth caching before
+// composing them. Cached results are copies, so a caller mutating one cannot
+// corrupt what the next caller is handed.
+//
+// The shared store is an optimization: an error reading or writing it falls back
+// to the wrapped client and is otherwise ignored.
+type Client struct {
+	client     moderation.Client
+	shared     moderation.ResultStore
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+
+	hits       atomic.Uint64
+	sharedHits atomic.Uint64
+	misses     atomic.Uint64
+}
+
+type entry struct {
+	key       string
+	result    *moderation.Result
+	expiresAt time.Time
+}
+
+// NewClient returns a Client caching the results of client.
+func NewClient(client moderation.Client, opts ...Option) *Client {
+	c := &Client{
+		client:     client,
+		ttl:        defaultTTL,
+		maxEntries: defaultMaxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) ClassifyText(ctx context.Context, text string) (*moderation.Result, error) {
+	return c.classify(ctx, kindText, []byte(text), func() (*moderation.Result, error) {
+		return c.client.ClassifyText(ctx, text)
+	})
+}
+
+func (c *Client) ClassifyImage(ctx context.Context, data []byte) (*moderation.Result, error) {
+	return c.classify(ctx, kindImage, data, func() (*moderation.Result, error) {
+		return c.client.ClassifyImage(ctx, data)
+	})
+}
+
+func (c *Client) ClassifyCurrencyName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.classify(ctx, kindCurrencyName, []byte(name), func() (*moderation.Result, error) {
+		return c.client.ClassifyCurrencyName(ctx, name)
+	})
+}
+
+func (c *Client) ClassifyDisplayName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.classify(ctx, kindDisplayName, []byte(name), func() (*moderation.Result, error) {
+		return c.client.ClassifyDisplayName(ctx, name)
+	})
+}
+
+// ClassifierName names the wrapped client's classifier: a cached result is still
+// its verdict.
+func (c *Client) ClassifierName(method moderation.Method) string {
+	return moderation.ClassifierName(c.client, method)
+}
+
+// Stats returns the client's lookup counts so far.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Hits:       c.hits.Load(),
+		SharedHits: c.sharedHits.Load(),
+		Misses:     c.misses.Load(),
+	}
+}
+
+// classify answers from the in-process cache, then the shared store, and only
+// then calls the vendor through classifyFn, caching what it returns.
+func (c *Client) classify(ctx context.Context, k kind, content []byte, classifyFn func() (*moderation.Result, error)) (*moderation.Result, error) {
+	key := cacheKey(k, content)
+
+	if result, ok := c.get(key); ok {
+		c.hits.Add(1)
+		recordLookup(ctx, k, outcomeHit)
+		return result, nil
+	}
+
+	// A shared miss and a shared error alike fall through to the vendor.
+	if c.shared != nil {
+		if result, err := c.shared.GetResult(ctx, key); err == nil {
+			// Held locally for a fresh TTL, a shared result can be reused for up
+			// to twice the TTL after the vendor call that produced it.
+			c.put(key, result)
+			c.sharedHits.Add(1)
+			recordLookup(ctx, k, outcomeSharedHit)
+			return result, nil
+		}
+	}
+
+	c.misses.Add(1)
+	recordLookup(ctx, k, outcomeMiss)
+
+	result, err := classifyFn()
+	if err != nil {
+		return nil, err
+	}
+
+	c.put(key, result)
+	if c.shared != nil {
+		_ = c.shared.PutResult(ctx, key, result, time.Now().Add(c.ttl))
+	}
+	return result, nil
+}
+
+func (c *Client) get(key string) (*moderation.Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if !time.Now().Before(e.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return e.result.Clone(), true
+}
+
+func (c *Client) put(key string, result *moderation.Result) {
+	if c.maxEntries <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{key: key, result: result.Clone(), expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = e
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// cacheKey is the hex SHA-256 of the classification kind and the content. The
+// kind is a fixed set of names with no NUL in them, so the separator keeps two
+// (kind, content) pairs from ever hashing the same input.
+func cacheKey(k kind, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(k))
+	h.Write([]byte{0})
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordLookup emits one event per lookup, from which the hit rate is computed,
+// and counts each hit as a vendor call saved. Both are no-ops when ctx carries no
+// metrics provider.
+func recordLookup(ctx context.Context, k kind, o outcome) {
+	metrics.RecordEvent(ctx, cacheLookupEventName, map[string]any{
+		"kind":    string(k),
+		"outcome": string(o),
+	})
+	if o != outcomeMiss {
+		metrics.RecordCount(ctx, vendorCallsSavedCountName, 1)
+	}
+}
+
+var _ moderation.Client = (*Client)(nil)
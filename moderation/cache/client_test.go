@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+	"github.com/code-payments/flipcash2-server/moderation/memory"
+)
+
+// countingClient flags any content equal to flagged, and counts its calls.
+type countingClient struct {
+	flagged string
+	err     error
+	calls   int
+}
+
+func (c *countingClient) classify(content string) (*moderation.Result, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	if content == c.flagged {
+		return &moderation.Result{
+			Flagged:           true,
+			FlaggedCategories: []string{"violence"},
+			CategoryScores:    map[string]float64{"violence": 0.9},
+		}, nil
+	}
+	return &moderation.Result{CategoryScores: map[string]float64{"violence": 0.01}}, nil
+}
+
+func (c *countingClient) ClassifyText(_ context.Context, text string) (*moderation.Result, error) {
+	return c.classify(text)
+}
+
+func (c *countingClient) ClassifyImage(_ context.Context, data []byte) (*moderation.Result, error) {
+	return c.classify(string(data))
+}
+
+func (c *countingClient) ClassifyCurrencyName(_ context.Context, name string) (*moderation.Result, error) {
+	return c.classify(name)
+}
+
+func (c *countingClient) ClassifyDisplayName(_ context.Context, name string) (*moderation.Result, error) {
+	return c.classify(name)
+}
+
+func TestClient_CachesByContentAndKind(t *testing.T) {
+	ctx := context.Background()
+	vendor := &countingClient{flagged: "bad"}
+	client := NewClient(vendor)
+
+	first, err := client.ClassifyText(ctx, "bad")
+	require.NoError(t, err)
+	require.True(t, first.Flagged)
+
+	second, err := client.ClassifyText(ctx, "bad")
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	require.Equal(t, 1, vendor.calls)
+
+	// The same bytes under another kind of classification are a separate entry.
+	_, err = client.ClassifyCurrencyName(ctx, "bad")
+	require.NoError(t, err)
+	_, err = client.ClassifyImage(ctx, []byte("bad"))
+	require.NoError(t, err)
+	require.Equal(t, 3, vendor.calls)
+
+	// Different content is a miss.
+	clean, err := client.ClassifyText(ctx, "good")
+	require.NoError(t, err)
+	require.False(t, clean.Flagged)
+	require.Equal(t, 4, vendor.calls)
+
+	require.Equal(t, Stats{Hits: 1, Misses: 4}, client.Stats())
+}
+
+func TestClient_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(&countingClient{flagged: "bad"})
+
+	result, err := client.ClassifyDisplayName(ctx, "bad")
+	require.NoError(t, err)
+	result.Flagged = false
+	result.FlaggedCategories[0] = "tampered"
+	result.CategoryScores["violence"] = 0
+
+	cached, err := client.ClassifyDisplayName(ctx, "bad")
+	require.NoError(t, err)
+	require.True(t, cached.Flagged)
+	require.Equal(t, []string{"violence"}, cached.FlaggedCategories)
+	require.Equal(t, 0.9, cached.CategoryScores["violence"])
+}
+
+func TestClient_ErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	vendor := &countingClient{err: moderation.ErrUnsupportedLanguage}
+	client := NewClient(vendor)
+
+	_, err := client.ClassifyText(ctx, "texto")
+	require.ErrorIs(t, err, moderation.ErrUnsupportedLanguage)
+
+	vendor.err = errors.New("unavailable")
+	_, err = client.ClassifyText(ctx, "texto")
+	require.Error(t, err)
+
+	vendor.err = nil
+	_, err = client.ClassifyText(ctx, "texto")
+	require.NoError(t, err)
+	require.Equal(t, 3, vendor.calls)
+}
+
+func TestClient_Bounds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		vendor := &countingClient{}
+		client := NewClient(vendor, WithMaxEntries(2))
+
+		for _, text := range []string{"a", "b", "a", "c"} {
+			_, err := client.ClassifyText(ctx, text)
+			require.NoError(t, err)
+		}
+		require.Equal(t, 3, vendor.calls)
+
+		// "b" was the least recently used when "c" arrived.
+		_, err := client.ClassifyText(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, 3, vendor.calls)
+		_, err = client.ClassifyText(ctx, "b")
+		require.NoError(t, err)
+		require.Equal(t, 4, vendor.calls)
+	})
+
+	t.Run("expired entries are classified again", func(t *testing.T) {
+		vendor := &countingClient{}
+		client := NewClient(vendor, WithTTL(20*time.Millisecond))
+
+		_, err := client.ClassifyText(ctx, "a")
+		require.NoError(t, err)
+		_, err = client.ClassifyText(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, 1, vendor.calls)
+
+		time.Sleep(30 * time.Millisecond)
+		_, err = client.ClassifyText(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, 2, vendor.calls)
+	})
+}
+
+func TestClient_SharedStore(t *testing.T) {
+	ctx := context.Background()
+	shared := memory.NewInMemory()
+
+	vendor := &countingClient{flagged: "bad"}
+	first := NewClient(vendor, WithSharedStore(shared))
+	second := NewClient(vendor, WithSharedStore(shared))
+
+	_, err := first.ClassifyImage(ctx, []byte("bad"))
+	require.NoError(t, err)
+
+	// Another instance reuses the result without calling the vendor...
+	result, err := second.ClassifyImage(ctx, []byte("bad"))
+	require.NoError(t, err)
+	require.True(t, result.Flagged)
+	require.Equal(t, 1, vendor.calls)
+	require.Equal(t, Stats{SharedHits: 1}, second.Stats())
+
+	// ...and then holds it locally.
+	_, err = second.ClassifyImage(ctx, []byte("bad"))
+	require.NoError(t, err)
+	require.Equal(t, Stats{Hits: 1, SharedHits: 1}, second.Stats())
+	require.Equal(t, 1, vendor.calls)
+}
@@ -119,6 +119,10 @@ func (c *client) ClassifyDisplayName(ctx context.Context, name string) (*moderat
 	return res, err
 }
 
+func (c *client) ClassifierName(_ moderation.Method) string {
+	return "claude"
+}
+
 type messagesRequest struct {
 	Model     string         `json:"model"`
 	MaxTokens int            `json:"max_tokens"`
@@ -3,6 +3,9 @@ package moderation
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
 )
 
 // ErrUnsupportedLanguage is returned when the moderation service does not
@@ -20,6 +23,18 @@ type Result struct {
 	CategoryScores map[string]float64
 }
 
+// Clone returns a deep copy of the result.
+func (r *Result) Clone() *Result {
+	cloned := &Result{
+		Flagged:           r.Flagged,
+		FlaggedCategories: slices.Clone(r.FlaggedCategories),
+	}
+	if r.CategoryScores != nil {
+		cloned.CategoryScores = maps.Clone(r.CategoryScores)
+	}
+	return cloned
+}
+
 type Client interface {
 	// ClassifyText classifies the provided text for moderation. The result
 	// indicates whether the text was flagged and includes per-category scores.
@@ -47,3 +62,19 @@ type Client interface {
 	// and has little to work with in a one- or two-word name.
 	ClassifyDisplayName(ctx context.Context, name string) (*Result, error)
 }
+
+// Classifier is implemented by a Client that can name what classifies each
+// method, for the audit trail. A client routing methods elsewhere (composite) or
+// wrapping another (cache, shadow) names the client that actually answers.
+type Classifier interface {
+	ClassifierName(method Method) string
+}
+
+// ClassifierName names the classifier client uses for method: the name it gives
+// as a Classifier, or its Go type otherwise.
+func ClassifierName(client Client, method Method) string {
+	if classifier, ok := client.(Classifier); ok {
+		return classifier.ClassifierName(method)
+	}
+	return fmt.Sprintf("%T", client)
+}
@@ -39,3 +39,16 @@ func (c *client) ClassifyCurrencyName(ctx context.Context, name string) (*modera
 func (c *client) ClassifyDisplayName(ctx context.Context, name string) (*moderation.Result, error) {
 	return c.displayNameClient.ClassifyDisplayName(ctx, name)
 }
+
+func (c *client) ClassifierName(method moderation.Method) string {
+	switch method {
+	case moderation.MethodText:
+		return moderation.ClassifierName(c.textClient, method)
+	case moderation.MethodImage:
+		return moderation.ClassifierName(c.imageClient, method)
+	case moderation.MethodCurrencyName:
+		return moderation.ClassifierName(c.currencyNameClient, method)
+	default:
+		return moderation.ClassifierName(c.displayNameClient, method)
+	}
+}
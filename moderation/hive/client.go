@@ -202,6 +202,10 @@ func (c *client) ClassifyDisplayName(ctx context.Context, name string) (*moderat
 	return nil, errors.New("not implemented")
 }
 
+func (c *client) ClassifierName(_ moderation.Method) string {
+	return "hive"
+}
+
 type response struct {
 	Status []taskStatus `json:"status"`
 }
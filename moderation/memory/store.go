@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+type storedResult struct {
+	result    *moderation.Result
+	expiresAt time.Time
+}
+
+type store struct {
+	sync.Mutex
+
+	results       map[string]storedResult
+	disagreements []*moderation.Disagreement
+	auditRecords  []*moderation.AuditRecord
+	appeals       []*moderation.Appeal
+}
+
+// NewInMemory returns an in-memory moderation.Store.
+func NewInMemory() moderation.Store {
+	return &store{
+		results: make(map[string]storedResult),
+	}
+}
+
+func (s *store) GetResult(_ context.Context, key string) (*moderation.Result, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, ok := s.results[key]
+	if !ok || !time.Now().Before(stored.expiresAt) {
+		return nil, moderation.ErrResultNotFound
+	}
+	return stored.result.Clone(), nil
+}
+
+func (s *store) PutResult(_ context.Context, key string, result *moderation.Result, expiresAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.results[key] = storedResult{result: result.Clone(), expiresAt: expiresAt}
+	return nil
+}
+
+func (s *store) PutDisagreement(_ context.Context, disagreement *moderation.Disagreement) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.disagreements = append(s.disagreements, cloneDisagreement(disagreement))
+	return nil
+}
+
+func (s *store) GetDisagreements(_ context.Context, method moderation.Method, limit int) ([]*moderation.Disagreement, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	// Walked newest recorded first, and sorted stably, so disagreements recorded
+	// at the same instant are still returned most recent first.
+	res := make([]*moderation.Disagreement, 0)
+	for i := len(s.disagreements) - 1; i >= 0; i-- {
+		if s.disagreements[i].Method == method {
+			res = append(res, cloneDisagreement(s.disagreements[i]))
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func cloneDisagreement(d *moderation.Disagreement) *moderation.Disagreement {
+	cloned := *d
+	cloned.Primary = d.Primary.Clone()
+	cloned.Candidate = d.Candidate.Clone()
+	return &cloned
+}
+
+func (s *store) PutAuditRecord(_ context.Context, record *moderation.AuditRecord) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.auditRecords = append(s.auditRecords, cloneAuditRecord(record))
+	return nil
+}
+
+func (s *store) GetAuditRecord(_ context.Context, id string) (*moderation.AuditRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, record := range s.auditRecords {
+		if record.ID == id {
+			return cloneAuditRecord(record), nil
+		}
+	}
+	return nil, moderation.ErrAuditRecordNotFound
+}
+
+func (s *store) GetAuditRecordByBlob(_ context.Context, id *blobpb.BlobId) (*moderation.AuditRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var latest *moderation.AuditRecord
+	for _, record := range s.auditRecords {
+		if record.BlobID == nil || !bytes.Equal(record.BlobID.Value, id.Value) {
+			continue
+		}
+		if latest == nil || !record.CreatedAt.Before(latest.CreatedAt) {
+			latest = record
+		}
+	}
+	if latest == nil {
+		return nil, moderation.ErrAuditRecordNotFound
+	}
+	return cloneAuditRecord(latest), nil
+}
+
+func (s *store) GetAuditRecordsByUser(_ context.Context, userID *commonpb.UserId, limit int) ([]*moderation.AuditRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*moderation.AuditRecord, 0)
+	for i := len(s.auditRecords) - 1; i >= 0; i-- {
+		if bytes.Equal(s.auditRecords[i].UserID.Value, userID.Value) {
+			res = append(res, cloneAuditRecord(s.auditRecords[i]))
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) CreateAppeal(_ context.Context, appeal *moderation.Appeal) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, existing := range s.appeals {
+		if existing.RecordID == appeal.RecordID {
+			return moderation.ErrAppealExists
+		}
+	}
+	s.appeals = append(s.appeals, cloneAppeal(appeal))
+	return nil
+}
+
+func (s *store) GetAppeal(_ context.Context, id string) (*moderation.Appeal, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, appeal := range s.appeals {
+		if appeal.ID == id {
+			return cloneAppeal(appeal), nil
+		}
+	}
+	return nil, moderation.ErrAppealNotFound
+}
+
+func (s *store) GetAppealsByState(_ context.Context, state moderation.AppealState, limit int) ([]*moderation.Appeal, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*moderation.Appeal, 0)
+	for _, appeal := range s.appeals {
+		if appeal.State == state {
+			res = append(res, cloneAppeal(appeal))
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) ResolveAppeal(_ context.Context, id string, state moderation.AppealState, resolvedBy *commonpb.UserId, note string, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, appeal := range s.appeals {
+		if appeal.ID != id {
+			continue
+		}
+		if appeal.State != moderation.AppealStatePending {
+			return moderation.ErrAppealResolved
+		}
+		appeal.State = state
+		appeal.ResolvedBy = proto.Clone(resolvedBy).(*commonpb.UserId)
+		appeal.Note = note
+		appeal.ResolvedAt = at
+		return nil
+	}
+	return moderation.ErrAppealNotFound
+}
+
+func cloneAuditRecord(r *moderation.AuditRecord) *moderation.AuditRecord {
+	cloned := *r
+	cloned.UserID = proto.Clone(r.UserID).(*commonpb.UserId)
+	cloned.FlaggedCategories = slices.Clone(r.FlaggedCategories)
+	cloned.CategoryScores = maps.Clone(r.CategoryScores)
+	if r.BlobID != nil {
+		cloned.BlobID = proto.Clone(r.BlobID).(*blobpb.BlobId)
+	}
+	if r.ChatID != nil {
+		cloned.ChatID = proto.Clone(r.ChatID).(*commonpb.ChatId)
+	}
+	if r.MessageID != nil {
+		cloned.MessageID = proto.Clone(r.MessageID).(*messagingpb.MessageId)
+	}
+	return &cloned
+}
+
+func cloneAppeal(a *moderation.Appeal) *moderation.Appeal {
+	cloned := *a
+	cloned.UserID = proto.Clone(a.UserID).(*commonpb.UserId)
+	if a.ResolvedBy != nil {
+		cloned.ResolvedBy = proto.Clone(a.ResolvedBy).(*commonpb.UserId)
+	}
+	return &cloned
+}
+
+func (s *store) reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.results = make(map[string]storedResult)
+	s.disagreements = nil
+	s.auditRecords = nil
+	s.appeals = nil
+}
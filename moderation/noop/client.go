@@ -28,3 +28,7 @@ func (c *client) ClassifyCurrencyName(ctx context.Context, name string) (*modera
 func (c *client) ClassifyDisplayName(ctx context.Context, name string) (*moderation.Result, error) {
 	return &moderation.Result{Flagged: false, CategoryScores: make(map[string]float64)}, nil
 }
+
+func (c *client) ClassifierName(_ moderation.Method) string {
+	return "noop"
+}
@@ -0,0 +1,68 @@
+// Package policy implements a moderation.Client decorator that applies
+// configurable per-method, per-category thresholds to the scores of the client it
+// wraps, in place of the thresholds built into each vendor client. Thresholds can
+// be loaded from a file and reloaded as it changes, so trust and safety can tune
+// them without a deploy.
+package policy
+
+import (
+	"context"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+// Client applies the thresholds of its Source to every result of the client it
+// wraps. Errors, including moderation.ErrUnsupportedLanguage, pass through.
+//
+// Wrap it around a cache (see moderation/cache), not inside one, so a threshold
+// change applies to cached results as soon as it is loaded.
+type Client struct {
+	client moderation.Client
+	source Source
+}
+
+// NewClient returns a Client applying the thresholds of source to client.
+func NewClient(client moderation.Client, source Source) *Client {
+	return &Client{
+		client: client,
+		source: source,
+	}
+}
+
+func (c *Client) ClassifyText(ctx context.Context, text string) (*moderation.Result, error) {
+	return c.apply(moderation.MethodText, func() (*moderation.Result, error) {
+		return c.client.ClassifyText(ctx, text)
+	})
+}
+
+func (c *Client) ClassifyImage(ctx context.Context, data []byte) (*moderation.Result, error) {
+	return c.apply(moderation.MethodImage, func() (*moderation.Result, error) {
+		return c.client.ClassifyImage(ctx, data)
+	})
+}
+
+func (c *Client) ClassifyCurrencyName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.apply(moderation.MethodCurrencyName, func() (*moderation.Result, error) {
+		return c.client.ClassifyCurrencyName(ctx, name)
+	})
+}
+
+func (c *Client) ClassifyDisplayName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.apply(moderation.MethodDisplayName, func() (*moderation.Result, error) {
+		return c.client.ClassifyDisplayName(ctx, name)
+	})
+}
+
+// ClassifierName names the wrapped client's classifier, which still produced the
+// scores the thresholds were applied to.
+func (c *Client) ClassifierName(method moderation.Method) string {
+	return moderation.ClassifierName(c.client, method)
+}
+
+func (c *Client) apply(method moderation.Method, classifyFn func() (*moderation.Result, error)) (*moderation.Result, error) {
+	result, err := classifyFn()
+	if err != nil {
+		return nil, err
+	}
+	return c.source.Thresholds().Apply(method, result), nil
+}
@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+// scoringClient returns the same result for every method.
+type scoringClient struct {
+	result *moderation.Result
+	err    error
+}
+
+func (c *scoringClient) classify() (*moderation.Result, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.result.Clone(), nil
+}
+
+func (c *scoringClient) ClassifyText(context.Context, string) (*moderation.Result, error) {
+	return c.classify()
+}
+
+func (c *scoringClient) ClassifyImage(context.Context, []byte) (*moderation.Result, error) {
+	return c.classify()
+}
+
+func (c *scoringClient) ClassifyCurrencyName(context.Context, string) (*moderation.Result, error) {
+	return c.classify()
+}
+
+func (c *scoringClient) ClassifyDisplayName(context.Context, string) (*moderation.Result, error) {
+	return c.classify()
+}
+
+func (c *scoringClient) ClassifierName(method moderation.Method) string {
+	return "scoring/" + string(method)
+}
+
+func threshold(v float64) *float64 {
+	return &v
+}
+
+func TestThresholds_Apply(t *testing.T) {
+	vendor := &moderation.Result{
+		Flagged:           true,
+		FlaggedCategories: []string{"violence", "child_exploitation"},
+		CategoryScores:    map[string]float64{"violence": 0.6, "hate": 0.4, "spam": 0.2},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		thresholds *MethodThresholds
+		expected   *moderation.Result
+	}{
+		{
+			name:     "unconfigured keeps the vendor verdict",
+			expected: vendor,
+		},
+		{
+			name:       "category threshold raised unflags",
+			thresholds: &MethodThresholds{Categories: map[string]float64{"violence": 0.8}},
+			expected: &moderation.Result{
+				Flagged:           true,
+				FlaggedCategories: []string{"child_exploitation"},
+				CategoryScores:    vendor.CategoryScores,
+			},
+		},
+		{
+			name:       "default lowered flags, in order",
+			thresholds: &MethodThresholds{Default: threshold(0.3), Categories: map[string]float64{"hate": 0.5}},
+			expected: &moderation.Result{
+				Flagged:           true,
+				FlaggedCategories: []string{"violence", "child_exploitation"},
+				CategoryScores:    vendor.CategoryScores,
+			},
+		},
+		{
+			name:       "threshold is inclusive",
+			thresholds: &MethodThresholds{Categories: map[string]float64{"spam": 0.2, "hate": 0.4}},
+			expected: &moderation.Result{
+				Flagged:           true,
+				FlaggedCategories: []string{"violence", "child_exploitation", "hate", "spam"},
+				CategoryScores:    vendor.CategoryScores,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			thresholds := Thresholds{}
+			if tc.thresholds != nil {
+				thresholds[moderation.MethodText] = tc.thresholds
+			}
+			applied := thresholds.Apply(moderation.MethodText, vendor)
+			require.Equal(t, tc.expected, applied)
+		})
+	}
+
+	t.Run("unscored categories are all that can stay flagged", func(t *testing.T) {
+		thresholds := Thresholds{moderation.MethodText: {Default: threshold(1)}}
+		applied := thresholds.Apply(moderation.MethodText, vendor)
+		require.True(t, applied.Flagged)
+		require.Equal(t, []string{"child_exploitation"}, applied.FlaggedCategories)
+
+		unflagged := &moderation.Result{
+			Flagged:           true,
+			FlaggedCategories: []string{"violence"},
+			CategoryScores:    map[string]float64{"violence": 0.6},
+		}
+		applied = thresholds.Apply(moderation.MethodText, unflagged)
+		require.False(t, applied.Flagged)
+		require.Empty(t, applied.FlaggedCategories)
+	})
+
+	t.Run("vendor result is left unmodified", func(t *testing.T) {
+		original := vendor.Clone()
+		thresholds := Thresholds{moderation.MethodText: {Default: threshold(0)}}
+		applied := thresholds.Apply(moderation.MethodText, vendor)
+		require.Len(t, applied.FlaggedCategories, 4)
+		require.Equal(t, original, vendor)
+	})
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds([]byte(`{
+		"text": {"default": 2, "categories": {"spam": 3}},
+		"display_name": {"categories": {"hate": 0.6}}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, Thresholds{
+		moderation.MethodText:        {Default: threshold(2), Categories: map[string]float64{"spam": 3}},
+		moderation.MethodDisplayName: {Categories: map[string]float64{"hate": 0.6}},
+	}, thresholds)
+
+	for _, invalid := range []string{
+		``,
+		`{"chat": {"default": 1}}`,
+		`{"text": {"defualt": 1}}`,
+		`{"text": {"default": -1}}`,
+		`{"image": {"categories": {"nudity": -0.1}}}`,
+	} {
+		_, err := ParseThresholds([]byte(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestClient_AppliesThresholdsPerMethod(t *testing.T) {
+	ctx := context.Background()
+	vendor := &scoringClient{result: &moderation.Result{
+		CategoryScores: map[string]float64{"hate": 0.5},
+	}}
+	client := NewClient(vendor, NewStaticSource(Thresholds{
+		moderation.MethodDisplayName: {Categories: map[string]float64{"hate": 0.5}},
+		moderation.MethodImage:       {Default: threshold(0.4)},
+	}))
+
+	result, err := client.ClassifyText(ctx, "text")
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+
+	result, err = client.ClassifyCurrencyName(ctx, "name")
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+
+	result, err = client.ClassifyDisplayName(ctx, "name")
+	require.NoError(t, err)
+	require.True(t, result.Flagged)
+	require.Equal(t, []string{"hate"}, result.FlaggedCategories)
+
+	result, err = client.ClassifyImage(ctx, []byte("image"))
+	require.NoError(t, err)
+	require.True(t, result.Flagged)
+
+	require.Equal(t, "scoring/display_name", client.ClassifierName(moderation.MethodDisplayName))
+}
+
+func TestClient_PassesErrorsThrough(t *testing.T) {
+	vendor := &scoringClient{err: moderation.ErrUnsupportedLanguage}
+	client := NewClient(vendor, NewStaticSource(Thresholds{
+		moderation.MethodText: {Default: threshold(0)},
+	}))
+
+	_, err := client.ClassifyText(context.Background(), "text")
+	require.ErrorIs(t, err, moderation.ErrUnsupportedLanguage)
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// Source provides the thresholds currently in force.
+type Source interface {
+	Thresholds() Thresholds
+}
+
+type staticSource struct {
+	thresholds Thresholds
+}
+
+// NewStaticSource returns a Source that always provides thresholds.
+func NewStaticSource(thresholds Thresholds) Source {
+	return &staticSource{thresholds: thresholds}
+}
+
+func (s *staticSource) Thresholds() Thresholds {
+	return s.thresholds
+}
+
+// FileSourceOption configures a FileSource.
+type FileSourceOption func(*FileSource)
+
+// WithReloadInterval sets how often Run checks the file for changes. Defaults to
+// 30 seconds.
+func WithReloadInterval(interval time.Duration) FileSourceOption {
+	return func(s *FileSource) {
+		s.interval = interval
+	}
+}
+
+// FileSource is a Source loaded from a JSON file (see ParseThresholds) that is
+// reloaded when the file changes, so thresholds can be tuned without a deploy.
+// A change that fails to read or parse is logged and leaves the thresholds last
+// loaded in force; fixing the file is picked up by the next check.
+type FileSource struct {
+	log      *zap.Logger
+	path     string
+	interval time.Duration
+
+	current atomic.Pointer[Thresholds]
+
+	// mu serializes reloads, and guards the file's modification time and size
+	// as of the last load, by which a change is detected.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// NewFileSource returns a FileSource loaded from the file at path. Unlike a
+// reload, the initial load fails on a missing or invalid file. Changes are only
+// picked up while Run is running.
+func NewFileSource(log *zap.Logger, path string, opts ...FileSourceOption) (*FileSource, error) {
+	s := &FileSource{
+		log:      log.With(zap.String("path", path)),
+		path:     path,
+		interval: defaultReloadInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSource) Thresholds() Thresholds {
+	return *s.current.Load()
+}
+
+// Run checks the file for changes every reload interval until ctx is done.
+func (s *FileSource) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := s.Reload()
+			if err != nil {
+				s.log.Warn("Failed to reload moderation thresholds; keeping the last loaded", zap.Error(err))
+			} else if reloaded {
+				s.log.Info("Reloaded moderation thresholds")
+			}
+		}
+	}
+}
+
+// Reload loads the file if it changed since it was last loaded, reporting
+// whether it did. On an error, the thresholds last loaded stay in force.
+func (s *FileSource) Reload() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("error checking thresholds file: %w", err)
+	}
+	if s.current.Load() != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("error reading thresholds file: %w", err)
+	}
+	thresholds, err := ParseThresholds(data)
+	if err != nil {
+		return false, err
+	}
+
+	s.current.Store(&thresholds)
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return true, nil
+}
@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+func writeThresholds(t *testing.T, path, contents string, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileSource_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thresholds.json")
+	start := time.Now().Add(-time.Hour)
+
+	_, err := NewFileSource(zap.NewNop(), path)
+	require.Error(t, err)
+
+	writeThresholds(t, path, `{"text": {"default": 2}}`, start)
+	source, err := NewFileSource(zap.NewNop(), path)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, *source.Thresholds()[moderation.MethodText].Default)
+
+	reloaded, err := source.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	writeThresholds(t, path, `{"text": {"default": 1}}`, start.Add(time.Minute))
+	reloaded, err = source.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, 1.0, *source.Thresholds()[moderation.MethodText].Default)
+
+	// A bad change leaves the last loaded thresholds in force, and fixing it is
+	// picked up.
+	writeThresholds(t, path, `{"text": {"default": -1}}`, start.Add(2*time.Minute))
+	_, err = source.Reload()
+	require.Error(t, err)
+	require.Equal(t, 1.0, *source.Thresholds()[moderation.MethodText].Default)
+
+	require.NoError(t, os.Remove(path))
+	_, err = source.Reload()
+	require.Error(t, err)
+	require.Equal(t, 1.0, *source.Thresholds()[moderation.MethodText].Default)
+
+	writeThresholds(t, path, `{"text": {"default": 3}}`, start.Add(2*time.Minute))
+	reloaded, err = source.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, 3.0, *source.Thresholds()[moderation.MethodText].Default)
+}
+
+func TestFileSource_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thresholds.json")
+	start := time.Now().Add(-time.Hour)
+
+	writeThresholds(t, path, `{}`, start)
+	source, err := NewFileSource(zap.NewNop(), path, WithReloadInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		source.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	client := NewClient(&scoringClient{result: &moderation.Result{
+		CategoryScores: map[string]float64{"hate": 0.5},
+	}}, source)
+
+	result, err := client.ClassifyDisplayName(ctx, "name")
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+
+	writeThresholds(t, path, `{"display_name": {"categories": {"hate": 0.5}}}`, start.Add(time.Minute))
+	require.Eventually(t, func() bool {
+		result, err := client.ClassifyDisplayName(ctx, "name")
+		return err == nil && result.Flagged
+	}, time.Second, 10*time.Millisecond)
+}
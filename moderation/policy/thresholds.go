@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+// Thresholds are score thresholds by method — the surface being moderated: chat
+// text, images, currency names, or display names. A method without thresholds
+// keeps the vendor's verdict.
+//
+// Thresholds are on the scale of the client that scores the method, which
+// differs by vendor: Hive scores text 0 to 3 and images 0 to 1, Claude scores 0
+// to 1.
+type Thresholds map[moderation.Method]*MethodThresholds
+
+// MethodThresholds are the thresholds of one method. A category scored at or
+// above its threshold is flagged, and one scored below it is not, whatever the
+// vendor's verdict.
+type MethodThresholds struct {
+	// Default is the threshold of a category without one of its own. When unset,
+	// such a category keeps the vendor's verdict.
+	Default *float64 `json:"default,omitempty"`
+
+	// Categories are thresholds by category, as the vendor names it.
+	Categories map[string]float64 `json:"categories,omitempty"`
+}
+
+// ParseThresholds parses thresholds from JSON keyed by method name, e.g.
+//
+//	{
+//	  "text":         {"default": 2, "categories": {"spam": 3}},
+//	  "image":        {"categories": {"yes_sexual_activity": 0.9}},
+//	  "display_name": {"default": 0.6}
+//	}
+//
+// An unknown method or field, or a negative threshold, is an error.
+func ParseThresholds(data []byte) (Thresholds, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var thresholds Thresholds
+	if err := decoder.Decode(&thresholds); err != nil {
+		return nil, fmt.Errorf("error decoding thresholds: %w", err)
+	}
+	if err := thresholds.validate(); err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+func (t Thresholds) validate() error {
+	for method, methodThresholds := range t {
+		switch method {
+		case moderation.MethodText, moderation.MethodImage, moderation.MethodCurrencyName, moderation.MethodDisplayName:
+		default:
+			return fmt.Errorf("unknown method %q", method)
+		}
+		if methodThresholds == nil {
+			continue
+		}
+		if methodThresholds.Default != nil && !validThreshold(*methodThresholds.Default) {
+			return fmt.Errorf("invalid %s default threshold %v", method, *methodThresholds.Default)
+		}
+		for category, threshold := range methodThresholds.Categories {
+			if !validThreshold(threshold) {
+				return fmt.Errorf("invalid %s threshold %v for category %q", method, threshold, category)
+			}
+		}
+	}
+	return nil
+}
+
+func validThreshold(threshold float64) bool {
+	return threshold >= 0 && !math.IsNaN(threshold) && !math.IsInf(threshold, 0)
+}
+
+// Apply returns result with the method's thresholds applied to its scores. A
+// category without a score, or without a threshold, keeps the vendor's verdict,
+// so pattern matches a vendor flags outright stay flagged. The result is a copy;
+// result itself is left unmodified.
+func (t Thresholds) Apply(method moderation.Method, result *moderation.Result) *moderation.Result {
+	methodThresholds := t[method]
+	if methodThresholds == nil {
+		return result.Clone()
+	}
+
+	applied := &moderation.Result{}
+	if result.CategoryScores != nil {
+		applied.CategoryScores = maps.Clone(result.CategoryScores)
+	}
+
+	// The vendor's flagged categories keep their order, followed by any the
+	// thresholds newly flag, sorted.
+	for _, category := range result.FlaggedCategories {
+		if methodThresholds.flags(category, result, true) {
+			applied.FlaggedCategories = append(applied.FlaggedCategories, category)
+		}
+	}
+	for _, category := range slices.Sorted(maps.Keys(result.CategoryScores)) {
+		if slices.Contains(result.FlaggedCategories, category) {
+			continue
+		}
+		if methodThresholds.flags(category, result, false) {
+			applied.FlaggedCategories = append(applied.FlaggedCategories, category)
+		}
+	}
+
+	// A result the vendor flagged without naming a category has nothing for the
+	// thresholds to apply to.
+	applied.Flagged = len(applied.FlaggedCategories) > 0 || (result.Flagged && len(result.FlaggedCategories) == 0)
+	return applied
+}
+
+// flags reports whether category is flagged under the thresholds, given the
+// vendor's verdict on it.
+func (m *MethodThresholds) flags(category string, result *moderation.Result, vendorFlagged bool) bool {
+	score, ok := result.CategoryScores[category]
+	if !ok {
+		return vendorFlagged
+	}
+	threshold, ok := m.Categories[category]
+	if !ok {
+		if m.Default == nil {
+			return vendorFlagged
+		}
+		threshold = *m.Default
+	}
+	return score >= threshold
+}
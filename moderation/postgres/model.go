@@ -0,0 +1,508 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+
+	pg "github.com/code-payments/flipcash2-server/database/postgres"
+)
+
+const (
+	resultsTableName = "flipcash_moderation_results"
+	allResultFields  = `"key", "flagged", "flaggedCategories", "categoryScores", "createdAt", "updatedAt", "expiresAt"`
+
+	disagreementsTableName = "flipcash_moderation_disagreements"
+	allDisagreementFields  = `"method", "contentHash", "content", "primaryFlagged", "primaryCategories", "primaryScores", "candidateFlagged", "candidateCategories", "candidateScores", "createdAt"`
+
+	auditRecordsTableName = "flipcash_moderation_audit_records"
+	allAuditRecordFields  = `"id", "userId", "method", "classifier", "contentHash", "flaggedCategories", "categoryScores", "decision", "blobId", "chatId", "messageId", "createdAt"`
+
+	appealsTableName = "flipcash_moderation_appeals"
+	allAppealFields  = `"id", "recordId", "userId", "reason", "state", "resolvedBy", "note", "createdAt", "resolvedAt"`
+)
+
+type resultModel struct {
+	Key               string    `db:"key"`
+	Flagged           bool      `db:"flagged"`
+	FlaggedCategories []string  `db:"flaggedCategories"`
+	CategoryScores    []byte    `db:"categoryScores"`
+	CreatedAt         time.Time `db:"createdAt"`
+	UpdatedAt         time.Time `db:"updatedAt"`
+	ExpiresAt         time.Time `db:"expiresAt"`
+}
+
+func toResultModel(key string, result *moderation.Result, expiresAt time.Time) (*resultModel, error) {
+	encoded, err := encodeScores(result.CategoryScores)
+	if err != nil {
+		return nil, err
+	}
+	return &resultModel{
+		Key:               key,
+		Flagged:           result.Flagged,
+		FlaggedCategories: result.FlaggedCategories,
+		CategoryScores:    encoded,
+		ExpiresAt:         expiresAt,
+	}, nil
+}
+
+func encodeScores(scores map[string]float64) ([]byte, error) {
+	if scores == nil {
+		scores = map[string]float64{}
+	}
+	return json.Marshal(scores)
+}
+
+func fromResultModel(m *resultModel) (*moderation.Result, error) {
+	result := &moderation.Result{
+		Flagged:           m.Flagged,
+		FlaggedCategories: m.FlaggedCategories,
+	}
+	if err := json.Unmarshal(m.CategoryScores, &result.CategoryScores); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *resultModel) dbPut(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + resultsTableName + `(` + allResultFields + `)
+			VALUES ($1, $2, $3, $4, NOW(), NOW(), $5)
+
+			ON CONFLICT ("key")
+			DO UPDATE
+				SET "flagged" = $2, "flaggedCategories" = $3, "categoryScores" = $4, "updatedAt" = NOW(), "expiresAt" = $5
+				WHERE ` + resultsTableName + `."key" = $1`
+		_, err := tx.Exec(
+			ctx,
+			query,
+			m.Key,
+			m.Flagged,
+			m.FlaggedCategories,
+			string(m.CategoryScores),
+			m.ExpiresAt.UTC(),
+		)
+		return err
+	})
+}
+
+func dbGetResult(ctx context.Context, pool *pgxpool.Pool, key string) (*resultModel, error) {
+	res := &resultModel{}
+	query := `SELECT ` + allResultFields + ` FROM ` + resultsTableName + `
+		WHERE "key" = $1 AND "expiresAt" > NOW()`
+	err := pgxscan.Get(
+		ctx,
+		pool,
+		res,
+		query,
+		key,
+	)
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, moderation.ErrResultNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+type disagreementModel struct {
+	Method              string    `db:"method"`
+	ContentHash         string    `db:"contentHash"`
+	Content             string    `db:"content"`
+	PrimaryFlagged      bool      `db:"primaryFlagged"`
+	PrimaryCategories   []string  `db:"primaryCategories"`
+	PrimaryScores       []byte    `db:"primaryScores"`
+	CandidateFlagged    bool      `db:"candidateFlagged"`
+	CandidateCategories []string  `db:"candidateCategories"`
+	CandidateScores     []byte    `db:"candidateScores"`
+	CreatedAt           time.Time `db:"createdAt"`
+}
+
+func toDisagreementModel(d *moderation.Disagreement) (*disagreementModel, error) {
+	primaryScores, err := encodeScores(d.Primary.CategoryScores)
+	if err != nil {
+		return nil, err
+	}
+	candidateScores, err := encodeScores(d.Candidate.CategoryScores)
+	if err != nil {
+		return nil, err
+	}
+	return &disagreementModel{
+		Method:              string(d.Method),
+		ContentHash:         d.ContentHash,
+		Content:             d.Content,
+		PrimaryFlagged:      d.Primary.Flagged,
+		PrimaryCategories:   d.Primary.FlaggedCategories,
+		PrimaryScores:       primaryScores,
+		CandidateFlagged:    d.Candidate.Flagged,
+		CandidateCategories: d.Candidate.FlaggedCategories,
+		CandidateScores:     candidateScores,
+		CreatedAt:           d.CreatedAt,
+	}, nil
+}
+
+func fromDisagreementModel(m *disagreementModel) (*moderation.Disagreement, error) {
+	d := &moderation.Disagreement{
+		Method:      moderation.Method(m.Method),
+		ContentHash: m.ContentHash,
+		Content:     m.Content,
+		Primary: &moderation.Result{
+			Flagged:           m.PrimaryFlagged,
+			FlaggedCategories: m.PrimaryCategories,
+		},
+		Candidate: &moderation.Result{
+			Flagged:           m.CandidateFlagged,
+			FlaggedCategories: m.CandidateCategories,
+		},
+		CreatedAt: m.CreatedAt,
+	}
+	if err := json.Unmarshal(m.PrimaryScores, &d.Primary.CategoryScores); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(m.CandidateScores, &d.Candidate.CategoryScores); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (m *disagreementModel) dbPut(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + disagreementsTableName + `(` + allDisagreementFields + `)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		_, err := tx.Exec(
+			ctx,
+			query,
+			m.Method,
+			m.ContentHash,
+			m.Content,
+			m.PrimaryFlagged,
+			m.PrimaryCategories,
+			string(m.PrimaryScores),
+			m.CandidateFlagged,
+			m.CandidateCategories,
+			string(m.CandidateScores),
+			m.CreatedAt.UTC(),
+		)
+		return err
+	})
+}
+
+func dbGetDisagreements(ctx context.Context, pool *pgxpool.Pool, method moderation.Method, limit int) ([]*disagreementModel, error) {
+	var res []*disagreementModel
+	query := `SELECT ` + allDisagreementFields + ` FROM ` + disagreementsTableName + `
+		WHERE "method" = $1
+		ORDER BY "createdAt" DESC, "id" DESC
+		LIMIT $2`
+	err := pgxscan.Select(
+		ctx,
+		pool,
+		&res,
+		query,
+		string(method),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+type auditRecordModel struct {
+	ID                string    `db:"id"`
+	UserID            string    `db:"userId"`
+	Method            string    `db:"method"`
+	Classifier        string    `db:"classifier"`
+	ContentHash       string    `db:"contentHash"`
+	FlaggedCategories []string  `db:"flaggedCategories"`
+	CategoryScores    []byte    `db:"categoryScores"`
+	Decision          string    `db:"decision"`
+	BlobID            *string   `db:"blobId"`
+	ChatID            *string   `db:"chatId"`
+	MessageID         *int64    `db:"messageId"`
+	CreatedAt         time.Time `db:"createdAt"`
+}
+
+func toAuditRecordModel(r *moderation.AuditRecord) (*auditRecordModel, error) {
+	scores, err := encodeScores(r.CategoryScores)
+	if err != nil {
+		return nil, err
+	}
+	m := &auditRecordModel{
+		ID:                r.ID,
+		UserID:            pg.Encode(r.UserID.Value),
+		Method:            string(r.Method),
+		Classifier:        r.Classifier,
+		ContentHash:       r.ContentHash,
+		FlaggedCategories: r.FlaggedCategories,
+		CategoryScores:    scores,
+		Decision:          string(r.Decision),
+		CreatedAt:         r.CreatedAt,
+	}
+	if r.BlobID != nil {
+		encoded := pg.Encode(r.BlobID.Value)
+		m.BlobID = &encoded
+	}
+	if r.ChatID != nil {
+		encoded := pg.Encode(r.ChatID.Value)
+		m.ChatID = &encoded
+	}
+	if r.MessageID != nil {
+		messageID := int64(r.MessageID.Value)
+		m.MessageID = &messageID
+	}
+	return m, nil
+}
+
+func fromAuditRecordModel(m *auditRecordModel) (*moderation.AuditRecord, error) {
+	userID, err := pg.Decode(m.UserID)
+	if err != nil {
+		return nil, err
+	}
+	r := &moderation.AuditRecord{
+		ID:                m.ID,
+		UserID:            &commonpb.UserId{Value: userID},
+		Method:            moderation.Method(m.Method),
+		Classifier:        m.Classifier,
+		ContentHash:       m.ContentHash,
+		FlaggedCategories: m.FlaggedCategories,
+		Decision:          moderation.Decision(m.Decision),
+		CreatedAt:         m.CreatedAt,
+	}
+	if err := json.Unmarshal(m.CategoryScores, &r.CategoryScores); err != nil {
+		return nil, err
+	}
+	if m.BlobID != nil {
+		blobID, err := pg.Decode(*m.BlobID)
+		if err != nil {
+			return nil, err
+		}
+		r.BlobID = &blobpb.BlobId{Value: blobID}
+	}
+	if m.ChatID != nil {
+		chatID, err := pg.Decode(*m.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		r.ChatID = &commonpb.ChatId{Value: chatID}
+	}
+	if m.MessageID != nil {
+		r.MessageID = &messagingpb.MessageId{Value: uint64(*m.MessageID)}
+	}
+	return r, nil
+}
+
+func (m *auditRecordModel) dbPut(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + auditRecordsTableName + `(` + allAuditRecordFields + `)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		_, err := tx.Exec(
+			ctx,
+			query,
+			m.ID,
+			m.UserID,
+			m.Method,
+			m.Classifier,
+			m.ContentHash,
+			m.FlaggedCategories,
+			string(m.CategoryScores),
+			m.Decision,
+			m.BlobID,
+			m.ChatID,
+			m.MessageID,
+			m.CreatedAt.UTC(),
+		)
+		return err
+	})
+}
+
+func dbGetAuditRecord(ctx context.Context, pool *pgxpool.Pool, id string) (*auditRecordModel, error) {
+	res := &auditRecordModel{}
+	query := `SELECT ` + allAuditRecordFields + ` FROM ` + auditRecordsTableName + `
+		WHERE "id" = $1`
+	err := pgxscan.Get(ctx, pool, res, query, id)
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, moderation.ErrAuditRecordNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetAuditRecordByBlob(ctx context.Context, pool *pgxpool.Pool, id *blobpb.BlobId) (*auditRecordModel, error) {
+	res := &auditRecordModel{}
+	query := `SELECT ` + allAuditRecordFields + ` FROM ` + auditRecordsTableName + `
+		WHERE "blobId" = $1
+		ORDER BY "createdAt" DESC
+		LIMIT 1`
+	err := pgxscan.Get(ctx, pool, res, query, pg.Encode(id.Value))
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, moderation.ErrAuditRecordNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetAuditRecordsByUser(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId, limit int) ([]*auditRecordModel, error) {
+	var res []*auditRecordModel
+	query := `SELECT ` + allAuditRecordFields + ` FROM ` + auditRecordsTableName + `
+		WHERE "userId" = $1
+		ORDER BY "createdAt" DESC
+		LIMIT $2`
+	err := pgxscan.Select(ctx, pool, &res, query, pg.Encode(userID.Value), limit)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+type appealModel struct {
+	ID         string     `db:"id"`
+	RecordID   string     `db:"recordId"`
+	UserID     string     `db:"userId"`
+	Reason     string     `db:"reason"`
+	State      string     `db:"state"`
+	ResolvedBy *string    `db:"resolvedBy"`
+	Note       string     `db:"note"`
+	CreatedAt  time.Time  `db:"createdAt"`
+	ResolvedAt *time.Time `db:"resolvedAt"`
+}
+
+func toAppealModel(a *moderation.Appeal) *appealModel {
+	return &appealModel{
+		ID:        a.ID,
+		RecordID:  a.RecordID,
+		UserID:    pg.Encode(a.UserID.Value),
+		Reason:    a.Reason,
+		State:     string(a.State),
+		Note:      a.Note,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+func fromAppealModel(m *appealModel) (*moderation.Appeal, error) {
+	userID, err := pg.Decode(m.UserID)
+	if err != nil {
+		return nil, err
+	}
+	a := &moderation.Appeal{
+		ID:        m.ID,
+		RecordID:  m.RecordID,
+		UserID:    &commonpb.UserId{Value: userID},
+		Reason:    m.Reason,
+		State:     moderation.AppealState(m.State),
+		Note:      m.Note,
+		CreatedAt: m.CreatedAt,
+	}
+	if m.ResolvedBy != nil {
+		resolvedBy, err := pg.Decode(*m.ResolvedBy)
+		if err != nil {
+			return nil, err
+		}
+		a.ResolvedBy = &commonpb.UserId{Value: resolvedBy}
+	}
+	if m.ResolvedAt != nil {
+		a.ResolvedAt = *m.ResolvedAt
+	}
+	return a, nil
+}
+
+func (m *appealModel) dbCreate(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + appealsTableName + `(` + allAppealFields + `)
+			VALUES ($1, $2, $3, $4, $5, NULL, $6, $7, NULL)`
+		_, err := tx.Exec(
+			ctx,
+			query,
+			m.ID,
+			m.RecordID,
+			m.UserID,
+			m.Reason,
+			m.State,
+			m.Note,
+			m.CreatedAt.UTC(),
+		)
+		if err == nil {
+			return nil
+		} else if strings.Contains(err.Error(), "23505") { // todo: better utility for detecting unique violations with pgx.Tx
+			return moderation.ErrAppealExists
+		}
+		return err
+	})
+}
+
+func dbGetAppeal(ctx context.Context, pool *pgxpool.Pool, id string) (*appealModel, error) {
+	res := &appealModel{}
+	query := `SELECT ` + allAppealFields + ` FROM ` + appealsTableName + `
+		WHERE "id" = $1`
+	err := pgxscan.Get(ctx, pool, res, query, id)
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, moderation.ErrAppealNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetAppealsByState(ctx context.Context, pool *pgxpool.Pool, state moderation.AppealState, limit int) ([]*appealModel, error) {
+	var res []*appealModel
+	query := `SELECT ` + allAppealFields + ` FROM ` + appealsTableName + `
+		WHERE "state" = $1
+		ORDER BY "createdAt" ASC
+		LIMIT $2`
+	err := pgxscan.Select(ctx, pool, &res, query, string(state), limit)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbResolveAppeal(ctx context.Context, pool *pgxpool.Pool, id string, state moderation.AppealState, resolvedBy *commonpb.UserId, note string, at time.Time) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `UPDATE ` + appealsTableName + `
+			SET "state" = $3, "resolvedBy" = $4, "note" = $5, "resolvedAt" = $6
+			WHERE "id" = $1 AND "state" = $2`
+		tag, err := tx.Exec(
+			ctx,
+			query,
+			id,
+			string(moderation.AppealStatePending),
+			string(state),
+			pg.Encode(resolvedBy.Value),
+			note,
+			at.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+
+		// Nothing pending under the id: tell a resolved appeal from a missing one.
+		var exists bool
+		query = `SELECT EXISTS (SELECT 1 FROM ` + appealsTableName + ` WHERE "id" = $1)`
+		if err := tx.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return moderation.ErrAppealResolved
+		}
+		return moderation.ErrAppealNotFound
+	})
+}
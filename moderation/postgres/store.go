@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+type store struct {
+	pool *pgxpool.Pool
+}
+
+// NewInPostgres returns a moderation.Store backed by Postgres. Expired results
+// are ignored on read and overwritten by the next put under the same key;
+// nothing prunes them otherwise.
+func NewInPostgres(pool *pgxpool.Pool) moderation.Store {
+	return &store{
+		pool: pool,
+	}
+}
+
+func (s *store) GetResult(ctx context.Context, key string) (*moderation.Result, error) {
+	model, err := dbGetResult(ctx, s.pool, key)
+	if err != nil {
+		return nil, err
+	}
+	return fromResultModel(model)
+}
+
+func (s *store) PutResult(ctx context.Context, key string, result *moderation.Result, expiresAt time.Time) error {
+	model, err := toResultModel(key, result, expiresAt)
+	if err != nil {
+		return err
+	}
+	return model.dbPut(ctx, s.pool)
+}
+
+func (s *store) PutDisagreement(ctx context.Context, disagreement *moderation.Disagreement) error {
+	model, err := toDisagreementModel(disagreement)
+	if err != nil {
+		return err
+	}
+	return model.dbPut(ctx, s.pool)
+}
+
+func (s *store) GetDisagreements(ctx context.Context, method moderation.Method, limit int) ([]*moderation.Disagreement, error) {
+	models, err := dbGetDisagreements(ctx, s.pool, method, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*moderation.Disagreement, len(models))
+	for i, model := range models {
+		res[i], err = fromDisagreementModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) PutAuditRecord(ctx context.Context, record *moderation.AuditRecord) error {
+	model, err := toAuditRecordModel(record)
+	if err != nil {
+		return err
+	}
+	return model.dbPut(ctx, s.pool)
+}
+
+func (s *store) GetAuditRecord(ctx context.Context, id string) (*moderation.AuditRecord, error) {
+	model, err := dbGetAuditRecord(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	return fromAuditRecordModel(model)
+}
+
+func (s *store) GetAuditRecordByBlob(ctx context.Context, id *blobpb.BlobId) (*moderation.AuditRecord, error) {
+	model, err := dbGetAuditRecordByBlob(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	return fromAuditRecordModel(model)
+}
+
+func (s *store) GetAuditRecordsByUser(ctx context.Context, userID *commonpb.UserId, limit int) ([]*moderation.AuditRecord, error) {
+	models, err := dbGetAuditRecordsByUser(ctx, s.pool, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*moderation.AuditRecord, len(models))
+	for i, model := range models {
+		res[i], err = fromAuditRecordModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) CreateAppeal(ctx context.Context, appeal *moderation.Appeal) error {
+	return toAppealModel(appeal).dbCreate(ctx, s.pool)
+}
+
+func (s *store) GetAppeal(ctx context.Context, id string) (*moderation.Appeal, error) {
+	model, err := dbGetAppeal(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	return fromAppealModel(model)
+}
+
+func (s *store) GetAppealsByState(ctx context.Context, state moderation.AppealState, limit int) ([]*moderation.Appeal, error) {
+	models, err := dbGetAppealsByState(ctx, s.pool, state, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*moderation.Appeal, len(models))
+	for i, model := range models {
+		res[i], err = fromAppealModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) ResolveAppeal(ctx context.Context, id string, state moderation.AppealState, resolvedBy *commonpb.UserId, note string, at time.Time) error {
+	return dbResolveAppeal(ctx, s.pool, id, state, resolvedBy, note, at)
+}
+
+func (s *store) reset() {
+	for _, table := range []string{resultsTableName, disagreementsTableName, appealsTableName, auditRecordsTableName} {
+		_, err := s.pool.Exec(context.Background(), "DELETE FROM "+table)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
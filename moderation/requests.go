@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+)
+
+// The requests below are for the Server methods backing unpublished Moderation
+// RPCs, each signed over its Payload (see auth.NewPayload).
+
+// AppealRequest appeals the flag recorded under RecordID.
+type AppealRequest struct {
+	RecordID string
+	Reason   string
+	Ts       time.Time
+	Auth     *commonpb.Auth
+}
+
+func (r *AppealRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "Appeal", r.RecordID, r.Reason)
+}
+
+// AppealBlobRejectionRequest appeals the moderation rejection of a blob.
+type AppealBlobRejectionRequest struct {
+	BlobID *blobpb.BlobId
+	Reason string
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *AppealBlobRejectionRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "AppealBlobRejection", hex.EncodeToString(r.BlobID.GetValue()), r.Reason)
+}
+
+// ListAppealsRequest lists a page of appeals in a state.
+type ListAppealsRequest struct {
+	State AppealState
+	Limit int
+	Ts    time.Time
+	Auth  *commonpb.Auth
+}
+
+func (r *ListAppealsRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ListAppeals", string(r.State), strconv.Itoa(r.Limit))
+}
+
+// GetAuditRecordRequest asks for the flag recorded under RecordID.
+type GetAuditRecordRequest struct {
+	RecordID string
+	Ts       time.Time
+	Auth     *commonpb.Auth
+}
+
+func (r *GetAuditRecordRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "GetAuditRecord", r.RecordID)
+}
+
+// ResolveAppealRequest resolves a pending appeal, overturning the flag if
+// Overturn is set and upholding it otherwise.
+type ResolveAppealRequest struct {
+	AppealID string
+	Overturn bool
+	Note     string
+	Ts       time.Time
+	Auth     *commonpb.Auth
+}
+
+func (r *ResolveAppealRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ResolveAppeal", r.AppealID, strconv.FormatBool(r.Overturn), r.Note)
+}
+
+// requestPayload is the signed payload of an unpublished Moderation request.
+func requestPayload(ts time.Time, method string, args ...string) proto.Message {
+	return auth.NewPayload("flipcash.moderation.v1.Moderation/"+method, ts, args...)
+}
@@ -0,0 +1,277 @@
+// Package rules implements moderation.Client with local keyword and pattern
+// rules. It costs nothing per call and needs no network, which makes it a
+// cheap first pass in front of a vendor (see composite.NewClient) and a
+// deterministic classifier for offline tests. It cannot classify images.
+package rules
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+const (
+	// matchScore is the score of a category a rule matched. Rules are yes/no,
+	// so there is no partial score: a category either matched or scores zero.
+	matchScore = 1.0
+
+	contactInfoCategory  = "contact_info"
+	solicitationCategory = "solicitation"
+)
+
+// Rules are the keyword and pattern lists for one kind of classification, by
+// category. A category is scored matchScore, and the content flagged, when any
+// of its keywords or patterns matches.
+type Rules struct {
+	// Keywords maps a category to the words and phrases that match it. A keyword
+	// is matched as whole words, after the keyword and the content are both
+	// normalized the same way: homoglyphs, styled letters, diacritics, leetspeak
+	// and spaced-out letters are all read as the plain letters they imitate. So
+	// "b1tc0in" and "bіtcοin" match "bitcoin", while "class" does not match
+	// "ass".
+	Keywords map[string][]string
+
+	// Patterns maps a category to regular expressions (RE2 syntax) that match it.
+	// They run against the content lowercased and with homoglyphs and diacritics
+	// folded, but with digits and punctuation intact.
+	Patterns map[string][]string
+}
+
+// Config holds the rules for each kind of classification. Display names are
+// additionally checked for contact details and solicitation, whatever their
+// rules say.
+type Config struct {
+	Text         Rules
+	CurrencyName Rules
+	DisplayName  Rules
+}
+
+// builtinSolicitation is what a display name advertising or directing the
+// reader elsewhere typically says. It is merged into the display name rules.
+var builtinSolicitation = []string{
+	"dm me", "dm for", "dms open", "message me", "text me", "call me",
+	"follow me", "join my", "link in bio", "buy followers", "promo code",
+	"whatsapp", "telegram", "snapchat", "onlyfans",
+}
+
+var (
+	// obfuscatedDot catches a dot written out to get a domain past a filter:
+	// "example dot com", "example(dot)com", "example[.]com".
+	obfuscatedDot = regexp.MustCompile(`\s*(?:[\(\[\{]\s*(?:dot|\.)\s*[\)\]\}]|\s+dot\s+)\s*`)
+
+	// obfuscatedAt is the same for the "@" of an email address.
+	obfuscatedAt = regexp.MustCompile(`\s*(?:[\(\[\{]\s*at\s*[\)\]\}]|\s+at\s+)\s*`)
+
+	contactInfoPatterns = []*regexp.Regexp{
+		// A URL, or a bare domain under a TLD commonly used to advertise.
+		regexp.MustCompile(`(?:https?://|www\.)\S+`),
+		regexp.MustCompile(`\b[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.(?:com|net|org|io|co|me|xyz|app|gg|ly|tv|link|info|biz|us|uk|ru|cc|to|sh|dev|ai|fun|site|online|store|shop|club|live|pro)\b`),
+		// An email address.
+		regexp.MustCompile(`[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}`),
+		// A phone number: seven or more digits, with the usual separators.
+		regexp.MustCompile(`\+?\d(?:[\s().-]*\d){6,}`),
+		// A social handle.
+		regexp.MustCompile(`(?:^|\s)@[a-z0-9_.]{2,}`),
+	}
+
+	// walletAddressPatterns need the original letter case: base58 is
+	// case-sensitive, and lowercasing would let any long word through.
+	walletAddressPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b[1-9A-HJ-NP-Za-km-z]{32,44}\b`),
+		regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`),
+	}
+)
+
+type client struct {
+	text         *matcher
+	currencyName *matcher
+	displayName  *matcher
+}
+
+// NewClient returns a moderation.Client classifying by cfg's rules. It returns
+// an error if a pattern does not compile.
+func NewClient(cfg Config) (moderation.Client, error) {
+	text, err := compile(cfg.Text)
+	if err != nil {
+		return nil, fmt.Errorf("text rules: %w", err)
+	}
+	currencyName, err := compile(cfg.CurrencyName)
+	if err != nil {
+		return nil, fmt.Errorf("currency name rules: %w", err)
+	}
+	displayName, err := compile(cfg.DisplayName)
+	if err != nil {
+		return nil, fmt.Errorf("display name rules: %w", err)
+	}
+	displayName.addKeywords(solicitationCategory, builtinSolicitation)
+	displayName.contactInfo = true
+
+	return &client{
+		text:         text,
+		currencyName: currencyName,
+		displayName:  displayName,
+	}, nil
+}
+
+func (c *client) ClassifyText(_ context.Context, text string) (*moderation.Result, error) {
+	return c.text.classify(text), nil
+}
+
+// ClassifyImage reports every image as clean: rules only read text. Compose a
+// vendor in for images.
+func (c *client) ClassifyImage(_ context.Context, _ []byte) (*moderation.Result, error) {
+	return &moderation.Result{Flagged: false, CategoryScores: make(map[string]float64)}, nil
+}
+
+func (c *client) ClassifyCurrencyName(_ context.Context, name string) (*moderation.Result, error) {
+	return c.currencyName.classify(name), nil
+}
+
+func (c *client) ClassifyDisplayName(_ context.Context, name string) (*moderation.Result, error) {
+	return c.displayName.classify(name), nil
+}
+
+func (c *client) ClassifierName(_ moderation.Method) string {
+	return "rules"
+}
+
+// matcher is a compiled Rules.
+type matcher struct {
+	// keywords maps a category to its keywords, each as its words joined by
+	// single spaces, in both the normalized and the leetspeak-folded form.
+	keywords map[string][]string
+	patterns map[string][]*regexp.Regexp
+
+	// contactInfo enables the built-in contact detail detection.
+	contactInfo bool
+}
+
+func compile(rules Rules) (*matcher, error) {
+	m := &matcher{
+		keywords: make(map[string][]string),
+		patterns: make(map[string][]*regexp.Regexp),
+	}
+	for category, keywords := range rules.Keywords {
+		m.addKeywords(category, keywords)
+	}
+	for category, patterns := range rules.Patterns {
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("category %s: %w", category, err)
+			}
+			m.patterns[category] = append(m.patterns[category], re)
+		}
+	}
+	return m, nil
+}
+
+func (m *matcher) addKeywords(category string, keywords []string) {
+	for _, keyword := range keywords {
+		normalized := normalize(keyword)
+		for _, form := range []string{normalized, foldLeetspeak(normalized)} {
+			if joined := strings.Join(tokens(form), " "); joined != "" {
+				m.keywords[category] = append(m.keywords[category], joined)
+			}
+		}
+	}
+	// Every category is scored, matched or not.
+	if _, ok := m.keywords[category]; !ok {
+		m.keywords[category] = nil
+	}
+}
+
+func (m *matcher) classify(text string) *moderation.Result {
+	normalized := normalize(text)
+	folded := foldLeetspeak(normalized)
+
+	// Keywords are looked up among the words of the content in both forms, so a
+	// keyword matches whether the content spells it plainly ("scam!", whose "!"
+	// would fold to an "i") or in leetspeak ("5c4m").
+	words := make(map[string]struct{})
+	var phrases []string
+	for _, form := range []string{normalized, folded} {
+		toks := tokens(form)
+		for _, word := range append(toks, spelledOut(toks)...) {
+			words[word] = struct{}{}
+		}
+		phrases = append(phrases, " "+strings.Join(toks, " ")+" ")
+	}
+
+	scores := make(map[string]float64)
+	for category, keywords := range m.keywords {
+		scores[category] = 0
+		for _, keyword := range keywords {
+			if matchesKeyword(keyword, words, phrases) {
+				scores[category] = matchScore
+				break
+			}
+		}
+	}
+	for category, patterns := range m.patterns {
+		if scores[category] == matchScore {
+			continue
+		}
+		scores[category] = 0
+		for _, re := range patterns {
+			if re.MatchString(normalized) {
+				scores[category] = matchScore
+				break
+			}
+		}
+	}
+	if m.contactInfo {
+		scores[contactInfoCategory] = 0
+		if hasContactInfo(text, normalized) {
+			scores[contactInfoCategory] = matchScore
+		}
+	}
+
+	result := &moderation.Result{CategoryScores: scores}
+	for category, score := range scores {
+		if score >= matchScore {
+			result.Flagged = true
+			result.FlaggedCategories = append(result.FlaggedCategories, category)
+		}
+	}
+	sort.Strings(result.FlaggedCategories)
+	return result
+}
+
+func matchesKeyword(keyword string, words map[string]struct{}, phrases []string) bool {
+	if !strings.Contains(keyword, " ") {
+		_, ok := words[keyword]
+		return ok
+	}
+	for _, phrase := range phrases {
+		if strings.Contains(phrase, " "+keyword+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasContactInfo reports whether text carries a URL, domain, email address,
+// phone number, social handle, or wallet address, including one with its dots
+// or "@" written out to evade a filter.
+func hasContactInfo(text, normalized string) bool {
+	deobfuscated := obfuscatedAt.ReplaceAllString(obfuscatedDot.ReplaceAllString(normalized, "."), "@")
+	for _, re := range contactInfoPatterns {
+		if re.MatchString(deobfuscated) {
+			return true
+		}
+	}
+	original := norm.NFKC.String(text)
+	for _, re := range walletAddressPatterns {
+		if re.MatchString(original) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Keywords(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(Config{
+		Text: Rules{
+			Keywords: map[string][]string{
+				"financial_claim": {"bitcoin", "guaranteed returns"},
+				"profanity":       {"ass"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		text    string
+		flagged []string
+	}{
+		{"buy bitcoin now", []string{"financial_claim"}},
+		{"BITCOIN!", []string{"financial_claim"}},
+		{"b1tc0in", []string{"financial_claim"}},                // leetspeak
+		{"bіtcοin", []string{"financial_claim"}},                // Cyrillic/Greek homoglyphs
+		{"ｂｉｔｃｏｉｎ", []string{"financial_claim"}},                // fullwidth
+		{"bít​cóin", []string{"financial_claim"}},               // diacritics and a zero-width space
+		{"b i t c o i n", []string{"financial_claim"}},          // spaced out
+		{"b.1.t.c.0.1.n", []string{"financial_claim"}},          // spaced out leetspeak
+		{"Guaranteed   Returns!!", []string{"financial_claim"}}, // phrase
+		{"guaranteed daily returns", nil},                       // phrase words apart
+		{"first class passage", nil},                            // not whole words
+		{"kick 4ss", []string{"profanity"}},                     // leetspeak
+		{"hello there", nil},
+	} {
+		result, err := client.ClassifyText(ctx, tc.text)
+		require.NoError(t, err)
+		require.Equal(t, tc.flagged != nil, result.Flagged, tc.text)
+		require.Equal(t, tc.flagged, result.FlaggedCategories, tc.text)
+	}
+
+	// Every configured category is scored.
+	result, err := client.ClassifyText(ctx, "b1tc0in")
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"financial_claim": 1, "profanity": 0}, result.CategoryScores)
+}
+
+func TestClient_Patterns(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(Config{
+		CurrencyName: Rules{
+			Patterns: map[string][]string{"financial_claim": {`\d+x\b`, `to the moon`}},
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := client.ClassifyCurrencyName(ctx, "Token 100X")
+	require.NoError(t, err)
+	require.True(t, result.Flagged)
+	require.Equal(t, []string{"financial_claim"}, result.FlaggedCategories)
+
+	// Patterns see homoglyphs folded.
+	result, err = client.ClassifyCurrencyName(ctx, "tο the mοοn")
+	require.NoError(t, err)
+	require.True(t, result.Flagged)
+
+	result, err = client.ClassifyCurrencyName(ctx, "Sunflower")
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+	require.Equal(t, map[string]float64{"financial_claim": 0}, result.CategoryScores)
+
+	// Each kind of classification only applies its own rules.
+	result, err = client.ClassifyText(ctx, "Token 100X")
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+}
+
+func TestClient_DisplayNameContactInfo(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(Config{})
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		"visit https://example.org/x",
+		"www.example.org",
+		"shop at example.com",
+		"example dot com",
+		"example(dot)com",
+		"example[.]com",
+		"jane@example.org",
+		"jane (at) example (dot) org",
+		"call 555-123-4567",
+		"+1 (555) 123 4567",
+		"@jane_doe",
+		"tips 7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+		"0x52908400098527886E0F7030069857D2E4169EE7",
+	} {
+		result, err := client.ClassifyDisplayName(ctx, name)
+		require.NoError(t, err)
+		require.True(t, result.Flagged, name)
+		require.Equal(t, []string{contactInfoCategory}, result.FlaggedCategories, name)
+	}
+
+	for _, name := range []string{"Jane Doe", "Agent 007", "Class of 2024", "Dr. Smith", "Meet at noon"} {
+		result, err := client.ClassifyDisplayName(ctx, name)
+		require.NoError(t, err)
+		require.False(t, result.Flagged, name)
+		require.Equal(t, map[string]float64{contactInfoCategory: 0, solicitationCategory: 0}, result.CategoryScores, name)
+	}
+}
+
+func TestClient_DisplayNameSolicitation(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(Config{
+		DisplayName: Rules{
+			Keywords: map[string][]string{solicitationCategory: {"free crypto"}},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, name := range []string{"Jane | DM me", "ｔｅｌｅｇｒａｍ jane", "Free Crypto Jane", "j0in my server"} {
+		result, err := client.ClassifyDisplayName(ctx, name)
+		require.NoError(t, err)
+		require.True(t, result.Flagged, name)
+		require.Equal(t, []string{solicitationCategory}, result.FlaggedCategories, name)
+	}
+
+	result, err := client.ClassifyDisplayName(ctx, "Jane the Admirer")
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+}
+
+func TestClient_ClassifyImage(t *testing.T) {
+	client, err := NewClient(Config{})
+	require.NoError(t, err)
+
+	result, err := client.ClassifyImage(context.Background(), []byte("bitcoin"))
+	require.NoError(t, err)
+	require.False(t, result.Flagged)
+	require.Empty(t, result.CategoryScores)
+}
+
+func TestNewClient_InvalidPattern(t *testing.T) {
+	_, err := NewClient(Config{
+		DisplayName: Rules{Patterns: map[string][]string{"hate": {"("}}},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters that render like a Latin letter onto that letter.
+// It covers the Cyrillic and Greek lookalikes used to slip a word past a plain
+// match, plus a few Latin-extended letters with the same role; NFKC has already
+// folded the fullwidth and mathematical alphanumerics by the time it is applied.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ї': 'i',
+	'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'һ': 'h', 'ү': 'y', 'ɡ': 'g',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w', 'ς': 's', 'σ': 'o',
+	// Latin extended
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ħ': 'h', 'ŀ': 'l', 'ſ': 's', 'ß': 's',
+}
+
+// leetspeak maps the digits and symbols commonly standing in for letters onto
+// those letters. It is only applied for word matching: contact details are
+// detected before it, since folding would destroy the digits of a phone number.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't', '€': 'e', '£': 'l',
+}
+
+// normalize reduces text to the lowercase Latin letters it imitates: it applies
+// NFKC (folding fullwidth and styled letters), drops invisible format characters
+// (zero-width spaces and joiners) and combining marks (so diacritics do not
+// separate "é" from "e"), and maps homoglyphs onto the letters they resemble.
+// Digits and punctuation are kept.
+func normalize(text string) string {
+	decomposed := norm.NFD.String(norm.NFKC.String(text))
+
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		switch {
+		case unicode.Is(unicode.Cf, r), unicode.Is(unicode.Mn, r):
+			continue
+		}
+		r = unicode.ToLower(r)
+		if mapped, ok := confusables[r]; ok {
+			r = mapped
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// foldLeetspeak maps leetspeak digits and symbols in normalized text onto the
+// letters they stand in for.
+func foldLeetspeak(normalized string) string {
+	return strings.Map(func(r rune) rune {
+		if mapped, ok := leetspeak[r]; ok {
+			return mapped
+		}
+		return r
+	}, normalized)
+}
+
+// tokens splits folded text into its words: maximal runs of letters and digits.
+func tokens(folded string) []string {
+	return strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// spelledOut returns the words spelled by runs of single-letter tokens, so that
+// spacing a word out, as in "f u c k" or "f.u.c.k", does not hide it.
+func spelledOut(toks []string) []string {
+	var out []string
+	var run strings.Builder
+	runLen := 0
+	flush := func() {
+		if runLen > 1 {
+			out = append(out, run.String())
+		}
+		run.Reset()
+		runLen = 0
+	}
+	for _, tok := range toks {
+		if len([]rune(tok)) == 1 {
+			run.WriteString(tok)
+			runLen++
+			continue
+		}
+		flush()
+	}
+	flush()
+	return out
+}
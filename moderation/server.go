@@ -13,6 +13,7 @@ import (
 	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
 	moderationpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/moderation/v1"
 
+	"github.com/code-payments/flipcash2-server/account"
 	"github.com/code-payments/flipcash2-server/auth"
 	"github.com/code-payments/flipcash2-server/model"
 	"github.com/code-payments/ocp-server/solana/currencycreator"
@@ -28,22 +29,61 @@ var (
 	pngMagic  = []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
 )
 
+// blocklistClassifier is the classifier name recorded for a currency name
+// refused by blockedCurrencyNames rather than by the client.
+const blocklistClassifier = "blocklist"
+
 type Server struct {
 	log      *zap.Logger
 	authz    auth.Authorizer
 	client   Client
 	attestor model.KeyPair
 
+	// audit records every flag, when set.
+	audit AuditStore
+
+	// appeals, accounts and blobs back the appeal workflow (see Appeal); it is
+	// disabled while appeals is nil.
+	appeals  AppealStore
+	accounts account.Store
+	blobs    AppealedBlobs
+
 	moderationpb.UnimplementedModerationServer
 }
 
-func NewServer(log *zap.Logger, authz auth.Authorizer, client Client, attestor model.KeyPair) *Server {
-	return &Server{
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithAuditStore records every flag the server makes to audit.
+func WithAuditStore(audit AuditStore) ServerOption {
+	return func(s *Server) {
+		s.audit = audit
+	}
+}
+
+// WithAppeals enables the appeal workflow: users appeal flags recorded to the
+// audit store (see WithAuditStore, which it requires), and staff, as accounts
+// tells them apart, resolve the appeals. A rejected blob is held by blobs while
+// it is under appeal, and reinstated if its rejection is overturned.
+func WithAppeals(appeals AppealStore, accounts account.Store, blobs AppealedBlobs) ServerOption {
+	return func(s *Server) {
+		s.appeals = appeals
+		s.accounts = accounts
+		s.blobs = blobs
+	}
+}
+
+func NewServer(log *zap.Logger, authz auth.Authorizer, client Client, attestor model.KeyPair, opts ...ServerOption) *Server {
+	s := &Server{
 		log:      log,
 		authz:    authz,
 		client:   client,
 		attestor: attestor,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) ModerateText(ctx context.Context, req *moderationpb.ModerateTextRequest) (*moderationpb.ModerateTextResponse, error) {
@@ -63,6 +103,7 @@ func (s *Server) ModerateText(ctx context.Context, req *moderationpb.ModerateTex
 		log.Warn("Failed to classify text", zap.Error(err))
 		return nil, status.Error(codes.Internal, "")
 	}
+	method, classifier := MethodText, ClassifierName(s.client, MethodText)
 
 	if !result.Flagged && len(req.Text) <= currencycreator.MaxCurrencyConfigAccountNameLength {
 		currencyNameResult, err := s.client.ClassifyCurrencyName(ctx, req.Text)
@@ -76,6 +117,7 @@ func (s *Server) ModerateText(ctx context.Context, req *moderationpb.ModerateTex
 		}
 		if currencyNameResult.Flagged {
 			result = currencyNameResult
+			method, classifier = MethodCurrencyName, ClassifierName(s.client, MethodCurrencyName)
 		}
 	}
 
@@ -85,6 +127,7 @@ func (s *Server) ModerateText(ctx context.Context, req *moderationpb.ModerateTex
 			FlaggedCategories: []string{"platform_impersonation"},
 			CategoryScores:    map[string]float64{"platform_impersonation": 1.0},
 		}
+		method, classifier = MethodCurrencyName, blocklistClassifier
 	}
 
 	isAllowed := !result.Flagged
@@ -99,6 +142,7 @@ func (s *Server) ModerateText(ctx context.Context, req *moderationpb.ModerateTex
 	} else {
 		log.Info("Text is flagged", zap.Strings("categories", result.FlaggedCategories))
 		resp.FlaggedCategory = HighestFlaggedCategory(result)
+		s.recordFlag(ctx, log, NewAuditRecord(userID, method, classifier, []byte(req.Text), result))
 	}
 
 	return resp, nil
@@ -136,11 +180,23 @@ func (s *Server) ModerateImage(ctx context.Context, req *moderationpb.ModerateIm
 	} else {
 		log.Info("Image is flagged", zap.Strings("categories", result.FlaggedCategories))
 		resp.FlaggedCategory = HighestFlaggedCategory(result)
+		s.recordFlag(ctx, log, NewAuditRecord(userID, MethodImage, ClassifierName(s.client, MethodImage), req.ImageData, result))
 	}
 
 	return resp, nil
 }
 
+// recordFlag writes record to the audit store, if there is one. The flag stands
+// whether or not it is recorded, so a failure is only logged.
+func (s *Server) recordFlag(ctx context.Context, log *zap.Logger, record *AuditRecord) {
+	if s.audit == nil {
+		return
+	}
+	if err := s.audit.PutAuditRecord(ctx, record); err != nil {
+		log.Warn("Failed to record moderation flag", zap.Error(err))
+	}
+}
+
 func (s *Server) signAttestation(log *zap.Logger, content any, userID *commonpb.UserId) *moderationpb.ModerationAttestation {
 	var hash [sha256.Size]byte
 	switch v := content.(type) {
@@ -0,0 +1,243 @@
+// Package shadow implements a moderation.Client that runs a candidate client in
+// shadow of the primary, so a vendor switch can be evaluated on live traffic
+// before it is made.
+package shadow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/code-payments/ocp-server/metrics"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultMaxInFlight = 64
+
+	comparisonEventName = "ModerationShadowComparison"
+)
+
+// outcome is how a shadow comparison ended, as reported in the comparison event.
+type outcome string
+
+const (
+	outcomeAgreed         outcome = "agreed"
+	outcomeDisagreed      outcome = "disagreed"
+	outcomeCandidateError outcome = "candidate_error"
+	outcomeStoreError     outcome = "store_error"
+	outcomeDropped        outcome = "dropped"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithSampleRate sets the fraction of classifications, in [0, 1], that are also
+// sent to the candidate. Defaults to 1, shadowing everything.
+func WithSampleRate(rate float64) Option {
+	return func(c *Client) {
+		c.sampleRate = rate
+	}
+}
+
+// WithMethods limits shadowing to the given methods. Defaults to all of them.
+func WithMethods(methods ...moderation.Method) Option {
+	return func(c *Client) {
+		c.methods = make(map[moderation.Method]struct{}, len(methods))
+		for _, method := range methods {
+			c.methods[method] = struct{}{}
+		}
+	}
+}
+
+// WithTimeout bounds each candidate classification. Defaults to 30 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithMaxInFlight bounds how many candidate classifications run at once. A
+// sampled classification arriving past it is not shadowed. Defaults to 64.
+func WithMaxInFlight(maxInFlight int) Option {
+	return func(c *Client) {
+		c.maxInFlight = maxInFlight
+	}
+}
+
+// Client is a moderation.Client that returns the primary client's verdict, and
+// for a sample of classifications also asks a candidate client in the
+// background. Where the two disagree, the disagreement is recorded to a
+// moderation.DisagreementStore for review.
+//
+// The candidate never affects the caller: it runs after the primary has
+// answered, under its own timeout and detached from the caller's cancellation,
+// and its errors are only counted. A classification the primary fails is not
+// shadowed, since there is no verdict to compare against.
+type Client struct {
+	primary   moderation.Client
+	candidate moderation.Client
+	store     moderation.DisagreementStore
+
+	sampleRate  float64
+	methods     map[moderation.Method]struct{}
+	timeout     time.Duration
+	maxInFlight int
+
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewClient returns a Client answering with primary and shadowing it with
+// candidate, recording disagreements to store.
+func NewClient(primary, candidate moderation.Client, store moderation.DisagreementStore, opts ...Option) *Client {
+	c := &Client{
+		primary:     primary,
+		candidate:   candidate,
+		store:       store,
+		sampleRate:  1,
+		timeout:     defaultTimeout,
+		maxInFlight: defaultMaxInFlight,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.inFlight = make(chan struct{}, c.maxInFlight)
+	return c
+}
+
+func (c *Client) ClassifyText(ctx context.Context, text string) (*moderation.Result, error) {
+	return c.classify(ctx, moderation.MethodText, []byte(text),
+		func(ctx context.Context, client moderation.Client) (*moderation.Result, error) {
+			return client.ClassifyText(ctx, text)
+		},
+	)
+}
+
+func (c *Client) ClassifyImage(ctx context.Context, data []byte) (*moderation.Result, error) {
+	return c.classify(ctx, moderation.MethodImage, data,
+		func(ctx context.Context, client moderation.Client) (*moderation.Result, error) {
+			return client.ClassifyImage(ctx, data)
+		},
+	)
+}
+
+func (c *Client) ClassifyCurrencyName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.classify(ctx, moderation.MethodCurrencyName, []byte(name),
+		func(ctx context.Context, client moderation.Client) (*moderation.Result, error) {
+			return client.ClassifyCurrencyName(ctx, name)
+		},
+	)
+}
+
+func (c *Client) ClassifyDisplayName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.classify(ctx, moderation.MethodDisplayName, []byte(name),
+		func(ctx context.Context, client moderation.Client) (*moderation.Result, error) {
+			return client.ClassifyDisplayName(ctx, name)
+		},
+	)
+}
+
+// ClassifierName names the primary's classifier, whose verdict is returned.
+func (c *Client) ClassifierName(method moderation.Method) string {
+	return moderation.ClassifierName(c.primary, method)
+}
+
+// Wait blocks until every candidate classification started so far has finished
+// and been recorded. It is for graceful shutdown and tests.
+func (c *Client) Wait() {
+	c.wg.Wait()
+}
+
+func (c *Client) classify(ctx context.Context, method moderation.Method, content []byte, classifyFn func(context.Context, moderation.Client) (*moderation.Result, error)) (*moderation.Result, error) {
+	result, err := classifyFn(ctx, c.primary)
+	if err != nil || !c.sampled(method) {
+		return result, err
+	}
+
+	select {
+	case c.inFlight <- struct{}{}:
+	default:
+		recordComparison(ctx, method, outcomeDropped)
+		return result, nil
+	}
+
+	// The caller owns result once it is returned, so compare against a copy.
+	primary := result.Clone()
+	c.wg.Go(func() {
+		defer func() { <-c.inFlight }()
+
+		// Detached so the candidate outlives the caller's request, but keeping
+		// its values (the metrics provider among them).
+		candidateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancel()
+
+		candidate, err := classifyFn(candidateCtx, c.candidate)
+		if err != nil {
+			recordComparison(candidateCtx, method, outcomeCandidateError)
+			return
+		}
+		if !disagree(primary, candidate) {
+			recordComparison(candidateCtx, method, outcomeAgreed)
+			return
+		}
+
+		disagreement := &moderation.Disagreement{
+			Method:      method,
+			ContentHash: contentHash(content),
+			Primary:     primary,
+			Candidate:   candidate.Clone(),
+			CreatedAt:   time.Now(),
+		}
+		if method == moderation.MethodCurrencyName || method == moderation.MethodDisplayName {
+			disagreement.Content = string(content)
+		}
+		if err := c.store.PutDisagreement(candidateCtx, disagreement); err != nil {
+			recordComparison(candidateCtx, method, outcomeStoreError)
+			return
+		}
+		recordComparison(candidateCtx, method, outcomeDisagreed)
+	})
+	return result, nil
+}
+
+func (c *Client) sampled(method moderation.Method) bool {
+	if c.methods != nil {
+		if _, ok := c.methods[method]; !ok {
+			return false
+		}
+	}
+	return c.sampleRate >= 1 || rand.Float64() < c.sampleRate
+}
+
+// disagree reports whether two results reach a different verdict: one is flagged
+// and the other is not, or both are flagged under a different
+// moderation.HighestFlaggedCategory. Differing scores alone are not a
+// disagreement, since vendors score on unrelated scales.
+func disagree(a, b *moderation.Result) bool {
+	if a.Flagged != b.Flagged {
+		return true
+	}
+	return a.Flagged && moderation.HighestFlaggedCategory(a) != moderation.HighestFlaggedCategory(b)
+}
+
+func contentHash(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+// recordComparison emits one event per shadowed classification, from which the
+// agreement rate is computed. It is a no-op when ctx carries no metrics provider.
+func recordComparison(ctx context.Context, method moderation.Method, o outcome) {
+	metrics.RecordEvent(ctx, comparisonEventName, map[string]any{
+		"method":  string(method),
+		"outcome": string(o),
+	})
+}
+
+var _ moderation.Client = (*Client)(nil)
@@ -0,0 +1,210 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/moderation"
+	"github.com/code-payments/flipcash2-server/moderation/memory"
+)
+
+// fakeClient flags content in flagged under the mapped category, and counts its
+// calls.
+type fakeClient struct {
+	flagged map[string]string
+	err     error
+	delay   time.Duration
+	calls   atomic.Int32
+}
+
+func (c *fakeClient) classify(ctx context.Context, content string) (*moderation.Result, error) {
+	c.calls.Add(1)
+	if c.delay > 0 {
+		select {
+		case <-time.After(c.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	category, ok := c.flagged[content]
+	if !ok {
+		return &moderation.Result{CategoryScores: map[string]float64{}}, nil
+	}
+	return &moderation.Result{
+		Flagged:           true,
+		FlaggedCategories: []string{category},
+		CategoryScores:    map[string]float64{category: 0.9},
+	}, nil
+}
+
+func (c *fakeClient) ClassifyText(ctx context.Context, text string) (*moderation.Result, error) {
+	return c.classify(ctx, text)
+}
+
+func (c *fakeClient) ClassifyImage(ctx context.Context, data []byte) (*moderation.Result, error) {
+	return c.classify(ctx, string(data))
+}
+
+func (c *fakeClient) ClassifyCurrencyName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.classify(ctx, name)
+}
+
+func (c *fakeClient) ClassifyDisplayName(ctx context.Context, name string) (*moderation.Result, error) {
+	return c.classify(ctx, name)
+}
+
+func TestClient_RecordsDisagreements(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemory()
+	primary := &fakeClient{flagged: map[string]string{"both": "hate", "primary": "violence", "category": "hate", "synonym": "hate"}}
+	candidate := &fakeClient{flagged: map[string]string{"both": "hate", "candidate": "sexual", "category": "financial_claim", "synonym": "violence"}}
+	client := NewClient(primary, candidate, store)
+
+	// "synonym" is flagged under different vendor categories that map to the same
+	// FlaggedCategory, which is not a disagreement.
+	for _, name := range []string{"both", "neither", "primary", "candidate", "category", "synonym"} {
+		expected, err := primary.ClassifyDisplayName(ctx, name)
+		require.NoError(t, err)
+
+		// The primary's verdict is returned.
+		actual, err := client.ClassifyDisplayName(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+	client.Wait()
+	require.EqualValues(t, 6, candidate.calls.Load())
+
+	disagreements, err := store.GetDisagreements(ctx, moderation.MethodDisplayName, 10)
+	require.NoError(t, err)
+	require.Len(t, disagreements, 3)
+
+	byContent := make(map[string]*moderation.Disagreement)
+	for _, disagreement := range disagreements {
+		require.Len(t, disagreement.ContentHash, 64)
+		byContent[disagreement.Content] = disagreement
+	}
+	require.True(t, byContent["primary"].Primary.Flagged)
+	require.False(t, byContent["primary"].Candidate.Flagged)
+	require.False(t, byContent["candidate"].Primary.Flagged)
+	require.True(t, byContent["candidate"].Candidate.Flagged)
+	require.Equal(t, []string{"hate"}, byContent["category"].Primary.FlaggedCategories)
+	require.Equal(t, []string{"financial_claim"}, byContent["category"].Candidate.FlaggedCategories)
+}
+
+func TestClient_PrivateContentIsNotPersisted(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewInMemory()
+	client := NewClient(&fakeClient{}, &fakeClient{flagged: map[string]string{"secret": "hate"}}, store)
+
+	_, err := client.ClassifyText(ctx, "secret")
+	require.NoError(t, err)
+	_, err = client.ClassifyImage(ctx, []byte("secret"))
+	require.NoError(t, err)
+	client.Wait()
+
+	for _, method := range []moderation.Method{moderation.MethodText, moderation.MethodImage} {
+		disagreements, err := store.GetDisagreements(ctx, method, 10)
+		require.NoError(t, err)
+		require.Len(t, disagreements, 1)
+		require.Empty(t, disagreements[0].Content)
+		require.NotEmpty(t, disagreements[0].ContentHash)
+	}
+}
+
+func TestClient_CandidateNeverAffectsCaller(t *testing.T) {
+	store := memory.NewInMemory()
+
+	t.Run("candidate errors", func(t *testing.T) {
+		client := NewClient(&fakeClient{}, &fakeClient{err: errors.New("unavailable")}, store)
+
+		result, err := client.ClassifyText(context.Background(), "text")
+		require.NoError(t, err)
+		require.False(t, result.Flagged)
+		client.Wait()
+	})
+
+	t.Run("candidate times out and outlives the caller", func(t *testing.T) {
+		candidate := &fakeClient{delay: time.Second}
+		client := NewClient(&fakeClient{}, candidate, store, WithTimeout(20*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		start := time.Now()
+		_, err := client.ClassifyText(ctx, "text")
+		require.NoError(t, err)
+		cancel()
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+
+		client.Wait()
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+		require.EqualValues(t, 1, candidate.calls.Load())
+	})
+
+	t.Run("primary errors are returned and not shadowed", func(t *testing.T) {
+		candidate := &fakeClient{}
+		client := NewClient(&fakeClient{err: moderation.ErrUnsupportedLanguage}, candidate, store)
+
+		_, err := client.ClassifyText(context.Background(), "text")
+		require.ErrorIs(t, err, moderation.ErrUnsupportedLanguage)
+		client.Wait()
+		require.Zero(t, candidate.calls.Load())
+	})
+
+	disagreements, err := store.GetDisagreements(context.Background(), moderation.MethodText, 10)
+	require.NoError(t, err)
+	require.Empty(t, disagreements)
+}
+
+func TestClient_Sampling(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled methods are not shadowed", func(t *testing.T) {
+		candidate := &fakeClient{}
+		client := NewClient(&fakeClient{}, candidate, memory.NewInMemory(), WithMethods(moderation.MethodImage))
+
+		_, err := client.ClassifyText(ctx, "text")
+		require.NoError(t, err)
+		_, err = client.ClassifyImage(ctx, []byte("image"))
+		require.NoError(t, err)
+		client.Wait()
+		require.EqualValues(t, 1, candidate.calls.Load())
+	})
+
+	t.Run("sample rate", func(t *testing.T) {
+		none := &fakeClient{}
+		client := NewClient(&fakeClient{}, none, memory.NewInMemory(), WithSampleRate(0))
+		some := &fakeClient{}
+		sampled := NewClient(&fakeClient{}, some, memory.NewInMemory(), WithSampleRate(0.5), WithMaxInFlight(200))
+
+		for range 200 {
+			_, err := client.ClassifyText(ctx, "text")
+			require.NoError(t, err)
+			_, err = sampled.ClassifyText(ctx, "text")
+			require.NoError(t, err)
+		}
+		client.Wait()
+		sampled.Wait()
+		require.Zero(t, none.calls.Load())
+		require.Greater(t, some.calls.Load(), int32(50))
+		require.Less(t, some.calls.Load(), int32(150))
+	})
+
+	t.Run("classifications past the in-flight bound are not shadowed", func(t *testing.T) {
+		candidate := &fakeClient{delay: 50 * time.Millisecond}
+		client := NewClient(&fakeClient{}, candidate, memory.NewInMemory(), WithMaxInFlight(1))
+
+		for range 3 {
+			_, err := client.ClassifyText(ctx, "text")
+			require.NoError(t, err)
+		}
+		client.Wait()
+		require.EqualValues(t, 1, candidate.calls.Load())
+	})
+}
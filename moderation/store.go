@@ -0,0 +1,72 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrResultNotFound is returned by ResultStore.GetResult when no unexpired
+// result is held under the key.
+var ErrResultNotFound = errors.New("moderation result not found")
+
+// ResultStore persists classification results under an opaque key, so identical
+// content classified by one server instance is not sent to the vendor again by
+// another. It is a cache, not a record: a result may be dropped at any time, and
+// a caller must always be able to fall back to classifying afresh.
+type ResultStore interface {
+	// GetResult returns the result held under key, or ErrResultNotFound if there
+	// is none or it has expired.
+	GetResult(ctx context.Context, key string) (*Result, error)
+
+	// PutResult holds result under key until expiresAt, replacing whatever was
+	// held there before.
+	PutResult(ctx context.Context, key string, result *Result, expiresAt time.Time) error
+}
+
+// Method names a Client classification method.
+type Method string
+
+const (
+	MethodText         Method = "text"
+	MethodImage        Method = "image"
+	MethodCurrencyName Method = "currency_name"
+	MethodDisplayName  Method = "display_name"
+)
+
+// Disagreement is a classification on which a candidate client, run in shadow of
+// the primary (see moderation/shadow), reached a different verdict: one flagged
+// the content and the other did not, or both flagged it under a different
+// HighestFlaggedCategory.
+type Disagreement struct {
+	Method Method
+
+	// ContentHash is the hex SHA-256 of the classified content. Content holds the
+	// content itself only for currency and display names, which are public; chat
+	// text and images are never persisted here.
+	ContentHash string
+	Content     string
+
+	Primary   *Result
+	Candidate *Result
+
+	CreatedAt time.Time
+}
+
+// DisagreementStore records shadow-mode disagreements for review.
+type DisagreementStore interface {
+	// PutDisagreement records a disagreement.
+	PutDisagreement(ctx context.Context, disagreement *Disagreement) error
+
+	// GetDisagreements returns up to limit disagreements recorded for method,
+	// most recent first.
+	GetDisagreements(ctx context.Context, method Method, limit int) ([]*Disagreement, error)
+}
+
+// Store is everything the moderation stores persist.
+type Store interface {
+	ResultStore
+	DisagreementStore
+	AuditStore
+	AppealStore
+}
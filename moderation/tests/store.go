@@ -0,0 +1,310 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/moderation"
+)
+
+func RunStoreTests(t *testing.T, s moderation.Store, teardown func()) {
+	for _, tf := range []func(t *testing.T, s moderation.Store){
+		testResultStore_HappyPath,
+		testResultStore_Expiry,
+		testDisagreementStore,
+		testAuditStore,
+		testAppealStore,
+	} {
+		tf(t, s)
+		teardown()
+	}
+}
+
+func testResultStore_HappyPath(t *testing.T, s moderation.Store) {
+	ctx := context.Background()
+
+	_, err := s.GetResult(ctx, "key")
+	require.Equal(t, moderation.ErrResultNotFound, err)
+
+	flagged := &moderation.Result{
+		Flagged:           true,
+		FlaggedCategories: []string{"violence"},
+		CategoryScores:    map[string]float64{"violence": 0.9, "sexual": 0.1},
+	}
+	require.NoError(t, s.PutResult(ctx, "key", flagged, time.Now().Add(time.Minute)))
+
+	actual, err := s.GetResult(ctx, "key")
+	require.NoError(t, err)
+	require.Equal(t, flagged, actual)
+
+	// A later put replaces the result.
+	clean := &moderation.Result{CategoryScores: map[string]float64{"violence": 0.01}}
+	require.NoError(t, s.PutResult(ctx, "key", clean, time.Now().Add(time.Minute)))
+
+	actual, err = s.GetResult(ctx, "key")
+	require.NoError(t, err)
+	require.False(t, actual.Flagged)
+	require.Empty(t, actual.FlaggedCategories)
+	require.Equal(t, clean.CategoryScores, actual.CategoryScores)
+
+	// Keys are independent.
+	_, err = s.GetResult(ctx, "other")
+	require.Equal(t, moderation.ErrResultNotFound, err)
+}
+
+func testResultStore_Expiry(t *testing.T, s moderation.Store) {
+	ctx := context.Background()
+
+	result := &moderation.Result{CategoryScores: map[string]float64{}}
+	require.NoError(t, s.PutResult(ctx, "key", result, time.Now().Add(-time.Second)))
+
+	_, err := s.GetResult(ctx, "key")
+	require.Equal(t, moderation.ErrResultNotFound, err)
+
+	// An expired result is replaced like any other.
+	require.NoError(t, s.PutResult(ctx, "key", result, time.Now().Add(time.Minute)))
+	_, err = s.GetResult(ctx, "key")
+	require.NoError(t, err)
+}
+
+func testDisagreementStore(t *testing.T, s moderation.Store) {
+	ctx := context.Background()
+
+	actual, err := s.GetDisagreements(ctx, moderation.MethodDisplayName, 10)
+	require.NoError(t, err)
+	require.Empty(t, actual)
+
+	start := time.Now().Truncate(time.Millisecond)
+	var recorded []*moderation.Disagreement
+	for i := range 3 {
+		disagreement := &moderation.Disagreement{
+			Method:      moderation.MethodDisplayName,
+			ContentHash: fmt.Sprintf("hash%d", i),
+			Content:     fmt.Sprintf("name%d", i),
+			Primary: &moderation.Result{
+				Flagged:           true,
+				FlaggedCategories: []string{"hate"},
+				CategoryScores:    map[string]float64{"hate": 0.9},
+			},
+			Candidate: &moderation.Result{
+				CategoryScores: map[string]float64{"hate": 0.2},
+			},
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, s.PutDisagreement(ctx, disagreement))
+		recorded = append(recorded, disagreement)
+	}
+	require.NoError(t, s.PutDisagreement(ctx, &moderation.Disagreement{
+		Method:      moderation.MethodImage,
+		ContentHash: "image",
+		Primary:     &moderation.Result{CategoryScores: map[string]float64{}},
+		Candidate: &moderation.Result{
+			Flagged:           true,
+			FlaggedCategories: []string{"violence"},
+			CategoryScores:    map[string]float64{"violence": 0.8},
+		},
+		CreatedAt: start,
+	}))
+
+	// Most recent first, limited, and only for the method asked.
+	actual, err = s.GetDisagreements(ctx, moderation.MethodDisplayName, 2)
+	require.NoError(t, err)
+	require.Len(t, actual, 2)
+	for i, expected := range []*moderation.Disagreement{recorded[2], recorded[1]} {
+		require.Equal(t, expected.Method, actual[i].Method)
+		require.Equal(t, expected.ContentHash, actual[i].ContentHash)
+		require.Equal(t, expected.Content, actual[i].Content)
+		require.Equal(t, expected.Primary, actual[i].Primary)
+		require.Equal(t, expected.Candidate.Flagged, actual[i].Candidate.Flagged)
+		require.Empty(t, actual[i].Candidate.FlaggedCategories)
+		require.Equal(t, expected.Candidate.CategoryScores, actual[i].Candidate.CategoryScores)
+		require.True(t, expected.CreatedAt.Equal(actual[i].CreatedAt))
+	}
+
+	actual, err = s.GetDisagreements(ctx, moderation.MethodImage, 10)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Empty(t, actual[0].Content)
+	require.Equal(t, []string{"violence"}, actual[0].Candidate.FlaggedCategories)
+
+	actual, err = s.GetDisagreements(ctx, moderation.MethodText, 10)
+	require.NoError(t, err)
+	require.Empty(t, actual)
+}
+
+func testAuditStore(t *testing.T, s moderation.Store) {
+	ctx := context.Background()
+
+	_, err := s.GetAuditRecord(ctx, uuid.NewString())
+	require.Equal(t, moderation.ErrAuditRecordNotFound, err)
+
+	user := model.MustGenerateUserID()
+	other := model.MustGenerateUserID()
+	blobID := &blobpb.BlobId{Value: []byte(uuid.NewString())}
+
+	_, err = s.GetAuditRecordByBlob(ctx, blobID)
+	require.Equal(t, moderation.ErrAuditRecordNotFound, err)
+
+	flagged := &moderation.Result{
+		Flagged:           true,
+		FlaggedCategories: []string{"hate"},
+		CategoryScores:    map[string]float64{"hate": 0.9, "violence": 0.1},
+	}
+	start := time.Now().Truncate(time.Millisecond)
+
+	text := moderation.NewAuditRecord(user, moderation.MethodText, "hive", []byte("text"), flagged)
+	text.Decision = moderation.DecisionFlagged
+	text.ChatID = &commonpb.ChatId{Value: append(model.MustGenerateUserID().Value, model.MustGenerateUserID().Value...)}
+	text.MessageID = &messagingpb.MessageId{Value: 42}
+	text.CreatedAt = start
+	first := moderation.NewAuditRecord(user, moderation.MethodImage, "hive", []byte("image"), flagged)
+	first.BlobID = blobID
+	first.CreatedAt = start.Add(time.Second)
+	second := moderation.NewAuditRecord(user, moderation.MethodImage, "duplicate", []byte("image"), flagged)
+	second.BlobID = blobID
+	second.CreatedAt = start.Add(2 * time.Second)
+	others := moderation.NewAuditRecord(other, moderation.MethodDisplayName, "claude", []byte("name"), flagged)
+	others.CreatedAt = start.Add(3 * time.Second)
+	for _, record := range []*moderation.AuditRecord{text, first, second, others} {
+		require.NoError(t, s.PutAuditRecord(ctx, record))
+	}
+
+	actual, err := s.GetAuditRecord(ctx, text.ID)
+	require.NoError(t, err)
+	assertEquivalentAuditRecords(t, text, actual)
+	require.Nil(t, actual.BlobID)
+
+	actual, err = s.GetAuditRecord(ctx, first.ID)
+	require.NoError(t, err)
+	require.Nil(t, actual.ChatID)
+	require.Nil(t, actual.MessageID)
+
+	// The most recent record of the blob.
+	actual, err = s.GetAuditRecordByBlob(ctx, blobID)
+	require.NoError(t, err)
+	assertEquivalentAuditRecords(t, second, actual)
+
+	records, err := s.GetAuditRecordsByUser(ctx, user, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	for i, expected := range []*moderation.AuditRecord{second, first, text} {
+		assertEquivalentAuditRecords(t, expected, records[i])
+	}
+
+	records, err = s.GetAuditRecordsByUser(ctx, user, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, second.ID, records[0].ID)
+
+	records, err = s.GetAuditRecordsByUser(ctx, model.MustGenerateUserID(), 10)
+	require.NoError(t, err)
+	require.Empty(t, records)
+}
+
+func testAppealStore(t *testing.T, s moderation.Store) {
+	ctx := context.Background()
+
+	_, err := s.GetAppeal(ctx, uuid.NewString())
+	require.Equal(t, moderation.ErrAppealNotFound, err)
+
+	user := model.MustGenerateUserID()
+	staff := model.MustGenerateUserID()
+	start := time.Now().Truncate(time.Millisecond)
+
+	var appeals []*moderation.Appeal
+	for i := range 3 {
+		appeal := &moderation.Appeal{
+			ID:        uuid.NewString(),
+			RecordID:  uuid.NewString(),
+			UserID:    user,
+			Reason:    fmt.Sprintf("reason%d", i),
+			State:     moderation.AppealStatePending,
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, s.CreateAppeal(ctx, appeal))
+		appeals = append(appeals, appeal)
+	}
+
+	// A record is appealed at most once.
+	require.Equal(t, moderation.ErrAppealExists, s.CreateAppeal(ctx, &moderation.Appeal{
+		ID:        uuid.NewString(),
+		RecordID:  appeals[0].RecordID,
+		UserID:    user,
+		State:     moderation.AppealStatePending,
+		CreatedAt: start,
+	}))
+
+	actual, err := s.GetAppeal(ctx, appeals[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, appeals[0].RecordID, actual.RecordID)
+	require.Equal(t, user.Value, actual.UserID.Value)
+	require.Equal(t, "reason0", actual.Reason)
+	require.Equal(t, moderation.AppealStatePending, actual.State)
+	require.Nil(t, actual.ResolvedBy)
+	require.True(t, actual.ResolvedAt.IsZero())
+
+	pending, err := s.GetAppealsByState(ctx, moderation.AppealStatePending, 2)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, appeals[0].ID, pending[0].ID)
+	require.Equal(t, appeals[1].ID, pending[1].ID)
+
+	resolvedAt := start.Add(time.Hour)
+	require.NoError(t, s.ResolveAppeal(ctx, appeals[0].ID, moderation.AppealStateOverturned, staff, "not hate", resolvedAt))
+	require.Equal(t, moderation.ErrAppealResolved, s.ResolveAppeal(ctx, appeals[0].ID, moderation.AppealStateUpheld, staff, "", resolvedAt))
+	require.Equal(t, moderation.ErrAppealNotFound, s.ResolveAppeal(ctx, uuid.NewString(), moderation.AppealStateUpheld, staff, "", resolvedAt))
+
+	actual, err = s.GetAppeal(ctx, appeals[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, moderation.AppealStateOverturned, actual.State)
+	require.Equal(t, staff.Value, actual.ResolvedBy.Value)
+	require.Equal(t, "not hate", actual.Note)
+	require.True(t, resolvedAt.Equal(actual.ResolvedAt))
+
+	pending, err = s.GetAppealsByState(ctx, moderation.AppealStatePending, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, appeals[1].ID, pending[0].ID)
+
+	overturned, err := s.GetAppealsByState(ctx, moderation.AppealStateOverturned, 10)
+	require.NoError(t, err)
+	require.Len(t, overturned, 1)
+	require.Equal(t, appeals[0].ID, overturned[0].ID)
+}
+
+func assertEquivalentAuditRecords(t *testing.T, expected, actual *moderation.AuditRecord) {
+	require.Equal(t, expected.ID, actual.ID)
+	require.Equal(t, expected.UserID.Value, actual.UserID.Value)
+	require.Equal(t, expected.Method, actual.Method)
+	require.Equal(t, expected.Classifier, actual.Classifier)
+	require.Equal(t, expected.ContentHash, actual.ContentHash)
+	require.Equal(t, expected.FlaggedCategories, actual.FlaggedCategories)
+	require.Equal(t, expected.CategoryScores, actual.CategoryScores)
+	require.Equal(t, expected.Decision, actual.Decision)
+	if expected.BlobID == nil {
+		require.Nil(t, actual.BlobID)
+	} else {
+		require.Equal(t, expected.BlobID.Value, actual.BlobID.Value)
+	}
+	if expected.ChatID == nil {
+		require.Nil(t, actual.ChatID)
+	} else {
+		require.Equal(t, expected.ChatID.Value, actual.ChatID.Value)
+	}
+	if expected.MessageID == nil {
+		require.Nil(t, actual.MessageID)
+	} else {
+		require.Equal(t, expected.MessageID.Value, actual.MessageID.Value)
+	}
+	require.True(t, expected.CreatedAt.Equal(actual.CreatedAt))
+}
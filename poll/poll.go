@@ -0,0 +1,33 @@
+// Package poll runs the polling loop behind the background jobs that implement
+// the OCP worker.Runtime interface.
+package poll
+
+import (
+	"context"
+	"time"
+)
+
+// Loop runs tick every interval until ctx is cancelled, whose error it returns.
+// The interval is measured from the start of each tick, so a slow tick does not
+// push the next one back by its own duration. A tick that reports more work
+// left, typically because it processed a full batch, runs again immediately, so
+// a backlog drains at processing speed rather than one batch per interval.
+//
+// Every server instance runs its own loop, so a job started with it must be
+// safe to run concurrently with itself on other instances.
+func Loop(ctx context.Context, interval time.Duration, tick func(ctx context.Context) (more bool)) error {
+	for {
+		start := time.Now()
+		if tick(ctx) && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		// A non-positive remainder fires immediately, still giving cancellation
+		// the chance to win the select.
+		case <-time.After(interval - time.Since(start)):
+		}
+	}
+}
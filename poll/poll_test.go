@@ -0,0 +1,39 @@
+package poll_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/code-payments/flipcash2-server/poll"
+)
+
+func TestLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// A tick with more work left runs again without waiting out the interval
+	var ticks int
+	err := poll.Loop(ctx, time.Hour, func(context.Context) bool {
+		ticks++
+		if ticks == 3 {
+			cancel()
+		}
+		return true
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 3, ticks)
+
+	// Otherwise the next tick waits for the interval
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ticks = 0
+	err = poll.Loop(ctx, time.Hour, func(context.Context) bool {
+		ticks++
+		return false
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, ticks)
+}
@@ -18,6 +18,10 @@ type Media interface {
 	// blob.ErrBlobInvalid when the blob cannot be used.
 	SetAsProfilePicture(ctx context.Context, ownerID *commonpb.UserId, blobID *blobpb.BlobId) error
 
+	// RevokeProfilePicture withdraws ownerID's public profile's read access to a
+	// blob it no longer shows. It is idempotent.
+	RevokeProfilePicture(ctx context.Context, ownerID *commonpb.UserId, blobID *blobpb.BlobId) error
+
 	// ResolveRenditions returns each original's full rendition set — the ORIGINAL
 	// plus every derived rendition, each with a freshly minted, short-lived download
 	// URL — keyed by string(BlobId.Value). It performs no authorization.
@@ -446,6 +446,19 @@ func (m *InMemoryStore) GetXProfile(ctx context.Context, userID *commonpb.UserId
 	return proto.Clone(val).(*profilepb.XProfile), nil
 }
 
+func (m *InMemoryStore) DeleteProfile(_ context.Context, userID *commonpb.UserId) error {
+	m.Lock()
+	defer m.Unlock()
+
+	key := userIDCacheKey(userID)
+	delete(m.profiles, key)
+	delete(m.phoneHashesByUser, key)
+	delete(m.linkedForPaymentByUser, key)
+	delete(m.xProfilesByUser, key)
+	delete(m.createdAtByUser, key)
+	return nil
+}
+
 func (m *InMemoryStore) reset() {
 	m.Lock()
 	defer m.Unlock()
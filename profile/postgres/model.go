@@ -533,3 +533,16 @@ func dbGetXProfile(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.Use
 	}
 	return res, nil
 }
+
+func dbDeleteProfile(ctx context.Context, pool *pgxpool.Pool, userID *commonpb.UserId) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		clearQuery := `UPDATE ` + usersTableName + ` SET "displayName" = NULL, "profilePictureBlobId" = NULL, "phoneNumber" = NULL, "phoneNumberHash" = NULL, "emailAddress" = NULL, "isPhoneNumberLinkedForPayment" = FALSE WHERE "id" = $1`
+		if _, err := tx.Exec(ctx, clearQuery, pg.Encode(userID.Value)); err != nil {
+			return err
+		}
+
+		deleteXQuery := `DELETE FROM ` + xProfilesTableName + ` WHERE "userId" = $1`
+		_, err := tx.Exec(ctx, deleteXQuery, pg.Encode(userID.Value))
+		return err
+	})
+}
@@ -180,6 +180,10 @@ func (s *store) GetXProfile(ctx context.Context, userID *commonpb.UserId) (*prof
 	return fromXProfileModel(model)
 }
 
+func (s *store) DeleteProfile(ctx context.Context, userID *commonpb.UserId) error {
+	return dbDeleteProfile(ctx, s.pool, userID)
+}
+
 func (s *store) reset() {
 	_, err := s.pool.Exec(context.Background(), `UPDATE `+usersTableName+` SET "displayName" = NULL, "phoneNumber" = NULL, "phoneNumberHash" = NULL, "emailAddress" = NULL, "isPhoneNumberLinkedForPayment" = FALSE`)
 	if err != nil {
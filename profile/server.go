@@ -34,13 +34,27 @@ type Server struct {
 
 	moderator moderation.Client
 
+	// audit records every display name the moderator flags, when set.
+	audit moderation.AuditStore
+
 	xClient *x.Client
 
 	profilepb.UnimplementedProfileServer
 }
 
-func NewServer(log *zap.Logger, authz auth.Authorizer, accounts account.Store, profiles Store, media Media, moderator moderation.Client, xClient *x.Client) *Server {
-	return &Server{
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithModerationAudit records every display name the moderator flags to audit,
+// which is what lets the user appeal it.
+func WithModerationAudit(audit moderation.AuditStore) ServerOption {
+	return func(s *Server) {
+		s.audit = audit
+	}
+}
+
+func NewServer(log *zap.Logger, authz auth.Authorizer, accounts account.Store, profiles Store, media Media, moderator moderation.Client, xClient *x.Client, opts ...ServerOption) *Server {
+	s := &Server{
 		log: log,
 
 		authz: authz,
@@ -54,6 +68,10 @@ func NewServer(log *zap.Logger, authz auth.Authorizer, accounts account.Store, p
 
 		xClient: xClient,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) GetProfile(ctx context.Context, req *profilepb.GetProfileRequest) (*profilepb.GetProfileResponse, error) {
@@ -131,12 +149,27 @@ func (s *Server) SetDisplayName(ctx context.Context, req *profilepb.SetDisplayNa
 		// The display-name result is checked first so that its name-specific
 		// category is the one reported when both classifiers flag. Their scores are
 		// on different scales, so they cannot be merged and ranked together.
-		for _, result := range []*moderation.Result{displayNameResult, textResult} {
+		for _, classified := range []struct {
+			method moderation.Method
+			result *moderation.Result
+		}{
+			{moderation.MethodDisplayName, displayNameResult},
+			{moderation.MethodText, textResult},
+		} {
+			result := classified.result
 			if result == nil || !result.Flagged {
 				continue
 			}
 
 			log.Info("Display name is flagged", zap.Strings("categories", result.FlaggedCategories))
+			if s.audit != nil {
+				classifier := moderation.ClassifierName(s.moderator, classified.method)
+				record := moderation.NewAuditRecord(userID, classified.method, classifier, []byte(req.DisplayName), result)
+				// Best-effort: the name is refused either way.
+				if err := s.audit.PutAuditRecord(ctx, record); err != nil {
+					log.Warn("Failed to record moderation audit record", zap.Error(err))
+				}
+			}
 			return &profilepb.SetDisplayNameResponse{
 				Result:          profilepb.SetDisplayNameResponse_FAILED_MODERATED,
 				FlaggedCategory: moderation.HighestFlaggedCategory(result),
@@ -176,6 +209,12 @@ func (s *Server) SetProfilePicture(ctx context.Context, req *profilepb.SetProfil
 		return &profilepb.SetProfilePictureResponse{Result: profilepb.SetProfilePictureResponse_DENIED}, nil
 	}
 
+	previous, err := s.profiles.GetProfilePictures(ctx, []*commonpb.UserId{userID})
+	if err != nil {
+		log.Warn("Failed to get current profile picture", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to set profile picture")
+	}
+
 	// Grant before persisting, so the picture is readable the instant it is
 	// discoverable — a profile the client could read a blob id from, but not the
 	// blob, would render as a broken image. This also validates the blob, so
@@ -194,6 +233,19 @@ func (s *Server) SetProfilePicture(ctx context.Context, req *profilepb.SetProfil
 		return nil, status.Error(codes.Internal, "failed to set profile picture")
 	}
 
+	// Only once the replacement is persisted does the superseded picture stop
+	// resolving through the profile. The change has already committed, so a
+	// failure here is logged rather than failing the call: the old picture stays
+	// readable, as it was before this change.
+	if old, ok := previous[string(userID.Value)]; ok && !bytes.Equal(old.Value, req.BlobId.Value) {
+		if err := s.media.RevokeProfilePicture(ctx, userID, old); err != nil {
+			log.Warn("Failed to revoke previous profile picture",
+				zap.String("previous_blob_id", blob.IDString(old)),
+				zap.Error(err),
+			)
+		}
+	}
+
 	picture := &blobpb.Media{
 		Renditions: []*blobpb.Rendition{{
 			Role:   blobpb.Rendition_ORIGINAL,
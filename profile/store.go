@@ -108,4 +108,9 @@ type Store interface {
 
 	// GetXProfile gets a user's X profile if it has been linked
 	GetXProfile(ctx context.Context, userID *commonpb.UserId) (*profilepb.XProfile, error)
+
+	// DeleteProfile clears everything the user's profile holds: display name,
+	// profile picture, phone number (and its hash and payment flag), email
+	// address and linked X account. It is a no-op for a user with no profile.
+	DeleteProfile(ctx context.Context, userID *commonpb.UserId) error
 }
@@ -2,6 +2,8 @@ package tests
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"testing"
 
@@ -24,6 +26,7 @@ import (
 	blobmemory "github.com/code-payments/flipcash2-server/blob/memory"
 	"github.com/code-payments/flipcash2-server/model"
 	"github.com/code-payments/flipcash2-server/moderation"
+	moderationmemory "github.com/code-payments/flipcash2-server/moderation/memory"
 	"github.com/code-payments/flipcash2-server/profile"
 	"github.com/code-payments/flipcash2-server/protoutil"
 	"github.com/code-payments/flipcash2-server/social/x"
@@ -402,10 +405,10 @@ func testProfilePicture(t *testing.T, accounts account.Store, profiles profile.S
 		require.NoError(t, err)
 		require.Equal(t, second.Value, getResp.UserProfile.GetProfilePicture().GetRenditions()[0].BlobId.Value)
 
-		// Grants are never revoked, so the superseded picture stays readable through
-		// the profile to anyone still holding its blob id.
+		// The superseded picture's grant is revoked, so it no longer resolves
+		// through the profile for anyone still holding its blob id.
 		require.True(t, isGranted(second))
-		require.True(t, isGranted(first))
+		require.False(t, isGranted(first))
 	})
 
 	t.Run("Setting the same picture again is idempotent", func(t *testing.T) {
@@ -464,7 +467,8 @@ func testDisplayNameModeration(t *testing.T, accounts account.Store, profiles pr
 	media, _, _ := newMedia()
 
 	moderator := &fakeModerator{}
-	serv := profile.NewServer(log, authz, accounts, profiles, media, moderator, x.NewClient())
+	audit := moderationmemory.NewInMemory()
+	serv := profile.NewServer(log, authz, accounts, profiles, media, moderator, x.NewClient(), profile.WithModerationAudit(audit))
 	cc := testutil.RunGRPCServer(t, log, testutil.WithService(func(s *grpc.Server) {
 		profilepb.RegisterProfileServer(s, serv)
 	}))
@@ -490,6 +494,19 @@ func testDisplayNameModeration(t *testing.T, accounts account.Store, profiles pr
 		return resp.GetUserProfile().GetDisplayName()
 	}
 
+	// latestFlag returns the most recent audit record of a flag on the user's
+	// names, asserting how many there are in all.
+	latestFlag := func(count int) *moderation.AuditRecord {
+		t.Helper()
+		records, err := audit.GetAuditRecordsByUser(ctx, userID, 10)
+		require.NoError(t, err)
+		require.Len(t, records, count)
+		if count == 0 {
+			return nil
+		}
+		return records[0]
+	}
+
 	// Each subtest configures the moderator from a clean slate, so a verdict left
 	// behind by an earlier one cannot be what makes a later one pass.
 	reset := func() {
@@ -503,6 +520,7 @@ func testDisplayNameModeration(t *testing.T, accounts account.Store, profiles pr
 		require.NoError(t, err)
 		require.Equal(t, profilepb.SetDisplayNameResponse_OK, resp.Result)
 		require.Equal(t, "clean name", displayName())
+		latestFlag(0)
 	})
 
 	t.Run("Name flagged as text is rejected and not persisted", func(t *testing.T) {
@@ -517,6 +535,14 @@ func testDisplayNameModeration(t *testing.T, accounts account.Store, profiles pr
 
 		// The prior clean name is left untouched.
 		require.Equal(t, "clean name", displayName())
+
+		flag := latestFlag(1)
+		require.Equal(t, moderation.MethodText, flag.Method)
+		require.Equal(t, "*tests.fakeModerator", flag.Classifier)
+		require.Equal(t, []string{"general_nsfw"}, flag.FlaggedCategories)
+		hash := sha256.Sum256([]byte("bad name"))
+		require.Equal(t, hex.EncodeToString(hash[:]), flag.ContentHash)
+		require.Equal(t, moderation.DecisionRejected, flag.Decision)
 	})
 
 	t.Run("Name flagged as a display name is rejected and not persisted", func(t *testing.T) {
@@ -530,6 +556,10 @@ func testDisplayNameModeration(t *testing.T, accounts account.Store, profiles pr
 		require.Equal(t, moderationpb.FlaggedCategory_SPAM, resp.FlaggedCategory)
 
 		require.Equal(t, "clean name", displayName())
+
+		flag := latestFlag(2)
+		require.Equal(t, moderation.MethodDisplayName, flag.Method)
+		require.Equal(t, []string{"solicitation"}, flag.FlaggedCategories)
 	})
 
 	t.Run("Display name category is reported when both classifiers flag", func(t *testing.T) {
@@ -545,6 +575,9 @@ func testDisplayNameModeration(t *testing.T, accounts account.Store, profiles pr
 		require.Equal(t, moderationpb.FlaggedCategory_SPAM, resp.FlaggedCategory)
 
 		require.Equal(t, "clean name", displayName())
+
+		// Only the reported verdict is recorded.
+		require.Equal(t, moderation.MethodDisplayName, latestFlag(3).Method)
 	})
 
 	t.Run("Name the text classifier cannot identify a language for is still allowed", func(t *testing.T) {
@@ -28,6 +28,7 @@ func RunStoreTests(t *testing.T, s profile.Store, teardown func()) {
 		testGetUserIdByPhoneNumber,
 		testLinkPhoneNumberForPayment,
 		testProfilePictures,
+		testDeleteProfile,
 	} {
 		tf(t, s)
 		teardown()
@@ -611,3 +612,55 @@ func testProfilePictures(t *testing.T, s profile.Store) {
 		require.Equal(t, third.Value, pictures[string(otherUserID.Value)].Value)
 	})
 }
+
+func testDeleteProfile(t *testing.T, s profile.Store) {
+	ctx := context.Background()
+
+	userID := model.MustGenerateUserID()
+	otherUserID := model.MustGenerateUserID()
+
+	// Deleting a profile that was never set is a no-op.
+	require.NoError(t, s.DeleteProfile(ctx, userID))
+
+	for _, id := range []*commonpb.UserId{userID, otherUserID} {
+		require.NoError(t, s.SetDisplayName(ctx, id, "name"))
+		require.NoError(t, s.SetProfilePicture(ctx, id, blob.MustGenerateID()))
+	}
+	require.NoError(t, s.LinkPhoneNumber(ctx, userID, "+12223334444", &commonpb.Hash{Value: []byte("phone-hash")}))
+	_, err := s.LinkPhoneNumberForPayment(ctx, userID, "+12223334444")
+	require.NoError(t, err)
+	require.NoError(t, s.LinkEmailAddress(ctx, userID, "someone@gmail.com"))
+	require.NoError(t, s.LinkXAccount(ctx, userID, &profilepb.XProfile{Id: "1", Username: "username"}, "accessToken"))
+
+	require.NoError(t, s.DeleteProfile(ctx, userID))
+	require.NoError(t, s.DeleteProfile(ctx, userID))
+
+	_, err = s.GetProfile(ctx, userID, true)
+	require.ErrorIs(t, err, profile.ErrNotFound)
+
+	_, err = s.GetXProfile(ctx, userID)
+	require.ErrorIs(t, err, profile.ErrNotFound)
+
+	_, err = s.GetUserIdByPhoneNumber(ctx, "+12223334444")
+	require.ErrorIs(t, err, profile.ErrNotFound)
+
+	phones, err := s.GetPhonesByHashes(ctx, []*commonpb.Hash{{Value: []byte("phone-hash")}})
+	require.NoError(t, err)
+	require.Empty(t, phones)
+
+	displayNames, err := s.GetDisplayNames(ctx, []*commonpb.UserId{userID, otherUserID})
+	require.NoError(t, err)
+	require.Len(t, displayNames, 1)
+	require.Equal(t, "name", displayNames[string(otherUserID.Value)])
+
+	pictures, err := s.GetProfilePictures(ctx, []*commonpb.UserId{userID, otherUserID})
+	require.NoError(t, err)
+	require.Len(t, pictures, 1)
+	require.Contains(t, pictures, string(otherUserID.Value))
+
+	// The profile can be set up again afterwards.
+	require.NoError(t, s.SetDisplayName(ctx, userID, "new name"))
+	p, err := s.GetProfile(ctx, userID, false)
+	require.NoError(t, err)
+	require.Equal(t, "new name", p.DisplayName)
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/report"
+)
+
+type store struct {
+	sync.Mutex
+
+	reports []*report.Report
+}
+
+// NewInMemory returns an in-memory report.Store.
+func NewInMemory() report.Store {
+	return &store{}
+}
+
+func (s *store) reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.reports = nil
+}
+
+func (s *store) CreateReport(_ context.Context, r *report.Report) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, existing := range s.reports {
+		if existing.ID == r.ID {
+			return report.ErrReportExists
+		}
+	}
+	s.reports = append(s.reports, r.Clone())
+	return nil
+}
+
+func (s *store) GetReport(_ context.Context, id string) (*report.Report, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, r := range s.reports {
+		if r.ID == id {
+			return r.Clone(), nil
+		}
+	}
+	return nil, report.ErrReportNotFound
+}
+
+func (s *store) GetReportsByState(_ context.Context, state report.State, limit int) ([]*report.Report, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*report.Report, 0)
+	for _, r := range s.reports {
+		if r.State == state {
+			res = append(res, r.Clone())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) GetReportsBySubject(_ context.Context, subjectID *commonpb.UserId, limit int) ([]*report.Report, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	res := make([]*report.Report, 0)
+	for _, r := range s.reports {
+		if bytes.Equal(r.SubjectID.Value, subjectID.Value) {
+			res = append(res, r.Clone())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (s *store) ResolveReport(_ context.Context, id string, state report.State, actions []report.Action, resolvedBy *commonpb.UserId, note string, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, r := range s.reports {
+		if r.ID != id {
+			continue
+		}
+		if r.State != report.StatePending {
+			return report.ErrReportResolved
+		}
+		r.State = state
+		r.Actions = append([]report.Action(nil), actions...)
+		r.ResolvedBy = proto.Clone(resolvedBy).(*commonpb.UserId)
+		r.Note = note
+		r.ResolvedAt = at
+		return nil
+	}
+	return report.ErrReportNotFound
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+)
+
+// Kind is what a report is against.
+type Kind string
+
+const (
+	KindMessage Kind = "message"
+	KindUser    Kind = "user"
+	KindBlob    Kind = "blob"
+)
+
+// State is where a report is in its review.
+type State string
+
+const (
+	// StatePending is a report awaiting staff review.
+	StatePending State = "pending"
+
+	// StateActioned is a report staff acted on (see Action).
+	StateActioned State = "actioned"
+
+	// StateDismissed is a report staff reviewed and took no action on.
+	StateDismissed State = "dismissed"
+)
+
+// Action is what staff did about a report.
+type Action string
+
+const (
+	// ActionTombstoneMessage deletes the reported message on the system's behalf.
+	// It applies to message reports only.
+	ActionTombstoneMessage Action = "tombstone_message"
+
+	// ActionRejectBlob takes the reported blob down. It applies to blob reports
+	// only.
+	ActionRejectBlob Action = "reject_blob"
+
+	// ActionSuspendAccount suspends the reported user's account. It applies to
+	// every kind of report.
+	ActionSuspendAccount Action = "suspend_account"
+)
+
+// Report is a user's report of abuse, and staff's verdict on it.
+type Report struct {
+	ID string
+
+	ReporterID *commonpb.UserId
+
+	Kind Kind
+
+	// SubjectID is the user the report is against: the reported user, the sender
+	// of the reported message, or the owner of the reported blob.
+	SubjectID *commonpb.UserId
+
+	// ChatID and MessageID identify the reported message, set on a message
+	// report only.
+	ChatID    *commonpb.ChatId
+	MessageID *messagingpb.MessageId
+
+	// Snapshot is the reported message as it read when it was reported, kept so
+	// staff can review it after it is edited or deleted. It is set on a message
+	// report only.
+	Snapshot *messagingpb.Message
+
+	// BlobID is the reported blob, set on a blob report only.
+	BlobID *blobpb.BlobId
+
+	// Reason is the reporter's explanation.
+	Reason string
+
+	// Blocked is whether the reporter blocked the subject along with reporting
+	// them.
+	Blocked bool
+
+	State State
+
+	// Actions, ResolvedBy, and Note are what the staff member who resolved the
+	// report did, who they were, and their note, set with ResolvedAt once State
+	// is no longer pending.
+	Actions    []Action
+	ResolvedBy *commonpb.UserId
+	Note       string
+
+	CreatedAt  time.Time
+	ResolvedAt time.Time
+}
+
+// Clone returns a deep copy of the report.
+func (r *Report) Clone() *Report {
+	cloned := *r
+	if r.ReporterID != nil {
+		cloned.ReporterID = proto.Clone(r.ReporterID).(*commonpb.UserId)
+	}
+	if r.SubjectID != nil {
+		cloned.SubjectID = proto.Clone(r.SubjectID).(*commonpb.UserId)
+	}
+	if r.ChatID != nil {
+		cloned.ChatID = proto.Clone(r.ChatID).(*commonpb.ChatId)
+	}
+	if r.MessageID != nil {
+		cloned.MessageID = proto.Clone(r.MessageID).(*messagingpb.MessageId)
+	}
+	if r.Snapshot != nil {
+		cloned.Snapshot = proto.Clone(r.Snapshot).(*messagingpb.Message)
+	}
+	if r.BlobID != nil {
+		cloned.BlobID = proto.Clone(r.BlobID).(*blobpb.BlobId)
+	}
+	if r.ResolvedBy != nil {
+		cloned.ResolvedBy = proto.Clone(r.ResolvedBy).(*commonpb.UserId)
+	}
+	if r.Actions != nil {
+		cloned.Actions = append([]Action(nil), r.Actions...)
+	}
+	return &cloned
+}
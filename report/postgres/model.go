@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/report"
+
+	pg "github.com/code-payments/flipcash2-server/database/postgres"
+)
+
+const (
+	reportsTableName = "flipcash_reports"
+	allReportFields  = `"id", "reporterId", "kind", "subjectId", "chatId", "messageId", "blobId", "snapshot", "reason", "blocked", "state", "actions", "resolvedBy", "note", "createdAt", "resolvedAt"`
+)
+
+type reportModel struct {
+	ID         string     `db:"id"`
+	ReporterID string     `db:"reporterId"`
+	Kind       string     `db:"kind"`
+	SubjectID  string     `db:"subjectId"`
+	ChatID     *string    `db:"chatId"`
+	MessageID  *int64     `db:"messageId"`
+	BlobID     *string    `db:"blobId"`
+	Snapshot   *string    `db:"snapshot"`
+	Reason     string     `db:"reason"`
+	Blocked    bool       `db:"blocked"`
+	State      string     `db:"state"`
+	Actions    []string   `db:"actions"`
+	ResolvedBy *string    `db:"resolvedBy"`
+	Note       string     `db:"note"`
+	CreatedAt  time.Time  `db:"createdAt"`
+	ResolvedAt *time.Time `db:"resolvedAt"`
+}
+
+func toReportModel(r *report.Report) (*reportModel, error) {
+	m := &reportModel{
+		ID:         r.ID,
+		ReporterID: pg.Encode(r.ReporterID.Value),
+		Kind:       string(r.Kind),
+		SubjectID:  pg.Encode(r.SubjectID.Value),
+		Reason:     r.Reason,
+		Blocked:    r.Blocked,
+		State:      string(r.State),
+		Note:       r.Note,
+		CreatedAt:  r.CreatedAt,
+	}
+	if r.ChatID != nil {
+		chatID := pg.Encode(r.ChatID.Value)
+		m.ChatID = &chatID
+	}
+	if r.MessageID != nil {
+		messageID := int64(r.MessageID.Value)
+		m.MessageID = &messageID
+	}
+	if r.BlobID != nil {
+		blobID := pg.Encode(r.BlobID.Value)
+		m.BlobID = &blobID
+	}
+	if r.Snapshot != nil {
+		b, err := proto.Marshal(r.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := pg.Encode(b)
+		m.Snapshot = &snapshot
+	}
+	return m, nil
+}
+
+func fromReportModel(m *reportModel) (*report.Report, error) {
+	reporterID, err := pg.Decode(m.ReporterID)
+	if err != nil {
+		return nil, err
+	}
+	subjectID, err := pg.Decode(m.SubjectID)
+	if err != nil {
+		return nil, err
+	}
+	r := &report.Report{
+		ID:         m.ID,
+		ReporterID: &commonpb.UserId{Value: reporterID},
+		Kind:       report.Kind(m.Kind),
+		SubjectID:  &commonpb.UserId{Value: subjectID},
+		Reason:     m.Reason,
+		Blocked:    m.Blocked,
+		State:      report.State(m.State),
+		Note:       m.Note,
+		CreatedAt:  m.CreatedAt,
+	}
+	if m.ChatID != nil {
+		chatID, err := pg.Decode(*m.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		r.ChatID = &commonpb.ChatId{Value: chatID}
+	}
+	if m.MessageID != nil {
+		r.MessageID = &messagingpb.MessageId{Value: uint64(*m.MessageID)}
+	}
+	if m.BlobID != nil {
+		blobID, err := pg.Decode(*m.BlobID)
+		if err != nil {
+			return nil, err
+		}
+		r.BlobID = &blobpb.BlobId{Value: blobID}
+	}
+	if m.Snapshot != nil {
+		b, err := pg.Decode(*m.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+		r.Snapshot = &messagingpb.Message{}
+		if err := proto.Unmarshal(b, r.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range m.Actions {
+		r.Actions = append(r.Actions, report.Action(action))
+	}
+	if m.ResolvedBy != nil {
+		resolvedBy, err := pg.Decode(*m.ResolvedBy)
+		if err != nil {
+			return nil, err
+		}
+		r.ResolvedBy = &commonpb.UserId{Value: resolvedBy}
+	}
+	if m.ResolvedAt != nil {
+		r.ResolvedAt = *m.ResolvedAt
+	}
+	return r, nil
+}
+
+func (m *reportModel) dbCreate(ctx context.Context, pool *pgxpool.Pool) error {
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO ` + reportsTableName + `(` + allReportFields + `)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, '{}', NULL, $12, $13, NULL)`
+		_, err := tx.Exec(
+			ctx,
+			query,
+			m.ID,
+			m.ReporterID,
+			m.Kind,
+			m.SubjectID,
+			m.ChatID,
+			m.MessageID,
+			m.BlobID,
+			m.Snapshot,
+			m.Reason,
+			m.Blocked,
+			m.State,
+			m.Note,
+			m.CreatedAt.UTC(),
+		)
+		if err == nil {
+			return nil
+		} else if strings.Contains(err.Error(), "23505") { // todo: better utility for detecting unique violations with pgx.Tx
+			return report.ErrReportExists
+		}
+		return err
+	})
+}
+
+func dbGetReport(ctx context.Context, pool *pgxpool.Pool, id string) (*reportModel, error) {
+	res := &reportModel{}
+	query := `SELECT ` + allReportFields + ` FROM ` + reportsTableName + `
+		WHERE "id" = $1`
+	err := pgxscan.Get(ctx, pool, res, query, id)
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, report.ErrReportNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetReportsByState(ctx context.Context, pool *pgxpool.Pool, state report.State, limit int) ([]*reportModel, error) {
+	var res []*reportModel
+	query := `SELECT ` + allReportFields + ` FROM ` + reportsTableName + `
+		WHERE "state" = $1
+		ORDER BY "createdAt" ASC
+		LIMIT $2`
+	err := pgxscan.Select(ctx, pool, &res, query, string(state), limit)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbGetReportsBySubject(ctx context.Context, pool *pgxpool.Pool, subjectID *commonpb.UserId, limit int) ([]*reportModel, error) {
+	var res []*reportModel
+	query := `SELECT ` + allReportFields + ` FROM ` + reportsTableName + `
+		WHERE "subjectId" = $1
+		ORDER BY "createdAt" DESC
+		LIMIT $2`
+	err := pgxscan.Select(ctx, pool, &res, query, pg.Encode(subjectID.Value), limit)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func dbResolveReport(ctx context.Context, pool *pgxpool.Pool, id string, state report.State, actions []report.Action, resolvedBy *commonpb.UserId, note string, at time.Time) error {
+	encodedActions := make([]string, len(actions))
+	for i, action := range actions {
+		encodedActions[i] = string(action)
+	}
+
+	return pg.ExecuteInTx(ctx, pool, func(tx pgx.Tx) error {
+		query := `UPDATE ` + reportsTableName + `
+			SET "state" = $3, "actions" = $4, "resolvedBy" = $5, "note" = $6, "resolvedAt" = $7
+			WHERE "id" = $1 AND "state" = $2`
+		tag, err := tx.Exec(
+			ctx,
+			query,
+			id,
+			string(report.StatePending),
+			string(state),
+			encodedActions,
+			pg.Encode(resolvedBy.Value),
+			note,
+			at.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+
+		// Nothing pending under the id: tell a resolved report from a missing one.
+		var exists bool
+		query = `SELECT EXISTS (SELECT 1 FROM ` + reportsTableName + ` WHERE "id" = $1)`
+		if err := tx.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return report.ErrReportResolved
+		}
+		return report.ErrReportNotFound
+	})
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/report"
+)
+
+type store struct {
+	pool *pgxpool.Pool
+}
+
+// NewInPostgres returns a report.Store backed by Postgres.
+func NewInPostgres(pool *pgxpool.Pool) report.Store {
+	return &store{
+		pool: pool,
+	}
+}
+
+func (s *store) CreateReport(ctx context.Context, r *report.Report) error {
+	model, err := toReportModel(r)
+	if err != nil {
+		return err
+	}
+	return model.dbCreate(ctx, s.pool)
+}
+
+func (s *store) GetReport(ctx context.Context, id string) (*report.Report, error) {
+	model, err := dbGetReport(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	return fromReportModel(model)
+}
+
+func (s *store) GetReportsByState(ctx context.Context, state report.State, limit int) ([]*report.Report, error) {
+	models, err := dbGetReportsByState(ctx, s.pool, state, limit)
+	if err != nil {
+		return nil, err
+	}
+	return fromReportModels(models)
+}
+
+func (s *store) GetReportsBySubject(ctx context.Context, subjectID *commonpb.UserId, limit int) ([]*report.Report, error) {
+	models, err := dbGetReportsBySubject(ctx, s.pool, subjectID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return fromReportModels(models)
+}
+
+func (s *store) ResolveReport(ctx context.Context, id string, state report.State, actions []report.Action, resolvedBy *commonpb.UserId, note string, at time.Time) error {
+	return dbResolveReport(ctx, s.pool, id, state, actions, resolvedBy, note, at)
+}
+
+func fromReportModels(models []*reportModel) ([]*report.Report, error) {
+	res := make([]*report.Report, len(models))
+	for i, model := range models {
+		var err error
+		res[i], err = fromReportModel(model)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *store) reset() {
+	_, err := s.pool.Exec(context.Background(), "DELETE FROM "+reportsTableName)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+// The requests below are for the Server methods backing unpublished Moderation
+// RPCs, each signed over its Payload (see auth.NewPayload).
+
+// ReportMessageRequest reports a message in a chat, blocking its sender too if
+// Block is set.
+type ReportMessageRequest struct {
+	ChatID    *commonpb.ChatId
+	MessageID *messagingpb.MessageId
+	Reason    string
+	Block     bool
+	Ts        time.Time
+	Auth      *commonpb.Auth
+}
+
+func (r *ReportMessageRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ReportMessage",
+		hex.EncodeToString(r.ChatID.GetValue()),
+		strconv.FormatUint(r.MessageID.GetValue(), 10),
+		r.Reason,
+		strconv.FormatBool(r.Block),
+	)
+}
+
+// ReportUserRequest reports a user, blocking them too if Block is set.
+type ReportUserRequest struct {
+	UserID *commonpb.UserId
+	Reason string
+	Block  bool
+	Ts     time.Time
+	Auth   *commonpb.Auth
+}
+
+func (r *ReportUserRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ReportUser", model.UserIDString(r.UserID), r.Reason, strconv.FormatBool(r.Block))
+}
+
+// ReportBlobRequest reports a blob seen where Context names, blocking its owner
+// too if Block is set.
+type ReportBlobRequest struct {
+	BlobID  *blobpb.BlobId
+	Context *blobpb.AccessContext
+	Reason  string
+	Block   bool
+	Ts      time.Time
+	Auth    *commonpb.Auth
+}
+
+func (r *ReportBlobRequest) Payload() proto.Message {
+	var scope string
+	switch s := r.Context.GetScope().(type) {
+	case *blobpb.AccessContext_Chat:
+		scope = "chat:" + hex.EncodeToString(s.Chat.GetValue())
+	case *blobpb.AccessContext_Profile:
+		scope = "profile:" + model.UserIDString(s.Profile)
+	}
+	return requestPayload(r.Ts, "ReportBlob",
+		hex.EncodeToString(r.BlobID.GetValue()),
+		scope,
+		r.Reason,
+		strconv.FormatBool(r.Block),
+	)
+}
+
+// ListReportsRequest lists a page of reports in a state.
+type ListReportsRequest struct {
+	State State
+	Limit int
+	Ts    time.Time
+	Auth  *commonpb.Auth
+}
+
+func (r *ListReportsRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ListReports", string(r.State), strconv.Itoa(r.Limit))
+}
+
+// ListReportsAgainstRequest lists a page of the reports against a user.
+type ListReportsAgainstRequest struct {
+	SubjectID *commonpb.UserId
+	Limit     int
+	Ts        time.Time
+	Auth      *commonpb.Auth
+}
+
+func (r *ListReportsAgainstRequest) Payload() proto.Message {
+	return requestPayload(r.Ts, "ListReportsAgainst", model.UserIDString(r.SubjectID), strconv.Itoa(r.Limit))
+}
+
+// ResolveReportRequest resolves a pending report, taking Actions, or
+// dismissing it if there are none.
+type ResolveReportRequest struct {
+	ReportID string
+	Actions  []Action
+	Note     string
+	Ts       time.Time
+	Auth     *commonpb.Auth
+}
+
+func (r *ResolveReportRequest) Payload() proto.Message {
+	args := []string{r.ReportID, r.Note}
+	for _, action := range r.Actions {
+		args = append(args, string(action))
+	}
+	return requestPayload(r.Ts, "ResolveReport", args...)
+}
+
+// requestPayload is the signed payload of an unpublished Moderation request.
+func requestPayload(ts time.Time, method string, args ...string) proto.Message {
+	return auth.NewPayload("flipcash.moderation.v1.Moderation/"+method, ts, args...)
+}
@@ -0,0 +1,221 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/messaging"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+const (
+	maxNoteLength       = 1000
+	maxReportsListLimit = 100
+)
+
+// ListReports returns a page of reports in a state, oldest first, so the
+// pending queue is worked in order. A zero limit, or one over the cap, lists a
+// full page. Only staff may call it.
+func (s *Server) ListReports(ctx context.Context, req *ListReportsRequest) ([]*Report, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("state", string(req.State)),
+	)
+
+	if err := s.requireStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxReportsListLimit {
+		limit = maxReportsListLimit
+	}
+
+	reports, err := s.reports.GetReportsByState(ctx, req.State, limit)
+	if err != nil {
+		log.Warn("Failed to get reports", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list reports")
+	}
+	return reports, nil
+}
+
+// ListReportsAgainst returns a page of the reports against a user, most recent
+// first, for staff weighing a report against the user's history. A zero limit,
+// or one over the cap, lists a full page. Only staff may call it.
+func (s *Server) ListReportsAgainst(ctx context.Context, req *ListReportsAgainstRequest) ([]*Report, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("subject_id", model.UserIDString(req.SubjectID)),
+	)
+
+	if err := s.requireStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxReportsListLimit {
+		limit = maxReportsListLimit
+	}
+
+	reports, err := s.reports.GetReportsBySubject(ctx, req.SubjectID, limit)
+	if err != nil {
+		log.Warn("Failed to get reports", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list reports")
+	}
+	return reports, nil
+}
+
+// ResolveReport resolves a pending report, taking actions against what was
+// reported, with the caller's note. No actions dismisses it. Every action must
+// apply to the report's kind. They are taken in order before the report is
+// resolved, and each is idempotent, so a failure leaves the report pending for
+// a retry that repeats the ones already taken harmlessly. A message or blob
+// already gone by then needs no action. Only staff may call it.
+func (s *Server) ResolveReport(ctx context.Context, req *ResolveReportRequest) (*Report, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	reportID, actions, note := req.ReportID, req.Actions, req.Note
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("report_id", reportID),
+	)
+
+	if err := s.requireStaff(ctx, caller, log); err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(note) > maxNoteLength {
+		return nil, status.Error(codes.InvalidArgument, "note too long")
+	}
+
+	report, err := s.reports.GetReport(ctx, reportID)
+	if errors.Is(err, ErrReportNotFound) {
+		return nil, status.Error(codes.NotFound, "report not found")
+	} else if err != nil {
+		log.Warn("Failed to get report", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to resolve report")
+	}
+	if report.State != StatePending {
+		return nil, status.Error(codes.FailedPrecondition, "report already resolved")
+	}
+
+	for _, action := range actions {
+		if err := s.checkAction(report, action); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range actions {
+		if err := s.act(ctx, log, caller, report, action, note); err != nil {
+			return nil, err
+		}
+	}
+
+	state := StateDismissed
+	if len(actions) > 0 {
+		state = StateActioned
+	}
+	resolvedAt := time.Now()
+	err = s.reports.ResolveReport(ctx, reportID, state, actions, caller, note, resolvedAt)
+	if errors.Is(err, ErrReportResolved) {
+		return nil, status.Error(codes.FailedPrecondition, "report already resolved")
+	} else if err != nil {
+		log.Warn("Failed to resolve report", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to resolve report")
+	}
+
+	report.State = state
+	report.Actions = actions
+	report.ResolvedBy = caller
+	report.Note = note
+	report.ResolvedAt = resolvedAt
+	log.Info("Report resolved", zap.String("state", string(state)))
+	return report, nil
+}
+
+// checkAction refuses an action that does not apply to the report, or is not
+// enabled, before any action is taken.
+func (s *Server) checkAction(report *Report, action Action) error {
+	switch action {
+	case ActionTombstoneMessage:
+		if report.Kind != KindMessage {
+			return status.Error(codes.InvalidArgument, "only a reported message can be tombstoned")
+		}
+	case ActionRejectBlob:
+		if report.Kind != KindBlob {
+			return status.Error(codes.InvalidArgument, "only a reported blob can be rejected")
+		}
+	case ActionSuspendAccount:
+		if s.suspender == nil {
+			return status.Error(codes.Unimplemented, "account suspension not enabled")
+		}
+	default:
+		return status.Error(codes.InvalidArgument, "unknown action")
+	}
+	return nil
+}
+
+func (s *Server) act(ctx context.Context, log *zap.Logger, caller *commonpb.UserId, report *Report, action Action, note string) error {
+	log = log.With(zap.String("action", string(action)))
+
+	switch action {
+	case ActionTombstoneMessage:
+		_, err := s.sender.Tombstone(ctx, report.ChatID, report.MessageID)
+		if errors.Is(err, messaging.ErrMessageNotFound) {
+			log.Info("Reported message no longer exists")
+			return nil
+		} else if errors.Is(err, messaging.ErrMessageNotDeletable) {
+			return status.Error(codes.FailedPrecondition, "message cannot be tombstoned")
+		} else if err != nil {
+			log.Warn("Failed to tombstone reported message", zap.Error(err))
+			return status.Error(codes.Internal, "failed to tombstone message")
+		}
+	case ActionRejectBlob:
+		err := s.blobs.RejectBlob(ctx, report.BlobID)
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			log.Info("Reported blob no longer exists")
+			return nil
+		} else if err != nil {
+			log.Warn("Failed to reject reported blob", zap.Error(err))
+			return status.Error(codes.Internal, "failed to reject blob")
+		}
+	case ActionSuspendAccount:
+		if err := s.suspender.SuspendAccount(ctx, report.SubjectID, note, caller); err != nil {
+			log.Warn("Failed to suspend reported account", zap.Error(err))
+			return status.Error(codes.Internal, "failed to suspend account")
+		}
+	}
+	log.Info("Acted on report")
+	return nil
+}
+
+// requireStaff gates the review queue on the caller being staff.
+func (s *Server) requireStaff(ctx context.Context, caller *commonpb.UserId, log *zap.Logger) error {
+	isStaff, err := s.accounts.IsStaff(ctx, caller)
+	if err != nil {
+		log.Warn("Failed to check staff status", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check staff status")
+	}
+	if !isStaff {
+		return status.Error(codes.PermissionDenied, "staff only")
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+
+	"github.com/code-payments/flipcash2-server/account"
+	"github.com/code-payments/flipcash2-server/auth"
+	"github.com/code-payments/flipcash2-server/blob"
+	"github.com/code-payments/flipcash2-server/blocklist"
+	"github.com/code-payments/flipcash2-server/chat"
+	"github.com/code-payments/flipcash2-server/messaging"
+	"github.com/code-payments/flipcash2-server/model"
+)
+
+const maxReasonLength = 1000
+
+// ReportedBlobs is what reporting needs of the blob domain: who uploaded a
+// reported blob, where it is shared, and taking it down. It is implemented by
+// blob.Integration, whose BlobOwner and RejectBlob return blob.ErrBlobNotFound
+// for a blob that is missing or collected.
+type ReportedBlobs interface {
+	BlobOwner(ctx context.Context, id *blobpb.BlobId) (*commonpb.UserId, error)
+	HasReadGrant(ctx context.Context, id *blobpb.BlobId, principal blob.Principal) (bool, error)
+	RejectBlob(ctx context.Context, id *blobpb.BlobId) error
+}
+
+var _ ReportedBlobs = (*blob.Integration)(nil)
+
+// AccountSuspender suspends an account staff found abusive on review of a
+// report. It is idempotent. It is implemented by account.Suspender.
+type AccountSuspender interface {
+	SuspendAccount(ctx context.Context, userID *commonpb.UserId, reason string, suspendedBy *commonpb.UserId) error
+}
+
+var _ AccountSuspender = (*account.Suspender)(nil)
+
+// Server is the reporting subsystem: users report a message, a user, or a blob
+// for staff to review (optionally blocking the user responsible at the same
+// time), and staff work the queue of pending reports, acting on them.
+type Server struct {
+	log   *zap.Logger
+	authz auth.Authorizer
+
+	accounts   account.Store
+	chats      chat.Store
+	messages   messaging.Store
+	sender     *messaging.Sender
+	blocklists blocklist.Store
+	blobs      ReportedBlobs
+	reports    Store
+
+	// suspender is optional: without one, ActionSuspendAccount is refused.
+	suspender AccountSuspender
+}
+
+// ServerOption configures optional Server behavior.
+type ServerOption func(*Server)
+
+// WithAccountSuspender enables ActionSuspendAccount.
+func WithAccountSuspender(suspender AccountSuspender) ServerOption {
+	return func(s *Server) {
+		s.suspender = suspender
+	}
+}
+
+func NewServer(
+	log *zap.Logger,
+	authz auth.Authorizer,
+	accounts account.Store,
+	chats chat.Store,
+	messages messaging.Store,
+	sender *messaging.Sender,
+	blocklists blocklist.Store,
+	blobs ReportedBlobs,
+	reports Store,
+	opts ...ServerOption,
+) *Server {
+	s := &Server{
+		log:        log,
+		authz:      authz,
+		accounts:   accounts,
+		chats:      chats,
+		messages:   messages,
+		sender:     sender,
+		blocklists: blocklists,
+		blobs:      blobs,
+		reports:    reports,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ReportMessage reports a message in a chat the caller is a member of. The
+// message is snapshotted into the report as it reads now, so staff can review it
+// even if its sender edits or deletes it first. With Block set, the caller also
+// blocks its sender.
+func (s *Server) ReportMessage(ctx context.Context, req *ReportMessageRequest) (*Report, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	chatID, messageID, reason := req.ChatID, req.MessageID, req.Reason
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.Uint64("message_id", messageID.GetValue()),
+	)
+
+	if err := validateReason(reason); err != nil {
+		return nil, err
+	}
+
+	// Non-members can't probe which message IDs exist, so the membership check
+	// comes first and reports the same as a missing message.
+	isMember, err := s.chats.IsMember(ctx, chatID, caller)
+	if err != nil {
+		log.Warn("Failed to check chat membership", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to report message")
+	}
+	if !isMember {
+		return nil, status.Error(codes.NotFound, "message not found")
+	}
+
+	msg, err := s.messages.GetMessage(ctx, chatID, messageID)
+	if errors.Is(err, messaging.ErrMessageNotFound) {
+		return nil, status.Error(codes.NotFound, "message not found")
+	} else if err != nil {
+		log.Warn("Failed to get message", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to report message")
+	}
+	if msg.SenderID == nil {
+		return nil, status.Error(codes.InvalidArgument, "cannot report a system message")
+	}
+	if msg.IsDeleted() {
+		return nil, status.Error(codes.FailedPrecondition, "message deleted")
+	}
+	if bytes.Equal(msg.SenderID.Value, caller.Value) {
+		return nil, status.Error(codes.InvalidArgument, "cannot report own message")
+	}
+
+	return s.create(ctx, log, &Report{
+		ReporterID: caller,
+		Kind:       KindMessage,
+		SubjectID:  msg.SenderID,
+		ChatID:     chatID,
+		MessageID:  messageID,
+		Snapshot:   msg.ToProto(),
+		Reason:     reason,
+	}, req.Block)
+}
+
+// ReportUser reports a user. With Block set, the caller also blocks them.
+func (s *Server) ReportUser(ctx context.Context, req *ReportUserRequest) (*Report, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	userID, reason := req.UserID, req.Reason
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("subject_id", model.UserIDString(userID)),
+	)
+
+	if err := validateReason(reason); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(userID.GetValue(), caller.Value) {
+		return nil, status.Error(codes.InvalidArgument, "cannot report self")
+	}
+
+	pubKeys, err := s.accounts.GetPubKeys(ctx, userID)
+	if err != nil {
+		log.Warn("Failed to check whether reported user exists", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to report user")
+	}
+	if len(pubKeys) == 0 {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	return s.create(ctx, log, &Report{
+		ReporterID: caller,
+		Kind:       KindUser,
+		SubjectID:  userID,
+		Reason:     reason,
+	}, req.Block)
+}
+
+// ReportBlob reports an uploaded blob — a picture seen in a chat or on a
+// profile, which the request's Context names as GetBlobs takes it. The caller
+// must be able to read the blob there, so a blob id alone cannot be reported.
+// With Block set, the caller also blocks its owner.
+func (s *Server) ReportBlob(ctx context.Context, req *ReportBlobRequest) (*Report, error) {
+	caller, err := s.authz.Authorize(ctx, req.Payload(), &req.Auth)
+	if err != nil {
+		return nil, err
+	}
+	blobID, reason := req.BlobID, req.Reason
+
+	log := s.log.With(
+		zap.String("caller_id", model.UserIDString(caller)),
+		zap.String("blob_id", blob.IDString(blobID)),
+	)
+
+	if err := validateReason(reason); err != nil {
+		return nil, err
+	}
+
+	owner, err := s.blobs.BlobOwner(ctx, blobID)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		return nil, status.Error(codes.NotFound, "blob not found")
+	} else if err != nil {
+		log.Warn("Failed to get blob owner", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to report blob")
+	}
+	if bytes.Equal(owner.Value, caller.Value) {
+		return nil, status.Error(codes.InvalidArgument, "cannot report own blob")
+	}
+
+	// A blob the caller cannot read is reported the same as a missing one, so
+	// its existence is not confirmed to them.
+	canRead, err := s.canReadBlob(ctx, caller, blobID, req.Context)
+	if err != nil {
+		log.Warn("Failed to check blob access", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to report blob")
+	}
+	if !canRead {
+		return nil, status.Error(codes.NotFound, "blob not found")
+	}
+
+	return s.create(ctx, log, &Report{
+		ReporterID: caller,
+		Kind:       KindBlob,
+		SubjectID:  owner,
+		BlobID:     blobID,
+		Reason:     reason,
+	}, req.Block)
+}
+
+// canReadBlob reports whether caller can read a blob through accessContext: it
+// is shared into a chat they are a member of, or is a profile's picture.
+func (s *Server) canReadBlob(ctx context.Context, caller *commonpb.UserId, blobID *blobpb.BlobId, accessContext *blobpb.AccessContext) (bool, error) {
+	var principal blob.Principal
+	switch scope := accessContext.GetScope().(type) {
+	case *blobpb.AccessContext_Chat:
+		isMember, err := s.chats.IsMember(ctx, scope.Chat, caller)
+		if err != nil || !isMember {
+			return false, err
+		}
+		principal = blob.PrincipalForChat(scope.Chat)
+	case *blobpb.AccessContext_Profile:
+		// A profile is public, so its picture is readable by anyone.
+		principal = blob.PrincipalForProfile(scope.Profile)
+	default:
+		return false, nil
+	}
+	return s.blobs.HasReadGrant(ctx, blobID, principal)
+}
+
+// create blocks the report's subject on the reporter's behalf if asked to, then
+// queues the report for review. The block comes first: it is idempotent, so a
+// retry after a failure to queue the report blocks harmlessly again.
+func (s *Server) create(ctx context.Context, log *zap.Logger, report *Report, block bool) (*Report, error) {
+	now := time.Now()
+
+	if block {
+		if _, err := s.blocklists.Block(ctx, report.ReporterID, report.SubjectID, now.UTC()); err != nil {
+			log.Warn("Failed to block reported user", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to block user")
+		}
+		report.Blocked = true
+	}
+
+	report.ID = uuid.NewString()
+	report.State = StatePending
+	report.CreatedAt = now
+	if err := s.reports.CreateReport(ctx, report); err != nil {
+		log.Warn("Failed to create report", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create report")
+	}
+	log.Info("Abuse reported",
+		zap.String("report_id", report.ID),
+		zap.String("kind", string(report.Kind)),
+		zap.Bool("blocked", report.Blocked),
+	)
+	return report, nil
+}
+
+func validateReason(reason string) error {
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return status.Error(codes.InvalidArgument, "reason too long")
+	}
+	return nil
+}
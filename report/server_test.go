@@ -0,0 +1,429 @@
+package report_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	chatpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/chat/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	eventpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/event/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/account"
+	account_memory "github.com/code-payments/flipcash2-server/account/memory"
+	"github.com/code-payments/flipcash2-server/auth"
+	badge_memory "github.com/code-payments/flipcash2-server/badge/memory"
+	"github.com/code-payments/flipcash2-server/blob"
+	blob_memory "github.com/code-payments/flipcash2-server/blob/memory"
+	"github.com/code-payments/flipcash2-server/blocklist"
+	blocklist_memory "github.com/code-payments/flipcash2-server/blocklist/memory"
+	"github.com/code-payments/flipcash2-server/chat"
+	chat_memory "github.com/code-payments/flipcash2-server/chat/memory"
+	"github.com/code-payments/flipcash2-server/event"
+	"github.com/code-payments/flipcash2-server/messaging"
+	messaging_memory "github.com/code-payments/flipcash2-server/messaging/memory"
+	"github.com/code-payments/flipcash2-server/model"
+	profile_memory "github.com/code-payments/flipcash2-server/profile/memory"
+	"github.com/code-payments/flipcash2-server/push"
+	"github.com/code-payments/flipcash2-server/report"
+	"github.com/code-payments/flipcash2-server/report/memory"
+	ocp_data "github.com/code-payments/ocp-server/ocp/data"
+)
+
+// staffAccounts is an account.Store whose staff are added in the test.
+type staffAccounts struct {
+	account.Store
+	staff sync.Map
+}
+
+func (a *staffAccounts) IsStaff(_ context.Context, userID *commonpb.UserId) (bool, error) {
+	_, ok := a.staff.Load(string(userID.Value))
+	return ok, nil
+}
+
+// fakeSuspender is a report.AccountSuspender recording who it suspended.
+type fakeSuspender struct {
+	sync.Mutex
+	suspended []string
+	err       error
+}
+
+func (s *fakeSuspender) SuspendAccount(_ context.Context, userID *commonpb.UserId, reason string, suspendedBy *commonpb.UserId) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.suspended = append(s.suspended, string(userID.Value)+":"+reason+":"+string(suspendedBy.Value))
+	return nil
+}
+
+type testEnv struct {
+	ctx context.Context
+
+	accounts   *staffAccounts
+	chats      chat.Store
+	messages   messaging.Store
+	blocklists blocklist.Store
+	blobs      blob.Store
+	access     blob.AccessStore
+	reports    report.Store
+	sender     *messaging.Sender
+	authz      *auth.StaticAuthorizer
+	server     *report.Server
+	keys       map[string]model.KeyPair
+
+	reporter *commonpb.UserId
+	abuser   *commonpb.UserId
+	staff    *commonpb.UserId
+	chatID   *commonpb.ChatId
+}
+
+func newTestEnv(t *testing.T, opts ...report.ServerOption) *testEnv {
+	ctx := context.Background()
+	log := zaptest.NewLogger(t)
+
+	e := &testEnv{
+		ctx:        ctx,
+		accounts:   &staffAccounts{Store: account_memory.NewInMemory()},
+		chats:      chat_memory.NewInMemory(),
+		messages:   messaging_memory.NewInMemory(),
+		blocklists: blocklist_memory.NewInMemory(),
+		blobs:      blob_memory.NewInMemory(),
+		access:     blob_memory.NewInMemoryAccessStore(),
+		reports:    memory.NewInMemory(),
+		authz:      auth.NewStaticAuthorizer(log),
+		keys:       make(map[string]model.KeyPair),
+	}
+	e.reporter = e.addUser(t)
+	e.abuser = e.addUser(t)
+	e.staff = e.addUser(t)
+	e.accounts.staff.Store(string(e.staff.Value), struct{}{})
+
+	e.chatID = &commonpb.ChatId{Value: append(append([]byte{}, e.reporter.Value...), e.abuser.Value...)}
+	require.NoError(t, e.chats.PutChat(ctx, &chat.Chat{
+		ID:           e.chatID,
+		Type:         chatpb.ChatType_CONTACT_DM,
+		Members:      []*commonpb.UserId{e.reporter, e.abuser},
+		LastActivity: time.Now(),
+	}))
+
+	media := blob.NewIntegration(e.blobs, blob_memory.NewInMemoryStorage(), e.access)
+	bus := event.NewBus[*commonpb.UserId, *eventpb.Event]()
+	e.sender = messaging.NewSender(log, badge_memory.NewInMemory(), e.chats, e.messages, profile_memory.NewInMemory(), e.blocklists, media, ocp_data.NewTestDataProvider(), push.NewNoOpPusher(), bus)
+
+	e.server = report.NewServer(log, e.authz, e.accounts, e.chats, e.messages, e.sender, e.blocklists, media, e.reports, opts...)
+	return e
+}
+
+func (e *testEnv) addUser(t *testing.T) *commonpb.UserId {
+	userID, keyPair := model.MustGenerateUserID(), model.MustGenerateKeyPair()
+	_, err := e.accounts.Bind(e.ctx, userID, keyPair.Proto())
+	require.NoError(t, err)
+	e.authz.Add(userID, keyPair)
+	e.keys[string(userID.Value)] = keyPair
+	return userID
+}
+
+// sign signs req as caller.
+func (e *testEnv) sign(t *testing.T, caller *commonpb.UserId, req interface{ Payload() proto.Message }, authField **commonpb.Auth) {
+	require.NoError(t, e.keys[string(caller.Value)].Auth(req.Payload(), authField))
+}
+
+func (e *testEnv) reportMessage(t *testing.T, caller *commonpb.UserId, chatID *commonpb.ChatId, messageID *messagingpb.MessageId, reason string, block bool) (*report.Report, error) {
+	req := &report.ReportMessageRequest{ChatID: chatID, MessageID: messageID, Reason: reason, Block: block, Ts: time.Now()}
+	e.sign(t, caller, req, &req.Auth)
+	return e.server.ReportMessage(e.ctx, req)
+}
+
+func (e *testEnv) reportUser(t *testing.T, caller, userID *commonpb.UserId, reason string, block bool) (*report.Report, error) {
+	req := &report.ReportUserRequest{UserID: userID, Reason: reason, Block: block, Ts: time.Now()}
+	e.sign(t, caller, req, &req.Auth)
+	return e.server.ReportUser(e.ctx, req)
+}
+
+func (e *testEnv) reportBlob(t *testing.T, caller *commonpb.UserId, blobID *blobpb.BlobId, accessContext *blobpb.AccessContext, reason string, block bool) (*report.Report, error) {
+	req := &report.ReportBlobRequest{BlobID: blobID, Context: accessContext, Reason: reason, Block: block, Ts: time.Now()}
+	e.sign(t, caller, req, &req.Auth)
+	return e.server.ReportBlob(e.ctx, req)
+}
+
+func (e *testEnv) listReports(t *testing.T, caller *commonpb.UserId, state report.State, limit int) ([]*report.Report, error) {
+	req := &report.ListReportsRequest{State: state, Limit: limit, Ts: time.Now()}
+	e.sign(t, caller, req, &req.Auth)
+	return e.server.ListReports(e.ctx, req)
+}
+
+func (e *testEnv) listReportsAgainst(t *testing.T, caller, subjectID *commonpb.UserId, limit int) ([]*report.Report, error) {
+	req := &report.ListReportsAgainstRequest{SubjectID: subjectID, Limit: limit, Ts: time.Now()}
+	e.sign(t, caller, req, &req.Auth)
+	return e.server.ListReportsAgainst(e.ctx, req)
+}
+
+func (e *testEnv) resolveReport(t *testing.T, caller *commonpb.UserId, reportID string, actions []report.Action, note string) (*report.Report, error) {
+	req := &report.ResolveReportRequest{ReportID: reportID, Actions: actions, Note: note, Ts: time.Now()}
+	e.sign(t, caller, req, &req.Auth)
+	return e.server.ResolveReport(e.ctx, req)
+}
+
+func (e *testEnv) putMessage(t *testing.T, senderID *commonpb.UserId, text string) *messaging.Message {
+	clientID := make([]byte, 16)
+	_, err := rand.Read(clientID)
+	require.NoError(t, err)
+
+	content := []*messagingpb.Content{{
+		Type: &messagingpb.Content_Text{Text: &messagingpb.TextContent{Text: text}},
+	}}
+	msg, _, err := e.messages.PutMessage(e.ctx, e.chatID, senderID, content, time.Now().UTC(), &messagingpb.ClientMessageId{Value: clientID}, senderID != nil)
+	require.NoError(t, err)
+	return msg
+}
+
+func (e *testEnv) putReadyBlob(t *testing.T, owner *commonpb.UserId) *blobpb.BlobId {
+	id := uuid.New()
+	blobID := &blobpb.BlobId{Value: id[:]}
+	require.NoError(t, e.blobs.CreatePending(e.ctx, &blob.Blob{
+		ID:         blobID,
+		Rendition:  blob.RenditionOriginal,
+		Owner:      owner,
+		State:      blob.StatePending,
+		StorageKey: "images/x/original.png",
+		MimeType:   "image/png",
+		SizeBytes:  1,
+	}))
+	_, err := e.blobs.Advance(e.ctx, blobID, blob.StateReady, nil)
+	require.NoError(t, err)
+	return blobID
+}
+
+// shareBlob grants a blob to principal, as attaching it there does.
+func (e *testEnv) shareBlob(t *testing.T, blobID *blobpb.BlobId, principal blob.Principal) {
+	require.NoError(t, e.access.Grant(e.ctx, &blob.Grant{BlobID: blobID, Principal: principal, Permission: blob.PermissionRead}))
+}
+
+func requireCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+}
+
+func TestServer_ReportMessage(t *testing.T) {
+	e := newTestEnv(t)
+
+	msg := e.putMessage(t, e.abuser, "abusive")
+
+	reported, err := e.reportMessage(t, e.reporter, e.chatID, msg.ID, "harassment", true)
+	require.NoError(t, err)
+	require.Equal(t, report.KindMessage, reported.Kind)
+	require.Equal(t, report.StatePending, reported.State)
+	require.Equal(t, e.abuser.Value, reported.SubjectID.Value)
+	require.True(t, reported.Blocked)
+
+	blocked, err := e.blocklists.IsBlocked(e.ctx, e.reporter, e.abuser)
+	require.NoError(t, err)
+	require.True(t, blocked)
+
+	// The snapshot outlives the sender deleting the message.
+	_, err = e.messages.DeleteMessage(e.ctx, e.chatID, msg.ID, e.abuser, time.Now(), msg.EventSequence)
+	require.NoError(t, err)
+	stored, err := e.reports.GetReport(e.ctx, reported.ID)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(msg.ToProto(), stored.Snapshot))
+	require.Equal(t, "abusive", stored.Snapshot.Content[0].GetText().Text)
+
+	// Reporting without blocking leaves the blocklist alone.
+	other := e.putMessage(t, e.reporter, "hello")
+	reported, err = e.reportMessage(t, e.abuser, e.chatID, other.ID, "", false)
+	require.NoError(t, err)
+	require.False(t, reported.Blocked)
+	blocked, err = e.blocklists.IsBlocked(e.ctx, e.abuser, e.reporter)
+	require.NoError(t, err)
+	require.False(t, blocked)
+
+	_, err = e.reportMessage(t, e.reporter, e.chatID, msg.ID, "", false)
+	requireCode(t, err, codes.FailedPrecondition)
+
+	_, err = e.reportMessage(t, e.reporter, e.chatID, other.ID, "", false)
+	requireCode(t, err, codes.InvalidArgument)
+
+	sysMsg := e.putMessage(t, nil, "system")
+	_, err = e.reportMessage(t, e.reporter, e.chatID, sysMsg.ID, "", false)
+	requireCode(t, err, codes.InvalidArgument)
+
+	_, err = e.reportMessage(t, e.reporter, e.chatID, &messagingpb.MessageId{Value: 999}, "", false)
+	requireCode(t, err, codes.NotFound)
+
+	// A non-member can't tell a message exists.
+	fresh := e.putMessage(t, e.abuser, "abusive")
+	_, err = e.reportMessage(t, e.staff, e.chatID, fresh.ID, "", false)
+	requireCode(t, err, codes.NotFound)
+
+	_, err = e.reportMessage(t, e.reporter, e.chatID, fresh.ID, strings.Repeat("a", 1001), false)
+	requireCode(t, err, codes.InvalidArgument)
+}
+
+func TestServer_ReportUserAndBlob(t *testing.T) {
+	e := newTestEnv(t)
+
+	reported, err := e.reportUser(t, e.reporter, e.abuser, "spam", true)
+	require.NoError(t, err)
+	require.Equal(t, report.KindUser, reported.Kind)
+	require.True(t, reported.Blocked)
+
+	_, err = e.reportUser(t, e.reporter, e.reporter, "", false)
+	requireCode(t, err, codes.InvalidArgument)
+	_, err = e.reportUser(t, e.reporter, model.MustGenerateUserID(), "", false)
+	requireCode(t, err, codes.NotFound)
+
+	inChat := &blobpb.AccessContext{Scope: &blobpb.AccessContext_Chat{Chat: e.chatID}}
+	onProfile := &blobpb.AccessContext{Scope: &blobpb.AccessContext_Profile{Profile: e.abuser}}
+
+	// A blob is reported where the caller can read it.
+	blobID := e.putReadyBlob(t, e.abuser)
+	_, err = e.reportBlob(t, e.reporter, blobID, inChat, "explicit", false)
+	requireCode(t, err, codes.NotFound)
+	e.shareBlob(t, blobID, blob.PrincipalForChat(e.chatID))
+
+	reported, err = e.reportBlob(t, e.reporter, blobID, inChat, "explicit", false)
+	require.NoError(t, err)
+	require.Equal(t, report.KindBlob, reported.Kind)
+	require.Equal(t, e.abuser.Value, reported.SubjectID.Value)
+	require.Equal(t, blobID.Value, reported.BlobID.Value)
+
+	// A non-member can't tell a blob shared into the chat exists, and one that
+	// is not a profile picture isn't reported through the profile.
+	_, err = e.reportBlob(t, e.staff, blobID, inChat, "", false)
+	requireCode(t, err, codes.NotFound)
+	_, err = e.reportBlob(t, e.staff, blobID, onProfile, "", false)
+	requireCode(t, err, codes.NotFound)
+	_, err = e.reportBlob(t, e.staff, blobID, nil, "", false)
+	requireCode(t, err, codes.NotFound)
+
+	picture := e.putReadyBlob(t, e.abuser)
+	e.shareBlob(t, picture, blob.PrincipalForProfile(e.abuser))
+	_, err = e.reportBlob(t, e.staff, picture, onProfile, "", false)
+	require.NoError(t, err)
+
+	_, err = e.reportBlob(t, e.abuser, blobID, inChat, "", false)
+	requireCode(t, err, codes.InvalidArgument)
+	missing := uuid.New()
+	_, err = e.reportBlob(t, e.reporter, &blobpb.BlobId{Value: missing[:]}, inChat, "", false)
+	requireCode(t, err, codes.NotFound)
+
+	against, err := e.listReportsAgainst(t, e.staff, e.abuser, 0)
+	require.NoError(t, err)
+	require.Len(t, against, 3)
+}
+
+func TestServer_ReviewRequiresStaff(t *testing.T) {
+	e := newTestEnv(t)
+
+	reported, err := e.reportUser(t, e.reporter, e.abuser, "", false)
+	require.NoError(t, err)
+
+	_, err = e.listReports(t, e.reporter, report.StatePending, 0)
+	requireCode(t, err, codes.PermissionDenied)
+	_, err = e.listReportsAgainst(t, e.reporter, e.abuser, 0)
+	requireCode(t, err, codes.PermissionDenied)
+	_, err = e.resolveReport(t, e.reporter, reported.ID, nil, "")
+	requireCode(t, err, codes.PermissionDenied)
+
+	pending, err := e.listReports(t, e.staff, report.StatePending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+}
+
+func TestServer_ResolveReport(t *testing.T) {
+	e := newTestEnv(t)
+
+	msg := e.putMessage(t, e.abuser, "abusive")
+	messageReport, err := e.reportMessage(t, e.reporter, e.chatID, msg.ID, "", false)
+	require.NoError(t, err)
+
+	// An action must apply to the report's kind.
+	_, err = e.resolveReport(t, e.staff, messageReport.ID, []report.Action{report.ActionRejectBlob}, "")
+	requireCode(t, err, codes.InvalidArgument)
+	_, err = e.resolveReport(t, e.staff, messageReport.ID, []report.Action{"unknown"}, "")
+	requireCode(t, err, codes.InvalidArgument)
+
+	// Suspension needs a suspender, and nothing is acted on without one.
+	_, err = e.resolveReport(t, e.staff, messageReport.ID, []report.Action{report.ActionTombstoneMessage, report.ActionSuspendAccount}, "")
+	requireCode(t, err, codes.Unimplemented)
+	stored, err := e.messages.GetMessage(e.ctx, e.chatID, msg.ID)
+	require.NoError(t, err)
+	require.False(t, stored.IsDeleted())
+
+	resolved, err := e.resolveReport(t, e.staff, messageReport.ID, []report.Action{report.ActionTombstoneMessage}, "slur")
+	require.NoError(t, err)
+	require.Equal(t, report.StateActioned, resolved.State)
+	require.Equal(t, e.staff.Value, resolved.ResolvedBy.Value)
+
+	stored, err = e.messages.GetMessage(e.ctx, e.chatID, msg.ID)
+	require.NoError(t, err)
+	require.True(t, stored.IsDeleted())
+	require.Nil(t, stored.Content[0].GetDeleted().DeletedBy)
+
+	_, err = e.resolveReport(t, e.staff, messageReport.ID, nil, "")
+	requireCode(t, err, codes.FailedPrecondition)
+	_, err = e.resolveReport(t, e.staff, uuid.NewString(), nil, "")
+	requireCode(t, err, codes.NotFound)
+
+	// A reported blob is taken down.
+	blobID := e.putReadyBlob(t, e.abuser)
+	e.shareBlob(t, blobID, blob.PrincipalForChat(e.chatID))
+	inChat := &blobpb.AccessContext{Scope: &blobpb.AccessContext_Chat{Chat: e.chatID}}
+	blobReport, err := e.reportBlob(t, e.reporter, blobID, inChat, "", false)
+	require.NoError(t, err)
+	_, err = e.resolveReport(t, e.staff, blobReport.ID, []report.Action{report.ActionRejectBlob}, "")
+	require.NoError(t, err)
+	taken, err := e.blobs.GetByID(e.ctx, blobID)
+	require.NoError(t, err)
+	require.Equal(t, blob.StateRejected, taken.State)
+	require.Equal(t, blob.RejectionReasonTakenDown, taken.Rejection.Reason)
+
+	// No actions dismisses.
+	userReport, err := e.reportUser(t, e.reporter, e.abuser, "", false)
+	require.NoError(t, err)
+	dismissed, err := e.resolveReport(t, e.staff, userReport.ID, nil, "not abuse")
+	require.NoError(t, err)
+	require.Equal(t, report.StateDismissed, dismissed.State)
+
+	pending, err := e.listReports(t, e.staff, report.StatePending, 0)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestServer_SuspendAccount(t *testing.T) {
+	suspender := &fakeSuspender{}
+	e := newTestEnv(t, report.WithAccountSuspender(suspender))
+
+	reported, err := e.reportUser(t, e.reporter, e.abuser, "", false)
+	require.NoError(t, err)
+
+	// A failed action leaves the report pending for a retry.
+	suspender.err = errors.New("unavailable")
+	_, err = e.resolveReport(t, e.staff, reported.ID, []report.Action{report.ActionSuspendAccount}, "repeat offender")
+	requireCode(t, err, codes.Internal)
+	stored, err := e.reports.GetReport(e.ctx, reported.ID)
+	require.NoError(t, err)
+	require.Equal(t, report.StatePending, stored.State)
+
+	suspender.err = nil
+	resolved, err := e.resolveReport(t, e.staff, reported.ID, []report.Action{report.ActionSuspendAccount}, "repeat offender")
+	require.NoError(t, err)
+	require.Equal(t, report.StateActioned, resolved.State)
+	require.Equal(t, []string{string(e.abuser.Value) + ":repeat offender:" + string(e.staff.Value)}, suspender.suspended)
+}
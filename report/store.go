@@ -0,0 +1,44 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+)
+
+var (
+	// ErrReportNotFound is returned when no report matches.
+	ErrReportNotFound = errors.New("report not found")
+
+	// ErrReportExists is returned by Store.CreateReport when a report with the
+	// same ID already exists.
+	ErrReportExists = errors.New("report already exists")
+
+	// ErrReportResolved is returned by Store.ResolveReport when the report was
+	// already resolved.
+	ErrReportResolved = errors.New("report already resolved")
+)
+
+// Store persists reports and their review.
+type Store interface {
+	// CreateReport records a pending report, or returns ErrReportExists.
+	CreateReport(ctx context.Context, report *Report) error
+
+	// GetReport returns the report with the given ID, or ErrReportNotFound.
+	GetReport(ctx context.Context, id string) (*Report, error)
+
+	// GetReportsByState returns up to limit reports in state, oldest first.
+	GetReportsByState(ctx context.Context, state State, limit int) ([]*Report, error)
+
+	// GetReportsBySubject returns up to limit reports against the user, most
+	// recent first.
+	GetReportsBySubject(ctx context.Context, subjectID *commonpb.UserId, limit int) ([]*Report, error)
+
+	// ResolveReport moves a pending report to state (actioned or dismissed),
+	// recording the actions taken, who resolved it, their note, and when. It
+	// returns ErrReportNotFound if there is no such report, and ErrReportResolved
+	// if it is no longer pending.
+	ResolveReport(ctx context.Context, id string, state State, actions []Action, resolvedBy *commonpb.UserId, note string, at time.Time) error
+}
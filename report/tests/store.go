@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	blobpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/blob/v1"
+	commonpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/common/v1"
+	messagingpb "github.com/code-payments/flipcash2-protobuf-api/generated/go/messaging/v1"
+
+	"github.com/code-payments/flipcash2-server/model"
+	"github.com/code-payments/flipcash2-server/report"
+)
+
+func RunStoreTests(t *testing.T, s report.Store, teardown func()) {
+	for _, tf := range []func(t *testing.T, s report.Store){
+		testStore_RoundTrip,
+		testStore_Queue,
+		testStore_Resolve,
+	} {
+		tf(t, s)
+		teardown()
+	}
+}
+
+func testStore_RoundTrip(t *testing.T, s report.Store) {
+	ctx := context.Background()
+
+	_, err := s.GetReport(ctx, uuid.NewString())
+	require.Equal(t, report.ErrReportNotFound, err)
+
+	start := time.Now().Truncate(time.Millisecond)
+	chatID := &commonpb.ChatId{Value: append(model.MustGenerateUserID().Value, model.MustGenerateUserID().Value...)}
+	sender := model.MustGenerateUserID()
+	snapshot := &messagingpb.Message{
+		MessageId:     &messagingpb.MessageId{Value: 7},
+		SenderId:      sender,
+		EventSequence: 9,
+		Content: []*messagingpb.Content{{
+			Type: &messagingpb.Content_Text{Text: &messagingpb.TextContent{Text: "abuse"}},
+		}},
+	}
+
+	message := &report.Report{
+		ID:         uuid.NewString(),
+		ReporterID: model.MustGenerateUserID(),
+		Kind:       report.KindMessage,
+		SubjectID:  sender,
+		ChatID:     chatID,
+		MessageID:  &messagingpb.MessageId{Value: 7},
+		Snapshot:   snapshot,
+		Reason:     "harassment",
+		Blocked:    true,
+		State:      report.StatePending,
+		CreatedAt:  start,
+	}
+	require.NoError(t, s.CreateReport(ctx, message))
+	require.Equal(t, report.ErrReportExists, s.CreateReport(ctx, message))
+
+	actual, err := s.GetReport(ctx, message.ID)
+	require.NoError(t, err)
+	require.Equal(t, message.ReporterID.Value, actual.ReporterID.Value)
+	require.Equal(t, report.KindMessage, actual.Kind)
+	require.Equal(t, sender.Value, actual.SubjectID.Value)
+	require.Equal(t, chatID.Value, actual.ChatID.Value)
+	require.EqualValues(t, 7, actual.MessageID.Value)
+	require.True(t, proto.Equal(snapshot, actual.Snapshot))
+	require.Nil(t, actual.BlobID)
+	require.Equal(t, "harassment", actual.Reason)
+	require.True(t, actual.Blocked)
+	require.Equal(t, report.StatePending, actual.State)
+	require.Empty(t, actual.Actions)
+	require.Nil(t, actual.ResolvedBy)
+	require.True(t, start.Equal(actual.CreatedAt))
+	require.True(t, actual.ResolvedAt.IsZero())
+
+	blobID := &blobpb.BlobId{Value: model.MustGenerateUserID().Value}
+	blobReport := &report.Report{
+		ID:         uuid.NewString(),
+		ReporterID: model.MustGenerateUserID(),
+		Kind:       report.KindBlob,
+		SubjectID:  model.MustGenerateUserID(),
+		BlobID:     blobID,
+		State:      report.StatePending,
+		CreatedAt:  start,
+	}
+	require.NoError(t, s.CreateReport(ctx, blobReport))
+
+	actual, err = s.GetReport(ctx, blobReport.ID)
+	require.NoError(t, err)
+	require.Equal(t, report.KindBlob, actual.Kind)
+	require.Equal(t, blobID.Value, actual.BlobID.Value)
+	require.Nil(t, actual.ChatID)
+	require.Nil(t, actual.MessageID)
+	require.Nil(t, actual.Snapshot)
+	require.Empty(t, actual.Reason)
+	require.False(t, actual.Blocked)
+}
+
+func testStore_Queue(t *testing.T, s report.Store) {
+	ctx := context.Background()
+
+	start := time.Now().Truncate(time.Millisecond)
+	subject := model.MustGenerateUserID()
+
+	var reports []*report.Report
+	for i := range 3 {
+		r := &report.Report{
+			ID:         uuid.NewString(),
+			ReporterID: model.MustGenerateUserID(),
+			Kind:       report.KindUser,
+			SubjectID:  subject,
+			Reason:     fmt.Sprintf("reason%d", i),
+			State:      report.StatePending,
+			CreatedAt:  start.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, s.CreateReport(ctx, r))
+		reports = append(reports, r)
+	}
+	require.NoError(t, s.CreateReport(ctx, &report.Report{
+		ID:         uuid.NewString(),
+		ReporterID: model.MustGenerateUserID(),
+		Kind:       report.KindUser,
+		SubjectID:  model.MustGenerateUserID(),
+		State:      report.StatePending,
+		CreatedAt:  start.Add(time.Hour),
+	}))
+
+	// The queue is worked oldest first.
+	pending, err := s.GetReportsByState(ctx, report.StatePending, 2)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, reports[0].ID, pending[0].ID)
+	require.Equal(t, reports[1].ID, pending[1].ID)
+
+	dismissed, err := s.GetReportsByState(ctx, report.StateDismissed, 10)
+	require.NoError(t, err)
+	require.Empty(t, dismissed)
+
+	// A subject's history is most recent first.
+	against, err := s.GetReportsBySubject(ctx, subject, 10)
+	require.NoError(t, err)
+	require.Len(t, against, 3)
+	require.Equal(t, reports[2].ID, against[0].ID)
+	require.Equal(t, reports[0].ID, against[2].ID)
+
+	against, err = s.GetReportsBySubject(ctx, model.MustGenerateUserID(), 10)
+	require.NoError(t, err)
+	require.Empty(t, against)
+}
+
+func testStore_Resolve(t *testing.T, s report.Store) {
+	ctx := context.Background()
+
+	start := time.Now().Truncate(time.Millisecond)
+	staff := model.MustGenerateUserID()
+
+	r := &report.Report{
+		ID:         uuid.NewString(),
+		ReporterID: model.MustGenerateUserID(),
+		Kind:       report.KindBlob,
+		SubjectID:  model.MustGenerateUserID(),
+		BlobID:     &blobpb.BlobId{Value: model.MustGenerateUserID().Value},
+		State:      report.StatePending,
+		CreatedAt:  start,
+	}
+	require.NoError(t, s.CreateReport(ctx, r))
+
+	actions := []report.Action{report.ActionRejectBlob, report.ActionSuspendAccount}
+	resolvedAt := start.Add(time.Hour)
+	require.NoError(t, s.ResolveReport(ctx, r.ID, report.StateActioned, actions, staff, "explicit", resolvedAt))
+	require.Equal(t, report.ErrReportResolved, s.ResolveReport(ctx, r.ID, report.StateDismissed, nil, staff, "", resolvedAt))
+	require.Equal(t, report.ErrReportNotFound, s.ResolveReport(ctx, uuid.NewString(), report.StateDismissed, nil, staff, "", resolvedAt))
+
+	actual, err := s.GetReport(ctx, r.ID)
+	require.NoError(t, err)
+	require.Equal(t, report.StateActioned, actual.State)
+	require.Equal(t, actions, actual.Actions)
+	require.Equal(t, staff.Value, actual.ResolvedBy.Value)
+	require.Equal(t, "explicit", actual.Note)
+	require.True(t, resolvedAt.Equal(actual.ResolvedAt))
+
+	pending, err := s.GetReportsByState(ctx, report.StatePending, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+	actioned, err := s.GetReportsByState(ctx, report.StateActioned, 10)
+	require.NoError(t, err)
+	require.Len(t, actioned, 1)
+}
@@ -94,13 +94,14 @@ func (s *Server) Resolve(ctx context.Context, req *resolverpb.ResolveRequest) (*
 }
 
 func (s *Server) getPaymentAddress(ctx context.Context, log *zap.Logger, userID *commonpb.UserId) (*commonpb.PublicKey, error) {
-	pubKeys, err := s.accounts.GetPubKeys(ctx, userID)
-	if err != nil {
-		log.With(zap.Error(err)).Warn("Failure getting public keys for resolved user")
-		return nil, status.Error(codes.Internal, "")
-	}
-	if len(pubKeys) == 0 {
+	// The primary key owns the user's payment account; any other key is just a
+	// device the user signs in on.
+	pubKey, err := s.accounts.GetPrimaryPubKey(ctx, userID)
+	if errors.Is(err, account.ErrNotFound) {
 		return nil, nil
+	} else if err != nil {
+		log.With(zap.Error(err)).Warn("Failure getting primary public key for resolved user")
+		return nil, status.Error(codes.Internal, "")
 	}
-	return pubKeys[0], nil
+	return pubKey, nil
 }
@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
@@ -85,6 +86,15 @@ func testServer_Resolve_ByUserID_OK(t *testing.T, accounts account.Store, profil
 	require.NoError(t, err)
 	require.NoError(t, accounts.SetRegistrationFlag(ctx, targetID, true))
 
+	// Device keys added later do not change the payment address.
+	for _, label := range []string{"tablet", "laptop"} {
+		require.NoError(t, accounts.AddPubKey(ctx, targetID, &account.Key{
+			PubKey:    model.MustGenerateKeyPair().Proto(),
+			Label:     label,
+			CreatedAt: time.Now(),
+		}))
+	}
+
 	req := &resolverpb.ResolveRequest{
 		Identifier: &resolverpb.Identifier{
 			Kind: &resolverpb.Identifier_UserId{